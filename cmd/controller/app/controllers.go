@@ -23,7 +23,9 @@ import (
 	"kubesphere.io/devops/controllers/gitrepository"
 	"kubesphere.io/devops/controllers/jenkins/devopscredential"
 	"kubesphere.io/devops/controllers/jenkins/devopsproject"
+	"kubesphere.io/devops/pkg/admission"
 	"kubesphere.io/devops/pkg/jwt/token"
+	"kubesphere.io/devops/pkg/metrics"
 	"kubesphere.io/devops/pkg/server/errors"
 
 	"github.com/jenkins-zh/jenkins-client/pkg/core"
@@ -34,6 +36,7 @@ import (
 	"kubesphere.io/devops/controllers/jenkins/pipelinerun"
 	"kubesphere.io/devops/pkg/client/devops"
 	"kubesphere.io/devops/pkg/client/k8s"
+	"kubesphere.io/devops/pkg/client/s3"
 	"kubesphere.io/devops/pkg/informers"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 )
@@ -48,6 +51,16 @@ func addControllers(mgr manager.Manager, client k8s.Client, informerFactory info
 	reconcilers := getAllControllers(mgr, client, informerFactory, devopsClient, s, jenkinsCore)
 	reconcilers["pipeline"] = func(mgr manager.Manager) (err error) {
 		tokenIssuer := token.NewTokenIssuer(s.JWTOptions.Secret, s.JWTOptions.MaximumClockSkew)
+		var s3Client s3.Interface
+		if s.FeatureOptions.PipelineRunDataStore == "s3" {
+			if s.S3Options == nil || s.S3Options.Endpoint == "" {
+				return errors.New("the s3 options are required by the s3 PipelineRun data store")
+			}
+			if s3Client, err = s3.NewS3Client(s.S3Options); err != nil {
+				klog.Errorf("unable to create the s3 client, err: %v", err)
+				return
+			}
+		}
 		// add PipelineRun controller
 		if err = (&pipelinerun.Reconciler{
 			Client:               mgr.GetClient(),
@@ -56,11 +69,46 @@ func addControllers(mgr manager.Manager, client k8s.Client, informerFactory info
 			JenkinsCore:          jenkinsCore,
 			TokenIssuer:          tokenIssuer,
 			PipelineRunDataStore: s.FeatureOptions.PipelineRunDataStore,
+			S3Client:             s3Client,
+			RunnerImage:          s.FeatureOptions.PipelineRunnerImage,
+			ResyncPeriod:         s.FeatureOptions.PipelineRunResyncPeriod,
 		}).SetupWithManager(mgr); err != nil {
 			klog.Errorf("unable to create pipelinerun-controller, err: %v", err)
 			return
 		}
 
+		// add PipelineRun garbage collector
+		if err = (&pipelinerun.GCReconciler{
+			Client:   mgr.GetClient(),
+			MaxAge:   s.FeatureOptions.PipelineRunMaxAge,
+			MaxCount: s.FeatureOptions.PipelineRunMaxCount,
+		}).SetupWithManager(mgr); err != nil {
+			klog.Errorf("unable to create pipelinerun-gc-controller, err: %v", err)
+			return
+		}
+
+		// add PipelineSchedule controller
+		if err = (&pipelinerun.ScheduleReconciler{
+			Client: mgr.GetClient(),
+		}).SetupWithManager(mgr); err != nil {
+			klog.Errorf("unable to create pipelineschedule-controller, err: %v", err)
+			return
+		}
+
+		// add PipelineRun trigger
+		if err = (&pipelinerun.TriggerReconciler{
+			Client: mgr.GetClient(),
+		}).SetupWithManager(mgr); err != nil {
+			klog.Errorf("unable to create pipelinerun-trigger, err: %v", err)
+			return
+		}
+
+		// add PipelineRun metrics
+		if err = metrics.SetupPipelineRunMetrics(mgr); err != nil {
+			klog.Errorf("unable to set up the PipelineRun metrics, err: %v", err)
+			return
+		}
+
 		// add PipelineRun Synchronizer
 		if err = (&pipelinerun.SyncReconciler{
 			Client:      mgr.GetClient(),
@@ -78,6 +126,11 @@ func addControllers(mgr manager.Manager, client k8s.Client, informerFactory info
 		return
 	}
 
+	// the admission webhooks are disabled by default, because they require the certificates of the webhook server
+	reconcilers["admission"] = func(mgr manager.Manager) error {
+		return admission.SetupWithManager(mgr, devopsClient)
+	}
+
 	// Add all controllers into manager.
 	for name, ok := range s.FeatureOptions.GetControllers() {
 		ctrl := reconcilers[name]
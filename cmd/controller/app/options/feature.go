@@ -18,6 +18,7 @@ package options
 
 import (
 	"strings"
+	"time"
 
 	"github.com/spf13/pflag"
 	cliflag "k8s.io/component-base/cli/flag"
@@ -31,6 +32,13 @@ type FeatureOptions struct {
 	ExternalAddress      string
 	ClusterName          string
 	PipelineRunDataStore string
+	PipelineRunnerImage  string
+	// PipelineRunResyncPeriod is the interval of synchronizing the running PipelineRuns from the engine
+	PipelineRunResyncPeriod time.Duration
+	// PipelineRunMaxAge is the global cap of the time to keep the completed PipelineRuns
+	PipelineRunMaxAge time.Duration
+	// PipelineRunMaxCount is the global cap of the number of the completed PipelineRuns to keep for each Pipeline
+	PipelineRunMaxCount int
 }
 
 // GetControllers returns the controllers map
@@ -83,7 +91,15 @@ func (o *FeatureOptions) AddFlags(fs *pflag.FlagSet, c *FeatureOptions) {
 	fs.StringVarP(&o.ExternalAddress, "external-address", "", "", "The external address for the UI")
 	fs.StringVarP(&o.ClusterName, "cluster-name", "", "default", "Current cluster name")
 	fs.StringVarP(&o.PipelineRunDataStore, "pipelinerun-data-store", "", "configmap",
-		"The data store type of the PipelineRun data, could be empty or configmap")
+		"The data store type of the PipelineRun data, could be empty, configmap or s3")
+	fs.StringVarP(&o.PipelineRunnerImage, "pipelinerun-runner-image", "", "alpine:3.16",
+		"The default image of the stages which are executed by the kubernetes engine")
+	fs.DurationVarP(&o.PipelineRunResyncPeriod, "pipelinerun-resync-period", "", 30*time.Second,
+		"The interval of synchronizing the running PipelineRuns. It is a safety net, the status is updated by the events from Jenkins")
+	fs.DurationVarP(&o.PipelineRunMaxAge, "pipelinerun-max-age", "", 0,
+		"The maximum time to keep the completed PipelineRuns, it caps the discarder of Pipelines. Zero means no cap")
+	fs.IntVarP(&o.PipelineRunMaxCount, "pipelinerun-max-count", "", 0,
+		"The maximum number of the completed PipelineRuns to keep for each Pipeline (or branch), it caps the discarder of Pipelines. Zero means no cap")
 }
 
 func (o *FeatureOptions) knownControllers() []string {
@@ -121,4 +121,8 @@ func TestFeatureOptions(t *testing.T) {
 	assert.NotNil(t, flagSet.Lookup("external-address"))
 	assert.NotNil(t, flagSet.Lookup("cluster-name"))
 	assert.NotNil(t, flagSet.Lookup("pipelinerun-data-store"))
+	assert.NotNil(t, flagSet.Lookup("pipelinerun-runner-image"))
+	assert.NotNil(t, flagSet.Lookup("pipelinerun-resync-period"))
+	assert.NotNil(t, flagSet.Lookup("pipelinerun-max-age"))
+	assert.NotNil(t, flagSet.Lookup("pipelinerun-max-count"))
 }
@@ -192,6 +192,9 @@ func Run(s *options.DevOpsControllerManagerOptions, ctx context.Context) error {
 	if err = indexers.CreatePipelineRunSCMRefNameIndexer(mgr.GetCache()); err != nil {
 		return err
 	}
+	if err = indexers.CreatePipelineUpstreamIndexer(mgr.GetCache()); err != nil {
+		return err
+	}
 
 	// Start cache data after all informer is registered
 	klog.V(0).Info("Starting cache resource from apiserver...")
@@ -19,8 +19,10 @@ package addon
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
-	"html/template"
+	"text/template"
+	"time"
 
 	"github.com/go-logr/logr"
 	corev1 "k8s.io/api/core/v1"
@@ -32,13 +34,16 @@ import (
 	"kubesphere.io/devops/pkg/utils/k8sutil"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
-	"sigs.k8s.io/yaml"
 )
 
 //+kubebuilder:rbac:groups=devops.kubesphere.io,resources=addons,verbs=get;delete;create;update;watch;list
+//+kubebuilder:rbac:groups=devops.kubesphere.io,resources=addons/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=devops.kubesphere.io,resources=addonstrategies,verbs=get;delete;create;update;watch;list
 //+kubebuilder:rbac:groups=devops.kubesphere.io,resources=releasercontrollers,verbs=get;delete;create;update;list
 //+kubebuilder:rbac:groups=argoproj.io,resources=argocds,verbs=get;delete;create;update;list
+//+kubebuilder:rbac:groups=source.toolkit.fluxcd.io,resources=helmrepositories,verbs=get;delete;create;update;list
+//+kubebuilder:rbac:groups=helm.toolkit.fluxcd.io,resources=helmreleases,verbs=get;delete;create;update;list
+//+kubebuilder:rbac:groups=operators.coreos.com,resources=subscriptions,verbs=get;delete;create;update;list
 
 // Reconciler takes the responsible for addon lifecycle
 type Reconciler struct {
@@ -64,93 +69,178 @@ func (r *Reconciler) Reconcile(ctx context.Context, req ctrl.Request) (result ct
 	}
 
 	err = r.addonHandle(ctx, addon)
+	if statusErr := r.updateStatus(ctx, addon); err == nil {
+		err = statusErr
+	}
+
+	// the resources of an addon are not watched, check their health periodically
+	if err == nil {
+		switch addon.Status.Phase {
+		case v1alpha3.AddonPhaseInstalling:
+			result.RequeueAfter = installingCheckInterval
+		case v1alpha3.AddonPhaseReady:
+			result.RequeueAfter = readyCheckInterval
+		}
+	}
 	return
 }
 
+const (
+	// installingCheckInterval is the interval to check the health of an addon which is installing
+	installingCheckInterval = 30 * time.Second
+	// readyCheckInterval is the interval to check the health of an addon which is ready
+	readyCheckInterval = 5 * time.Minute
+)
+
 func beingDeleting(addon *v1alpha3.Addon) bool {
 	return addon != nil && !addon.DeletionTimestamp.IsZero()
 }
 
 func (r *Reconciler) cleanup(addon *v1alpha3.Addon) (result ctrl.Result, err error) {
-	var obj *unstructured.Unstructured
-	if obj, _, err = r.findTemplateInstance(context.Background(), addon); err != nil && !apierrors.IsNotFound(err) {
+	ctx := context.Background()
+	if addon.Status.Phase != v1alpha3.AddonPhaseDeleting {
+		addon.Status.Phase = v1alpha3.AddonPhaseDeleting
+		if err = r.updateStatus(ctx, addon); err != nil {
+			return
+		}
+	}
+
+	// the installed resources are unknown without the strategy, keep the finalizer until the strategy is back
+	var objects []*unstructured.Unstructured
+	if objects, err = r.renderObjects(ctx, addon); err != nil {
 		return
 	}
 
-	if err = r.Client.Delete(context.Background(), obj); err == nil {
-		k8sutil.RemoveFinalizer(&addon.ObjectMeta, v1alpha3.AddonFinalizerName)
-		err = r.Client.Update(context.Background(), addon)
+	// uninstall in the reverse order, e.g. delete the custom resource before the operator
+	for i := len(objects) - 1; i >= 0; i-- {
+		if err = r.Client.Delete(ctx, objects[i]); err != nil && !apierrors.IsNotFound(err) {
+			err = fmt.Errorf("failed to delete %s, error: %v", objectKey(objects[i]), err)
+			return
+		}
 	}
+
+	k8sutil.RemoveFinalizer(&addon.ObjectMeta, v1alpha3.AddonFinalizerName)
+	err = r.Client.Update(ctx, addon)
 	return
 }
 
 const (
 	// EventReasonMissing represents the reason because of missing something
 	EventReasonMissing = "Missing"
+	// EventReasonInstalled represents the reason of an object was created
+	EventReasonInstalled = "Installed"
+	// EventReasonUpgraded represents the reason of an object was updated
+	EventReasonUpgraded = "Upgraded"
+	// EventReasonPruned represents the reason of an object was deleted because it's not rendered anymore
+	EventReasonPruned = "Pruned"
 )
 
 func (r *Reconciler) addonHandle(ctx context.Context, addon *v1alpha3.Addon) (err error) {
-	var tpl string
-	var obj *unstructured.Unstructured
-	if obj, tpl, err = r.findTemplateInstance(ctx, addon); err != nil {
+	var objects []*unstructured.Unstructured
+	if objects, err = r.renderObjects(ctx, addon); err != nil {
 		if apierrors.IsNotFound(err) {
-			err = r.Client.Create(ctx, obj)
+			setFailed(addon, ConditionReasonStrategyNotFound, err)
+		} else {
+			setFailed(addon, ConditionReasonRenderFailed, err)
 		}
-	} else {
-		resVersion := obj.GetResourceVersion()
+		return
+	}
 
-		if err = yaml.Unmarshal([]byte(tpl), obj); err != nil {
-			err = fmt.Errorf("failed parse template to addon, error is %v", err)
+	for _, obj := range objects {
+		if err = r.applyObject(ctx, addon, obj); err != nil {
+			setFailed(addon, ConditionReasonApplyFailed, err)
 			return
 		}
-		obj.SetResourceVersion(resVersion)
-		obj.SetName(addon.Name)
-		obj.SetNamespace(defaultNamespace)
-		err = r.Client.Update(ctx, obj)
+	}
+
+	// delete the objects of the previous strategy or version in the reverse order, the same as uninstalling
+	stale := staleObjects(addon.Status.Resources, objects)
+	for i := len(stale) - 1; i >= 0; i-- {
+		if err = r.Client.Delete(ctx, stale[i]); apierrors.IsNotFound(err) {
+			err = nil
+			continue
+		} else if err != nil {
+			err = fmt.Errorf("failed to delete %s, error: %v", objectKey(stale[i]), err)
+			setFailed(addon, ConditionReasonApplyFailed, err)
+			return
+		}
+		r.recorder.Eventf(addon, corev1.EventTypeNormal, EventReasonPruned, "deleted %s", objectKey(stale[i]))
 	}
 
 	// add finalizer
-	if err == nil {
-		k8sutil.AddFinalizer(&addon.ObjectMeta, v1alpha3.AddonFinalizerName)
-		err = r.Update(ctx, addon)
+	k8sutil.AddFinalizer(&addon.ObjectMeta, v1alpha3.AddonFinalizerName)
+	if err = r.Update(ctx, addon); err == nil {
+		r.setResourcesStatus(ctx, addon, objects)
 	}
 	return
 }
 
-func (r *Reconciler) findTemplateInstance(ctx context.Context, addon *v1alpha3.Addon) (instance *unstructured.Unstructured, tpl string, err error) {
-	strategy := addon.Spec.Strategy
+// applyObject creates the object if it does not exist, or updates it if it differs from the desired one
+func (r *Reconciler) applyObject(ctx context.Context, addon *v1alpha3.Addon, obj *unstructured.Unstructured) (err error) {
+	existing := &unstructured.Unstructured{}
+	existing.SetGroupVersionKind(obj.GroupVersionKind())
+	if err = r.Client.Get(ctx, types.NamespacedName{Name: obj.GetName(), Namespace: obj.GetNamespace()}, existing); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return
+		}
 
-	addonStrategy := &v1alpha3.AddonStrategy{}
-	if err = r.Client.Get(ctx, types.NamespacedName{Name: strategy.Name}, addonStrategy); err != nil {
-		r.recorder.Eventf(addon, corev1.EventTypeWarning, EventReasonMissing, "failed to get AddonStrategy with: %s", strategy.Name)
+		if err = r.Client.Create(ctx, obj); err == nil {
+			r.recorder.Eventf(addon, corev1.EventTypeNormal, EventReasonInstalled, "created %s", objectKey(obj))
+		}
 		return
 	}
 
-	if !r.supportedStrategy(addonStrategy) {
-		err = fmt.Errorf("not supported addon strategy: %s", strategy.Name)
+	if containsFields(existing.Object, obj.Object) {
+		// nothing changed, the addon is checked periodically so don't update it again and again
 		return
 	}
+	obj.SetResourceVersion(existing.GetResourceVersion())
+	if err = r.Client.Update(ctx, obj); err == nil {
+		r.recorder.Eventf(addon, corev1.EventTypeNormal, EventReasonUpgraded, "updated %s", objectKey(obj))
+	}
+	return
+}
 
-	if addonStrategy.Spec.Template == "" {
-		r.recorder.Eventf(addon, corev1.EventTypeWarning, EventReasonMissing, "no template found from %s", strategy.Name)
-		err = fmt.Errorf("no template found from %s", strategy.Name)
-		return
+// containsFields tells if all the desired fields have the same values in the existing object.
+// The fields which are set by the API server or the controllers, such as the status and the defaults, are ignored.
+func containsFields(existing, desired interface{}) bool {
+	if desiredMap, ok := desired.(map[string]interface{}); ok {
+		existingMap, ok := existing.(map[string]interface{})
+		if !ok {
+			// an empty map might be pruned
+			return existing == nil && len(desiredMap) == 0
+		}
+		for key, val := range desiredMap {
+			if !containsFields(existingMap[key], val) {
+				return false
+			}
+		}
+		return true
 	}
+	// compare the JSON to ignore the differences of the number types, e.g. int64 and float64
+	existingJSON, existingErr := json.Marshal(existing)
+	desiredJSON, desiredErr := json.Marshal(desired)
+	return existingErr == nil && desiredErr == nil && bytes.Equal(existingJSON, desiredJSON)
+}
 
-	if tpl, err = getTemplate(addonStrategy.Spec.Template, addon); err != nil {
-		err = fmt.Errorf("failed to render template: %s, error: %v", addonStrategy.Spec.Template, err)
+// renderObjects returns the desired objects of an addon according to its strategy
+func (r *Reconciler) renderObjects(ctx context.Context, addon *v1alpha3.Addon) (objects []*unstructured.Unstructured, err error) {
+	strategy := addon.Spec.Strategy
+
+	addonStrategy := &v1alpha3.AddonStrategy{}
+	if err = r.Client.Get(ctx, types.NamespacedName{Name: strategy.Name}, addonStrategy); err != nil {
+		r.recorder.Eventf(addon, corev1.EventTypeWarning, EventReasonMissing, "failed to get AddonStrategy with: %s", strategy.Name)
 		return
 	}
 
-	instance = &unstructured.Unstructured{}
-	if err = yaml.Unmarshal([]byte(tpl), instance); err != nil {
-		err = fmt.Errorf("failed parse template to addon, error is %v", err)
+	if !r.supportedStrategy(addonStrategy) {
+		err = fmt.Errorf("not supported addon strategy: %s", strategy.Name)
 		return
 	}
-	instance.SetName(addon.Name)
-	instance.SetNamespace(defaultNamespace)
 
-	err = r.Client.Get(ctx, types.NamespacedName{Name: addon.Name, Namespace: defaultNamespace}, instance)
+	if objects, err = installers[addonStrategy.Spec.Type].render(addon, addonStrategy); err != nil {
+		r.recorder.Eventf(addon, corev1.EventTypeWarning, EventReasonMissing, err.Error())
+	}
 	return
 }
 
@@ -166,9 +256,9 @@ func getTemplate(tpl string, addon *v1alpha3.Addon) (result string, err error) {
 }
 
 func (r *Reconciler) supportedStrategy(strategy *v1alpha3.AddonStrategy) bool {
-	// TODO support more types in the future
 	if strategy != nil {
-		return strategy.Spec.Type == v1alpha3.AddonInstallStrategySimpleOperator
+		_, ok := installers[strategy.Spec.Type]
+		return ok
 	}
 	return false
 }
@@ -24,6 +24,7 @@ import (
 	"github.com/go-logr/logr"
 	"github.com/stretchr/testify/assert"
 	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/types"
@@ -60,6 +61,24 @@ func TestReconciler_supportedStrategy(t *testing.T) {
 			Spec: v1alpha3.AddStrategySpec{Type: "simple-operator"},
 		}},
 		want: true,
+	}, {
+		name: "simple",
+		args: args{strategy: &v1alpha3.AddonStrategy{
+			Spec: v1alpha3.AddStrategySpec{Type: "simple"},
+		}},
+		want: true,
+	}, {
+		name: "helm",
+		args: args{strategy: &v1alpha3.AddonStrategy{
+			Spec: v1alpha3.AddStrategySpec{Type: "helm"},
+		}},
+		want: true,
+	}, {
+		name: "operator",
+		args: args{strategy: &v1alpha3.AddonStrategy{
+			Spec: v1alpha3.AddStrategySpec{Type: "operator"},
+		}},
+		want: true,
 	}}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -88,6 +107,16 @@ func Test_getTemplate(t *testing.T) {
 		wantErr: func(t assert.TestingT, err error, i ...interface{}) bool {
 			return false
 		},
+	}, {
+		name: "the values are not escaped",
+		args: args{
+			tpl:   `image: "{{.Spec.Parameters.image}}"`,
+			addon: &v1alpha3.Addon{Spec: v1alpha3.AddonSpec{Parameters: map[string]string{"image": "a/b:c&d<e>"}}},
+		},
+		wantResult: `image: "a/b:c&d<e>"`,
+		wantErr: func(t assert.TestingT, err error, i ...interface{}) bool {
+			return err == nil
+		},
 	}, {
 		name: "addon with version",
 		args: args{
@@ -130,6 +159,16 @@ spec:
   webhook: false`,
 		},
 	}
+	helmStrategy := &v1alpha3.AddonStrategy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "helm-jenkins",
+		},
+		Spec: v1alpha3.AddStrategySpec{
+			Available: true,
+			Type:      v1alpha3.AddonInstallStrategyHelm,
+			HelmRepo:  "https://charts.jenkins.io",
+		},
+	}
 	addon := &v1alpha3.Addon{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:            "ks-releaser",
@@ -239,6 +278,108 @@ spec:
 			assert.True(t, ok)
 			assert.Equal(t, "ghcr.io/kubesphere-sigs/ks-releaser", image)
 		},
+	}, {
+		name: "upgrade a helm addon",
+		fields: fields{
+			Client: fake.NewClientBuilder().WithScheme(schema).WithObjects(helmStrategy.DeepCopy(), &unstructured.Unstructured{Object: map[string]interface{}{
+				"apiVersion": "helm.toolkit.fluxcd.io/v2beta1",
+				"kind":       "HelmRelease",
+				"metadata": map[string]interface{}{
+					"name":      "jenkins",
+					"namespace": "default",
+				},
+				"spec": map[string]interface{}{
+					"chart": map[string]interface{}{
+						"spec": map[string]interface{}{"version": "4.1.12"},
+					},
+				},
+			}}).Build(),
+		},
+		args: args{
+			ctx: context.TODO(),
+			addon: &v1alpha3.Addon{
+				ObjectMeta: metav1.ObjectMeta{Name: "jenkins"},
+				Spec: v1alpha3.AddonSpec{
+					Version:  "4.1.13",
+					Strategy: v1.LocalObjectReference{Name: "helm-jenkins"},
+				}},
+		},
+		wantErr: func(t assert.TestingT, err error, i ...interface{}) bool {
+			return err == nil
+		},
+		verify: func(t *testing.T, c client.Client) {
+			repo := &unstructured.Unstructured{}
+			repo.SetKind("HelmRepository")
+			repo.SetAPIVersion("source.toolkit.fluxcd.io/v1beta2")
+			err := c.Get(context.Background(), types.NamespacedName{Namespace: "default", Name: "jenkins"}, repo)
+			assert.Nil(t, err)
+
+			release := &unstructured.Unstructured{}
+			release.SetKind("HelmRelease")
+			release.SetAPIVersion("helm.toolkit.fluxcd.io/v2beta1")
+			err = c.Get(context.Background(), types.NamespacedName{Namespace: "default", Name: "jenkins"}, release)
+			assert.Nil(t, err)
+			version, _, _ := unstructured.NestedString(release.Object, "spec", "chart", "spec", "version")
+			assert.Equal(t, "4.1.13", version)
+		},
+	}, {
+		name: "switch to another strategy, the objects of the previous one are pruned",
+		fields: fields{
+			Client: fake.NewClientBuilder().WithScheme(schema).WithObjects(strategy.DeepCopy(), &unstructured.Unstructured{Object: map[string]interface{}{
+				"apiVersion": "helm.toolkit.fluxcd.io/v2beta1",
+				"kind":       "HelmRelease",
+				"metadata": map[string]interface{}{
+					"name":      "ks-releaser",
+					"namespace": "default",
+				},
+			}}).Build(),
+		},
+		args: args{
+			ctx: context.TODO(),
+			addon: &v1alpha3.Addon{
+				ObjectMeta: metav1.ObjectMeta{Name: "ks-releaser"},
+				Spec: v1alpha3.AddonSpec{
+					Version:  "v0.0.1",
+					Strategy: v1.LocalObjectReference{Name: "simple-operator"},
+				},
+				Status: v1alpha3.AddonStatus{Resources: []v1alpha3.AddonResource{{
+					APIVersion: "source.toolkit.fluxcd.io/v1beta2", Kind: "HelmRepository", Namespace: "default", Name: "ks-releaser",
+				}, {
+					APIVersion: "helm.toolkit.fluxcd.io/v2beta1", Kind: "HelmRelease", Namespace: "default", Name: "ks-releaser",
+				}, {
+					// a previous version of the same object is kept
+					APIVersion: "devops.kubesphere.io/v1alpha0", Kind: "ReleaserController", Namespace: "default", Name: "ks-releaser",
+				}}},
+			},
+		},
+		wantErr: func(t assert.TestingT, err error, i ...interface{}) bool {
+			return err == nil
+		},
+		verify: func(t *testing.T, c client.Client) {
+			release := &unstructured.Unstructured{}
+			release.SetKind("HelmRelease")
+			release.SetAPIVersion("helm.toolkit.fluxcd.io/v2beta1")
+			err := c.Get(context.Background(), types.NamespacedName{Namespace: "default", Name: "ks-releaser"}, release)
+			assert.True(t, apierrors.IsNotFound(err))
+
+			obj := &unstructured.Unstructured{}
+			obj.SetKind("ReleaserController")
+			obj.SetAPIVersion("devops.kubesphere.io/v1alpha1")
+			err = c.Get(context.Background(), types.NamespacedName{Namespace: "default", Name: "ks-releaser"}, obj)
+			assert.Nil(t, err)
+		},
+	}, {
+		name: "strategy does not exist",
+		fields: fields{
+			Client: fake.NewClientBuilder().WithScheme(schema).Build(),
+		},
+		args: args{
+			ctx:   context.TODO(),
+			addon: addon.DeepCopy(),
+		},
+		wantErr: func(t assert.TestingT, err error, i ...interface{}) bool {
+			return err != nil
+		},
 	}}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -255,6 +396,100 @@ spec:
 	}
 }
 
+func TestReconciler_applyObject(t *testing.T) {
+	schema, err := v1alpha3.SchemeBuilder.Register().Build()
+	assert.Nil(t, err)
+
+	newRelease := func(version string, replicas interface{}) *unstructured.Unstructured {
+		release := &unstructured.Unstructured{Object: map[string]interface{}{
+			"spec": map[string]interface{}{
+				"chart":  map[string]interface{}{"spec": map[string]interface{}{"version": version}},
+				"values": map[string]interface{}{"replicas": replicas},
+			},
+		}}
+		release.SetAPIVersion(helmReleaseAPIVersion)
+		release.SetKind("HelmRelease")
+		release.SetName("jenkins")
+		release.SetNamespace("default")
+		return release
+	}
+	existing := newRelease("4.1.12", int64(1))
+	// the fields set by others are ignored
+	_ = unstructured.SetNestedField(existing.Object, "Ready", "status", "phase")
+	_ = unstructured.SetNestedField(existing.Object, "5m", "spec", "timeout")
+
+	c := fake.NewClientBuilder().WithScheme(schema).WithObjects(existing).Build()
+	recorder := record.NewFakeRecorder(100)
+	r := &Reconciler{Client: c, log: logr.Discard(), recorder: recorder}
+	addon := &v1alpha3.Addon{ObjectMeta: metav1.ObjectMeta{Name: "jenkins"}}
+
+	// nothing changed
+	assert.Nil(t, r.applyObject(context.Background(), addon, newRelease("4.1.12", float64(1))))
+	assert.Empty(t, recorder.Events)
+	live := newRelease("", nil)
+	assert.Nil(t, c.Get(context.Background(), types.NamespacedName{Namespace: "default", Name: "jenkins"}, live))
+	assert.Equal(t, existing.GetResourceVersion(), live.GetResourceVersion())
+
+	// upgrade
+	assert.Nil(t, r.applyObject(context.Background(), addon, newRelease("4.1.13", float64(1))))
+	if assert.Len(t, recorder.Events, 1) {
+		assert.Contains(t, <-recorder.Events, EventReasonUpgraded)
+	}
+	assert.Nil(t, c.Get(context.Background(), types.NamespacedName{Namespace: "default", Name: "jenkins"}, live))
+	version, _, _ := unstructured.NestedString(live.Object, "spec", "chart", "spec", "version")
+	assert.Equal(t, "4.1.13", version)
+}
+
+func Test_containsFields(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing interface{}
+		desired  interface{}
+		want     bool
+	}{{
+		name:     "same",
+		existing: map[string]interface{}{"a": "b"},
+		desired:  map[string]interface{}{"a": "b"},
+		want:     true,
+	}, {
+		name:     "extra fields in the existing object",
+		existing: map[string]interface{}{"a": map[string]interface{}{"b": "c", "d": "e"}, "f": "g"},
+		desired:  map[string]interface{}{"a": map[string]interface{}{"b": "c"}},
+		want:     true,
+	}, {
+		name:     "different numbers types",
+		existing: map[string]interface{}{"a": int64(1)},
+		desired:  map[string]interface{}{"a": float64(1)},
+		want:     true,
+	}, {
+		name:     "different value",
+		existing: map[string]interface{}{"a": map[string]interface{}{"b": "c"}},
+		desired:  map[string]interface{}{"a": map[string]interface{}{"b": "d"}},
+	}, {
+		name:     "missing field",
+		existing: map[string]interface{}{},
+		desired:  map[string]interface{}{"a": "b"},
+	}, {
+		name:     "not a map",
+		existing: map[string]interface{}{"a": "b"},
+		desired:  map[string]interface{}{"a": map[string]interface{}{}},
+	}, {
+		name:     "an empty map was pruned",
+		existing: map[string]interface{}{},
+		desired:  map[string]interface{}{"a": map[string]interface{}{}},
+		want:     true,
+	}, {
+		name:     "different arrays",
+		existing: map[string]interface{}{"a": []interface{}{"b", "c"}},
+		desired:  map[string]interface{}{"a": []interface{}{"b"}},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, containsFields(tt.existing, tt.desired))
+		})
+	}
+}
+
 func Test_beingDeleting(t *testing.T) {
 	nowTime := metav1.Now()
 
@@ -288,3 +523,57 @@ func Test_beingDeleting(t *testing.T) {
 		})
 	}
 }
+
+func TestReconciler_cleanup(t *testing.T) {
+	schema, err := v1alpha3.SchemeBuilder.Register().Build()
+	assert.Nil(t, err)
+
+	strategy := &v1alpha3.AddonStrategy{
+		ObjectMeta: metav1.ObjectMeta{Name: "helm-jenkins"},
+		Spec: v1alpha3.AddStrategySpec{
+			Type:     v1alpha3.AddonInstallStrategyHelm,
+			HelmRepo: "https://charts.jenkins.io",
+		},
+	}
+	addon := &v1alpha3.Addon{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "jenkins",
+			Finalizers: []string{v1alpha3.AddonFinalizerName},
+		},
+		Spec: v1alpha3.AddonSpec{
+			Strategy: v1.LocalObjectReference{Name: "helm-jenkins"},
+		},
+	}
+	release := &unstructured.Unstructured{}
+	release.SetAPIVersion("helm.toolkit.fluxcd.io/v2beta1")
+	release.SetKind("HelmRelease")
+	release.SetName("jenkins")
+	release.SetNamespace("default")
+
+	c := fake.NewClientBuilder().WithScheme(schema).WithObjects(strategy, addon, release).Build()
+	r := &Reconciler{
+		Client:   c,
+		log:      logr.Discard(),
+		recorder: record.NewFakeRecorder(100),
+	}
+	_, err = r.cleanup(addon.DeepCopy())
+	assert.Nil(t, err)
+
+	// the HelmRelease was deleted, and the missing HelmRepository should not block the cleanup
+	err = c.Get(context.Background(), types.NamespacedName{Namespace: "default", Name: "jenkins"}, release)
+	assert.True(t, apierrors.IsNotFound(err))
+
+	result := &v1alpha3.Addon{}
+	err = c.Get(context.Background(), types.NamespacedName{Name: "jenkins"}, result)
+	assert.Nil(t, err)
+	assert.Empty(t, result.Finalizers)
+
+	// the resources and the finalizer are kept if the strategy does not exist
+	c = fake.NewClientBuilder().WithScheme(schema).WithObjects(addon.DeepCopy(), release.DeepCopy()).Build()
+	r.Client = c
+	_, err = r.cleanup(addon.DeepCopy())
+	assert.True(t, apierrors.IsNotFound(err))
+	assert.Nil(t, c.Get(context.Background(), types.NamespacedName{Namespace: "default", Name: "jenkins"}, release))
+	assert.Nil(t, c.Get(context.Background(), types.NamespacedName{Name: "jenkins"}, result))
+	assert.Equal(t, []string{v1alpha3.AddonFinalizerName}, result.Finalizers)
+}
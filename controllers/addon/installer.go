@@ -0,0 +1,342 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package addon
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
+	"sigs.k8s.io/yaml"
+)
+
+// installer renders the desired objects of an addon for an install strategy.
+// Installing, upgrading and uninstalling are all based on the desired objects.
+type installer interface {
+	render(addon *v1alpha3.Addon, strategy *v1alpha3.AddonStrategy) ([]*unstructured.Unstructured, error)
+}
+
+// installers are all the supported install strategies
+var installers = map[v1alpha3.AddonInstallStrategy]installer{
+	v1alpha3.AddonInstallStrategySimpleOperator: &simpleOperatorInstaller{},
+	v1alpha3.AddonInstallStrategySimple:         &simpleInstaller{},
+	v1alpha3.AddonInstallStrategyHelm:           &helmInstaller{},
+	v1alpha3.AddonInstallStrategyOperator:       &operatorInstaller{},
+}
+
+// simpleOperatorInstaller creates a custom resource for an operator which was installed already
+type simpleOperatorInstaller struct{}
+
+func (i *simpleOperatorInstaller) render(addon *v1alpha3.Addon, strategy *v1alpha3.AddonStrategy) (
+	objects []*unstructured.Unstructured, err error) {
+	var instance *unstructured.Unstructured
+	if instance, err = renderInstance(addon, strategy); err == nil {
+		objects = []*unstructured.Unstructured{instance}
+	}
+	return
+}
+
+// renderInstance renders the template as a single object which has the same name as the addon
+func renderInstance(addon *v1alpha3.Addon, strategy *v1alpha3.AddonStrategy) (instance *unstructured.Unstructured, err error) {
+	if strategy.Spec.Template == "" {
+		err = fmt.Errorf("no template found from %s", strategy.Name)
+		return
+	}
+
+	var tpl string
+	if tpl, err = getTemplate(strategy.Spec.Template, addon); err != nil {
+		err = fmt.Errorf("failed to render template: %s, error: %v", strategy.Spec.Template, err)
+		return
+	}
+
+	instance = &unstructured.Unstructured{}
+	if err = yaml.Unmarshal([]byte(tpl), instance); err != nil {
+		err = fmt.Errorf("failed parse template to addon, error is %v", err)
+		return
+	}
+	instance.SetName(addon.Name)
+	instance.SetNamespace(defaultNamespace)
+	return
+}
+
+// simpleInstaller applies all the objects of a multi-document YAML
+type simpleInstaller struct{}
+
+func (i *simpleInstaller) render(addon *v1alpha3.Addon, strategy *v1alpha3.AddonStrategy) (
+	objects []*unstructured.Unstructured, err error) {
+	if strategy.Spec.YAML == "" {
+		err = fmt.Errorf("no YAML found from %s", strategy.Name)
+		return
+	}
+
+	var tpl string
+	if tpl, err = getTemplate(strategy.Spec.YAML, addon); err != nil {
+		err = fmt.Errorf("failed to render YAML of %s, error: %v", strategy.Name, err)
+		return
+	}
+
+	decoder := utilyaml.NewYAMLOrJSONDecoder(strings.NewReader(tpl), 4096)
+	for {
+		obj := &unstructured.Unstructured{}
+		if err = decoder.Decode(&obj.Object); err != nil {
+			if err == io.EOF {
+				err = nil
+				break
+			}
+			err = fmt.Errorf("failed to parse YAML of %s, error: %v", strategy.Name, err)
+			return
+		}
+		if len(obj.Object) == 0 {
+			// an empty document
+			continue
+		}
+		if obj.GetKind() == "" || obj.GetName() == "" {
+			err = fmt.Errorf("missing kind or name in the YAML of %s", strategy.Name)
+			return
+		}
+		if obj.GetNamespace() == "" {
+			obj.SetNamespace(defaultNamespace)
+		}
+		objects = append(objects, obj)
+	}
+	return
+}
+
+const (
+	// helmRepositoryAPIVersion is the API version of FluxCD HelmRepository
+	helmRepositoryAPIVersion = "source.toolkit.fluxcd.io/v1beta2"
+	// helmReleaseAPIVersion is the API version of FluxCD HelmRelease
+	helmReleaseAPIVersion = "helm.toolkit.fluxcd.io/v2beta1"
+	// helmInterval is the interval of FluxCD to reconcile the Helm chart
+	helmInterval = "10m"
+)
+
+// helmInstaller installs a Helm chart via FluxCD HelmRepository and HelmRelease.
+// The values come from the template of the strategy, then the parameters of the strategy and the addon.
+type helmInstaller struct{}
+
+func (i *helmInstaller) render(addon *v1alpha3.Addon, strategy *v1alpha3.AddonStrategy) (
+	objects []*unstructured.Unstructured, err error) {
+	if strategy.Spec.HelmRepo == "" {
+		err = fmt.Errorf("no helmRepo found from %s", strategy.Name)
+		return
+	}
+
+	chart := strategy.Spec.Chart
+	if chart == "" {
+		chart = addon.Name
+	}
+
+	values := map[string]interface{}{}
+	if strategy.Spec.Template != "" {
+		var tpl string
+		if tpl, err = getTemplate(strategy.Spec.Template, addon); err != nil {
+			err = fmt.Errorf("failed to render the values of %s, error: %v", strategy.Name, err)
+			return
+		}
+		if err = yaml.Unmarshal([]byte(tpl), &values); err != nil {
+			err = fmt.Errorf("failed to parse the values of %s, error: %v", strategy.Name, err)
+			return
+		}
+	}
+	setValues(values, strategy.Spec.Parameters)
+	setValues(values, addon.Spec.Parameters)
+
+	repo := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{
+			"url":      strategy.Spec.HelmRepo,
+			"interval": helmInterval,
+		},
+	}}
+	repo.SetAPIVersion(helmRepositoryAPIVersion)
+	repo.SetKind("HelmRepository")
+	repo.SetName(addon.Name)
+	repo.SetNamespace(defaultNamespace)
+
+	release := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{
+			"interval":    helmInterval,
+			"releaseName": addon.Name,
+			"chart": map[string]interface{}{
+				"spec": map[string]interface{}{
+					"chart":   chart,
+					"version": addon.Spec.Version,
+					"sourceRef": map[string]interface{}{
+						"kind": "HelmRepository",
+						"name": addon.Name,
+					},
+				},
+			},
+			"values": values,
+		},
+	}}
+	release.SetAPIVersion(helmReleaseAPIVersion)
+	release.SetKind("HelmRelease")
+	release.SetName(addon.Name)
+	release.SetNamespace(defaultNamespace)
+
+	objects = []*unstructured.Unstructured{repo, release}
+	return
+}
+
+// setValues sets the parameters into the Helm values, a key like "image.tag" represents a nested value
+func setValues(values map[string]interface{}, parameters map[string]string) {
+	// sort the keys to make sure a nested key always overrides its parent
+	keys := make([]string, 0, len(parameters))
+	for key := range parameters {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		fields := strings.Split(key, ".")
+		current := values
+		for _, field := range fields[:len(fields)-1] {
+			next, ok := current[field].(map[string]interface{})
+			if !ok {
+				next = map[string]interface{}{}
+				current[field] = next
+			}
+			current = next
+		}
+		current[fields[len(fields)-1]] = parameters[key]
+	}
+}
+
+const (
+	// subscriptionAPIVersion is the API version of OLM Subscription
+	subscriptionAPIVersion = "operators.coreos.com/v1alpha1"
+
+	// the parameter keys of the operator strategy
+	operatorChannelParameter   = "channel"
+	operatorCatalogParameter   = "catalog"
+	operatorNamespaceParameter = "namespace"
+	// operatorCSVParameter is the name of the ClusterServiceVersion to install, it's required if the name
+	// does not follow the convention: {package}.v{version}
+	operatorCSVParameter = "startingCSV"
+
+	defaultOperatorChannel          = "stable"
+	defaultOperatorCatalog          = "operatorhubio-catalog"
+	defaultOperatorCatalogNamespace = "olm"
+	defaultOperatorNamespace        = "operators"
+)
+
+// operatorInstaller installs an operator via an OLM Subscription, then creates the custom resource from the template.
+// The name of the operator reference is the package name, and the namespace of it is the namespace of the catalog.
+// The version of the addon is the version of the operator.
+type operatorInstaller struct{}
+
+func (i *operatorInstaller) render(addon *v1alpha3.Addon, strategy *v1alpha3.AddonStrategy) (
+	objects []*unstructured.Unstructured, err error) {
+	operator := strategy.Spec.Operator
+	if operator.Name == "" {
+		err = fmt.Errorf("no operator found from %s", strategy.Name)
+		return
+	}
+
+	catalogNamespace := operator.Namespace
+	if catalogNamespace == "" {
+		catalogNamespace = defaultOperatorCatalogNamespace
+	}
+	parameters := strategy.Spec.Parameters
+	subscription := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{
+			"name":                operator.Name,
+			"channel":             getOrDefault(parameters, operatorChannelParameter, defaultOperatorChannel),
+			"source":              getOrDefault(parameters, operatorCatalogParameter, defaultOperatorCatalog),
+			"sourceNamespace":     catalogNamespace,
+			"installPlanApproval": "Automatic",
+		},
+	}}
+	if csv := getStartingCSV(operator.Name, addon.Spec.Version, parameters); csv != "" {
+		_ = unstructured.SetNestedField(subscription.Object, csv, "spec", "startingCSV")
+	}
+	subscription.SetAPIVersion(subscriptionAPIVersion)
+	subscription.SetKind("Subscription")
+	subscription.SetName(operator.Name)
+	subscription.SetNamespace(getOrDefault(parameters, operatorNamespaceParameter, defaultOperatorNamespace))
+	objects = append(objects, subscription)
+
+	// the custom resource is optional, some operators work without it
+	if strategy.Spec.Template != "" {
+		var instance *unstructured.Unstructured
+		if instance, err = renderInstance(addon, strategy); err != nil {
+			return
+		}
+		objects = append(objects, instance)
+	}
+	return
+}
+
+// getStartingCSV returns the ClusterServiceVersion of the operator version, it's empty if no version is specified
+func getStartingCSV(packageName, version string, parameters map[string]string) string {
+	if csv := parameters[operatorCSVParameter]; csv != "" {
+		return csv
+	}
+	if version == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s.v%s", packageName, strings.TrimPrefix(version, "v"))
+}
+
+func getOrDefault(parameters map[string]string, key, defaultVal string) string {
+	if val, ok := parameters[key]; ok && val != "" {
+		return val
+	}
+	return defaultVal
+}
+
+// objectKey returns a readable key of an object, such as: HelmRelease/default/jenkins
+func objectKey(obj *unstructured.Unstructured) string {
+	buf := bytes.NewBufferString(obj.GetKind())
+	if obj.GetNamespace() != "" {
+		buf.WriteString("/" + obj.GetNamespace())
+	}
+	buf.WriteString("/" + obj.GetName())
+	return buf.String()
+}
+
+// staleObjects returns the installed objects which are not rendered anymore, for instance,
+// the strategy or the version of the addon changed. They are in the same order as the installed ones.
+// The API version is ignored, because a new version of the same kind is still the same object.
+func staleObjects(installed []v1alpha3.AddonResource, objects []*unstructured.Unstructured) (stale []*unstructured.Unstructured) {
+	rendered := make(map[string]bool, len(objects))
+	for _, obj := range objects {
+		rendered[groupObjectKey(obj)] = true
+	}
+	for _, resource := range installed {
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion(resource.APIVersion)
+		obj.SetKind(resource.Kind)
+		obj.SetNamespace(resource.Namespace)
+		obj.SetName(resource.Name)
+		if !rendered[groupObjectKey(obj)] {
+			stale = append(stale, obj)
+		}
+	}
+	return
+}
+
+// groupObjectKey returns a key of an object which contains the API group, such as: helm.toolkit.fluxcd.io/HelmRelease/default/jenkins
+func groupObjectKey(obj *unstructured.Unstructured) string {
+	return obj.GroupVersionKind().Group + "/" + objectKey(obj)
+}
@@ -0,0 +1,233 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package addon
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
+)
+
+func newAddon(version string, parameters map[string]string) *v1alpha3.Addon {
+	return &v1alpha3.Addon{
+		ObjectMeta: metav1.ObjectMeta{Name: "fake", Namespace: "default"},
+		Spec: v1alpha3.AddonSpec{
+			Version:    version,
+			Parameters: parameters,
+		},
+	}
+}
+
+func TestSimpleInstaller(t *testing.T) {
+	tests := []struct {
+		name     string
+		yaml     string
+		wantKeys []string
+		wantErr  bool
+	}{{
+		name:    "no YAML",
+		wantErr: true,
+	}, {
+		name: "multiple documents",
+		yaml: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+data:
+  version: {{.Spec.Version}}
+---
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+  namespace: addon
+`,
+		wantKeys: []string{"ConfigMap/default/config", "Deployment/addon/app"},
+	}, {
+		name:    "missing the name",
+		yaml:    `kind: ConfigMap`,
+		wantErr: true,
+	}, {
+		name:    "invalid YAML",
+		yaml:    `kind: [`,
+		wantErr: true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			strategy := &v1alpha3.AddonStrategy{Spec: v1alpha3.AddStrategySpec{YAML: tt.yaml}}
+			objects, err := (&simpleInstaller{}).render(newAddon("v1.0.0", nil), strategy)
+			if tt.wantErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			var keys []string
+			for _, obj := range objects {
+				keys = append(keys, objectKey(obj))
+			}
+			assert.Equal(t, tt.wantKeys, keys)
+			version, _, _ := unstructured.NestedString(objects[0].Object, "data", "version")
+			assert.Equal(t, "v1.0.0", version)
+		})
+	}
+}
+
+func TestHelmInstaller(t *testing.T) {
+	t.Run("no helm repository", func(t *testing.T) {
+		_, err := (&helmInstaller{}).render(newAddon("", nil), &v1alpha3.AddonStrategy{})
+		assert.NotNil(t, err)
+	})
+
+	t.Run("normal case", func(t *testing.T) {
+		strategy := &v1alpha3.AddonStrategy{Spec: v1alpha3.AddStrategySpec{
+			HelmRepo: "https://charts.jenkins.io",
+			Chart:    "jenkins",
+			Template: `controller:
+  tag: {{.Spec.Version}}
+  image: jenkins/jenkins`,
+			Parameters: map[string]string{"persistence.enabled": "false"},
+		}}
+		addon := newAddon("4.1.13", map[string]string{"controller.image": "ghcr.io/jenkins"})
+
+		objects, err := (&helmInstaller{}).render(addon, strategy)
+		assert.Nil(t, err)
+		if !assert.Equal(t, 2, len(objects)) {
+			return
+		}
+
+		repo := objects[0]
+		assert.Equal(t, "HelmRepository/default/fake", objectKey(repo))
+		url, _, _ := unstructured.NestedString(repo.Object, "spec", "url")
+		assert.Equal(t, "https://charts.jenkins.io", url)
+
+		release := objects[1]
+		assert.Equal(t, "HelmRelease/default/fake", objectKey(release))
+		chart, _, _ := unstructured.NestedString(release.Object, "spec", "chart", "spec", "chart")
+		assert.Equal(t, "jenkins", chart)
+		version, _, _ := unstructured.NestedString(release.Object, "spec", "chart", "spec", "version")
+		assert.Equal(t, "4.1.13", version)
+		values, _, _ := unstructured.NestedMap(release.Object, "spec", "values")
+		assert.Equal(t, map[string]interface{}{
+			"controller": map[string]interface{}{
+				"tag":   "4.1.13",
+				"image": "ghcr.io/jenkins",
+			},
+			"persistence": map[string]interface{}{
+				"enabled": "false",
+			},
+		}, values)
+	})
+
+	t.Run("the chart name is the addon name by default", func(t *testing.T) {
+		strategy := &v1alpha3.AddonStrategy{Spec: v1alpha3.AddStrategySpec{HelmRepo: "https://charts.jenkins.io"}}
+		objects, err := (&helmInstaller{}).render(newAddon("", nil), strategy)
+		assert.Nil(t, err)
+		chart, _, _ := unstructured.NestedString(objects[1].Object, "spec", "chart", "spec", "chart")
+		assert.Equal(t, "fake", chart)
+	})
+}
+
+func Test_setValues(t *testing.T) {
+	values := map[string]interface{}{"image": "jenkins", "replicas": "1"}
+	setValues(values, map[string]string{
+		"image.tag":  "lts",
+		"image":      "ignored",
+		"a.b.c":      "d",
+		"replicas":   "2",
+		"persistent": "true",
+	})
+	assert.Equal(t, map[string]interface{}{
+		"image":      map[string]interface{}{"tag": "lts"},
+		"a":          map[string]interface{}{"b": map[string]interface{}{"c": "d"}},
+		"replicas":   "2",
+		"persistent": "true",
+	}, values)
+}
+
+func TestOperatorInstaller(t *testing.T) {
+	t.Run("no operator", func(t *testing.T) {
+		_, err := (&operatorInstaller{}).render(newAddon("", nil), &v1alpha3.AddonStrategy{})
+		assert.NotNil(t, err)
+	})
+
+	t.Run("subscription only", func(t *testing.T) {
+		strategy := &v1alpha3.AddonStrategy{Spec: v1alpha3.AddStrategySpec{
+			Operator: v1.ObjectReference{Name: "argocd-operator"},
+		}}
+		objects, err := (&operatorInstaller{}).render(newAddon("", nil), strategy)
+		assert.Nil(t, err)
+		if assert.Equal(t, 1, len(objects)) {
+			assert.Equal(t, "Subscription/operators/argocd-operator", objectKey(objects[0]))
+			spec, _, _ := unstructured.NestedStringMap(objects[0].Object, "spec")
+			assert.Equal(t, map[string]string{
+				"name":                "argocd-operator",
+				"channel":             "stable",
+				"source":              "operatorhubio-catalog",
+				"sourceNamespace":     "olm",
+				"installPlanApproval": "Automatic",
+			}, spec)
+		}
+	})
+
+	t.Run("subscription and custom resource", func(t *testing.T) {
+		strategy := &v1alpha3.AddonStrategy{Spec: v1alpha3.AddStrategySpec{
+			Operator: v1.ObjectReference{Name: "argocd-operator", Namespace: "marketplace"},
+			Parameters: map[string]string{
+				operatorChannelParameter:   "alpha",
+				operatorCatalogParameter:   "community",
+				operatorNamespaceParameter: "argocd",
+			},
+			Template: `apiVersion: argoproj.io/v1alpha1
+kind: ArgoCD
+spec:
+  server:
+    insecure: true`,
+		}}
+		objects, err := (&operatorInstaller{}).render(newAddon("v0.5.0", nil), strategy)
+		assert.Nil(t, err)
+		if assert.Equal(t, 2, len(objects)) {
+			assert.Equal(t, "Subscription/argocd/argocd-operator", objectKey(objects[0]))
+			spec, _, _ := unstructured.NestedStringMap(objects[0].Object, "spec")
+			assert.Equal(t, "alpha", spec["channel"])
+			assert.Equal(t, "community", spec["source"])
+			assert.Equal(t, "marketplace", spec["sourceNamespace"])
+			assert.Equal(t, "argocd-operator.v0.5.0", spec["startingCSV"])
+
+			assert.Equal(t, "ArgoCD/default/fake", objectKey(objects[1]))
+			insecure, _, _ := unstructured.NestedBool(objects[1].Object, "spec", "server", "insecure")
+			assert.True(t, insecure)
+		}
+	})
+
+	t.Run("specify the CSV", func(t *testing.T) {
+		strategy := &v1alpha3.AddonStrategy{Spec: v1alpha3.AddStrategySpec{
+			Operator:   v1.ObjectReference{Name: "jenkins-operator"},
+			Parameters: map[string]string{operatorCSVParameter: "jenkins-operator.0.7.1"},
+		}}
+		objects, err := (&operatorInstaller{}).render(newAddon("0.7.1", nil), strategy)
+		assert.Nil(t, err)
+		if assert.Equal(t, 1, len(objects)) {
+			csv, _, _ := unstructured.NestedString(objects[0].Object, "spec", "startingCSV")
+			assert.Equal(t, "jenkins-operator.0.7.1", csv)
+		}
+	})
+}
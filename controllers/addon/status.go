@@ -0,0 +1,184 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package addon
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
+)
+
+const (
+	// ConditionReasonStrategyNotFound represents the reason of the AddonStrategy does not exist
+	ConditionReasonStrategyNotFound = "StrategyNotFound"
+	// ConditionReasonRenderFailed represents the reason of failed to render the resources
+	ConditionReasonRenderFailed = "RenderFailed"
+	// ConditionReasonApplyFailed represents the reason of failed to create or update the resources
+	ConditionReasonApplyFailed = "ApplyFailed"
+	// ConditionReasonApplied represents the reason of all the resources were applied
+	ConditionReasonApplied = "Applied"
+	// ConditionReasonResourcesReady represents the reason of all the resources are ready
+	ConditionReasonResourcesReady = "ResourcesReady"
+	// ConditionReasonResourcesNotReady represents the reason of some resources are not ready
+	ConditionReasonResourcesNotReady = "ResourcesNotReady"
+)
+
+// readyPhases are the phases or states of an object which mean it is ready,
+// such as the phase of ArgoCD and the state of an OLM Subscription
+var readyPhases = []string{"Available", "Ready", "Running", "Succeeded", "Deployed", "AtLatestKnown", "Active", "Bound"}
+
+// setFailed marks the addon as failed with the reason
+func setFailed(addon *v1alpha3.Addon, reason string, err error) {
+	addon.Status.Phase = v1alpha3.AddonPhaseFailed
+	addon.Status.SetCondition(v1alpha3.Condition{
+		Type:    v1alpha3.AddonConditionInstalled,
+		Status:  v1alpha3.ConditionFalse,
+		Reason:  reason,
+		Message: err.Error(),
+	})
+}
+
+// setResourcesStatus checks the health of the applied objects, then sets the phase of the addon
+func (r *Reconciler) setResourcesStatus(ctx context.Context, addon *v1alpha3.Addon, objects []*unstructured.Unstructured) {
+	addon.Status.SetCondition(v1alpha3.Condition{
+		Type:   v1alpha3.AddonConditionInstalled,
+		Status: v1alpha3.ConditionTrue,
+		Reason: ConditionReasonApplied,
+	})
+
+	var notReady []string
+	resources := make([]v1alpha3.AddonResource, 0, len(objects))
+	for _, obj := range objects {
+		resource := v1alpha3.AddonResource{
+			APIVersion: obj.GetAPIVersion(),
+			Kind:       obj.GetKind(),
+			Namespace:  obj.GetNamespace(),
+			Name:       obj.GetName(),
+		}
+
+		live := &unstructured.Unstructured{}
+		live.SetGroupVersionKind(obj.GroupVersionKind())
+		if err := r.Client.Get(ctx, types.NamespacedName{Namespace: obj.GetNamespace(), Name: obj.GetName()}, live); err != nil {
+			resource.Message = err.Error()
+		} else {
+			resource.Ready, resource.Message = checkHealth(live)
+		}
+
+		if !resource.Ready {
+			notReady = append(notReady, fmt.Sprintf("%s: %s", objectKey(obj), resource.Message))
+		}
+		resources = append(resources, resource)
+	}
+	addon.Status.Resources = resources
+
+	if len(notReady) == 0 {
+		addon.Status.Phase = v1alpha3.AddonPhaseReady
+		addon.Status.Version = addon.Spec.Version
+		addon.Status.SetCondition(v1alpha3.Condition{
+			Type:   v1alpha3.AddonConditionReady,
+			Status: v1alpha3.ConditionTrue,
+			Reason: ConditionReasonResourcesReady,
+		})
+	} else {
+		addon.Status.Phase = v1alpha3.AddonPhaseInstalling
+		addon.Status.SetCondition(v1alpha3.Condition{
+			Type:    v1alpha3.AddonConditionReady,
+			Status:  v1alpha3.ConditionFalse,
+			Reason:  ConditionReasonResourcesNotReady,
+			Message: strings.Join(notReady, "; "),
+		})
+	}
+}
+
+// updateStatus updates the status of the addon, it ignores the missing addon
+func (r *Reconciler) updateStatus(ctx context.Context, addon *v1alpha3.Addon) (err error) {
+	addon.Status.ObservedGeneration = addon.Generation
+	if err = r.Client.Status().Update(ctx, addon); err != nil {
+		if apierrors.IsNotFound(err) {
+			err = nil
+		} else {
+			r.recorder.Eventf(addon, corev1.EventTypeWarning, EventReasonMissing, "failed to update status, error: %v", err)
+		}
+	}
+	return
+}
+
+// checkHealth returns whether an object is ready, and the reason if it is not.
+// An object without any known health signal is considered ready once it exists,
+// but an object which has a spec is expected to report its status.
+func checkHealth(obj *unstructured.Unstructured) (ready bool, message string) {
+	status, ok, _ := unstructured.NestedMap(obj.Object, "status")
+	if !ok {
+		if _, hasSpec := obj.Object["spec"]; hasSpec {
+			return false, "waiting for the status"
+		}
+		return true, ""
+	}
+
+	// the status is out of date when the spec was changed just now
+	if observed, ok, _ := unstructured.NestedInt64(status, "observedGeneration"); ok && observed < obj.GetGeneration() {
+		return false, "waiting for the latest generation to be observed"
+	}
+
+	conditions, _, _ := unstructured.NestedSlice(status, "conditions")
+	for _, item := range conditions {
+		condition, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if conditionType := condition["type"]; conditionType != "Ready" && conditionType != "Available" {
+			continue
+		}
+		if condition["status"] == string(v1alpha3.ConditionTrue) {
+			return true, ""
+		}
+		if message, _ := condition["message"].(string); message != "" {
+			return false, message
+		}
+		return false, fmt.Sprintf("condition %v is %v", condition["type"], condition["status"])
+	}
+
+	for _, field := range []string{"phase", "state"} {
+		if phase, ok, _ := unstructured.NestedString(status, field); ok && phase != "" {
+			for _, readyPhase := range readyPhases {
+				if phase == readyPhase {
+					return true, ""
+				}
+			}
+			return false, fmt.Sprintf("%s is %s", field, phase)
+		}
+	}
+
+	// workloads like Deployment and StatefulSet
+	if _, ok := status["replicas"]; ok {
+		desired, found, _ := unstructured.NestedInt64(obj.Object, "spec", "replicas")
+		if !found {
+			desired = 1
+		}
+		readyReplicas, _, _ := unstructured.NestedInt64(status, "readyReplicas")
+		if readyReplicas < desired {
+			return false, fmt.Sprintf("%d of %d replicas are ready", readyReplicas, desired)
+		}
+	}
+	return true, ""
+}
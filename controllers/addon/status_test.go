@@ -0,0 +1,211 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package addon
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func Test_checkHealth(t *testing.T) {
+	tests := []struct {
+		name        string
+		object      map[string]interface{}
+		wantReady   bool
+		wantMessage string
+	}{{
+		name:      "no status",
+		object:    map[string]interface{}{"kind": "ConfigMap"},
+		wantReady: true,
+	}, {
+		name:        "no status but has a spec",
+		object:      map[string]interface{}{"kind": "HelmRelease", "spec": map[string]interface{}{}},
+		wantMessage: "waiting for the status",
+	}, {
+		name: "ready condition is true",
+		object: map[string]interface{}{"status": map[string]interface{}{
+			"conditions": []interface{}{map[string]interface{}{"type": "Ready", "status": "True"}},
+		}},
+		wantReady: true,
+	}, {
+		name: "ready condition is false",
+		object: map[string]interface{}{"status": map[string]interface{}{
+			"conditions": []interface{}{
+				map[string]interface{}{"type": "Released", "status": "True"},
+				map[string]interface{}{"type": "Ready", "status": "False", "message": "install retries exhausted"},
+			},
+		}},
+		wantMessage: "install retries exhausted",
+	}, {
+		name: "available condition without message",
+		object: map[string]interface{}{"status": map[string]interface{}{
+			"conditions": []interface{}{map[string]interface{}{"type": "Available", "status": "Unknown"}},
+		}},
+		wantMessage: "condition Available is Unknown",
+	}, {
+		name: "out of date status",
+		object: map[string]interface{}{
+			"metadata": map[string]interface{}{"generation": int64(2)},
+			"status": map[string]interface{}{
+				"observedGeneration": int64(1),
+				"conditions":         []interface{}{map[string]interface{}{"type": "Ready", "status": "True"}},
+			},
+		},
+		wantMessage: "waiting for the latest generation to be observed",
+	}, {
+		name:      "available phase",
+		object:    map[string]interface{}{"status": map[string]interface{}{"phase": "Available"}},
+		wantReady: true,
+	}, {
+		name:        "pending phase",
+		object:      map[string]interface{}{"status": map[string]interface{}{"phase": "Pending"}},
+		wantMessage: "phase is Pending",
+	}, {
+		name:      "the state of a subscription",
+		object:    map[string]interface{}{"status": map[string]interface{}{"state": "AtLatestKnown"}},
+		wantReady: true,
+	}, {
+		name: "replicas are not ready",
+		object: map[string]interface{}{
+			"spec":   map[string]interface{}{"replicas": int64(2)},
+			"status": map[string]interface{}{"replicas": int64(2), "readyReplicas": int64(1)},
+		},
+		wantMessage: "1 of 2 replicas are ready",
+	}, {
+		name: "replicas are ready",
+		object: map[string]interface{}{
+			"status": map[string]interface{}{"replicas": int64(1), "readyReplicas": int64(1)},
+		},
+		wantReady: true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ready, message := checkHealth(&unstructured.Unstructured{Object: tt.object})
+			assert.Equal(t, tt.wantReady, ready)
+			assert.Equal(t, tt.wantMessage, message)
+		})
+	}
+}
+
+func TestReconciler_Reconcile_status(t *testing.T) {
+	schema, err := v1alpha3.SchemeBuilder.Register().Build()
+	assert.Nil(t, err)
+	err = v1.AddToScheme(schema)
+	assert.Nil(t, err)
+
+	simpleStrategy := &v1alpha3.AddonStrategy{
+		ObjectMeta: metav1.ObjectMeta{Name: "simple-config"},
+		Spec: v1alpha3.AddStrategySpec{
+			Type: v1alpha3.AddonInstallStrategySimple,
+			YAML: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config`,
+		},
+	}
+	helmStrategy := &v1alpha3.AddonStrategy{
+		ObjectMeta: metav1.ObjectMeta{Name: "helm-jenkins"},
+		Spec: v1alpha3.AddStrategySpec{
+			Type:     v1alpha3.AddonInstallStrategyHelm,
+			HelmRepo: "https://charts.jenkins.io",
+		},
+	}
+	newAddonWithStrategy := func(strategy string) *v1alpha3.Addon {
+		return &v1alpha3.Addon{
+			ObjectMeta: metav1.ObjectMeta{Name: "fake", Namespace: "default"},
+			Spec: v1alpha3.AddonSpec{
+				Version:  "v1.0.0",
+				Strategy: v1.LocalObjectReference{Name: strategy},
+			},
+		}
+	}
+
+	tests := []struct {
+		name          string
+		objects       []client.Object
+		wantErr       bool
+		wantResult    ctrl.Result
+		wantPhase     v1alpha3.AddonPhase
+		wantReason    string
+		wantResources int
+		wantReady     bool
+	}{{
+		name:       "strategy does not exist",
+		objects:    []client.Object{newAddonWithStrategy("missing")},
+		wantErr:    true,
+		wantPhase:  v1alpha3.AddonPhaseFailed,
+		wantReason: ConditionReasonStrategyNotFound,
+	}, {
+		name:       "failed to render",
+		objects:    []client.Object{newAddonWithStrategy("helm-jenkins"), &v1alpha3.AddonStrategy{ObjectMeta: metav1.ObjectMeta{Name: "helm-jenkins"}, Spec: v1alpha3.AddStrategySpec{Type: v1alpha3.AddonInstallStrategyHelm}}},
+		wantErr:    true,
+		wantPhase:  v1alpha3.AddonPhaseFailed,
+		wantReason: ConditionReasonRenderFailed,
+	}, {
+		name:          "all resources are ready",
+		objects:       []client.Object{newAddonWithStrategy("simple-config"), simpleStrategy},
+		wantResult:    ctrl.Result{RequeueAfter: readyCheckInterval},
+		wantPhase:     v1alpha3.AddonPhaseReady,
+		wantReason:    ConditionReasonApplied,
+		wantResources: 1,
+		wantReady:     true,
+	}, {
+		name:          "the HelmRelease is not ready",
+		objects:       []client.Object{newAddonWithStrategy("helm-jenkins"), helmStrategy},
+		wantResult:    ctrl.Result{RequeueAfter: installingCheckInterval},
+		wantPhase:     v1alpha3.AddonPhaseInstalling,
+		wantReason:    ConditionReasonApplied,
+		wantResources: 2,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := fake.NewClientBuilder().WithScheme(schema).WithObjects(tt.objects...).Build()
+			r := &Reconciler{
+				Client:   c,
+				log:      logr.Discard(),
+				recorder: record.NewFakeRecorder(100),
+			}
+			result, err := r.Reconcile(context.Background(), ctrl.Request{
+				NamespacedName: types.NamespacedName{Namespace: "default", Name: "fake"},
+			})
+			assert.Equal(t, tt.wantErr, err != nil, err)
+			assert.Equal(t, tt.wantResult, result)
+
+			addon := &v1alpha3.Addon{}
+			err = c.Get(context.Background(), types.NamespacedName{Namespace: "default", Name: "fake"}, addon)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.wantPhase, addon.Status.Phase)
+			if condition := addon.Status.GetCondition(v1alpha3.AddonConditionInstalled); assert.NotNil(t, condition) {
+				assert.Equal(t, tt.wantReason, condition.Reason)
+			}
+			assert.Equal(t, tt.wantResources, len(addon.Status.Resources))
+			assert.Equal(t, tt.wantReady, addon.Status.IsReady())
+		})
+	}
+}
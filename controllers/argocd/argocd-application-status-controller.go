@@ -30,11 +30,12 @@ import (
 	"k8s.io/client-go/tools/record"
 	"kubesphere.io/devops/controllers/predicate"
 	"kubesphere.io/devops/pkg/api/gitops/v1alpha1"
+	"kubesphere.io/devops/pkg/metrics"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
-//+kubebuilder:rbac:groups=gitops.kubesphere.io,resources=applications,verbs=get;update
+//+kubebuilder:rbac:groups=gitops.kubesphere.io,resources=applications,verbs=get;list;watch;update
 //+kubebuilder:rbac:groups=gitops.kubesphere.io,resources=applications/status,verbs=get;update
 //+kubebuilder:rbac:groups=argoproj.io,resources=applications,verbs=get;list;watch
 
@@ -163,6 +164,9 @@ func (r *ApplicationStatusReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	argoApp := createBareArgoCDApplicationObject()
 	r.log = ctrl.Log.WithName(r.GetName())
 	r.recorder = mgr.GetEventRecorderFor(r.GetName())
+	if err := metrics.Register(&applicationCollector{reader: mgr.GetClient()}); err != nil {
+		return err
+	}
 	var withLabelPredicate = predicate.NewPredicateFuncs(predicate.NewFilterHasLabel(v1alpha1.ArgoCDAppControlByLabelKey))
 	return ctrl.NewControllerManagedBy(mgr).
 		For(argoApp).
@@ -0,0 +1,70 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package argocd
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/klog/v2"
+	"kubesphere.io/devops/pkg/api/gitops/v1alpha1"
+	"kubesphere.io/devops/pkg/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var (
+	applicationSyncStatusDesc = prometheus.NewDesc(metrics.Namespace+"_argocd_application_sync_status",
+		"The sync status of the Argo CD Applications, it's always 1", []string{"namespace", "application", "status"}, nil)
+	applicationHealthStatusDesc = prometheus.NewDesc(metrics.Namespace+"_argocd_application_health_status",
+		"The health status of the Argo CD Applications, it's always 1", []string{"namespace", "application", "status"}, nil)
+)
+
+// applicationCollector collects the status of the Argo CD Applications from the labels
+// which are set by ApplicationStatusReconciler
+type applicationCollector struct {
+	reader client.Reader
+}
+
+// Describe implements prometheus.Collector
+func (c *applicationCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- applicationSyncStatusDesc
+	ch <- applicationHealthStatusDesc
+}
+
+// Collect implements prometheus.Collector
+func (c *applicationCollector) Collect(ch chan<- prometheus.Metric) {
+	appList := &v1alpha1.ApplicationList{}
+	if err := c.reader.List(context.Background(), appList); err != nil {
+		klog.V(4).Infof("failed to list applications, error: %v", err)
+		return
+	}
+
+	for i := range appList.Items {
+		app := &appList.Items[i]
+		if app.Spec.ArgoApp == nil {
+			continue
+		}
+		if status := app.Labels[v1alpha1.SyncStatusLabelKey]; status != "" {
+			ch <- prometheus.MustNewConstMetric(applicationSyncStatusDesc, prometheus.GaugeValue, 1,
+				app.Namespace, app.Name, status)
+		}
+		if status := app.Labels[v1alpha1.HealthStatusLabelKey]; status != "" {
+			ch <- prometheus.MustNewConstMetric(applicationHealthStatusDesc, prometheus.GaugeValue, 1,
+				app.Namespace, app.Name, status)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package argocd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"kubesphere.io/devops/pkg/api/gitops/v1alpha1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestApplicationCollector(t *testing.T) {
+	schema, err := v1alpha1.SchemeBuilder.Register().Build()
+	assert.Nil(t, err)
+
+	newApp := func(name string, labels map[string]string, spec v1alpha1.ApplicationSpec) *v1alpha1.Application {
+		return &v1alpha1.Application{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: name, Labels: labels},
+			Spec:       spec,
+		}
+	}
+	argoSpec := v1alpha1.ApplicationSpec{ArgoApp: &v1alpha1.ArgoApplication{}}
+	reader := fake.NewClientBuilder().WithScheme(schema).WithObjects(
+		newApp("synced", map[string]string{
+			v1alpha1.SyncStatusLabelKey:   "Synced",
+			v1alpha1.HealthStatusLabelKey: "Healthy",
+		}, argoSpec),
+		newApp("out-of-sync", map[string]string{
+			v1alpha1.SyncStatusLabelKey: "OutOfSync",
+		}, argoSpec),
+		newApp("no-status", nil, argoSpec),
+		newApp("flux", map[string]string{
+			v1alpha1.SyncStatusLabelKey: "Synced",
+		}, v1alpha1.ApplicationSpec{FluxApp: &v1alpha1.FluxApplication{}}),
+	).Build()
+
+	expected := `
+# HELP ks_devops_argocd_application_health_status The health status of the Argo CD Applications, it's always 1
+# TYPE ks_devops_argocd_application_health_status gauge
+ks_devops_argocd_application_health_status{application="synced",namespace="ns",status="Healthy"} 1
+# HELP ks_devops_argocd_application_sync_status The sync status of the Argo CD Applications, it's always 1
+# TYPE ks_devops_argocd_application_sync_status gauge
+ks_devops_argocd_application_sync_status{application="out-of-sync",namespace="ns",status="OutOfSync"} 1
+ks_devops_argocd_application_sync_status{application="synced",namespace="ns",status="Synced"} 1
+`
+	assert.Nil(t, testutil.CollectAndCompare(&applicationCollector{reader: reader}, strings.NewReader(expected)))
+}
@@ -28,6 +28,7 @@ import (
 	helmv2 "kubesphere.io/devops/pkg/external/fluxcd/helm/v2beta1"
 	kusv1 "kubesphere.io/devops/pkg/external/fluxcd/kustomize/v1beta2"
 	apimeta "kubesphere.io/devops/pkg/external/fluxcd/meta"
+	"kubesphere.io/devops/pkg/metrics"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
@@ -35,7 +36,7 @@ import (
 	"strconv"
 )
 
-//+kubebuilder:rbac:groups=gitops.kubesphere.io,resources=applications,verbs=get;update
+//+kubebuilder:rbac:groups=gitops.kubesphere.io,resources=applications,verbs=get;list;watch;update
 //+kubebuilder:rbac:groups=gitops.kubesphere.io,resources=applications/status,verbs=get;update
 //+kubebuilder:rbac:groups="kustomize.toolkit.fluxcd.io",resources=kustomizations,verbs=get;list;watch
 //+kubebuilder:rbac:groups="helm.toolkit.fluxcd.io",resources=helmreleases,verbs=get;list;watch
@@ -179,6 +180,9 @@ func (r *ApplicationStatusReconciler) GetName() string {
 func (r *ApplicationStatusReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	r.log = ctrl.Log.WithName(r.GetName())
 	r.recorder = mgr.GetEventRecorderFor(r.GetName())
+	if err := metrics.Register(&applicationCollector{reader: mgr.GetClient()}); err != nil {
+		return err
+	}
 	return ctrl.NewControllerManagedBy(mgr).
 		Watches(&source.Kind{Type: &kusv1.Kustomization{}}, &handler.EnqueueRequestForObject{}).
 		For(&helmv2.HelmRelease{}).
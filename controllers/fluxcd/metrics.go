@@ -0,0 +1,89 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fluxcd
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/klog/v2"
+	"kubesphere.io/devops/pkg/api/gitops/v1alpha1"
+	"kubesphere.io/devops/pkg/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var (
+	applicationReadyDesc = prometheus.NewDesc(metrics.Namespace+"_fluxcd_application_ready_resources",
+		"The number of the ready HelmReleases or Kustomizations of the FluxCD Applications",
+		[]string{"namespace", "application", "type"}, nil)
+	applicationResourcesDesc = prometheus.NewDesc(metrics.Namespace+"_fluxcd_application_resources",
+		"The number of the HelmReleases or Kustomizations of the FluxCD Applications",
+		[]string{"namespace", "application", "type"}, nil)
+)
+
+// applicationCollector collects the readiness of the FluxCD Applications from the labels
+// which are set by ApplicationStatusReconciler
+type applicationCollector struct {
+	reader client.Reader
+}
+
+// Describe implements prometheus.Collector
+func (c *applicationCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- applicationReadyDesc
+	ch <- applicationResourcesDesc
+}
+
+// Collect implements prometheus.Collector
+func (c *applicationCollector) Collect(ch chan<- prometheus.Metric) {
+	appList := &v1alpha1.ApplicationList{}
+	if err := c.reader.List(context.Background(), appList); err != nil {
+		klog.V(4).Infof("failed to list applications, error: %v", err)
+		return
+	}
+
+	for i := range appList.Items {
+		app := &appList.Items[i]
+		if app.Spec.FluxApp == nil {
+			continue
+		}
+		ready, total, ok := parseReadyNumber(app.Labels[FluxAppReadyNumKey])
+		if !ok {
+			continue
+		}
+		appType := app.Labels[FluxAppTypeKey]
+		ch <- prometheus.MustNewConstMetric(applicationReadyDesc, prometheus.GaugeValue, float64(ready),
+			app.Namespace, app.Name, appType)
+		ch <- prometheus.MustNewConstMetric(applicationResourcesDesc, prometheus.GaugeValue, float64(total),
+			app.Namespace, app.Name, appType)
+	}
+}
+
+// parseReadyNumber parses the ready number label, it's in form of readyNumber-totalNumber
+func parseReadyNumber(readyNumber string) (ready, total int, ok bool) {
+	numbers := strings.SplitN(readyNumber, "-", 2)
+	if len(numbers) != 2 {
+		return
+	}
+	readyNum, readyErr := strconv.Atoi(numbers[0])
+	totalNum, totalErr := strconv.Atoi(numbers[1])
+	if readyErr == nil && totalErr == nil {
+		ready, total, ok = readyNum, totalNum, true
+	}
+	return
+}
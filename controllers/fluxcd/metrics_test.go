@@ -0,0 +1,97 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fluxcd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"kubesphere.io/devops/pkg/api/gitops/v1alpha1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestParseReadyNumber(t *testing.T) {
+	tests := []struct {
+		readyNumber string
+		wantReady   int
+		wantTotal   int
+		wantOK      bool
+	}{{
+		readyNumber: "1-2",
+		wantReady:   1,
+		wantTotal:   2,
+		wantOK:      true,
+	}, {
+		readyNumber: "",
+	}, {
+		readyNumber: "1",
+	}, {
+		readyNumber: "a-2",
+	}, {
+		readyNumber: "1-b",
+	}}
+	for _, tt := range tests {
+		t.Run(tt.readyNumber, func(t *testing.T) {
+			ready, total, ok := parseReadyNumber(tt.readyNumber)
+			assert.Equal(t, tt.wantReady, ready)
+			assert.Equal(t, tt.wantTotal, total)
+			assert.Equal(t, tt.wantOK, ok)
+		})
+	}
+}
+
+func TestApplicationCollector(t *testing.T) {
+	schema, err := v1alpha1.SchemeBuilder.Register().Build()
+	assert.Nil(t, err)
+
+	newApp := func(name string, labels map[string]string, spec v1alpha1.ApplicationSpec) *v1alpha1.Application {
+		return &v1alpha1.Application{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: name, Labels: labels},
+			Spec:       spec,
+		}
+	}
+	fluxSpec := v1alpha1.ApplicationSpec{FluxApp: &v1alpha1.FluxApplication{}}
+	reader := fake.NewClientBuilder().WithScheme(schema).WithObjects(
+		newApp("helm", map[string]string{
+			FluxAppReadyNumKey: "1-2",
+			FluxAppTypeKey:     string(HelmRelease),
+		}, fluxSpec),
+		newApp("kustomization", map[string]string{
+			FluxAppReadyNumKey: "1-1",
+			FluxAppTypeKey:     string(Kustomization),
+		}, fluxSpec),
+		newApp("no-status", nil, fluxSpec),
+		newApp("argo", map[string]string{
+			FluxAppReadyNumKey: "1-1",
+		}, v1alpha1.ApplicationSpec{ArgoApp: &v1alpha1.ArgoApplication{}}),
+	).Build()
+
+	expected := `
+# HELP ks_devops_fluxcd_application_ready_resources The number of the ready HelmReleases or Kustomizations of the FluxCD Applications
+# TYPE ks_devops_fluxcd_application_ready_resources gauge
+ks_devops_fluxcd_application_ready_resources{application="helm",namespace="ns",type="HelmRelease"} 1
+ks_devops_fluxcd_application_ready_resources{application="kustomization",namespace="ns",type="Kustomization"} 1
+# HELP ks_devops_fluxcd_application_resources The number of the HelmReleases or Kustomizations of the FluxCD Applications
+# TYPE ks_devops_fluxcd_application_resources gauge
+ks_devops_fluxcd_application_resources{application="helm",namespace="ns",type="HelmRelease"} 2
+ks_devops_fluxcd_application_resources{application="kustomization",namespace="ns",type="Kustomization"} 1
+`
+	assert.Nil(t, testutil.CollectAndCompare(&applicationCollector{reader: reader}, strings.NewReader(expected)))
+}
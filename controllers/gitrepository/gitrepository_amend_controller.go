@@ -63,6 +63,7 @@ func init() {
 		&gitlabPublicAmend{},
 		&githubPublicAmend{},
 		&bitbucketPublicAmend{},
+		&selfHostedAmend{},
 	}
 }
 
@@ -126,6 +127,24 @@ func (a *bitbucketPublicAmend) Amend(repo *v1alpha3.GitRepository) (changed bool
 	return
 }
 
+// selfHostedAmend generates the URL from the server for the self-hosted git providers, such as Gitea and Gogs
+type selfHostedAmend struct {
+}
+
+func (a *selfHostedAmend) Match(repo *v1alpha3.GitRepository) bool {
+	provider := strings.ToLower(repo.Spec.Provider)
+	return provider == "gitea" || provider == "gogs"
+}
+
+func (a *selfHostedAmend) Amend(repo *v1alpha3.GitRepository) (changed bool) {
+	if repo.Spec.URL == "" && repo.Spec.Server != "" {
+		repo.Spec.URL = fmt.Sprintf("%s/%s/%s",
+			strings.TrimSuffix(repo.Spec.Server, "/"), repo.Spec.Owner, repo.Spec.Repo)
+		changed = true
+	}
+	return
+}
+
 func (r *AmendReconciler) GetName() string {
 	return "git-repository-amend"
 }
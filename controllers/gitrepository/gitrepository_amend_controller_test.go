@@ -95,6 +95,67 @@ func Test_amendGitlabURL(t *testing.T) {
 	}
 }
 
+func Test_amendSelfHostedURL(t *testing.T) {
+	tests := []struct {
+		name        string
+		repo        *v1alpha3.GitRepository
+		wantMatched bool
+		wantChanged bool
+		wantURL     string
+	}{{
+		name:        "not a self-hosted provider",
+		repo:        &v1alpha3.GitRepository{Spec: v1alpha3.GitRepositorySpec{Provider: "github"}},
+		wantMatched: false,
+	}, {
+		name: "gitea, have server, owner and repo, but without URL",
+		repo: &v1alpha3.GitRepository{
+			Spec: v1alpha3.GitRepositorySpec{
+				Provider: "Gitea",
+				Server:   "https://gitea.com/",
+				Owner:    "linuxsuren",
+				Repo:     "test",
+			},
+		},
+		wantMatched: true,
+		wantChanged: true,
+		wantURL:     "https://gitea.com/linuxsuren/test",
+	}, {
+		name: "gogs, have URL",
+		repo: &v1alpha3.GitRepository{
+			Spec: v1alpha3.GitRepositorySpec{
+				Provider: "gogs",
+				Server:   "https://gogs.com",
+				URL:      "https://gogs.com/linuxsuren/test.git",
+			},
+		},
+		wantMatched: true,
+		wantChanged: false,
+		wantURL:     "https://gogs.com/linuxsuren/test.git",
+	}, {
+		name: "gitea, without server",
+		repo: &v1alpha3.GitRepository{
+			Spec: v1alpha3.GitRepositorySpec{
+				Provider: "gitea",
+				Owner:    "linuxsuren",
+				Repo:     "test",
+			},
+		},
+		wantMatched: true,
+		wantChanged: false,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			amend := selfHostedAmend{}
+			assert.Equal(t, tt.wantMatched, amend.Match(tt.repo))
+			if !tt.wantMatched {
+				return
+			}
+			assert.Equal(t, tt.wantChanged, amend.Amend(tt.repo))
+			assert.Equal(t, tt.wantURL, tt.repo.Spec.URL)
+		})
+	}
+}
+
 func TestAmendReconciler_SetupWithManager(t *testing.T) {
 	schema, err := v1alpha3.SchemeBuilder.Register().Build()
 	assert.Nil(t, err)
@@ -16,6 +16,7 @@ package gitrepository
 import (
 	"context"
 	"fmt"
+	"net/url"
 	"strings"
 	"time"
 
@@ -109,8 +110,13 @@ func (r *Reconciler) createOrUpdateWebhook(repo *v1alpha3.GitRepository) (err er
 			continue
 		}
 
+		// the secret of the webhook takes precedence over the one of the git repository
+		secretRef := repo.Spec.Secret
+		if webhook.Spec.Secret != nil {
+			secretRef = webhook.Spec.Secret
+		}
 		// the token is optional, we can ignore the error
-		webhookToken, _ := r.getTokenFromSecret(repo.Spec.Secret, repo.Namespace)
+		webhookToken, _ := r.getTokenFromSecret(secretRef, repo.Namespace)
 
 		// TODO users need to add every single event of target git provider if they want to add all of them
 		//   it's possible to have a solution to allow users add all events in an easy way.
@@ -123,9 +129,15 @@ func (r *Reconciler) createOrUpdateWebhook(repo *v1alpha3.GitRepository) (err er
 			NativeEvents: webhook.Spec.Events,
 		}
 
-		if ok, _ := exist(webhook.Spec.Server, hooks); ok {
+		if ok, id := exist(webhook.Spec.Server, hooks); ok {
 			// update the existing webhooks
 			_, _, err = gitClient.Repositories.UpdateHook(context.TODO(), repoAddress, hookInput)
+			if err == scm.ErrNotSupported {
+				// some git providers are not able to update a webhook, such as Gitea and Gogs, recreate it instead
+				if _, err = gitClient.Repositories.DeleteHook(context.TODO(), repoAddress, id); err == nil {
+					_, _, err = gitClient.Repositories.CreateHook(context.TODO(), repoAddress, hookInput)
+				}
+			}
 		} else {
 			// create the webhook
 			_, _, err = gitClient.Repositories.CreateHook(context.TODO(), repoAddress, hookInput)
@@ -136,7 +148,7 @@ func (r *Reconciler) createOrUpdateWebhook(repo *v1alpha3.GitRepository) (err er
 
 func exist(server string, hooks []*scm.Hook) (exist bool, id string) {
 	for _, hook := range hooks {
-		if hook.Target == server {
+		if trimSecretParam(hook.Target) == server {
 			id = hook.ID
 			exist = true
 			break
@@ -145,6 +157,22 @@ func exist(server string, hooks []*scm.Hook) (exist bool, id string) {
 	return
 }
 
+// trimSecretParam removes the secret query parameter from a webhook target.
+// Some git providers put the secret in it, such as Gitea.
+func trimSecretParam(target string) string {
+	targetURL, err := url.Parse(target)
+	if err != nil {
+		return target
+	}
+	query := targetURL.Query()
+	if _, ok := query["secret"]; !ok {
+		return target
+	}
+	query.Del("secret")
+	targetURL.RawQuery = query.Encode()
+	return targetURL.String()
+}
+
 func (r *Reconciler) getGitClient(repo *v1alpha3.GitRepository) (client *scm.Client, err error) {
 	spec := repo.Spec.DeepCopy()
 	provider := spec.Provider
@@ -153,7 +181,11 @@ func (r *Reconciler) getGitClient(repo *v1alpha3.GitRepository) (client *scm.Cli
 	if spec.Secret != nil && spec.Secret.Namespace == "" {
 		spec.Secret.Namespace = repo.Namespace
 	}
-	return git.NewClientFactory(provider, spec.Secret, r.Client).GetClient()
+	factory := git.NewClientFactory(provider, spec.Secret, r.Client)
+	if isSelfHosted(provider) {
+		factory.Server = spec.Server
+	}
+	return factory.GetClient()
 }
 
 func (r *Reconciler) getTokenFromSecret(secretRef *v1.SecretReference, defaultNamespace string) (token string, err error) {
@@ -198,10 +230,22 @@ func getRepo(repo *v1alpha3.GitRepository) string {
 		return strings.ReplaceAll(address, "https://github.com/", "")
 	case "gitlab":
 		return strings.ReplaceAll(address, "https://gitlab.com/", "")
+	case "gitea", "gogs":
+		if repo.Spec.Owner != "" && repo.Spec.Repo != "" {
+			return fmt.Sprintf("%s/%s", repo.Spec.Owner, repo.Spec.Repo)
+		}
+		if server := strings.TrimSuffix(repo.Spec.Server, "/"); server != "" && strings.HasPrefix(address, server+"/") {
+			return strings.TrimSuffix(strings.TrimPrefix(address, server+"/"), ".git")
+		}
 	}
 	return ""
 }
 
+// isSelfHosted returns true if the git provider is always self-hosted, the server is required by it
+func isSelfHosted(provider string) bool {
+	return provider == "gitea" || provider == "gogs"
+}
+
 func (r *Reconciler) linkToWebhooks(repo *v1alpha3.GitRepository) (err error) {
 	var failedLinks []string
 	for i := range repo.Spec.Webhooks {
@@ -80,6 +80,26 @@ func Test_getRepo(t *testing.T) {
 			}},
 		},
 		want: "linuxsuren/test",
+	}, {
+		name: "gitea with the owner and repo",
+		args: args{
+			repo: &v1alpha3.GitRepository{Spec: v1alpha3.GitRepositorySpec{
+				Provider: "gitea",
+				Owner:    "linuxsuren",
+				Repo:     "test",
+			}},
+		},
+		want: "linuxsuren/test",
+	}, {
+		name: "gogs with the server and URL",
+		args: args{
+			repo: &v1alpha3.GitRepository{Spec: v1alpha3.GitRepositorySpec{
+				Provider: "gogs",
+				Server:   "https://gogs.com/",
+				URL:      "https://gogs.com/linuxsuren/test.git",
+			}},
+		},
+		want: "linuxsuren/test",
 	}}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -132,6 +152,17 @@ func Test_exist(t *testing.T) {
 		},
 		wantExist: true,
 		wantId:    "fake-id",
+	}, {
+		name: "exist with the secret parameter",
+		args: args{
+			server: "https://fake.com/webhook?ns=fake",
+			hooks: []*scm.Hook{{
+				ID:     "fake-id",
+				Target: "https://fake.com/webhook?ns=fake&secret=token",
+			}},
+		},
+		wantExist: true,
+		wantId:    "fake-id",
 	}}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
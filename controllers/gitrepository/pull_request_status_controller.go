@@ -94,7 +94,8 @@ func (r *PullRequestStatusReconciler) Reconcile(ctx context.Context, req ctrl.Re
 	}
 
 	maker := NewStatusMaker(repo, token)
-	maker.WithTarget(target).WithPR(prNumber).WithProvider(repoInfo.provider).WithUsername(username)
+	maker.WithTarget(target).WithPR(prNumber).WithProvider(repoInfo.provider).WithUsername(username).
+		WithServer(repoInfo.server)
 	maker.WithExpirationCheck(createExpirationCheckFunc(ctx, r, pipelinerun.DeepCopy()))
 
 	var desc string
@@ -161,9 +162,11 @@ func getPipelineRunNameAndNsFromURL(link string) (name, ns string, err error) {
 
 type repoInformation struct {
 	provider string
-	owner    string
-	repo     string
-	tokenId  string
+	// server is the address of a self-hosted git provider, it's empty for the public ones
+	server  string
+	owner   string
+	repo    string
+	tokenId string
 }
 
 func (r repoInformation) getRepoPath() string {
@@ -178,6 +181,8 @@ func getRepoInfo(repo *v1alpha3.MultiBranchPipeline) (info repoInformation) {
 	if repo == nil {
 		return
 	}
+	// the server is required by the self-hosted git providers, such as GitHub Enterprise and Bitbucket Server
+	info.server = repo.ResolveGitURLs().Server
 	switch repo.SourceType {
 	case v1alpha3.SourceTypeBitbucket:
 		if repo.BitbucketServerSource != nil {
@@ -185,7 +190,9 @@ func getRepoInfo(repo *v1alpha3.MultiBranchPipeline) (info repoInformation) {
 			info.owner = repo.BitbucketServerSource.Owner
 			info.repo = repo.BitbucketServerSource.Repo
 			info.tokenId = repo.BitbucketServerSource.CredentialId
-			if repo.BitbucketServerSource.ApiUri == "https://bitbucket.org" {
+			if info.server != "" {
+				info.provider = "bitbucketserver"
+			} else if repo.BitbucketServerSource.ApiUri == "https://bitbucket.org" {
 				info.provider = "bitbucketcloud"
 			}
 		}
@@ -204,6 +211,13 @@ func getRepoInfo(repo *v1alpha3.MultiBranchPipeline) (info repoInformation) {
 			info.repo = strings.TrimPrefix(repo.GitlabSource.Repo, repo.GitlabSource.Owner+"/")
 			info.tokenId = repo.GitlabSource.CredentialId
 		}
+	case v1alpha3.SourceTypeGitea:
+		if repo.GiteaSource != nil {
+			info.provider = "gitea"
+			info.owner = repo.GiteaSource.Owner
+			info.repo = repo.GiteaSource.Repo
+			info.tokenId = repo.GiteaSource.CredentialId
+		}
 	}
 	return
 }
@@ -377,6 +391,12 @@ func (s *StatusMaker) Create(ctx context.Context, status scm.State, label, desc
 
 	var pullRequest *scm.PullRequest
 	if pullRequest, _, err = scmClient.PullRequests.Find(ctx, s.repo, s.pr); err == nil {
+		if pullRequest == nil {
+			// some drivers return nothing instead of an error, such as Gitea
+			err = fmt.Errorf("cannot find the pull request %d of %s", s.pr, s.repo)
+			return
+		}
+
 		var previousStatus *scm.Status
 		if previousStatus, err = s.FindPreviousStatus(ctx, scmClient, pullRequest.Sha, label); err != nil {
 			return
@@ -131,6 +131,36 @@ func TestCreatePullRequestStatus(t *testing.T) {
 			return maker
 		},
 		wantErr: true,
+	}, {
+		name: "gitea",
+		createStatusMaker: func() *StatusMaker {
+			gock.New("https://gitea.com").
+				Get("/api/v1/version").
+				Reply(200).
+				JSON(map[string]string{"version": "1.17.0"})
+
+			gock.New("https://gitea.com").
+				Get("/api/v1/repos/octocat/hello-world/pulls/1347").
+				Reply(200).
+				Type("application/json").
+				File("testdata/gitea-pr.json")
+
+			gock.New("https://gitea.com").
+				Get("/api/v1/repos/octocat/hello-world/commits/6dcb09b5b57875f334f61aebed695e2e4193db5e/statuses").
+				Reply(200).
+				JSON([]interface{}{})
+
+			gock.New("https://gitea.com").
+				Post("/api/v1/repos/octocat/hello-world/statuses/6dcb09b5b57875f334f61aebed695e2e4193db5e").
+				Reply(201).
+				JSON(map[string]string{"state": "success"})
+
+			maker := NewStatusMaker("octocat/hello-world", "token")
+			maker.WithProvider("gitea").WithServer("https://gitea.com").
+				WithTarget("https://ci.example.com/1000/output").WithPR(1347)
+			return maker
+		},
+		wantErr: false,
 	}}
 	for i, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -436,6 +466,54 @@ func TestGetRepoInfo(t *testing.T) {
 			},
 		},
 		wantInfo: repoInformation{owner: "owner", repo: "repo", tokenId: "token", provider: "bitbucketcloud"},
+	}, {
+		name: "gitea",
+		repo: &v1alpha3.MultiBranchPipeline{
+			SourceType: v1alpha3.SourceTypeGitea,
+			GiteaSource: &v1alpha3.GiteaSource{
+				ServerUrl:    "https://gitea.com",
+				Owner:        "owner",
+				Repo:         "repo",
+				CredentialId: "token",
+			},
+		},
+		wantInfo: repoInformation{owner: "owner", repo: "repo", tokenId: "token", provider: "gitea", server: "https://gitea.com"},
+	}, {
+		name: "GitHub Enterprise",
+		repo: &v1alpha3.MultiBranchPipeline{
+			SourceType: v1alpha3.SourceTypeGithub,
+			GitHubSource: &v1alpha3.GithubSource{
+				ApiUri:       "https://github.example.com/api/v3",
+				Owner:        "owner",
+				Repo:         "repo",
+				CredentialId: "token",
+			},
+		},
+		wantInfo: repoInformation{owner: "owner", repo: "repo", tokenId: "token", provider: "github", server: "https://github.example.com"},
+	}, {
+		name: "self-managed GitLab",
+		repo: &v1alpha3.MultiBranchPipeline{
+			SourceType: v1alpha3.SourceTypeGitlab,
+			GitlabSource: &v1alpha3.GitlabSource{
+				ServerName:   "https://gitlab.example.com",
+				Owner:        "owner",
+				Repo:         "owner/repo",
+				CredentialId: "token",
+			},
+		},
+		wantInfo: repoInformation{owner: "owner", repo: "repo", tokenId: "token", provider: "gitlab", server: "https://gitlab.example.com"},
+	}, {
+		name: "Bitbucket Server",
+		repo: &v1alpha3.MultiBranchPipeline{
+			SourceType: v1alpha3.SourceTypeBitbucket,
+			BitbucketServerSource: &v1alpha3.BitbucketServerSource{
+				ApiUri:       "https://bitbucket.example.com",
+				Owner:        "owner",
+				Repo:         "repo",
+				CredentialId: "token",
+			},
+		},
+		wantInfo: repoInformation{owner: "owner", repo: "repo", tokenId: "token", provider: "bitbucketserver", server: "https://bitbucket.example.com"},
 	}}
 	for i, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
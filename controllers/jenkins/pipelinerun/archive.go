@@ -0,0 +1,108 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinerun
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"k8s.io/klog/v2"
+	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
+	"kubesphere.io/devops/pkg/models/pipelinerun"
+	s3store "kubesphere.io/devops/pkg/store/s3"
+	storeInter "kubesphere.io/devops/pkg/store/store"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// archive keeps the stages and logs of a completed PipelineRun in the S3 data store.
+// It only happens once, the PipelineRun will be annotated after archiving.
+func (r *Reconciler) archive(ctx context.Context, pr *v1alpha3.PipelineRun) (err error) {
+	if r.PipelineRunDataStore != pipelinerun.DataStoreS3 || !pr.HasStarted() || !pr.HasCompleted() ||
+		pr.Annotations[v1alpha3.PipelineRunArchivedAnnoKey] == "true" ||
+		pr.Spec.PipelineRef == nil || pr.Spec.PipelineRef.Name == "" {
+		return
+	}
+
+	var engine Engine
+	if engine, err = r.getEngine(pr.GetEngineType(), pr); err == nil {
+		err = r.archiveWithEngine(ctx, engine, pr)
+	}
+	return
+}
+
+func (r *Reconciler) archiveWithEngine(ctx context.Context, engine Engine, pr *v1alpha3.PipelineRun) (err error) {
+	var store storeInter.S3Store
+	if store, err = s3store.NewS3Store(client.ObjectKeyFromObject(pr), r.S3Client); err != nil {
+		return
+	}
+
+	var log string
+	if log, err = engine.GetLog(ctx, pr); err == nil {
+		store.SetAllLog(log)
+	} else if !errors.Is(err, ErrActionNotSupported) {
+		return fmt.Errorf("failed to get the log of PipelineRun: %s/%s, error: %v", pr.Namespace, pr.Name, err)
+	}
+
+	pipeline := &v1alpha3.Pipeline{}
+	pipeline.Namespace = pr.Namespace
+	pipeline.Name = pr.Spec.PipelineRef.Name
+	var nodes []pipelinerun.NodeDetail
+	if nodes, err = engine.GetNodeDetails(ctx, pipeline, pr); err != nil {
+		return fmt.Errorf("failed to get the stages of PipelineRun: %s/%s, error: %v", pr.Namespace, pr.Name, err)
+	}
+	for i, node := range nodes {
+		for j, step := range node.Steps {
+			if log, err = engine.GetStepLog(ctx, pr, node.ID, step.ID); err == nil {
+				store.SetStepLog(i, j, log)
+			} else if !errors.Is(err, ErrActionNotSupported) {
+				return fmt.Errorf("failed to get the log of step %s/%s, error: %v", node.ID, step.ID, err)
+			}
+		}
+	}
+
+	var stages []byte
+	if stages, err = json.Marshal(nodes); err != nil {
+		return
+	}
+	store.SetStages(string(stages))
+	if err = store.Save(); err != nil {
+		return
+	}
+
+	if pr.Annotations == nil {
+		pr.Annotations = map[string]string{}
+	}
+	pr.Annotations[v1alpha3.PipelineRunArchivedAnnoKey] = "true"
+	return r.updateLabelsAndAnnotations(ctx, pr)
+}
+
+// deleteArchive deletes the archived data of a PipelineRun, it's best effort
+func (r *Reconciler) deleteArchive(pr *v1alpha3.PipelineRun) {
+	if r.PipelineRunDataStore != pipelinerun.DataStoreS3 {
+		return
+	}
+
+	store, err := s3store.NewS3Store(client.ObjectKeyFromObject(pr), r.S3Client)
+	if err == nil {
+		err = store.Delete()
+	}
+	if err != nil {
+		klog.V(4).Infof("failed to delete the archived data of PipelineRun: %s/%s, error: %v", pr.Namespace, pr.Name, err)
+	}
+}
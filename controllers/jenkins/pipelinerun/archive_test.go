@@ -0,0 +1,156 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinerun
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jenkins-zh/jenkins-client/pkg/job"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
+	"kubesphere.io/devops/pkg/client/s3/fake"
+	"kubesphere.io/devops/pkg/models/pipelinerun"
+	s3store "kubesphere.io/devops/pkg/store/s3"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestReconciler_archive(t *testing.T) {
+	schema, err := v1alpha3.SchemeBuilder.Register().Build()
+	assert.Nil(t, err)
+
+	newPipelineRun := func(started, completed bool) *v1alpha3.PipelineRun {
+		pr := &v1alpha3.PipelineRun{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "pr", Annotations: map[string]string{}},
+			Spec:       v1alpha3.PipelineRunSpec{PipelineRef: &v1.ObjectReference{Name: "pipeline"}},
+		}
+		if started {
+			pr.Annotations[v1alpha3.JenkinsPipelineRunIDAnnoKey] = "1"
+		}
+		if completed {
+			now := metav1.Now()
+			pr.Status.CompletionTime = &now
+		}
+		return pr
+	}
+	archivedPipelineRun := newPipelineRun(true, true)
+	archivedPipelineRun.Annotations[v1alpha3.PipelineRunArchivedAnnoKey] = "true"
+
+	nodes := []pipelinerun.NodeDetail{{
+		Node:  job.Node{ID: "1"},
+		Steps: []pipelinerun.Step{{Step: job.Step{ID: "2"}}, {Step: job.Step{ID: "3"}}},
+	}}
+
+	tests := []struct {
+		name         string
+		store        string
+		pr           *v1alpha3.PipelineRun
+		engineErr    error
+		wantErr      bool
+		wantArchived bool
+		verify       func(t *testing.T, fakeS3 *fake.FakeS3)
+	}{{
+		name:  "not a s3 store",
+		store: "configmap",
+		pr:    newPipelineRun(true, true),
+	}, {
+		name:  "not completed",
+		store: pipelinerun.DataStoreS3,
+		pr:    newPipelineRun(true, false),
+	}, {
+		name:         "archived already",
+		store:        pipelinerun.DataStoreS3,
+		pr:           archivedPipelineRun,
+		wantArchived: true,
+	}, {
+		name:         "archive the stages and logs",
+		store:        pipelinerun.DataStoreS3,
+		pr:           newPipelineRun(true, true),
+		wantArchived: true,
+		verify: func(t *testing.T, fakeS3 *fake.FakeS3) {
+			store, err := s3store.NewS3Store(client.ObjectKey{Namespace: "ns", Name: "pr"}, fakeS3)
+			assert.Nil(t, err)
+			assert.Equal(t, "log", store.GetAllLog())
+			assert.Equal(t, "log of 1-2", store.GetStepLog(0, 0))
+			assert.Equal(t, "log of 1-3", store.GetStepLog(0, 1))
+			assert.Contains(t, store.GetStages(), `"id":"1"`)
+		},
+	}, {
+		name:      "failed to get the logs",
+		store:     pipelinerun.DataStoreS3,
+		pr:        newPipelineRun(true, true),
+		engineErr: errors.New("fake"),
+		wantErr:   true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fakeS3 := fake.NewFakeS3()
+			r := &Reconciler{
+				Client:               fakeclient.NewClientBuilder().WithScheme(schema).WithObjects(tt.pr.DeepCopy()).Build(),
+				PipelineRunDataStore: tt.store,
+				S3Client:             fakeS3,
+			}
+			engine := &fakeEngine{nodes: nodes, err: tt.engineErr}
+			pr := tt.pr.DeepCopy()
+
+			if r.PipelineRunDataStore != pipelinerun.DataStoreS3 || !pr.HasCompleted() ||
+				pr.Annotations[v1alpha3.PipelineRunArchivedAnnoKey] == "true" {
+				// the engine is not needed at all
+				err = r.archive(context.Background(), pr)
+			} else {
+				err = r.archiveWithEngine(context.Background(), engine, pr)
+			}
+			if tt.wantErr {
+				assert.NotNil(t, err)
+			} else {
+				assert.Nil(t, err)
+			}
+
+			result := &v1alpha3.PipelineRun{}
+			assert.Nil(t, r.Get(context.Background(), client.ObjectKeyFromObject(tt.pr), result))
+			assert.Equal(t, tt.wantArchived, result.Annotations[v1alpha3.PipelineRunArchivedAnnoKey] == "true")
+			if tt.verify != nil {
+				tt.verify(t, fakeS3)
+			}
+		})
+	}
+}
+
+func TestReconciler_deleteArchive(t *testing.T) {
+	fakeS3 := fake.NewFakeS3()
+	key := client.ObjectKey{Namespace: "ns", Name: "pr"}
+	store, err := s3store.NewS3Store(key, fakeS3)
+	assert.Nil(t, err)
+	store.SetAllLog("log")
+	assert.Nil(t, store.Save())
+	assert.NotEmpty(t, fakeS3.Storage)
+
+	pr := &v1alpha3.PipelineRun{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "pr"}}
+	// nothing happens if it's not a s3 store
+	(&Reconciler{S3Client: fakeS3}).deleteArchive(pr)
+	assert.NotEmpty(t, fakeS3.Storage)
+
+	(&Reconciler{PipelineRunDataStore: pipelinerun.DataStoreS3, S3Client: fakeS3}).deleteArchive(pr)
+	assert.Empty(t, fakeS3.Storage)
+
+	// it's best effort without a S3 client
+	(&Reconciler{PipelineRunDataStore: pipelinerun.DataStoreS3}).deleteArchive(pr)
+}
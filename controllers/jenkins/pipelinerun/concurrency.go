@@ -0,0 +1,333 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinerun
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// admissionResult is the result of admitting a PipelineRun
+type admissionResult int
+
+const (
+	// admitted indicates that the PipelineRun is able to run
+	admitted admissionResult = iota
+	// queued indicates that the PipelineRun has to wait in the queue
+	queued
+	// forbidden indicates that the PipelineRun should be skipped due to the concurrency policy Forbid
+	forbidden
+)
+
+// concurrencyLimiter admits the PipelineRuns of a namespace in the order of their creation, according to the
+// concurrency policies of the Pipelines and the max parallel of the DevOpsProject.
+type concurrencyLimiter struct {
+	// pipelines are the Pipelines of the namespace by name
+	pipelines map[string]*v1alpha3.Pipeline
+	// projectMaxParallel is the max number of running PipelineRuns of the namespace, zero means no limit
+	projectMaxParallel int
+}
+
+// admit returns the result of the target PipelineRun, and its position if it's queued.
+// It simulates admitting all the waiting PipelineRuns one by one, so the earlier ones always run first.
+func (l *concurrencyLimiter) admit(prs []v1alpha3.PipelineRun, target *v1alpha3.PipelineRun) (result admissionResult, position int) {
+	running := map[string]int{}
+	var projectRunning int
+	waiting := []*v1alpha3.PipelineRun{target}
+	for i := range prs {
+		pr := &prs[i]
+		if pr.Name == target.Name || pr.HasCompleted() || !pr.DeletionTimestamp.IsZero() {
+			continue
+		}
+		if pr.HasStarted() {
+			running[l.getScope(pr)]++
+			projectRunning++
+		} else if !pr.IsPaused() && pr.GetAction() != v1alpha3.Stop {
+			waiting = append(waiting, pr)
+		}
+	}
+	sort.SliceStable(waiting, func(i, j int) bool {
+		return isEarlier(waiting[i], waiting[j])
+	})
+
+	pipelineQueued := map[string]int{}
+	var projectQueued int
+	for _, pr := range waiting {
+		scope := l.getScope(pr)
+		pipeline := l.pipelines[getPipelineRefName(pr)]
+		if maxParallel := pipeline.GetMaxParallel(); maxParallel > 0 && running[scope] >= maxParallel {
+			switch pipeline.GetConcurrencyPolicy() {
+			case v1alpha3.ForbidConcurrent:
+				if pr == target {
+					return forbidden, 0
+				}
+				continue
+			case v1alpha3.QueueConcurrent:
+				pipelineQueued[scope]++
+				if pr == target {
+					return queued, pipelineQueued[scope]
+				}
+				continue
+			}
+			// the older ones will be replaced
+		}
+		if l.projectMaxParallel > 0 && projectRunning >= l.projectMaxParallel {
+			projectQueued++
+			if pr == target {
+				return queued, projectQueued
+			}
+			continue
+		}
+		if pr == target {
+			return admitted, 0
+		}
+		running[scope]++
+		projectRunning++
+	}
+	return admitted, 0
+}
+
+// getScope returns the scope of the concurrency policy of a PipelineRun.
+// The PipelineRuns of different branches of a multi-branch Pipeline don't affect each other.
+func (l *concurrencyLimiter) getScope(pr *v1alpha3.PipelineRun) string {
+	scope := getPipelineRefName(pr)
+	if pipeline := l.pipelines[scope]; pipeline.IsMultiBranch() && pr.Spec.SCM != nil {
+		scope = scope + "/" + pr.Spec.SCM.RefName
+	}
+	return scope
+}
+
+// getSuperseded returns the older PipelineRuns which are in the same scope of the target one.
+// The newest ones are kept running with the target one if the max parallel of the Pipeline allows.
+func (l *concurrencyLimiter) getSuperseded(prs []v1alpha3.PipelineRun, target *v1alpha3.PipelineRun) (superseded []*v1alpha3.PipelineRun) {
+	scope := l.getScope(target)
+	for i := range prs {
+		pr := &prs[i]
+		if pr.Name == target.Name || pr.HasCompleted() || !pr.DeletionTimestamp.IsZero() ||
+			pr.GetAction() == v1alpha3.Stop || l.getScope(pr) != scope {
+			continue
+		}
+		if pr.HasStarted() || isEarlier(pr, target) {
+			superseded = append(superseded, pr)
+		}
+	}
+	sort.SliceStable(superseded, func(i, j int) bool {
+		return isEarlier(superseded[i], superseded[j])
+	})
+	if keep := l.pipelines[getPipelineRefName(target)].GetMaxParallel() - 1; keep >= len(superseded) {
+		superseded = nil
+	} else if keep > 0 {
+		superseded = superseded[:len(superseded)-keep]
+	}
+	return
+}
+
+// isEarlier returns true if the PipelineRun was created before the other one
+func isEarlier(pr, other *v1alpha3.PipelineRun) bool {
+	if !pr.CreationTimestamp.Equal(&other.CreationTimestamp) {
+		return pr.CreationTimestamp.Before(&other.CreationTimestamp)
+	}
+	return pr.Name < other.Name
+}
+
+func getPipelineRefName(pr *v1alpha3.PipelineRun) (name string) {
+	if pr.Spec.PipelineRef != nil {
+		name = pr.Spec.PipelineRef.Name
+	}
+	return
+}
+
+// admit holds a PipelineRun which has not started in the queue until the concurrency limits allow it to run.
+// The PipelineRun is skipped if it's forbidden, and the older ones are stopped if they should be replaced.
+func (r *Reconciler) admit(ctx context.Context, pipeline *v1alpha3.Pipeline, pr *v1alpha3.PipelineRun) (ok bool, err error) {
+	limiter := &concurrencyLimiter{pipelines: map[string]*v1alpha3.Pipeline{pipeline.Name: pipeline}}
+	if limiter.projectMaxParallel, err = r.getProjectMaxParallel(ctx, pr.Namespace); err != nil {
+		return
+	}
+	if limiter.projectMaxParallel <= 0 && pipeline.GetMaxParallel() <= 0 {
+		// no limits at all
+		ok = true
+		dequeue(pr)
+		return
+	}
+
+	prs := &v1alpha3.PipelineRunList{}
+	if err = r.List(ctx, prs, client.InNamespace(pr.Namespace)); err != nil {
+		return
+	}
+	if limiter.projectMaxParallel > 0 {
+		// the policies of other Pipelines affect the queue of the project
+		pipelines := &v1alpha3.PipelineList{}
+		if err = r.List(ctx, pipelines, client.InNamespace(pr.Namespace)); err != nil {
+			return
+		}
+		for i := range pipelines.Items {
+			limiter.pipelines[pipelines.Items[i].Name] = &pipelines.Items[i]
+		}
+	}
+
+	result, position := limiter.admit(prs.Items, pr)
+	switch result {
+	case forbidden:
+		err = r.skipPipelineRun(ctx, pr)
+	case queued:
+		err = r.enqueuePipelineRun(ctx, pr, position)
+	case admitted:
+		ok = true
+		if pipeline.GetConcurrencyPolicy() == v1alpha3.ReplaceConcurrent {
+			err = r.supersede(ctx, limiter.getSuperseded(prs.Items, pr), pr)
+		}
+		dequeue(pr)
+	}
+	return
+}
+
+// dequeue resets the phase of a Queued PipelineRun, the status will be updated once it was triggered
+func dequeue(pr *v1alpha3.PipelineRun) {
+	if pr.Status.Phase == v1alpha3.Queued {
+		pr.Status.Phase = v1alpha3.Pending
+		pr.Status.QueuePosition = 0
+	}
+}
+
+// getProjectMaxParallel returns the max number of running PipelineRuns of the DevOpsProject, zero means no limit
+func (r *Reconciler) getProjectMaxParallel(ctx context.Context, namespace string) (maxParallel int, err error) {
+	var project *v1alpha3.DevOpsProject
+	if project, err = getDevOpsProject(ctx, r.Client, namespace); err != nil || project == nil {
+		return
+	}
+	if value := project.Annotations[v1alpha3.PipelineRunMaxParallelAnnoKey]; value != "" {
+		if maxParallel, err = strconv.Atoi(value); err != nil {
+			err = fmt.Errorf("invalid annotation %s of DevOpsProject %s: %v", v1alpha3.PipelineRunMaxParallelAnnoKey,
+				project.Name, err)
+		}
+	}
+	return
+}
+
+// enqueuePipelineRun marks the PipelineRun as Queued with its position
+func (r *Reconciler) enqueuePipelineRun(ctx context.Context, pr *v1alpha3.PipelineRun, position int) (err error) {
+	if pr.Status.Phase == v1alpha3.Queued && pr.Status.QueuePosition == position {
+		return
+	}
+	firstTime := pr.Status.Phase != v1alpha3.Queued
+	now := v1.Now()
+	pr.Status.Phase = v1alpha3.Queued
+	pr.Status.QueuePosition = position
+	pr.Status.UpdateTime = &now
+	if err = r.updateStatus(ctx, &pr.Status, client.ObjectKeyFromObject(pr)); err == nil && firstTime {
+		r.recorder.Eventf(pr, corev1.EventTypeNormal, v1alpha3.Enqueued,
+			"Queued PipelineRun %s/%s due to the concurrency limits, position: %d", pr.Namespace, pr.Name, position)
+	}
+	return
+}
+
+// skipPipelineRun marks the PipelineRun as cancelled without running it
+func (r *Reconciler) skipPipelineRun(ctx context.Context, pr *v1alpha3.PipelineRun) (err error) {
+	now := v1.Now()
+	pr.Status.Phase = v1alpha3.Cancelled
+	pr.Status.QueuePosition = 0
+	pr.Status.CompletionTime = &now
+	pr.Status.UpdateTime = &now
+	pr.Status.AddCondition(&v1alpha3.Condition{
+		Type:               v1alpha3.ConditionSucceeded,
+		Status:             v1alpha3.ConditionFalse,
+		Reason:             v1alpha3.ConcurrencyForbidden,
+		Message:            "the PipelineRun was skipped because another one is running, and the concurrency policy is Forbid",
+		LastProbeTime:      now,
+		LastTransitionTime: now,
+	})
+	if err = r.updateStatus(ctx, &pr.Status, client.ObjectKeyFromObject(pr)); err == nil {
+		r.recorder.Eventf(pr, corev1.EventTypeNormal, v1alpha3.ConcurrencyForbidden,
+			"Skipped PipelineRun %s/%s due to the concurrency policy Forbid", pr.Namespace, pr.Name)
+	}
+	return
+}
+
+// supersede stops the older PipelineRuns via the Stop action
+func (r *Reconciler) supersede(ctx context.Context, superseded []*v1alpha3.PipelineRun, pr *v1alpha3.PipelineRun) error {
+	for _, older := range superseded {
+		if err := stopPipelineRun(ctx, r.Client, older); err != nil {
+			return err
+		}
+		r.recorder.Eventf(older, corev1.EventTypeNormal, v1alpha3.Superseded, "Superseded by PipelineRun %s/%s",
+			pr.Namespace, pr.Name)
+	}
+	return nil
+}
+
+// stopPipelineRun sets the Stop action of a PipelineRun unless it has completed
+func stopPipelineRun(ctx context.Context, c client.Client, pr *v1alpha3.PipelineRun) error {
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		latest := &v1alpha3.PipelineRun{}
+		if err := c.Get(ctx, client.ObjectKeyFromObject(pr), latest); err != nil {
+			return err
+		}
+		if latest.HasCompleted() || latest.GetAction() == v1alpha3.Stop {
+			return nil
+		}
+		action := v1alpha3.Stop
+		latest.Spec.Action = &action
+		return c.Update(ctx, latest)
+	})
+	return client.IgnoreNotFound(err)
+}
+
+// getQueuedPipelineRuns returns the Queued PipelineRuns of the same namespace, they might be able to run
+// once a PipelineRun completed or was deleted
+func (r *Reconciler) getQueuedPipelineRuns(obj client.Object) (requests []reconcile.Request) {
+	prs := &v1alpha3.PipelineRunList{}
+	if err := r.List(context.Background(), prs, client.InNamespace(obj.GetNamespace())); err != nil {
+		r.log.Error(err, "unable to list the queued PipelineRuns", "namespace", obj.GetNamespace())
+		return
+	}
+	for i := range prs.Items {
+		if prs.Items[i].Status.Phase == v1alpha3.Queued && !prs.Items[i].HasCompleted() {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&prs.Items[i])})
+		}
+	}
+	return
+}
+
+// pipelineRunCompletedPredicate only allows the events of a PipelineRun which completed or was deleted
+var pipelineRunCompletedPredicate = predicate.Funcs{
+	CreateFunc: func(event.CreateEvent) bool {
+		return false
+	},
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		oldPR, oldOK := e.ObjectOld.(*v1alpha3.PipelineRun)
+		newPR, newOK := e.ObjectNew.(*v1alpha3.PipelineRun)
+		return oldOK && newOK && !oldPR.HasCompleted() && newPR.HasCompleted()
+	},
+	DeleteFunc: func(event.DeleteEvent) bool {
+		return true
+	},
+	GenericFunc: func(event.GenericEvent) bool {
+		return false
+	},
+}
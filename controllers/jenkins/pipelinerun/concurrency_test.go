@@ -0,0 +1,368 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinerun
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
+	"kubesphere.io/devops/pkg/constants"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+func newConcurrencyPipeline(name string, policy v1alpha3.ConcurrencyPolicyType, maxParallel int) *v1alpha3.Pipeline {
+	pipeline := &v1alpha3.Pipeline{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: name}}
+	if policy != "" {
+		pipeline.Spec.Concurrency = &v1alpha3.ConcurrencyPolicy{Policy: policy, MaxParallel: maxParallel}
+	}
+	return pipeline
+}
+
+// newConcurrencyPipelineRun returns a PipelineRun which was created some minutes ago
+func newConcurrencyPipelineRun(pipeline, name string, minutesAgo int, running bool) *v1alpha3.PipelineRun {
+	pr := &v1alpha3.PipelineRun{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:         "ns",
+			Name:              name,
+			CreationTimestamp: metav1.NewTime(time.Now().Add(-time.Duration(minutesAgo) * time.Minute).Truncate(time.Second)),
+			Annotations:       map[string]string{},
+		},
+		Spec: v1alpha3.PipelineRunSpec{PipelineRef: &v1.ObjectReference{Name: pipeline}},
+	}
+	if running {
+		pr.Annotations[v1alpha3.JenkinsPipelineRunIDAnnoKey] = "1"
+	}
+	return pr
+}
+
+func Test_concurrencyLimiter_admit(t *testing.T) {
+	completionTime := metav1.Now()
+	completed := newConcurrencyPipelineRun("queue", "completed", 10, true)
+	completed.Status.CompletionTime = &completionTime
+	stopped := newConcurrencyPipelineRun("queue", "stopped", 9, false)
+	stop := v1alpha3.Stop
+	stopped.Spec.Action = &stop
+
+	pipelines := map[string]*v1alpha3.Pipeline{
+		"allow":   newConcurrencyPipeline("allow", "", 0),
+		"forbid":  newConcurrencyPipeline("forbid", v1alpha3.ForbidConcurrent, 0),
+		"replace": newConcurrencyPipeline("replace", v1alpha3.ReplaceConcurrent, 0),
+		"queue":   newConcurrencyPipeline("queue", v1alpha3.QueueConcurrent, 2),
+	}
+	multiBranch := newConcurrencyPipeline("multi", v1alpha3.QueueConcurrent, 1)
+	multiBranch.Spec.Type = v1alpha3.MultiBranchPipelineType
+	pipelines["multi"] = multiBranch
+	newBranchRun := func(name, branch string, minutesAgo int, running bool) *v1alpha3.PipelineRun {
+		pr := newConcurrencyPipelineRun("multi", name, minutesAgo, running)
+		pr.Spec.SCM = &v1alpha3.SCM{RefName: branch}
+		return pr
+	}
+
+	tests := []struct {
+		name               string
+		projectMaxParallel int
+		prs                []*v1alpha3.PipelineRun
+		target             *v1alpha3.PipelineRun
+		wantResult         admissionResult
+		wantPosition       int
+	}{{
+		name:       "no limits",
+		prs:        []*v1alpha3.PipelineRun{newConcurrencyPipelineRun("allow", "a", 5, true)},
+		target:     newConcurrencyPipelineRun("allow", "b", 1, false),
+		wantResult: admitted,
+	}, {
+		name:       "forbid a concurrent one",
+		prs:        []*v1alpha3.PipelineRun{newConcurrencyPipelineRun("forbid", "a", 5, true)},
+		target:     newConcurrencyPipelineRun("forbid", "b", 1, false),
+		wantResult: forbidden,
+	}, {
+		name:       "forbid nothing if no one is running",
+		prs:        []*v1alpha3.PipelineRun{completed},
+		target:     newConcurrencyPipelineRun("forbid", "b", 1, false),
+		wantResult: admitted,
+	}, {
+		name:       "replace never waits",
+		prs:        []*v1alpha3.PipelineRun{newConcurrencyPipelineRun("replace", "a", 5, true)},
+		target:     newConcurrencyPipelineRun("replace", "b", 1, false),
+		wantResult: admitted,
+	}, {
+		name:       "queue below the max parallel",
+		prs:        []*v1alpha3.PipelineRun{newConcurrencyPipelineRun("queue", "a", 5, true), completed, stopped},
+		target:     newConcurrencyPipelineRun("queue", "b", 1, false),
+		wantResult: admitted,
+	}, {
+		name: "queue after the earlier ones",
+		prs: []*v1alpha3.PipelineRun{
+			newConcurrencyPipelineRun("queue", "a", 5, true),
+			newConcurrencyPipelineRun("queue", "b", 4, false),
+			newConcurrencyPipelineRun("queue", "c", 3, false),
+			newConcurrencyPipelineRun("queue", "e", 1, false),
+		},
+		target:       newConcurrencyPipelineRun("queue", "d", 2, false),
+		wantResult:   queued,
+		wantPosition: 2,
+	}, {
+		name: "the earliest one in the queue runs first",
+		prs: []*v1alpha3.PipelineRun{
+			newConcurrencyPipelineRun("queue", "a", 5, true),
+			newConcurrencyPipelineRun("queue", "c", 3, false),
+		},
+		target:     newConcurrencyPipelineRun("queue", "b", 4, false),
+		wantResult: admitted,
+	}, {
+		name: "branches are queued separately",
+		prs: []*v1alpha3.PipelineRun{
+			newBranchRun("a", "master", 5, true),
+			newBranchRun("b", "master", 4, false),
+		},
+		target:     newBranchRun("c", "dev", 3, false),
+		wantResult: admitted,
+	}, {
+		name: "queue in the same branch",
+		prs: []*v1alpha3.PipelineRun{
+			newBranchRun("a", "master", 5, true),
+			newBranchRun("b", "dev", 4, false),
+		},
+		target:       newBranchRun("c", "master", 3, false),
+		wantResult:   queued,
+		wantPosition: 1,
+	}, {
+		name:               "reached the max parallel of the project",
+		projectMaxParallel: 2,
+		prs: []*v1alpha3.PipelineRun{
+			newConcurrencyPipelineRun("allow", "a", 5, true),
+			newConcurrencyPipelineRun("replace", "b", 4, true),
+			newConcurrencyPipelineRun("allow", "c", 3, false),
+		},
+		target:       newConcurrencyPipelineRun("unknown", "d", 2, false),
+		wantResult:   queued,
+		wantPosition: 2,
+	}, {
+		name:               "the ones waiting for the Pipeline don't take the place of the project",
+		projectMaxParallel: 2,
+		prs: []*v1alpha3.PipelineRun{
+			newConcurrencyPipelineRun("queue", "a", 5, true),
+			newConcurrencyPipelineRun("queue", "b", 4, true),
+			newConcurrencyPipelineRun("queue", "c", 3, false),
+			newConcurrencyPipelineRun("allow", "d", 2, false),
+		},
+		target:       newConcurrencyPipelineRun("allow", "e", 1, false),
+		wantResult:   queued,
+		wantPosition: 2,
+	}, {
+		name:               "below the max parallel of the project",
+		projectMaxParallel: 3,
+		prs: []*v1alpha3.PipelineRun{
+			newConcurrencyPipelineRun("queue", "a", 5, true),
+			newConcurrencyPipelineRun("queue", "b", 4, true),
+			newConcurrencyPipelineRun("queue", "c", 3, false),
+		},
+		target:     newConcurrencyPipelineRun("allow", "d", 1, false),
+		wantResult: admitted,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter := &concurrencyLimiter{pipelines: pipelines, projectMaxParallel: tt.projectMaxParallel}
+			prs := []v1alpha3.PipelineRun{*tt.target.DeepCopy()}
+			for _, pr := range tt.prs {
+				prs = append(prs, *pr.DeepCopy())
+			}
+			result, position := limiter.admit(prs, tt.target)
+			assert.Equal(t, tt.wantResult, result)
+			assert.Equal(t, tt.wantPosition, position)
+		})
+	}
+}
+
+func TestReconciler_admit(t *testing.T) {
+	schema, err := v1alpha3.SchemeBuilder.Register().Build()
+	assert.Nil(t, err)
+	err = v1.SchemeBuilder.AddToScheme(schema)
+	assert.Nil(t, err)
+
+	ns := &v1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name:   "ns",
+		Labels: map[string]string{constants.DevOpsProjectLabelKey: "project"},
+	}}
+	newProject := func(maxParallel string) *v1alpha3.DevOpsProject {
+		return &v1alpha3.DevOpsProject{ObjectMeta: metav1.ObjectMeta{
+			Name:        "project",
+			Annotations: map[string]string{v1alpha3.PipelineRunMaxParallelAnnoKey: maxParallel},
+		}}
+	}
+	queuedRun := newConcurrencyPipelineRun("build", "b", 1, false)
+	queuedRun.Status.Phase = v1alpha3.Queued
+	queuedRun.Status.QueuePosition = 1
+
+	tests := []struct {
+		name          string
+		pipeline      *v1alpha3.Pipeline
+		objects       []client.Object
+		pr            *v1alpha3.PipelineRun
+		wantOK        bool
+		wantErr       bool
+		wantPhase     v1alpha3.RunPhase
+		wantPosition  int
+		wantCompleted bool
+		wantStopped   []string
+	}{{
+		name:     "no limits",
+		pipeline: newConcurrencyPipeline("build", "", 0),
+		objects:  []client.Object{newConcurrencyPipelineRun("build", "a", 5, true)},
+		pr:       newConcurrencyPipelineRun("build", "b", 1, false),
+		wantOK:   true,
+	}, {
+		name:     "invalid max parallel of the project",
+		pipeline: newConcurrencyPipeline("build", "", 0),
+		objects:  []client.Object{ns, newProject("two")},
+		pr:       newConcurrencyPipelineRun("build", "b", 1, false),
+		wantErr:  true,
+	}, {
+		name:         "queued",
+		pipeline:     newConcurrencyPipeline("build", v1alpha3.QueueConcurrent, 1),
+		objects:      []client.Object{newConcurrencyPipelineRun("build", "a", 5, true)},
+		pr:           newConcurrencyPipelineRun("build", "b", 1, false),
+		wantPhase:    v1alpha3.Queued,
+		wantPosition: 1,
+	}, {
+		name:         "queued by the project",
+		pipeline:     newConcurrencyPipeline("build", "", 0),
+		objects:      []client.Object{ns, newProject("1"), newConcurrencyPipelineRun("other", "a", 5, true)},
+		pr:           newConcurrencyPipelineRun("build", "b", 1, false),
+		wantPhase:    v1alpha3.Queued,
+		wantPosition: 1,
+	}, {
+		name:      "dequeued",
+		pipeline:  newConcurrencyPipeline("build", v1alpha3.QueueConcurrent, 1),
+		pr:        queuedRun,
+		wantOK:    true,
+		wantPhase: v1alpha3.Pending,
+	}, {
+		name:          "forbidden",
+		pipeline:      newConcurrencyPipeline("build", v1alpha3.ForbidConcurrent, 0),
+		objects:       []client.Object{newConcurrencyPipelineRun("build", "a", 5, true)},
+		pr:            newConcurrencyPipelineRun("build", "b", 1, false),
+		wantPhase:     v1alpha3.Cancelled,
+		wantCompleted: true,
+	}, {
+		name:     "replace the older ones",
+		pipeline: newConcurrencyPipeline("build", v1alpha3.ReplaceConcurrent, 0),
+		objects: []client.Object{
+			newConcurrencyPipelineRun("build", "a", 5, true),
+			newConcurrencyPipelineRun("build", "b", 4, false),
+			newConcurrencyPipelineRun("build", "d", 1, false),
+			newConcurrencyPipelineRun("other", "e", 3, true),
+		},
+		pr:          newConcurrencyPipelineRun("build", "c", 2, false),
+		wantOK:      true,
+		wantStopped: []string{"a", "b"},
+	}, {
+		name:     "replace the oldest ones beyond the max parallel",
+		pipeline: newConcurrencyPipeline("build", v1alpha3.ReplaceConcurrent, 2),
+		objects: []client.Object{
+			newConcurrencyPipelineRun("build", "a", 5, true),
+			newConcurrencyPipelineRun("build", "b", 4, true),
+		},
+		pr:          newConcurrencyPipelineRun("build", "c", 2, false),
+		wantOK:      true,
+		wantStopped: []string{"a"},
+	}, {
+		name:     "replace nothing below the max parallel",
+		pipeline: newConcurrencyPipeline("build", v1alpha3.ReplaceConcurrent, 2),
+		objects:  []client.Object{newConcurrencyPipelineRun("build", "a", 5, true)},
+		pr:       newConcurrencyPipelineRun("build", "c", 2, false),
+		wantOK:   true,
+	}, {
+		name:     "forbid nothing below the max parallel",
+		pipeline: newConcurrencyPipeline("build", v1alpha3.ForbidConcurrent, 2),
+		objects:  []client.Object{newConcurrencyPipelineRun("build", "a", 5, true)},
+		pr:       newConcurrencyPipelineRun("build", "b", 1, false),
+		wantOK:   true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objects := append([]client.Object{tt.pr.DeepCopy()}, tt.objects...)
+			r := &Reconciler{
+				Client:   fake.NewClientBuilder().WithScheme(schema).WithObjects(objects...).Build(),
+				recorder: record.NewFakeRecorder(10),
+			}
+			pr := tt.pr.DeepCopy()
+			ok, err := r.admit(context.Background(), tt.pipeline, pr)
+			assert.Equal(t, tt.wantErr, err != nil, err)
+			assert.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.wantPhase, pr.Status.Phase)
+			assert.Equal(t, tt.wantPosition, pr.Status.QueuePosition)
+
+			latest := &v1alpha3.PipelineRun{}
+			assert.Nil(t, r.Get(context.Background(), client.ObjectKeyFromObject(pr), latest))
+			assert.Equal(t, tt.wantCompleted, latest.HasCompleted())
+			if !ok && !tt.wantErr {
+				// the status of the admitted one is updated when it's triggered
+				assert.Equal(t, tt.wantPhase, latest.Status.Phase)
+				assert.Equal(t, tt.wantPosition, latest.Status.QueuePosition)
+			}
+
+			prs := &v1alpha3.PipelineRunList{}
+			assert.Nil(t, r.List(context.Background(), prs))
+			var stopped []string
+			for i := range prs.Items {
+				if prs.Items[i].GetAction() == v1alpha3.Stop {
+					stopped = append(stopped, prs.Items[i].Name)
+				}
+			}
+			assert.ElementsMatch(t, tt.wantStopped, stopped)
+		})
+	}
+}
+
+func TestReconciler_getQueuedPipelineRuns(t *testing.T) {
+	schema, err := v1alpha3.SchemeBuilder.Register().Build()
+	assert.Nil(t, err)
+
+	queuedRun := newConcurrencyPipelineRun("build", "b", 1, false)
+	queuedRun.Status.Phase = v1alpha3.Queued
+	otherNamespace := queuedRun.DeepCopy()
+	otherNamespace.Namespace = "other"
+	r := &Reconciler{Client: fake.NewClientBuilder().WithScheme(schema).WithObjects(
+		newConcurrencyPipelineRun("build", "a", 5, true), queuedRun, otherNamespace).Build()}
+
+	requests := r.getQueuedPipelineRuns(newConcurrencyPipelineRun("build", "a", 5, true))
+	if assert.Equal(t, 1, len(requests)) {
+		assert.Equal(t, client.ObjectKeyFromObject(queuedRun), requests[0].NamespacedName)
+	}
+}
+
+func Test_pipelineRunCompletedPredicate(t *testing.T) {
+	running := newConcurrencyPipelineRun("build", "a", 5, true)
+	completed := running.DeepCopy()
+	completionTime := metav1.Now()
+	completed.Status.CompletionTime = &completionTime
+
+	assert.False(t, pipelineRunCompletedPredicate.Create(event.CreateEvent{Object: running}))
+	assert.False(t, pipelineRunCompletedPredicate.Update(event.UpdateEvent{ObjectOld: running, ObjectNew: running}))
+	assert.False(t, pipelineRunCompletedPredicate.Update(event.UpdateEvent{ObjectOld: completed, ObjectNew: completed}))
+	assert.True(t, pipelineRunCompletedPredicate.Update(event.UpdateEvent{ObjectOld: running, ObjectNew: completed}))
+	assert.True(t, pipelineRunCompletedPredicate.Delete(event.DeleteEvent{Object: running}))
+}
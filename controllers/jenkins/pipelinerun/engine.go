@@ -0,0 +1,132 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinerun
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jenkins-zh/jenkins-client/pkg/job"
+	corev1 "k8s.io/api/core/v1"
+	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
+	"kubesphere.io/devops/pkg/constants"
+	"kubesphere.io/devops/pkg/models/pipelinerun"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Engine is the execution backend of PipelineRuns.
+// All engines report the run data in the format of Jenkins BlueOcean, so that
+// the status, annotations and stage data of PipelineRuns keep the same for all engines.
+type Engine interface {
+	// Trigger starts a new run of the Pipeline for the PipelineRun
+	Trigger(ctx context.Context, pipeline *v1alpha3.Pipeline, pr *v1alpha3.PipelineRun) (*job.PipelineRun, error)
+	// GetRunResult returns the current result of a started PipelineRun
+	GetRunResult(ctx context.Context, pipeline *v1alpha3.Pipeline, pr *v1alpha3.PipelineRun) (*job.PipelineRun, error)
+	// GetNodeDetails returns the stages and steps of a started PipelineRun
+	GetNodeDetails(ctx context.Context, pipeline *v1alpha3.Pipeline, pr *v1alpha3.PipelineRun) ([]pipelinerun.NodeDetail, error)
+	// Stop aborts a running PipelineRun
+	Stop(ctx context.Context, pr *v1alpha3.PipelineRun) error
+	// Pause holds a running PipelineRun, ErrActionNotSupported is returned if the engine cannot do it.
+	// Pausing a paused PipelineRun does nothing, because the action is retried if the status update fails.
+	Pause(ctx context.Context, pr *v1alpha3.PipelineRun) error
+	// Resume releases a paused PipelineRun, ErrActionNotSupported is returned if the engine cannot do it.
+	// Resuming a running PipelineRun does nothing.
+	Resume(ctx context.Context, pr *v1alpha3.PipelineRun) error
+	// DeleteHistory deletes all the data of a PipelineRun kept by the engine
+	DeleteHistory(ctx context.Context, pr *v1alpha3.PipelineRun) error
+	// GetLog returns the whole log of a started PipelineRun, ErrActionNotSupported is returned if the engine cannot do it
+	GetLog(ctx context.Context, pr *v1alpha3.PipelineRun) (string, error)
+	// GetStepLog returns the log of a step, the node and step IDs come from GetNodeDetails.
+	// ErrActionNotSupported is returned if the engine cannot do it.
+	GetStepLog(ctx context.Context, pr *v1alpha3.PipelineRun, nodeID, stepID string) (string, error)
+	// Restart starts a new run for the PipelineRun from a stage of the completed parent PipelineRun.
+	// ErrActionNotSupported is returned if the engine cannot do it.
+	Restart(ctx context.Context, pipeline *v1alpha3.Pipeline, pr, parent *v1alpha3.PipelineRun, stage string) (*job.PipelineRun, error)
+}
+
+// ErrActionNotSupported indicates that the engine is not able to handle the action of a PipelineRun
+var ErrActionNotSupported = errors.New("the action is not supported by the engine")
+
+// resolveEngineType finds out the engine type of a PipelineRun.
+// The PipelineRun annotation takes precedence over the Pipeline, and the Pipeline takes
+// precedence over the DevOpsProject. The Pipeline could be nil, for instance, it has been deleted.
+func resolveEngineType(ctx context.Context, c client.Reader, pipeline *v1alpha3.Pipeline, pr *v1alpha3.PipelineRun) (
+	engineType v1alpha3.EngineType, err error) {
+	if engineType = pr.GetEngineType(); engineType != "" {
+		return
+	}
+	engineType = v1alpha3.JenkinsEngine
+	if pipeline == nil {
+		return
+	}
+	if value := pipeline.Annotations[v1alpha3.PipelineRunEngineAnnoKey]; value != "" {
+		engineType = v1alpha3.EngineType(value)
+		return
+	}
+
+	var project *v1alpha3.DevOpsProject
+	if project, err = getDevOpsProject(ctx, c, pipeline.Namespace); err != nil || project == nil {
+		return
+	}
+	if value := project.Annotations[v1alpha3.PipelineRunEngineAnnoKey]; value != "" {
+		engineType = v1alpha3.EngineType(value)
+	}
+	return
+}
+
+// getDevOpsProject returns the DevOpsProject of a namespace, it's nil if not found
+func getDevOpsProject(ctx context.Context, c client.Reader, namespace string) (project *v1alpha3.DevOpsProject, err error) {
+	ns := &corev1.Namespace{}
+	if err = c.Get(ctx, client.ObjectKey{Name: namespace}, ns); err != nil {
+		err = client.IgnoreNotFound(err)
+		return
+	}
+	projectName := ns.Labels[constants.DevOpsProjectLabelKey]
+	if projectName == "" {
+		return
+	}
+	project = &v1alpha3.DevOpsProject{}
+	if err = c.Get(ctx, client.ObjectKey{Name: projectName}, project); err != nil {
+		project = nil
+		err = client.IgnoreNotFound(err)
+	}
+	return
+}
+
+// getEngine returns the engine which is able to handle the PipelineRun
+func (r *Reconciler) getEngine(engineType v1alpha3.EngineType, pr *v1alpha3.PipelineRun) (engine Engine, err error) {
+	switch engineType {
+	case v1alpha3.JenkinsEngine, "":
+		var jenkinsCore = &r.JenkinsCore
+		if !pr.HasStarted() {
+			// the creator of the PipelineRun is the one who triggers it
+			if jenkinsCore, err = r.getOrCreateJenkinsCore(pr.GetAnnotations()); err != nil {
+				return
+			}
+		}
+		engine = &jenkinsHandler{jenkinsCore}
+	case v1alpha3.KubernetesEngine:
+		engine = &kubernetesEngine{
+			Client:       r.Client,
+			DefaultImage: r.RunnerImage,
+		}
+	default:
+		err = fmt.Errorf("unknown PipelineRun engine type: %s", engineType)
+	}
+	return
+}
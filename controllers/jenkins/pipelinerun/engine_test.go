@@ -0,0 +1,129 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinerun
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
+	"kubesphere.io/devops/pkg/constants"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func Test_resolveEngineType(t *testing.T) {
+	schema, err := v1alpha3.SchemeBuilder.Register().Build()
+	assert.Nil(t, err)
+	err = v1.SchemeBuilder.AddToScheme(schema)
+	assert.Nil(t, err)
+
+	ns := &v1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name:   "ns",
+		Labels: map[string]string{constants.DevOpsProjectLabelKey: "project"},
+	}}
+	project := &v1alpha3.DevOpsProject{ObjectMeta: metav1.ObjectMeta{
+		Name:        "project",
+		Annotations: map[string]string{v1alpha3.PipelineRunEngineAnnoKey: "kubernetes"},
+	}}
+	pipeline := &v1alpha3.Pipeline{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "pipeline"}}
+	pipelineWithEngine := pipeline.DeepCopy()
+	pipelineWithEngine.Annotations = map[string]string{v1alpha3.PipelineRunEngineAnnoKey: "jenkins"}
+	pr := &v1alpha3.PipelineRun{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "run"}}
+	prWithEngine := pr.DeepCopy()
+	prWithEngine.Annotations = map[string]string{v1alpha3.PipelineRunEngineAnnoKey: "kubernetes"}
+
+	tests := []struct {
+		name     string
+		objects  []client.Object
+		pipeline *v1alpha3.Pipeline
+		pr       *v1alpha3.PipelineRun
+		want     v1alpha3.EngineType
+	}{{
+		name:     "the PipelineRun has the engine annotation",
+		pipeline: pipelineWithEngine,
+		pr:       prWithEngine,
+		want:     v1alpha3.KubernetesEngine,
+	}, {
+		name: "the Pipeline is nil",
+		pr:   pr,
+		want: v1alpha3.JenkinsEngine,
+	}, {
+		name:     "the Pipeline has the engine annotation",
+		objects:  []client.Object{ns, project},
+		pipeline: pipelineWithEngine,
+		pr:       pr,
+		want:     v1alpha3.JenkinsEngine,
+	}, {
+		name:     "take the engine from the DevOpsProject",
+		objects:  []client.Object{ns, project},
+		pipeline: pipeline,
+		pr:       pr,
+		want:     v1alpha3.KubernetesEngine,
+	}, {
+		name:     "namespace not found",
+		pipeline: pipeline,
+		pr:       pr,
+		want:     v1alpha3.JenkinsEngine,
+	}, {
+		name:     "DevOpsProject not found",
+		objects:  []client.Object{ns},
+		pipeline: pipeline,
+		pr:       pr,
+		want:     v1alpha3.JenkinsEngine,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			builder := fake.NewClientBuilder().WithScheme(schema)
+			for _, obj := range tt.objects {
+				builder.WithObjects(obj.DeepCopyObject().(client.Object))
+			}
+			engineType, err := resolveEngineType(context.Background(), builder.Build(), tt.pipeline, tt.pr)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, engineType)
+		})
+	}
+}
+
+func TestReconciler_getEngine(t *testing.T) {
+	pr := &v1alpha3.PipelineRun{ObjectMeta: metav1.ObjectMeta{
+		Namespace:   "ns",
+		Name:        "run",
+		Annotations: map[string]string{v1alpha3.JenkinsPipelineRunIDAnnoKey: "1"},
+	}}
+	r := &Reconciler{RunnerImage: "busybox"}
+
+	engine, err := r.getEngine(v1alpha3.JenkinsEngine, pr)
+	assert.Nil(t, err)
+	assert.IsType(t, &jenkinsHandler{}, engine)
+
+	engine, err = r.getEngine("", pr)
+	assert.Nil(t, err)
+	assert.IsType(t, &jenkinsHandler{}, engine)
+
+	engine, err = r.getEngine(v1alpha3.KubernetesEngine, pr)
+	assert.Nil(t, err)
+	if assert.IsType(t, &kubernetesEngine{}, engine) {
+		assert.Equal(t, "busybox", engine.(*kubernetesEngine).DefaultImage)
+	}
+
+	_, err = r.getEngine("fake", pr)
+	assert.NotNil(t, err)
+}
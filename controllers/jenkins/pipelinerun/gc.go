@@ -0,0 +1,317 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinerun
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+// gcResyncPeriod is the interval of collecting the PipelineRuns of a Pipeline even if nothing changed,
+// then the changes of the DevOpsProject defaults take effect eventually
+const gcResyncPeriod = time.Hour
+
+// GCReconciler deletes the completed PipelineRuns of a Pipeline according to its discarder.
+// The discarder of a Pipeline takes precedence over the defaults of its DevOpsProject, and both of them
+// are limited by the global caps. The PipelineRuns of a multi-branch Pipeline are collected per branch.
+type GCReconciler struct {
+	client.Client
+	// MaxAge is the global cap of the time to keep a completed PipelineRun, zero means no cap
+	MaxAge time.Duration
+	// MaxCount is the global cap of the number of the completed PipelineRuns to keep, zero means no cap
+	MaxCount int
+
+	log      logr.Logger
+	recorder record.EventRecorder
+	now      func() time.Time
+}
+
+// gcPolicy describes how many and how long the completed PipelineRuns should be kept.
+// A zero value means unlimited.
+type gcPolicy struct {
+	maxAge   time.Duration
+	maxCount int
+}
+
+//+kubebuilder:rbac:groups=devops.kubesphere.io,resources=pipelines,verbs=get;list;watch
+//+kubebuilder:rbac:groups=devops.kubesphere.io,resources=devopsprojects,verbs=get
+//+kubebuilder:rbac:groups=devops.kubesphere.io,resources=pipelineruns,verbs=get;list;watch;delete
+//+kubebuilder:rbac:groups="",resources=configmaps,verbs=get;delete
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+// Reconcile collects the PipelineRuns of a Pipeline
+func (r *GCReconciler) Reconcile(ctx context.Context, req ctrl.Request) (result ctrl.Result, err error) {
+	log := r.log.WithValues("Pipeline", req.NamespacedName)
+
+	pipeline := &v1alpha3.Pipeline{}
+	if err = r.Get(ctx, req.NamespacedName, pipeline); err != nil {
+		err = client.IgnoreNotFound(err)
+		return
+	}
+	if !pipeline.DeletionTimestamp.IsZero() {
+		return
+	}
+
+	var policy gcPolicy
+	if policy, err = r.getPolicy(ctx, pipeline); err != nil {
+		log.Error(err, "unable to get the discarder policy")
+		return
+	}
+	if policy.maxAge <= 0 && policy.maxCount <= 0 {
+		return
+	}
+
+	pipelineRuns := &v1alpha3.PipelineRunList{}
+	if err = r.List(ctx, pipelineRuns, client.InNamespace(pipeline.Namespace),
+		client.MatchingLabels{v1alpha3.PipelineNameLabelKey: pipeline.Name}); err != nil {
+		return
+	}
+
+	discarded, nextExpiration := policy.discard(pipelineRuns.Items, r.getNow())
+	for _, item := range discarded {
+		if err = r.deletePipelineRun(ctx, pipeline, item.pipelineRun, item.reason); err != nil {
+			log.Error(err, "unable to delete PipelineRun", "PipelineRun", item.pipelineRun.Name)
+			return
+		}
+	}
+	log.V(4).Info("collected the PipelineRuns", "deleted", len(discarded))
+
+	result.RequeueAfter = gcResyncPeriod
+	if !nextExpiration.IsZero() {
+		if wait := nextExpiration.Sub(r.getNow()); wait < result.RequeueAfter {
+			// wait a bit more in case of the clock skew
+			result.RequeueAfter = wait + time.Second
+		}
+	}
+	return
+}
+
+// discardedPipelineRun is a PipelineRun which should be deleted, and why
+type discardedPipelineRun struct {
+	pipelineRun *v1alpha3.PipelineRun
+	reason      string
+}
+
+// discard returns the PipelineRuns which should be deleted, and the time when the next one expires.
+// The PipelineRuns which are running, pinned or being deleted are always kept, and not counted.
+func (p gcPolicy) discard(pipelineRuns []v1alpha3.PipelineRun, now time.Time) (
+	discarded []discardedPipelineRun, nextExpiration time.Time) {
+	branches := map[string][]*v1alpha3.PipelineRun{}
+	for i := range pipelineRuns {
+		pr := &pipelineRuns[i]
+		if !pr.HasCompleted() || pr.IsPinned() || !pr.DeletionTimestamp.IsZero() {
+			continue
+		}
+		refName := pr.GetRefName()
+		branches[refName] = append(branches[refName], pr)
+	}
+
+	refNames := make([]string, 0, len(branches))
+	for refName := range branches {
+		refNames = append(refNames, refName)
+	}
+	sort.Strings(refNames)
+
+	for _, refName := range refNames {
+		prs := branches[refName]
+		// the newest ones come first
+		sort.SliceStable(prs, func(i, j int) bool {
+			left, right := prs[i].Status.CompletionTime, prs[j].Status.CompletionTime
+			if left.Equal(right) {
+				return prs[i].Name > prs[j].Name
+			}
+			return right.Before(left)
+		})
+
+		for i, pr := range prs {
+			if p.maxCount > 0 && i >= p.maxCount {
+				discarded = append(discarded, discardedPipelineRun{pipelineRun: pr,
+					reason: fmt.Sprintf("exceeded the number of PipelineRuns to keep: %d", p.maxCount)})
+				continue
+			}
+			if p.maxAge <= 0 {
+				continue
+			}
+
+			expiration := pr.Status.CompletionTime.Add(p.maxAge)
+			if !expiration.After(now) {
+				discarded = append(discarded, discardedPipelineRun{pipelineRun: pr,
+					reason: fmt.Sprintf("exceeded the time to keep PipelineRuns: %s", p.maxAge)})
+			} else if nextExpiration.IsZero() || expiration.Before(nextExpiration) {
+				nextExpiration = expiration
+			}
+		}
+	}
+	return
+}
+
+// getPolicy returns the policy of a Pipeline. Each field of the discarder falls back to the DevOpsProject
+// if it's empty, then it's limited by the global caps.
+func (r *GCReconciler) getPolicy(ctx context.Context, pipeline *v1alpha3.Pipeline) (policy gcPolicy, err error) {
+	daysToKeep, numToKeep := getDiscarder(pipeline)
+
+	if daysToKeep == "" || numToKeep == "" {
+		var project *v1alpha3.DevOpsProject
+		if project, err = getDevOpsProject(ctx, r.Client, pipeline.Namespace); err != nil {
+			return
+		}
+		if project != nil {
+			if daysToKeep == "" {
+				daysToKeep = project.Annotations[v1alpha3.PipelineRunDaysToKeepAnnoKey]
+			}
+			if numToKeep == "" {
+				numToKeep = project.Annotations[v1alpha3.PipelineRunNumToKeepAnnoKey]
+			}
+		}
+	}
+
+	if days := parseToKeep(daysToKeep); days > 0 {
+		policy.maxAge = time.Duration(days) * 24 * time.Hour
+	}
+	policy.maxCount = parseToKeep(numToKeep)
+
+	if r.MaxAge > 0 && (policy.maxAge <= 0 || policy.maxAge > r.MaxAge) {
+		policy.maxAge = r.MaxAge
+	}
+	if r.MaxCount > 0 && (policy.maxCount <= 0 || policy.maxCount > r.MaxCount) {
+		policy.maxCount = r.MaxCount
+	}
+	return
+}
+
+// getDiscarder returns the discarder settings of a Pipeline
+func getDiscarder(pipeline *v1alpha3.Pipeline) (daysToKeep, numToKeep string) {
+	var discarder *v1alpha3.DiscarderProperty
+	switch pipeline.Spec.Type {
+	case v1alpha3.NoScmPipelineType:
+		if pipeline.Spec.Pipeline != nil {
+			discarder = pipeline.Spec.Pipeline.Discarder
+		}
+	case v1alpha3.MultiBranchPipelineType:
+		if pipeline.Spec.MultiBranchPipeline != nil {
+			discarder = pipeline.Spec.MultiBranchPipeline.Discarder
+		}
+	}
+	if discarder != nil {
+		daysToKeep, numToKeep = discarder.DaysToKeep, discarder.NumToKeep
+	}
+	return
+}
+
+// parseToKeep parses a value of the discarder. Same as Jenkins, a non-positive value means unlimited.
+func parseToKeep(value string) (result int) {
+	if value == "" {
+		return
+	}
+	var err error
+	if result, err = strconv.Atoi(value); err != nil || result < 0 {
+		result = 0
+	}
+	return
+}
+
+// deletePipelineRun deletes a PipelineRun and its ConfigMap data store. The run history of the engine and the
+// archived data are deleted by the PipelineRun controller via the finalizer.
+func (r *GCReconciler) deletePipelineRun(ctx context.Context, pipeline *v1alpha3.Pipeline, pr *v1alpha3.PipelineRun,
+	reason string) (err error) {
+	if err = r.Delete(ctx, pr); err != nil {
+		if err = client.IgnoreNotFound(err); err != nil {
+			r.recorder.Eventf(pipeline, corev1.EventTypeWarning, v1alpha3.GarbageCollectFailed,
+				"Failed to delete PipelineRun %s, error: %v", pr.Name, err)
+		}
+		return
+	}
+	r.recorder.Eventf(pipeline, corev1.EventTypeNormal, v1alpha3.GarbageCollected,
+		"Deleted PipelineRun %s, because it %s", pr.Name, reason)
+
+	// the owner reference might be missing, then the garbage collector of Kubernetes doesn't work
+	cm := &corev1.ConfigMap{}
+	if err = r.Get(ctx, types.NamespacedName{Namespace: pr.Namespace, Name: pr.Name}, cm); err != nil {
+		err = client.IgnoreNotFound(err)
+		return
+	}
+	if isDataStoreOf(cm, pr) {
+		if err = r.Delete(ctx, cm); apierrors.IsNotFound(err) {
+			err = nil
+		}
+	}
+	return
+}
+
+// isDataStoreOf returns true if the ConfigMap is the data store of the PipelineRun
+func isDataStoreOf(cm *corev1.ConfigMap, pr *v1alpha3.PipelineRun) bool {
+	for _, ref := range cm.OwnerReferences {
+		if (ref.UID != "" && ref.UID == pr.UID) || (ref.Kind == "PipelineRun" && ref.Name == pr.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *GCReconciler) getNow() time.Time {
+	if r.now == nil {
+		return time.Now()
+	}
+	return r.now()
+}
+
+// getPipelineOfRun maps a PipelineRun to its Pipeline
+func getPipelineOfRun(obj client.Object) (requests []reconcile.Request) {
+	if pipelineName := obj.GetLabels()[v1alpha3.PipelineNameLabelKey]; pipelineName != "" {
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{
+			Namespace: obj.GetNamespace(),
+			Name:      pipelineName,
+		}})
+	}
+	return
+}
+
+// isCompletedPipelineRun returns true if the object is a completed PipelineRun
+func isCompletedPipelineRun(obj client.Object) bool {
+	pr, ok := obj.(*v1alpha3.PipelineRun)
+	return ok && pr.HasCompleted()
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *GCReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	r.recorder = mgr.GetEventRecorderFor("pipelinerun-gc-controller")
+	r.log = ctrl.Log.WithName("pipelinerun-gc-controller")
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("pipelinerun-gc").
+		For(&v1alpha3.Pipeline{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		// collect the PipelineRuns once a new one completes
+		Watches(&source.Kind{Type: &v1alpha3.PipelineRun{}}, handler.EnqueueRequestsFromMapFunc(getPipelineOfRun),
+			builder.WithPredicates(predicate.NewPredicateFuncs(isCompletedPipelineRun))).
+		Complete(r)
+}
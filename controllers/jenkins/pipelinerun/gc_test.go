@@ -0,0 +1,293 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinerun
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
+	"kubesphere.io/devops/pkg/constants"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+var gcNow = time.Date(2022, 10, 1, 0, 0, 0, 0, time.UTC)
+
+func newCompletedPipelineRun(name, refName string, completedBefore time.Duration) *v1alpha3.PipelineRun {
+	completionTime := metav1.NewTime(gcNow.Add(-completedBefore))
+	pr := &v1alpha3.PipelineRun{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "ns",
+			Name:      name,
+			UID:       types.UID(name),
+			Labels:    map[string]string{v1alpha3.PipelineNameLabelKey: "pipeline"},
+		},
+		Status: v1alpha3.PipelineRunStatus{CompletionTime: &completionTime},
+	}
+	if refName != "" {
+		pr.Spec.PipelineSpec = &v1alpha3.PipelineSpec{Type: v1alpha3.MultiBranchPipelineType}
+		pr.Spec.SCM = &v1alpha3.SCM{RefName: refName}
+	}
+	return pr
+}
+
+func getDiscardedNames(discarded []discardedPipelineRun) (names []string) {
+	for _, item := range discarded {
+		names = append(names, item.pipelineRun.Name)
+	}
+	return
+}
+
+func TestGCPolicy_discard(t *testing.T) {
+	running := newCompletedPipelineRun("running", "", 0)
+	running.Status.CompletionTime = nil
+	pinned := newCompletedPipelineRun("pinned", "", 10*time.Hour)
+	pinned.Annotations = map[string]string{v1alpha3.PipelineRunPinnedAnnoKey: "true"}
+
+	tests := []struct {
+		name               string
+		policy             gcPolicy
+		pipelineRuns       []*v1alpha3.PipelineRun
+		wantDiscarded      []string
+		wantNextExpiration time.Time
+	}{{
+		name: "no PipelineRuns",
+	}, {
+		name:   "keep the newest ones",
+		policy: gcPolicy{maxCount: 2},
+		pipelineRuns: []*v1alpha3.PipelineRun{
+			newCompletedPipelineRun("pr-1", "", 3*time.Hour),
+			newCompletedPipelineRun("pr-3", "", time.Hour),
+			newCompletedPipelineRun("pr-2", "", 2*time.Hour),
+			newCompletedPipelineRun("pr-0", "", 4*time.Hour),
+		},
+		wantDiscarded: []string{"pr-1", "pr-0"},
+	}, {
+		name:   "delete the expired ones",
+		policy: gcPolicy{maxAge: 2 * time.Hour},
+		pipelineRuns: []*v1alpha3.PipelineRun{
+			newCompletedPipelineRun("pr-1", "", 3*time.Hour),
+			newCompletedPipelineRun("pr-2", "", 2*time.Hour),
+			newCompletedPipelineRun("pr-3", "", 30*time.Minute),
+			newCompletedPipelineRun("pr-4", "", 10*time.Minute),
+		},
+		wantDiscarded:      []string{"pr-2", "pr-1"},
+		wantNextExpiration: gcNow.Add(90 * time.Minute),
+	}, {
+		name:   "running and pinned ones are not counted",
+		policy: gcPolicy{maxAge: time.Hour, maxCount: 1},
+		pipelineRuns: []*v1alpha3.PipelineRun{
+			running, pinned,
+			newCompletedPipelineRun("pr-1", "", 20*time.Minute),
+			newCompletedPipelineRun("pr-2", "", 10*time.Minute),
+		},
+		wantDiscarded:      []string{"pr-1"},
+		wantNextExpiration: gcNow.Add(50 * time.Minute),
+	}, {
+		name:   "collect per branch",
+		policy: gcPolicy{maxCount: 1},
+		pipelineRuns: []*v1alpha3.PipelineRun{
+			newCompletedPipelineRun("main-1", "main", 2*time.Hour),
+			newCompletedPipelineRun("main-2", "main", time.Hour),
+			newCompletedPipelineRun("dev-1", "dev", 3*time.Hour),
+		},
+		wantDiscarded: []string{"main-1"},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var pipelineRuns []v1alpha3.PipelineRun
+			for _, pr := range tt.pipelineRuns {
+				pipelineRuns = append(pipelineRuns, *pr.DeepCopy())
+			}
+			discarded, nextExpiration := tt.policy.discard(pipelineRuns, gcNow)
+			assert.Equal(t, tt.wantDiscarded, getDiscardedNames(discarded))
+			assert.Equal(t, tt.wantNextExpiration, nextExpiration)
+		})
+	}
+}
+
+func TestParseToKeep(t *testing.T) {
+	assert.Equal(t, 0, parseToKeep(""))
+	assert.Equal(t, 0, parseToKeep("-1"))
+	assert.Equal(t, 0, parseToKeep("invalid"))
+	assert.Equal(t, 7, parseToKeep("7"))
+}
+
+func newPipelineWithDiscarder(pipelineType v1alpha3.PipelineType, daysToKeep, numToKeep string) *v1alpha3.Pipeline {
+	pipeline := &v1alpha3.Pipeline{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "pipeline"},
+		Spec:       v1alpha3.PipelineSpec{Type: pipelineType},
+	}
+	discarder := &v1alpha3.DiscarderProperty{DaysToKeep: daysToKeep, NumToKeep: numToKeep}
+	if pipelineType == v1alpha3.MultiBranchPipelineType {
+		pipeline.Spec.MultiBranchPipeline = &v1alpha3.MultiBranchPipeline{Discarder: discarder}
+	} else {
+		pipeline.Spec.Pipeline = &v1alpha3.NoScmPipeline{Discarder: discarder}
+	}
+	return pipeline
+}
+
+func TestGCReconciler_getPolicy(t *testing.T) {
+	schema, err := v1alpha3.SchemeBuilder.Register().Build()
+	assert.Nil(t, err)
+	assert.Nil(t, corev1.AddToScheme(schema))
+
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name:   "ns",
+		Labels: map[string]string{constants.DevOpsProjectLabelKey: "project"},
+	}}
+	project := &v1alpha3.DevOpsProject{ObjectMeta: metav1.ObjectMeta{
+		Name: "project",
+		Annotations: map[string]string{
+			v1alpha3.PipelineRunDaysToKeepAnnoKey: "3",
+			v1alpha3.PipelineRunNumToKeepAnnoKey:  "20",
+		},
+	}}
+
+	tests := []struct {
+		name     string
+		pipeline *v1alpha3.Pipeline
+		objects  []client.Object
+		maxAge   time.Duration
+		maxCount int
+		want     gcPolicy
+	}{{
+		name:     "no discarder",
+		pipeline: newPipelineWithDiscarder(v1alpha3.NoScmPipelineType, "", ""),
+	}, {
+		name:     "the discarder of a Pipeline",
+		pipeline: newPipelineWithDiscarder(v1alpha3.NoScmPipelineType, "1", "10"),
+		objects:  []client.Object{ns, project},
+		want:     gcPolicy{maxAge: 24 * time.Hour, maxCount: 10},
+	}, {
+		name:     "the discarder of a multi-branch Pipeline",
+		pipeline: newPipelineWithDiscarder(v1alpha3.MultiBranchPipelineType, "2", "-1"),
+		want:     gcPolicy{maxAge: 48 * time.Hour},
+	}, {
+		name:     "fall back to the DevOpsProject",
+		pipeline: newPipelineWithDiscarder(v1alpha3.NoScmPipelineType, "", "5"),
+		objects:  []client.Object{ns, project},
+		want:     gcPolicy{maxAge: 72 * time.Hour, maxCount: 5},
+	}, {
+		name:     "limited by the global caps",
+		pipeline: newPipelineWithDiscarder(v1alpha3.NoScmPipelineType, "-1", "10"),
+		maxAge:   time.Hour,
+		maxCount: 3,
+		want:     gcPolicy{maxAge: time.Hour, maxCount: 3},
+	}, {
+		name:     "less than the global caps",
+		pipeline: newPipelineWithDiscarder(v1alpha3.NoScmPipelineType, "1", "2"),
+		maxAge:   72 * time.Hour,
+		maxCount: 3,
+		want:     gcPolicy{maxAge: 24 * time.Hour, maxCount: 2},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &GCReconciler{
+				Client:   fakeclient.NewClientBuilder().WithScheme(schema).WithObjects(tt.objects...).Build(),
+				MaxAge:   tt.maxAge,
+				MaxCount: tt.maxCount,
+			}
+			policy, err := r.getPolicy(context.Background(), tt.pipeline)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, policy)
+		})
+	}
+}
+
+func TestGCReconciler_Reconcile(t *testing.T) {
+	schema, err := v1alpha3.SchemeBuilder.Register().Build()
+	assert.Nil(t, err)
+	assert.Nil(t, corev1.AddToScheme(schema))
+
+	pipeline := newPipelineWithDiscarder(v1alpha3.NoScmPipelineType, "1", "2")
+	newDataStore := func(pr *v1alpha3.PipelineRun) *corev1.ConfigMap {
+		return &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+			Namespace:       pr.Namespace,
+			Name:            pr.Name,
+			OwnerReferences: []metav1.OwnerReference{{Kind: "PipelineRun", Name: pr.Name, UID: pr.UID}},
+		}}
+	}
+	expired := newCompletedPipelineRun("expired", "", 25*time.Hour)
+	exceeded := newCompletedPipelineRun("exceeded", "", 3*time.Hour)
+	kept := newCompletedPipelineRun("kept", "", 2*time.Hour)
+	latest := newCompletedPipelineRun("latest", "", time.Hour)
+	anotherPipelineRun := newCompletedPipelineRun("another", "", 48*time.Hour)
+	anotherPipelineRun.Labels[v1alpha3.PipelineNameLabelKey] = "another"
+	// a ConfigMap which has the same name but it's not a data store
+	notDataStore := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: exceeded.Name}}
+
+	c := fakeclient.NewClientBuilder().WithScheme(schema).WithObjects(pipeline, expired, exceeded, kept, latest,
+		anotherPipelineRun, newDataStore(expired), notDataStore).Build()
+	recorder := record.NewFakeRecorder(10)
+	r := &GCReconciler{
+		Client:   c,
+		log:      logr.New(log.NullLogSink{}),
+		recorder: recorder,
+		now:      func() time.Time { return gcNow },
+	}
+
+	result, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: types.NamespacedName{
+		Namespace: "ns", Name: "pipeline",
+	}})
+	assert.Nil(t, err)
+	// the PipelineRun "kept" expires after 22 hours
+	assert.Equal(t, gcResyncPeriod, result.RequeueAfter)
+
+	assertExists := func(obj client.Object, exists bool) {
+		err := c.Get(context.Background(), client.ObjectKeyFromObject(obj), obj)
+		assert.Equal(t, exists, err == nil, fmt.Sprintf("%s exists: %v", obj.GetName(), exists))
+	}
+	assertExists(&v1alpha3.PipelineRun{ObjectMeta: expired.ObjectMeta}, false)
+	assertExists(&v1alpha3.PipelineRun{ObjectMeta: exceeded.ObjectMeta}, false)
+	assertExists(&v1alpha3.PipelineRun{ObjectMeta: kept.ObjectMeta}, true)
+	assertExists(&v1alpha3.PipelineRun{ObjectMeta: latest.ObjectMeta}, true)
+	assertExists(&v1alpha3.PipelineRun{ObjectMeta: anotherPipelineRun.ObjectMeta}, true)
+	assertExists(&corev1.ConfigMap{ObjectMeta: expired.ObjectMeta}, false)
+	assertExists(&corev1.ConfigMap{ObjectMeta: notDataStore.ObjectMeta}, true)
+	assert.Len(t, recorder.Events, 2)
+
+	// the Pipeline was deleted
+	result, err = r.Reconcile(context.Background(), ctrl.Request{NamespacedName: types.NamespacedName{
+		Namespace: "ns", Name: "not-found",
+	}})
+	assert.Nil(t, err)
+	assert.Zero(t, result.RequeueAfter)
+}
+
+func TestGetPipelineOfRun(t *testing.T) {
+	pr := newCompletedPipelineRun("pr", "", time.Hour)
+	assert.Equal(t, "pipeline", getPipelineOfRun(pr)[0].Name)
+	assert.True(t, isCompletedPipelineRun(pr))
+
+	pr.Labels = nil
+	pr.Status.CompletionTime = nil
+	assert.Empty(t, getPipelineOfRun(pr))
+	assert.False(t, isCompletedPipelineRun(pr))
+	assert.False(t, isCompletedPipelineRun(&corev1.ConfigMap{}))
+}
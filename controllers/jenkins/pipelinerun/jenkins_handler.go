@@ -17,7 +17,10 @@ limitations under the License.
 package pipelinerun
 
 import (
+	"context"
 	"fmt"
+	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 
@@ -33,64 +36,167 @@ type jenkinsHandler struct {
 	*core.JenkinsCore
 }
 
-// getPipelineNodeDetails gets node details including pipeline steps.
-func (handler *jenkinsHandler) getPipelineNodeDetails(pipelineName, namespace string, pr *v1alpha3.PipelineRun) ([]pipelinerun.NodeDetail, error) {
+// make sure jenkinsHandler implements Engine
+var _ Engine = &jenkinsHandler{}
+
+// Trigger triggers a Jenkins build
+func (handler *jenkinsHandler) Trigger(_ context.Context, pipeline *v1alpha3.Pipeline, pr *v1alpha3.PipelineRun) (*job.PipelineRun, error) {
+	return handler.triggerJenkinsJob(pipeline.Namespace, pipeline.Name, &pr.Spec)
+}
+
+// GetRunResult returns the Jenkins build
+func (handler *jenkinsHandler) GetRunResult(_ context.Context, pipeline *v1alpha3.Pipeline, pr *v1alpha3.PipelineRun) (*job.PipelineRun, error) {
+	return handler.getPipelineRunResult(pipeline.Namespace, pipeline.Name, pr)
+}
+
+// GetNodeDetails returns the nodes of a Jenkins build
+func (handler *jenkinsHandler) GetNodeDetails(_ context.Context, pipeline *v1alpha3.Pipeline, pr *v1alpha3.PipelineRun) ([]pipelinerun.NodeDetail, error) {
+	return handler.getPipelineNodeDetails(pipeline.Name, pipeline.Namespace, pr)
+}
+
+// Stop aborts a Jenkins build
+func (handler *jenkinsHandler) Stop(_ context.Context, pr *v1alpha3.PipelineRun) error {
+	return handler.stopJenkinsJob(pr)
+}
+
+// Pause pauses a running Jenkins build
+func (handler *jenkinsHandler) Pause(_ context.Context, pr *v1alpha3.PipelineRun) error {
+	return handler.setJenkinsJobPaused(pr, true)
+}
+
+// Resume resumes a paused Jenkins build
+func (handler *jenkinsHandler) Resume(_ context.Context, pr *v1alpha3.PipelineRun) error {
+	return handler.setJenkinsJobPaused(pr, false)
+}
+
+// DeleteHistory deletes a Jenkins build
+func (handler *jenkinsHandler) DeleteHistory(_ context.Context, pr *v1alpha3.PipelineRun) error {
+	return handler.deleteJenkinsJobHistory(pr)
+}
+
+// GetLog returns the console output of a Jenkins build
+func (handler *jenkinsHandler) GetLog(_ context.Context, pr *v1alpha3.PipelineRun) (string, error) {
+	buildNum := getJenkinsBuildNumber(pr)
+	if buildNum < 0 {
+		return "", fmt.Errorf("unable to get PipelineRun log due to not found a valid run ID")
+	}
+	return handler.getLog(fmt.Sprintf("%s/%d/consoleText", getJenkinsJobPath(pr), buildNum))
+}
+
+// GetStepLog returns the log of a step of a Jenkins build via the BlueOcean API
+func (handler *jenkinsHandler) GetStepLog(_ context.Context, pr *v1alpha3.PipelineRun, nodeID, stepID string) (string, error) {
 	runID, exists := pr.GetPipelineRunID()
-	if !exists {
-		return nil, fmt.Errorf("unable to get PipelineRun nodes due to not found run ID")
+	if !exists || pr.Spec.PipelineRef == nil {
+		return "", fmt.Errorf("unable to get step log due to not found run ID")
+	}
+	api, err := getBlueOceanRunAPI(pr, runID)
+	if err != nil {
+		return "", err
+	}
+	return handler.getLog(fmt.Sprintf("%s/nodes/%s/steps/%s/log/", api, nodeID, stepID))
+}
+
+// Restart restarts a stage of the parent Jenkins build via the BlueOcean API, a new build will be queued.
+// See also https://github.com/jenkinsci/blueocean-plugin/blob/master/blueocean-pipeline-api-impl/src/main/java/io/jenkins/blueocean/rest/impl/pipeline/PipelineNodeImpl.java
+func (handler *jenkinsHandler) Restart(_ context.Context, pipeline *v1alpha3.Pipeline, pr, parent *v1alpha3.PipelineRun,
+	stage string) (*job.PipelineRun, error) {
+	runID, exists := parent.GetPipelineRunID()
+	if !exists || parent.Spec.PipelineRef == nil {
+		return nil, fmt.Errorf("unable to restart PipelineRun due to not found run ID of %s", parent.Name)
 	}
 	branch, err := getSCMRefName(&pr.Spec)
 	if err != nil {
 		return nil, err
 	}
+
 	c := job.BlueOceanClient{JenkinsCore: *handler.JenkinsCore, Organization: "jenkins"}
 	nodes, err := c.GetNodes(job.GetNodesOption{
-		Pipelines: []string{namespace, pipelineName},
+		Pipelines: []string{pipeline.Namespace, pipeline.Name},
 		Branch:    branch,
 		RunID:     runID,
 	})
 	if err != nil {
 		return nil, err
 	}
-
-	// get steps for every node
-	nodeDetails := []pipelinerun.NodeDetail{}
+	var nodeID string
 	for _, node := range nodes {
-		jobSteps, err := handler.getSteps(node.ID, pipelineName, namespace, pr)
-		if err != nil {
-			return nil, err
-		}
-		steps := make([]pipelinerun.Step, 0, len(jobSteps))
-		for i := range jobSteps {
-			steps = append(steps, pipelinerun.Step{
-				Step: jobSteps[i],
-			})
+		if node.DisplayName == stage {
+			if !node.Restartable {
+				return nil, fmt.Errorf("stage %s of run %s is not restartable", stage, runID)
+			}
+			nodeID = node.ID
+			break
 		}
-		nodeDetails = append(nodeDetails, pipelinerun.NodeDetail{
-			Node:  node,
-			Steps: steps,
-		})
 	}
-	return nodeDetails, nil
+	if nodeID == "" {
+		return nil, fmt.Errorf("stage %s is not found in run %s", stage, runID)
+	}
+
+	api, err := getBlueOceanRunAPI(parent, runID)
+	if err != nil {
+		return nil, err
+	}
+	// the response is the new run, its ID is the number of the new build
+	jobRun := &job.PipelineRun{}
+	if err = handler.JenkinsCore.RequestWithData(http.MethodPost, fmt.Sprintf("%s/nodes/%s/restart/", api, nodeID),
+		map[string]string{"Content-Type": "application/json"}, strings.NewReader(`{"restart":true}`),
+		http.StatusOK, jobRun); err != nil {
+		return nil, fmt.Errorf("failed to restart stage %s of run %s, error: %v", stage, runID, err)
+	}
+	if jobRun.ID == "" {
+		return nil, fmt.Errorf("no run ID found after restarting stage %s of run %s", stage, runID)
+	}
+	jobRun.Pipeline = branch
+	return jobRun, nil
+}
+
+// getBlueOceanRunAPI returns the BlueOcean API of a Jenkins build
+func getBlueOceanRunAPI(pr *v1alpha3.PipelineRun, runID string) (api string, err error) {
+	var branch string
+	if branch, err = getSCMRefName(&pr.Spec); err != nil {
+		return
+	}
+
+	namespace := pr.Spec.PipelineRef.Namespace
+	if namespace == "" {
+		namespace = pr.Namespace
+	}
+	api = fmt.Sprintf("/blue/rest/organizations/jenkins/pipelines/%s/pipelines/%s", namespace, pr.Spec.PipelineRef.Name)
+	if branch != "" {
+		api = fmt.Sprintf("%s/branches/%s", api, url.PathEscape(branch))
+	}
+	api = fmt.Sprintf("%s/runs/%s", api, runID)
+	return
 }
 
-func (handler *jenkinsHandler) getSteps(nodeID, pipelineName, namespace string, pr *v1alpha3.PipelineRun) ([]job.Step, error) {
+func (handler *jenkinsHandler) getLog(api string) (log string, err error) {
+	var (
+		statusCode int
+		data       []byte
+	)
+	if statusCode, data, err = handler.JenkinsCore.Request(http.MethodGet, api, nil, nil); err != nil {
+		return
+	}
+	if statusCode != http.StatusOK {
+		err = fmt.Errorf("failed to get log from %s, status code: %d", api, statusCode)
+		return
+	}
+	log = string(data)
+	return
+}
+
+// getPipelineNodeDetails gets node details including pipeline steps.
+func (handler *jenkinsHandler) getPipelineNodeDetails(pipelineName, namespace string, pr *v1alpha3.PipelineRun) ([]pipelinerun.NodeDetail, error) {
 	runID, exists := pr.GetPipelineRunID()
 	if !exists {
-		return nil, fmt.Errorf("unable to get PipelineRun all steps due to not found runID")
+		return nil, fmt.Errorf("unable to get PipelineRun nodes due to not found run ID")
 	}
 	branch, err := getSCMRefName(&pr.Spec)
 	if err != nil {
 		return nil, err
 	}
 	c := job.BlueOceanClient{JenkinsCore: *handler.JenkinsCore, Organization: "jenkins"}
-	return c.GetSteps(job.GetStepsOption{
-		RunID:        runID,
-		Branch:       branch,
-		PipelineName: pipelineName,
-		Folders:      []string{namespace},
-		NodeID:       nodeID,
-	})
+	return pipelinerun.GetNodeDetails(c, namespace, pipelineName, branch, runID)
 }
 
 func (handler *jenkinsHandler) getPipelineRunResult(devopsProjectName, pipelineName string, pr *v1alpha3.PipelineRun) (*job.PipelineRun, error) {
@@ -144,6 +250,58 @@ func (handler *jenkinsHandler) deleteJenkinsJobHistory(pipelineRun *v1alpha3.Pip
 	return
 }
 
+func (handler *jenkinsHandler) stopJenkinsJob(pipelineRun *v1alpha3.PipelineRun) (err error) {
+	var buildNum int
+	if buildNum = getJenkinsBuildNumber(pipelineRun); buildNum < 0 {
+		return fmt.Errorf("unable to stop PipelineRun due to not found a valid run ID")
+	}
+
+	jenkinsClient := job.Client{JenkinsCore: *handler.JenkinsCore}
+	jobPath := getJenkinsJobPath(pipelineRun)
+	if err = jenkinsClient.StopJob(jobPath, buildNum); err != nil {
+		err = fmt.Errorf("failed to stop Jenkins job: %s, build: %d, error: %v", jobPath, buildNum, err)
+	}
+	return
+}
+
+// setJenkinsJobPaused pauses or resumes a build. The toggle endpoint is not idempotent,
+// so the build is toggled only if its current state differs from the desired one.
+func (handler *jenkinsHandler) setJenkinsJobPaused(pipelineRun *v1alpha3.PipelineRun, paused bool) (err error) {
+	ref := pipelineRun.Spec.PipelineRef
+	if ref == nil {
+		return fmt.Errorf("unable to pause or resume PipelineRun due to not found the Pipeline reference")
+	}
+	namespace := ref.Namespace
+	if namespace == "" {
+		namespace = pipelineRun.Namespace
+	}
+
+	var run *job.PipelineRun
+	if run, err = handler.getPipelineRunResult(namespace, ref.Name, pipelineRun); err != nil {
+		return fmt.Errorf("failed to get the state of PipelineRun %s/%s, error: %v", pipelineRun.Namespace, pipelineRun.Name, err)
+	}
+	if (run.State == Paused.String()) == paused {
+		return
+	}
+	return handler.toggleJenkinsJobPause(pipelineRun)
+}
+
+// toggleJenkinsJobPause pauses a running build, or resumes a paused one.
+// See also https://github.com/jenkinsci/workflow-cps-plugin/blob/master/plugin/src/main/java/org/jenkinsci/plugins/workflow/cps/CpsFlowExecution.java
+func (handler *jenkinsHandler) toggleJenkinsJobPause(pipelineRun *v1alpha3.PipelineRun) (err error) {
+	var buildNum int
+	if buildNum = getJenkinsBuildNumber(pipelineRun); buildNum < 0 {
+		return fmt.Errorf("unable to pause or resume PipelineRun due to not found a valid run ID")
+	}
+
+	jobPath := getJenkinsJobPath(pipelineRun)
+	api := fmt.Sprintf("%s/%d/pause/toggle", jobPath, buildNum)
+	if _, err = handler.JenkinsCore.RequestWithoutData(http.MethodPost, api, nil, nil, http.StatusOK); err != nil {
+		err = fmt.Errorf("failed to pause or resume Jenkins job: %s, build: %d, error: %v", jobPath, buildNum, err)
+	}
+	return
+}
+
 // getJenkinsJobPath returns the corresponding Jenkins job path
 // only a regular or multi-branch Pipeline supported
 func getJenkinsJobPath(run *v1alpha3.PipelineRun) (jobPath string) {
@@ -159,12 +317,21 @@ func getJenkinsJobPath(run *v1alpha3.PipelineRun) (jobPath string) {
 	}
 
 	jobPath = fmt.Sprintf("/job/%s/job/%s", ref.Namespace, ref.Name)
-	if run.Spec.SCM != nil && run.Spec.SCM.RefName != "" {
-		jobPath = fmt.Sprintf("%s/job/%s", jobPath, run.Spec.SCM.RefName)
+	if refName := getJenkinsRefName(run); refName != "" {
+		jobPath = fmt.Sprintf("%s/job/%s", jobPath, refName)
 	}
 	return
 }
 
+// getJenkinsRefName returns the SCM reference name which is a part of the Jenkins job path.
+// A regular PipelineRun might have an SCM, e.g. triggered by a pull request, but there are no branch jobs of it.
+func getJenkinsRefName(run *v1alpha3.PipelineRun) string {
+	if run.Spec.SCM == nil || (run.Spec.PipelineSpec != nil && !run.Spec.IsMultiBranchPipeline()) {
+		return ""
+	}
+	return run.Spec.SCM.RefName
+}
+
 // getJenkinsBuildNumber returns the build number of a Jenkins job build which related with a PipelineRun
 // return a negative value if there is no valid build number
 func getJenkinsBuildNumber(pipelineRun *v1alpha3.PipelineRun) (num int) {
@@ -238,6 +238,152 @@ var _ = Describe("Test deleteJenkinsJobHistory", func() {
 	})
 })
 
+var _ = Describe("Test stopJenkinsJob, setJenkinsJobPaused and toggleJenkinsJobPause", func() {
+	var (
+		ctrl         *gomock.Controller
+		roundTripper *mhttp.MockRoundTripper
+		jHandler     *jenkinsHandler
+		pipelineRun  *v1alpha3.PipelineRun
+	)
+
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		roundTripper = mhttp.NewMockRoundTripper(ctrl)
+		jHandler = &jenkinsHandler{&core.JenkinsCore{
+			URL:          "http://localhost",
+			RoundTripper: roundTripper,
+		}}
+		pipelineRun = &v1alpha3.PipelineRun{
+			ObjectMeta: v1.ObjectMeta{
+				Namespace: "project1",
+				Annotations: map[string]string{
+					v1alpha3.JenkinsPipelineRunIDAnnoKey: "2",
+				},
+			},
+			Spec: v1alpha3.PipelineRunSpec{
+				PipelineRef: &corev1.ObjectReference{
+					Name: "testPipeline",
+				},
+			},
+		}
+	})
+
+	It("stop a PipelineRun without run ID", func() {
+		err := jHandler.stopJenkinsJob(&v1alpha3.PipelineRun{})
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("stop a valid PipelineRun", func() {
+		requestCrumb, _ := http.NewRequest(http.MethodGet, "http://localhost/crumbIssuer/api/json", nil)
+		responseCrumb := &http.Response{
+			StatusCode: 200,
+			Proto:      "HTTP/1.1",
+			Request:    requestCrumb,
+			Body: ioutil.NopCloser(bytes.NewBufferString(`
+				{"crumbRequestField":"CrumbRequestField","crumb":"Crumb"}
+				`)),
+		}
+		roundTripper.EXPECT().
+			RoundTrip(core.NewRequestMatcher(requestCrumb)).Return(responseCrumb, nil)
+
+		request, _ := http.NewRequest(http.MethodPost, "http://localhost/job/project1/job/testPipeline/2/stop", nil)
+		request.Header.Set("CrumbRequestField", "Crumb")
+		response := &http.Response{
+			Request:    request,
+			StatusCode: http.StatusOK,
+			Body:       ioutil.NopCloser(bytes.NewBufferString("")),
+		}
+		roundTripper.EXPECT().
+			RoundTrip(core.NewRequestMatcher(request)).Return(response, nil)
+
+		err := jHandler.stopJenkinsJob(pipelineRun)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("pause or resume a PipelineRun without run ID", func() {
+		err := jHandler.toggleJenkinsJobPause(&v1alpha3.PipelineRun{})
+		Expect(err).To(HaveOccurred())
+	})
+
+	expectState := func(state string) {
+		request, _ := http.NewRequest(http.MethodGet,
+			"http://localhost/blue/rest/organizations/jenkins/pipelines/project1/pipelines/testPipeline/runs/2/", nil)
+		request.Header.Set("Content-Type", "application/json")
+		response := &http.Response{
+			Request:    request,
+			StatusCode: http.StatusOK,
+			Body:       ioutil.NopCloser(bytes.NewBufferString(fmt.Sprintf(`{"id":"2","state":"%s"}`, state))),
+		}
+		roundTripper.EXPECT().
+			RoundTrip(core.NewRequestMatcher(request)).Return(response, nil)
+	}
+	expectToggle := func() {
+		requestCrumb, _ := http.NewRequest(http.MethodGet, "http://localhost/crumbIssuer/api/json", nil)
+		responseCrumb := &http.Response{
+			StatusCode: 200,
+			Proto:      "HTTP/1.1",
+			Request:    requestCrumb,
+			Body: ioutil.NopCloser(bytes.NewBufferString(`
+				{"crumbRequestField":"CrumbRequestField","crumb":"Crumb"}
+				`)),
+		}
+		roundTripper.EXPECT().
+			RoundTrip(core.NewRequestMatcher(requestCrumb)).Return(responseCrumb, nil)
+
+		request, _ := http.NewRequest(http.MethodPost, "http://localhost/job/project1/job/testPipeline/2/pause/toggle", nil)
+		request.Header.Set("CrumbRequestField", "Crumb")
+		response := &http.Response{
+			Request:    request,
+			StatusCode: http.StatusOK,
+			Body:       ioutil.NopCloser(bytes.NewBufferString("")),
+		}
+		roundTripper.EXPECT().
+			RoundTrip(core.NewRequestMatcher(request)).Return(response, nil)
+	}
+
+	It("pause a running PipelineRun", func() {
+		expectState("RUNNING")
+		expectToggle()
+		err := jHandler.setJenkinsJobPaused(pipelineRun, true)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("pause a paused PipelineRun", func() {
+		// the build should not be toggled back to running
+		expectState("PAUSED")
+		err := jHandler.setJenkinsJobPaused(pipelineRun, true)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("resume a paused PipelineRun", func() {
+		expectState("PAUSED")
+		expectToggle()
+		err := jHandler.setJenkinsJobPaused(pipelineRun, false)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("resume a running PipelineRun", func() {
+		expectState("RUNNING")
+		err := jHandler.setJenkinsJobPaused(pipelineRun, false)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("pause or resume a PipelineRun without Pipeline reference", func() {
+		err := jHandler.setJenkinsJobPaused(&v1alpha3.PipelineRun{}, true)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("pause a valid PipelineRun", func() {
+		expectToggle()
+		err := jHandler.toggleJenkinsJobPause(pipelineRun)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		ctrl.Finish()
+	})
+})
+
 func Test_getJenkinsJobPath(t *testing.T) {
 	type args struct {
 		pipelineRun *v1alpha3.PipelineRun
@@ -304,6 +450,29 @@ func Test_getJenkinsJobPath(t *testing.T) {
 			},
 		},
 		want: "/job/ns/job/pipeline/job/master",
+	}, {
+		name: "a regular PipelineRun triggered by a pull request",
+		args: args{
+			pipelineRun: &v1alpha3.PipelineRun{
+				ObjectMeta: v1.ObjectMeta{
+					Namespace: "ns",
+					Name:      "run",
+				},
+				Spec: v1alpha3.PipelineRunSpec{
+					SCM: &v1alpha3.SCM{
+						RefType: v1alpha3.PullRequest,
+						RefName: "PR-1",
+					},
+					PipelineSpec: &v1alpha3.PipelineSpec{
+						Type: v1alpha3.NoScmPipelineType,
+					},
+					PipelineRef: &corev1.ObjectReference{
+						Name: "pipeline",
+					},
+				},
+			},
+		},
+		want: "/job/ns/job/pipeline",
 	}}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -0,0 +1,497 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinerun
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jenkins-zh/jenkins-client/pkg/job"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/util/retry"
+	"kubesphere.io/devops/pkg/api/devops"
+	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
+	"kubesphere.io/devops/pkg/models/pipelinerun"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// runnerDefinitionAnnoKey is the annotation key of the runner definition on a Job
+	runnerDefinitionAnnoKey = devops.GroupName + "/runner-definition"
+	// runnerPipelineRunLabelKey is the label key of the PipelineRun name on a Job, the name is truncated as the Job name
+	runnerPipelineRunLabelKey = devops.GroupName + "/pipelinerun"
+	// runnerWorkspace is the shared working directory of all steps
+	runnerWorkspace = "/workspace"
+	// runnerJobNameMaxLength is the max length of a Job name, it's a label value of the Pods
+	runnerJobNameMaxLength = validation.LabelValueMaxLength
+	// DefaultRunnerImage is the image of stages which do not have a docker agent
+	DefaultRunnerImage = "alpine:3.16"
+)
+
+// kubernetesEngine runs the stages of a NoScmPipeline as a Kubernetes Job.
+// Every step is a container of the Pod, steps run in sequence as init containers
+// except the last one, which is the main container.
+type kubernetesEngine struct {
+	client.Client
+	DefaultImage string
+}
+
+// make sure kubernetesEngine implements Engine
+var _ Engine = &kubernetesEngine{}
+
+// Trigger creates a Job for the PipelineRun
+func (e *kubernetesEngine) Trigger(ctx context.Context, pipeline *v1alpha3.Pipeline, pr *v1alpha3.PipelineRun) (*job.PipelineRun, error) {
+	if pipeline.Spec.Type != v1alpha3.NoScmPipelineType || pipeline.Spec.Pipeline == nil {
+		return nil, fmt.Errorf("the kubernetes engine only supports the Pipeline type: %s", v1alpha3.NoScmPipelineType)
+	}
+	defaultImage := e.DefaultImage
+	if defaultImage == "" {
+		defaultImage = DefaultRunnerImage
+	}
+	definition, err := parseRunnerDefinition(pipeline.Annotations[v1alpha3.PipelineJenkinsfileValueAnnoKey], defaultImage)
+	if err != nil {
+		return nil, err
+	}
+
+	// the Job was created in a previous reconciling, take it rather than allocating another run ID
+	runnerJob := &batchv1.Job{}
+	if err = e.Get(ctx, client.ObjectKey{Namespace: pr.Namespace, Name: runnerJobName(pr)}, runnerJob); err == nil {
+		return e.toJobRun(pipeline.Name, runnerJob, nil), nil
+	} else if !apierrors.IsNotFound(err) {
+		return nil, err
+	}
+
+	runID, err := e.nextRunID(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	if runnerJob, err = buildRunnerJob(pipeline, pr, definition, runID); err != nil {
+		return nil, err
+	}
+	if err = e.Create(ctx, runnerJob); err != nil {
+		if !apierrors.IsAlreadyExists(err) {
+			return nil, err
+		}
+		if err = e.Get(ctx, client.ObjectKeyFromObject(runnerJob), runnerJob); err != nil {
+			return nil, err
+		}
+	}
+	return e.toJobRun(pipeline.Name, runnerJob, nil), nil
+}
+
+// GetRunResult returns the result which is computed from the Job and its Pod
+func (e *kubernetesEngine) GetRunResult(ctx context.Context, pipeline *v1alpha3.Pipeline, pr *v1alpha3.PipelineRun) (*job.PipelineRun, error) {
+	runnerJob, pod, err := e.getJobAndPod(ctx, pr)
+	if err != nil {
+		return nil, err
+	}
+	return e.toJobRun(pipeline.Name, runnerJob, pod), nil
+}
+
+// GetNodeDetails returns the stages and steps which are computed from the container statuses
+func (e *kubernetesEngine) GetNodeDetails(ctx context.Context, _ *v1alpha3.Pipeline, pr *v1alpha3.PipelineRun) ([]pipelinerun.NodeDetail, error) {
+	runnerJob, pod, err := e.getJobAndPod(ctx, pr)
+	if err != nil {
+		return nil, err
+	}
+	definition := &runnerDefinition{}
+	if err = json.Unmarshal([]byte(runnerJob.Annotations[runnerDefinitionAnnoKey]), definition); err != nil {
+		return nil, fmt.Errorf("invalid runner definition of Job %s/%s, error: %v", runnerJob.Namespace, runnerJob.Name, err)
+	}
+
+	containerStatuses := map[string]*corev1.ContainerStatus{}
+	if pod != nil {
+		for i := range pod.Status.InitContainerStatuses {
+			containerStatuses[pod.Status.InitContainerStatuses[i].Name] = &pod.Status.InitContainerStatuses[i]
+		}
+		for i := range pod.Status.ContainerStatuses {
+			containerStatuses[pod.Status.ContainerStatuses[i].Name] = &pod.Status.ContainerStatuses[i]
+		}
+	}
+	finished, _, _ := getJobFinishedResult(runnerJob)
+
+	nodeDetails := make([]pipelinerun.NodeDetail, 0, len(definition.Stages))
+	for i, stage := range definition.Stages {
+		steps := make([]pipelinerun.Step, 0, len(stage.Steps))
+		for j, step := range stage.Steps {
+			name := stepContainerName(i, j)
+			jobStep := job.Step{
+				ID:                 name,
+				DisplayName:        step.Name,
+				DisplayDescription: step.Script,
+				Type:               "STEP",
+			}
+			applyContainerStatus(&jobStep, containerStatuses[name], finished)
+			steps = append(steps, pipelinerun.Step{Step: jobStep})
+		}
+		node := job.Node{
+			ID:          strconv.Itoa(i + 1),
+			DisplayName: stage.Name,
+			Type:        "STAGE",
+		}
+		if i+1 < len(definition.Stages) {
+			node.Edges = []job.Edge{{ID: strconv.Itoa(i + 2), Type: "STAGE"}}
+		}
+		if i > 0 {
+			node.FirstParent = strconv.Itoa(i)
+		}
+		aggregateSteps(&node, steps)
+		nodeDetails = append(nodeDetails, pipelinerun.NodeDetail{Node: node, Steps: steps})
+	}
+	return nodeDetails, nil
+}
+
+// Stop suspends the Job, Kubernetes deletes its active Pods then
+func (e *kubernetesEngine) Stop(ctx context.Context, pr *v1alpha3.PipelineRun) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		runnerJob := &batchv1.Job{}
+		if err := e.Get(ctx, client.ObjectKey{Namespace: pr.Namespace, Name: runnerJobName(pr)}, runnerJob); err != nil {
+			return err
+		}
+		if runnerJob.Spec.Suspend != nil && *runnerJob.Spec.Suspend {
+			return nil
+		}
+		suspend := true
+		runnerJob.Spec.Suspend = &suspend
+		return e.Update(ctx, runnerJob)
+	})
+}
+
+// Pause is not supported, because the containers of a Pod cannot be paused
+func (e *kubernetesEngine) Pause(context.Context, *v1alpha3.PipelineRun) error {
+	return ErrActionNotSupported
+}
+
+// Resume is not supported, because the containers of a Pod cannot be paused
+func (e *kubernetesEngine) Resume(context.Context, *v1alpha3.PipelineRun) error {
+	return ErrActionNotSupported
+}
+
+// DeleteHistory deletes the Job and its Pods
+func (e *kubernetesEngine) DeleteHistory(ctx context.Context, pr *v1alpha3.PipelineRun) error {
+	runnerJob := &batchv1.Job{}
+	runnerJob.Namespace = pr.Namespace
+	runnerJob.Name = runnerJobName(pr)
+	return client.IgnoreNotFound(e.Delete(ctx, runnerJob, client.PropagationPolicy(metav1.DeletePropagationBackground)))
+}
+
+// GetLog is not supported, because reading the logs of containers needs a Kubernetes clientset
+func (e *kubernetesEngine) GetLog(context.Context, *v1alpha3.PipelineRun) (string, error) {
+	return "", ErrActionNotSupported
+}
+
+// GetStepLog is not supported, because reading the logs of containers needs a Kubernetes clientset
+func (e *kubernetesEngine) GetStepLog(context.Context, *v1alpha3.PipelineRun, string, string) (string, error) {
+	return "", ErrActionNotSupported
+}
+
+// Restart is not supported, because the steps of a Job always run from the beginning
+func (e *kubernetesEngine) Restart(context.Context, *v1alpha3.Pipeline, *v1alpha3.PipelineRun, *v1alpha3.PipelineRun, string) (*job.PipelineRun, error) {
+	return nil, ErrActionNotSupported
+}
+
+// nextRunID returns an increasing number as the run ID, which is the same as Jenkins does.
+// The last run ID is kept on the Pipeline and updated with optimistic concurrency, so concurrent
+// triggers never get the same ID, and the IDs of the garbage collected PipelineRuns are not reused.
+func (e *kubernetesEngine) nextRunID(ctx context.Context, pipeline *v1alpha3.Pipeline) (runID string, err error) {
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		latest := &v1alpha3.Pipeline{}
+		if err := e.Get(ctx, client.ObjectKeyFromObject(pipeline), latest); err != nil {
+			return err
+		}
+		lastID, err := strconv.Atoi(latest.Annotations[v1alpha3.PipelineLastRunIDAnnoKey])
+		if err != nil {
+			// the Pipeline has not run with the kubernetes engine since the annotation was introduced
+			if lastID, err = e.maxRunID(ctx, latest); err != nil {
+				return err
+			}
+		}
+		runID = strconv.Itoa(lastID + 1)
+
+		latest = latest.DeepCopy()
+		if latest.Annotations == nil {
+			latest.Annotations = map[string]string{}
+		}
+		latest.Annotations[v1alpha3.PipelineLastRunIDAnnoKey] = runID
+		return e.Update(ctx, latest)
+	})
+	return
+}
+
+// maxRunID returns the max run ID of the existing PipelineRuns of a Pipeline
+func (e *kubernetesEngine) maxRunID(ctx context.Context, pipeline *v1alpha3.Pipeline) (int, error) {
+	pipelineRuns := &v1alpha3.PipelineRunList{}
+	if err := e.List(ctx, pipelineRuns, client.InNamespace(pipeline.Namespace),
+		client.MatchingLabels{v1alpha3.PipelineNameLabelKey: pipeline.Name}); err != nil {
+		return 0, err
+	}
+	maxID := 0
+	for i := range pipelineRuns.Items {
+		if id, err := strconv.Atoi(pipelineRuns.Items[i].Annotations[v1alpha3.JenkinsPipelineRunIDAnnoKey]); err == nil && id > maxID {
+			maxID = id
+		}
+	}
+	return maxID, nil
+}
+
+func (e *kubernetesEngine) getJobAndPod(ctx context.Context, pr *v1alpha3.PipelineRun) (runnerJob *batchv1.Job, pod *corev1.Pod, err error) {
+	runnerJob = &batchv1.Job{}
+	if err = e.Get(ctx, client.ObjectKey{Namespace: pr.Namespace, Name: runnerJobName(pr)}, runnerJob); err != nil {
+		if apierrors.IsNotFound(err) {
+			err = errors.New(BuildNotExistMsg)
+		}
+		return
+	}
+
+	pods := &corev1.PodList{}
+	if err = e.List(ctx, pods, client.InNamespace(pr.Namespace), client.MatchingLabels{"job-name": runnerJob.Name}); err != nil {
+		return
+	}
+	if len(pods.Items) > 0 {
+		// take the latest Pod
+		sort.Slice(pods.Items, func(i, j int) bool {
+			return pods.Items[j].CreationTimestamp.Before(&pods.Items[i].CreationTimestamp)
+		})
+		pod = &pods.Items[0]
+	}
+	return
+}
+
+func (e *kubernetesEngine) toJobRun(pipelineName string, runnerJob *batchv1.Job, pod *corev1.Pod) *job.PipelineRun {
+	run := &job.PipelineRun{}
+	run.ID = runnerJob.Annotations[v1alpha3.JenkinsPipelineRunIDAnnoKey]
+	run.Pipeline = pipelineName
+	run.Name = runnerJob.Name
+	run.Organization = string(v1alpha3.KubernetesEngine)
+	run.Type = "WorkflowRun"
+	run.EnQueueTime = job.Time{Time: runnerJob.CreationTimestamp.Time}
+	run.State = Queued.String()
+	run.Result = Unknown.String()
+	if runnerJob.Status.StartTime != nil {
+		run.StartTime = job.Time{Time: runnerJob.Status.StartTime.Time}
+	}
+
+	if finished, result, endTime := getJobFinishedResult(runnerJob); finished {
+		run.State = Finished.String()
+		run.Result = result.String()
+		run.EndTime = job.Time{Time: endTime}
+		if run.StartTime.IsZero() {
+			run.StartTime = run.EndTime
+		}
+		duration := run.EndTime.Sub(run.StartTime.Time).Milliseconds()
+		run.DurationInMillis = &duration
+	} else if pod != nil && hasStartedContainer(pod) {
+		run.State = Running.String()
+	}
+	return run
+}
+
+// getJobFinishedResult returns the result and end time if the Job has finished
+func getJobFinishedResult(runnerJob *batchv1.Job) (finished bool, result JenkinsRunResult, endTime time.Time) {
+	for _, condition := range runnerJob.Status.Conditions {
+		if condition.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch condition.Type {
+		case batchv1.JobComplete:
+			return true, Success, condition.LastTransitionTime.Time
+		case batchv1.JobFailed:
+			return true, Failure, condition.LastTransitionTime.Time
+		case batchv1.JobSuspended:
+			return true, Aborted, condition.LastTransitionTime.Time
+		}
+	}
+	if runnerJob.Spec.Suspend != nil && *runnerJob.Spec.Suspend {
+		// the Job controller has not handled the suspension yet
+		return true, Aborted, time.Now()
+	}
+	return
+}
+
+func hasStartedContainer(pod *corev1.Pod) bool {
+	for _, statuses := range [][]corev1.ContainerStatus{pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses} {
+		for _, status := range statuses {
+			if status.State.Running != nil || status.State.Terminated != nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func applyContainerStatus(step *job.Step, status *corev1.ContainerStatus, runFinished bool) {
+	switch {
+	case status != nil && status.State.Terminated != nil:
+		terminated := status.State.Terminated
+		step.State = Finished.String()
+		step.Result = Success.String()
+		if terminated.ExitCode != 0 {
+			step.Result = Failure.String()
+		}
+		step.StartTime = job.Time{Time: terminated.StartedAt.Time}
+		step.DurationInMillis = terminated.FinishedAt.Sub(terminated.StartedAt.Time).Milliseconds()
+	case status != nil && status.State.Running != nil && !runFinished:
+		step.State = Running.String()
+		step.Result = Unknown.String()
+		step.StartTime = job.Time{Time: status.State.Running.StartedAt.Time}
+		step.DurationInMillis = time.Since(step.StartTime.Time).Milliseconds()
+	case runFinished:
+		step.State = NotBuiltState.String()
+		step.Result = NotBuiltResult.String()
+	default:
+		step.State = Queued.String()
+		step.Result = Unknown.String()
+	}
+}
+
+// aggregateSteps computes the state and result of a stage from its steps
+func aggregateSteps(node *job.Node, steps []pipelinerun.Step) {
+	var finishedCount, notBuiltCount, runningCount, failedCount int
+	for _, step := range steps {
+		if node.StartTime.IsZero() && !step.StartTime.IsZero() {
+			node.StartTime = step.StartTime
+		}
+		node.DurationInMillis += step.DurationInMillis
+		switch step.State {
+		case Finished.String():
+			finishedCount++
+		case NotBuiltState.String():
+			notBuiltCount++
+		case Running.String():
+			runningCount++
+		}
+		if step.Result == Failure.String() {
+			failedCount++
+		}
+	}
+
+	switch {
+	case failedCount > 0:
+		node.State, node.Result = Finished.String(), Failure.String()
+	case finishedCount == len(steps):
+		node.State, node.Result = Finished.String(), Success.String()
+	case notBuiltCount == len(steps):
+		node.State, node.Result = NotBuiltState.String(), NotBuiltResult.String()
+	case runningCount > 0 || finishedCount > 0:
+		node.State, node.Result = Running.String(), Unknown.String()
+	default:
+		node.State, node.Result = Queued.String(), Unknown.String()
+	}
+}
+
+func stepContainerName(stage, step int) string {
+	return fmt.Sprintf("step-%d-%d", stage, step)
+}
+
+// runnerJobName returns the name of the Job of a PipelineRun. A long name is truncated,
+// and a hash of the whole name is appended to keep it unique.
+func runnerJobName(pr *v1alpha3.PipelineRun) string {
+	if len(pr.Name) <= runnerJobNameMaxLength {
+		return pr.Name
+	}
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(pr.Name))
+	suffix := fmt.Sprintf("-%08x", hash.Sum32())
+	return strings.TrimRight(pr.Name[:runnerJobNameMaxLength-len(suffix)], "-.") + suffix
+}
+
+func buildRunnerJob(pipeline *v1alpha3.Pipeline, pr *v1alpha3.PipelineRun, definition *runnerDefinition, runID string) (*batchv1.Job, error) {
+	definitionJSON, err := json.Marshal(definition)
+	if err != nil {
+		return nil, err
+	}
+
+	var env []corev1.EnvVar
+	for key, value := range definition.Env {
+		env = append(env, corev1.EnvVar{Name: key, Value: value})
+	}
+	for _, param := range pr.Spec.Parameters {
+		env = append(env, corev1.EnvVar{Name: param.Name, Value: param.Value})
+	}
+	sort.SliceStable(env, func(i, j int) bool {
+		return env[i].Name < env[j].Name
+	})
+
+	var containers []corev1.Container
+	for i, stage := range definition.Stages {
+		for j, step := range stage.Steps {
+			containers = append(containers, corev1.Container{
+				Name:       stepContainerName(i, j),
+				Image:      stage.Image,
+				Command:    []string{"sh", "-c", step.Script},
+				WorkingDir: runnerWorkspace,
+				Env:        env,
+				VolumeMounts: []corev1.VolumeMount{{
+					Name:      "workspace",
+					MountPath: runnerWorkspace,
+				}},
+			})
+		}
+	}
+
+	isController := true
+	var backoffLimit int32
+	runnerJob := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: pr.Namespace,
+			Name:      runnerJobName(pr),
+			Labels: map[string]string{
+				v1alpha3.PipelineNameLabelKey: pipeline.Name,
+				runnerPipelineRunLabelKey:     runnerJobName(pr),
+			},
+			Annotations: map[string]string{
+				v1alpha3.JenkinsPipelineRunIDAnnoKey: runID,
+				runnerDefinitionAnnoKey:              string(definitionJSON),
+			},
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: v1alpha3.GroupVersion.String(),
+				Kind:       "PipelineRun",
+				Name:       pr.Name,
+				UID:        pr.UID,
+				Controller: &isController,
+			}},
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: &backoffLimit,
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					RestartPolicy:  corev1.RestartPolicyNever,
+					InitContainers: containers[:len(containers)-1],
+					Containers:     containers[len(containers)-1:],
+					Volumes: []corev1.Volume{{
+						Name: "workspace",
+						VolumeSource: corev1.VolumeSource{
+							EmptyDir: &corev1.EmptyDirVolumeSource{},
+						},
+					}},
+				},
+			},
+		},
+	}
+	return runnerJob, nil
+}
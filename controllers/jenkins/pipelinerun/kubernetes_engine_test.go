@@ -0,0 +1,362 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinerun
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+const testRunnerJenkinsfile = `{"pipeline":{"stages":[
+{"name":"build","branches":[{"name":"default","steps":[
+	{"name":"sh","arguments":{"isLiteral":true,"value":"make"}},
+	{"name":"sh","arguments":{"isLiteral":true,"value":"make test"}}]}]},
+{"name":"deploy","branches":[{"name":"default","steps":[
+	{"name":"echo","arguments":{"isLiteral":true,"value":"deployed"}}]}]}]}}`
+
+func newRunnerTestScheme(t *testing.T) *runtime.Scheme {
+	schema, err := v1alpha3.SchemeBuilder.Register().Build()
+	assert.Nil(t, err)
+	assert.Nil(t, v1.SchemeBuilder.AddToScheme(schema))
+	assert.Nil(t, batchv1.SchemeBuilder.AddToScheme(schema))
+	return schema
+}
+
+func newRunnerTestObjects() (*v1alpha3.Pipeline, *v1alpha3.PipelineRun) {
+	pipeline := &v1alpha3.Pipeline{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   "ns",
+			Name:        "pipeline",
+			Annotations: map[string]string{v1alpha3.PipelineJenkinsfileValueAnnoKey: testRunnerJenkinsfile},
+		},
+		Spec: v1alpha3.PipelineSpec{
+			Type:     v1alpha3.NoScmPipelineType,
+			Pipeline: &v1alpha3.NoScmPipeline{Name: "pipeline"},
+		},
+	}
+	pr := &v1alpha3.PipelineRun{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "ns",
+			Name:      "pipeline-abcde",
+			Labels:    map[string]string{v1alpha3.PipelineNameLabelKey: "pipeline"},
+		},
+		Spec: v1alpha3.PipelineRunSpec{
+			Parameters: []v1alpha3.Parameter{{Name: "VERSION", Value: "v1"}},
+		},
+	}
+	return pipeline, pr
+}
+
+func Test_kubernetesEngine_Trigger(t *testing.T) {
+	schema := newRunnerTestScheme(t)
+	pipeline, pr := newRunnerTestObjects()
+	previousRun := pr.DeepCopy()
+	previousRun.Name = "pipeline-previous"
+	previousRun.Annotations = map[string]string{v1alpha3.JenkinsPipelineRunIDAnnoKey: "3"}
+
+	engine := &kubernetesEngine{
+		Client: fake.NewClientBuilder().WithScheme(schema).WithObjects(pipeline.DeepCopy(), previousRun).Build(),
+	}
+	run, err := engine.Trigger(context.Background(), pipeline, pr)
+	assert.Nil(t, err)
+	assert.Equal(t, "4", run.ID)
+	latest := &v1alpha3.Pipeline{}
+	assert.Nil(t, engine.Get(context.Background(), client.ObjectKeyFromObject(pipeline), latest))
+	assert.Equal(t, "4", latest.Annotations[v1alpha3.PipelineLastRunIDAnnoKey])
+	assert.Equal(t, "pipeline", run.Pipeline)
+	assert.Equal(t, Queued.String(), run.State)
+
+	runnerJob := &batchv1.Job{}
+	err = engine.Get(context.Background(), client.ObjectKey{Namespace: "ns", Name: "pipeline-abcde"}, runnerJob)
+	assert.Nil(t, err)
+	assert.Equal(t, "pipeline", runnerJob.Labels[v1alpha3.PipelineNameLabelKey])
+	assert.Equal(t, "4", runnerJob.Annotations[v1alpha3.JenkinsPipelineRunIDAnnoKey])
+	podSpec := runnerJob.Spec.Template.Spec
+	if assert.Equal(t, 2, len(podSpec.InitContainers)) && assert.Equal(t, 1, len(podSpec.Containers)) {
+		assert.Equal(t, "step-0-0", podSpec.InitContainers[0].Name)
+		assert.Equal(t, []string{"sh", "-c", "make test"}, podSpec.InitContainers[1].Command)
+		assert.Equal(t, "step-1-0", podSpec.Containers[0].Name)
+		assert.Equal(t, DefaultRunnerImage, podSpec.Containers[0].Image)
+		assert.Equal(t, []v1.EnvVar{{Name: "VERSION", Value: "v1"}}, podSpec.Containers[0].Env)
+	}
+
+	// trigger it again, the existing Job will be taken
+	run, err = engine.Trigger(context.Background(), pipeline, pr)
+	assert.Nil(t, err)
+	assert.Equal(t, "4", run.ID)
+
+	// the run ID is not reused even if the previous PipelineRuns were deleted
+	assert.Nil(t, engine.Delete(context.Background(), previousRun))
+	another := pr.DeepCopy()
+	another.Name = "pipeline-another"
+	run, err = engine.Trigger(context.Background(), pipeline, another)
+	assert.Nil(t, err)
+	assert.Equal(t, "5", run.ID)
+
+	// not supported Pipeline type
+	multiBranch := pipeline.DeepCopy()
+	multiBranch.Spec.Type = v1alpha3.MultiBranchPipelineType
+	_, err = engine.Trigger(context.Background(), multiBranch, pr)
+	assert.NotNil(t, err)
+
+	// invalid Jenkinsfile
+	invalid := pipeline.DeepCopy()
+	invalid.Annotations[v1alpha3.PipelineJenkinsfileValueAnnoKey] = ""
+	_, err = engine.Trigger(context.Background(), invalid, pr)
+	assert.NotNil(t, err)
+}
+
+func Test_kubernetesEngine_GetRunResult(t *testing.T) {
+	schema := newRunnerTestScheme(t)
+	pipeline, pr := newRunnerTestObjects()
+	definition, err := parseRunnerDefinition(testRunnerJenkinsfile, DefaultRunnerImage)
+	assert.Nil(t, err)
+	runnerJob, err := buildRunnerJob(pipeline, pr, definition, "1")
+	assert.Nil(t, err)
+
+	startTime := metav1.NewTime(time.Now().Add(-time.Minute))
+	endTime := metav1.Now()
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Namespace: "ns",
+		Name:      "pipeline-abcde-xyz",
+		Labels:    map[string]string{"job-name": runnerJob.Name},
+	}}
+
+	runningJob := runnerJob.DeepCopy()
+	runningJob.Status.StartTime = &startTime
+	runningPod := pod.DeepCopy()
+	runningPod.Status.InitContainerStatuses = []v1.ContainerStatus{{
+		Name:  "step-0-0",
+		State: v1.ContainerState{Terminated: &v1.ContainerStateTerminated{StartedAt: startTime, FinishedAt: endTime}},
+	}, {
+		Name:  "step-0-1",
+		State: v1.ContainerState{Running: &v1.ContainerStateRunning{StartedAt: endTime}},
+	}}
+
+	completedJob := runningJob.DeepCopy()
+	completedJob.Status.Conditions = []batchv1.JobCondition{{
+		Type: batchv1.JobComplete, Status: v1.ConditionTrue, LastTransitionTime: endTime,
+	}}
+	failedJob := runningJob.DeepCopy()
+	failedJob.Status.Conditions = []batchv1.JobCondition{{
+		Type: batchv1.JobFailed, Status: v1.ConditionTrue, LastTransitionTime: endTime,
+	}}
+	suspended := true
+	suspendedJob := runningJob.DeepCopy()
+	suspendedJob.Spec.Suspend = &suspended
+
+	tests := []struct {
+		name       string
+		objects    []client.Object
+		wantState  string
+		wantResult string
+		wantErr    bool
+	}{{
+		name:    "Job not found",
+		wantErr: true,
+	}, {
+		name:       "Pod not created",
+		objects:    []client.Object{runnerJob.DeepCopy()},
+		wantState:  Queued.String(),
+		wantResult: Unknown.String(),
+	}, {
+		name:       "running",
+		objects:    []client.Object{runningJob, runningPod},
+		wantState:  Running.String(),
+		wantResult: Unknown.String(),
+	}, {
+		name:       "completed",
+		objects:    []client.Object{completedJob, runningPod},
+		wantState:  Finished.String(),
+		wantResult: Success.String(),
+	}, {
+		name:       "failed",
+		objects:    []client.Object{failedJob},
+		wantState:  Finished.String(),
+		wantResult: Failure.String(),
+	}, {
+		name:       "suspended",
+		objects:    []client.Object{suspendedJob},
+		wantState:  Finished.String(),
+		wantResult: Aborted.String(),
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine := &kubernetesEngine{
+				Client: fake.NewClientBuilder().WithScheme(schema).WithObjects(tt.objects...).Build(),
+			}
+			run, err := engine.GetRunResult(context.Background(), pipeline, pr)
+			if tt.wantErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, "1", run.ID)
+			assert.Equal(t, tt.wantState, run.State)
+			assert.Equal(t, tt.wantResult, run.Result)
+			if tt.wantState == Finished.String() {
+				assert.NotNil(t, run.DurationInMillis)
+			}
+		})
+	}
+}
+
+func Test_kubernetesEngine_GetNodeDetails(t *testing.T) {
+	schema := newRunnerTestScheme(t)
+	pipeline, pr := newRunnerTestObjects()
+	definition, err := parseRunnerDefinition(testRunnerJenkinsfile, DefaultRunnerImage)
+	assert.Nil(t, err)
+	runnerJob, err := buildRunnerJob(pipeline, pr, definition, "1")
+	assert.Nil(t, err)
+
+	startTime := metav1.NewTime(time.Now().Add(-time.Minute))
+	endTime := metav1.Now()
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "ns",
+			Name:      "pipeline-abcde-xyz",
+			Labels:    map[string]string{"job-name": runnerJob.Name},
+		},
+		Status: v1.PodStatus{
+			InitContainerStatuses: []v1.ContainerStatus{{
+				Name:  "step-0-0",
+				State: v1.ContainerState{Terminated: &v1.ContainerStateTerminated{StartedAt: startTime, FinishedAt: endTime}},
+			}, {
+				Name:  "step-0-1",
+				State: v1.ContainerState{Running: &v1.ContainerStateRunning{StartedAt: endTime}},
+			}},
+		},
+	}
+
+	engine := &kubernetesEngine{
+		Client: fake.NewClientBuilder().WithScheme(schema).WithObjects(runnerJob, pod).Build(),
+	}
+	nodeDetails, err := engine.GetNodeDetails(context.Background(), pipeline, pr)
+	assert.Nil(t, err)
+	if assert.Equal(t, 2, len(nodeDetails)) {
+		assert.Equal(t, "build", nodeDetails[0].DisplayName)
+		assert.Equal(t, Running.String(), nodeDetails[0].State)
+		assert.Equal(t, "2", nodeDetails[0].Edges[0].ID)
+		assert.Equal(t, Finished.String(), nodeDetails[0].Steps[0].State)
+		assert.Equal(t, Success.String(), nodeDetails[0].Steps[0].Result)
+		assert.Equal(t, Running.String(), nodeDetails[0].Steps[1].State)
+
+		assert.Equal(t, "deploy", nodeDetails[1].DisplayName)
+		assert.Equal(t, "1", nodeDetails[1].FirstParent)
+		assert.Equal(t, Queued.String(), nodeDetails[1].State)
+	}
+
+	// the Job was failed in the second step
+	failedJob := runnerJob.DeepCopy()
+	failedJob.Status.Conditions = []batchv1.JobCondition{{
+		Type: batchv1.JobFailed, Status: v1.ConditionTrue, LastTransitionTime: endTime,
+	}}
+	failedPod := pod.DeepCopy()
+	failedPod.Status.InitContainerStatuses[1].State = v1.ContainerState{
+		Terminated: &v1.ContainerStateTerminated{ExitCode: 1, StartedAt: startTime, FinishedAt: endTime},
+	}
+	engine = &kubernetesEngine{
+		Client: fake.NewClientBuilder().WithScheme(schema).WithObjects(failedJob, failedPod).Build(),
+	}
+	nodeDetails, err = engine.GetNodeDetails(context.Background(), pipeline, pr)
+	assert.Nil(t, err)
+	if assert.Equal(t, 2, len(nodeDetails)) {
+		assert.Equal(t, Failure.String(), nodeDetails[0].Result)
+		assert.Equal(t, Failure.String(), nodeDetails[0].Steps[1].Result)
+		assert.Equal(t, NotBuiltState.String(), nodeDetails[1].State)
+	}
+}
+
+func Test_kubernetesEngine_StopAndDeleteHistory(t *testing.T) {
+	schema := newRunnerTestScheme(t)
+	pipeline, pr := newRunnerTestObjects()
+	definition, err := parseRunnerDefinition(testRunnerJenkinsfile, DefaultRunnerImage)
+	assert.Nil(t, err)
+	runnerJob, err := buildRunnerJob(pipeline, pr, definition, "1")
+	assert.Nil(t, err)
+
+	engine := &kubernetesEngine{
+		Client: fake.NewClientBuilder().WithScheme(schema).WithObjects(runnerJob).Build(),
+	}
+	ctx := context.Background()
+	assert.Nil(t, engine.Stop(ctx, pr))
+	// stop it again
+	assert.Nil(t, engine.Stop(ctx, pr))
+
+	stoppedJob := &batchv1.Job{}
+	assert.Nil(t, engine.Get(ctx, client.ObjectKeyFromObject(runnerJob), stoppedJob))
+	if assert.NotNil(t, stoppedJob.Spec.Suspend) {
+		assert.True(t, *stoppedJob.Spec.Suspend)
+	}
+
+	assert.Equal(t, ErrActionNotSupported, engine.Pause(ctx, pr))
+	assert.Equal(t, ErrActionNotSupported, engine.Resume(ctx, pr))
+
+	assert.Nil(t, engine.DeleteHistory(ctx, pr))
+	// the Job does not exist anymore
+	assert.Nil(t, engine.DeleteHistory(ctx, pr))
+	assert.NotNil(t, engine.Stop(ctx, pr))
+}
+
+func Test_runnerJobName(t *testing.T) {
+	longName := strings.Repeat("a", 60) + "-pipeline-run"
+	anotherLongName := strings.Repeat("a", 60) + "-pipeline-run-2"
+	tests := []struct {
+		name string
+		pr   string
+		want string
+	}{{
+		name: "short name",
+		pr:   "pipeline-abcde",
+		want: "pipeline-abcde",
+	}, {
+		name: "the max length",
+		pr:   strings.Repeat("a", 63),
+		want: strings.Repeat("a", 63),
+	}, {
+		name: "long name",
+		pr:   longName,
+	}, {
+		name: "another long name with the same prefix",
+		pr:   anotherLongName,
+	}}
+	names := map[string]bool{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := runnerJobName(&v1alpha3.PipelineRun{ObjectMeta: metav1.ObjectMeta{Name: tt.pr}})
+			assert.LessOrEqual(t, len(got), 63)
+			assert.Empty(t, validation.IsDNS1123Label(got))
+			if tt.want != "" {
+				assert.Equal(t, tt.want, got)
+			}
+			assert.False(t, names[got], "the name %s is not unique", got)
+			names[got] = true
+		})
+	}
+}
@@ -0,0 +1,188 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinerun
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// jsonPipeline is the JSON format of a declarative Jenkinsfile.
+// See also https://github.com/jenkinsci/pipeline-model-definition-plugin/blob/master/EXTENDING.md
+type jsonPipeline struct {
+	Pipeline struct {
+		Agent       *jsonAgent     `json:"agent,omitempty"`
+		Stages      []jsonStage    `json:"stages"`
+		Environment []jsonArgument `json:"environment,omitempty"`
+	} `json:"pipeline"`
+}
+
+type jsonAgent struct {
+	Type      string          `json:"type"`
+	Arguments json.RawMessage `json:"arguments,omitempty"`
+}
+
+type jsonStage struct {
+	Name     string       `json:"name"`
+	Agent    *jsonAgent   `json:"agent,omitempty"`
+	Branches []jsonBranch `json:"branches,omitempty"`
+	Parallel []jsonStage  `json:"parallel,omitempty"`
+	Stages   []jsonStage  `json:"stages,omitempty"`
+}
+
+type jsonBranch struct {
+	Name  string     `json:"name"`
+	Steps []jsonStep `json:"steps"`
+}
+
+type jsonStep struct {
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments,omitempty"`
+	Children  []jsonStep      `json:"children,omitempty"`
+}
+
+type jsonArgument struct {
+	Key   string    `json:"key"`
+	Value jsonValue `json:"value"`
+}
+
+type jsonValue struct {
+	IsLiteral bool        `json:"isLiteral"`
+	Value     interface{} `json:"value"`
+}
+
+// runnerStage is a stage which could be executed by the Kubernetes engine
+type runnerStage struct {
+	Name  string       `json:"name"`
+	Image string       `json:"image"`
+	Steps []runnerStep `json:"steps"`
+}
+
+// runnerStep is a step which could be executed by the Kubernetes engine
+type runnerStep struct {
+	Name   string `json:"name"`
+	Script string `json:"script"`
+}
+
+// runnerDefinition is the executable definition of a Pipeline
+type runnerDefinition struct {
+	Stages []runnerStage     `json:"stages"`
+	Env    map[string]string `json:"env,omitempty"`
+}
+
+// parseRunnerDefinition parses the JSON format Jenkinsfile into a runner definition.
+// Only sequential top-level stages, and the steps sh and echo are supported.
+func parseRunnerDefinition(jsonData, defaultImage string) (definition *runnerDefinition, err error) {
+	if jsonData == "" {
+		err = fmt.Errorf("the JSON format Jenkinsfile is empty")
+		return
+	}
+	pip := &jsonPipeline{}
+	if err = json.Unmarshal([]byte(jsonData), pip); err != nil {
+		err = fmt.Errorf("failed to parse the JSON format Jenkinsfile, error: %v", err)
+		return
+	}
+	if len(pip.Pipeline.Stages) == 0 {
+		err = fmt.Errorf("no stages found in the Pipeline")
+		return
+	}
+
+	pipelineImage := defaultImage
+	if image := pip.Pipeline.Agent.getImage(); image != "" {
+		pipelineImage = image
+	}
+
+	definition = &runnerDefinition{Env: map[string]string{}}
+	for _, env := range pip.Pipeline.Environment {
+		definition.Env[env.Key] = fmt.Sprint(env.Value.Value)
+	}
+	for _, stage := range pip.Pipeline.Stages {
+		if len(stage.Parallel) > 0 || len(stage.Stages) > 0 {
+			err = fmt.Errorf("nested or parallel stages are not supported, stage: %s", stage.Name)
+			return
+		}
+		runStage := runnerStage{Name: stage.Name, Image: pipelineImage}
+		if image := stage.Agent.getImage(); image != "" {
+			runStage.Image = image
+		}
+		for _, branch := range stage.Branches {
+			var steps []runnerStep
+			if steps, err = convertSteps(branch.Steps); err != nil {
+				return
+			}
+			runStage.Steps = append(runStage.Steps, steps...)
+		}
+		if len(runStage.Steps) == 0 {
+			err = fmt.Errorf("no steps found in stage: %s", stage.Name)
+			return
+		}
+		definition.Stages = append(definition.Stages, runStage)
+	}
+	return
+}
+
+func convertSteps(steps []jsonStep) (result []runnerStep, err error) {
+	for _, step := range steps {
+		if len(step.Children) > 0 {
+			// the wrapper steps, such as container, dir and withCredentials, change how the wrapped steps run
+			err = fmt.Errorf("step '%s' which wraps other steps is not supported", step.Name)
+			return
+		}
+
+		var script string
+		switch step.Name {
+		case "sh":
+			script = getArgument(step.Arguments, "script")
+		case "echo":
+			script = fmt.Sprintf("echo '%s'", strings.ReplaceAll(getArgument(step.Arguments, "message"), "'", `'\''`))
+		default:
+			err = fmt.Errorf("step '%s' is not supported", step.Name)
+			return
+		}
+		result = append(result, runnerStep{Name: step.Name, Script: script})
+	}
+	return
+}
+
+// getArgument returns the value of an argument by key.
+// The arguments could be a single value or a list of key-value pairs.
+func getArgument(raw json.RawMessage, key string) string {
+	if len(raw) == 0 {
+		return ""
+	}
+	single := jsonValue{}
+	if err := json.Unmarshal(raw, &single); err == nil && single.Value != nil {
+		return fmt.Sprint(single.Value)
+	}
+	var args []jsonArgument
+	if err := json.Unmarshal(raw, &args); err == nil {
+		for _, arg := range args {
+			if arg.Key == key {
+				return fmt.Sprint(arg.Value.Value)
+			}
+		}
+	}
+	return ""
+}
+
+func (agent *jsonAgent) getImage() string {
+	if agent == nil || agent.Type != "docker" {
+		return ""
+	}
+	return getArgument(agent.Arguments, "image")
+}
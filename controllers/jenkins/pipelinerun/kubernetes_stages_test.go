@@ -0,0 +1,142 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinerun
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_parseRunnerDefinition(t *testing.T) {
+	tests := []struct {
+		name     string
+		jsonData string
+		want     *runnerDefinition
+		wantErr  bool
+	}{{
+		name:    "empty",
+		wantErr: true,
+	}, {
+		name:     "invalid JSON",
+		jsonData: "{",
+		wantErr:  true,
+	}, {
+		name:     "no stages",
+		jsonData: `{"pipeline":{"stages":[]}}`,
+		wantErr:  true,
+	}, {
+		name:     "parallel stages",
+		jsonData: `{"pipeline":{"stages":[{"name":"a","parallel":[{"name":"b"}]}]}}`,
+		wantErr:  true,
+	}, {
+		name:     "stage without steps",
+		jsonData: `{"pipeline":{"stages":[{"name":"a","branches":[{"name":"default","steps":[]}]}]}}`,
+		wantErr:  true,
+	}, {
+		name:     "unsupported step",
+		jsonData: `{"pipeline":{"stages":[{"name":"a","branches":[{"name":"default","steps":[{"name":"git"}]}]}]}}`,
+		wantErr:  true,
+	}, {
+		name: "wrapper step",
+		jsonData: `{"pipeline":{"stages":[{"name":"a","branches":[{"name":"default","steps":[
+	{"name":"dir","arguments":{"isLiteral":true,"value":"src"},"children":[
+		{"name":"sh","arguments":{"isLiteral":true,"value":"ls"}}]}]}]}]}}`,
+		wantErr: true,
+	}, {
+		name: "normal case",
+		jsonData: `{"pipeline":{
+"agent":{"type":"docker","arguments":[{"key":"image","value":{"isLiteral":true,"value":"golang:1.18"}}]},
+"environment":[{"key":"NAME","value":{"isLiteral":true,"value":"devops"}}],
+"stages":[{"name":"build","branches":[{"name":"default","steps":[
+	{"name":"sh","arguments":[{"key":"script","value":{"isLiteral":true,"value":"go build"}}]},
+	{"name":"echo","arguments":{"isLiteral":true,"value":"it's done"}}]}]},
+{"name":"test","agent":{"type":"docker","arguments":[{"key":"image","value":{"isLiteral":true,"value":"alpine"}}]},
+"branches":[{"name":"default","steps":[{"name":"sh","arguments":{"isLiteral":true,"value":"ls"}}]}]}]}}`,
+		want: &runnerDefinition{
+			Env: map[string]string{"NAME": "devops"},
+			Stages: []runnerStage{{
+				Name:  "build",
+				Image: "golang:1.18",
+				Steps: []runnerStep{{Name: "sh", Script: "go build"}, {Name: "echo", Script: `echo 'it'\''s done'`}},
+			}, {
+				Name:  "test",
+				Image: "alpine",
+				Steps: []runnerStep{{Name: "sh", Script: "ls"}},
+			}},
+		},
+	}, {
+		name:     "take the default image",
+		jsonData: `{"pipeline":{"agent":{"type":"any"},"stages":[{"name":"a","branches":[{"name":"default","steps":[{"name":"sh","arguments":{"isLiteral":true,"value":"ls"}}]}]}]}}`,
+		want: &runnerDefinition{
+			Env: map[string]string{},
+			Stages: []runnerStage{{
+				Name:  "a",
+				Image: DefaultRunnerImage,
+				Steps: []runnerStep{{Name: "sh", Script: "ls"}},
+			}},
+		},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseRunnerDefinition(tt.jsonData, DefaultRunnerImage)
+			if tt.wantErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_getArgument(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		key  string
+		want string
+	}{{
+		name: "empty",
+		want: "",
+	}, {
+		name: "single value",
+		raw:  `{"isLiteral":true,"value":"ls"}`,
+		key:  "script",
+		want: "ls",
+	}, {
+		name: "key-value pairs",
+		raw:  `[{"key":"script","value":{"isLiteral":true,"value":"ls"}}]`,
+		key:  "script",
+		want: "ls",
+	}, {
+		name: "key not found",
+		raw:  `[{"key":"script","value":{"isLiteral":true,"value":"ls"}}]`,
+		key:  "label",
+		want: "",
+	}, {
+		name: "invalid",
+		raw:  `"ls"`,
+		key:  "script",
+		want: "",
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, getArgument([]byte(tt.raw), tt.key))
+		})
+	}
+}
@@ -0,0 +1,172 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinerun
+
+import (
+	"context"
+	"errors"
+
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// actionNotSupportedReason is the condition reason when the engine cannot handle the action
+	actionNotSupportedReason = "NotSupported"
+	// stoppingReason is the condition reason when the engine has been asked to abort the run
+	stoppingReason = "Stopping"
+)
+
+// handleAction handles the action of a PipelineRun.
+// The hold is true if the PipelineRun should not be triggered or synchronized in this reconciling.
+func (r *Reconciler) handleAction(ctx context.Context, engine Engine, pr *v1alpha3.PipelineRun) (hold bool, err error) {
+	switch pr.GetAction() {
+	case v1alpha3.Stop:
+		hold, err = r.stopPipelineRun(ctx, engine, pr)
+	case v1alpha3.Pause:
+		hold, err = r.pausePipelineRun(ctx, engine, pr)
+	case v1alpha3.Resume:
+		err = r.resumePipelineRun(ctx, engine, pr)
+	}
+	return
+}
+
+// stopPipelineRun marks a PipelineRun which has not started as cancelled, or aborts a started one.
+// A started PipelineRun keeps being synchronized, the final result and stages are stored by the
+// synchronization once the engine reports that the run finished, see markCancelled.
+func (r *Reconciler) stopPipelineRun(ctx context.Context, engine Engine, pr *v1alpha3.PipelineRun) (hold bool, err error) {
+	if !pr.HasStarted() {
+		hold = true
+		markCancelled(&pr.Status)
+		if err = r.updateStatus(ctx, &pr.Status, client.ObjectKeyFromObject(pr)); err == nil {
+			r.recorder.Eventf(pr, corev1.EventTypeNormal, v1alpha3.Stopped, "Stopped PipelineRun %s/%s", pr.Namespace, pr.Name)
+		}
+		return
+	}
+	if condition := pr.Status.GetCondition(v1alpha3.ConditionSucceeded); condition != nil && condition.Reason == stoppingReason {
+		// the engine has been asked to abort the run, waiting for it to finish
+		return
+	}
+
+	if err = engine.Stop(ctx, pr); err != nil {
+		r.recorder.Eventf(pr, corev1.EventTypeWarning, v1alpha3.ActionFailed, "Failed to stop PipelineRun %s/%s, and error was %v", pr.Namespace, pr.Name, err)
+		hold = true
+		return
+	}
+	now := v1.Now()
+	pr.Status.UpdateTime = &now
+	pr.Status.AddCondition(&v1alpha3.Condition{
+		Type:               v1alpha3.ConditionSucceeded,
+		Status:             v1alpha3.ConditionUnknown,
+		Reason:             stoppingReason,
+		Message:            "the PipelineRun is being stopped by the Stop action",
+		LastProbeTime:      now,
+		LastTransitionTime: now,
+	})
+	err = r.updateStatus(ctx, &pr.Status, client.ObjectKeyFromObject(pr))
+	return
+}
+
+// markCancelled marks the status as cancelled by the Stop action.
+// The completion time reported by the engine is kept if there is.
+func markCancelled(status *v1alpha3.PipelineRunStatus) {
+	now := v1.Now()
+	status.Phase = v1alpha3.Cancelled
+	if status.CompletionTime == nil {
+		status.CompletionTime = &now
+	}
+	status.UpdateTime = &now
+	status.AddCondition(&v1alpha3.Condition{
+		Type:               v1alpha3.ConditionSucceeded,
+		Status:             v1alpha3.ConditionFalse,
+		Reason:             string(v1alpha3.Cancelled),
+		Message:            "the PipelineRun was stopped by the Stop action",
+		LastProbeTime:      now,
+		LastTransitionTime: now,
+	})
+}
+
+// pausePipelineRun holds a PipelineRun which has not started in the queue, or pauses a started one
+func (r *Reconciler) pausePipelineRun(ctx context.Context, engine Engine, pr *v1alpha3.PipelineRun) (hold bool, err error) {
+	hold = !pr.HasStarted()
+	if condition := pr.Status.GetCondition(v1alpha3.ConditionPaused); condition != nil &&
+		(condition.Status == v1alpha3.ConditionTrue || condition.Reason == actionNotSupportedReason) {
+		// the action has been handled
+		return
+	}
+
+	now := v1.Now()
+	condition := &v1alpha3.Condition{
+		Type:               v1alpha3.ConditionPaused,
+		Status:             v1alpha3.ConditionTrue,
+		Reason:             string(v1alpha3.Pause),
+		Message:            "the PipelineRun was held in the queue by the Pause action",
+		LastProbeTime:      now,
+		LastTransitionTime: now,
+	}
+	if pr.HasStarted() {
+		condition.Message = "the PipelineRun was paused by the Pause action"
+		if err = engine.Pause(ctx, pr); errors.Is(err, ErrActionNotSupported) {
+			r.recorder.Eventf(pr, corev1.EventTypeWarning, v1alpha3.ActionFailed, "Unable to pause PipelineRun %s/%s, and error was %v", pr.Namespace, pr.Name, err)
+			condition.Status = v1alpha3.ConditionFalse
+			condition.Reason = actionNotSupportedReason
+			condition.Message = err.Error()
+		} else if err != nil {
+			r.recorder.Eventf(pr, corev1.EventTypeWarning, v1alpha3.ActionFailed, "Failed to pause PipelineRun %s/%s, and error was %v", pr.Namespace, pr.Name, err)
+			return
+		}
+	} else {
+		pr.Status.Phase = v1alpha3.Pending
+	}
+
+	pr.Status.UpdateTime = &now
+	pr.Status.AddCondition(condition)
+	if err = r.updateStatus(ctx, &pr.Status, client.ObjectKeyFromObject(pr)); err == nil && condition.Status == v1alpha3.ConditionTrue {
+		r.recorder.Eventf(pr, corev1.EventTypeNormal, v1alpha3.Suspended, "Paused PipelineRun %s/%s", pr.Namespace, pr.Name)
+	}
+	return
+}
+
+// resumePipelineRun releases a paused PipelineRun
+func (r *Reconciler) resumePipelineRun(ctx context.Context, engine Engine, pr *v1alpha3.PipelineRun) (err error) {
+	if !pr.IsPaused() {
+		return
+	}
+	if pr.HasStarted() {
+		if err = engine.Resume(ctx, pr); err != nil {
+			r.recorder.Eventf(pr, corev1.EventTypeWarning, v1alpha3.ActionFailed, "Failed to resume PipelineRun %s/%s, and error was %v", pr.Namespace, pr.Name, err)
+			return
+		}
+	}
+
+	now := v1.Now()
+	pr.Status.UpdateTime = &now
+	pr.Status.AddCondition(&v1alpha3.Condition{
+		Type:               v1alpha3.ConditionPaused,
+		Status:             v1alpha3.ConditionFalse,
+		Reason:             string(v1alpha3.Resume),
+		Message:            "the PipelineRun was resumed by the Resume action",
+		LastProbeTime:      now,
+		LastTransitionTime: now,
+	})
+	if err = r.updateStatus(ctx, &pr.Status, client.ObjectKeyFromObject(pr)); err == nil {
+		r.recorder.Eventf(pr, corev1.EventTypeNormal, v1alpha3.Resumed, "Resumed PipelineRun %s/%s", pr.Namespace, pr.Name)
+	}
+	return
+}
@@ -0,0 +1,243 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinerun
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jenkins-zh/jenkins-client/pkg/job"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
+	"kubesphere.io/devops/pkg/models/pipelinerun"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// fakeEngine records the invoked actions
+type fakeEngine struct {
+	actions []string
+	nodes   []pipelinerun.NodeDetail
+	err     error
+}
+
+func (e *fakeEngine) Trigger(context.Context, *v1alpha3.Pipeline, *v1alpha3.PipelineRun) (*job.PipelineRun, error) {
+	return nil, e.record("trigger")
+}
+
+func (e *fakeEngine) GetRunResult(context.Context, *v1alpha3.Pipeline, *v1alpha3.PipelineRun) (*job.PipelineRun, error) {
+	return nil, e.record("result")
+}
+
+func (e *fakeEngine) GetNodeDetails(context.Context, *v1alpha3.Pipeline, *v1alpha3.PipelineRun) ([]pipelinerun.NodeDetail, error) {
+	return e.nodes, e.record("nodes")
+}
+
+func (e *fakeEngine) Stop(context.Context, *v1alpha3.PipelineRun) error {
+	return e.record("stop")
+}
+
+func (e *fakeEngine) Pause(context.Context, *v1alpha3.PipelineRun) error {
+	return e.record("pause")
+}
+
+func (e *fakeEngine) Resume(context.Context, *v1alpha3.PipelineRun) error {
+	return e.record("resume")
+}
+
+func (e *fakeEngine) DeleteHistory(context.Context, *v1alpha3.PipelineRun) error {
+	return e.record("delete")
+}
+
+func (e *fakeEngine) GetLog(context.Context, *v1alpha3.PipelineRun) (string, error) {
+	return "log", e.record("log")
+}
+
+func (e *fakeEngine) GetStepLog(_ context.Context, _ *v1alpha3.PipelineRun, nodeID, stepID string) (string, error) {
+	return fmt.Sprintf("log of %s-%s", nodeID, stepID), e.record("step-log")
+}
+
+func (e *fakeEngine) Restart(context.Context, *v1alpha3.Pipeline, *v1alpha3.PipelineRun, *v1alpha3.PipelineRun, string) (*job.PipelineRun, error) {
+	return &job.PipelineRun{BlueItemRun: job.BlueItemRun{ID: "2"}}, e.record("restart")
+}
+
+func (e *fakeEngine) record(action string) error {
+	e.actions = append(e.actions, action)
+	return e.err
+}
+
+func TestReconciler_handleAction(t *testing.T) {
+	schema, err := v1alpha3.SchemeBuilder.Register().Build()
+	assert.Nil(t, err)
+
+	newPipelineRun := func(action v1alpha3.Action, started bool, conditions ...v1alpha3.Condition) *v1alpha3.PipelineRun {
+		pr := &v1alpha3.PipelineRun{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "pr"}}
+		if action != "" {
+			pr.Spec.Action = &action
+		}
+		if started {
+			pr.Annotations = map[string]string{v1alpha3.JenkinsPipelineRunIDAnnoKey: "1"}
+		}
+		pr.Status.Conditions = conditions
+		return pr
+	}
+	pausedCondition := v1alpha3.Condition{Type: v1alpha3.ConditionPaused, Status: v1alpha3.ConditionTrue}
+
+	tests := []struct {
+		name        string
+		pr          *v1alpha3.PipelineRun
+		engineErr   error
+		wantHold    bool
+		wantErr     bool
+		wantActions []string
+		verify      func(t *testing.T, pr *v1alpha3.PipelineRun)
+	}{{
+		name: "no action",
+		pr:   newPipelineRun("", true),
+	}, {
+		name:     "stop a pending PipelineRun",
+		pr:       newPipelineRun(v1alpha3.Stop, false),
+		wantHold: true,
+		verify: func(t *testing.T, pr *v1alpha3.PipelineRun) {
+			assert.Equal(t, v1alpha3.Cancelled, pr.Status.Phase)
+			assert.True(t, pr.HasCompleted())
+			assert.Equal(t, v1alpha3.ConditionFalse, pr.Status.GetCondition(v1alpha3.ConditionSucceeded).Status)
+		},
+	}, {
+		name:        "stop a running PipelineRun",
+		pr:          newPipelineRun(v1alpha3.Stop, true),
+		wantActions: []string{"stop"},
+		verify: func(t *testing.T, pr *v1alpha3.PipelineRun) {
+			// the phase is left to the synchronization
+			assert.False(t, pr.HasCompleted())
+			assert.Equal(t, stoppingReason, pr.Status.GetCondition(v1alpha3.ConditionSucceeded).Reason)
+		},
+	}, {
+		name: "stop a stopping PipelineRun",
+		pr: newPipelineRun(v1alpha3.Stop, true, v1alpha3.Condition{
+			Type: v1alpha3.ConditionSucceeded, Status: v1alpha3.ConditionUnknown, Reason: stoppingReason}),
+		verify: func(t *testing.T, pr *v1alpha3.PipelineRun) {
+			assert.False(t, pr.HasCompleted())
+		},
+	}, {
+		name:        "failed to stop a running PipelineRun",
+		pr:          newPipelineRun(v1alpha3.Stop, true),
+		engineErr:   errors.New("fake"),
+		wantHold:    true,
+		wantErr:     true,
+		wantActions: []string{"stop"},
+		verify: func(t *testing.T, pr *v1alpha3.PipelineRun) {
+			assert.False(t, pr.HasCompleted())
+		},
+	}, {
+		name:     "pause a pending PipelineRun",
+		pr:       newPipelineRun(v1alpha3.Pause, false),
+		wantHold: true,
+		verify: func(t *testing.T, pr *v1alpha3.PipelineRun) {
+			assert.True(t, pr.IsPaused())
+			assert.Equal(t, v1alpha3.Pending, pr.Status.Phase)
+		},
+	}, {
+		name:        "pause a running PipelineRun",
+		pr:          newPipelineRun(v1alpha3.Pause, true),
+		wantActions: []string{"pause"},
+		verify: func(t *testing.T, pr *v1alpha3.PipelineRun) {
+			assert.True(t, pr.IsPaused())
+		},
+	}, {
+		name: "pause a paused PipelineRun",
+		pr:   newPipelineRun(v1alpha3.Pause, true, pausedCondition),
+	}, {
+		name:        "the engine does not support pausing",
+		pr:          newPipelineRun(v1alpha3.Pause, true),
+		engineErr:   ErrActionNotSupported,
+		wantActions: []string{"pause"},
+		verify: func(t *testing.T, pr *v1alpha3.PipelineRun) {
+			assert.False(t, pr.IsPaused())
+			assert.Equal(t, actionNotSupportedReason, pr.Status.GetCondition(v1alpha3.ConditionPaused).Reason)
+		},
+	}, {
+		name:        "failed to pause a running PipelineRun",
+		pr:          newPipelineRun(v1alpha3.Pause, true),
+		engineErr:   errors.New("fake"),
+		wantErr:     true,
+		wantActions: []string{"pause"},
+	}, {
+		name: "resume a PipelineRun which is not paused",
+		pr:   newPipelineRun(v1alpha3.Resume, true),
+	}, {
+		name: "resume a pending PipelineRun",
+		pr:   newPipelineRun(v1alpha3.Resume, false, pausedCondition),
+		verify: func(t *testing.T, pr *v1alpha3.PipelineRun) {
+			assert.False(t, pr.IsPaused())
+		},
+	}, {
+		name:        "resume a running PipelineRun",
+		pr:          newPipelineRun(v1alpha3.Resume, true, pausedCondition),
+		wantActions: []string{"resume"},
+		verify: func(t *testing.T, pr *v1alpha3.PipelineRun) {
+			assert.False(t, pr.IsPaused())
+		},
+	}, {
+		name:        "failed to resume a running PipelineRun",
+		pr:          newPipelineRun(v1alpha3.Resume, true, pausedCondition),
+		engineErr:   errors.New("fake"),
+		wantErr:     true,
+		wantActions: []string{"resume"},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Reconciler{
+				Client:   fake.NewClientBuilder().WithScheme(schema).WithObjects(tt.pr.DeepCopy()).Build(),
+				recorder: record.NewFakeRecorder(10),
+			}
+			engine := &fakeEngine{err: tt.engineErr}
+			hold, err := r.handleAction(context.Background(), engine, tt.pr.DeepCopy())
+			assert.Equal(t, tt.wantHold, hold)
+			if tt.wantErr {
+				assert.NotNil(t, err)
+			} else {
+				assert.Nil(t, err)
+			}
+			assert.Equal(t, tt.wantActions, engine.actions)
+
+			if tt.verify != nil {
+				pr := &v1alpha3.PipelineRun{}
+				assert.Nil(t, r.Get(context.Background(), client.ObjectKeyFromObject(tt.pr), pr))
+				tt.verify(t, pr)
+			}
+		})
+	}
+}
+
+func Test_markCancelled(t *testing.T) {
+	endTime := metav1.NewTime(metav1.Now().Add(-time.Minute))
+	status := &v1alpha3.PipelineRunStatus{Phase: v1alpha3.Failed, CompletionTime: &endTime}
+	markCancelled(status)
+	assert.Equal(t, v1alpha3.Cancelled, status.Phase)
+	assert.Equal(t, &endTime, status.CompletionTime, "the completion time from the engine is kept")
+	assert.Equal(t, string(v1alpha3.Cancelled), status.GetCondition(v1alpha3.ConditionSucceeded).Reason)
+
+	status = &v1alpha3.PipelineRunStatus{}
+	markCancelled(status)
+	assert.NotNil(t, status.CompletionTime)
+}
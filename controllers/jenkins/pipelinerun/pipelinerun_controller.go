@@ -21,14 +21,14 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/go-logr/logr"
-	cmstore "kubesphere.io/devops/pkg/store/configmap"
-	storeInter "kubesphere.io/devops/pkg/store/store"
+	"kubesphere.io/devops/pkg/client/s3"
 	"kubesphere.io/devops/pkg/utils/k8sutil"
 	"reflect"
 	"time"
 
 	"github.com/jenkins-zh/jenkins-client/pkg/core"
 	"github.com/jenkins-zh/jenkins-client/pkg/job"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -39,13 +39,21 @@ import (
 	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
 	devopsClient "kubesphere.io/devops/pkg/client/devops"
 	"kubesphere.io/devops/pkg/jwt/token"
+	"kubesphere.io/devops/pkg/models/pipelinerun"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 )
 
 // tokenExpireIn indicates that the temporary token issued by controller will be expired in some time.
 const tokenExpireIn time.Duration = 5 * time.Minute
 
+// defaultResyncPeriod is the interval of synchronizing a running PipelineRun if no one was specified
+const defaultResyncPeriod = 30 * time.Second
+
 // BuildNotExistMsg indicates the build with pipelinerun-id not exist in jenkins
 const BuildNotExistMsg = "not found resources"
 
@@ -61,10 +69,20 @@ type Reconciler struct {
 	TokenIssuer          token.Issuer
 	recorder             record.EventRecorder
 	PipelineRunDataStore string
+	// S3Client is required when the PipelineRunDataStore is s3
+	S3Client s3.Interface
+	// RunnerImage is the default image of the stages which are executed by the Kubernetes engine
+	RunnerImage string
+	// ResyncPeriod is the interval of synchronizing a running PipelineRun from the engine.
+	// It is a safety net, because the status is mainly updated by the events from Jenkins.
+	ResyncPeriod time.Duration
 }
 
 //+kubebuilder:rbac:groups=devops.kubesphere.io,resources=pipelineruns,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=devops.kubesphere.io,resources=pipelines,verbs=get;list;watch;update
 //+kubebuilder:rbac:groups=devops.kubesphere.io,resources=pipelineruns/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -80,17 +98,21 @@ func (r *Reconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Resu
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
 
-	jHandler := &jenkinsHandler{&r.JenkinsCore}
-
 	// don't modify the cache in other places, like informer cache.
 	pipelineRunCopied := pipelineRun.DeepCopy()
 
 	// DeletionTimestamp.IsZero() means copyPipeline has not been deleted.
 	if !pipelineRunCopied.ObjectMeta.DeletionTimestamp.IsZero() {
-		if err = jHandler.deleteJenkinsJobHistory(pipelineRunCopied); err != nil {
-			klog.V(4).Infof("failed to delete Jenkins job history from PipelineRun: %s/%s, error: %v",
+		var engine Engine
+		if engine, err = r.getEngine(pipelineRunCopied.GetEngineType(), pipelineRunCopied); err == nil {
+			err = engine.DeleteHistory(ctx, pipelineRunCopied)
+		}
+		if err != nil {
+			klog.V(4).Infof("failed to delete the run history from PipelineRun: %s/%s, error: %v",
 				pipelineRunCopied.Namespace, pipelineRunCopied.Name, err)
 		} else {
+			r.deleteArchive(pipelineRunCopied)
+
 			k8sutil.RemoveFinalizer(&pipelineRunCopied.ObjectMeta, v1alpha3.PipelineRunFinalizerName)
 			err = r.Update(context.TODO(), pipelineRunCopied)
 		}
@@ -99,7 +121,12 @@ func (r *Reconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Resu
 
 	// the PipelineRun cannot allow building
 	if !pipelineRunCopied.Buildable() {
-		return ctrl.Result{}, nil
+		requeueAfter, err := r.retry(ctx, pipelineRunCopied)
+		if err != nil {
+			log.Error(err, "unable to retry PipelineRun")
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{RequeueAfter: requeueAfter}, r.archive(ctx, pipelineRunCopied)
 	}
 
 	// check PipelineRef
@@ -126,10 +153,26 @@ func (r *Reconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Resu
 
 	log = log.WithValues("namespace", namespaceName, "Pipeline", pipelineName)
 
+	engineType, err := resolveEngineType(ctx, r.Client, pipeline, pipelineRunCopied)
+	if err != nil {
+		log.Error(err, "unable to resolve the engine type")
+		return ctrl.Result{}, err
+	}
+	engine, err := r.getEngine(engineType, pipelineRunCopied)
+	if err != nil {
+		r.recorder.Eventf(pipelineRunCopied, corev1.EventTypeWarning, v1alpha3.TriggerFailed, "Failed to handle PipelineRun %s, and error was %v", req.NamespacedName, err)
+		return ctrl.Result{}, err
+	}
+
+	// handle the action, such as Stop, Pause and Resume
+	if hold, err := r.handleAction(ctx, engine, pipelineRunCopied); err != nil || hold {
+		return ctrl.Result{}, err
+	}
+
 	// check PipelineRun status
 	if pipelineRunCopied.HasStarted() {
-		log.V(5).Info("pipeline has already started, and we are retrieving run data from Jenkins.")
-		pipelineBuild, err := jHandler.getPipelineRunResult(namespaceName, pipelineName, pipelineRunCopied)
+		log.V(5).Info("pipeline has already started, and we are retrieving run data from the engine.", "engine", engineType)
+		pipelineBuild, err := engine.GetRunResult(ctx, pipeline, pipelineRunCopied)
 		if err != nil {
 			if err.Error() == BuildNotExistMsg { // retry if get pipelinerun failed by not exist
 				runID, _ := pipelineRunCopied.GetPipelineRunID()
@@ -137,7 +180,7 @@ func (r *Reconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Resu
 				return ctrl.Result{RequeueAfter: 5 * time.Second}, nil
 			}
 			log.Error(err, "unable get PipelineRun data.")
-			r.recorder.Eventf(pipelineRunCopied, corev1.EventTypeWarning, v1alpha3.RetrieveFailed, "Failed to retrieve running data from Jenkins, and error was %v", err)
+			r.recorder.Eventf(pipelineRunCopied, corev1.EventTypeWarning, v1alpha3.RetrieveFailed, "Failed to retrieve running data from %s, and error was %v", engineType, err)
 			return ctrl.Result{}, err
 		}
 
@@ -145,17 +188,25 @@ func (r *Reconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Resu
 		status := pipelineRunCopied.Status.DeepCopy()
 		pbApplier := pipelineBuildApplier{pipelineBuild}
 		pbApplier.apply(status)
+		// the run aborted by the Stop action is cancelled rather than failed
+		stopped := pipelineRunCopied.GetAction() == v1alpha3.Stop && status.CompletionTime != nil
+		if stopped {
+			markCancelled(status)
+		}
 		// Because the status is a subresource of PipelineRun, we have to update status separately.
 		// See also: https://book-v1.book.kubebuilder.io/basics/status_subresource.html
 		if err := r.updateStatus(ctx, status, req.NamespacedName); err != nil {
 			log.Error(err, "unable to update PipelineRun status.")
 			return ctrl.Result{}, err
 		}
+		if stopped {
+			r.recorder.Eventf(pipelineRunCopied, corev1.EventTypeNormal, v1alpha3.Stopped, "Stopped PipelineRun %s", req.NamespacedName)
+		}
 
-		nodeDetails, err := jHandler.getPipelineNodeDetails(pipelineName, namespaceName, pipelineRunCopied)
+		nodeDetails, err := engine.GetNodeDetails(ctx, pipeline, pipelineRunCopied)
 		if err != nil {
 			log.Error(err, "unable to get PipelineRun nodes detail")
-			r.recorder.Eventf(pipelineRunCopied, corev1.EventTypeWarning, v1alpha3.RetrieveFailed, "Failed to retrieve nodes detail from Jenkins, and error was %v", err)
+			r.recorder.Eventf(pipelineRunCopied, corev1.EventTypeWarning, v1alpha3.RetrieveFailed, "Failed to retrieve nodes detail from %s, and error was %v", engineType, err)
 		}
 		runResultJSON, err := json.Marshal(pipelineBuild)
 		if err != nil {
@@ -168,9 +219,10 @@ func (r *Reconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Resu
 			nodeDetailsJSON = []byte("[]")
 		}
 
-		// store pipelinerun stage to configmap
-		if err = r.storePipelineRunData(string(nodeDetailsJSON), pipelineRunCopied); err != nil {
-			log.Error(err, "unable to store pipeline stages to configmap.")
+		// store pipelinerun stages to the data store
+		if err = pipelinerun.StoreStages(ctx, r.Client, r.S3Client, r.PipelineRunDataStore, pipelineRunCopied,
+			string(nodeDetailsJSON)); err != nil {
+			log.Error(err, "unable to store pipeline stages to the data store.")
 			return ctrl.Result{}, err
 		}
 
@@ -179,6 +231,7 @@ func (r *Reconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Resu
 			pipelineRunCopied.Annotations = make(map[string]string)
 		}
 		pipelineRunCopied.Annotations[v1alpha3.JenkinsPipelineRunStatusAnnoKey] = string(runResultJSON)
+		pipelineRunCopied.Annotations[v1alpha3.PipelineRunDataStoreAnnoKey] = r.PipelineRunDataStore
 		// update labels and annotations
 		if err := r.updateLabelsAndAnnotations(ctx, pipelineRunCopied); err != nil {
 			log.Error(err, "unable to update PipelineRun labels and annotations.")
@@ -187,20 +240,24 @@ func (r *Reconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Resu
 
 		r.recorder.Eventf(pipelineRunCopied, corev1.EventTypeNormal, v1alpha3.Updated, "Updated running data for PipelineRun %s", req.NamespacedName)
 		// until the status is okay
-		// TODO make the RequeueAfter configurable
-		return ctrl.Result{RequeueAfter: 3 * time.Second}, nil
+		return ctrl.Result{RequeueAfter: r.getResyncPeriod()}, nil
 	}
 
-	// get or create JenkinsCore if the PipelineRun has creator annotation
-	jenkinsCore, err := r.getOrCreateJenkinsCore(pipelineRunCopied.GetAnnotations())
-	if err != nil {
-		r.recorder.Eventf(pipelineRunCopied, corev1.EventTypeWarning, v1alpha3.TriggerFailed, "Failed to trigger PipelineRun %s, and error was %v", req.NamespacedName, err)
+	// hold the PipelineRun in the queue until the concurrency limits allow it to run
+	if ok, err := r.admit(ctx, pipeline, pipelineRunCopied); err != nil {
+		log.Error(err, "unable to admit PipelineRun")
 		return ctrl.Result{}, err
+	} else if !ok {
+		return ctrl.Result{RequeueAfter: r.getResyncPeriod()}, nil
+	}
+
+	// first run, or rerun from a stage of the parent PipelineRun
+	var jobRun *job.PipelineRun
+	if stage := pipelineRunCopied.Annotations[v1alpha3.PipelineRunRestartStageAnnoKey]; stage != "" {
+		jobRun, err = r.restart(ctx, engine, pipeline, pipelineRunCopied, stage)
+	} else {
+		jobRun, err = engine.Trigger(ctx, pipeline, pipelineRunCopied)
 	}
-	// create trigger handler
-	triggerHandler := &jenkinsHandler{jenkinsCore}
-	// first run
-	jobRun, err := triggerHandler.triggerJenkinsJob(namespaceName, pipelineName, &pipelineRunCopied.Spec)
 	if err != nil {
 		log.Error(err, "unable to run pipeline", "namespace", namespaceName, "pipeline", pipeline.Name)
 		r.recorder.Eventf(pipelineRunCopied, corev1.EventTypeWarning, v1alpha3.TriggerFailed, "Failed to trigger PipelineRun %s, and error was %v", req.NamespacedName, err)
@@ -210,6 +267,10 @@ func (r *Reconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Resu
 	if exists, err := r.hasSamePipelineRun(jobRun, pipeline); err != nil {
 		return ctrl.Result{}, err
 	} else if exists {
+		if pipeline.GetConcurrencyPolicy() != v1alpha3.AllowConcurrent {
+			// Jenkins merged it into the same pending run, trigger it again once that one completed
+			return ctrl.Result{RequeueAfter: r.getResyncPeriod()}, r.enqueuePipelineRun(ctx, pipelineRunCopied, 1)
+		}
 		// if there still exists the same pending PipelineRun, then give up reconciling
 		if err := r.Delete(ctx, pipelineRunCopied); err != nil {
 			// ignore the not found error here
@@ -219,13 +280,15 @@ func (r *Reconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Resu
 		return ctrl.Result{}, nil
 	}
 
-	log.Info("Triggered a PipelineRun", "runID", jobRun.ID)
+	log.Info("Triggered a PipelineRun", "runID", jobRun.ID, "engine", engineType)
 
 	// set Jenkins run ID
 	if pipelineRunCopied.Annotations == nil {
 		pipelineRunCopied.Annotations = make(map[string]string)
 	}
 	pipelineRunCopied.Annotations[v1alpha3.JenkinsPipelineRunIDAnnoKey] = jobRun.ID
+	pipelineRunCopied.Annotations[v1alpha3.PipelineRunEngineAnnoKey] = string(engineType)
+	pipelineRunCopied.Annotations[v1alpha3.PipelineRunDataStoreAnnoKey] = r.PipelineRunDataStore
 
 	// the Update method only updates fields except subresource: status
 	if err := r.updateLabelsAndAnnotations(ctx, pipelineRunCopied); err != nil {
@@ -243,37 +306,7 @@ func (r *Reconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Resu
 		return ctrl.Result{}, err
 	}
 	r.recorder.Eventf(pipelineRunCopied, corev1.EventTypeNormal, v1alpha3.Started, "Started PipelineRun %s", req.NamespacedName)
-	// requeue after 1 second
-	return ctrl.Result{}, nil
-}
-
-func (r *Reconciler) storePipelineRunData(nodeDetailsJSON string, pipelineRunCopied *v1alpha3.PipelineRun) (err error) {
-	if r.PipelineRunDataStore == "" {
-		if pipelineRunCopied.Annotations == nil {
-			pipelineRunCopied.Annotations = make(map[string]string)
-		}
-		pipelineRunCopied.Annotations[v1alpha3.JenkinsPipelineRunStagesStatusAnnoKey] = nodeDetailsJSON
-
-		// update labels and annotations
-		if err = r.updateLabelsAndAnnotations(r.ctx, pipelineRunCopied); err != nil {
-			r.log.Error(err, "unable to update PipelineRun labels and annotations.")
-		}
-	} else if r.PipelineRunDataStore == "configmap" {
-		var cmStore storeInter.ConfigMapStore
-		if cmStore, err = cmstore.NewConfigMapStore(r.ctx, r.req.NamespacedName, r.Client); err == nil {
-			cmStore.SetStages(nodeDetailsJSON)
-			cmStore.SetOwnerReference(v1.OwnerReference{
-				APIVersion: pipelineRunCopied.APIVersion,
-				Kind:       pipelineRunCopied.Kind,
-				Name:       pipelineRunCopied.Name,
-				UID:        pipelineRunCopied.UID,
-			})
-			err = cmStore.Save()
-		}
-	} else {
-		err = fmt.Errorf("unknown pipelineRun data store type: %s", r.PipelineRunDataStore)
-	}
-	return
+	return ctrl.Result{RequeueAfter: r.getResyncPeriod()}, nil
 }
 
 func (r *Reconciler) hasSamePipelineRun(jobRun *job.PipelineRun, pipeline *v1alpha3.Pipeline) (exists bool, err error) {
@@ -334,11 +367,12 @@ func (r *Reconciler) updateStatus(ctx context.Context, desiredStatus *v1alpha3.P
 		if err != nil {
 			return err
 		}
-		if reflect.DeepEqual(*desiredStatus, prToUpdate.Status) {
+		status := pipelinerun.KeepApprovals(desiredStatus, &prToUpdate.Status)
+		if reflect.DeepEqual(*status, prToUpdate.Status) {
 			return nil
 		}
 		prToUpdate = *prToUpdate.DeepCopy()
-		prToUpdate.Status = *desiredStatus
+		prToUpdate.Status = *status
 		return r.Status().Update(ctx, &prToUpdate)
 	})
 }
@@ -382,12 +416,27 @@ func (r *Reconciler) getOrCreateJenkinsCore(annotations map[string]string) (*cor
 	return jenkinsCore, nil
 }
 
+func (r *Reconciler) getResyncPeriod() time.Duration {
+	if r.ResyncPeriod <= 0 {
+		return defaultResyncPeriod
+	}
+	return r.ResyncPeriod
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *Reconciler) SetupWithManager(mgr ctrl.Manager) error {
 	// the name should obey Kubernetes naming convention: https://kubernetes.io/docs/concepts/overview/working-with-objects/names/
 	r.recorder = mgr.GetEventRecorderFor("pipelinerun-controller")
 	r.log = ctrl.Log.WithName("pipelinerun-controller")
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&v1alpha3.PipelineRun{}).
+		// the changes of status and annotations are made by the controller itself or the events from Jenkins,
+		// reconciling on them causes polling the engine continuously
+		For(&v1alpha3.PipelineRun{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		// the queued PipelineRuns might be able to run once another one completed or was deleted
+		Watches(&source.Kind{Type: &v1alpha3.PipelineRun{}},
+			handler.EnqueueRequestsFromMapFunc(r.getQueuedPipelineRuns),
+			builder.WithPredicates(pipelineRunCompletedPredicate)).
+		// the Jobs are created by the kubernetes engine
+		Owns(&batchv1.Job{}).
 		Complete(r)
 }
@@ -41,6 +41,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	"testing"
+	"time"
 	// nolint
 	// The fakeclient will undeprecated starting with v0.7.0
 	// Reference:
@@ -480,39 +481,37 @@ func TestPipelineRunReconcile(t *testing.T) {
 	}
 }
 
-func TestStorePipelineRunData(t *testing.T) {
-	schema, err := v1alpha3.SchemeBuilder.Register().Build()
-	assert.Nil(t, err)
-	err = v1.SchemeBuilder.AddToScheme(schema)
-	assert.Nil(t, err)
-	err = v1alpha1.SchemeBuilder.AddToScheme(schema)
-	assert.Nil(t, err)
-
-	pipelineRun := v1alpha3.PipelineRun{}
-	pipelineRun.SetName("name")
-	pipelineRun.SetNamespace("ns")
+func TestReconciler_getResyncPeriod(t *testing.T) {
+	r := &Reconciler{}
+	assert.Equal(t, defaultResyncPeriod, r.getResyncPeriod())
 
-	r := &Reconciler{
-		Client:               fake.NewClientBuilder().WithScheme(schema).WithObjects(pipelineRun.DeepCopy()).Build(),
-		log:                  logr.New(log.NullLogSink{}),
-		PipelineRunDataStore: "fake",
-	}
-	assert.NotNil(t, r.storePipelineRunData("", pipelineRun.DeepCopy()))
+	r.ResyncPeriod = time.Minute
+	assert.Equal(t, time.Minute, r.getResyncPeriod())
+}
 
-	r = &Reconciler{
-		Client: fake.NewClientBuilder().WithScheme(schema).WithObjects(pipelineRun.DeepCopy()).Build(),
-		log:    logr.New(log.NullLogSink{}),
-		req: ctrl.Request{
-			NamespacedName: types.NamespacedName{Name: "name", Namespace: "ns"},
-		},
-		PipelineRunDataStore: "configmap",
-	}
-	assert.Nil(t, r.storePipelineRunData("", pipelineRun.DeepCopy()))
+func TestReconciler_updateStatus(t *testing.T) {
+	schema, err := v1alpha3.SchemeBuilder.Register().Build()
+	assert.Nil(t, err)
 
-	r = &Reconciler{
-		Client:               fake.NewClientBuilder().WithScheme(schema).WithObjects(pipelineRun.DeepCopy()).Build(),
-		log:                  logr.New(log.NullLogSink{}),
-		PipelineRunDataStore: "",
+	pr := &v1alpha3.PipelineRun{}
+	pr.SetName("pr")
+	pr.SetNamespace("ns")
+	pr.Status.Phase = v1alpha3.Running
+	approvals := []v1alpha3.Approval{{NodeID: "1", StepID: "2", Approver: "alice", Time: metav1.Now()}}
+	pr.Status.Approvals = approvals
+
+	c := fake.NewClientBuilder().WithScheme(schema).WithObjects(pr.DeepCopy()).Build()
+	r := &Reconciler{Client: c}
+
+	// the desired status comes from a PipelineRun which has no approvals yet
+	desiredStatus := &v1alpha3.PipelineRunStatus{Phase: v1alpha3.Succeeded}
+	assert.Nil(t, r.updateStatus(context.Background(), desiredStatus, client.ObjectKeyFromObject(pr)))
+
+	updated := &v1alpha3.PipelineRun{}
+	assert.Nil(t, c.Get(context.Background(), client.ObjectKeyFromObject(pr), updated))
+	assert.Equal(t, v1alpha3.Succeeded, updated.Status.Phase)
+	if assert.Equal(t, 1, len(updated.Status.Approvals)) {
+		assert.Equal(t, "alice", updated.Status.Approvals[0].Approver)
 	}
-	assert.Nil(t, r.storePipelineRunData("", pipelineRun.DeepCopy()))
+	assert.Empty(t, desiredStatus.Approvals)
 }
@@ -0,0 +1,144 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinerun
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jenkins-zh/jenkins-client/pkg/job"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// agentLostMarkers are the messages in the log of a Jenkins build which indicate that the agent was lost
+var agentLostMarkers = []string{
+	"ChannelClosedException",
+	"ClosedChannelException",
+	"was deleted; cancelling node body",
+	"went offline during the build",
+	"RemovedNodeCause",
+	"Cannot contact ",
+}
+
+// retry creates the next attempt of a failed PipelineRun according to its retry policy.
+// It returns a positive duration if the PipelineRun has to wait for the backoff.
+func (r *Reconciler) retry(ctx context.Context, pr *v1alpha3.PipelineRun) (requeueAfter time.Duration, err error) {
+	policy := pr.Spec.RetryPolicy
+	if policy == nil || pr.Status.Phase != v1alpha3.Failed || pr.GetAttempt() >= policy.MaxAttempts {
+		return
+	}
+
+	// a PipelineRun is retried only once, and it's not necessary to retry it if it has been rerun
+	children := &v1alpha3.PipelineRunList{}
+	if err = r.List(ctx, children, client.InNamespace(pr.Namespace),
+		client.MatchingLabels{v1alpha3.PipelineRunParentLabelKey: pr.Name}); err != nil || len(children.Items) > 0 {
+		return
+	}
+
+	reason := r.getFailureReason(ctx, pr, policy)
+	if !policy.ShouldRetry(reason) {
+		return
+	}
+	if pr.Status.CompletionTime != nil {
+		if wait := time.Until(pr.Status.CompletionTime.Add(policy.GetBackoff(pr.GetAttempt()))); wait > 0 {
+			return wait, nil
+		}
+	}
+
+	// make sure the first attempt is able to be found by the root label
+	if pr.LabelAsRoot() {
+		if err = r.updateLabelsAndAnnotations(ctx, pr); err != nil {
+			return
+		}
+	}
+
+	attempt := pr.NewAttempt()
+	attempt.Annotations[v1alpha3.PipelineRunRetryReasonAnnoKey] = string(reason)
+	if err = r.Create(ctx, attempt); err != nil {
+		r.recorder.Eventf(pr, corev1.EventTypeWarning, v1alpha3.RetryFailed,
+			"Failed to retry PipelineRun %s/%s, and error was %v", pr.Namespace, pr.Name, err)
+		return
+	}
+	r.recorder.Eventf(pr, corev1.EventTypeNormal, v1alpha3.Retried, "Retried PipelineRun %s/%s due to %s, attempt %d of %d",
+		pr.Namespace, attempt.Name, reason, attempt.GetAttempt(), policy.MaxAttempts)
+	return
+}
+
+// getFailureReason finds out why a PipelineRun failed.
+// The log is only checked if the retry policy cares about the agent loss, because it might be huge.
+func (r *Reconciler) getFailureReason(ctx context.Context, pr *v1alpha3.PipelineRun, policy *v1alpha3.RetryPolicy) v1alpha3.FailureReason {
+	for _, reason := range policy.RetryOn {
+		if reason == v1alpha3.FailureReasonAgentLost && r.isAgentLost(ctx, pr) {
+			return v1alpha3.FailureReasonAgentLost
+		}
+	}
+
+	run := &job.PipelineRun{}
+	if err := json.Unmarshal([]byte(pr.Annotations[v1alpha3.JenkinsPipelineRunStatusAnnoKey]), run); err == nil {
+		switch run.Result {
+		case Unstable.String():
+			return v1alpha3.FailureReasonUnstable
+		case Aborted.String():
+			return v1alpha3.FailureReasonAborted
+		}
+	}
+	return v1alpha3.FailureReasonFailure
+}
+
+// isAgentLost checks if the agent of the PipelineRun was lost according to its log
+func (r *Reconciler) isAgentLost(ctx context.Context, pr *v1alpha3.PipelineRun) bool {
+	engine, err := r.getEngine(pr.GetEngineType(), pr)
+	if err != nil {
+		return false
+	}
+	log, err := engine.GetLog(ctx, pr)
+	if err != nil {
+		klog.V(4).Infof("failed to get the log of PipelineRun: %s/%s, error: %v", pr.Namespace, pr.Name, err)
+		return false
+	}
+	return hasAgentLostMarker(log)
+}
+
+// hasAgentLostMarker checks if the log has any messages of the agent loss
+func hasAgentLostMarker(log string) bool {
+	for _, marker := range agentLostMarkers {
+		if strings.Contains(log, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// restart starts the PipelineRun from a stage of its parent
+func (r *Reconciler) restart(ctx context.Context, engine Engine, pipeline *v1alpha3.Pipeline, pr *v1alpha3.PipelineRun,
+	stage string) (*job.PipelineRun, error) {
+	parentName := pr.Labels[v1alpha3.PipelineRunParentLabelKey]
+	if parentName == "" {
+		return nil, fmt.Errorf("unable to restart from stage %s due to not found the parent PipelineRun", stage)
+	}
+	parent := &v1alpha3.PipelineRun{}
+	if err := r.Get(ctx, client.ObjectKey{Namespace: pr.Namespace, Name: parentName}, parent); err != nil {
+		return nil, err
+	}
+	return engine.Restart(ctx, pipeline, pr, parent, stage)
+}
@@ -0,0 +1,232 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinerun
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jenkins-zh/jenkins-client/pkg/core"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestReconciler_retry(t *testing.T) {
+	schema, err := v1alpha3.SchemeBuilder.Register().Build()
+	assert.Nil(t, err)
+
+	newPipelineRun := func(phase v1alpha3.RunPhase, result string, policy *v1alpha3.RetryPolicy) *v1alpha3.PipelineRun {
+		completionTime := metav1.NewTime(time.Now().Add(-time.Minute))
+		return &v1alpha3.PipelineRun{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "ns",
+				Name:      "build-abcde",
+				Labels:    map[string]string{v1alpha3.PipelineNameLabelKey: "build"},
+				Annotations: map[string]string{
+					v1alpha3.JenkinsPipelineRunIDAnnoKey:     "1",
+					v1alpha3.JenkinsPipelineRunStatusAnnoKey: `{"result":"` + result + `"}`,
+					v1alpha3.PipelineRunEngineAnnoKey:        string(v1alpha3.KubernetesEngine),
+				},
+			},
+			Spec: v1alpha3.PipelineRunSpec{
+				PipelineRef: &v1.ObjectReference{Name: "build"},
+				RetryPolicy: policy,
+			},
+			Status: v1alpha3.PipelineRunStatus{
+				Phase:          phase,
+				CompletionTime: &completionTime,
+			},
+		}
+	}
+	policy := &v1alpha3.RetryPolicy{MaxAttempts: 3}
+
+	lastAttempt := newPipelineRun(v1alpha3.Failed, "FAILURE", policy)
+	lastAttempt.Labels[v1alpha3.PipelineRunAttemptLabelKey] = "3"
+	retried := newPipelineRun(v1alpha3.Failed, "FAILURE", policy)
+	child := retried.NewAttempt()
+	child.Name = "build-abcde-fghij"
+
+	tests := []struct {
+		name             string
+		pr               *v1alpha3.PipelineRun
+		objects          []client.Object
+		wantRequeueAfter bool
+		wantRetried      bool
+		wantReason       v1alpha3.FailureReason
+	}{{
+		name: "no retry policy",
+		pr:   newPipelineRun(v1alpha3.Failed, "FAILURE", nil),
+	}, {
+		name: "succeeded",
+		pr:   newPipelineRun(v1alpha3.Succeeded, "SUCCESS", policy),
+	}, {
+		name: "cancelled",
+		pr:   newPipelineRun(v1alpha3.Cancelled, "ABORTED", policy),
+	}, {
+		name: "reached the max attempts",
+		pr:   lastAttempt,
+	}, {
+		name:    "retried already",
+		pr:      retried,
+		objects: []client.Object{child},
+	}, {
+		name: "not a failure reason to retry",
+		pr: newPipelineRun(v1alpha3.Failed, "FAILURE", &v1alpha3.RetryPolicy{
+			MaxAttempts: 3,
+			RetryOn:     []v1alpha3.FailureReason{v1alpha3.FailureReasonUnstable, v1alpha3.FailureReasonAgentLost},
+		}),
+	}, {
+		name: "wait for the backoff",
+		pr: newPipelineRun(v1alpha3.Failed, "FAILURE", &v1alpha3.RetryPolicy{
+			MaxAttempts: 3,
+			Backoff:     &metav1.Duration{Duration: time.Hour},
+		}),
+		wantRequeueAfter: true,
+	}, {
+		name:        "retry any failures",
+		pr:          newPipelineRun(v1alpha3.Failed, "FAILURE", policy),
+		wantRetried: true,
+		wantReason:  v1alpha3.FailureReasonFailure,
+	}, {
+		name: "retry an unstable PipelineRun",
+		pr: newPipelineRun(v1alpha3.Failed, "UNSTABLE", &v1alpha3.RetryPolicy{
+			MaxAttempts: 3,
+			Backoff:     &metav1.Duration{Duration: time.Second},
+			RetryOn:     []v1alpha3.FailureReason{v1alpha3.FailureReasonUnstable},
+		}),
+		wantRetried: true,
+		wantReason:  v1alpha3.FailureReasonUnstable,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objects := append([]client.Object{tt.pr.DeepCopy()}, tt.objects...)
+			r := &Reconciler{
+				Client:   fakeclient.NewClientBuilder().WithScheme(schema).WithObjects(objects...).Build(),
+				recorder: record.NewFakeRecorder(10),
+			}
+			pr := tt.pr.DeepCopy()
+			requeueAfter, err := r.retry(context.Background(), pr)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.wantRequeueAfter, requeueAfter > 0)
+
+			children := &v1alpha3.PipelineRunList{}
+			assert.Nil(t, r.List(context.Background(), children,
+				client.MatchingLabels{v1alpha3.PipelineRunParentLabelKey: tt.pr.Name}))
+			if !tt.wantRetried {
+				assert.Equal(t, len(tt.objects), len(children.Items))
+				return
+			}
+			if assert.Equal(t, 1, len(children.Items)) {
+				attempt := children.Items[0]
+				assert.Equal(t, "build-abcde", attempt.Labels[v1alpha3.PipelineRunRootLabelKey])
+				assert.Equal(t, "2", attempt.Labels[v1alpha3.PipelineRunAttemptLabelKey])
+				assert.Equal(t, string(tt.wantReason), attempt.Annotations[v1alpha3.PipelineRunRetryReasonAnnoKey])
+				assert.False(t, attempt.HasStarted())
+			}
+
+			root := &v1alpha3.PipelineRun{}
+			assert.Nil(t, r.Get(context.Background(), client.ObjectKeyFromObject(tt.pr), root))
+			assert.Equal(t, "build-abcde", root.Labels[v1alpha3.PipelineRunRootLabelKey])
+			assert.Equal(t, "1", root.Labels[v1alpha3.PipelineRunAttemptLabelKey])
+		})
+	}
+}
+
+func Test_hasAgentLostMarker(t *testing.T) {
+	assert.False(t, hasAgentLostMarker(""))
+	assert.False(t, hasAgentLostMarker("ERROR: script returned exit code 1"))
+	assert.True(t, hasAgentLostMarker("Agent base-abcde was deleted; cancelling node body"))
+	assert.True(t, hasAgentLostMarker("hudson.remoting.ChannelClosedException: Channel \"unknown\": Remote call failed"))
+}
+
+func TestReconciler_restart(t *testing.T) {
+	schema, err := v1alpha3.SchemeBuilder.Register().Build()
+	assert.Nil(t, err)
+
+	parent := &v1alpha3.PipelineRun{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "build-abcde"}}
+	r := &Reconciler{Client: fakeclient.NewClientBuilder().WithScheme(schema).WithObjects(parent).Build()}
+	pipeline := &v1alpha3.Pipeline{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "build"}}
+
+	engine := &fakeEngine{}
+	pr := parent.NewAttempt()
+	pr.Name = "build-abcde-fghij"
+	jobRun, err := r.restart(context.Background(), engine, pipeline, pr, "Deploy")
+	assert.Nil(t, err)
+	assert.Equal(t, "2", jobRun.ID)
+	assert.Equal(t, []string{"restart"}, engine.actions)
+
+	// without the parent
+	delete(pr.Labels, v1alpha3.PipelineRunParentLabelKey)
+	_, err = r.restart(context.Background(), engine, pipeline, pr, "Deploy")
+	assert.NotNil(t, err)
+
+	// the parent is not found
+	pr.Labels[v1alpha3.PipelineRunParentLabelKey] = "fake"
+	_, err = r.restart(context.Background(), engine, pipeline, pr, "Deploy")
+	assert.NotNil(t, err)
+}
+
+func TestJenkinsHandler_Restart(t *testing.T) {
+	var restartRequested bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/blue/rest/organizations/jenkins/pipelines/ns/pipelines/build/runs/1/nodes/":
+			_, _ = w.Write([]byte(`[{"id":"6","displayName":"Build","restartable":true},` +
+				`{"id":"12","displayName":"Test","restartable":false},` +
+				`{"id":"18","displayName":"Deploy","restartable":true}]`))
+		case "/blue/rest/organizations/jenkins/pipelines/ns/pipelines/build/runs/1/nodes/18/restart/":
+			restartRequested = r.Method == http.MethodPost
+			_, _ = w.Write([]byte(`{"_class":"io.jenkins.blueocean.rest.impl.pipeline.PipelineRunImpl",` +
+				`"id":"3","organization":"jenkins","pipeline":"build","result":"UNKNOWN","state":"QUEUED","type":"WorkflowRun"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	handler := &jenkinsHandler{&core.JenkinsCore{URL: server.URL}}
+	pipeline := &v1alpha3.Pipeline{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "build"}}
+	parent := &v1alpha3.PipelineRun{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   "ns",
+			Name:        "build-abcde",
+			Annotations: map[string]string{v1alpha3.JenkinsPipelineRunIDAnnoKey: "1"},
+		},
+		Spec: v1alpha3.PipelineRunSpec{PipelineRef: &v1.ObjectReference{Name: "build"}},
+	}
+	pr := parent.NewAttempt()
+
+	jobRun, err := handler.Restart(context.Background(), pipeline, pr, parent, "Deploy")
+	assert.Nil(t, err)
+	assert.True(t, restartRequested)
+	assert.Equal(t, "3", jobRun.ID)
+
+	_, err = handler.Restart(context.Background(), pipeline, pr, parent, "Test")
+	assert.NotNil(t, err)
+	_, err = handler.Restart(context.Background(), pipeline, pr, parent, "Release")
+	assert.NotNil(t, err)
+	_, err = handler.Restart(context.Background(), pipeline, pr, &v1alpha3.PipelineRun{}, "Deploy")
+	assert.NotNil(t, err)
+}
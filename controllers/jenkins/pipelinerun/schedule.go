@@ -0,0 +1,337 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinerun
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/retry"
+	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
+	"kubesphere.io/devops/pkg/kapis/devops/v1alpha3/pipelinerun"
+	"kubesphere.io/devops/pkg/utils/cronutil"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+// ScheduleReconciler creates the PipelineRuns of the PipelineSchedules at the scheduled times.
+// The PipelineRuns are handled by the PipelineRun controller, so the schedules work with any engine.
+type ScheduleReconciler struct {
+	client.Client
+
+	log      logr.Logger
+	recorder record.EventRecorder
+	now      func() time.Time
+}
+
+//+kubebuilder:rbac:groups=devops.kubesphere.io,resources=pipelineschedules,verbs=get;list;watch
+//+kubebuilder:rbac:groups=devops.kubesphere.io,resources=pipelineschedules/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=devops.kubesphere.io,resources=pipelines,verbs=get;list;watch
+//+kubebuilder:rbac:groups=devops.kubesphere.io,resources=pipelineruns,verbs=get;list;watch;create;update;delete
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+// Reconcile creates a PipelineRun if it's time to run, then waits until the next scheduled time
+func (r *ScheduleReconciler) Reconcile(ctx context.Context, req ctrl.Request) (result ctrl.Result, err error) {
+	log := r.log.WithValues("PipelineSchedule", req.NamespacedName)
+
+	schedule := &v1alpha3.PipelineSchedule{}
+	if err = r.Get(ctx, req.NamespacedName, schedule); err != nil {
+		err = client.IgnoreNotFound(err)
+		return
+	}
+	if !schedule.DeletionTimestamp.IsZero() {
+		return
+	}
+
+	pipelineRuns := &v1alpha3.PipelineRunList{}
+	if err = r.List(ctx, pipelineRuns, client.InNamespace(schedule.Namespace),
+		client.MatchingLabels{v1alpha3.PipelineScheduleLabelKey: schedule.Name}); err != nil {
+		return
+	}
+	active := getActivePipelineRuns(pipelineRuns.Items)
+	if err = r.cleanupHistory(ctx, schedule, pipelineRuns.Items); err != nil {
+		log.Error(err, "unable to clean up the history")
+		return
+	}
+
+	status := schedule.Status.DeepCopy()
+	status.Active = getObjectReferences(active)
+	defer func() {
+		if updateErr := r.updateStatus(ctx, status, req.NamespacedName); updateErr != nil {
+			log.Error(updateErr, "unable to update the status")
+			if err == nil {
+				err = updateErr
+			}
+		}
+	}()
+
+	cron, location, err := parseSchedule(schedule)
+	if err != nil {
+		// wait for the schedule to be fixed
+		r.recorder.Eventf(schedule, corev1.EventTypeWarning, v1alpha3.InvalidSchedule, err.Error())
+		status.NextScheduleTime = nil
+		err = nil
+		return
+	}
+	if schedule.Spec.Suspend {
+		status.NextScheduleTime = nil
+		return
+	}
+
+	now := r.getNow().In(location)
+	scheduledTime, missed := getScheduledTime(schedule, cron, now)
+	if missed > 1 {
+		r.recorder.Eventf(schedule, corev1.EventTypeWarning, v1alpha3.MissedSchedule,
+			"Missed %d scheduled times, only the latest one at %s runs", missed, scheduledTime.Format(time.RFC3339))
+	}
+	if !scheduledTime.IsZero() {
+		var pr *v1alpha3.PipelineRun
+		if pr, err = r.run(ctx, schedule, scheduledTime, active); err != nil {
+			log.Error(err, "unable to run the schedule", "scheduledTime", scheduledTime)
+			return
+		}
+		if pr != nil {
+			status.Active = append(status.Active, getObjectReferences([]*v1alpha3.PipelineRun{pr})...)
+		}
+		status.LastScheduleTime = &metav1.Time{Time: scheduledTime}
+	}
+
+	if next := cron.Next(now); !next.IsZero() {
+		status.NextScheduleTime = &metav1.Time{Time: next}
+		// wait a bit more in case of the clock skew
+		result.RequeueAfter = next.Sub(now) + time.Second
+	} else {
+		status.NextScheduleTime = nil
+	}
+	return
+}
+
+// parseSchedule parses the cron and the time zone of a PipelineSchedule. The values of H are hashed from
+// the namespace and name, so they never change.
+func parseSchedule(schedule *v1alpha3.PipelineSchedule) (cron *cronutil.Schedule, location *time.Location, err error) {
+	if location, err = time.LoadLocation(schedule.Spec.TimeZone); err != nil {
+		err = fmt.Errorf("invalid time zone %q: %v", schedule.Spec.TimeZone, err)
+		return
+	}
+	cron, err = cronutil.Parse(schedule.Spec.Cron, schedule.Namespace+"/"+schedule.Name)
+	return
+}
+
+// getScheduledTime returns the latest scheduled time which has not run, and how many scheduled times were missed.
+// The scheduled times before the starting deadline are never caught up.
+func getScheduledTime(schedule *v1alpha3.PipelineSchedule, cron *cronutil.Schedule, now time.Time) (
+	scheduledTime time.Time, missed int) {
+	earliest := schedule.CreationTimestamp.Time
+	if schedule.Status.LastScheduleTime != nil {
+		earliest = schedule.Status.LastScheduleTime.Time
+	}
+	if deadline := schedule.Spec.StartingDeadlineSeconds; deadline != nil {
+		if start := now.Add(-time.Duration(*deadline) * time.Second); start.After(earliest) {
+			// it's fine to run at the deadline exactly
+			earliest = start.Add(-time.Nanosecond)
+		}
+	}
+
+	for t := cron.Next(earliest.In(now.Location())); !t.IsZero() && !t.After(now); t = cron.Next(t) {
+		scheduledTime = t
+		missed++
+	}
+	return
+}
+
+// run creates a PipelineRun for the scheduled time according to the concurrency policy.
+// It returns nil if the scheduled time was skipped.
+func (r *ScheduleReconciler) run(ctx context.Context, schedule *v1alpha3.PipelineSchedule, scheduledTime time.Time,
+	active []*v1alpha3.PipelineRun) (pr *v1alpha3.PipelineRun, err error) {
+	if len(active) > 0 {
+		switch schedule.GetConcurrencyPolicy() {
+		case v1alpha3.ForbidConcurrent:
+			r.recorder.Eventf(schedule, corev1.EventTypeNormal, v1alpha3.ScheduleSkipped,
+				"Skipped the scheduled time %s, because PipelineRun %s is still running",
+				scheduledTime.Format(time.RFC3339), active[0].Name)
+			return
+		case v1alpha3.ReplaceConcurrent:
+			for _, item := range active {
+				if err = stopPipelineRun(ctx, r.Client, item); err != nil {
+					return
+				}
+			}
+		}
+	}
+
+	pipeline := &v1alpha3.Pipeline{}
+	if err = r.Get(ctx, types.NamespacedName{Namespace: schedule.Namespace, Name: schedule.Spec.PipelineRef.Name},
+		pipeline); err != nil {
+		if apierrors.IsNotFound(err) {
+			// skip this time, the next one might be fine
+			r.recorder.Eventf(schedule, corev1.EventTypeWarning, v1alpha3.ScheduleFailed,
+				"Skipped the scheduled time %s, because Pipeline %s was not found",
+				scheduledTime.Format(time.RFC3339), schedule.Spec.PipelineRef.Name)
+			err = nil
+		}
+		return
+	}
+
+	var scm *v1alpha3.SCM
+	if scm, err = pipelinerun.CreateScm(&pipeline.Spec, schedule.Spec.Branch); err != nil {
+		r.recorder.Eventf(schedule, corev1.EventTypeWarning, v1alpha3.ScheduleFailed,
+			"Skipped the scheduled time %s, error: %v", scheduledTime.Format(time.RFC3339), err)
+		err = nil
+		return
+	}
+	// the kind is required by the owner reference
+	pipeline.SetGroupVersionKind(v1alpha3.GroupVersion.WithKind(v1alpha3.ResourceKindPipeline))
+	pr = pipelinerun.CreateBarePipelineRun(pipeline, schedule.Spec.Parameters, scm)
+	// the name is determined by the scheduled time, so a scheduled time never runs twice
+	pr.GenerateName = ""
+	pr.Name = fmt.Sprintf("%s-%d", schedule.Name, scheduledTime.Unix()/60)
+	pr.Labels[v1alpha3.PipelineScheduleLabelKey] = schedule.Name
+	pr.Annotations[v1alpha3.PipelineRunScheduledTimeAnnoKey] = scheduledTime.Format(time.RFC3339)
+	if err = r.Create(ctx, pr); err != nil {
+		if apierrors.IsAlreadyExists(err) {
+			// it was created by the last reconciling, it's in the active ones if it's running
+			pr, err = nil, nil
+		} else {
+			r.recorder.Eventf(schedule, corev1.EventTypeWarning, v1alpha3.ScheduleFailed,
+				"Failed to create PipelineRun %s, error: %v", pr.Name, err)
+		}
+		return
+	}
+	r.recorder.Eventf(schedule, corev1.EventTypeNormal, v1alpha3.Scheduled, "Created PipelineRun %s for the scheduled time %s",
+		pr.Name, scheduledTime.Format(time.RFC3339))
+	return
+}
+
+// cleanupHistory deletes the completed PipelineRuns which exceed the history limits, the oldest ones go first.
+// The pinned PipelineRuns are always kept, and not counted.
+func (r *ScheduleReconciler) cleanupHistory(ctx context.Context, schedule *v1alpha3.PipelineSchedule,
+	pipelineRuns []v1alpha3.PipelineRun) (err error) {
+	var succeeded, failed []*v1alpha3.PipelineRun
+	for i := range pipelineRuns {
+		pr := &pipelineRuns[i]
+		if !pr.HasCompleted() || pr.IsPinned() || !pr.DeletionTimestamp.IsZero() {
+			continue
+		}
+		if pr.Status.Phase == v1alpha3.Succeeded {
+			succeeded = append(succeeded, pr)
+		} else {
+			failed = append(failed, pr)
+		}
+	}
+
+	for _, history := range []struct {
+		pipelineRuns []*v1alpha3.PipelineRun
+		limit        int
+	}{{succeeded, schedule.GetSuccessfulRunsHistoryLimit()}, {failed, schedule.GetFailedRunsHistoryLimit()}} {
+		prs := history.pipelineRuns
+		if len(prs) <= history.limit {
+			continue
+		}
+		// the newest ones come first
+		sort.SliceStable(prs, func(i, j int) bool {
+			return prs[j].Status.CompletionTime.Before(prs[i].Status.CompletionTime)
+		})
+		for _, pr := range prs[history.limit:] {
+			if err = client.IgnoreNotFound(r.Delete(ctx, pr)); err != nil {
+				return
+			}
+		}
+	}
+	return
+}
+
+func (r *ScheduleReconciler) updateStatus(ctx context.Context, status *v1alpha3.PipelineScheduleStatus,
+	key client.ObjectKey) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		schedule := &v1alpha3.PipelineSchedule{}
+		if err := r.Get(ctx, key, schedule); err != nil {
+			return client.IgnoreNotFound(err)
+		}
+		if reflect.DeepEqual(schedule.Status, *status) {
+			return nil
+		}
+		schedule.Status = *status
+		return r.Status().Update(ctx, schedule)
+	})
+}
+
+func (r *ScheduleReconciler) getNow() time.Time {
+	if r.now == nil {
+		return time.Now()
+	}
+	return r.now()
+}
+
+// getActivePipelineRuns returns the PipelineRuns which have not completed
+func getActivePipelineRuns(pipelineRuns []v1alpha3.PipelineRun) (active []*v1alpha3.PipelineRun) {
+	for i := range pipelineRuns {
+		if !pipelineRuns[i].HasCompleted() && pipelineRuns[i].DeletionTimestamp.IsZero() {
+			active = append(active, &pipelineRuns[i])
+		}
+	}
+	return
+}
+
+func getObjectReferences(pipelineRuns []*v1alpha3.PipelineRun) (refs []corev1.ObjectReference) {
+	for _, pr := range pipelineRuns {
+		refs = append(refs, corev1.ObjectReference{
+			APIVersion: v1alpha3.GroupVersion.String(),
+			Kind:       "PipelineRun",
+			Namespace:  pr.Namespace,
+			Name:       pr.Name,
+			UID:        pr.UID,
+		})
+	}
+	return
+}
+
+// getScheduleOfRun maps a PipelineRun to the PipelineSchedule which created it
+func getScheduleOfRun(obj client.Object) (requests []reconcile.Request) {
+	if scheduleName := obj.GetLabels()[v1alpha3.PipelineScheduleLabelKey]; scheduleName != "" {
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{
+			Namespace: obj.GetNamespace(),
+			Name:      scheduleName,
+		}})
+	}
+	return
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ScheduleReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	r.recorder = mgr.GetEventRecorderFor("pipelineschedule-controller")
+	r.log = ctrl.Log.WithName("pipelineschedule-controller")
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&v1alpha3.PipelineSchedule{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		// update the active PipelineRuns and the history once a PipelineRun completed or was deleted
+		Watches(&source.Kind{Type: &v1alpha3.PipelineRun{}}, handler.EnqueueRequestsFromMapFunc(getScheduleOfRun),
+			builder.WithPredicates(pipelineRunCompletedPredicate)).
+		Complete(r)
+}
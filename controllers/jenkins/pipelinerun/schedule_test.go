@@ -0,0 +1,312 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinerun
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func TestScheduleReconciler_Reconcile(t *testing.T) {
+	schema, err := v1alpha3.SchemeBuilder.Register().Build()
+	assert.Nil(t, err)
+
+	base := time.Date(2022, 8, 1, 10, 0, 0, 0, time.UTC)
+	at := func(minutes, seconds int) time.Time {
+		return base.Add(time.Duration(minutes)*time.Minute + time.Duration(seconds)*time.Second)
+	}
+	newSchedule := func(modify func(spec *v1alpha3.PipelineScheduleSpec, status *v1alpha3.PipelineScheduleStatus)) *v1alpha3.PipelineSchedule {
+		schedule := &v1alpha3.PipelineSchedule{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "nightly", CreationTimestamp: metav1.NewTime(base)},
+			Spec: v1alpha3.PipelineScheduleSpec{
+				PipelineRef: &v1.ObjectReference{Name: "build"},
+				Cron:        "*/5 * * * *",
+				Parameters:  []v1alpha3.Parameter{{Name: "version", Value: "nightly"}},
+			},
+		}
+		if modify != nil {
+			modify(&schedule.Spec, &schedule.Status)
+		}
+		return schedule
+	}
+	pipeline := &v1alpha3.Pipeline{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "build"},
+		Spec:       v1alpha3.PipelineSpec{Type: v1alpha3.NoScmPipelineType},
+	}
+	multiBranchPipeline := &v1alpha3.Pipeline{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "build"},
+		Spec:       v1alpha3.PipelineSpec{Type: v1alpha3.MultiBranchPipelineType},
+	}
+	running := &v1alpha3.PipelineRun{ObjectMeta: metav1.ObjectMeta{
+		Namespace: "ns",
+		Name:      "nightly-running",
+		Labels:    map[string]string{v1alpha3.PipelineScheduleLabelKey: "nightly"},
+	}}
+	deadline := int64(60)
+	nameOf := func(scheduledTime time.Time) string {
+		return fmt.Sprintf("nightly-%d", scheduledTime.Unix()/60)
+	}
+
+	tests := []struct {
+		name         string
+		schedule     *v1alpha3.PipelineSchedule
+		objects      []client.Object
+		now          time.Time
+		wantRuns     []string
+		wantStopped  []string
+		wantLast     time.Time
+		wantNext     time.Time
+		wantRequeue  bool
+		wantBranch   string
+		wantActive   int
+		wantEventsIn string
+	}{{
+		name:        "not the time yet",
+		schedule:    newSchedule(nil),
+		objects:     []client.Object{pipeline},
+		now:         at(3, 0),
+		wantNext:    at(5, 0),
+		wantRequeue: true,
+	}, {
+		name:        "run at the scheduled time",
+		schedule:    newSchedule(nil),
+		objects:     []client.Object{pipeline},
+		now:         at(5, 1),
+		wantRuns:    []string{nameOf(at(5, 0))},
+		wantLast:    at(5, 0),
+		wantNext:    at(10, 0),
+		wantRequeue: true,
+		wantActive:  1,
+	}, {
+		name: "catch up on the latest missed time",
+		schedule: newSchedule(func(_ *v1alpha3.PipelineScheduleSpec, status *v1alpha3.PipelineScheduleStatus) {
+			status.LastScheduleTime = &metav1.Time{Time: at(-60, 0)}
+		}),
+		objects:      []client.Object{pipeline},
+		now:          at(7, 0),
+		wantRuns:     []string{nameOf(at(5, 0))},
+		wantLast:     at(5, 0),
+		wantNext:     at(10, 0),
+		wantRequeue:  true,
+		wantActive:   1,
+		wantEventsIn: v1alpha3.MissedSchedule,
+	}, {
+		name: "missed the starting deadline",
+		schedule: newSchedule(func(spec *v1alpha3.PipelineScheduleSpec, status *v1alpha3.PipelineScheduleStatus) {
+			spec.StartingDeadlineSeconds = &deadline
+			status.LastScheduleTime = &metav1.Time{Time: at(-60, 0)}
+		}),
+		objects:     []client.Object{pipeline},
+		now:         at(7, 0),
+		wantLast:    at(-60, 0),
+		wantNext:    at(10, 0),
+		wantRequeue: true,
+	}, {
+		name: "in a time zone",
+		schedule: newSchedule(func(spec *v1alpha3.PipelineScheduleSpec, _ *v1alpha3.PipelineScheduleStatus) {
+			spec.Cron = "3 18 * * *"
+			spec.TimeZone = "Asia/Shanghai"
+		}),
+		objects:     []client.Object{pipeline},
+		now:         at(4, 0),
+		wantRuns:    []string{nameOf(at(3, 0))},
+		wantLast:    at(3, 0),
+		wantNext:    at(24*60+3, 0),
+		wantRequeue: true,
+		wantActive:  1,
+	}, {
+		name: "suspended",
+		schedule: newSchedule(func(spec *v1alpha3.PipelineScheduleSpec, _ *v1alpha3.PipelineScheduleStatus) {
+			spec.Suspend = true
+		}),
+		objects: []client.Object{pipeline},
+		now:     at(5, 1),
+	}, {
+		name: "invalid time zone",
+		schedule: newSchedule(func(spec *v1alpha3.PipelineScheduleSpec, _ *v1alpha3.PipelineScheduleStatus) {
+			spec.TimeZone = "Mars/Olympus"
+		}),
+		objects:      []client.Object{pipeline},
+		now:          at(5, 1),
+		wantEventsIn: v1alpha3.InvalidSchedule,
+	}, {
+		name:         "the Pipeline was not found",
+		schedule:     newSchedule(nil),
+		now:          at(5, 1),
+		wantLast:     at(5, 0),
+		wantNext:     at(10, 0),
+		wantRequeue:  true,
+		wantEventsIn: v1alpha3.ScheduleFailed,
+	}, {
+		name: "run a branch of a multi-branch Pipeline",
+		schedule: newSchedule(func(spec *v1alpha3.PipelineScheduleSpec, _ *v1alpha3.PipelineScheduleStatus) {
+			spec.Branch = "main"
+		}),
+		objects:     []client.Object{multiBranchPipeline},
+		now:         at(5, 1),
+		wantRuns:    []string{nameOf(at(5, 0))},
+		wantLast:    at(5, 0),
+		wantNext:    at(10, 0),
+		wantRequeue: true,
+		wantBranch:  "main",
+		wantActive:  1,
+	}, {
+		name: "forbid a concurrent run",
+		schedule: newSchedule(func(spec *v1alpha3.PipelineScheduleSpec, _ *v1alpha3.PipelineScheduleStatus) {
+			spec.ConcurrencyPolicy = v1alpha3.ForbidConcurrent
+		}),
+		objects:      []client.Object{pipeline, running.DeepCopy()},
+		now:          at(5, 1),
+		wantRuns:     []string{running.Name},
+		wantLast:     at(5, 0),
+		wantNext:     at(10, 0),
+		wantRequeue:  true,
+		wantActive:   1,
+		wantEventsIn: v1alpha3.ScheduleSkipped,
+	}, {
+		name: "replace the running one",
+		schedule: newSchedule(func(spec *v1alpha3.PipelineScheduleSpec, _ *v1alpha3.PipelineScheduleStatus) {
+			spec.ConcurrencyPolicy = v1alpha3.ReplaceConcurrent
+		}),
+		objects:     []client.Object{pipeline, running.DeepCopy()},
+		now:         at(5, 1),
+		wantRuns:    []string{running.Name, nameOf(at(5, 0))},
+		wantStopped: []string{running.Name},
+		wantLast:    at(5, 0),
+		wantNext:    at(10, 0),
+		wantRequeue: true,
+		wantActive:  2,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := record.NewFakeRecorder(10)
+			r := &ScheduleReconciler{
+				Client:   fake.NewClientBuilder().WithScheme(schema).WithObjects(append(tt.objects, tt.schedule)...).Build(),
+				log:      logr.New(log.NullLogSink{}),
+				recorder: recorder,
+				now: func() time.Time {
+					return tt.now
+				},
+			}
+			key := client.ObjectKeyFromObject(tt.schedule)
+			result, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: key})
+			assert.Nil(t, err)
+			assert.Equal(t, tt.wantRequeue, result.RequeueAfter > 0)
+
+			prs := &v1alpha3.PipelineRunList{}
+			assert.Nil(t, r.List(context.Background(), prs))
+			var names, stopped []string
+			for _, pr := range prs.Items {
+				names = append(names, pr.Name)
+				if pr.GetAction() == v1alpha3.Stop {
+					stopped = append(stopped, pr.Name)
+				}
+				if pr.Name == running.Name {
+					continue
+				}
+				assert.Equal(t, "nightly", pr.Labels[v1alpha3.PipelineScheduleLabelKey])
+				assert.Equal(t, "build", pr.Labels[v1alpha3.PipelineNameLabelKey])
+				assert.Equal(t, tt.schedule.Spec.Parameters, pr.Spec.Parameters)
+				scheduledTime, err := time.Parse(time.RFC3339, pr.Annotations[v1alpha3.PipelineRunScheduledTimeAnnoKey])
+				assert.Nil(t, err)
+				assert.True(t, tt.wantLast.Equal(scheduledTime), scheduledTime)
+				if tt.wantBranch != "" && assert.NotNil(t, pr.Spec.SCM) {
+					assert.Equal(t, tt.wantBranch, pr.Spec.SCM.RefName)
+				}
+			}
+			assert.ElementsMatch(t, tt.wantRuns, names)
+			assert.ElementsMatch(t, tt.wantStopped, stopped)
+
+			schedule := &v1alpha3.PipelineSchedule{}
+			assert.Nil(t, r.Get(context.Background(), key, schedule))
+			assert.Equal(t, tt.wantActive, len(schedule.Status.Active))
+			if tt.wantLast.IsZero() {
+				assert.Nil(t, schedule.Status.LastScheduleTime)
+			} else if assert.NotNil(t, schedule.Status.LastScheduleTime) {
+				assert.True(t, tt.wantLast.Equal(schedule.Status.LastScheduleTime.Time), schedule.Status.LastScheduleTime)
+			}
+			if tt.wantNext.IsZero() {
+				assert.Nil(t, schedule.Status.NextScheduleTime)
+			} else if assert.NotNil(t, schedule.Status.NextScheduleTime) {
+				assert.True(t, tt.wantNext.Equal(schedule.Status.NextScheduleTime.Time), schedule.Status.NextScheduleTime)
+			}
+
+			var events string
+			for len(recorder.Events) > 0 {
+				events += <-recorder.Events + "\n"
+			}
+			if tt.wantEventsIn != "" {
+				assert.Contains(t, events, tt.wantEventsIn)
+			}
+		})
+	}
+}
+
+func TestScheduleReconciler_cleanupHistory(t *testing.T) {
+	schema, err := v1alpha3.SchemeBuilder.Register().Build()
+	assert.Nil(t, err)
+
+	newPipelineRun := func(name string, phase v1alpha3.RunPhase, minutesAgo int) *v1alpha3.PipelineRun {
+		pr := &v1alpha3.PipelineRun{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: name},
+			Status:     v1alpha3.PipelineRunStatus{Phase: phase},
+		}
+		if phase != v1alpha3.Running {
+			completionTime := metav1.NewTime(time.Now().Add(-time.Duration(minutesAgo) * time.Minute))
+			pr.Status.CompletionTime = &completionTime
+		}
+		return pr
+	}
+	pinned := newPipelineRun("pinned", v1alpha3.Failed, 60)
+	pinned.Annotations = map[string]string{v1alpha3.PipelineRunPinnedAnnoKey: "true"}
+	objects := []client.Object{
+		newPipelineRun("succeeded-1", v1alpha3.Succeeded, 50),
+		newPipelineRun("succeeded-2", v1alpha3.Succeeded, 40),
+		newPipelineRun("succeeded-3", v1alpha3.Succeeded, 30),
+		newPipelineRun("failed-1", v1alpha3.Failed, 25),
+		newPipelineRun("cancelled-1", v1alpha3.Cancelled, 20),
+		newPipelineRun("running", v1alpha3.Running, 0),
+		pinned,
+	}
+	limit := int32(2)
+	schedule := &v1alpha3.PipelineSchedule{Spec: v1alpha3.PipelineScheduleSpec{SuccessfulRunsHistoryLimit: &limit}}
+
+	r := &ScheduleReconciler{Client: fake.NewClientBuilder().WithScheme(schema).WithObjects(objects...).Build()}
+	prs := &v1alpha3.PipelineRunList{}
+	assert.Nil(t, r.List(context.Background(), prs))
+	assert.Nil(t, r.cleanupHistory(context.Background(), schedule, prs.Items))
+
+	assert.Nil(t, r.List(context.Background(), prs))
+	var names []string
+	for _, pr := range prs.Items {
+		names = append(names, pr.Name)
+	}
+	assert.ElementsMatch(t, []string{"succeeded-2", "succeeded-3", "cancelled-1", "running", "pinned"}, names)
+}
@@ -0,0 +1,293 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinerun
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"strings"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/retry"
+	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
+	"kubesphere.io/devops/pkg/kapis/devops/v1alpha3/pipelinerun"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// TriggerReconciler creates the PipelineRuns of the downstream Pipelines once an upstream PipelineRun completed.
+// The upstream and downstream PipelineRuns are linked by the annotations, so the UI is able to draw the chain.
+type TriggerReconciler struct {
+	client.Client
+
+	log      logr.Logger
+	recorder record.EventRecorder
+}
+
+//+kubebuilder:rbac:groups=devops.kubesphere.io,resources=pipelines,verbs=get;list;watch
+//+kubebuilder:rbac:groups=devops.kubesphere.io,resources=pipelineruns,verbs=get;list;watch;create;update
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+// Reconcile triggers the downstream Pipelines of a completed PipelineRun, every downstream Pipeline is triggered once
+func (r *TriggerReconciler) Reconcile(ctx context.Context, req ctrl.Request) (result ctrl.Result, err error) {
+	log := r.log.WithValues("PipelineRun", req.NamespacedName)
+
+	upstream := &v1alpha3.PipelineRun{}
+	if err = r.Get(ctx, req.NamespacedName, upstream); err != nil {
+		err = client.IgnoreNotFound(err)
+		return
+	}
+	upstreamPipelineName := upstream.GetPipelineName()
+	if !upstream.HasCompleted() || !upstream.DeletionTimestamp.IsZero() || upstreamPipelineName == "" {
+		return
+	}
+
+	pipelines := &v1alpha3.PipelineList{}
+	if err = r.List(ctx, pipelines, client.MatchingFields{
+		v1alpha3.PipelineUpstreamIndexerName: upstream.Namespace + "/" + upstreamPipelineName}); err != nil {
+		return
+	}
+	if len(pipelines.Items) == 0 {
+		err = r.recordDownstreams(ctx, sets.NewString(), true, req.NamespacedName)
+		return
+	}
+
+	// the upstream Pipeline decides which namespaces are allowed to be triggered
+	upstreamPipeline := &v1alpha3.Pipeline{}
+	if err = r.Get(ctx, types.NamespacedName{Namespace: upstream.Namespace, Name: upstreamPipelineName},
+		upstreamPipeline); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return
+		}
+		// only the same namespace is allowed without the upstream Pipeline
+		upstreamPipeline = &v1alpha3.Pipeline{}
+		upstreamPipeline.Namespace = upstream.Namespace
+	}
+	var chain sets.String
+	if chain, err = r.getUpstreamChain(ctx, upstream); err != nil {
+		return
+	}
+
+	downstreams := sets.NewString(upstream.GetDownstreams()...)
+	triggered := downstreams.Len()
+	for i := range pipelines.Items {
+		pipeline := &pipelines.Items[i]
+		trigger := getUpstreamTrigger(pipeline, upstream)
+		if trigger == nil || !pipeline.DeletionTimestamp.IsZero() {
+			continue
+		}
+		name := getDownstreamName(pipeline, upstream)
+		if downstreams.Has(pipeline.Namespace + "/" + name) {
+			continue
+		}
+
+		if !upstreamPipeline.AllowsDownstreamNamespace(pipeline.Namespace) {
+			r.recorder.Eventf(pipeline, corev1.EventTypeWarning, v1alpha3.UpstreamTriggerFailed,
+				"Namespace %s is not allowed to be triggered by Pipeline %s/%s, see the annotation %s",
+				pipeline.Namespace, upstream.Namespace, upstreamPipelineName, v1alpha3.PipelineDownstreamNamespacesAnnoKey)
+			continue
+		}
+		if chain.Has(pipeline.Namespace + "/" + pipeline.Name) {
+			r.recorder.Eventf(pipeline, corev1.EventTypeWarning, v1alpha3.UpstreamTriggerFailed,
+				"Skipped being triggered by PipelineRun %s/%s, because the Pipeline is one of its upstreams",
+				upstream.Namespace, upstream.Name)
+			continue
+		}
+
+		var ok bool
+		if ok, err = r.trigger(ctx, pipeline, trigger, upstream, name); err != nil {
+			log.Error(err, "unable to trigger the downstream Pipeline", "Pipeline", client.ObjectKeyFromObject(pipeline))
+			break
+		}
+		if ok {
+			downstreams.Insert(pipeline.Namespace + "/" + name)
+			r.recorder.Eventf(upstream, corev1.EventTypeNormal, v1alpha3.DownstreamTriggered,
+				"Triggered PipelineRun %s/%s of Pipeline %s", pipeline.Namespace, name, pipeline.Name)
+		}
+	}
+
+	// record the triggered ones even if some of the others failed, the PipelineRun is evaluated again in that case
+	if downstreams.Len() > triggered || err == nil {
+		if updateErr := r.recordDownstreams(ctx, downstreams, err == nil, req.NamespacedName); updateErr != nil {
+			log.Error(updateErr, "unable to record the downstream PipelineRuns")
+			if err == nil {
+				err = updateErr
+			}
+		}
+	}
+	return
+}
+
+// trigger creates a downstream PipelineRun, it returns false if the Pipeline is unable to be triggered by the upstream
+// PipelineRun, for instance, the branch of a multi-branch Pipeline is missing.
+func (r *TriggerReconciler) trigger(ctx context.Context, pipeline *v1alpha3.Pipeline, trigger *v1alpha3.UpstreamTrigger,
+	upstream *v1alpha3.PipelineRun, name string) (ok bool, err error) {
+	branch := trigger.Branch
+	if branch == "" {
+		branch = upstream.GetRefName()
+	}
+	var scm *v1alpha3.SCM
+	if scm, err = pipelinerun.CreateScm(&pipeline.Spec, branch); err != nil {
+		r.recorder.Eventf(pipeline, corev1.EventTypeWarning, v1alpha3.UpstreamTriggerFailed,
+			"Failed to be triggered by PipelineRun %s/%s, error: %v", upstream.Namespace, upstream.Name, err)
+		return false, nil
+	}
+	var parameters []v1alpha3.Parameter
+	if parameters, err = trigger.RenderParameters(upstream); err != nil {
+		r.recorder.Eventf(pipeline, corev1.EventTypeWarning, v1alpha3.UpstreamTriggerFailed,
+			"Failed to be triggered by PipelineRun %s/%s, error: %v", upstream.Namespace, upstream.Name, err)
+		return false, nil
+	}
+
+	// the kind is required by the owner reference
+	pipeline.SetGroupVersionKind(v1alpha3.GroupVersion.WithKind(v1alpha3.ResourceKindPipeline))
+	pr := pipelinerun.CreateBarePipelineRun(pipeline, parameters, scm)
+	// the name is determined by the upstream PipelineRun, so a downstream Pipeline is never triggered twice
+	pr.GenerateName = ""
+	pr.Name = name
+	pr.Annotations[v1alpha3.PipelineRunUpstreamAnnoKey] = upstream.Namespace + "/" + upstream.Name
+	if err = r.Create(ctx, pr); err != nil {
+		if apierrors.IsAlreadyExists(err) {
+			return true, nil
+		}
+		r.recorder.Eventf(pipeline, corev1.EventTypeWarning, v1alpha3.UpstreamTriggerFailed,
+			"Failed to create PipelineRun %s, error: %v", name, err)
+		return
+	}
+	return true, nil
+}
+
+// getUpstreamChain returns the Pipelines of the PipelineRun and all its upstream PipelineRuns, in the format of
+// namespace/name. A Pipeline in the chain is never triggered again, so the triggers never run in a loop.
+func (r *TriggerReconciler) getUpstreamChain(ctx context.Context, pr *v1alpha3.PipelineRun) (chain sets.String, err error) {
+	chain = sets.NewString()
+	visited := sets.NewString()
+	for pr != nil && !visited.Has(pr.Namespace+"/"+pr.Name) {
+		visited.Insert(pr.Namespace + "/" + pr.Name)
+		chain.Insert(pr.Namespace + "/" + pr.GetPipelineName())
+
+		namespace, name, found := getUpstreamOfRun(pr)
+		if !found {
+			break
+		}
+		upstream := &v1alpha3.PipelineRun{}
+		if err = r.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, upstream); err != nil {
+			if apierrors.IsNotFound(err) {
+				err = nil
+			}
+			break
+		}
+		pr = upstream
+	}
+	return
+}
+
+// recordDownstreams records the triggered downstream PipelineRuns, and marks the PipelineRun as evaluated if all
+// the downstream Pipelines were handled
+func (r *TriggerReconciler) recordDownstreams(ctx context.Context, downstreams sets.String, evaluated bool, key client.ObjectKey) error {
+	value := strings.Join(downstreams.List(), ",")
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		pr := &v1alpha3.PipelineRun{}
+		if err := r.Get(ctx, key, pr); err != nil {
+			return client.IgnoreNotFound(err)
+		}
+		annotations := map[string]string{}
+		if downstreams.Len() > 0 {
+			annotations[v1alpha3.PipelineRunDownstreamsAnnoKey] = value
+		}
+		if evaluated {
+			annotations[v1alpha3.PipelineRunDownstreamsEvaluatedAnnoKey] = "true"
+		}
+		changed := false
+		for k, v := range annotations {
+			if pr.Annotations[k] != v {
+				changed = true
+			}
+		}
+		if !changed {
+			return nil
+		}
+		if pr.Annotations == nil {
+			pr.Annotations = map[string]string{}
+		}
+		for k, v := range annotations {
+			pr.Annotations[k] = v
+		}
+		return r.Update(ctx, pr)
+	})
+}
+
+// getUpstreamTrigger returns the first trigger of the Pipeline which matches the upstream PipelineRun
+func getUpstreamTrigger(pipeline *v1alpha3.Pipeline, upstream *v1alpha3.PipelineRun) *v1alpha3.UpstreamTrigger {
+	for i := range pipeline.Spec.UpstreamTriggers {
+		if trigger := &pipeline.Spec.UpstreamTriggers[i]; trigger.Matches(pipeline.Namespace, upstream) {
+			return trigger
+		}
+	}
+	return nil
+}
+
+// getDownstreamName returns the name of the PipelineRun of a downstream Pipeline which is triggered by the upstream
+// PipelineRun. The UID is taken into account, so a new upstream PipelineRun with the same name triggers again.
+func getDownstreamName(pipeline *v1alpha3.Pipeline, upstream *v1alpha3.PipelineRun) string {
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(fmt.Sprintf("%s/%s/%s", upstream.Namespace, upstream.Name, upstream.UID)))
+	return fmt.Sprintf("%s-%08x", pipeline.Name, hash.Sum32())
+}
+
+// getUpstreamOfRun returns the upstream PipelineRun which triggered the PipelineRun
+func getUpstreamOfRun(pr *v1alpha3.PipelineRun) (namespace, name string, found bool) {
+	if items := strings.SplitN(pr.Annotations[v1alpha3.PipelineRunUpstreamAnnoKey], "/", 2); len(items) == 2 {
+		namespace, name, found = items[0], items[1], true
+	}
+	return
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *TriggerReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	r.recorder = mgr.GetEventRecorderFor("pipelinerun-trigger")
+	r.log = ctrl.Log.WithName("pipelinerun-trigger")
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("pipelinerun-trigger").
+		For(&v1alpha3.PipelineRun{}, builder.WithPredicates(upstreamCompletedPredicate)).
+		Complete(r)
+}
+
+// upstreamCompletedPredicate only allows the events of a PipelineRun which just completed, and the completed
+// PipelineRuns which were not evaluated yet, for instance, they completed when the controller was down.
+var upstreamCompletedPredicate = predicate.Funcs{
+	CreateFunc: func(e event.CreateEvent) bool {
+		pr, ok := e.Object.(*v1alpha3.PipelineRun)
+		return ok && pr.HasCompleted() && pr.Annotations[v1alpha3.PipelineRunDownstreamsEvaluatedAnnoKey] != "true"
+	},
+	UpdateFunc: pipelineRunCompletedPredicate.UpdateFunc,
+	DeleteFunc: func(event.DeleteEvent) bool {
+		return false
+	},
+	GenericFunc: func(event.GenericEvent) bool {
+		return false
+	},
+}
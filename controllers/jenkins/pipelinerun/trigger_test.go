@@ -0,0 +1,203 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinerun
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func TestTriggerReconciler_Reconcile(t *testing.T) {
+	schema, err := v1alpha3.SchemeBuilder.Register().Build()
+	assert.Nil(t, err)
+
+	newPipeline := func(namespace, name string, triggers ...v1alpha3.UpstreamTrigger) *v1alpha3.Pipeline {
+		return &v1alpha3.Pipeline{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+			Spec: v1alpha3.PipelineSpec{
+				Type:             v1alpha3.NoScmPipelineType,
+				Pipeline:         &v1alpha3.NoScmPipeline{},
+				UpstreamTriggers: triggers,
+			},
+		}
+	}
+	newPipelineRun := func(namespace, name, pipeline string, phase v1alpha3.RunPhase) *v1alpha3.PipelineRun {
+		pr := &v1alpha3.PipelineRun{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:   namespace,
+				Name:        name,
+				UID:         types.UID(name),
+				Labels:      map[string]string{v1alpha3.PipelineNameLabelKey: pipeline},
+				Annotations: map[string]string{},
+			},
+			Spec: v1alpha3.PipelineRunSpec{
+				PipelineRef: &v1.ObjectReference{Name: pipeline},
+				Parameters:  []v1alpha3.Parameter{{Name: "version", Value: "v1.0.0"}},
+			},
+			Status: v1alpha3.PipelineRunStatus{Phase: phase},
+		}
+		if phase != v1alpha3.Running {
+			pr.Status.CompletionTime = &metav1.Time{Time: time.Now()}
+		}
+		return pr
+	}
+
+	upstream := newPipelineRun("ns", "build-abcde", "build", v1alpha3.Succeeded)
+	allowAll := newPipeline("ns", "build")
+	allowAll.Annotations = map[string]string{v1alpha3.PipelineDownstreamNamespacesAnnoKey: "*"}
+	deploy := newPipeline("ns", "deploy", v1alpha3.UpstreamTrigger{
+		Pipeline:   "build",
+		Parameters: []v1alpha3.Parameter{{Name: "image", Value: "app:$(upstream.parameters.version)"}},
+	})
+	release := newPipeline("release", "release", v1alpha3.UpstreamTrigger{Namespace: "ns", Pipeline: "build"})
+	multiBranch := newPipeline("ns", "test", v1alpha3.UpstreamTrigger{Pipeline: "build"})
+	multiBranch.Spec.Type = v1alpha3.MultiBranchPipelineType
+	// the upstream PipelineRun was triggered by a PipelineRun of deploy
+	cycled := newPipelineRun("ns", "build-fghij", "build", v1alpha3.Succeeded)
+	cycled.Annotations[v1alpha3.PipelineRunUpstreamAnnoKey] = "ns/deploy-klmno"
+
+	tests := []struct {
+		name            string
+		upstream        *v1alpha3.PipelineRun
+		objects         []client.Object
+		wantDownstreams []*v1alpha3.Pipeline
+	}{{
+		name:     "not completed",
+		upstream: newPipelineRun("ns", "build-abcde", "build", v1alpha3.Running),
+		objects:  []client.Object{newPipeline("ns", "build"), deploy.DeepCopy()},
+	}, {
+		name:     "no matched triggers",
+		upstream: newPipelineRun("ns", "build-abcde", "build", v1alpha3.Failed),
+		objects:  []client.Object{newPipeline("ns", "build"), deploy.DeepCopy()},
+	}, {
+		name:            "trigger the Pipeline in the same namespace",
+		upstream:        upstream.DeepCopy(),
+		objects:         []client.Object{newPipeline("ns", "build"), deploy.DeepCopy(), newPipeline("ns", "other")},
+		wantDownstreams: []*v1alpha3.Pipeline{deploy},
+	}, {
+		name:            "the upstream Pipeline was deleted",
+		upstream:        upstream.DeepCopy(),
+		objects:         []client.Object{deploy.DeepCopy()},
+		wantDownstreams: []*v1alpha3.Pipeline{deploy},
+	}, {
+		name:            "another namespace is not allowed",
+		upstream:        upstream.DeepCopy(),
+		objects:         []client.Object{newPipeline("ns", "build"), deploy.DeepCopy(), release.DeepCopy()},
+		wantDownstreams: []*v1alpha3.Pipeline{deploy},
+	}, {
+		name:            "another namespace is allowed",
+		upstream:        upstream.DeepCopy(),
+		objects:         []client.Object{allowAll.DeepCopy(), deploy.DeepCopy(), release.DeepCopy()},
+		wantDownstreams: []*v1alpha3.Pipeline{deploy, release},
+	}, {
+		name:     "missing the branch of a multi-branch Pipeline",
+		upstream: upstream.DeepCopy(),
+		objects:  []client.Object{newPipeline("ns", "build"), multiBranch.DeepCopy()},
+	}, {
+		name:     "never trigger the upstreams",
+		upstream: cycled.DeepCopy(),
+		objects: []client.Object{newPipeline("ns", "build"), deploy.DeepCopy(),
+			newPipelineRun("ns", "deploy-klmno", "deploy", v1alpha3.Succeeded)},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &TriggerReconciler{
+				Client:   fake.NewClientBuilder().WithScheme(schema).WithObjects(append(tt.objects, tt.upstream)...).Build(),
+				log:      logr.New(log.NullLogSink{}),
+				recorder: record.NewFakeRecorder(10),
+			}
+			req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(tt.upstream)}
+			// reconciling again never triggers twice
+			for i := 0; i < 2; i++ {
+				_, err := r.Reconcile(context.Background(), req)
+				assert.Nil(t, err)
+			}
+
+			var wantDownstreams []string
+			for _, pipeline := range tt.wantDownstreams {
+				name := getDownstreamName(pipeline, tt.upstream)
+				wantDownstreams = append(wantDownstreams, pipeline.Namespace+"/"+name)
+
+				pr := &v1alpha3.PipelineRun{}
+				if assert.Nil(t, r.Get(context.Background(), types.NamespacedName{Namespace: pipeline.Namespace, Name: name}, pr)) {
+					assert.Equal(t, pipeline.Name, pr.Labels[v1alpha3.PipelineNameLabelKey])
+					assert.Equal(t, "ns/"+tt.upstream.Name, pr.Annotations[v1alpha3.PipelineRunUpstreamAnnoKey])
+					assert.Equal(t, len(pipeline.Spec.UpstreamTriggers[0].Parameters), len(pr.Spec.Parameters))
+					if pipeline.Name == "deploy" {
+						assert.Equal(t, []v1alpha3.Parameter{{Name: "image", Value: "app:v1.0.0"}}, pr.Spec.Parameters)
+					}
+				}
+			}
+
+			prs := &v1alpha3.PipelineRunList{}
+			assert.Nil(t, r.List(context.Background(), prs))
+			var created int
+			for _, item := range prs.Items {
+				if item.Annotations[v1alpha3.PipelineRunUpstreamAnnoKey] == "ns/"+tt.upstream.Name {
+					created++
+				}
+			}
+			assert.Equal(t, len(tt.wantDownstreams), created)
+
+			pr := &v1alpha3.PipelineRun{}
+			assert.Nil(t, r.Get(context.Background(), req.NamespacedName, pr))
+			assert.ElementsMatch(t, wantDownstreams, pr.GetDownstreams())
+			assert.Equal(t, tt.upstream.HasCompleted(), pr.Annotations[v1alpha3.PipelineRunDownstreamsEvaluatedAnnoKey] == "true")
+		})
+	}
+}
+
+func Test_upstreamCompletedPredicate(t *testing.T) {
+	running := &v1alpha3.PipelineRun{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "build-abcde"}}
+	completed := running.DeepCopy()
+	completed.Status.CompletionTime = &metav1.Time{Time: time.Now()}
+	evaluated := completed.DeepCopy()
+	evaluated.Annotations = map[string]string{v1alpha3.PipelineRunDownstreamsEvaluatedAnnoKey: "true"}
+
+	assert.False(t, upstreamCompletedPredicate.Create(event.CreateEvent{Object: running}))
+	// completed when the controller was down
+	assert.True(t, upstreamCompletedPredicate.Create(event.CreateEvent{Object: completed}))
+	assert.False(t, upstreamCompletedPredicate.Create(event.CreateEvent{Object: evaluated}))
+	assert.True(t, upstreamCompletedPredicate.Update(event.UpdateEvent{ObjectOld: running, ObjectNew: completed}))
+	assert.False(t, upstreamCompletedPredicate.Update(event.UpdateEvent{ObjectOld: completed, ObjectNew: evaluated}))
+	assert.False(t, upstreamCompletedPredicate.Delete(event.DeleteEvent{Object: completed}))
+}
+
+func Test_getDownstreamName(t *testing.T) {
+	pipeline := &v1alpha3.Pipeline{ObjectMeta: metav1.ObjectMeta{Namespace: "deploy", Name: "deploy"}}
+	upstream := &v1alpha3.PipelineRun{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "build-abcde", UID: "uid"}}
+	name := getDownstreamName(pipeline, upstream)
+	assert.Regexp(t, "^deploy-[0-9a-f]{8}$", name)
+	assert.Equal(t, name, getDownstreamName(pipeline, upstream.DeepCopy()))
+
+	upstream.UID = "another"
+	assert.NotEqual(t, name, getDownstreamName(pipeline, upstream))
+}
@@ -22,6 +22,7 @@ import (
 	"github.com/jenkins-zh/jenkins-client/pkg/job"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
+	"kubesphere.io/devops/pkg/models/pipelinerun"
 )
 
 // JenkinsRunState represents current PipelineRun state.
@@ -52,17 +53,17 @@ type JenkinsRunResult string
 
 const (
 	// Success indicates the PipelineRun runs successfully.
-	Success JenkinsRunResult = "SUCCESS"
+	Success JenkinsRunResult = pipelinerun.ResultSuccess
 	// Unstable indicates the PipelineRun runs with unstable result.
-	Unstable JenkinsRunResult = "UNSTABLE"
+	Unstable JenkinsRunResult = pipelinerun.ResultUnstable
 	// Failure indicates the PipelineRun runs with failure.
-	Failure JenkinsRunResult = "FAILURE"
+	Failure JenkinsRunResult = pipelinerun.ResultFailure
 	// NotBuiltResult indicates the PipelineRun hasn't been built but finishied.
-	NotBuiltResult JenkinsRunResult = "NOT_BUILT"
+	NotBuiltResult JenkinsRunResult = pipelinerun.ResultNotBuilt
 	// Unknown indicates the PipelineRun hasn't running yet or runs with unknown result.
-	Unknown JenkinsRunResult = "UNKNOWN"
+	Unknown JenkinsRunResult = pipelinerun.ResultUnknown
 	// Aborted indicates the PipelineRun runs with aborted result.
-	Aborted JenkinsRunResult = "ABORTED"
+	Aborted JenkinsRunResult = pipelinerun.ResultAborted
 )
 
 // String returns string value of result of PipelineRun.
@@ -122,28 +123,7 @@ func (pbApplier pipelineBuildApplier) whenPipelineRunFinished(condition *v1alpha
 		// should never happen
 		prStatus.CompletionTime = &v1.Time{Time: time.Now()}
 	}
-	condition.Type = v1alpha3.ConditionSucceeded
-	// handle result
-	switch pbApplier.Result {
-	case Success.String():
-		condition.Status = v1alpha3.ConditionTrue
-		prStatus.Phase = v1alpha3.Succeeded
-	case Unstable.String():
-		condition.Status = v1alpha3.ConditionFalse
-		prStatus.Phase = v1alpha3.Failed
-	case Failure.String():
-		condition.Status = v1alpha3.ConditionFalse
-		prStatus.Phase = v1alpha3.Failed
-	case NotBuiltResult.String():
-		condition.Status = v1alpha3.ConditionUnknown
-		prStatus.Phase = v1alpha3.Unknown
-	case Unknown.String():
-		condition.Status = v1alpha3.ConditionUnknown
-		prStatus.Phase = v1alpha3.Unknown
-	case Aborted.String():
-		condition.Status = v1alpha3.ConditionFalse
-		prStatus.Phase = v1alpha3.Failed
-	}
+	pipelinerun.ApplyResult(pbApplier.Result, condition, prStatus)
 }
 
 // parameterConverter is responsible to convert Parameter slice of PipelineRun into job.Parameter slice.
@@ -0,0 +1,126 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package admission contains the validating and defaulting admission webhooks of the DevOps resources.
+// The invalid objects are rejected when they are created or updated, instead of failing in the controllers.
+package admission
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"regexp"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/klog/v2"
+	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
+	"kubesphere.io/devops/pkg/client/devops"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// CronChecker checks the cron expressions of the timer triggers and schedules via Jenkins, it's a part of devops.Interface
+type CronChecker interface {
+	CheckCron(projectName string, httpParameters *devops.HttpParameters) (*devops.CheckCronRes, error)
+}
+
+// SetupWithManager registers all the webhooks into the webhook server of the manager.
+// The cron expressions are not checked if the cronChecker is nil.
+func SetupWithManager(mgr ctrl.Manager, cronChecker CronChecker) (err error) {
+	if err = ctrl.NewWebhookManagedBy(mgr).For(&v1alpha3.Pipeline{}).
+		WithDefaulter(&pipelineWebhook{}).
+		WithValidator(&pipelineWebhook{cronChecker: cronChecker}).
+		Complete(); err != nil {
+		return
+	}
+	if err = ctrl.NewWebhookManagedBy(mgr).For(&v1alpha3.PipelineRun{}).
+		WithDefaulter(&pipelineRunWebhook{}).
+		WithValidator(&pipelineRunWebhook{reader: mgr.GetClient()}).
+		Complete(); err != nil {
+		return
+	}
+	if err = ctrl.NewWebhookManagedBy(mgr).For(&v1alpha3.PipelineSchedule{}).
+		WithValidator(&pipelineScheduleWebhook{cronChecker: cronChecker}).
+		Complete(); err != nil {
+		return
+	}
+	if err = ctrl.NewWebhookManagedBy(mgr).For(&v1alpha3.Template{}).
+		WithValidator(&templateWebhook{}).
+		Complete(); err != nil {
+		return
+	}
+	if err = ctrl.NewWebhookManagedBy(mgr).For(&v1alpha3.ClusterTemplate{}).
+		WithValidator(&templateWebhook{}).
+		Complete(); err != nil {
+		return
+	}
+	if err = ctrl.NewWebhookManagedBy(mgr).For(&v1alpha3.ClusterStepTemplate{}).
+		WithValidator(&stepTemplateWebhook{}).
+		Complete(); err != nil {
+		return
+	}
+	return ctrl.NewWebhookManagedBy(mgr).For(&v1alpha3.GitRepository{}).
+		WithDefaulter(&gitRepositoryWebhook{}).
+		WithValidator(&gitRepositoryWebhook{}).
+		Complete()
+}
+
+// toInvalidError converts the field errors into an Invalid error, it's nil if there are no errors
+func toInvalidError(kind, name string, errs field.ErrorList) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(schema.GroupKind{Group: v1alpha3.GroupVersion.Group, Kind: kind}, name, errs)
+}
+
+// validateRegex checks if the value is a valid regular expression. An empty value is valid.
+func validateRegex(path *field.Path, value string) (errs field.ErrorList) {
+	if value == "" {
+		return
+	}
+	if _, err := regexp.Compile(value); err != nil {
+		errs = append(errs, field.Invalid(path, value, err.Error()))
+	}
+	return
+}
+
+// checkCron checks the cron expression via Jenkins. A cron is treated as valid if it's unable to check,
+// because Jenkins being unavailable should not block the changes of Pipelines.
+func checkCron(cronChecker CronChecker, path *field.Path, projectName, cron string) (errs field.ErrorList) {
+	if cronChecker == nil || cron == "" {
+		return
+	}
+
+	data, err := json.Marshal(&devops.CronData{Cron: cron})
+	if err != nil {
+		return
+	}
+	result, err := cronChecker.CheckCron(projectName, &devops.HttpParameters{
+		Method: http.MethodPost,
+		Header: http.Header{},
+		Body:   io.NopCloser(bytes.NewReader(data)),
+	})
+	if err != nil || result == nil {
+		klog.V(4).Infof("skipped checking cron %q, error: %v", cron, err)
+		return
+	}
+	if result.Result == "error" {
+		errs = append(errs, field.Invalid(path, cron, result.Message))
+	}
+	return
+}
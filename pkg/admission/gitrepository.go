@@ -0,0 +1,128 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package admission
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
+)
+
+//+kubebuilder:webhook:path=/mutate-devops-kubesphere-io-v1alpha3-gitrepository,mutating=true,failurePolicy=fail,sideEffects=None,groups=devops.kubesphere.io,resources=gitrepositories,verbs=create;update,versions=v1alpha3,name=mgitrepository.devops.kubesphere.io,admissionReviewVersions=v1
+//+kubebuilder:webhook:path=/validate-devops-kubesphere-io-v1alpha3-gitrepository,mutating=false,failurePolicy=fail,sideEffects=None,groups=devops.kubesphere.io,resources=gitrepositories,verbs=create;update,versions=v1alpha3,name=vgitrepository.devops.kubesphere.io,admissionReviewVersions=v1
+
+// publicProviders are the git providers which are able to generate the URL from the owner and repo
+var publicProviders = sets.NewString("github", "gitlab", "bitbucket")
+
+// selfHostedProviders are the git providers which are able to generate the URL from the server, owner and repo
+var selfHostedProviders = sets.NewString("gitea", "gogs")
+
+// scpLikeURLPattern matches a git address like: git@github.com:owner/repo.git
+var scpLikeURLPattern = regexp.MustCompile(`^[\w.-]+@[\w.-]+:[^/].*$`)
+
+// gitRepositoryWebhook defaults and validates the GitRepositories
+type gitRepositoryWebhook struct{}
+
+// Default lowercases the provider, so the controllers are able to match it
+func (w *gitRepositoryWebhook) Default(_ context.Context, obj runtime.Object) error {
+	repo, ok := obj.(*v1alpha3.GitRepository)
+	if !ok {
+		return fmt.Errorf("expect a GitRepository but got %T", obj)
+	}
+	repo.Spec.Provider = strings.ToLower(repo.Spec.Provider)
+	return nil
+}
+
+// ValidateCreate validates a new GitRepository
+func (w *gitRepositoryWebhook) ValidateCreate(_ context.Context, obj runtime.Object) error {
+	return validateGitRepository(obj)
+}
+
+// ValidateUpdate validates a GitRepository
+func (w *gitRepositoryWebhook) ValidateUpdate(_ context.Context, _, newObj runtime.Object) error {
+	return validateGitRepository(newObj)
+}
+
+// ValidateDelete allows deleting any GitRepositories
+func (w *gitRepositoryWebhook) ValidateDelete(_ context.Context, _ runtime.Object) error {
+	return nil
+}
+
+func validateGitRepository(obj runtime.Object) error {
+	repo, ok := obj.(*v1alpha3.GitRepository)
+	if !ok {
+		return fmt.Errorf("expect a GitRepository but got %T", obj)
+	}
+
+	var errs field.ErrorList
+	specPath := field.NewPath("spec")
+	spec := repo.Spec
+	if spec.URL == "" {
+		if !publicProviders.Has(spec.Provider) && !(selfHostedProviders.Has(spec.Provider) && spec.Server != "") {
+			errs = append(errs, field.Required(specPath.Child("url"),
+				fmt.Sprintf("url is required unless the provider is one of %v, or one of %v with the server",
+					publicProviders.List(), selfHostedProviders.List())))
+		} else {
+			if spec.Owner == "" {
+				errs = append(errs, field.Required(specPath.Child("owner"), "owner is required without the url"))
+			}
+			if spec.Repo == "" {
+				errs = append(errs, field.Required(specPath.Child("repo"), "repo is required without the url"))
+			}
+		}
+	} else if err := validateGitURL(spec.URL); err != nil {
+		errs = append(errs, field.Invalid(specPath.Child("url"), spec.URL, err.Error()))
+	}
+
+	if spec.Secret != nil && spec.Secret.Name == "" {
+		errs = append(errs, field.Required(specPath.Child("secret", "name"), ""))
+	}
+	for i, webhook := range spec.Webhooks {
+		if webhook.Name == "" {
+			errs = append(errs, field.Required(specPath.Child("webhooks").Index(i).Child("name"), ""))
+		}
+	}
+	return toInvalidError("GitRepository", repo.Name, errs)
+}
+
+// validateGitURL checks if the address is a http(s), ssh or scp-like git address
+func validateGitURL(address string) error {
+	if scpLikeURLPattern.MatchString(address) {
+		return nil
+	}
+
+	gitURL, err := url.Parse(address)
+	if err != nil {
+		return err
+	}
+	switch gitURL.Scheme {
+	case "http", "https", "ssh", "git":
+	default:
+		return fmt.Errorf("unsupported scheme %q, expect one of http, https, ssh and git", gitURL.Scheme)
+	}
+	if gitURL.Host == "" {
+		return fmt.Errorf("missing the host")
+	}
+	return nil
+}
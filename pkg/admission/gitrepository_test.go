@@ -0,0 +1,94 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package admission
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
+)
+
+func TestGitRepositoryWebhook_Default(t *testing.T) {
+	repo := &v1alpha3.GitRepository{Spec: v1alpha3.GitRepositorySpec{Provider: "GitHub"}}
+	assert.Nil(t, (&gitRepositoryWebhook{}).Default(context.Background(), repo))
+	assert.Equal(t, "github", repo.Spec.Provider)
+}
+
+func TestGitRepositoryWebhook_ValidateCreate(t *testing.T) {
+	tests := []struct {
+		name       string
+		spec       v1alpha3.GitRepositorySpec
+		wantFields []string
+	}{{
+		name: "a https URL",
+		spec: v1alpha3.GitRepositorySpec{Provider: "git", URL: "https://gitee.com/linuxsuren/test.git"},
+	}, {
+		name: "a ssh URL",
+		spec: v1alpha3.GitRepositorySpec{URL: "ssh://git@github.com:22/kubesphere/ks-devops.git"},
+	}, {
+		name: "a scp-like URL",
+		spec: v1alpha3.GitRepositorySpec{URL: "git@github.com:kubesphere/ks-devops.git"},
+	}, {
+		name: "the owner and repo of a public provider",
+		spec: v1alpha3.GitRepositorySpec{Provider: "github", Owner: "kubesphere", Repo: "ks-devops"},
+	}, {
+		name:       "missing the repo of a public provider",
+		spec:       v1alpha3.GitRepositorySpec{Provider: "gitlab", Owner: "kubesphere"},
+		wantFields: []string{"spec.repo"},
+	}, {
+		name:       "missing the URL",
+		spec:       v1alpha3.GitRepositorySpec{Provider: "git", Owner: "kubesphere", Repo: "ks-devops"},
+		wantFields: []string{"spec.url"},
+	}, {
+		name: "the server, owner and repo of a self-hosted provider",
+		spec: v1alpha3.GitRepositorySpec{Provider: "gitea", Server: "https://gitea.com", Owner: "kubesphere", Repo: "ks-devops"},
+	}, {
+		name:       "missing the server of a self-hosted provider",
+		spec:       v1alpha3.GitRepositorySpec{Provider: "gitea", Owner: "kubesphere", Repo: "ks-devops"},
+		wantFields: []string{"spec.url"},
+	}, {
+		name:       "unsupported scheme",
+		spec:       v1alpha3.GitRepositorySpec{URL: "ftp://github.com/kubesphere/ks-devops.git"},
+		wantFields: []string{"spec.url"},
+	}, {
+		name:       "missing the host",
+		spec:       v1alpha3.GitRepositorySpec{URL: "https:///kubesphere/ks-devops.git"},
+		wantFields: []string{"spec.url"},
+	}, {
+		name: "missing the names of the references",
+		spec: v1alpha3.GitRepositorySpec{
+			URL:      "https://github.com/kubesphere/ks-devops",
+			Secret:   &corev1.SecretReference{Namespace: "ns"},
+			Webhooks: []corev1.LocalObjectReference{{Name: "webhook"}, {}},
+		},
+		wantFields: []string{"spec.secret.name", "spec.webhooks[1].name"},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &v1alpha3.GitRepository{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "repo"},
+				Spec:       tt.spec,
+			}
+			err := (&gitRepositoryWebhook{}).ValidateCreate(context.Background(), repo)
+			assert.Equal(t, tt.wantFields, getInvalidFields(t, err))
+		})
+	}
+}
@@ -0,0 +1,338 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package admission
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strconv"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
+	"kubesphere.io/devops/pkg/client/devops/jenkins"
+)
+
+//+kubebuilder:webhook:path=/mutate-devops-kubesphere-io-v1alpha3-pipeline,mutating=true,failurePolicy=fail,sideEffects=None,groups=devops.kubesphere.io,resources=pipelines,verbs=create;update,versions=v1alpha3,name=mpipeline.devops.kubesphere.io,admissionReviewVersions=v1
+//+kubebuilder:webhook:path=/validate-devops-kubesphere-io-v1alpha3-pipeline,mutating=false,failurePolicy=fail,sideEffects=None,groups=devops.kubesphere.io,resources=pipelines,verbs=create;update,versions=v1alpha3,name=vpipeline.devops.kubesphere.io,admissionReviewVersions=v1
+
+// pipelineWebhook defaults and validates the Pipelines
+type pipelineWebhook struct {
+	cronChecker CronChecker
+}
+
+// Default determines the type of a Pipeline from its spec if it's missing
+func (w *pipelineWebhook) Default(_ context.Context, obj runtime.Object) error {
+	pipeline, ok := obj.(*v1alpha3.Pipeline)
+	if !ok {
+		return fmt.Errorf("expect a Pipeline but got %T", obj)
+	}
+
+	spec := &pipeline.Spec
+	if spec.Type == "" {
+		if spec.MultiBranchPipeline != nil && spec.Pipeline == nil {
+			spec.Type = v1alpha3.MultiBranchPipelineType
+		} else if spec.Pipeline != nil {
+			spec.Type = v1alpha3.NoScmPipelineType
+		}
+	}
+	if spec.Pipeline != nil && spec.Pipeline.Name == "" {
+		spec.Pipeline.Name = pipeline.Name
+	}
+	if spec.MultiBranchPipeline != nil && spec.MultiBranchPipeline.Name == "" {
+		spec.MultiBranchPipeline.Name = pipeline.Name
+	}
+	return nil
+}
+
+// ValidateCreate validates a new Pipeline
+func (w *pipelineWebhook) ValidateCreate(_ context.Context, obj runtime.Object) error {
+	pipeline, ok := obj.(*v1alpha3.Pipeline)
+	if !ok {
+		return fmt.Errorf("expect a Pipeline but got %T", obj)
+	}
+	errs := validatePipelineSpec(field.NewPath("spec"), &pipeline.Spec)
+	errs = append(errs, validateUpstreamTriggers(field.NewPath("spec", "upstream_triggers"), pipeline)...)
+	errs = append(errs, w.validateCron(pipeline, nil)...)
+	return toInvalidError(v1alpha3.ResourceKindPipeline, pipeline.Name, errs)
+}
+
+// ValidateUpdate validates the spec of a Pipeline only if it was changed, then the existing Pipelines are able to
+// be updated by the controllers. The type of a Pipeline is immutable.
+func (w *pipelineWebhook) ValidateUpdate(_ context.Context, oldObj, newObj runtime.Object) error {
+	oldPipeline, ok := oldObj.(*v1alpha3.Pipeline)
+	if !ok {
+		return fmt.Errorf("expect a Pipeline but got %T", oldObj)
+	}
+	pipeline, ok := newObj.(*v1alpha3.Pipeline)
+	if !ok {
+		return fmt.Errorf("expect a Pipeline but got %T", newObj)
+	}
+
+	var errs field.ErrorList
+	if pipeline.Spec.Type != oldPipeline.Spec.Type {
+		errs = append(errs, field.Forbidden(field.NewPath("spec", "type"), "the type of a Pipeline is immutable"))
+	}
+	if !reflect.DeepEqual(pipeline.Spec, oldPipeline.Spec) {
+		errs = append(errs, validatePipelineSpec(field.NewPath("spec"), &pipeline.Spec)...)
+		errs = append(errs, validateUpstreamTriggers(field.NewPath("spec", "upstream_triggers"), pipeline)...)
+		errs = append(errs, w.validateCron(pipeline, oldPipeline)...)
+	}
+	return toInvalidError(v1alpha3.ResourceKindPipeline, pipeline.Name, errs)
+}
+
+// ValidateDelete allows deleting any Pipelines
+func (w *pipelineWebhook) ValidateDelete(_ context.Context, _ runtime.Object) error {
+	return nil
+}
+
+// validateCron checks the cron of the timer trigger if it was changed
+func (w *pipelineWebhook) validateCron(pipeline, oldPipeline *v1alpha3.Pipeline) field.ErrorList {
+	cron := getCron(pipeline)
+	if oldPipeline != nil && cron == getCron(oldPipeline) {
+		return nil
+	}
+	return checkCron(w.cronChecker, field.NewPath("spec", "pipeline", "timer_trigger", "cron"), pipeline.Namespace, cron)
+}
+
+func getCron(pipeline *v1alpha3.Pipeline) (cron string) {
+	if pipeline.Spec.Pipeline != nil && pipeline.Spec.Pipeline.TimerTrigger != nil {
+		cron = pipeline.Spec.Pipeline.TimerTrigger.Cron
+	}
+	return
+}
+
+// supportedPipelineTypes are all the types of Pipeline
+var supportedPipelineTypes = []string{string(v1alpha3.NoScmPipelineType), string(v1alpha3.MultiBranchPipelineType)}
+
+func validatePipelineSpec(path *field.Path, spec *v1alpha3.PipelineSpec) (errs field.ErrorList) {
+	switch spec.Type {
+	case v1alpha3.NoScmPipelineType:
+		if spec.Pipeline == nil {
+			errs = append(errs, field.Required(path.Child("pipeline"), "required by the type "+string(spec.Type)))
+		} else {
+			errs = append(errs, validateNoScmPipeline(path.Child("pipeline"), spec.Pipeline)...)
+		}
+	case v1alpha3.MultiBranchPipelineType:
+		if spec.MultiBranchPipeline == nil {
+			errs = append(errs, field.Required(path.Child("multi_branch_pipeline"), "required by the type "+string(spec.Type)))
+		} else {
+			errs = append(errs, validateMultiBranchPipeline(path.Child("multi_branch_pipeline"), spec.MultiBranchPipeline)...)
+		}
+	case "":
+		errs = append(errs, field.Required(path.Child("type"), ""))
+	default:
+		errs = append(errs, field.NotSupported(path.Child("type"), spec.Type, supportedPipelineTypes))
+	}
+	errs = append(errs, validateConcurrencyPolicy(path.Child("concurrency"), spec.Concurrency)...)
+	return
+}
+
+func validateConcurrencyPolicy(path *field.Path, concurrency *v1alpha3.ConcurrencyPolicy) (errs field.ErrorList) {
+	if concurrency == nil {
+		return
+	}
+	if concurrency.Policy != "" && !concurrency.Policy.IsValid() {
+		errs = append(errs, field.NotSupported(path.Child("policy"), concurrency.Policy, []string{
+			string(v1alpha3.AllowConcurrent), string(v1alpha3.ForbidConcurrent),
+			string(v1alpha3.ReplaceConcurrent), string(v1alpha3.QueueConcurrent)}))
+	}
+	if concurrency.MaxParallel < 0 {
+		errs = append(errs, field.Invalid(path.Child("max_parallel"), concurrency.MaxParallel, "must not be negative"))
+	}
+	return
+}
+
+// completedPhases are the phases of a completed PipelineRun which could trigger the downstream Pipelines
+var completedPhases = []string{string(v1alpha3.Succeeded), string(v1alpha3.Failed), string(v1alpha3.Cancelled)}
+
+// validateUpstreamTriggers checks the upstream triggers, a Pipeline is not allowed to be the upstream of itself.
+// Whether the upstream Pipeline in another namespace allows the Pipeline is checked when triggering.
+func validateUpstreamTriggers(path *field.Path, pipeline *v1alpha3.Pipeline) (errs field.ErrorList) {
+	for i := range pipeline.Spec.UpstreamTriggers {
+		trigger := &pipeline.Spec.UpstreamTriggers[i]
+		triggerPath := path.Index(i)
+		if trigger.Pipeline == "" {
+			errs = append(errs, field.Required(triggerPath.Child("pipeline"), ""))
+		} else if trigger.GetNamespace(pipeline.Namespace) == pipeline.Namespace && trigger.Pipeline == pipeline.Name {
+			errs = append(errs, field.Invalid(triggerPath.Child("pipeline"), trigger.Pipeline,
+				"must not be the Pipeline itself"))
+		}
+		for j, phase := range trigger.Phases {
+			if !sets.NewString(completedPhases...).Has(string(phase)) {
+				errs = append(errs, field.NotSupported(triggerPath.Child("phases").Index(j), phase, completedPhases))
+			}
+		}
+		errs = append(errs, validateRegex(triggerPath.Child("branch_filter"), trigger.BranchFilter)...)
+		errs = append(errs, validateParameterNames(triggerPath.Child("parameters"), trigger.Parameters)...)
+		for j, parameter := range trigger.Parameters {
+			for _, variable := range v1alpha3.GetUpstreamVariables(parameter.Value) {
+				if !v1alpha3.IsValidUpstreamVariable(variable) {
+					errs = append(errs, field.Invalid(triggerPath.Child("parameters").Index(j).Child("value"),
+						parameter.Value, fmt.Sprintf("unknown variable $(upstream.%s)", variable)))
+				}
+			}
+		}
+	}
+	return
+}
+
+func validateNoScmPipeline(path *field.Path, pipeline *v1alpha3.NoScmPipeline) (errs field.ErrorList) {
+	errs = append(errs, validateDiscarder(path.Child("discarder"), pipeline.Discarder)...)
+	errs = append(errs, validateParameterDefinitions(path.Child("parameters"), pipeline.Parameters)...)
+	if webhook := pipeline.GenericWebhook; webhook != nil {
+		webhookPath := path.Child("generic_webhook")
+		for i, variable := range webhook.RequestVariables {
+			errs = append(errs, validateRegex(webhookPath.Child("request_variables").Index(i).Child("regexp_filter"),
+				variable.RegexpFilter)...)
+		}
+		for i, variable := range webhook.HeaderVariables {
+			errs = append(errs, validateRegex(webhookPath.Child("header_variables").Index(i).Child("regexp_filter"),
+				variable.RegexpFilter)...)
+		}
+		errs = append(errs, validateRegex(webhookPath.Child("filter_expression"), webhook.FilterExpression)...)
+	}
+	return
+}
+
+// supportedSourceTypes are all the source types of a multi-branch Pipeline
+var supportedSourceTypes = []string{v1alpha3.SourceTypeGit, v1alpha3.SourceTypeGithub, v1alpha3.SourceTypeGitlab,
+	v1alpha3.SourceTypeBitbucket, v1alpha3.SourceTypeGitea, v1alpha3.SourceTypeSVN, v1alpha3.SourceTypeSingleSVN}
+
+func validateMultiBranchPipeline(path *field.Path, pipeline *v1alpha3.MultiBranchPipeline) (errs field.ErrorList) {
+	errs = append(errs, validateDiscarder(path.Child("discarder"), pipeline.Discarder)...)
+	if pipeline.TimerTrigger != nil && pipeline.TimerTrigger.Interval != "" {
+		if _, err := strconv.ParseInt(pipeline.TimerTrigger.Interval, 10, 64); err != nil {
+			errs = append(errs, field.Invalid(path.Child("timer_trigger", "interval"), pipeline.TimerTrigger.Interval,
+				"must be the milliseconds in integer"))
+		}
+	}
+
+	// the source of the source type is required
+	requireSource := func(name string, missing bool) {
+		if missing {
+			errs = append(errs, field.Required(path.Child(name), "required by the source type "+pipeline.SourceType))
+		}
+	}
+	// the owner and repository are required by the git providers
+	requireRepo := func(name, owner, repo string) {
+		if owner == "" {
+			errs = append(errs, field.Required(path.Child(name, "owner"), ""))
+		}
+		if repo == "" {
+			errs = append(errs, field.Required(path.Child(name, "repo"), ""))
+		}
+	}
+
+	switch pipeline.SourceType {
+	case v1alpha3.SourceTypeGit:
+		requireSource("git_source", pipeline.GitSource == nil)
+		if source := pipeline.GitSource; source != nil {
+			if source.Url == "" {
+				errs = append(errs, field.Required(path.Child("git_source", "url"), ""))
+			}
+			errs = append(errs, validateRegex(path.Child("git_source", "regex_filter"), source.RegexFilter)...)
+		}
+	case v1alpha3.SourceTypeGithub:
+		requireSource("github_source", pipeline.GitHubSource == nil)
+		if source := pipeline.GitHubSource; source != nil {
+			requireRepo("github_source", source.Owner, source.Repo)
+			errs = append(errs, validateRegex(path.Child("github_source", "regex_filter"), source.RegexFilter)...)
+		}
+	case v1alpha3.SourceTypeGitlab:
+		requireSource("gitlab_source", pipeline.GitlabSource == nil)
+		if source := pipeline.GitlabSource; source != nil {
+			requireRepo("gitlab_source", source.Owner, source.Repo)
+			errs = append(errs, validateRegex(path.Child("gitlab_source", "regex_filter"), source.RegexFilter)...)
+		}
+	case v1alpha3.SourceTypeBitbucket:
+		requireSource("bitbucket_server_source", pipeline.BitbucketServerSource == nil)
+		if source := pipeline.BitbucketServerSource; source != nil {
+			requireRepo("bitbucket_server_source", source.Owner, source.Repo)
+			errs = append(errs, validateRegex(path.Child("bitbucket_server_source", "regex_filter"), source.RegexFilter)...)
+		}
+	case v1alpha3.SourceTypeGitea:
+		requireSource("gitea_source", pipeline.GiteaSource == nil)
+		if source := pipeline.GiteaSource; source != nil {
+			// Gitea is always self-hosted
+			if source.ServerUrl == "" {
+				errs = append(errs, field.Required(path.Child("gitea_source", "server_url"), ""))
+			}
+			requireRepo("gitea_source", source.Owner, source.Repo)
+			errs = append(errs, validateRegex(path.Child("gitea_source", "regex_filter"), source.RegexFilter)...)
+		}
+	case v1alpha3.SourceTypeSVN:
+		requireSource("svn_source", pipeline.SvnSource == nil)
+		if pipeline.SvnSource != nil && pipeline.SvnSource.Remote == "" {
+			errs = append(errs, field.Required(path.Child("svn_source", "remote"), ""))
+		}
+	case v1alpha3.SourceTypeSingleSVN:
+		requireSource("single_svn_source", pipeline.SingleSvnSource == nil)
+		if pipeline.SingleSvnSource != nil && pipeline.SingleSvnSource.Remote == "" {
+			errs = append(errs, field.Required(path.Child("single_svn_source", "remote"), ""))
+		}
+	case "":
+		errs = append(errs, field.Required(path.Child("source_type"), ""))
+	default:
+		errs = append(errs, field.NotSupported(path.Child("source_type"), pipeline.SourceType, supportedSourceTypes))
+	}
+	return
+}
+
+// validateDiscarder checks the discarder, the values are integers. A non-positive value means unlimited.
+func validateDiscarder(path *field.Path, discarder *v1alpha3.DiscarderProperty) (errs field.ErrorList) {
+	if discarder == nil {
+		return
+	}
+	if _, err := strconv.Atoi(discarder.DaysToKeep); discarder.DaysToKeep != "" && err != nil {
+		errs = append(errs, field.Invalid(path.Child("days_to_keep"), discarder.DaysToKeep, "must be an integer"))
+	}
+	if _, err := strconv.Atoi(discarder.NumToKeep); discarder.NumToKeep != "" && err != nil {
+		errs = append(errs, field.Invalid(path.Child("num_to_keep"), discarder.NumToKeep, "must be an integer"))
+	}
+	return
+}
+
+// getParameterTypes returns the supported parameter types of a Pipeline, they are the ones Jenkins supports
+func getParameterTypes() (types []string) {
+	for _, parameterType := range jenkins.ParameterTypeMap {
+		types = append(types, parameterType)
+	}
+	return sets.NewString(types...).List()
+}
+
+func validateParameterDefinitions(path *field.Path, parameters []v1alpha3.ParameterDefinition) (errs field.ErrorList) {
+	names := sets.NewString()
+	types := getParameterTypes()
+	supportedTypes := sets.NewString(types...)
+	for i, parameter := range parameters {
+		parameterPath := path.Index(i)
+		if parameter.Name == "" {
+			errs = append(errs, field.Required(parameterPath.Child("name"), ""))
+		} else if names.Has(parameter.Name) {
+			errs = append(errs, field.Duplicate(parameterPath.Child("name"), parameter.Name))
+		}
+		names.Insert(parameter.Name)
+
+		if !supportedTypes.Has(parameter.Type) {
+			errs = append(errs, field.NotSupported(parameterPath.Child("type"), parameter.Type, types))
+		}
+	}
+	return
+}
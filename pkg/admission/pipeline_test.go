@@ -0,0 +1,318 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package admission
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
+	"kubesphere.io/devops/pkg/client/devops"
+)
+
+// fakeCronChecker returns the given result, or an error if the result is nil
+type fakeCronChecker struct {
+	result *devops.CheckCronRes
+	checks int
+}
+
+func (c *fakeCronChecker) CheckCron(_ string, _ *devops.HttpParameters) (*devops.CheckCronRes, error) {
+	c.checks++
+	if c.result == nil {
+		return nil, apierrors.NewServiceUnavailable("jenkins is unavailable")
+	}
+	return c.result, nil
+}
+
+// getInvalidFields returns the fields of an Invalid error
+func getInvalidFields(t *testing.T, err error) (fields []string) {
+	if err == nil {
+		return
+	}
+	statusErr, ok := err.(*apierrors.StatusError)
+	if !assert.True(t, ok, "expect a StatusError but got %v", err) || !assert.True(t, apierrors.IsInvalid(err)) {
+		return
+	}
+	for _, cause := range statusErr.ErrStatus.Details.Causes {
+		fields = append(fields, cause.Field)
+	}
+	return
+}
+
+func newNoScmPipeline(noScmPipeline *v1alpha3.NoScmPipeline) *v1alpha3.Pipeline {
+	return &v1alpha3.Pipeline{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "pipeline"},
+		Spec: v1alpha3.PipelineSpec{
+			Type:     v1alpha3.NoScmPipelineType,
+			Pipeline: noScmPipeline,
+		},
+	}
+}
+
+func newMultiBranchPipeline(multiBranchPipeline *v1alpha3.MultiBranchPipeline) *v1alpha3.Pipeline {
+	return &v1alpha3.Pipeline{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "pipeline"},
+		Spec: v1alpha3.PipelineSpec{
+			Type:                v1alpha3.MultiBranchPipelineType,
+			MultiBranchPipeline: multiBranchPipeline,
+		},
+	}
+}
+
+func TestPipelineWebhook_Default(t *testing.T) {
+	tests := []struct {
+		name     string
+		pipeline *v1alpha3.Pipeline
+		wantType v1alpha3.PipelineType
+	}{{
+		name:     "infer the type of a Pipeline",
+		pipeline: &v1alpha3.Pipeline{Spec: v1alpha3.PipelineSpec{Pipeline: &v1alpha3.NoScmPipeline{}}},
+		wantType: v1alpha3.NoScmPipelineType,
+	}, {
+		name:     "infer the type of a multi-branch Pipeline",
+		pipeline: &v1alpha3.Pipeline{Spec: v1alpha3.PipelineSpec{MultiBranchPipeline: &v1alpha3.MultiBranchPipeline{}}},
+		wantType: v1alpha3.MultiBranchPipelineType,
+	}, {
+		name: "keep the given type",
+		pipeline: &v1alpha3.Pipeline{Spec: v1alpha3.PipelineSpec{
+			Type:     v1alpha3.MultiBranchPipelineType,
+			Pipeline: &v1alpha3.NoScmPipeline{},
+		}},
+		wantType: v1alpha3.MultiBranchPipelineType,
+	}, {
+		name:     "unable to infer the type",
+		pipeline: &v1alpha3.Pipeline{},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.pipeline.Name = "pipeline"
+			assert.Nil(t, (&pipelineWebhook{}).Default(context.Background(), tt.pipeline))
+			assert.Equal(t, tt.wantType, tt.pipeline.Spec.Type)
+			if tt.pipeline.Spec.Pipeline != nil {
+				assert.Equal(t, "pipeline", tt.pipeline.Spec.Pipeline.Name)
+			}
+			if tt.pipeline.Spec.MultiBranchPipeline != nil {
+				assert.Equal(t, "pipeline", tt.pipeline.Spec.MultiBranchPipeline.Name)
+			}
+		})
+	}
+}
+
+func withConcurrency(pipeline *v1alpha3.Pipeline, policy v1alpha3.ConcurrencyPolicyType, maxParallel int) *v1alpha3.Pipeline {
+	pipeline.Spec.Concurrency = &v1alpha3.ConcurrencyPolicy{Policy: policy, MaxParallel: maxParallel}
+	return pipeline
+}
+
+func withUpstreamTriggers(pipeline *v1alpha3.Pipeline, triggers ...v1alpha3.UpstreamTrigger) *v1alpha3.Pipeline {
+	pipeline.Spec.UpstreamTriggers = triggers
+	return pipeline
+}
+
+func TestPipelineWebhook_ValidateCreate(t *testing.T) {
+	tests := []struct {
+		name       string
+		pipeline   *v1alpha3.Pipeline
+		cronResult *devops.CheckCronRes
+		wantFields []string
+	}{{
+		name:     "a valid Pipeline",
+		pipeline: newNoScmPipeline(&v1alpha3.NoScmPipeline{}),
+	}, {
+		name:       "missing the type",
+		pipeline:   &v1alpha3.Pipeline{},
+		wantFields: []string{"spec.type"},
+	}, {
+		name:       "unsupported type",
+		pipeline:   &v1alpha3.Pipeline{Spec: v1alpha3.PipelineSpec{Type: "fake"}},
+		wantFields: []string{"spec.type"},
+	}, {
+		name:       "missing the Pipeline of the type",
+		pipeline:   newNoScmPipeline(nil),
+		wantFields: []string{"spec.pipeline"},
+	}, {
+		name: "invalid discarder and parameters",
+		pipeline: newNoScmPipeline(&v1alpha3.NoScmPipeline{
+			Discarder: &v1alpha3.DiscarderProperty{DaysToKeep: "seven", NumToKeep: "-1"},
+			Parameters: []v1alpha3.ParameterDefinition{
+				{Name: "branch", Type: "string"},
+				{Name: "branch", Type: "choice"},
+				{Name: "", Type: "boolean"},
+				{Name: "version", Type: "fake"},
+			},
+		}),
+		wantFields: []string{"spec.pipeline.discarder.days_to_keep", "spec.pipeline.parameters[1].name",
+			"spec.pipeline.parameters[2].name", "spec.pipeline.parameters[3].type"},
+	}, {
+		name: "invalid regexes of the generic webhook",
+		pipeline: newNoScmPipeline(&v1alpha3.NoScmPipeline{
+			GenericWebhook: &v1alpha3.GenericWebhook{
+				RequestVariables: []v1alpha3.GenericVariable{{RegexpFilter: "[a-z"}},
+				HeaderVariables:  []v1alpha3.GenericVariable{{RegexpFilter: "^master$"}},
+				FilterExpression: "(",
+			},
+		}),
+		wantFields: []string{"spec.pipeline.generic_webhook.request_variables[0].regexp_filter",
+			"spec.pipeline.generic_webhook.filter_expression"},
+	}, {
+		name: "invalid cron",
+		pipeline: newNoScmPipeline(&v1alpha3.NoScmPipeline{
+			TimerTrigger: &v1alpha3.TimerTrigger{Cron: "invalid"},
+		}),
+		cronResult: &devops.CheckCronRes{Result: "error", Message: "invalid cron"},
+		wantFields: []string{"spec.pipeline.timer_trigger.cron"},
+	}, {
+		name: "a cron with warnings",
+		pipeline: newNoScmPipeline(&v1alpha3.NoScmPipeline{
+			TimerTrigger: &v1alpha3.TimerTrigger{Cron: "* * * * *"},
+		}),
+		cronResult: &devops.CheckCronRes{Result: "warning", Message: "do you really mean every minute"},
+	}, {
+		name: "unable to check the cron",
+		pipeline: newNoScmPipeline(&v1alpha3.NoScmPipeline{
+			TimerTrigger: &v1alpha3.TimerTrigger{Cron: "H H * * *"},
+		}),
+	}, {
+		name: "a valid multi-branch Pipeline",
+		pipeline: newMultiBranchPipeline(&v1alpha3.MultiBranchPipeline{
+			SourceType:   v1alpha3.SourceTypeGithub,
+			GitHubSource: &v1alpha3.GithubSource{Owner: "kubesphere", Repo: "ks-devops", RegexFilter: "^(main|release-.*)$"},
+			TimerTrigger: &v1alpha3.TimerTrigger{Interval: "60000"},
+		}),
+	}, {
+		name:       "missing the source type",
+		pipeline:   newMultiBranchPipeline(&v1alpha3.MultiBranchPipeline{}),
+		wantFields: []string{"spec.multi_branch_pipeline.source_type"},
+	}, {
+		name:       "unsupported source type",
+		pipeline:   newMultiBranchPipeline(&v1alpha3.MultiBranchPipeline{SourceType: "fake"}),
+		wantFields: []string{"spec.multi_branch_pipeline.source_type"},
+	}, {
+		name: "the source doesn't match the source type",
+		pipeline: newMultiBranchPipeline(&v1alpha3.MultiBranchPipeline{
+			SourceType: v1alpha3.SourceTypeGitlab,
+			GitSource:  &v1alpha3.GitSource{Url: "https://gitlab.com/kubesphere/ks-devops"},
+		}),
+		wantFields: []string{"spec.multi_branch_pipeline.gitlab_source"},
+	}, {
+		name: "invalid source",
+		pipeline: newMultiBranchPipeline(&v1alpha3.MultiBranchPipeline{
+			SourceType:   v1alpha3.SourceTypeGithub,
+			GitHubSource: &v1alpha3.GithubSource{Owner: "kubesphere", RegexFilter: "*"},
+			TimerTrigger: &v1alpha3.TimerTrigger{Interval: "1h"},
+		}),
+		wantFields: []string{"spec.multi_branch_pipeline.timer_trigger.interval",
+			"spec.multi_branch_pipeline.github_source.repo", "spec.multi_branch_pipeline.github_source.regex_filter"},
+	}, {
+		name: "missing the URL of a git source",
+		pipeline: newMultiBranchPipeline(&v1alpha3.MultiBranchPipeline{
+			SourceType: v1alpha3.SourceTypeGit,
+			GitSource:  &v1alpha3.GitSource{},
+		}),
+		wantFields: []string{"spec.multi_branch_pipeline.git_source.url"},
+	}, {
+		name: "missing the server of a Gitea source",
+		pipeline: newMultiBranchPipeline(&v1alpha3.MultiBranchPipeline{
+			SourceType:  v1alpha3.SourceTypeGitea,
+			GiteaSource: &v1alpha3.GiteaSource{Owner: "kubesphere", Repo: "ks-devops"},
+		}),
+		wantFields: []string{"spec.multi_branch_pipeline.gitea_source.server_url"},
+	}, {
+		name:     "a valid concurrency policy",
+		pipeline: withConcurrency(newNoScmPipeline(&v1alpha3.NoScmPipeline{}), v1alpha3.QueueConcurrent, 2),
+	}, {
+		name:       "invalid concurrency policy",
+		pipeline:   withConcurrency(newNoScmPipeline(&v1alpha3.NoScmPipeline{}), "Skip", -1),
+		wantFields: []string{"spec.concurrency.policy", "spec.concurrency.max_parallel"},
+	}, {
+		name: "valid upstream triggers",
+		pipeline: withUpstreamTriggers(newNoScmPipeline(&v1alpha3.NoScmPipeline{}), v1alpha3.UpstreamTrigger{
+			Pipeline:     "build",
+			Phases:       []v1alpha3.RunPhase{v1alpha3.Succeeded, v1alpha3.Failed},
+			BranchFilter: "main|release-.*",
+			Parameters: []v1alpha3.Parameter{
+				{Name: "image", Value: "kubesphere/devops:$(upstream.parameters.version)"},
+				{Name: "artifact", Value: "$(upstream.artifacts.app.tar.gz)"},
+			},
+		}, v1alpha3.UpstreamTrigger{Namespace: "other", Pipeline: "pipeline"}),
+	}, {
+		name: "invalid upstream triggers",
+		pipeline: withUpstreamTriggers(newNoScmPipeline(&v1alpha3.NoScmPipeline{}), v1alpha3.UpstreamTrigger{
+			Phases:       []v1alpha3.RunPhase{v1alpha3.Running},
+			BranchFilter: "[a-z",
+			Parameters: []v1alpha3.Parameter{
+				{Name: "version", Value: "$(upstream.parameters.version)"},
+				{Name: "version", Value: "$(upstream.outputs.version)"},
+			},
+		}, v1alpha3.UpstreamTrigger{Namespace: "ns", Pipeline: "pipeline"}),
+		wantFields: []string{"spec.upstream_triggers[0].pipeline", "spec.upstream_triggers[0].phases[0]",
+			"spec.upstream_triggers[0].branch_filter", "spec.upstream_triggers[0].parameters[1].name",
+			"spec.upstream_triggers[0].parameters[1].value", "spec.upstream_triggers[1].pipeline"},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &pipelineWebhook{cronChecker: &fakeCronChecker{result: tt.cronResult}}
+			err := w.ValidateCreate(context.Background(), tt.pipeline)
+			assert.Equal(t, tt.wantFields, getInvalidFields(t, err))
+		})
+	}
+}
+
+func TestPipelineWebhook_ValidateUpdate(t *testing.T) {
+	invalidCron := &v1alpha3.TimerTrigger{Cron: "invalid"}
+	tests := []struct {
+		name        string
+		oldPipeline *v1alpha3.Pipeline
+		pipeline    *v1alpha3.Pipeline
+		wantFields  []string
+		wantChecks  int
+	}{{
+		name:        "the type is immutable",
+		oldPipeline: newNoScmPipeline(&v1alpha3.NoScmPipeline{}),
+		pipeline: newMultiBranchPipeline(&v1alpha3.MultiBranchPipeline{
+			SourceType: v1alpha3.SourceTypeGit,
+			GitSource:  &v1alpha3.GitSource{Url: "https://github.com/kubesphere/ks-devops"},
+		}),
+		wantFields: []string{"spec.type"},
+	}, {
+		name:        "skip validating an unchanged spec",
+		oldPipeline: newNoScmPipeline(&v1alpha3.NoScmPipeline{TimerTrigger: invalidCron}),
+		pipeline:    newNoScmPipeline(&v1alpha3.NoScmPipeline{TimerTrigger: invalidCron}),
+	}, {
+		name:        "skip checking an unchanged cron",
+		oldPipeline: newNoScmPipeline(&v1alpha3.NoScmPipeline{TimerTrigger: invalidCron}),
+		pipeline: newNoScmPipeline(&v1alpha3.NoScmPipeline{TimerTrigger: invalidCron,
+			Discarder: &v1alpha3.DiscarderProperty{NumToKeep: "10"}}),
+	}, {
+		name:        "check a changed cron",
+		oldPipeline: newNoScmPipeline(&v1alpha3.NoScmPipeline{}),
+		pipeline:    newNoScmPipeline(&v1alpha3.NoScmPipeline{TimerTrigger: invalidCron}),
+		wantFields:  []string{"spec.pipeline.timer_trigger.cron"},
+		wantChecks:  1,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cronChecker := &fakeCronChecker{result: &devops.CheckCronRes{Result: "error"}}
+			w := &pipelineWebhook{cronChecker: cronChecker}
+			err := w.ValidateUpdate(context.Background(), tt.oldPipeline, tt.pipeline)
+			assert.Equal(t, tt.wantFields, getInvalidFields(t, err))
+			assert.Equal(t, tt.wantChecks, cronChecker.checks)
+		})
+	}
+}
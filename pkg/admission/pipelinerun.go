@@ -0,0 +1,212 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package admission
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+//+kubebuilder:webhook:path=/mutate-devops-kubesphere-io-v1alpha3-pipelinerun,mutating=true,failurePolicy=fail,sideEffects=None,groups=devops.kubesphere.io,resources=pipelineruns,verbs=create,versions=v1alpha3,name=mpipelinerun.devops.kubesphere.io,admissionReviewVersions=v1
+//+kubebuilder:webhook:path=/validate-devops-kubesphere-io-v1alpha3-pipelinerun,mutating=false,failurePolicy=fail,sideEffects=None,groups=devops.kubesphere.io,resources=pipelineruns,verbs=create;update,versions=v1alpha3,name=vpipelinerun.devops.kubesphere.io,admissionReviewVersions=v1
+
+// pipelineRunKind is the kind of PipelineRun
+const pipelineRunKind = "PipelineRun"
+
+// pipelineRunWebhook defaults and validates the PipelineRuns
+type pipelineRunWebhook struct {
+	reader client.Reader
+}
+
+// Default labels a PipelineRun with the name of its Pipeline
+func (w *pipelineRunWebhook) Default(_ context.Context, obj runtime.Object) error {
+	pr, ok := obj.(*v1alpha3.PipelineRun)
+	if !ok {
+		return fmt.Errorf("expect a PipelineRun but got %T", obj)
+	}
+
+	if pr.Spec.PipelineRef != nil && pr.Spec.PipelineRef.Name != "" {
+		if pr.Labels == nil {
+			pr.Labels = map[string]string{}
+		}
+		if pr.Labels[v1alpha3.PipelineNameLabelKey] == "" {
+			pr.Labels[v1alpha3.PipelineNameLabelKey] = pr.Spec.PipelineRef.Name
+		}
+	}
+	return nil
+}
+
+// ValidateCreate validates a new PipelineRun against its Pipeline
+func (w *pipelineRunWebhook) ValidateCreate(ctx context.Context, obj runtime.Object) error {
+	pr, ok := obj.(*v1alpha3.PipelineRun)
+	if !ok {
+		return fmt.Errorf("expect a PipelineRun but got %T", obj)
+	}
+
+	specPath := field.NewPath("spec")
+	errs := validateAction(specPath.Child("action"), pr.Spec.Action)
+	errs = append(errs, validateParameterNames(specPath.Child("parameters"), pr.Spec.Parameters)...)
+	errs = append(errs, validateRetryPolicy(specPath.Child("retryPolicy"), pr.Spec.RetryPolicy)...)
+
+	refPath := specPath.Child("pipelineRef")
+	ref := pr.Spec.PipelineRef
+	switch {
+	case ref == nil:
+		errs = append(errs, field.Required(refPath, ""))
+	case ref.Name == "":
+		errs = append(errs, field.Required(refPath.Child("name"), ""))
+	case ref.Namespace != "" && ref.Namespace != pr.Namespace:
+		errs = append(errs, field.Invalid(refPath.Child("namespace"), ref.Namespace,
+			"must be the namespace of the PipelineRun"))
+	default:
+		pipeline := &v1alpha3.Pipeline{}
+		if err := w.reader.Get(ctx, types.NamespacedName{Namespace: pr.Namespace, Name: ref.Name}, pipeline); err != nil {
+			if !apierrors.IsNotFound(err) {
+				return err
+			}
+			errs = append(errs, field.NotFound(refPath.Child("name"), ref.Name))
+		} else {
+			errs = append(errs, validatePipelineRunWithPipeline(specPath, pr, pipeline)...)
+		}
+	}
+	return toInvalidError(pipelineRunKind, pr.Name, errs)
+}
+
+// ValidateUpdate checks the action and retry policy, and makes sure the Pipeline reference is immutable
+func (w *pipelineRunWebhook) ValidateUpdate(_ context.Context, oldObj, newObj runtime.Object) error {
+	oldPipelineRun, ok := oldObj.(*v1alpha3.PipelineRun)
+	if !ok {
+		return fmt.Errorf("expect a PipelineRun but got %T", oldObj)
+	}
+	pr, ok := newObj.(*v1alpha3.PipelineRun)
+	if !ok {
+		return fmt.Errorf("expect a PipelineRun but got %T", newObj)
+	}
+
+	specPath := field.NewPath("spec")
+	errs := validateAction(specPath.Child("action"), pr.Spec.Action)
+	errs = append(errs, validateRetryPolicy(specPath.Child("retryPolicy"), pr.Spec.RetryPolicy)...)
+	if getPipelineRefName(pr) != getPipelineRefName(oldPipelineRun) {
+		errs = append(errs, field.Forbidden(specPath.Child("pipelineRef"), "the Pipeline reference is immutable"))
+	}
+	return toInvalidError(pipelineRunKind, pr.Name, errs)
+}
+
+// ValidateDelete allows deleting any PipelineRuns
+func (w *pipelineRunWebhook) ValidateDelete(_ context.Context, _ runtime.Object) error {
+	return nil
+}
+
+func getPipelineRefName(pr *v1alpha3.PipelineRun) (name string) {
+	if pr.Spec.PipelineRef != nil {
+		name = pr.Spec.PipelineRef.Name
+	}
+	return
+}
+
+func validateAction(path *field.Path, action *v1alpha3.Action) (errs field.ErrorList) {
+	if action != nil && !action.IsValid() {
+		errs = append(errs, field.NotSupported(path, *action,
+			[]string{string(v1alpha3.Stop), string(v1alpha3.Pause), string(v1alpha3.Resume)}))
+	}
+	return
+}
+
+func validateRetryPolicy(path *field.Path, policy *v1alpha3.RetryPolicy) (errs field.ErrorList) {
+	if policy == nil {
+		return
+	}
+	if policy.MaxAttempts < 1 {
+		errs = append(errs, field.Invalid(path.Child("maxAttempts"), policy.MaxAttempts, "must be greater than 0"))
+	}
+	if policy.Backoff != nil && policy.Backoff.Duration < 0 {
+		errs = append(errs, field.Invalid(path.Child("backoff"), policy.Backoff.Duration.String(), "must not be negative"))
+	}
+	for i, reason := range policy.RetryOn {
+		if !reason.IsValid() {
+			errs = append(errs, field.NotSupported(path.Child("retryOn").Index(i), reason, []string{
+				string(v1alpha3.FailureReasonFailure), string(v1alpha3.FailureReasonUnstable),
+				string(v1alpha3.FailureReasonAborted), string(v1alpha3.FailureReasonAgentLost)}))
+		}
+	}
+	return
+}
+
+func validateParameterNames(path *field.Path, parameters []v1alpha3.Parameter) (errs field.ErrorList) {
+	names := sets.NewString()
+	for i, parameter := range parameters {
+		if parameter.Name == "" {
+			errs = append(errs, field.Required(path.Index(i).Child("name"), ""))
+		} else if names.Has(parameter.Name) {
+			errs = append(errs, field.Duplicate(path.Index(i).Child("name"), parameter.Name))
+		}
+		names.Insert(parameter.Name)
+	}
+	return
+}
+
+// validatePipelineRunWithPipeline checks the PipelineRun against its Pipeline.
+// The parameters are checked only if the PipelineRun has not started, and the Pipeline declares the parameters,
+// because the parameters of a run from Jenkins might be declared in the Jenkinsfile.
+func validatePipelineRunWithPipeline(path *field.Path, pr *v1alpha3.PipelineRun, pipeline *v1alpha3.Pipeline) (
+	errs field.ErrorList) {
+	if pipeline.IsMultiBranch() {
+		if pr.Spec.SCM == nil || pr.Spec.SCM.RefName == "" {
+			errs = append(errs, field.Required(path.Child("scm", "refName"), "required by a multi-branch Pipeline"))
+		}
+		return
+	}
+
+	if pr.HasStarted() || pipeline.Spec.Pipeline == nil || len(pipeline.Spec.Pipeline.Parameters) == 0 {
+		return
+	}
+	definitions := map[string]v1alpha3.ParameterDefinition{}
+	for _, definition := range pipeline.Spec.Pipeline.Parameters {
+		definitions[definition.Name] = definition
+	}
+	for i, parameter := range pr.Spec.Parameters {
+		valuePath := path.Child("parameters").Index(i).Child("value")
+		definition, ok := definitions[parameter.Name]
+		if !ok {
+			errs = append(errs, field.NotFound(path.Child("parameters").Index(i).Child("name"), parameter.Name))
+			continue
+		}
+
+		switch definition.Type {
+		case "boolean":
+			if _, err := strconv.ParseBool(parameter.Value); err != nil {
+				errs = append(errs, field.Invalid(valuePath, parameter.Value, "must be a boolean"))
+			}
+		case "choice":
+			// the choices are separated by lines, see also the Jenkins job of a Pipeline
+			if choices := strings.Split(definition.DefaultValue, "\n"); !sets.NewString(choices...).Has(parameter.Value) {
+				errs = append(errs, field.NotSupported(valuePath, parameter.Value, choices))
+			}
+		}
+	}
+	return
+}
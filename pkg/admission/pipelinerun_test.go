@@ -0,0 +1,172 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package admission
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newPipelineRun(pipelineName string, parameters ...v1alpha3.Parameter) *v1alpha3.PipelineRun {
+	pr := &v1alpha3.PipelineRun{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "pipelinerun"},
+		Spec:       v1alpha3.PipelineRunSpec{Parameters: parameters},
+	}
+	if pipelineName != "" {
+		pr.Spec.PipelineRef = &corev1.ObjectReference{Name: pipelineName}
+	}
+	return pr
+}
+
+func TestPipelineRunWebhook_Default(t *testing.T) {
+	pr := newPipelineRun("pipeline")
+	assert.Nil(t, (&pipelineRunWebhook{}).Default(context.Background(), pr))
+	assert.Equal(t, "pipeline", pr.Labels[v1alpha3.PipelineNameLabelKey])
+
+	pr = newPipelineRun("")
+	assert.Nil(t, (&pipelineRunWebhook{}).Default(context.Background(), pr))
+	assert.Empty(t, pr.Labels)
+}
+
+func TestPipelineRunWebhook_ValidateCreate(t *testing.T) {
+	schema, err := v1alpha3.SchemeBuilder.Register().Build()
+	assert.Nil(t, err)
+
+	pipeline := newNoScmPipeline(&v1alpha3.NoScmPipeline{
+		Parameters: []v1alpha3.ParameterDefinition{
+			{Name: "debug", Type: "boolean"},
+			{Name: "env", Type: "choice", DefaultValue: "dev\ntest\nprod"},
+			{Name: "version", Type: "string"},
+		},
+	})
+	multiBranchPipeline := newMultiBranchPipeline(&v1alpha3.MultiBranchPipeline{})
+	multiBranchPipeline.Name = "multi-branch-pipeline"
+
+	invalidAction := v1alpha3.Action("fake")
+	startedPipelineRun := newPipelineRun("pipeline", v1alpha3.Parameter{Name: "unknown"})
+	startedPipelineRun.Annotations = map[string]string{v1alpha3.JenkinsPipelineRunIDAnnoKey: "1"}
+
+	tests := []struct {
+		name        string
+		pipelineRun *v1alpha3.PipelineRun
+		wantFields  []string
+	}{{
+		name: "a valid PipelineRun",
+		pipelineRun: newPipelineRun("pipeline", v1alpha3.Parameter{Name: "debug", Value: "true"},
+			v1alpha3.Parameter{Name: "env", Value: "prod"}, v1alpha3.Parameter{Name: "version", Value: "v1.0.0"}),
+	}, {
+		name:        "missing the Pipeline reference",
+		pipelineRun: newPipelineRun(""),
+		wantFields:  []string{"spec.pipelineRef"},
+	}, {
+		name: "a Pipeline from another namespace",
+		pipelineRun: func() *v1alpha3.PipelineRun {
+			pr := newPipelineRun("pipeline")
+			pr.Spec.PipelineRef.Namespace = "another"
+			return pr
+		}(),
+		wantFields: []string{"spec.pipelineRef.namespace"},
+	}, {
+		name:        "the Pipeline doesn't exist",
+		pipelineRun: newPipelineRun("fake"),
+		wantFields:  []string{"spec.pipelineRef.name"},
+	}, {
+		name: "invalid action",
+		pipelineRun: func() *v1alpha3.PipelineRun {
+			pr := newPipelineRun("pipeline")
+			pr.Spec.Action = &invalidAction
+			return pr
+		}(),
+		wantFields: []string{"spec.action"},
+	}, {
+		name: "invalid parameters",
+		pipelineRun: newPipelineRun("pipeline", v1alpha3.Parameter{Name: "debug", Value: "yes please"},
+			v1alpha3.Parameter{Name: "env", Value: "staging"}, v1alpha3.Parameter{Name: "unknown"},
+			v1alpha3.Parameter{Name: "env", Value: "dev"}),
+		wantFields: []string{"spec.parameters[3].name", "spec.parameters[0].value", "spec.parameters[1].value",
+			"spec.parameters[2].name"},
+	}, {
+		name: "a valid retry policy",
+		pipelineRun: func() *v1alpha3.PipelineRun {
+			pr := newPipelineRun("pipeline")
+			pr.Spec.RetryPolicy = &v1alpha3.RetryPolicy{
+				MaxAttempts: 3,
+				Backoff:     &metav1.Duration{Duration: time.Minute},
+				RetryOn:     []v1alpha3.FailureReason{v1alpha3.FailureReasonAgentLost},
+			}
+			return pr
+		}(),
+	}, {
+		name: "invalid retry policy",
+		pipelineRun: func() *v1alpha3.PipelineRun {
+			pr := newPipelineRun("pipeline")
+			pr.Spec.RetryPolicy = &v1alpha3.RetryPolicy{
+				Backoff: &metav1.Duration{Duration: -time.Minute},
+				RetryOn: []v1alpha3.FailureReason{v1alpha3.FailureReasonAgentLost, "Timeout"},
+			}
+			return pr
+		}(),
+		wantFields: []string{"spec.retryPolicy.maxAttempts", "spec.retryPolicy.backoff", "spec.retryPolicy.retryOn[1]"},
+	}, {
+		name:        "skip checking the parameters of a started PipelineRun",
+		pipelineRun: startedPipelineRun,
+	}, {
+		name:        "missing the branch of a multi-branch Pipeline",
+		pipelineRun: newPipelineRun("multi-branch-pipeline"),
+		wantFields:  []string{"spec.scm.refName"},
+	}, {
+		name: "a valid PipelineRun of a multi-branch Pipeline",
+		pipelineRun: func() *v1alpha3.PipelineRun {
+			pr := newPipelineRun("multi-branch-pipeline")
+			pr.Spec.SCM = &v1alpha3.SCM{RefType: "branch", RefName: "main"}
+			return pr
+		}(),
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &pipelineRunWebhook{
+				reader: fake.NewClientBuilder().WithScheme(schema).WithObjects(pipeline.DeepCopy(),
+					multiBranchPipeline.DeepCopy()).Build(),
+			}
+			err := w.ValidateCreate(context.Background(), tt.pipelineRun)
+			assert.Equal(t, tt.wantFields, getInvalidFields(t, err))
+		})
+	}
+}
+
+func TestPipelineRunWebhook_ValidateUpdate(t *testing.T) {
+	stop := v1alpha3.Stop
+	stopped := newPipelineRun("pipeline")
+	stopped.Spec.Action = &stop
+
+	w := &pipelineRunWebhook{}
+	assert.Nil(t, w.ValidateUpdate(context.Background(), newPipelineRun("pipeline"), stopped))
+	assert.Equal(t, []string{"spec.pipelineRef"}, getInvalidFields(t,
+		w.ValidateUpdate(context.Background(), newPipelineRun("pipeline"), newPipelineRun("another"))))
+
+	retried := newPipelineRun("pipeline")
+	retried.Spec.RetryPolicy = &v1alpha3.RetryPolicy{}
+	assert.Equal(t, []string{"spec.retryPolicy.maxAttempts"}, getInvalidFields(t,
+		w.ValidateUpdate(context.Background(), newPipelineRun("pipeline"), retried)))
+}
@@ -0,0 +1,116 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package admission
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
+	"kubesphere.io/devops/pkg/utils/cronutil"
+)
+
+//+kubebuilder:webhook:path=/validate-devops-kubesphere-io-v1alpha3-pipelineschedule,mutating=false,failurePolicy=fail,sideEffects=None,groups=devops.kubesphere.io,resources=pipelineschedules,verbs=create;update,versions=v1alpha3,name=vpipelineschedule.devops.kubesphere.io,admissionReviewVersions=v1
+
+// pipelineScheduleWebhook validates the PipelineSchedules
+type pipelineScheduleWebhook struct {
+	cronChecker CronChecker
+}
+
+// ValidateCreate validates a new PipelineSchedule
+func (w *pipelineScheduleWebhook) ValidateCreate(_ context.Context, obj runtime.Object) error {
+	schedule, ok := obj.(*v1alpha3.PipelineSchedule)
+	if !ok {
+		return fmt.Errorf("expect a PipelineSchedule but got %T", obj)
+	}
+	errs := w.validateSpec(schedule, nil)
+	return toInvalidError(v1alpha3.ResourceKindPipelineSchedule, schedule.Name, errs)
+}
+
+// ValidateUpdate validates the spec of a PipelineSchedule only if it was changed
+func (w *pipelineScheduleWebhook) ValidateUpdate(_ context.Context, oldObj, newObj runtime.Object) error {
+	oldSchedule, ok := oldObj.(*v1alpha3.PipelineSchedule)
+	if !ok {
+		return fmt.Errorf("expect a PipelineSchedule but got %T", oldObj)
+	}
+	schedule, ok := newObj.(*v1alpha3.PipelineSchedule)
+	if !ok {
+		return fmt.Errorf("expect a PipelineSchedule but got %T", newObj)
+	}
+
+	var errs field.ErrorList
+	if !reflect.DeepEqual(schedule.Spec, oldSchedule.Spec) {
+		errs = w.validateSpec(schedule, oldSchedule)
+	}
+	return toInvalidError(v1alpha3.ResourceKindPipelineSchedule, schedule.Name, errs)
+}
+
+// ValidateDelete allows deleting any PipelineSchedules
+func (w *pipelineScheduleWebhook) ValidateDelete(_ context.Context, _ runtime.Object) error {
+	return nil
+}
+
+// validateSpec validates the spec, the cron is checked via Jenkins only if it was changed
+func (w *pipelineScheduleWebhook) validateSpec(schedule, oldSchedule *v1alpha3.PipelineSchedule) (errs field.ErrorList) {
+	specPath := field.NewPath("spec")
+	spec := &schedule.Spec
+
+	refPath := specPath.Child("pipelineRef")
+	switch ref := spec.PipelineRef; {
+	case ref == nil:
+		errs = append(errs, field.Required(refPath, ""))
+	case ref.Name == "":
+		errs = append(errs, field.Required(refPath.Child("name"), ""))
+	case ref.Namespace != "" && ref.Namespace != schedule.Namespace:
+		errs = append(errs, field.Invalid(refPath.Child("namespace"), ref.Namespace,
+			"must be the namespace of the PipelineSchedule"))
+	}
+
+	cronPath := specPath.Child("cron")
+	if spec.Cron == "" {
+		errs = append(errs, field.Required(cronPath, ""))
+	} else if _, err := cronutil.Parse(spec.Cron, schedule.Name); err != nil {
+		errs = append(errs, field.Invalid(cronPath, spec.Cron, err.Error()))
+	} else if oldSchedule == nil || spec.Cron != oldSchedule.Spec.Cron {
+		errs = append(errs, checkCron(w.cronChecker, cronPath, schedule.Namespace, spec.Cron)...)
+	}
+	if _, err := time.LoadLocation(spec.TimeZone); err != nil {
+		errs = append(errs, field.Invalid(specPath.Child("timeZone"), spec.TimeZone, err.Error()))
+	}
+
+	errs = append(errs, validateParameterNames(specPath.Child("parameters"), spec.Parameters)...)
+	switch spec.ConcurrencyPolicy {
+	case "", v1alpha3.AllowConcurrent, v1alpha3.ForbidConcurrent, v1alpha3.ReplaceConcurrent:
+	default:
+		errs = append(errs, field.NotSupported(specPath.Child("concurrencyPolicy"), spec.ConcurrencyPolicy, []string{
+			string(v1alpha3.AllowConcurrent), string(v1alpha3.ForbidConcurrent), string(v1alpha3.ReplaceConcurrent)}))
+	}
+	if deadline := spec.StartingDeadlineSeconds; deadline != nil && *deadline <= 0 {
+		errs = append(errs, field.Invalid(specPath.Child("startingDeadlineSeconds"), *deadline, "must be greater than 0"))
+	}
+	if limit := spec.SuccessfulRunsHistoryLimit; limit != nil && *limit < 0 {
+		errs = append(errs, field.Invalid(specPath.Child("successfulRunsHistoryLimit"), *limit, "must not be negative"))
+	}
+	if limit := spec.FailedRunsHistoryLimit; limit != nil && *limit < 0 {
+		errs = append(errs, field.Invalid(specPath.Child("failedRunsHistoryLimit"), *limit, "must not be negative"))
+	}
+	return
+}
@@ -0,0 +1,143 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package admission
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
+	"kubesphere.io/devops/pkg/client/devops"
+)
+
+func newPipelineSchedule(spec v1alpha3.PipelineScheduleSpec) *v1alpha3.PipelineSchedule {
+	return &v1alpha3.PipelineSchedule{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "nightly"},
+		Spec:       spec,
+	}
+}
+
+func TestPipelineScheduleWebhook_ValidateCreate(t *testing.T) {
+	negative := int32(-1)
+	zero := int64(0)
+	tests := []struct {
+		name       string
+		schedule   *v1alpha3.PipelineSchedule
+		cronResult *devops.CheckCronRes
+		wantFields []string
+	}{{
+		name: "a valid PipelineSchedule",
+		schedule: newPipelineSchedule(v1alpha3.PipelineScheduleSpec{
+			PipelineRef:       &v1.ObjectReference{Name: "build"},
+			Cron:              "H 2 * * 1-5",
+			TimeZone:          "Asia/Shanghai",
+			Parameters:        []v1alpha3.Parameter{{Name: "version", Value: "nightly"}},
+			ConcurrencyPolicy: v1alpha3.ForbidConcurrent,
+		}),
+		cronResult: &devops.CheckCronRes{Result: "ok"},
+	}, {
+		name:       "missing the Pipeline and cron",
+		schedule:   newPipelineSchedule(v1alpha3.PipelineScheduleSpec{}),
+		wantFields: []string{"spec.pipelineRef", "spec.cron"},
+	}, {
+		name: "the Pipeline is in another namespace",
+		schedule: newPipelineSchedule(v1alpha3.PipelineScheduleSpec{
+			PipelineRef: &v1.ObjectReference{Name: "build", Namespace: "other"},
+			Cron:        "@daily",
+		}),
+		wantFields: []string{"spec.pipelineRef.namespace"},
+	}, {
+		name: "unable to parse the cron",
+		schedule: newPipelineSchedule(v1alpha3.PipelineScheduleSpec{
+			PipelineRef: &v1.ObjectReference{Name: "build"},
+			Cron:        "* * *",
+		}),
+		wantFields: []string{"spec.cron"},
+	}, {
+		name: "Jenkins rejects the cron",
+		schedule: newPipelineSchedule(v1alpha3.PipelineScheduleSpec{
+			PipelineRef: &v1.ObjectReference{Name: "build"},
+			Cron:        "0 0 31 2 *",
+		}),
+		cronResult: &devops.CheckCronRes{Result: "error", Message: "the cron never runs"},
+		wantFields: []string{"spec.cron"},
+	}, {
+		name: "invalid fields",
+		schedule: newPipelineSchedule(v1alpha3.PipelineScheduleSpec{
+			PipelineRef:                &v1.ObjectReference{Name: "build"},
+			Cron:                       "@daily",
+			TimeZone:                   "Mars/Olympus",
+			Parameters:                 []v1alpha3.Parameter{{Name: "version"}, {Name: "version"}},
+			ConcurrencyPolicy:          v1alpha3.QueueConcurrent,
+			StartingDeadlineSeconds:    &zero,
+			SuccessfulRunsHistoryLimit: &negative,
+			FailedRunsHistoryLimit:     &negative,
+		}),
+		wantFields: []string{"spec.timeZone", "spec.parameters[1].name", "spec.concurrencyPolicy",
+			"spec.startingDeadlineSeconds", "spec.successfulRunsHistoryLimit", "spec.failedRunsHistoryLimit"},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &pipelineScheduleWebhook{cronChecker: &fakeCronChecker{result: tt.cronResult}}
+			err := w.ValidateCreate(context.Background(), tt.schedule)
+			assert.Equal(t, tt.wantFields, getInvalidFields(t, err))
+		})
+	}
+}
+
+func TestPipelineScheduleWebhook_ValidateUpdate(t *testing.T) {
+	schedule := newPipelineSchedule(v1alpha3.PipelineScheduleSpec{
+		PipelineRef: &v1.ObjectReference{Name: "build"},
+		Cron:        "H 2 * * *",
+	})
+	suspended := schedule.DeepCopy()
+	suspended.Spec.Suspend = true
+	changedCron := schedule.DeepCopy()
+	changedCron.Spec.Cron = "H 3 * * *"
+
+	tests := []struct {
+		name        string
+		oldSchedule *v1alpha3.PipelineSchedule
+		schedule    *v1alpha3.PipelineSchedule
+		wantChecks  int
+	}{{
+		name:        "skip validating an unchanged spec",
+		oldSchedule: schedule,
+		schedule:    schedule,
+	}, {
+		name:        "skip checking an unchanged cron",
+		oldSchedule: schedule,
+		schedule:    suspended,
+	}, {
+		name:        "check a changed cron",
+		oldSchedule: schedule,
+		schedule:    changedCron,
+		wantChecks:  1,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checker := &fakeCronChecker{result: &devops.CheckCronRes{Result: "ok"}}
+			w := &pipelineScheduleWebhook{cronChecker: checker}
+			err := w.ValidateUpdate(context.Background(), tt.oldSchedule, tt.schedule)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.wantChecks, checker.checks)
+		})
+	}
+}
@@ -0,0 +1,149 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package admission
+
+import (
+	"context"
+	"fmt"
+	"text/template"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
+	tmpl "kubesphere.io/devops/pkg/kapis/devops/v1alpha3/template"
+)
+
+//+kubebuilder:webhook:path=/validate-devops-kubesphere-io-v1alpha3-template,mutating=false,failurePolicy=fail,sideEffects=None,groups=devops.kubesphere.io,resources=templates,verbs=create;update,versions=v1alpha3,name=vtemplate.devops.kubesphere.io,admissionReviewVersions=v1
+//+kubebuilder:webhook:path=/validate-devops-kubesphere-io-v1alpha3-clustertemplate,mutating=false,failurePolicy=fail,sideEffects=None,groups=devops.kubesphere.io,resources=clustertemplates,verbs=create;update,versions=v1alpha3,name=vclustertemplate.devops.kubesphere.io,admissionReviewVersions=v1
+//+kubebuilder:webhook:path=/validate-devops-kubesphere-io-v1alpha3-clustersteptemplate,mutating=false,failurePolicy=fail,sideEffects=None,groups=devops.kubesphere.io,resources=clustersteptemplates,verbs=create;update,versions=v1alpha3,name=vclustersteptemplate.devops.kubesphere.io,admissionReviewVersions=v1
+
+// templateWebhook validates the Templates and ClusterTemplates
+type templateWebhook struct{}
+
+// ValidateCreate validates a new Template or ClusterTemplate
+func (w *templateWebhook) ValidateCreate(_ context.Context, obj runtime.Object) error {
+	return validateTemplate(obj)
+}
+
+// ValidateUpdate validates a Template or ClusterTemplate
+func (w *templateWebhook) ValidateUpdate(_ context.Context, _, newObj runtime.Object) error {
+	return validateTemplate(newObj)
+}
+
+// ValidateDelete allows deleting any templates
+func (w *templateWebhook) ValidateDelete(_ context.Context, _ runtime.Object) error {
+	return nil
+}
+
+func validateTemplate(obj runtime.Object) error {
+	templateObject, ok := obj.(v1alpha3.TemplateObject)
+	if !ok {
+		return fmt.Errorf("expect a template but got %T", obj)
+	}
+	kind := "Template"
+	if _, ok = templateObject.(*v1alpha3.ClusterTemplate); ok {
+		kind = "ClusterTemplate"
+	}
+
+	var errs field.ErrorList
+	specPath := field.NewPath("spec")
+	spec := templateObject.TemplateSpec()
+	if _, err := tmpl.ParseTemplate(templateObject.GetName(), spec.Template); err != nil {
+		errs = append(errs, field.Invalid(specPath.Child("template"), spec.Template, err.Error()))
+	}
+
+	names := sets.NewString()
+	for i, parameter := range spec.Parameters {
+		parameterPath := specPath.Child("parameters").Index(i)
+		if parameter.Name == "" {
+			errs = append(errs, field.Required(parameterPath.Child("name"), ""))
+		} else if names.Has(parameter.Name) {
+			errs = append(errs, field.Duplicate(parameterPath.Child("name"), parameter.Name))
+		}
+		names.Insert(parameter.Name)
+
+		if parameter.Validation != nil && parameter.Validation.Expression != "" {
+			if err := tmpl.CompileExpression(parameter.Validation.Expression); err != nil {
+				errs = append(errs, field.Invalid(parameterPath.Child("validation", "expression"),
+					parameter.Validation.Expression, err.Error()))
+			}
+		}
+	}
+	return toInvalidError(kind, templateObject.GetName(), errs)
+}
+
+// stepTemplateWebhook validates the ClusterStepTemplates
+type stepTemplateWebhook struct{}
+
+// ValidateCreate validates a new ClusterStepTemplate
+func (w *stepTemplateWebhook) ValidateCreate(_ context.Context, obj runtime.Object) error {
+	return validateStepTemplate(obj)
+}
+
+// ValidateUpdate validates a ClusterStepTemplate
+func (w *stepTemplateWebhook) ValidateUpdate(_ context.Context, _, newObj runtime.Object) error {
+	return validateStepTemplate(newObj)
+}
+
+// ValidateDelete allows deleting any ClusterStepTemplates
+func (w *stepTemplateWebhook) ValidateDelete(_ context.Context, _ runtime.Object) error {
+	return nil
+}
+
+// supportedStepParameterTypes are all the parameter types of a step template, an empty type is a string
+var supportedStepParameterTypes = []string{"", string(v1alpha3.ParameterTypeString), string(v1alpha3.ParameterTypeText),
+	string(v1alpha3.ParameterTypeNumber), string(v1alpha3.ParameterTypeCode), string(v1alpha3.ParameterTypeBool),
+	string(v1alpha3.ParameterTypeEnum), string(v1alpha3.ParameterTypeSecret), string(v1alpha3.ParameterTypeHidden),
+	string(v1alpha3.ParameterTypeImportCodeRepo)}
+
+// supportedStepRuntimes are all the runtimes of a step template, an empty runtime is shell
+var supportedStepRuntimes = []string{"", "dsl", "shell"}
+
+func validateStepTemplate(obj runtime.Object) error {
+	stepTemplate, ok := obj.(*v1alpha3.ClusterStepTemplate)
+	if !ok {
+		return fmt.Errorf("expect a ClusterStepTemplate but got %T", obj)
+	}
+
+	var errs field.ErrorList
+	specPath := field.NewPath("spec")
+	spec := stepTemplate.Spec
+	if !sets.NewString(supportedStepRuntimes...).Has(spec.Runtime) {
+		errs = append(errs, field.NotSupported(specPath.Child("runtime"), spec.Runtime, supportedStepRuntimes))
+	}
+	// it's the same as the step template is rendered
+	if _, err := template.New(stepTemplate.Name).Parse(spec.Template); err != nil {
+		errs = append(errs, field.Invalid(specPath.Child("template"), spec.Template, err.Error()))
+	}
+
+	names := sets.NewString()
+	for i, parameter := range spec.Parameters {
+		parameterPath := specPath.Child("parameters").Index(i)
+		if parameter.Name == "" {
+			errs = append(errs, field.Required(parameterPath.Child("name"), ""))
+		} else if names.Has(parameter.Name) {
+			errs = append(errs, field.Duplicate(parameterPath.Child("name"), parameter.Name))
+		}
+		names.Insert(parameter.Name)
+
+		if !sets.NewString(supportedStepParameterTypes...).Has(string(parameter.Type)) {
+			errs = append(errs, field.NotSupported(parameterPath.Child("type"), parameter.Type, supportedStepParameterTypes))
+		}
+	}
+	return toInvalidError("ClusterStepTemplate", stepTemplate.Name, errs)
+}
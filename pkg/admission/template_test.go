@@ -0,0 +1,116 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package admission
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
+)
+
+func TestTemplateWebhook_ValidateCreate(t *testing.T) {
+	tests := []struct {
+		name       string
+		template   v1alpha3.TemplateObject
+		wantFields []string
+	}{{
+		name: "a valid Template",
+		template: &v1alpha3.Template{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "template"},
+			Spec: v1alpha3.TemplateSpec{
+				Template: `pipeline { agent { label '$(.label)' } }`,
+				Parameters: []v1alpha3.TemplateParameter{{
+					Name:       "label",
+					Validation: &v1alpha3.ParameterValidation{Expression: `startsWith('go')`},
+				}},
+			},
+		},
+	}, {
+		name: "an invalid Template",
+		template: &v1alpha3.Template{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "template"},
+			Spec: v1alpha3.TemplateSpec{
+				Template: `pipeline { agent { label '$(.label' } }`,
+				Parameters: []v1alpha3.TemplateParameter{{
+					Name:       "label",
+					Validation: &v1alpha3.ParameterValidation{Expression: `matches('[')`},
+				}, {
+					Name: "label",
+				}, {
+					Name: "",
+				}},
+			},
+		},
+		wantFields: []string{"spec.template", "spec.parameters[0].validation.expression",
+			"spec.parameters[1].name", "spec.parameters[2].name"},
+	}, {
+		name: "an invalid ClusterTemplate",
+		template: &v1alpha3.ClusterTemplate{
+			ObjectMeta: metav1.ObjectMeta{Name: "template"},
+			Spec: v1alpha3.TemplateSpec{
+				Template: `$(if .debug)`,
+			},
+		},
+		wantFields: []string{"spec.template"},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := (&templateWebhook{}).ValidateCreate(context.Background(), tt.template)
+			assert.Equal(t, tt.wantFields, getInvalidFields(t, err))
+		})
+	}
+}
+
+func TestStepTemplateWebhook_ValidateCreate(t *testing.T) {
+	tests := []struct {
+		name         string
+		stepTemplate *v1alpha3.ClusterStepTemplate
+		wantFields   []string
+	}{{
+		name: "a valid ClusterStepTemplate",
+		stepTemplate: &v1alpha3.ClusterStepTemplate{
+			ObjectMeta: metav1.ObjectMeta{Name: "echo"},
+			Spec: v1alpha3.StepTemplateSpec{
+				Runtime:    "shell",
+				Template:   `echo {{.param.message}}`,
+				Parameters: []v1alpha3.ParameterInStep{{Name: "message"}, {Name: "debug", Type: v1alpha3.ParameterTypeBool}},
+			},
+		},
+	}, {
+		name: "an invalid ClusterStepTemplate",
+		stepTemplate: &v1alpha3.ClusterStepTemplate{
+			ObjectMeta: metav1.ObjectMeta{Name: "echo"},
+			Spec: v1alpha3.StepTemplateSpec{
+				Runtime:  "python",
+				Template: `echo {{.param.message`,
+				Parameters: []v1alpha3.ParameterInStep{{Name: "message", Type: "float"}, {Name: "message"},
+					{Name: ""}},
+			},
+		},
+		wantFields: []string{"spec.runtime", "spec.template", "spec.parameters[0].type", "spec.parameters[1].name",
+			"spec.parameters[2].name"},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := (&stepTemplateWebhook{}).ValidateCreate(context.Background(), tt.stepTemplate)
+			assert.Equal(t, tt.wantFields, getInvalidFields(t, err))
+		})
+	}
+}
@@ -31,12 +31,90 @@ type AddonSpec struct {
 
 // AddonStatus represents the status of an addon
 type AddonStatus struct {
-	Phase string `json:"phase,omitempty"`
+	Phase AddonPhase `json:"phase,omitempty"`
+	// Version is the version of the addon which was installed
+	Version string `json:"version,omitempty"`
+	// ObservedGeneration is the generation of the addon which was reconciled
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Conditions are the conditions of the addon, the types are Installed and Ready
+	Conditions []Condition `json:"conditions,omitempty"`
+	// Resources are the objects which were rendered from the strategy
+	Resources []AddonResource `json:"resources,omitempty"`
+}
+
+// AddonResource represents an object which belongs to an addon
+type AddonResource struct {
+	APIVersion string `json:"apiVersion,omitempty"`
+	Kind       string `json:"kind,omitempty"`
+	Namespace  string `json:"namespace,omitempty"`
+	Name       string `json:"name,omitempty"`
+	// Ready indicates if the object is healthy
+	Ready bool `json:"ready"`
+	// Message is the reason why the object is not ready
+	Message string `json:"message,omitempty"`
+}
+
+// AddonPhase represents the phase of an addon
+type AddonPhase string
+
+const (
+	// AddonPhaseInstalling means the resources were applied, but some of them are not ready yet
+	AddonPhaseInstalling AddonPhase = "Installing"
+	// AddonPhaseReady means all the resources are ready
+	AddonPhaseReady AddonPhase = "Ready"
+	// AddonPhaseFailed means failed to render or apply the resources
+	AddonPhaseFailed AddonPhase = "Failed"
+	// AddonPhaseDeleting means the resources are being deleted
+	AddonPhaseDeleting AddonPhase = "Deleting"
+)
+
+const (
+	// AddonConditionInstalled indicates whether the resources of an addon were applied
+	AddonConditionInstalled ConditionType = "Installed"
+	// AddonConditionReady indicates whether all the resources of an addon are ready
+	AddonConditionReady ConditionType = "Ready"
+)
+
+// GetCondition returns the condition with the given type, it is nil if not found.
+func (status *AddonStatus) GetCondition(conditionType ConditionType) *Condition {
+	for i := range status.Conditions {
+		if status.Conditions[i].Type == conditionType {
+			return &status.Conditions[i]
+		}
+	}
+	return nil
+}
+
+// SetCondition adds or replaces the condition with the same type.
+// The last transition time only changes when the status of the condition changes.
+func (status *AddonStatus) SetCondition(newCondition Condition) {
+	now := metav1.Now()
+	newCondition.LastProbeTime = now
+	newCondition.LastTransitionTime = now
+	if existing := status.GetCondition(newCondition.Type); existing != nil {
+		if existing.Status == newCondition.Status {
+			newCondition.LastTransitionTime = existing.LastTransitionTime
+		}
+		*existing = newCondition
+		return
+	}
+	status.Conditions = append(status.Conditions, newCondition)
+}
+
+// IsReady returns true if all the resources of the addon are ready
+func (status *AddonStatus) IsReady() bool {
+	condition := status.GetCondition(AddonConditionReady)
+	return condition != nil && condition.Status == ConditionTrue
 }
 
 // +genclient
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 // +kubebuilder:printcolumn:name="Version",type=string,JSONPath=`.spec.version`,description="The version of target addon"
+// +kubebuilder:printcolumn:name="Strategy",type=string,JSONPath=`.spec.strategy.name`,description="The install strategy of target addon"
+// +kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`,description="The phase of target addon"
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`,description="Whether all the resources of target addon are ready"
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+// +kubebuilder:subresource:status
 // +kubebuilder:object:root=true
 // +k8s:openapi-gen=true
 
@@ -29,6 +29,7 @@ type AddStrategySpec struct {
 	Operator       v1.ObjectReference   `json:"operator,omitempty"`
 	SimpleOperator v1.ObjectReference   `json:"simpleOperator,omitempty"`
 	HelmRepo       string               `json:"helmRepo,omitempty"`
+	Chart          string               `json:"chart,omitempty"`
 	Template       string               `json:"template,omitempty"`
 	Parameters     map[string]string    `json:"parameters,omitempty"`
 }
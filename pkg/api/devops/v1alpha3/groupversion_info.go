@@ -40,6 +40,65 @@ const (
 	PipelineRunSCMRefNameField = "spec.scm.ref-name"
 	// PipelineRunIdentifierIndexerName is an indexer name of PipelineRun identifier.
 	PipelineRunIdentifierIndexerName = "pipelinerun.identifier"
+	// PipelineGitURLIndexerName is an indexer name of the normalized git URLs of Pipeline.
+	PipelineGitURLIndexerName = "pipeline.git-url"
+	// GitRepositoryURLIndexerName is an indexer name of the normalized URL of GitRepository.
+	GitRepositoryURLIndexerName = "gitrepository.url"
+	// PipelineWebhookAllowUnsignedAnnoKey is annotation key of a Pipeline, the SCM webhook deliveries are able to trigger
+	// the Pipeline without any signature if it's "true" and no secret is configured for the repository.
+	PipelineWebhookAllowUnsignedAnnoKey = devops.GroupName + "/webhook-allow-unsigned"
+	// PipelineRunEngineAnnoKey is annotation key of the execution engine. It could be set on a PipelineRun,
+	// a Pipeline or a DevOpsProject, and the first one found in that order takes effect.
+	PipelineRunEngineAnnoKey = devops.GroupName + "/engine"
+	// PipelineRunArchivedAnnoKey is annotation key which indicates the stages and logs of a completed PipelineRun
+	// were archived into the data store already.
+	PipelineRunArchivedAnnoKey = devops.GroupName + "/pipelinerun-archived"
+	// PipelineRunPinnedAnnoKey is annotation key which indicates a PipelineRun is never deleted by the garbage collector.
+	PipelineRunPinnedAnnoKey = devops.GroupName + "/pipelinerun-pinned"
+	// PipelineRunDataStoreAnnoKey is annotation key of the data store type of the stages, it's set by the PipelineRun controller.
+	// The apiserver stores the stages of a completed PipelineRun in the same data store.
+	PipelineRunDataStoreAnnoKey = devops.GroupName + "/pipelinerun-data-store"
+	// PipelineLastRunIDAnnoKey is annotation key of a Pipeline, it's the last run ID allocated by the kubernetes engine.
+	// It's updated with optimistic concurrency, so the run IDs are never duplicated or reused.
+	PipelineLastRunIDAnnoKey = devops.GroupName + "/pipeline-last-run-id"
+	// PipelineRunDaysToKeepAnnoKey is annotation key of a DevOpsProject, it's the default days to keep the PipelineRuns
+	// of the Pipelines which don't have it in the discarder.
+	PipelineRunDaysToKeepAnnoKey = devops.GroupName + "/pipelinerun-days-to-keep"
+	// PipelineRunNumToKeepAnnoKey is annotation key of a DevOpsProject, it's the default number of PipelineRuns to keep
+	// of the Pipelines which don't have it in the discarder.
+	PipelineRunNumToKeepAnnoKey = devops.GroupName + "/pipelinerun-num-to-keep"
+	// PipelineRunMaxParallelAnnoKey is annotation key of a DevOpsProject, it's the max number of running PipelineRuns
+	// of the project. The new PipelineRuns are held in the queue once it was reached.
+	PipelineRunMaxParallelAnnoKey = devops.GroupName + "/pipelinerun-max-parallel"
+	// PipelineRunRootLabelKey is label key of the name of the first PipelineRun, the retries and reruns of it
+	// and their descendants have the same root.
+	PipelineRunRootLabelKey = devops.GroupName + "/pipelinerun-root"
+	// PipelineRunParentLabelKey is label key of the name of the PipelineRun which the current one retries or reruns.
+	PipelineRunParentLabelKey = devops.GroupName + "/pipelinerun-parent"
+	// PipelineRunAttemptLabelKey is label key of the attempt number of a PipelineRun, the first attempt is 1.
+	PipelineRunAttemptLabelKey = devops.GroupName + "/pipelinerun-attempt"
+	// PipelineRunRestartStageAnnoKey is annotation key of the stage which a rerun PipelineRun restarts from.
+	PipelineRunRestartStageAnnoKey = devops.GroupName + "/pipelinerun-restart-stage"
+	// PipelineRunRetryReasonAnnoKey is annotation key of the failure reason which a PipelineRun was retried for.
+	PipelineRunRetryReasonAnnoKey = devops.GroupName + "/pipelinerun-retry-reason"
+	// PipelineScheduleLabelKey is label key of the name of the PipelineSchedule which created a PipelineRun.
+	PipelineScheduleLabelKey = devops.GroupName + "/pipelineschedule"
+	// PipelineRunScheduledTimeAnnoKey is annotation key of the time when a PipelineRun was scheduled, in RFC 3339.
+	PipelineRunScheduledTimeAnnoKey = devops.GroupName + "/pipelinerun-scheduled-time"
+	// PipelineDownstreamNamespacesAnnoKey is annotation key of a Pipeline, it's the namespaces separated by commas,
+	// the Pipelines in which are allowed to be triggered by the PipelineRuns of the Pipeline. "*" allows all namespaces.
+	PipelineDownstreamNamespacesAnnoKey = devops.GroupName + "/pipeline-downstream-namespaces"
+	// PipelineRunUpstreamAnnoKey is annotation key of the upstream PipelineRun which triggered a PipelineRun,
+	// in the format of namespace/name.
+	PipelineRunUpstreamAnnoKey = devops.GroupName + "/pipelinerun-upstream"
+	// PipelineRunDownstreamsAnnoKey is annotation key of the downstream PipelineRuns which were triggered by
+	// a PipelineRun, in the format of namespace/name and separated by commas.
+	PipelineRunDownstreamsAnnoKey = devops.GroupName + "/pipelinerun-downstreams"
+	// PipelineRunDownstreamsEvaluatedAnnoKey is annotation key which indicates the downstream Pipelines of a completed
+	// PipelineRun were evaluated already, so the PipelineRun is not evaluated again after the controller restarted.
+	PipelineRunDownstreamsEvaluatedAnnoKey = devops.GroupName + "/pipelinerun-downstreams-evaluated"
+	// PipelineUpstreamIndexerName is an indexer name of the upstream Pipelines of Pipeline, in the format of namespace/name.
+	PipelineUpstreamIndexerName = "pipeline.upstream"
 )
 
 var (
@@ -0,0 +1,162 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha3
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+const (
+	githubServer    = "https://github.com"
+	gitlabServer    = "https://gitlab.com"
+	bitbucketServer = "https://bitbucket.org"
+)
+
+// GitURLs are the addresses of a git repository which are derived from the source of a multi-branch Pipeline
+type GitURLs struct {
+	// Web is the address of the repository page, such as: https://github.com/owner/repo
+	Web string
+	// HTTPS is the address to clone the repository via HTTPS, such as: https://github.com/owner/repo.git
+	HTTPS string
+	// SSH is the address to clone the repository via SSH, such as: git@github.com:owner/repo.git
+	SSH string
+	// Server is the address of a self-hosted git provider, it's empty for the public ones
+	Server string
+}
+
+// All returns all the non-empty addresses of the repository
+func (u GitURLs) All() (urls []string) {
+	for _, address := range []string{u.Web, u.HTTPS, u.SSH} {
+		if address != "" {
+			urls = append(urls, address)
+		}
+	}
+	return
+}
+
+// ResolveGitURLs derives the addresses of the git repository from the source.
+// The API address and the server name are taken into account, so it works for the self-hosted git providers,
+// such as GitHub Enterprise, self-managed GitLab and Bitbucket Server.
+func (b *MultiBranchPipeline) ResolveGitURLs() (urls GitURLs) {
+	switch b.SourceType {
+	case SourceTypeGit:
+		if b.GitSource != nil {
+			urls.Web = b.GitSource.Url
+		}
+	case SourceTypeGithub:
+		if source := b.GitHubSource; source != nil {
+			server := trimAPIPath(source.ApiUri, "/api/v3")
+			if server == "" || server == githubServer || server == "https://api.github.com" {
+				urls = newGitURLs("", githubServer, source.Owner+"/"+source.Repo)
+			} else {
+				urls = newGitURLs(server, server, source.Owner+"/"+source.Repo)
+			}
+		}
+	case SourceTypeGitlab:
+		if source := b.GitlabSource; source != nil {
+			server := trimAPIPath(source.ApiUri, "/api/v4")
+			if server == "" && isURL(source.ServerName) {
+				// the server name is the name of a GitLab server in Jenkins normally, but it's an address sometimes
+				server = trimAPIPath(source.ServerName, "/api/v4")
+			}
+
+			// the repo of GitLab might be the full path, such as: owner/repo
+			path := source.Repo
+			if !strings.HasPrefix(path, source.Owner+"/") {
+				path = source.Owner + "/" + path
+			}
+			if server == "" || server == gitlabServer {
+				urls = newGitURLs("", gitlabServer, path)
+			} else {
+				urls = newGitURLs(server, server, path)
+			}
+		}
+	case SourceTypeBitbucket:
+		if source := b.BitbucketServerSource; source != nil {
+			server := trimAPIPath(source.ApiUri, "/rest/api/1.0")
+			if server == "" || server == bitbucketServer || server == "https://api.bitbucket.org" {
+				urls = newGitURLs("", bitbucketServer, source.Owner+"/"+source.Repo)
+			} else {
+				// the owner is the project key of Bitbucket Server
+				urls = GitURLs{
+					Web:    fmt.Sprintf("%s/projects/%s/repos/%s", server, source.Owner, source.Repo),
+					HTTPS:  fmt.Sprintf("%s/scm/%s/%s.git", server, strings.ToLower(source.Owner), source.Repo),
+					SSH:    getSSHURL(server, source.Owner+"/"+source.Repo),
+					Server: server,
+				}
+			}
+		}
+	case SourceTypeGitea:
+		if source := b.GiteaSource; source != nil && source.ServerUrl != "" {
+			server := strings.TrimSuffix(source.ServerUrl, "/")
+			urls = newGitURLs(server, server, source.Owner+"/"+source.Repo)
+		}
+	}
+	return
+}
+
+// GetGitURLs returns the addresses of the git repository which triggers the Pipeline via SCM webhooks.
+// They're derived from the source of a multi-branch Pipeline, or the annotation of a regular one.
+func (p *Pipeline) GetGitURLs() []string {
+	if p.IsMultiBranch() {
+		if p.Spec.MultiBranchPipeline == nil {
+			return nil
+		}
+		return p.Spec.MultiBranchPipeline.ResolveGitURLs().All()
+	}
+	if gitURL := p.GetAnnotations()[PipelineSCMURLAnnoKey]; gitURL != "" {
+		return []string{gitURL}
+	}
+	return nil
+}
+
+// newGitURLs creates the addresses of a git provider which has the same paths for browsing and cloning
+func newGitURLs(apiServer, server, path string) GitURLs {
+	return GitURLs{
+		Web:    fmt.Sprintf("%s/%s", server, path),
+		HTTPS:  fmt.Sprintf("%s/%s.git", server, path),
+		SSH:    getSSHURL(server, path),
+		Server: apiServer,
+	}
+}
+
+// getSSHURL returns a scp-like SSH address, such as: git@github.com:owner/repo.git
+func getSSHURL(server, path string) string {
+	serverURL, err := url.Parse(server)
+	if err != nil || serverURL.Hostname() == "" {
+		return ""
+	}
+	return fmt.Sprintf("git@%s:%s.git", serverURL.Hostname(), path)
+}
+
+// trimAPIPath trims the API path from an API address, then returns the address of the server
+func trimAPIPath(apiURI string, apiPaths ...string) string {
+	server := strings.TrimSuffix(apiURI, "/")
+	for _, apiPath := range apiPaths {
+		if strings.HasSuffix(server, apiPath) {
+			return strings.TrimSuffix(server, apiPath)
+		}
+	}
+	return server
+}
+
+// isURL returns true if the text is a HTTP or HTTPS address
+func isURL(text string) bool {
+	return strings.HasPrefix(text, "http://") || strings.HasPrefix(text, "https://")
+}
@@ -0,0 +1,185 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha3
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// upstreamVariablePattern matches the variables like $(upstream.parameters.version) in the parameters of an upstream trigger
+var upstreamVariablePattern = regexp.MustCompile(`\$\(upstream\.([^)]*)\)`)
+
+// upstreamVariables are the variables of an upstream PipelineRun which don't have a key
+var upstreamVariables = sets.NewString("namespace", "name", "pipeline", "branch", "phase", "id")
+
+// upstreamVariablePrefixes are the prefixes of the variables of an upstream PipelineRun which have a key,
+// such as $(upstream.parameters.version)
+var upstreamVariablePrefixes = []string{"parameters.", "annotations.", "artifacts."}
+
+// GetNamespace returns the namespace of the upstream Pipeline, it's the namespace of the downstream Pipeline by default
+func (t *UpstreamTrigger) GetNamespace(namespace string) string {
+	if t.Namespace == "" {
+		return namespace
+	}
+	return t.Namespace
+}
+
+// GetPhases returns the phases of the upstream PipelineRuns which trigger the downstream Pipeline, it's Succeeded
+// by default
+func (t *UpstreamTrigger) GetPhases() []RunPhase {
+	if len(t.Phases) == 0 {
+		return []RunPhase{Succeeded}
+	}
+	return t.Phases
+}
+
+// Matches indicates if a completed PipelineRun triggers the downstream Pipeline in the namespace
+func (t *UpstreamTrigger) Matches(namespace string, upstream *PipelineRun) bool {
+	if t.GetNamespace(namespace) != upstream.Namespace || t.Pipeline != upstream.GetPipelineName() {
+		return false
+	}
+
+	var phaseMatched bool
+	for _, phase := range t.GetPhases() {
+		if phase == upstream.Status.Phase {
+			phaseMatched = true
+			break
+		}
+	}
+	if !phaseMatched {
+		return false
+	}
+
+	if t.BranchFilter != "" {
+		matched, err := regexp.MatchString("^(?:"+t.BranchFilter+")$", upstream.GetRefName())
+		return err == nil && matched
+	}
+	return true
+}
+
+// RenderParameters returns the parameters of a downstream PipelineRun, the variables in the values are replaced
+// with the values of the upstream PipelineRun. A variable is empty if the upstream PipelineRun doesn't have it.
+func (t *UpstreamTrigger) RenderParameters(upstream *PipelineRun) (parameters []Parameter, err error) {
+	upstreamParameters := map[string]string{}
+	for _, parameter := range upstream.Spec.Parameters {
+		upstreamParameters[parameter.Name] = parameter.Value
+	}
+
+	for _, parameter := range t.Parameters {
+		value := upstreamVariablePattern.ReplaceAllStringFunc(parameter.Value, func(variable string) string {
+			name := upstreamVariablePattern.FindStringSubmatch(variable)[1]
+			if !IsValidUpstreamVariable(name) {
+				err = fmt.Errorf("unknown variable %s in the parameter %s", variable, parameter.Name)
+				return variable
+			}
+
+			switch {
+			case name == "namespace":
+				return upstream.Namespace
+			case name == "name":
+				return upstream.Name
+			case name == "pipeline":
+				return upstream.GetPipelineName()
+			case name == "branch":
+				return upstream.GetRefName()
+			case name == "phase":
+				return string(upstream.Status.Phase)
+			case name == "id":
+				id, _ := upstream.GetPipelineRunID()
+				return id
+			case strings.HasPrefix(name, "parameters."):
+				return upstreamParameters[strings.TrimPrefix(name, "parameters.")]
+			case strings.HasPrefix(name, "annotations."):
+				return upstream.Annotations[strings.TrimPrefix(name, "annotations.")]
+			default:
+				return GetArtifactDownloadPath(upstream, strings.TrimPrefix(name, "artifacts."))
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+		parameters = append(parameters, Parameter{Name: parameter.Name, Value: value})
+	}
+	return
+}
+
+// GetUpstreamVariables returns the names of the upstream variables in a value, such as parameters.version
+func GetUpstreamVariables(value string) (names []string) {
+	for _, match := range upstreamVariablePattern.FindAllStringSubmatch(value, -1) {
+		names = append(names, match[1])
+	}
+	return
+}
+
+// IsValidUpstreamVariable indicates if the name is a supported variable of an upstream PipelineRun
+func IsValidUpstreamVariable(name string) bool {
+	if upstreamVariables.Has(name) {
+		return true
+	}
+	for _, prefix := range upstreamVariablePrefixes {
+		if strings.HasPrefix(name, prefix) && len(name) > len(prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetArtifactDownloadPath returns the path of the API which downloads an artifact of the PipelineRun
+func GetArtifactDownloadPath(pr *PipelineRun, filename string) string {
+	return fmt.Sprintf("/kapis/devops.kubesphere.io/v1alpha3/namespaces/%s/pipelineruns/%s/artifacts/download?filename=%s",
+		pr.Namespace, pr.Name, url.QueryEscape(filename))
+}
+
+// AllowsDownstreamNamespace indicates if the Pipelines in the namespace are able to be triggered by the PipelineRuns
+// of this Pipeline. The Pipelines in the same namespace are always allowed.
+func (p *Pipeline) AllowsDownstreamNamespace(namespace string) bool {
+	if p.Namespace == namespace {
+		return true
+	}
+	for _, allowed := range strings.Split(p.Annotations[PipelineDownstreamNamespacesAnnoKey], ",") {
+		if allowed = strings.TrimSpace(allowed); allowed == "*" || allowed == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+// GetUpstreamPipelines returns the upstream Pipelines of the triggers in the format of namespace/name
+func (p *Pipeline) GetUpstreamPipelines() []string {
+	upstreams := sets.NewString()
+	for i := range p.Spec.UpstreamTriggers {
+		trigger := &p.Spec.UpstreamTriggers[i]
+		upstreams.Insert(trigger.GetNamespace(p.Namespace) + "/" + trigger.Pipeline)
+	}
+	return upstreams.List()
+}
+
+// GetDownstreams returns the downstream PipelineRuns which were triggered by the PipelineRun, in the format of
+// namespace/name
+func (pr *PipelineRun) GetDownstreams() (downstreams []string) {
+	for _, downstream := range strings.Split(pr.Annotations[PipelineRunDownstreamsAnnoKey], ",") {
+		if downstream = strings.TrimSpace(downstream); downstream != "" {
+			downstreams = append(downstreams, downstream)
+		}
+	}
+	return
+}
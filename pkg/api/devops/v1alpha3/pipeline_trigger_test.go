@@ -0,0 +1,174 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha3
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newUpstreamPipelineRun(phase RunPhase, branch string) *PipelineRun {
+	pr := &PipelineRun{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "ns",
+			Name:      "build-abcde",
+			Annotations: map[string]string{
+				JenkinsPipelineRunIDAnnoKey: "3",
+				"example.com/image":         "kubesphere/devops:v1",
+			},
+		},
+		Spec: PipelineRunSpec{
+			PipelineRef: &v1.ObjectReference{Name: "build"},
+			Parameters:  []Parameter{{Name: "version", Value: "v1.0.0"}},
+		},
+		Status: PipelineRunStatus{Phase: phase},
+	}
+	if branch != "" {
+		pr.Spec.PipelineSpec = &PipelineSpec{Type: MultiBranchPipelineType}
+		pr.Spec.SCM = &SCM{RefName: branch}
+	}
+	return pr
+}
+
+func TestUpstreamTrigger_Matches(t *testing.T) {
+	tests := []struct {
+		name      string
+		trigger   UpstreamTrigger
+		namespace string
+		upstream  *PipelineRun
+		want      bool
+	}{{
+		name:     "succeeded by default",
+		trigger:  UpstreamTrigger{Pipeline: "build"},
+		upstream: newUpstreamPipelineRun(Succeeded, ""),
+		want:     true,
+	}, {
+		name:     "not succeeded",
+		trigger:  UpstreamTrigger{Pipeline: "build"},
+		upstream: newUpstreamPipelineRun(Failed, ""),
+	}, {
+		name:     "matched phases",
+		trigger:  UpstreamTrigger{Pipeline: "build", Phases: []RunPhase{Failed, Cancelled}},
+		upstream: newUpstreamPipelineRun(Failed, ""),
+		want:     true,
+	}, {
+		name:     "another Pipeline",
+		trigger:  UpstreamTrigger{Pipeline: "test"},
+		upstream: newUpstreamPipelineRun(Succeeded, ""),
+	}, {
+		name:      "another namespace",
+		trigger:   UpstreamTrigger{Pipeline: "build"},
+		namespace: "deploy",
+		upstream:  newUpstreamPipelineRun(Succeeded, ""),
+	}, {
+		name:      "the upstream Pipeline in another namespace",
+		trigger:   UpstreamTrigger{Namespace: "ns", Pipeline: "build"},
+		namespace: "deploy",
+		upstream:  newUpstreamPipelineRun(Succeeded, ""),
+		want:      true,
+	}, {
+		name:     "matched branch",
+		trigger:  UpstreamTrigger{Pipeline: "build", BranchFilter: "main|release-.*"},
+		upstream: newUpstreamPipelineRun(Succeeded, "release-3.3"),
+		want:     true,
+	}, {
+		name:     "the whole branch must match",
+		trigger:  UpstreamTrigger{Pipeline: "build", BranchFilter: "main|release-.*"},
+		upstream: newUpstreamPipelineRun(Succeeded, "feature-main"),
+	}, {
+		name:     "invalid branch filter",
+		trigger:  UpstreamTrigger{Pipeline: "build", BranchFilter: "[a-z"},
+		upstream: newUpstreamPipelineRun(Succeeded, "main"),
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			namespace := tt.namespace
+			if namespace == "" {
+				namespace = "ns"
+			}
+			assert.Equal(t, tt.want, tt.trigger.Matches(namespace, tt.upstream))
+		})
+	}
+}
+
+func TestUpstreamTrigger_RenderParameters(t *testing.T) {
+	trigger := &UpstreamTrigger{Parameters: []Parameter{
+		{Name: "upstream", Value: "$(upstream.namespace)/$(upstream.name)"},
+		{Name: "info", Value: "$(upstream.pipeline)#$(upstream.id) $(upstream.branch) $(upstream.phase)"},
+		{Name: "version", Value: "$(upstream.parameters.version)"},
+		{Name: "image", Value: "$(upstream.annotations.example.com/image)"},
+		{Name: "artifact", Value: "$(upstream.artifacts.app v1.tar.gz)"},
+		{Name: "missing", Value: "$(upstream.parameters.fake)"},
+		{Name: "fixed", Value: "production"},
+	}}
+	parameters, err := trigger.RenderParameters(newUpstreamPipelineRun(Succeeded, "main"))
+	assert.Nil(t, err)
+	assert.Equal(t, []Parameter{
+		{Name: "upstream", Value: "ns/build-abcde"},
+		{Name: "info", Value: "build#3 main Succeeded"},
+		{Name: "version", Value: "v1.0.0"},
+		{Name: "image", Value: "kubesphere/devops:v1"},
+		{Name: "artifact", Value: "/kapis/devops.kubesphere.io/v1alpha3/namespaces/ns/pipelineruns/build-abcde/" +
+			"artifacts/download?filename=app+v1.tar.gz"},
+		{Name: "missing", Value: ""},
+		{Name: "fixed", Value: "production"},
+	}, parameters)
+
+	trigger = &UpstreamTrigger{Parameters: []Parameter{{Name: "version", Value: "$(upstream.outputs.version)"}}}
+	_, err = trigger.RenderParameters(newUpstreamPipelineRun(Succeeded, ""))
+	assert.NotNil(t, err)
+}
+
+func TestGetUpstreamVariables(t *testing.T) {
+	assert.Nil(t, GetUpstreamVariables("v1.0.0"))
+	assert.Equal(t, []string{"name", "parameters.version", ""},
+		GetUpstreamVariables("$(upstream.name)-$(upstream.parameters.version)$(upstream.)"))
+}
+
+func TestIsValidUpstreamVariable(t *testing.T) {
+	for _, name := range []string{"namespace", "name", "pipeline", "branch", "phase", "id",
+		"parameters.version", "annotations.example.com/image", "artifacts.app.tar.gz"} {
+		assert.True(t, IsValidUpstreamVariable(name), name)
+	}
+	for _, name := range []string{"", "fake", "parameters.", "outputs.version"} {
+		assert.False(t, IsValidUpstreamVariable(name), name)
+	}
+}
+
+func TestPipeline_AllowsDownstreamNamespace(t *testing.T) {
+	pipeline := &Pipeline{ObjectMeta: metav1.ObjectMeta{Namespace: "ns"}}
+	assert.True(t, pipeline.AllowsDownstreamNamespace("ns"))
+	assert.False(t, pipeline.AllowsDownstreamNamespace("deploy"))
+
+	pipeline.Annotations = map[string]string{PipelineDownstreamNamespacesAnnoKey: "test, deploy"}
+	assert.True(t, pipeline.AllowsDownstreamNamespace("deploy"))
+	assert.False(t, pipeline.AllowsDownstreamNamespace("other"))
+
+	pipeline.Annotations[PipelineDownstreamNamespacesAnnoKey] = "*"
+	assert.True(t, pipeline.AllowsDownstreamNamespace("other"))
+}
+
+func TestPipelineRun_GetDownstreams(t *testing.T) {
+	pr := &PipelineRun{}
+	assert.Nil(t, pr.GetDownstreams())
+
+	pr.Annotations = map[string]string{PipelineRunDownstreamsAnnoKey: "deploy/release-1a2b3c4d,ns/test-5e6f7a8b"}
+	assert.Equal(t, []string{"deploy/release-1a2b3c4d", "ns/test-5e6f7a8b"}, pr.GetDownstreams())
+}
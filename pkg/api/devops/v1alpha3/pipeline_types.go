@@ -17,7 +17,6 @@ limitations under the License.
 package v1alpha3
 
 import (
-	"fmt"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -47,6 +46,11 @@ const (
 	PipelineJenkinsfileEditModeAnnoKey = PipelinePrefix + "jenkinsfile.edit.mode"
 	// PipelineJenkinsfileValidateAnnoKey is the annotation key of the Jenkinsfile validate, success or failure
 	PipelineJenkinsfileValidateAnnoKey = PipelinePrefix + "jenkinsfile.validate"
+	// PipelineSCMURLAnnoKey is the annotation key of the git repository which triggers a regular Pipeline via SCM webhooks
+	PipelineSCMURLAnnoKey = "scm.devops.kubesphere.io"
+	// PipelineGitRepositoryLabelKey is the label key of the GitRepository which triggers the Pipeline via SCM webhooks.
+	// The GitRepository must be in the same namespace as the Pipeline.
+	PipelineGitRepositoryLabelKey = PipelinePrefix + "git-repository"
 
 	// PipelineJenkinsfileEditModeJSON indicates the Jenkinsfile editing mode is JSON
 	PipelineJenkinsfileEditModeJSON = "json"
@@ -64,6 +68,50 @@ type PipelineSpec struct {
 	Type                PipelineType         `json:"type" description:"type of devops pipeline, in scm or no scm"`
 	Pipeline            *NoScmPipeline       `json:"pipeline,omitempty" description:"no scm pipeline structs"`
 	MultiBranchPipeline *MultiBranchPipeline `json:"multi_branch_pipeline,omitempty" description:"in scm pipeline structs"`
+	Concurrency         *ConcurrencyPolicy   `json:"concurrency,omitempty" description:"how to handle the PipelineRuns which run at the same time"`
+	UpstreamTriggers    []UpstreamTrigger    `json:"upstream_triggers,omitempty" mapstructure:"upstream_triggers" description:"run the Pipeline once the PipelineRuns of the upstream Pipelines completed"`
+}
+
+// UpstreamTrigger runs a Pipeline once a PipelineRun of the upstream Pipeline completed.
+// The upstream Pipeline in another namespace must allow the namespace of the Pipeline via the annotation
+// PipelineDownstreamNamespacesAnnoKey.
+type UpstreamTrigger struct {
+	Namespace    string      `json:"namespace,omitempty" description:"the namespace of the upstream Pipeline, the namespace of the Pipeline by default"`
+	Pipeline     string      `json:"pipeline" description:"the name of the upstream Pipeline"`
+	Phases       []RunPhase  `json:"phases,omitempty" description:"the phases of the upstream PipelineRuns which trigger the Pipeline, Succeeded by default"`
+	BranchFilter string      `json:"branch_filter,omitempty" mapstructure:"branch_filter" description:"the regular expression which matches the whole branch of the upstream PipelineRuns, all branches by default"`
+	Branch       string      `json:"branch,omitempty" description:"the branch to run of a multi-branch Pipeline, the branch of the upstream PipelineRun by default"`
+	Parameters   []Parameter `json:"parameters,omitempty" description:"the parameters passed to the PipelineRuns, the values could refer to the upstream PipelineRun like $(upstream.parameters.version)"`
+}
+
+// ConcurrencyPolicy describes how to handle the PipelineRuns of a Pipeline which run at the same time.
+// The PipelineRuns of different branches of a multi-branch Pipeline don't affect each other.
+type ConcurrencyPolicy struct {
+	Policy      ConcurrencyPolicyType `json:"policy,omitempty" description:"one of Allow, Forbid, Replace and Queue, Allow by default"`
+	MaxParallel int                   `json:"max_parallel,omitempty" mapstructure:"max_parallel" description:"the max number of running PipelineRuns of the policies Forbid, Replace and Queue, 1 by default"`
+}
+
+// ConcurrencyPolicyType is the type of concurrency policies
+type ConcurrencyPolicyType string
+
+const (
+	// AllowConcurrent allows the PipelineRuns to run at the same time
+	AllowConcurrent ConcurrencyPolicyType = "Allow"
+	// ForbidConcurrent skips a new PipelineRun if there is still a running one
+	ForbidConcurrent ConcurrencyPolicyType = "Forbid"
+	// ReplaceConcurrent stops the older PipelineRuns, then runs the new one
+	ReplaceConcurrent ConcurrencyPolicyType = "Replace"
+	// QueueConcurrent holds the new PipelineRuns in the queue until the number of running ones is less than the max parallel
+	QueueConcurrent ConcurrencyPolicyType = "Queue"
+)
+
+// IsValid indicates if the concurrency policy is one of the supported policies
+func (policy ConcurrencyPolicyType) IsValid() bool {
+	switch policy {
+	case AllowConcurrent, ForbidConcurrent, ReplaceConcurrent, QueueConcurrent:
+		return true
+	}
+	return false
 }
 
 // PipelineStatus defines the observed state of Pipeline
@@ -105,6 +153,32 @@ func (p *Pipeline) IsMultiBranch() bool {
 	return p.Spec.Type == MultiBranchPipelineType
 }
 
+// AllowsUnsignedWebhook indicates if the SCM webhook deliveries without any signature are able to trigger the Pipeline.
+// It's only an explicit opt-out for the repositories which don't have any secret.
+func (p *Pipeline) AllowsUnsignedWebhook() bool {
+	return p != nil && p.Annotations[PipelineWebhookAllowUnsignedAnnoKey] == "true"
+}
+
+// GetConcurrencyPolicy returns the concurrency policy of the Pipeline, it's Allow by default
+func (p *Pipeline) GetConcurrencyPolicy() ConcurrencyPolicyType {
+	if p == nil || p.Spec.Concurrency == nil || p.Spec.Concurrency.Policy == "" {
+		return AllowConcurrent
+	}
+	return p.Spec.Concurrency.Policy
+}
+
+// GetMaxParallel returns the max number of running PipelineRuns of the Pipeline, zero means no limit.
+// It's 1 by default for all the policies except Allow.
+func (p *Pipeline) GetMaxParallel() int {
+	if p.GetConcurrencyPolicy() == AllowConcurrent {
+		return 0
+	}
+	if p.Spec.Concurrency.MaxParallel > 0 {
+		return p.Spec.Concurrency.MaxParallel
+	}
+	return 1
+}
+
 // PipelineType is an alias of string that represents the type of Pipelines
 type PipelineType string
 
@@ -120,6 +194,7 @@ const (
 	SourceTypeGitlab    = "gitlab"
 	SourceTypeGithub    = "github"
 	SourceTypeBitbucket = "bitbucket_server"
+	SourceTypeGitea     = "gitea"
 )
 
 type NoScmPipeline struct {
@@ -146,30 +221,14 @@ type MultiBranchPipeline struct {
 	SvnSource             *SvnSource             `json:"svn_source,omitempty" description:"multi branch svn scm define"`
 	SingleSvnSource       *SingleSvnSource       `json:"single_svn_source,omitempty" description:"single branch svn scm define"`
 	BitbucketServerSource *BitbucketServerSource `json:"bitbucket_server_source,omitempty" description:"bitbucket server scm defile"`
+	GiteaSource           *GiteaSource           `json:"gitea_source,omitempty" description:"gitea scm define"`
 	ScriptPath            string                 `json:"script_path" mapstructure:"script_path" description:"script path in scm"`
 	MultiBranchJobTrigger *MultiBranchJobTrigger `json:"multibranch_job_trigger,omitempty" mapstructure:"multibranch_job_trigger" description:"Pipeline tasks that need to be triggered when branch creation/deletion"`
 }
 
+// GetGitURL returns the web address of the git repository, it's empty if the source is not a git repository
 func (b *MultiBranchPipeline) GetGitURL() string {
-	switch b.SourceType {
-	case SourceTypeGit:
-		if b.GitSource != nil {
-			return b.GitSource.Url
-		}
-	case SourceTypeGithub:
-		if b.GitHubSource != nil {
-			return fmt.Sprintf("https://github.com/%s/%s", b.GitHubSource.Owner, b.GitHubSource.Repo)
-		}
-	case SourceTypeGitlab:
-		if b.GitlabSource != nil {
-			return fmt.Sprintf("https://gitlab.com/%s/%s", b.GitlabSource.Owner, b.GitlabSource.Repo)
-		}
-	case SourceTypeBitbucket:
-		if b.BitbucketServerSource != nil {
-			return fmt.Sprintf("https://bitbucket.org/%s/%s", b.BitbucketServerSource.Owner, b.BitbucketServerSource.Repo)
-		}
-	}
-	return ""
+	return b.ResolveGitURLs().Web
 }
 
 type GitSource struct {
@@ -229,6 +288,21 @@ type BitbucketServerSource struct {
 	AcceptJenkinsNotification bool                 `json:"accept_jenkins_notification,omitempty"  mapstructure:"accept_jenkins_notification" description:"Allow Jenkins send build status notification to Bitbucket"`
 }
 
+// GiteaSource is the source of a self-hosted Gitea
+type GiteaSource struct {
+	ScmId                string               `json:"scm_id,omitempty" description:"uid of scm"`
+	ServerUrl            string               `json:"server_url,omitempty" mapstructure:"server_url" description:"the address of gitea server which was configured in jenkins, such as: https://gitea.com"`
+	Owner                string               `json:"owner,omitempty" mapstructure:"owner" description:"owner of gitea repo"`
+	Repo                 string               `json:"repo,omitempty" mapstructure:"repo" description:"repo name of gitea repo"`
+	CredentialId         string               `json:"credential_id,omitempty" mapstructure:"credential_id" description:"credential id to access gitea source"`
+	DiscoverBranches     int                  `json:"discover_branches,omitempty" mapstructure:"discover_branches" description:"Discover branch configuration"`
+	DiscoverPRFromOrigin int                  `json:"discover_pr_from_origin,omitempty" mapstructure:"discover_pr_from_origin" description:"Discover origin PR configuration"`
+	DiscoverPRFromForks  *DiscoverPRFromForks `json:"discover_pr_from_forks,omitempty" mapstructure:"discover_pr_from_forks" description:"Discover fork PR configuration"`
+	DiscoverTags         bool                 `json:"discover_tags,omitempty" mapstructure:"discover_tags" description:"Discover tag configuration"`
+	CloneOption          *GitCloneOption      `json:"git_clone_option,omitempty" mapstructure:"git_clone_option" description:"advavced git clone options"`
+	RegexFilter          string               `json:"regex_filter,omitempty" mapstructure:"regex_filter" description:"Regex used to match the name of the branch that needs to be run"`
+}
+
 type MultiBranchJobTrigger struct {
 	CreateActionJobsToTrigger string `json:"create_action_job_to_trigger,omitempty" description:"pipeline name to trigger"`
 	DeleteActionJobsToTrigger string `json:"delete_action_job_to_trigger,omitempty" description:"pipeline name to trigger"`
@@ -63,6 +63,65 @@ func TestPipeline_IsMultiBranch(t *testing.T) {
 	}
 }
 
+func TestPipeline_GetMaxParallel(t *testing.T) {
+	newPipeline := func(policy ConcurrencyPolicyType, maxParallel int) *Pipeline {
+		return &Pipeline{Spec: PipelineSpec{Concurrency: &ConcurrencyPolicy{Policy: policy, MaxParallel: maxParallel}}}
+	}
+	tests := []struct {
+		name       string
+		pipeline   *Pipeline
+		wantPolicy ConcurrencyPolicyType
+		want       int
+	}{{
+		name:       "the Pipeline is nil",
+		wantPolicy: AllowConcurrent,
+	}, {
+		name:       "no concurrency policy",
+		pipeline:   &Pipeline{},
+		wantPolicy: AllowConcurrent,
+	}, {
+		name:       "allow without a limit",
+		pipeline:   newPipeline(AllowConcurrent, 3),
+		wantPolicy: AllowConcurrent,
+	}, {
+		name:       "forbid",
+		pipeline:   newPipeline(ForbidConcurrent, 0),
+		wantPolicy: ForbidConcurrent,
+		want:       1,
+	}, {
+		name:       "forbid with a max parallel",
+		pipeline:   newPipeline(ForbidConcurrent, 3),
+		wantPolicy: ForbidConcurrent,
+		want:       3,
+	}, {
+		name:       "replace with a max parallel",
+		pipeline:   newPipeline(ReplaceConcurrent, 2),
+		wantPolicy: ReplaceConcurrent,
+		want:       2,
+	}, {
+		name:       "replace",
+		pipeline:   newPipeline(ReplaceConcurrent, 0),
+		wantPolicy: ReplaceConcurrent,
+		want:       1,
+	}, {
+		name:       "queue with the default max parallel",
+		pipeline:   newPipeline(QueueConcurrent, 0),
+		wantPolicy: QueueConcurrent,
+		want:       1,
+	}, {
+		name:       "queue with a max parallel",
+		pipeline:   newPipeline(QueueConcurrent, 3),
+		wantPolicy: QueueConcurrent,
+		want:       3,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.wantPolicy, tt.pipeline.GetConcurrencyPolicy())
+			assert.Equal(t, tt.want, tt.pipeline.GetMaxParallel())
+		})
+	}
+}
+
 func TestMultiBranchPipeline_GetGitURL(t *testing.T) {
 	type fields struct {
 		SourceType            string
@@ -124,3 +183,129 @@ func TestMultiBranchPipeline_GetGitURL(t *testing.T) {
 		})
 	}
 }
+
+func TestMultiBranchPipeline_ResolveGitURLs(t *testing.T) {
+	tests := []struct {
+		name     string
+		pipeline *MultiBranchPipeline
+		want     GitURLs
+	}{{
+		name: "github",
+		pipeline: &MultiBranchPipeline{
+			SourceType:   SourceTypeGithub,
+			GitHubSource: &GithubSource{Owner: "linuxsuren", Repo: "tools", ApiUri: "https://api.github.com"},
+		},
+		want: GitURLs{
+			Web:   "https://github.com/linuxsuren/tools",
+			HTTPS: "https://github.com/linuxsuren/tools.git",
+			SSH:   "git@github.com:linuxsuren/tools.git",
+		},
+	}, {
+		name: "GitHub Enterprise",
+		pipeline: &MultiBranchPipeline{
+			SourceType:   SourceTypeGithub,
+			GitHubSource: &GithubSource{Owner: "linuxsuren", Repo: "tools", ApiUri: "https://github.example.com/api/v3/"},
+		},
+		want: GitURLs{
+			Web:    "https://github.example.com/linuxsuren/tools",
+			HTTPS:  "https://github.example.com/linuxsuren/tools.git",
+			SSH:    "git@github.example.com:linuxsuren/tools.git",
+			Server: "https://github.example.com",
+		},
+	}, {
+		name: "self-managed GitLab from the API address",
+		pipeline: &MultiBranchPipeline{
+			SourceType:   SourceTypeGitlab,
+			GitlabSource: &GitlabSource{Owner: "group", Repo: "group/tools", ApiUri: "http://gitlab.example.com:8080/api/v4"},
+		},
+		want: GitURLs{
+			Web:    "http://gitlab.example.com:8080/group/tools",
+			HTTPS:  "http://gitlab.example.com:8080/group/tools.git",
+			SSH:    "git@gitlab.example.com:group/tools.git",
+			Server: "http://gitlab.example.com:8080",
+		},
+	}, {
+		name: "self-managed GitLab from the server name",
+		pipeline: &MultiBranchPipeline{
+			SourceType:   SourceTypeGitlab,
+			GitlabSource: &GitlabSource{Owner: "group", Repo: "tools", ServerName: "https://gitlab.example.com"},
+		},
+		want: GitURLs{
+			Web:    "https://gitlab.example.com/group/tools",
+			HTTPS:  "https://gitlab.example.com/group/tools.git",
+			SSH:    "git@gitlab.example.com:group/tools.git",
+			Server: "https://gitlab.example.com",
+		},
+	}, {
+		name: "GitLab with a server name which is not an address",
+		pipeline: &MultiBranchPipeline{
+			SourceType:   SourceTypeGitlab,
+			GitlabSource: &GitlabSource{Owner: "group", Repo: "tools", ServerName: "default"},
+		},
+		want: GitURLs{
+			Web:   "https://gitlab.com/group/tools",
+			HTTPS: "https://gitlab.com/group/tools.git",
+			SSH:   "git@gitlab.com:group/tools.git",
+		},
+	}, {
+		name: "Bitbucket Server",
+		pipeline: &MultiBranchPipeline{
+			SourceType:            SourceTypeBitbucket,
+			BitbucketServerSource: &BitbucketServerSource{Owner: "PRJ", Repo: "tools", ApiUri: "https://bitbucket.example.com"},
+		},
+		want: GitURLs{
+			Web:    "https://bitbucket.example.com/projects/PRJ/repos/tools",
+			HTTPS:  "https://bitbucket.example.com/scm/prj/tools.git",
+			SSH:    "git@bitbucket.example.com:PRJ/tools.git",
+			Server: "https://bitbucket.example.com",
+		},
+	}, {
+		name: "Bitbucket Cloud",
+		pipeline: &MultiBranchPipeline{
+			SourceType:            SourceTypeBitbucket,
+			BitbucketServerSource: &BitbucketServerSource{Owner: "linuxsuren", Repo: "tools", ApiUri: "https://bitbucket.org"},
+		},
+		want: GitURLs{
+			Web:   "https://bitbucket.org/linuxsuren/tools",
+			HTTPS: "https://bitbucket.org/linuxsuren/tools.git",
+			SSH:   "git@bitbucket.org:linuxsuren/tools.git",
+		},
+	}, {
+		name: "gitea",
+		pipeline: &MultiBranchPipeline{
+			SourceType:  SourceTypeGitea,
+			GiteaSource: &GiteaSource{Owner: "linuxsuren", Repo: "tools", ServerUrl: "https://gitea.example.com/"},
+		},
+		want: GitURLs{
+			Web:    "https://gitea.example.com/linuxsuren/tools",
+			HTTPS:  "https://gitea.example.com/linuxsuren/tools.git",
+			SSH:    "git@gitea.example.com:linuxsuren/tools.git",
+			Server: "https://gitea.example.com",
+		},
+	}, {
+		name: "gitea without server",
+		pipeline: &MultiBranchPipeline{
+			SourceType:  SourceTypeGitea,
+			GiteaSource: &GiteaSource{Owner: "linuxsuren", Repo: "tools"},
+		},
+		want: GitURLs{},
+	}, {
+		name: "source is nil",
+		pipeline: &MultiBranchPipeline{
+			SourceType: SourceTypeGithub,
+		},
+		want: GitURLs{},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.pipeline.ResolveGitURLs())
+		})
+	}
+}
+
+func TestGitURLs_All(t *testing.T) {
+	assert.Nil(t, GitURLs{}.All())
+	assert.Equal(t, []string{"https://fake.com"}, GitURLs{Web: "https://fake.com"}.All())
+	assert.Equal(t, []string{"https://fake.com/a/b", "https://fake.com/a/b.git", "git@fake.com:a/b.git"},
+		GitURLs{Web: "https://fake.com/a/b", HTTPS: "https://fake.com/a/b.git", SSH: "git@fake.com:a/b.git", Server: "https://fake.com"}.All())
+}
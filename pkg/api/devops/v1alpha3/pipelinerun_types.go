@@ -18,7 +18,9 @@ package v1alpha3
 
 import (
 	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -47,6 +49,79 @@ type PipelineRunSpec struct {
 	// Action indicates what we need to do with current PipelineRun.
 	// +optional
 	Action *Action `json:"action,omitempty"`
+
+	// RetryPolicy defines how to retry the PipelineRun if it failed.
+	// +optional
+	RetryPolicy *RetryPolicy `json:"retryPolicy,omitempty"`
+}
+
+// RetryPolicy defines how to retry a failed PipelineRun. Every retry is a new PipelineRun which is linked to
+// the failed one by the lineage labels.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts, including the first one.
+	MaxAttempts int `json:"maxAttempts"`
+
+	// Backoff is the duration to wait before the first retry, it is doubled for every next retry.
+	// +optional
+	Backoff *metav1.Duration `json:"backoff,omitempty"`
+
+	// RetryOn are the failure reasons to retry, any failure is retried if it is empty.
+	// +optional
+	RetryOn []FailureReason `json:"retryOn,omitempty"`
+}
+
+// FailureReason is the reason why a PipelineRun failed.
+type FailureReason string
+
+const (
+	// FailureReasonFailure indicates that the PipelineRun failed, it is the reason of any other failures.
+	FailureReasonFailure FailureReason = "Failure"
+	// FailureReasonUnstable indicates that the PipelineRun completed with an unstable result, such as failed tests.
+	FailureReasonUnstable FailureReason = "Unstable"
+	// FailureReasonAborted indicates that the PipelineRun was aborted by the engine, such as a timeout.
+	FailureReasonAborted FailureReason = "Aborted"
+	// FailureReasonAgentLost indicates that the agent which ran the PipelineRun was lost, such as the Pod was deleted.
+	FailureReasonAgentLost FailureReason = "AgentLost"
+)
+
+// maxRetryBackoff is the upper limit of the backoff between two attempts
+const maxRetryBackoff = time.Hour
+
+// IsValid indicates if the failure reason is one of the supported reasons.
+func (reason FailureReason) IsValid() bool {
+	switch reason {
+	case FailureReasonFailure, FailureReasonUnstable, FailureReasonAborted, FailureReasonAgentLost:
+		return true
+	}
+	return false
+}
+
+// ShouldRetry indicates if a failure with the given reason should be retried.
+func (policy *RetryPolicy) ShouldRetry(reason FailureReason) bool {
+	if len(policy.RetryOn) == 0 {
+		return true
+	}
+	for _, retryOn := range policy.RetryOn {
+		if retryOn == reason {
+			return true
+		}
+	}
+	return false
+}
+
+// GetBackoff returns the duration to wait after the given attempt failed.
+func (policy *RetryPolicy) GetBackoff(attempt int) time.Duration {
+	if policy.Backoff == nil || policy.Backoff.Duration <= 0 {
+		return 0
+	}
+	backoff := policy.Backoff.Duration
+	for i := 1; i < attempt && backoff < maxRetryBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxRetryBackoff {
+		backoff = maxRetryBackoff
+	}
+	return backoff
 }
 
 // PipelineRunStatus defines the observed state of PipelineRun
@@ -72,6 +147,41 @@ type PipelineRunStatus struct {
 	// Current phase of PipelineRun.
 	// +optional
 	Phase RunPhase `json:"phase,omitempty"`
+
+	// QueuePosition is the position of a Queued PipelineRun in its queue, the first one is 1.
+	// +optional
+	QueuePosition int `json:"queuePosition,omitempty"`
+
+	// Approvals are the submitted input steps of PipelineRun, they are recorded by the apiserver.
+	// +optional
+	Approvals []Approval `json:"approvals,omitempty"`
+}
+
+// Approval is a record of an input step which was submitted by a user.
+type Approval struct {
+	// NodeID is the ID of the node which the step belongs to.
+	NodeID string `json:"nodeId"`
+
+	// StepID is the ID of the input step.
+	StepID string `json:"stepId"`
+
+	// InputID is the ID of the input in the Jenkinsfile.
+	// +optional
+	InputID string `json:"inputId,omitempty"`
+
+	// Approver is the name of the user who submitted the input.
+	Approver string `json:"approver"`
+
+	// Aborted indicates that the approver aborted the PipelineRun instead of proceeding.
+	// +optional
+	Aborted bool `json:"aborted,omitempty"`
+
+	// Parameters are the values of the input parameters.
+	// +optional
+	Parameters []Parameter `json:"parameters,omitempty"`
+
+	// Time is the timestamp of the approval.
+	Time metav1.Time `json:"time"`
 }
 
 // +kubebuilder:object:root=true
@@ -107,6 +217,16 @@ func (status *PipelineRunStatus) GetLatestCondition() *Condition {
 	return &status.Conditions[0]
 }
 
+// GetCondition returns the condition with the given type, it is nil if not found.
+func (status *PipelineRunStatus) GetCondition(conditionType ConditionType) *Condition {
+	for i := range status.Conditions {
+		if status.Conditions[i].Type == conditionType {
+			return &status.Conditions[i]
+		}
+	}
+	return nil
+}
+
 // AddCondition adds a new condition into history of conditions.
 func (status *PipelineRunStatus) AddCondition(newCondition *Condition) {
 	// compare newCondition
@@ -142,6 +262,11 @@ func (pr *PipelineRun) HasCompleted() bool {
 	return !pr.Status.CompletionTime.IsZero()
 }
 
+// IsPinned indicates if the PipelineRun is pinned, the garbage collector never deletes a pinned PipelineRun.
+func (pr *PipelineRun) IsPinned() bool {
+	return pr.Annotations[PipelineRunPinnedAnnoKey] == "true"
+}
+
 // LabelAsAnOrphan labels PipelineRun as an orphan.
 func (pr *PipelineRun) LabelAsAnOrphan() {
 	if pr == nil {
@@ -153,6 +278,67 @@ func (pr *PipelineRun) LabelAsAnOrphan() {
 	pr.Labels[PipelineRunOrphanLabelKey] = "true"
 }
 
+// LabelAsRoot labels the PipelineRun as the first attempt if it doesn't have the lineage labels.
+// It returns true if the labels were changed.
+func (pr *PipelineRun) LabelAsRoot() bool {
+	if pr.Labels[PipelineRunRootLabelKey] != "" {
+		return false
+	}
+	if pr.Labels == nil {
+		pr.Labels = make(map[string]string)
+	}
+	pr.Labels[PipelineRunRootLabelKey] = pr.Name
+	pr.Labels[PipelineRunAttemptLabelKey] = "1"
+	return true
+}
+
+// GetAttempt returns the attempt number of the PipelineRun, the first attempt is 1.
+func (pr *PipelineRun) GetAttempt() int {
+	if attempt, err := strconv.Atoi(pr.Labels[PipelineRunAttemptLabelKey]); err == nil && attempt > 0 {
+		return attempt
+	}
+	return 1
+}
+
+// GetRoot returns the name of the first PipelineRun of the attempts, it is the current one if it is the first attempt.
+func (pr *PipelineRun) GetRoot() string {
+	if root := pr.Labels[PipelineRunRootLabelKey]; root != "" {
+		return root
+	}
+	return pr.Name
+}
+
+// NewAttempt returns a new PipelineRun which runs the same Pipeline with the same parameters again.
+// It is linked to the current one by the lineage labels, and its attempt number is increased.
+func (pr *PipelineRun) NewAttempt() *PipelineRun {
+	spec := pr.Spec.DeepCopy()
+	spec.Action = nil
+	attempt := &PipelineRun{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName:    pr.GetRoot() + "-",
+			Namespace:       pr.Namespace,
+			OwnerReferences: pr.DeepCopy().OwnerReferences,
+			Labels: map[string]string{
+				PipelineRunRootLabelKey:    pr.GetRoot(),
+				PipelineRunParentLabelKey:  pr.Name,
+				PipelineRunAttemptLabelKey: strconv.Itoa(pr.GetAttempt() + 1),
+			},
+			Annotations: map[string]string{},
+		},
+		Spec: *spec,
+	}
+	if pipelineName := pr.Labels[PipelineNameLabelKey]; pipelineName != "" {
+		attempt.Labels[PipelineNameLabelKey] = pipelineName
+	}
+	// keep the same creator and engine
+	for _, key := range []string{PipelineRunCreatorAnnoKey, PipelineRunEngineAnnoKey} {
+		if value := pr.Annotations[key]; value != "" {
+			attempt.Annotations[key] = value
+		}
+	}
+	return attempt
+}
+
 // Buildable returns true if the PipelineRun is buildable, false otherwise.
 func (pr *PipelineRun) Buildable() bool {
 	return !pr.HasCompleted() && pr.Labels[PipelineRunOrphanLabelKey] != "true"
@@ -172,6 +358,14 @@ func (pr *PipelineRun) GetRefName() string {
 	return refName
 }
 
+// GetPipelineName returns the name of the Pipeline which the PipelineRun belongs to.
+func (pr *PipelineRun) GetPipelineName() string {
+	if pr.Spec.PipelineRef != nil && pr.Spec.PipelineRef.Name != "" {
+		return pr.Spec.PipelineRef.Name
+	}
+	return pr.Labels[PipelineNameLabelKey]
+}
+
 // GetPipelineRunID gets ID of PipelineRun.
 func (pr *PipelineRun) GetPipelineRunID() (pipelineRunID string, exist bool) {
 	pipelineRunID, exist = pr.Annotations[JenkinsPipelineRunIDAnnoKey]
@@ -248,6 +442,8 @@ type RunPhase string
 const (
 	// Pending indicates that the PipelineRun is pending.
 	Pending RunPhase = "Pending"
+	// Queued indicates that the PipelineRun is waiting in the queue due to the concurrency limits.
+	Queued RunPhase = "Queued"
 	// Running indicates that the PipelineRun is running.
 	Running RunPhase = "Running"
 	// Succeeded indicates that the PipelineRun has succeeded.
@@ -271,6 +467,9 @@ const (
 	// ConditionSucceeded indicates that the pipeline has finished.
 	// For pipeline which runs to completion
 	ConditionSucceeded ConditionType = "Succeeded"
+
+	// ConditionPaused indicates that the pipeline has been paused by the Pause action.
+	ConditionPaused ConditionType = "Paused"
 )
 
 // ConditionStatus is the status of the current condition.
@@ -326,6 +525,44 @@ const (
 	Resume Action = "Resume"
 )
 
+// IsValid indicates if the action is one of the supported actions.
+func (action Action) IsValid() bool {
+	switch action {
+	case Stop, Pause, Resume:
+		return true
+	}
+	return false
+}
+
+// GetAction returns the action of the PipelineRun, it is empty if no action was set.
+func (pr *PipelineRun) GetAction() Action {
+	if pr.Spec.Action == nil {
+		return ""
+	}
+	return *pr.Spec.Action
+}
+
+// IsPaused indicates if the PipelineRun has been paused by the Pause action.
+func (pr *PipelineRun) IsPaused() bool {
+	condition := pr.Status.GetCondition(ConditionPaused)
+	return condition != nil && condition.Status == ConditionTrue
+}
+
+// EngineType indicates which backend executes a PipelineRun.
+type EngineType string
+
+const (
+	// JenkinsEngine runs PipelineRuns on Jenkins, this is the default engine.
+	JenkinsEngine EngineType = "jenkins"
+	// KubernetesEngine runs the stages of a PipelineRun as a Kubernetes Job, no Jenkins is required.
+	KubernetesEngine EngineType = "kubernetes"
+)
+
+// GetEngineType returns the execution engine of the PipelineRun, it is empty if the engine has not been determined.
+func (pr *PipelineRun) GetEngineType() EngineType {
+	return EngineType(pr.Annotations[PipelineRunEngineAnnoKey])
+}
+
 // Valid values for event reasons (new reasons could be added in future)
 const (
 	// Started indicates PipelineRun has been triggered
@@ -336,6 +573,32 @@ const (
 	TriggerFailed string = "TriggerFailed"
 	// RetrieveFailed indicates that it failed to retrieve the latest running data
 	RetrieveFailed string = "RetrieveFailed"
+	// Stopped indicates PipelineRun has been stopped by the Stop action
+	Stopped string = "Stopped"
+	// Suspended indicates PipelineRun has been paused by the Pause action
+	Suspended string = "Suspended"
+	// Resumed indicates PipelineRun has been resumed by the Resume action
+	Resumed string = "Resumed"
+	// ActionFailed indicates that it failed to handle the action of PipelineRun
+	ActionFailed string = "ActionFailed"
+	// GarbageCollected indicates that a PipelineRun was deleted by the garbage collector
+	GarbageCollected string = "GarbageCollected"
+	// GarbageCollectFailed indicates that it failed to delete a PipelineRun by the garbage collector
+	GarbageCollectFailed string = "GarbageCollectFailed"
+	// Retried indicates that a failed PipelineRun has been retried by its retry policy
+	Retried string = "Retried"
+	// RetryFailed indicates that it failed to retry a failed PipelineRun
+	RetryFailed string = "RetryFailed"
+	// Enqueued indicates that a PipelineRun has been held in the queue due to the concurrency limits
+	Enqueued string = "Enqueued"
+	// Superseded indicates that a PipelineRun has been stopped by a newer one due to the concurrency policy Replace
+	Superseded string = "Superseded"
+	// ConcurrencyForbidden indicates that a PipelineRun has been skipped due to the concurrency policy Forbid
+	ConcurrencyForbidden string = "ConcurrencyForbidden"
+	// DownstreamTriggered indicates that a PipelineRun has triggered a downstream PipelineRun
+	DownstreamTriggered string = "DownstreamTriggered"
+	// UpstreamTriggerFailed indicates that a Pipeline failed to be triggered by an upstream PipelineRun
+	UpstreamTriggerFailed string = "UpstreamTriggerFailed"
 )
 
 func init() {
@@ -18,6 +18,7 @@ package v1alpha3
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	corev1 "k8s.io/api/core/v1"
@@ -315,3 +316,215 @@ func TestPipelineRun_GetRefName(t *testing.T) {
 		})
 	}
 }
+
+func TestPipelineRun_GetEngineType(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		want        EngineType
+	}{{
+		name: "no annotations",
+		want: "",
+	}, {
+		name: "jenkins engine",
+		annotations: map[string]string{
+			PipelineRunEngineAnnoKey: "jenkins",
+		},
+		want: JenkinsEngine,
+	}, {
+		name: "kubernetes engine",
+		annotations: map[string]string{
+			PipelineRunEngineAnnoKey: "kubernetes",
+		},
+		want: KubernetesEngine,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pr := &PipelineRun{}
+			pr.Annotations = tt.annotations
+			assert.Equal(t, tt.want, pr.GetEngineType())
+		})
+	}
+}
+
+func TestAction_IsValid(t *testing.T) {
+	assert.True(t, Stop.IsValid())
+	assert.True(t, Pause.IsValid())
+	assert.True(t, Resume.IsValid())
+	assert.False(t, Action("").IsValid())
+	assert.False(t, Action("Restart").IsValid())
+}
+
+func TestPipelineRun_GetAction(t *testing.T) {
+	pr := &PipelineRun{}
+	assert.Equal(t, Action(""), pr.GetAction())
+
+	action := Stop
+	pr.Spec.Action = &action
+	assert.Equal(t, Stop, pr.GetAction())
+}
+
+func TestPipelineRun_IsPaused(t *testing.T) {
+	tests := []struct {
+		name       string
+		conditions []Condition
+		want       bool
+	}{{
+		name: "no conditions",
+		want: false,
+	}, {
+		name: "no paused condition",
+		conditions: []Condition{{
+			Type:   ConditionReady,
+			Status: ConditionTrue,
+		}},
+		want: false,
+	}, {
+		name: "paused",
+		conditions: []Condition{{
+			Type:   ConditionReady,
+			Status: ConditionUnknown,
+		}, {
+			Type:   ConditionPaused,
+			Status: ConditionTrue,
+		}},
+		want: true,
+	}, {
+		name: "resumed",
+		conditions: []Condition{{
+			Type:   ConditionPaused,
+			Status: ConditionFalse,
+		}},
+		want: false,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pr := &PipelineRun{}
+			pr.Status.Conditions = tt.conditions
+			assert.Equal(t, tt.want, pr.IsPaused())
+		})
+	}
+}
+
+func TestPipelineRun_IsPinned(t *testing.T) {
+	pr := &PipelineRun{}
+	assert.False(t, pr.IsPinned())
+
+	pr.Annotations = map[string]string{PipelineRunPinnedAnnoKey: "false"}
+	assert.False(t, pr.IsPinned())
+
+	pr.Annotations[PipelineRunPinnedAnnoKey] = "true"
+	assert.True(t, pr.IsPinned())
+}
+
+func TestRetryPolicy_ShouldRetry(t *testing.T) {
+	policy := &RetryPolicy{MaxAttempts: 3}
+	assert.True(t, policy.ShouldRetry(FailureReasonFailure))
+	assert.True(t, policy.ShouldRetry(FailureReasonAgentLost))
+
+	policy.RetryOn = []FailureReason{FailureReasonAgentLost}
+	assert.True(t, policy.ShouldRetry(FailureReasonAgentLost))
+	assert.False(t, policy.ShouldRetry(FailureReasonFailure))
+	assert.False(t, policy.ShouldRetry(FailureReasonAborted))
+}
+
+func TestRetryPolicy_GetBackoff(t *testing.T) {
+	tests := []struct {
+		name    string
+		backoff *v1.Duration
+		attempt int
+		want    time.Duration
+	}{{
+		name:    "no backoff",
+		attempt: 1,
+		want:    0,
+	}, {
+		name:    "first attempt",
+		backoff: &v1.Duration{Duration: 10 * time.Second},
+		attempt: 1,
+		want:    10 * time.Second,
+	}, {
+		name:    "third attempt",
+		backoff: &v1.Duration{Duration: 10 * time.Second},
+		attempt: 3,
+		want:    40 * time.Second,
+	}, {
+		name:    "reach the limit",
+		backoff: &v1.Duration{Duration: 10 * time.Minute},
+		attempt: 100,
+		want:    time.Hour,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := &RetryPolicy{MaxAttempts: 3, Backoff: tt.backoff}
+			assert.Equal(t, tt.want, policy.GetBackoff(tt.attempt))
+		})
+	}
+}
+
+func TestFailureReason_IsValid(t *testing.T) {
+	assert.True(t, FailureReasonFailure.IsValid())
+	assert.True(t, FailureReasonUnstable.IsValid())
+	assert.True(t, FailureReasonAborted.IsValid())
+	assert.True(t, FailureReasonAgentLost.IsValid())
+	assert.False(t, FailureReason("").IsValid())
+	assert.False(t, FailureReason("Timeout").IsValid())
+}
+
+func TestPipelineRun_NewAttempt(t *testing.T) {
+	action := Stop
+	root := &PipelineRun{
+		ObjectMeta: v1.ObjectMeta{
+			Name:      "build-abcde",
+			Namespace: "ns",
+			Labels: map[string]string{
+				PipelineNameLabelKey: "build",
+			},
+			Annotations: map[string]string{
+				PipelineRunCreatorAnnoKey:       "admin",
+				PipelineRunEngineAnnoKey:        "jenkins",
+				JenkinsPipelineRunIDAnnoKey:     "1",
+				JenkinsPipelineRunStatusAnnoKey: "{}",
+			},
+			OwnerReferences: []v1.OwnerReference{{Kind: "Pipeline", Name: "build"}},
+		},
+		Spec: PipelineRunSpec{
+			PipelineRef: &corev1.ObjectReference{Name: "build"},
+			Parameters:  []Parameter{{Name: "version", Value: "v1"}},
+			Action:      &action,
+			RetryPolicy: &RetryPolicy{MaxAttempts: 3},
+		},
+	}
+	assert.Equal(t, 1, root.GetAttempt())
+	assert.Equal(t, "build-abcde", root.GetRoot())
+	assert.True(t, root.LabelAsRoot())
+	assert.False(t, root.LabelAsRoot())
+	assert.Equal(t, "build-abcde", root.Labels[PipelineRunRootLabelKey])
+
+	second := root.NewAttempt()
+	assert.Equal(t, "build-abcde-", second.GenerateName)
+	assert.Equal(t, "ns", second.Namespace)
+	assert.Equal(t, root.OwnerReferences, second.OwnerReferences)
+	assert.Equal(t, map[string]string{
+		PipelineNameLabelKey:       "build",
+		PipelineRunRootLabelKey:    "build-abcde",
+		PipelineRunParentLabelKey:  "build-abcde",
+		PipelineRunAttemptLabelKey: "2",
+	}, second.Labels)
+	assert.Equal(t, map[string]string{
+		PipelineRunCreatorAnnoKey: "admin",
+		PipelineRunEngineAnnoKey:  "jenkins",
+	}, second.Annotations)
+	assert.Nil(t, second.Spec.Action)
+	assert.Equal(t, root.Spec.Parameters, second.Spec.Parameters)
+	assert.Equal(t, root.Spec.RetryPolicy, second.Spec.RetryPolicy)
+	assert.Equal(t, 2, second.GetAttempt())
+	assert.False(t, second.LabelAsRoot())
+
+	second.Name = "build-abcde-fghij"
+	third := second.NewAttempt()
+	assert.Equal(t, "build-abcde-", third.GenerateName)
+	assert.Equal(t, "build-abcde", third.Labels[PipelineRunRootLabelKey])
+	assert.Equal(t, "build-abcde-fghij", third.Labels[PipelineRunParentLabelKey])
+	assert.Equal(t, 3, third.GetAttempt())
+}
@@ -0,0 +1,161 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha3
+
+import (
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// ResourceKindPipelineSchedule is the kind of PipelineSchedule
+	ResourceKindPipelineSchedule = "PipelineSchedule"
+
+	// defaultSuccessfulRunsHistoryLimit is the number of the succeeded PipelineRuns to keep by default
+	defaultSuccessfulRunsHistoryLimit = 3
+	// defaultFailedRunsHistoryLimit is the number of the failed PipelineRuns to keep by default
+	defaultFailedRunsHistoryLimit = 1
+)
+
+// Event reasons of PipelineSchedule
+const (
+	// Scheduled is the reason of the event when a PipelineRun was created by the schedule
+	Scheduled = "Scheduled"
+	// ScheduleFailed is the reason of the event when a PipelineRun was unable to be created by the schedule
+	ScheduleFailed = "ScheduleFailed"
+	// ScheduleSkipped is the reason of the event when a scheduled time was skipped due to the concurrency policy
+	ScheduleSkipped = "ScheduleSkipped"
+	// MissedSchedule is the reason of the event when some scheduled times were missed
+	MissedSchedule = "MissedSchedule"
+	// InvalidSchedule is the reason of the event when the cron or the time zone is invalid
+	InvalidSchedule = "InvalidSchedule"
+)
+
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// PipelineSchedule creates the PipelineRuns of a Pipeline at the scheduled times. Unlike the timer trigger of
+// a Pipeline, it doesn't rely on Jenkins, and works with any engine.
+// +k8s:openapi-gen=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Pipeline",type="string",JSONPath=".spec.pipelineRef.name"
+// +kubebuilder:printcolumn:name="Cron",type="string",JSONPath=".spec.cron"
+// +kubebuilder:printcolumn:name="Suspend",type="boolean",JSONPath=".spec.suspend"
+// +kubebuilder:printcolumn:name="Last Schedule",type="date",JSONPath=".status.lastScheduleTime"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+type PipelineSchedule struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   PipelineScheduleSpec   `json:"spec,omitempty"`
+	Status PipelineScheduleStatus `json:"status,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// PipelineScheduleList contains a list of PipelineSchedule
+type PipelineScheduleList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []PipelineSchedule `json:"items"`
+}
+
+// PipelineScheduleSpec defines when and how to run a Pipeline
+type PipelineScheduleSpec struct {
+	// PipelineRef is the Pipeline to run, it must be in the same namespace
+	PipelineRef *v1.ObjectReference `json:"pipelineRef"`
+
+	// Cron is the schedule in the cron format of Jenkins, such as: H 2 * * 1-5
+	Cron string `json:"cron"`
+
+	// TimeZone is the name of the time zone of the cron, such as: Asia/Shanghai. It's UTC by default.
+	// +optional
+	TimeZone string `json:"timeZone,omitempty"`
+
+	// Parameters are passed to the PipelineRuns
+	// +optional
+	Parameters []Parameter `json:"parameters,omitempty"`
+
+	// Branch is the branch to run, it's required by a multi-branch Pipeline
+	// +optional
+	Branch string `json:"branch,omitempty"`
+
+	// Suspend stops creating PipelineRuns, the existing ones are not affected
+	// +optional
+	Suspend bool `json:"suspend,omitempty"`
+
+	// ConcurrencyPolicy is how to handle a scheduled time when the previous PipelineRun is still running.
+	// It's one of Allow, Forbid and Replace, and it's Allow by default.
+	// +optional
+	ConcurrencyPolicy ConcurrencyPolicyType `json:"concurrencyPolicy,omitempty"`
+
+	// StartingDeadlineSeconds is the deadline in seconds to catch up on a missed scheduled time, for instance,
+	// the controller was down at that time. There is no deadline if it's nil.
+	// +optional
+	StartingDeadlineSeconds *int64 `json:"startingDeadlineSeconds,omitempty"`
+
+	// SuccessfulRunsHistoryLimit is the number of the succeeded PipelineRuns to keep, it's 3 by default
+	// +optional
+	SuccessfulRunsHistoryLimit *int32 `json:"successfulRunsHistoryLimit,omitempty"`
+
+	// FailedRunsHistoryLimit is the number of the failed PipelineRuns to keep, it's 1 by default
+	// +optional
+	FailedRunsHistoryLimit *int32 `json:"failedRunsHistoryLimit,omitempty"`
+}
+
+// PipelineScheduleStatus represents the current state of a PipelineSchedule
+type PipelineScheduleStatus struct {
+	// Active are the PipelineRuns created by the schedule which have not completed
+	// +optional
+	Active []v1.ObjectReference `json:"active,omitempty"`
+
+	// LastScheduleTime is the last time when a PipelineRun was scheduled
+	// +optional
+	LastScheduleTime *metav1.Time `json:"lastScheduleTime,omitempty"`
+
+	// NextScheduleTime is the next time when a PipelineRun will be scheduled, it's empty if the schedule was suspended
+	// +optional
+	NextScheduleTime *metav1.Time `json:"nextScheduleTime,omitempty"`
+}
+
+// GetConcurrencyPolicy returns the concurrency policy of the schedule, it's Allow by default
+func (s *PipelineSchedule) GetConcurrencyPolicy() ConcurrencyPolicyType {
+	if s.Spec.ConcurrencyPolicy == "" {
+		return AllowConcurrent
+	}
+	return s.Spec.ConcurrencyPolicy
+}
+
+// GetSuccessfulRunsHistoryLimit returns the number of the succeeded PipelineRuns to keep
+func (s *PipelineSchedule) GetSuccessfulRunsHistoryLimit() int {
+	if s.Spec.SuccessfulRunsHistoryLimit == nil {
+		return defaultSuccessfulRunsHistoryLimit
+	}
+	return int(*s.Spec.SuccessfulRunsHistoryLimit)
+}
+
+// GetFailedRunsHistoryLimit returns the number of the failed PipelineRuns to keep
+func (s *PipelineSchedule) GetFailedRunsHistoryLimit() int {
+	if s.Spec.FailedRunsHistoryLimit == nil {
+		return defaultFailedRunsHistoryLimit
+	}
+	return int(*s.Spec.FailedRunsHistoryLimit)
+}
+
+func init() {
+	SchemeBuilder.Register(&PipelineSchedule{}, &PipelineScheduleList{})
+}
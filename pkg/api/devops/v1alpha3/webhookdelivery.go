@@ -0,0 +1,119 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha3
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// WebhookDeliverySourceSCM indicates that the delivery comes from a git provider
+	WebhookDeliverySourceSCM = "scm"
+	// WebhookDeliverySourceJenkins indicates that the delivery comes from Jenkins
+	WebhookDeliverySourceJenkins = "jenkins"
+
+	// WebhookDeliverySourceLabelKey is the label key of the delivery source, it's one of scm and jenkins
+	WebhookDeliverySourceLabelKey = "webhookdelivery.devops.kubesphere.io/source"
+)
+
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// WebhookDelivery is a record of a webhook delivery, it tells which Pipelines were triggered by the delivery.
+// The status is not a subresource, because a delivery is recorded only once.
+// +k8s:openapi-gen=true
+// +kubebuilder:printcolumn:name="Source",type="string",JSONPath=".spec.source"
+// +kubebuilder:printcolumn:name="Event",type="string",JSONPath=".spec.event"
+// +kubebuilder:printcolumn:name="Repository",type="string",JSONPath=".spec.repository"
+// +kubebuilder:printcolumn:name="Code",type="integer",JSONPath=".status.code"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+type WebhookDelivery struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   WebhookDeliverySpec   `json:"spec,omitempty"`
+	Status WebhookDeliveryStatus `json:"status,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// WebhookDeliveryList contains a list of WebhookDelivery
+type WebhookDeliveryList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []WebhookDelivery `json:"items"`
+}
+
+// WebhookDeliverySpec represents the request of a webhook delivery
+type WebhookDeliverySpec struct {
+	// Source is where the delivery comes from, it's one of scm and jenkins
+	Source string `json:"source"`
+	// Provider is the git provider of a SCM delivery, such as: github, gitlab
+	Provider string `json:"provider,omitempty"`
+	// Event is the kind of the event, such as: push, tag, pr or run.initialize
+	Event string `json:"event,omitempty"`
+	// Repository is the address of the git repository
+	Repository string `json:"repository,omitempty"`
+	// RepositoryURLs are all the addresses of the git repository, they're used to match the GitRepositories
+	RepositoryURLs []string `json:"repositoryURLs,omitempty"`
+	// Ref is the git reference of a SCM delivery, such as: refs/heads/master
+	Ref string `json:"ref,omitempty"`
+	// Headers are the headers which are required to replay the delivery, the signatures and tokens are excluded
+	Headers map[string]string `json:"headers,omitempty"`
+	// Payload is the body of the delivery, it's empty if the payload is too large to keep
+	Payload string `json:"payload,omitempty"`
+	// PayloadHash is the SHA-256 checksum of the payload
+	PayloadHash string `json:"payloadHash,omitempty"`
+	// RedeliveryOf is the name of the original delivery if this is a redelivery
+	RedeliveryOf string `json:"redeliveryOf,omitempty"`
+}
+
+// WebhookDeliveryStatus represents the result of a webhook delivery
+type WebhookDeliveryStatus struct {
+	// Code is the HTTP status code of the response
+	Code int `json:"code,omitempty"`
+	// Message describes the result, such as: no pipeline matched
+	Message string `json:"message,omitempty"`
+	// Pipelines are the Pipelines which matched the delivery
+	Pipelines []WebhookDeliveryPipeline `json:"pipelines,omitempty"`
+	// DeliveredAt is the time when the delivery was received
+	DeliveredAt *metav1.Time `json:"deliveredAt,omitempty"`
+	// Verified indicates that the signature of a SCM delivery was verified against the secrets of the namespace
+	Verified bool `json:"verified,omitempty"`
+}
+
+// WebhookDeliveryPipeline is what a delivery did to a Pipeline
+type WebhookDeliveryPipeline struct {
+	// Name is the name of the Pipeline
+	Name string `json:"name"`
+	// Action is one of run, scan and reject
+	Action string `json:"action,omitempty"`
+	// PipelineRun is the name of the created PipelineRun
+	PipelineRun string `json:"pipelineRun,omitempty"`
+	// Error is the reason why the Pipeline was not triggered
+	Error string `json:"error,omitempty"`
+}
+
+// IsRedeliverable returns true if the payload was kept. A SCM delivery must be verified as well,
+// because a redelivery skips the signature verification.
+func (d *WebhookDelivery) IsRedeliverable() bool {
+	return d.Spec.Payload != "" && (d.Spec.Source != WebhookDeliverySourceSCM || d.Status.Verified)
+}
+
+func init() {
+	SchemeBuilder.Register(&WebhookDelivery{}, &WebhookDeliveryList{})
+}
@@ -33,14 +33,22 @@ import (
 	"k8s.io/apiserver/pkg/authentication/authenticator"
 	"k8s.io/apiserver/pkg/authentication/request/bearertoken"
 	unionauth "k8s.io/apiserver/pkg/authentication/request/union"
+	"k8s.io/apiserver/pkg/authorization/authorizer"
+	"k8s.io/apiserver/pkg/authorization/path"
+	unionauthz "k8s.io/apiserver/pkg/authorization/union"
 	"kubesphere.io/devops/pkg/api/devops/v1alpha1"
 	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
 	devopsbearertoken "kubesphere.io/devops/pkg/apiserver/authentication/authenticators/bearertoken"
+	"kubesphere.io/devops/pkg/apiserver/authentication/authenticators/idtoken"
+	"kubesphere.io/devops/pkg/apiserver/authentication/authenticators/tokenreview"
+	"kubesphere.io/devops/pkg/apiserver/authentication/oidc"
 	"kubesphere.io/devops/pkg/apiserver/authentication/request/anonymous"
+	"kubesphere.io/devops/pkg/apiserver/authorization/authorizers/subjectaccessreview"
 	"kubesphere.io/devops/pkg/apiserver/filters"
 	"kubesphere.io/devops/pkg/apiserver/request"
 	"kubesphere.io/devops/pkg/indexers"
 	"kubesphere.io/devops/pkg/kapis/oauth"
+	"kubesphere.io/devops/pkg/metrics"
 	"kubesphere.io/devops/pkg/models/auth"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
@@ -75,6 +83,17 @@ const (
 	MimeJsonPatchJson = "application/json-patch+json"
 )
 
+// alwaysAllowPaths are accessible without the authorization, such as the webhook deliveries from the git providers.
+// The deliveries are verified by their signatures instead.
+var alwaysAllowPaths = []string{
+	"/kapis/devops.kubesphere.io/v1alpha2/webhook/*",
+	"/kapis/devops.kubesphere.io/v1alpha3/webhooks/*",
+	"/v1alpha3/webhooks/*",
+	"/oauth/*",
+	"/apidocs.json",
+	"/apidocs/*",
+}
+
 type APIServer struct {
 
 	// number of kubesphere apiserver
@@ -107,6 +126,9 @@ type APIServer struct {
 	RuntimeCache runtimecache.Cache
 
 	Client client.Client
+
+	// idTokenVerifier verifies the ID tokens of the OIDC provider, it's only available in the oidc mode
+	idTokenVerifier oidc.Verifier
 }
 
 func (s *APIServer) PrepareRun(stopCh <-chan struct{}) error {
@@ -119,7 +141,14 @@ func (s *APIServer) PrepareRun(stopCh <-chan struct{}) error {
 		logStackOnRecover(panicReason, httpWriter)
 	})
 
+	if s.Config.AuthMode == apiserverconfig.AuthModeOIDC {
+		// both the authentication and the token exchange share the same verifier
+		s.idTokenVerifier = oidc.NewVerifier(s.Config.AuthenticationOptions.OIDC,
+			s.Config.AuthenticationOptions.MaximumClockSkew)
+	}
+
 	s.installKubeSphereAPIs()
+	s.container.Handle("/metrics", metrics.Handler())
 
 	for _, ws := range s.container.RegisteredWebServices() {
 		klog.V(2).Infof("%s", ws.RootPath())
@@ -157,18 +186,33 @@ func (s *APIServer) installKubeSphereAPIs() {
 		jenkinsCore)
 	utilruntime.Must(err)
 	wss = append(wss, v1alpha2WSS...)
-	wss = append(wss, devopsv1alpha3.AddToContainer(s.container, s.DevopsClient, s.KubernetesClient, s.Client, tokenIssue, jenkinsCore)...)
+	handlerClient := s.getHandlerClient()
+	wss = append(wss, devopsv1alpha3.AddToContainer(s.container, s.DevopsClient, s.KubernetesClient, s.Client, handlerClient,
+		tokenIssue, jenkinsCore, s.S3Client)...)
 	wss = append(wss, oauth.AddToContainer(s.container,
 		auth.NewTokenOperator(
 			s.CacheClient,
 			s.Config.AuthenticationOptions),
+		s.idTokenVerifier,
 	))
 	wss = append(wss, gitops.AddToContainer(s.container, &common.Options{
-		GenericClient: s.Client,
+		GenericClient: handlerClient,
 	}, s.Config.ArgoCDOption, s.Config.FluxCDOption)...)
 	doc.AddSwaggerService(wss, s.container)
 }
 
+// getHandlerClient returns the client of the API handlers.
+// It impersonates the request user in the impersonate mode, otherwise it's the own client of the server.
+func (s *APIServer) getHandlerClient() client.Client {
+	if s.Config.AuthMode != apiserverconfig.AuthModeImpersonate {
+		return s.Client
+	}
+	return k8s.NewImpersonatingClient(s.KubernetesClient.Config(), client.Options{
+		Scheme: s.Client.Scheme(),
+		Mapper: s.Client.RESTMapper(),
+	})
+}
+
 func getTokenIssue(config *apiserverconfig.Config) token.Issuer {
 	return token.NewTokenIssuer(config.AuthenticationOptions.JwtSecret, config.AuthenticationOptions.MaximumClockSkew)
 }
@@ -180,6 +224,12 @@ func (s *APIServer) Run(stopCh context.Context) (err error) {
 	if err := indexers.CreatePipelineRunIdentityIndexer(s.RuntimeCache); err != nil {
 		return err
 	}
+	if err := indexers.CreatePipelineGitURLIndexer(s.RuntimeCache); err != nil {
+		return err
+	}
+	if err := indexers.CreateGitRepositoryURLIndexer(s.RuntimeCache); err != nil {
+		return err
+	}
 
 	err = s.waitForResourceSync(stopCh)
 	if err != nil {
@@ -214,16 +264,31 @@ func (s *APIServer) buildHandlerChain(stopCh <-chan struct{}) {
 	handler := s.Server.Handler
 	handler = filters.WithKubeAPIServer(handler, s.KubernetesClient.Config(), &errorResponder{})
 
+	var authz authorizer.Authorizer
 	authenticators := make([]authenticator.Request, 0)
 	authenticators = append(authenticators, anonymous.NewAuthenticator())
 
 	switch s.Config.AuthMode {
 	case apiserverconfig.AuthModeToken:
-		authenticators = append(authenticators, bearertoken.New(devopsbearertoken.New()))
+		tokenOperator := auth.NewTokenOperator(s.CacheClient, s.Config.AuthenticationOptions)
+		authenticators = append(authenticators, bearertoken.New(devopsbearertoken.New(tokenOperator)))
+	case apiserverconfig.AuthModeTokenReview:
+		authenticators = append(authenticators, bearertoken.New(tokenreview.New(s.KubernetesClient.Kubernetes())))
+	case apiserverconfig.AuthModeImpersonate:
+		authenticators = append(authenticators, bearertoken.New(tokenreview.New(s.KubernetesClient.Kubernetes())))
+		pathAuthz, err := path.NewAuthorizer(alwaysAllowPaths)
+		utilruntime.Must(err)
+		authz = unionauthz.New(pathAuthz, subjectaccessreview.New(s.KubernetesClient.Kubernetes()))
+	case apiserverconfig.AuthModeOIDC:
+		// the DevOps tokens are issued by exchanging the ID tokens, and the ID tokens are accepted as well
+		tokenOperator := auth.NewTokenOperator(s.CacheClient, s.Config.AuthenticationOptions)
+		authenticators = append(authenticators, bearertoken.New(devopsbearertoken.New(tokenOperator)),
+			bearertoken.New(idtoken.New(s.idTokenVerifier)))
 	default:
-		// TODO error handle
+		// the auth mode was validated before starting
 	}
 
+	handler = filters.WithAuthorization(handler, authz)
 	handler = filters.WithAuthentication(handler, unionauth.New(authenticators...))
 	handler = filters.WithRequestInfo(handler, requestInfoResolver)
 
@@ -18,33 +18,41 @@ package bearertoken
 
 import (
 	"context"
-	"time"
-
-	"k8s.io/apiserver/pkg/authentication/user"
+	"fmt"
 
 	"k8s.io/apiserver/pkg/authentication/authenticator"
-	jwt "kubesphere.io/devops/pkg/jwt/token"
+	"k8s.io/apiserver/pkg/authentication/user"
+	jwttoken "kubesphere.io/devops/pkg/jwt/token"
+	"kubesphere.io/devops/pkg/models/auth"
 )
 
-// tokenAuthenticator implements an simple auth which only check the format of target JWT token
-type tokenAuthenticator struct{}
+// tokenAuthenticator verifies the signature and expiration of a JWT token, the revoked tokens are rejected as well
+type tokenAuthenticator struct {
+	tokenOperator auth.TokenManagementInterface
+}
 
-func New() authenticator.Token {
-	return &tokenAuthenticator{}
+// New creates an authenticator which verifies the tokens via the token operator
+func New(tokenOperator auth.TokenManagementInterface) authenticator.Token {
+	return &tokenAuthenticator{tokenOperator: tokenOperator}
 }
 
 func (a *tokenAuthenticator) AuthenticateToken(ctx context.Context, token string) (response *authenticator.Response, ok bool, err error) {
-	issuer := jwt.NewTokenIssuer("", time.Second)
-
 	var authenticated user.Info
-	if authenticated, _, err = issuer.VerifyWithoutClaimsValidation(token); err == nil {
-		response = &authenticator.Response{
-			User: &user.DefaultInfo{
-				Name: authenticated.GetName(),
-			}}
-		ok = true
-	} else {
-		ok = false
+	var tokenType jwttoken.TokenType
+	if authenticated, tokenType, err = a.tokenOperator.Verify(token); err != nil {
+		return
+	}
+	if !tokenType.GrantsAccess() {
+		// for example, the refresh tokens which are issued along with the access tokens
+		err = fmt.Errorf("the token type %q is not allowed to access the APIs", tokenType)
+		return
 	}
+	response = &authenticator.Response{
+		User: &user.DefaultInfo{
+			Name:   authenticated.GetName(),
+			Groups: authenticated.GetGroups(),
+			Extra:  authenticated.GetExtra(),
+		}}
+	ok = true
 	return
 }
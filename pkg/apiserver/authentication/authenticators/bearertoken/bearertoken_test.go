@@ -0,0 +1,85 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bearertoken
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"kubesphere.io/devops/pkg/apiserver/authentication/oauth"
+	authoptions "kubesphere.io/devops/pkg/apiserver/authentication/options"
+	"kubesphere.io/devops/pkg/client/cache"
+	"kubesphere.io/devops/pkg/jwt/token"
+	"kubesphere.io/devops/pkg/models/auth"
+)
+
+func TestTokenAuthenticator(t *testing.T) {
+	options := &authoptions.AuthenticationOptions{
+		JwtSecret:    "secret",
+		OAuthOptions: &oauth.Options{AccessTokenMaxAge: time.Minute},
+	}
+	operator := auth.NewTokenOperator(cache.NewSimpleCache(), options)
+	issued, err := operator.IssueTo(&user.DefaultInfo{Name: "admin", Groups: []string{"admin-group"}})
+	assert.Nil(t, err)
+
+	// a well-formed token which was not signed with the secret
+	forged, err := token.NewTokenIssuer("fake", 0).IssueTo(&user.DefaultInfo{Name: "admin"}, token.AccessToken, 0)
+	assert.Nil(t, err)
+
+	authenticator := New(operator)
+	tests := []struct {
+		name     string
+		token    string
+		wantUser string
+		wantOK   bool
+	}{{
+		name:     "a valid token",
+		token:    issued.AccessToken,
+		wantUser: "admin",
+		wantOK:   true,
+	}, {
+		name:  "a refresh token",
+		token: issued.RefreshToken,
+	}, {
+		name:  "a forged token",
+		token: forged,
+	}, {
+		name:  "not a JWT",
+		token: "fake",
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response, ok, err := authenticator.AuthenticateToken(context.Background(), tt.token)
+			assert.Equal(t, tt.wantOK, ok)
+			if tt.wantOK {
+				assert.Nil(t, err)
+				assert.Equal(t, tt.wantUser, response.User.GetName())
+				assert.Equal(t, []string{"admin-group"}, response.User.GetGroups())
+			} else {
+				assert.NotNil(t, err)
+			}
+		})
+	}
+
+	// the revoked token is rejected
+	assert.Nil(t, operator.RevokeAllUserTokens("admin"))
+	_, ok, _ := authenticator.AuthenticateToken(context.Background(), issued.AccessToken)
+	assert.False(t, ok)
+}
@@ -0,0 +1,50 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package idtoken
+
+import (
+	"context"
+	"errors"
+
+	"k8s.io/apiserver/pkg/authentication/authenticator"
+	"kubesphere.io/devops/pkg/apiserver/authentication/oidc"
+)
+
+// idTokenAuthenticator verifies the ID tokens of an OpenID Connect provider
+type idTokenAuthenticator struct {
+	verifier oidc.Verifier
+}
+
+// New creates an authenticator which accepts the ID tokens of an OpenID Connect provider as bearer tokens
+func New(verifier oidc.Verifier) authenticator.Token {
+	return &idTokenAuthenticator{verifier: verifier}
+}
+
+func (a *idTokenAuthenticator) AuthenticateToken(ctx context.Context, token string) (
+	response *authenticator.Response, ok bool, err error) {
+	info, err := a.verifier.Verify(ctx, token)
+	if err != nil {
+		if errors.Is(err, oidc.ErrIssuerMismatch) {
+			// leave it to the other authenticators
+			err = nil
+		}
+		return
+	}
+	response = &authenticator.Response{User: info}
+	ok = true
+	return
+}
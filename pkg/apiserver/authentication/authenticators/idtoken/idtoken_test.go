@@ -0,0 +1,60 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package idtoken
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"kubesphere.io/devops/pkg/apiserver/authentication/oidc"
+	"kubesphere.io/devops/pkg/apiserver/authentication/oidc/oidctest"
+)
+
+func TestIDTokenAuthenticator(t *testing.T) {
+	provider, err := oidctest.NewServer()
+	assert.Nil(t, err)
+	defer provider.Close()
+	another, err := oidctest.NewServer()
+	assert.Nil(t, err)
+	defer another.Close()
+
+	authenticator := New(oidc.NewVerifier(&oidc.Options{Issuer: provider.Issuer(), ClientID: "devops"}, time.Second))
+
+	idToken, err := provider.IDToken(map[string]interface{}{"sub": "alice", "aud": "devops", "groups": []string{"dev"}})
+	assert.Nil(t, err)
+	response, ok, err := authenticator.AuthenticateToken(context.Background(), idToken)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "alice", response.User.GetName())
+	assert.Equal(t, []string{"dev"}, response.User.GetGroups())
+
+	// an invalid token of the provider
+	idToken, err = provider.IDToken(map[string]interface{}{"sub": "alice", "aud": "other"})
+	assert.Nil(t, err)
+	_, ok, err = authenticator.AuthenticateToken(context.Background(), idToken)
+	assert.NotNil(t, err)
+	assert.False(t, ok)
+
+	// the tokens of other issuers are left to the other authenticators
+	idToken, err = another.IDToken(map[string]interface{}{"sub": "alice", "aud": "devops"})
+	assert.Nil(t, err)
+	_, ok, err = authenticator.AuthenticateToken(context.Background(), idToken)
+	assert.Nil(t, err)
+	assert.False(t, ok)
+}
@@ -0,0 +1,98 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tokenreview
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apiserver/pkg/authentication/authenticator"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/client-go/kubernetes"
+	"kubesphere.io/devops/pkg/client/cache"
+)
+
+// cacheTTL is how long an authenticated token is trusted without another TokenReview
+const cacheTTL = 10 * time.Second
+
+// tokenReviewAuthenticator delegates the authentication to Kubernetes via TokenReview.
+// It works for the deployments behind ks-apiserver which is able to review the tokens issued by itself.
+type tokenReviewAuthenticator struct {
+	client kubernetes.Interface
+	// cache keeps the responses of the authenticated tokens, the failures are not cached
+	cache *cache.TTLCache
+}
+
+// New creates an authenticator which forwards the tokens to a Kubernetes TokenReview
+func New(client kubernetes.Interface) authenticator.Token {
+	return &tokenReviewAuthenticator{
+		client: client,
+		cache:  cache.NewTTLCache(cacheTTL),
+	}
+}
+
+func (a *tokenReviewAuthenticator) AuthenticateToken(ctx context.Context, token string) (
+	response *authenticator.Response, ok bool, err error) {
+	hash := sha256.Sum256([]byte(token))
+	key := string(hash[:])
+	if cached, found := a.cache.Get(key); found {
+		return cached.(*authenticator.Response), true, nil
+	}
+
+	if response, ok, err = a.review(ctx, token); ok {
+		a.cache.Set(key, response)
+	}
+	return
+}
+
+func (a *tokenReviewAuthenticator) review(ctx context.Context, token string) (
+	response *authenticator.Response, ok bool, err error) {
+	review := &authenticationv1.TokenReview{
+		Spec: authenticationv1.TokenReviewSpec{Token: token},
+	}
+	if review, err = a.client.AuthenticationV1().TokenReviews().Create(ctx, review, metav1.CreateOptions{}); err != nil {
+		return
+	}
+
+	status := review.Status
+	if !status.Authenticated {
+		if status.Error != "" {
+			err = errors.New(status.Error)
+		}
+		return
+	}
+
+	extra := map[string][]string{}
+	for key, val := range status.User.Extra {
+		extra[key] = val
+	}
+	response = &authenticator.Response{
+		Audiences: status.Audiences,
+		User: &user.DefaultInfo{
+			Name:   status.User.Username,
+			UID:    status.User.UID,
+			Groups: status.User.Groups,
+			Extra:  extra,
+		},
+	}
+	ok = true
+	return
+}
@@ -0,0 +1,89 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tokenreview
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestTokenReviewAuthenticator(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	reviews := 0
+	client.PrependReactor("create", "tokenreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		reviews++
+		review := action.(k8stesting.CreateAction).GetObject().(*authenticationv1.TokenReview)
+		switch review.Spec.Token {
+		case "valid":
+			review.Status = authenticationv1.TokenReviewStatus{
+				Authenticated: true,
+				User: authenticationv1.UserInfo{
+					Username: "admin",
+					UID:      "uid",
+					Groups:   []string{"admin-group"},
+					Extra:    map[string]authenticationv1.ExtraValue{"scope": {"all"}},
+				},
+			}
+		case "expired":
+			review.Status = authenticationv1.TokenReviewStatus{Error: "token expired"}
+		}
+		return true, review, nil
+	})
+
+	now := time.Now()
+	authenticator := New(client).(*tokenReviewAuthenticator)
+	authenticator.cache.Now = func() time.Time {
+		return now
+	}
+
+	response, ok, err := authenticator.AuthenticateToken(context.Background(), "valid")
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "admin", response.User.GetName())
+	assert.Equal(t, "uid", response.User.GetUID())
+	assert.Equal(t, []string{"admin-group"}, response.User.GetGroups())
+	assert.Equal(t, map[string][]string{"scope": {"all"}}, response.User.GetExtra())
+
+	// the result is cached
+	_, ok, _ = authenticator.AuthenticateToken(context.Background(), "valid")
+	assert.True(t, ok)
+	assert.Equal(t, 1, reviews)
+
+	// review it again once the cache expired
+	now = now.Add(cacheTTL)
+	_, ok, _ = authenticator.AuthenticateToken(context.Background(), "valid")
+	assert.True(t, ok)
+	assert.Equal(t, 2, reviews)
+
+	_, ok, err = authenticator.AuthenticateToken(context.Background(), "expired")
+	assert.False(t, ok)
+	assert.NotNil(t, err)
+
+	// the failures are not cached
+	_, ok, err = authenticator.AuthenticateToken(context.Background(), "unknown")
+	assert.False(t, ok)
+	assert.Nil(t, err)
+	_, _, _ = authenticator.AuthenticateToken(context.Background(), "unknown")
+	assert.Equal(t, 5, reviews)
+}
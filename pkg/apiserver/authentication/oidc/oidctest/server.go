@@ -0,0 +1,99 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package oidctest provides an in-process OpenID Connect provider for the tests.
+package oidctest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/form3tech-oss/jwt-go"
+)
+
+// KeyID is the ID of the key which signs the ID tokens
+const KeyID = "oidctest"
+
+// Server is an OpenID Connect provider which serves the discovery document and the keys only.
+// The ID tokens are issued by IDToken instead of the authorization flows.
+type Server struct {
+	*httptest.Server
+	key *rsa.PrivateKey
+}
+
+// NewServer starts a provider, the caller should close it once the test finished
+func NewServer() (server *Server, err error) {
+	server = &Server{}
+	if server.key, err = rsa.GenerateKey(rand.Reader, 2048); err != nil {
+		return
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, map[string]interface{}{
+			"issuer":                                server.Issuer(),
+			"jwks_uri":                              server.Issuer() + "/keys",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, _ *http.Request) {
+		encode := func(val *big.Int) string {
+			return base64.RawURLEncoding.EncodeToString(val.Bytes())
+		}
+		writeJSON(w, map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA", "kid": KeyID, "use": "sig", "alg": "RS256",
+				"n": encode(server.key.N), "e": encode(big.NewInt(int64(server.key.E))),
+			}},
+		})
+	})
+	server.Server = httptest.NewServer(mux)
+	return
+}
+
+// Issuer returns the issuer URL of the provider
+func (s *Server) Issuer() string {
+	return s.URL
+}
+
+// IDToken signs an ID token with the claims.
+// The issuer, issued at and expiration (one hour later) claims are set if they are absent.
+func (s *Server) IDToken(claims map[string]interface{}) (string, error) {
+	now := time.Now()
+	mapClaims := jwt.MapClaims{
+		"iss": s.Issuer(),
+		"iat": now.Unix(),
+		"exp": now.Add(time.Hour).Unix(),
+	}
+	for key, val := range claims {
+		mapClaims[key] = val
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, mapClaims)
+	token.Header["kid"] = KeyID
+	return token.SignedString(s.key)
+}
+
+func writeJSON(w http.ResponseWriter, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(data)
+}
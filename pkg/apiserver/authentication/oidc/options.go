@@ -0,0 +1,81 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oidc
+
+import (
+	"errors"
+	"net/url"
+)
+
+const (
+	// DefaultUsernameClaim is the claim of the username, the subject is unique and never reassigned
+	DefaultUsernameClaim = "sub"
+	// DefaultGroupsClaim is the claim of the groups
+	DefaultGroupsClaim = "groups"
+)
+
+// Options are the options of an OpenID Connect provider which issues the ID tokens of the users
+type Options struct {
+	// Issuer is the URL of the provider, the discovery document is fetched from
+	// {Issuer}/.well-known/openid-configuration. It must be the same as the iss claim of the ID tokens.
+	Issuer string `json:"issuer" yaml:"issuer"`
+	// ClientID is the client which the ID tokens must be issued for, it's checked against the aud claim
+	ClientID string `json:"clientID" yaml:"clientID"`
+	// UsernameClaim is the claim of the ID tokens which is taken as the username
+	UsernameClaim string `json:"usernameClaim,omitempty" yaml:"usernameClaim,omitempty"`
+	// UsernamePrefix is prepended to the usernames to avoid the conflicts with other users
+	UsernamePrefix string `json:"usernamePrefix,omitempty" yaml:"usernamePrefix,omitempty"`
+	// GroupsClaim is the claim of the ID tokens which is taken as the groups of the user
+	GroupsClaim string `json:"groupsClaim,omitempty" yaml:"groupsClaim,omitempty"`
+	// GroupsPrefix is prepended to the groups to avoid the conflicts with other groups
+	GroupsPrefix string `json:"groupsPrefix,omitempty" yaml:"groupsPrefix,omitempty"`
+}
+
+// NewOptions creates the default options
+func NewOptions() *Options {
+	return &Options{
+		UsernameClaim: DefaultUsernameClaim,
+		GroupsClaim:   DefaultGroupsClaim,
+	}
+}
+
+// Validate checks if the options are valid
+func (o *Options) Validate() (errs []error) {
+	if o.Issuer == "" {
+		errs = append(errs, errors.New("the issuer of OIDC MUST not be empty"))
+	} else if issuer, err := url.Parse(o.Issuer); err != nil || issuer.Host == "" {
+		errs = append(errs, errors.New("the issuer of OIDC MUST be a valid URL"))
+	}
+	if o.ClientID == "" {
+		errs = append(errs, errors.New("the client ID of OIDC MUST not be empty"))
+	}
+	return
+}
+
+func (o *Options) getUsernameClaim() string {
+	if o.UsernameClaim == "" {
+		return DefaultUsernameClaim
+	}
+	return o.UsernameClaim
+}
+
+func (o *Options) getGroupsClaim() string {
+	if o.GroupsClaim == "" {
+		return DefaultGroupsClaim
+	}
+	return o.GroupsClaim
+}
@@ -0,0 +1,172 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/form3tech-oss/jwt-go"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"kubesphere.io/devops/pkg/jwt/token"
+)
+
+// ErrIssuerMismatch indicates that the token was not issued by the configured provider
+var ErrIssuerMismatch = errors.New("the token was not issued by the OIDC provider")
+
+// Verifier verifies the ID tokens of an OpenID Connect provider
+type Verifier interface {
+	// Verify returns the user of a valid ID token, otherwise returns an error
+	Verify(ctx context.Context, rawIDToken string) (user.Info, error)
+}
+
+type verifier struct {
+	options          *Options
+	maximumClockSkew time.Duration
+	client           *http.Client
+
+	mutex  sync.Mutex
+	keySet token.KeySet
+}
+
+// NewVerifier creates a Verifier of the provider. The provider is discovered once the first token comes,
+// then the apiserver is able to start before the provider is ready.
+func NewVerifier(options *Options, maximumClockSkew time.Duration) Verifier {
+	return &verifier{
+		options:          options,
+		maximumClockSkew: maximumClockSkew,
+		client:           &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Verify verifies an ID token as a token of the external issuer whose audience is the client ID
+func (v *verifier) Verify(ctx context.Context, rawIDToken string) (user.Info, error) {
+	// check the issuer before fetching anything from the provider
+	claims := jwt.MapClaims{}
+	if _, _, err := new(jwt.Parser).ParseUnverified(rawIDToken, claims); err != nil {
+		return nil, err
+	}
+	if iss, _ := claims["iss"].(string); iss != v.options.Issuer {
+		return nil, ErrIssuerMismatch
+	}
+
+	keySet, err := v.getKeySet(ctx)
+	if err != nil {
+		return nil, err
+	}
+	external := &token.ExternalIssuer{KeySet: keySet, Issuer: v.options.Issuer, Audience: v.options.ClientID}
+	claims = jwt.MapClaims{}
+	if err = external.Verify(rawIDToken, claims, v.maximumClockSkew); err != nil {
+		return nil, err
+	}
+	return v.getUser(claims)
+}
+
+// getUser maps the claims of a valid token to a user
+func (v *verifier) getUser(claims jwt.MapClaims) (user.Info, error) {
+	usernameClaim := v.options.getUsernameClaim()
+	username, _ := claims[usernameClaim].(string)
+	if username == "" {
+		return nil, fmt.Errorf("no claim '%s' found from the token", usernameClaim)
+	}
+	if usernameClaim == "email" {
+		// an unverified email might belong to anyone
+		if verified, ok := claims["email_verified"]; ok && verified != true {
+			return nil, errors.New("the email of the token is not verified")
+		}
+	}
+
+	var groups []string
+	switch val := claims[v.options.getGroupsClaim()].(type) {
+	case string:
+		groups = []string{v.options.GroupsPrefix + val}
+	case []interface{}:
+		for _, item := range val {
+			if group, ok := item.(string); ok {
+				groups = append(groups, v.options.GroupsPrefix+group)
+			}
+		}
+	}
+	return &user.DefaultInfo{
+		Name:   v.options.UsernamePrefix + username,
+		Groups: groups,
+	}, nil
+}
+
+// discovery is the part of the provider metadata which is required to verify the tokens,
+// see also https://openid.net/specs/openid-connect-discovery-1_0.html#ProviderMetadata
+type discovery struct {
+	Issuer  string `json:"issuer"`
+	JWKSURI string `json:"jwks_uri"`
+}
+
+// getKeySet returns the keys of the provider, the provider is discovered again if it failed last time
+func (v *verifier) getKeySet(ctx context.Context) (token.KeySet, error) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	if v.keySet != nil {
+		return v.keySet, nil
+	}
+
+	metadata, err := v.discover(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover the OIDC provider %s, error: %v", v.options.Issuer, err)
+	}
+	v.keySet = token.NewRemoteKeySet(metadata.JWKSURI)
+	return v.keySet, nil
+}
+
+func (v *verifier) discover(ctx context.Context) (metadata *discovery, err error) {
+	wellKnown := strings.TrimSuffix(v.options.Issuer, "/") + "/.well-known/openid-configuration"
+	var req *http.Request
+	if req, err = http.NewRequestWithContext(ctx, http.MethodGet, wellKnown, nil); err != nil {
+		return
+	}
+	var resp *http.Response
+	if resp, err = v.client.Do(req); err != nil {
+		return
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode != http.StatusOK {
+		err = fmt.Errorf("unexpected status code %d of %s", resp.StatusCode, wellKnown)
+		return
+	}
+
+	var data []byte
+	if data, err = io.ReadAll(resp.Body); err != nil {
+		return
+	}
+	metadata = &discovery{}
+	if err = json.Unmarshal(data, metadata); err != nil {
+		return
+	}
+	if metadata.Issuer != v.options.Issuer {
+		err = fmt.Errorf("the issuer '%s' of the discovery document does not match", metadata.Issuer)
+	} else if metadata.JWKSURI == "" {
+		err = errors.New("no jwks_uri found from the discovery document")
+	}
+	return
+}
@@ -0,0 +1,186 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oidc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"kubesphere.io/devops/pkg/apiserver/authentication/oidc/oidctest"
+)
+
+func TestVerifier(t *testing.T) {
+	provider, err := oidctest.NewServer()
+	assert.Nil(t, err)
+	defer provider.Close()
+	another, err := oidctest.NewServer()
+	assert.Nil(t, err)
+	defer another.Close()
+
+	now := time.Now()
+	tests := []struct {
+		name     string
+		options  *Options
+		claims   map[string]interface{}
+		signer   *oidctest.Server
+		wantUser user.Info
+		wantErr  bool
+	}{{
+		name:    "normal case",
+		options: &Options{Issuer: provider.Issuer(), ClientID: "devops"},
+		claims: map[string]interface{}{
+			"sub": "alice", "aud": "devops", "groups": []string{"dev", "ops"},
+		},
+		wantUser: &user.DefaultInfo{Name: "alice", Groups: []string{"dev", "ops"}},
+	}, {
+		name: "custom claims and prefixes",
+		options: &Options{
+			Issuer: provider.Issuer(), ClientID: "devops",
+			UsernameClaim: "email", UsernamePrefix: "oidc:",
+			GroupsClaim: "roles", GroupsPrefix: "oidc:",
+		},
+		claims: map[string]interface{}{
+			"sub": "1234", "aud": []string{"other", "devops"},
+			"email": "alice@example.com", "email_verified": true, "roles": "admin",
+		},
+		wantUser: &user.DefaultInfo{Name: "oidc:alice@example.com", Groups: []string{"oidc:admin"}},
+	}, {
+		name:    "unverified email",
+		options: &Options{Issuer: provider.Issuer(), ClientID: "devops", UsernameClaim: "email"},
+		claims: map[string]interface{}{
+			"aud": "devops", "email": "alice@example.com", "email_verified": false,
+		},
+		wantErr: true,
+	}, {
+		name:    "no username",
+		options: &Options{Issuer: provider.Issuer(), ClientID: "devops"},
+		claims:  map[string]interface{}{"aud": "devops"},
+		wantErr: true,
+	}, {
+		name:    "another client",
+		options: &Options{Issuer: provider.Issuer(), ClientID: "devops"},
+		claims:  map[string]interface{}{"sub": "alice", "aud": "other"},
+		wantErr: true,
+	}, {
+		name:    "no audience",
+		options: &Options{Issuer: provider.Issuer(), ClientID: "devops"},
+		claims:  map[string]interface{}{"sub": "alice"},
+		wantErr: true,
+	}, {
+		name:    "expired",
+		options: &Options{Issuer: provider.Issuer(), ClientID: "devops"},
+		claims: map[string]interface{}{
+			"sub": "alice", "aud": "devops", "exp": now.Add(-time.Minute).Unix(),
+		},
+		wantErr: true,
+	}, {
+		name:    "not valid yet",
+		options: &Options{Issuer: provider.Issuer(), ClientID: "devops"},
+		claims: map[string]interface{}{
+			"sub": "alice", "aud": "devops", "nbf": now.Add(time.Minute).Unix(),
+		},
+		wantErr: true,
+	}, {
+		name:    "another issuer",
+		options: &Options{Issuer: provider.Issuer(), ClientID: "devops"},
+		claims:  map[string]interface{}{"sub": "alice", "aud": "devops"},
+		signer:  another,
+		wantErr: true,
+	}, {
+		name:    "forged issuer",
+		options: &Options{Issuer: provider.Issuer(), ClientID: "devops"},
+		claims:  map[string]interface{}{"sub": "alice", "aud": "devops", "iss": provider.Issuer()},
+		signer:  another,
+		wantErr: true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signer := tt.signer
+			if signer == nil {
+				signer = provider
+			}
+			idToken, err := signer.IDToken(tt.claims)
+			assert.Nil(t, err)
+
+			got, err := NewVerifier(tt.options, 10*time.Second).Verify(context.Background(), idToken)
+			if tt.wantErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.wantUser, got)
+		})
+	}
+}
+
+func TestVerifier_discovery(t *testing.T) {
+	provider, err := oidctest.NewServer()
+	assert.Nil(t, err)
+	defer provider.Close()
+	idToken, err := provider.IDToken(map[string]interface{}{"sub": "alice", "aud": "devops"})
+	assert.Nil(t, err)
+
+	// a token of another issuer is rejected before discovering
+	transport := &fakeTransport{}
+	verifier := NewVerifier(&Options{Issuer: "https://oidc.example.com", ClientID: "devops"}, 0).(*verifier)
+	verifier.client = &http.Client{Transport: transport}
+	_, err = verifier.Verify(context.Background(), idToken)
+	assert.Equal(t, ErrIssuerMismatch, err)
+	assert.Equal(t, 0, transport.requests)
+
+	// the provider is discovered again after a failure
+	verifier.options.Issuer = provider.Issuer()
+	_, err = verifier.Verify(context.Background(), idToken)
+	assert.NotNil(t, err)
+	assert.Equal(t, 1, transport.requests)
+
+	transport.available = true
+	info, err := verifier.Verify(context.Background(), idToken)
+	assert.Nil(t, err)
+	assert.Equal(t, "alice", info.GetName())
+	assert.Equal(t, 2, transport.requests)
+
+	// the provider is discovered once
+	_, err = verifier.Verify(context.Background(), idToken)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, transport.requests)
+}
+
+// fakeTransport counts the discovery requests, and fails them until it's available
+type fakeTransport struct {
+	available bool
+	requests  int
+}
+
+func (t *fakeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.requests++
+	if !t.available {
+		return nil, errors.New("connection refused")
+	}
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestOptions_Validate(t *testing.T) {
+	assert.Empty(t, (&Options{Issuer: "https://oidc.example.com", ClientID: "devops"}).Validate())
+	assert.Len(t, NewOptions().Validate(), 2)
+	assert.Len(t, (&Options{Issuer: "oidc.example.com", ClientID: "devops"}).Validate(), 1)
+}
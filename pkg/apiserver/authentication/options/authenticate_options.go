@@ -23,6 +23,7 @@ import (
 	"github.com/spf13/pflag"
 
 	"kubesphere.io/devops/pkg/apiserver/authentication/oauth"
+	"kubesphere.io/devops/pkg/apiserver/authentication/oidc"
 )
 
 type AuthenticationOptions struct {
@@ -45,6 +46,15 @@ type AuthenticationOptions struct {
 	MultipleLogin bool `json:"multipleLogin" yaml:"multipleLogin"`
 	// secret to sign jwt token
 	JwtSecret string `json:"-" yaml:"jwtSecret"`
+	// JwksURL is the JSON Web Key Set URL of a third party token issuer, such as an OIDC provider.
+	// The tokens signed by the keys of it are accepted as well.
+	JwksURL string `json:"jwksURL,omitempty" yaml:"jwksURL,omitempty"`
+	// JwksIssuer is the expected issuer (iss) of the tokens signed by the keys from JwksURL
+	JwksIssuer string `json:"jwksIssuer,omitempty" yaml:"jwksIssuer,omitempty"`
+	// JwksAudience is the expected audience (aud) of the tokens signed by the keys from JwksURL, it's usually the client ID
+	JwksAudience string `json:"jwksAudience,omitempty" yaml:"jwksAudience,omitempty"`
+	// OIDC is the OpenID Connect provider which authenticates the users in the oidc auth mode
+	OIDC *oidc.Options `json:"oidc,omitempty" yaml:"oidc,omitempty"`
 	// OAuthOptions defines options needed for integrated oauth plugins
 	OAuthOptions *oauth.Options `json:"oauthOptions" yaml:"oauthOptions"`
 	// KubectlImage is the image address we use to create kubectl pod for users who have admin access to the cluster.
@@ -67,8 +77,11 @@ func NewAuthenticateOptions() *AuthenticationOptions {
 
 func (options *AuthenticationOptions) Validate() []error {
 	var errs []error
-	if len(options.JwtSecret) == 0 {
-		errs = append(errs, errors.New("JWT secret MUST not be empty"))
+	if len(options.JwtSecret) == 0 && len(options.JwksURL) == 0 {
+		errs = append(errs, errors.New("JWT secret and JWKS URL MUST not be empty at the same time"))
+	}
+	if len(options.JwksURL) != 0 && (len(options.JwksIssuer) == 0 || len(options.JwksAudience) == 0) {
+		errs = append(errs, errors.New("JWKS issuer and audience MUST not be empty if the JWKS URL is set"))
 	}
 	if options.AuthenticateRateLimiterMaxTries > options.LoginHistoryMaximumEntries {
 		errs = append(errs, errors.New("authenticateRateLimiterMaxTries MUST not be greater than loginHistoryMaximumEntries"))
@@ -81,6 +94,9 @@ func (options *AuthenticationOptions) AddFlags(fs *pflag.FlagSet, s *Authenticat
 	fs.DurationVar(&options.AuthenticateRateLimiterDuration, "authenticate-rate-limiter-duration", s.AuthenticateRateLimiterDuration, "")
 	fs.BoolVar(&options.MultipleLogin, "multiple-login", s.MultipleLogin, "Allow multiple login with the same account, disable means only one user can login at the same time.")
 	fs.StringVar(&options.JwtSecret, "jwt-secret", s.JwtSecret, "Secret to sign jwt token, must not be empty.")
+	fs.StringVar(&options.JwksURL, "jwks-url", s.JwksURL, "The JWKS URL of a third party token issuer, the tokens signed by it are accepted as well.")
+	fs.StringVar(&options.JwksIssuer, "jwks-issuer", s.JwksIssuer, "The expected issuer of the tokens signed by the JWKS.")
+	fs.StringVar(&options.JwksAudience, "jwks-audience", s.JwksAudience, "The expected audience of the tokens signed by the JWKS, it's usually the client ID.")
 	fs.DurationVar(&options.LoginHistoryRetentionPeriod, "login-history-retention-period", s.LoginHistoryRetentionPeriod, "login-history-retention-period defines how long login history should be kept.")
 	fs.IntVar(&options.LoginHistoryMaximumEntries, "login-history-maximum-entries", s.LoginHistoryMaximumEntries, "login-history-maximum-entries defines how many entries of login history should be kept.")
 	fs.DurationVar(&options.OAuthOptions.AccessTokenMaxAge, "access-token-max-age", s.OAuthOptions.AccessTokenMaxAge, "access-token-max-age control the lifetime of access tokens, 0 means no expiration.")
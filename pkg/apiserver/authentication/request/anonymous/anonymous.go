@@ -23,6 +23,9 @@ import (
 	"strings"
 )
 
+// Username is the name of the user who sends a request without the Authorization header
+const Username = "anonymous"
+
 // Authenticator implements an anonymous auth
 type Authenticator struct{}
 
@@ -34,10 +37,23 @@ func (a *Authenticator) AuthenticateRequest(req *http.Request) (*authenticator.R
 	if auth := strings.TrimSpace(req.Header.Get("Authorization")); auth == "" {
 		return &authenticator.Response{
 			User: &user.DefaultInfo{
-				Name:   "anonymous",
+				Name:   Username,
 				Groups: []string{user.AllAuthenticated},
 			},
 		}, true, nil
 	}
 	return nil, false, nil
 }
+
+// KubernetesUser returns the user which stands for the given one in Kubernetes.
+// Kubernetes knows the anonymous user as system:anonymous which is not authenticated,
+// other users are returned as they are.
+func KubernetesUser(info user.Info) user.Info {
+	if info.GetName() == Username {
+		return &user.DefaultInfo{
+			Name:   user.Anonymous,
+			Groups: []string{user.AllUnauthenticated},
+		}
+	}
+	return info
+}
@@ -0,0 +1,139 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package subjectaccessreview
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apiserver/pkg/authorization/authorizer"
+	"k8s.io/client-go/kubernetes"
+	"kubesphere.io/devops/pkg/apiserver/authentication/request/anonymous"
+	"kubesphere.io/devops/pkg/client/cache"
+)
+
+// cacheTTL is how long an allowed decision is reused for the same user and attributes
+const cacheTTL = 10 * time.Second
+
+// subjectAccessReviewAuthorizer delegates the authorization to Kubernetes via SubjectAccessReview.
+// Then the permissions of the DevOps APIs are able to be managed by the RBAC of Kubernetes.
+type subjectAccessReviewAuthorizer struct {
+	client kubernetes.Interface
+	// cache keeps the keys of the allowed reviews, the denied ones are not cached
+	cache *cache.TTLCache
+}
+
+// New creates an authorizer which asks Kubernetes whether the request user is able to access the attributes
+func New(client kubernetes.Interface) authorizer.Authorizer {
+	return &subjectAccessReviewAuthorizer{
+		client: client,
+		cache:  cache.NewTTLCache(cacheTTL),
+	}
+}
+
+func (a *subjectAccessReviewAuthorizer) Authorize(ctx context.Context, attrs authorizer.Attributes) (
+	decision authorizer.Decision, reason string, err error) {
+	if attrs.GetUser() == nil {
+		return authorizer.DecisionDeny, "no user found in the request", nil
+	}
+
+	review := newSubjectAccessReview(attrs)
+	key := getCacheKey(review)
+	if _, found := a.cache.Get(key); found {
+		return authorizer.DecisionAllow, "", nil
+	}
+
+	if review, err = a.client.AuthorizationV1().SubjectAccessReviews().Create(ctx, review, metav1.CreateOptions{}); err != nil {
+		return authorizer.DecisionNoOpinion, "", err
+	}
+
+	status := review.Status
+	switch {
+	case status.Allowed:
+		if key != "" {
+			a.cache.Set(key, true)
+		}
+		decision = authorizer.DecisionAllow
+	case status.Denied:
+		decision = authorizer.DecisionDeny
+	default:
+		decision = authorizer.DecisionNoOpinion
+	}
+	reason = status.Reason
+	if status.EvaluationError != "" {
+		err = fmt.Errorf("failed to evaluate the access: %s", status.EvaluationError)
+	}
+	return
+}
+
+// newSubjectAccessReview turns the attributes of a request into a SubjectAccessReview
+func newSubjectAccessReview(attrs authorizer.Attributes) *authorizationv1.SubjectAccessReview {
+	info := anonymous.KubernetesUser(attrs.GetUser())
+	extra := map[string]authorizationv1.ExtraValue{}
+	for key, val := range info.GetExtra() {
+		extra[key] = val
+	}
+
+	review := &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			User:   info.GetName(),
+			UID:    info.GetUID(),
+			Groups: info.GetGroups(),
+			Extra:  extra,
+		},
+	}
+	if attrs.IsResourceRequest() {
+		review.Spec.ResourceAttributes = &authorizationv1.ResourceAttributes{
+			Namespace:   attrs.GetNamespace(),
+			Verb:        attrs.GetVerb(),
+			Group:       attrs.GetAPIGroup(),
+			Version:     attrs.GetAPIVersion(),
+			Resource:    attrs.GetResource(),
+			Subresource: attrs.GetSubresource(),
+			Name:        attrs.GetName(),
+		}
+	} else {
+		review.Spec.NonResourceAttributes = &authorizationv1.NonResourceAttributes{
+			Path: attrs.GetPath(),
+			Verb: attrs.GetVerb(),
+		}
+	}
+	return review
+}
+
+// getCacheKey returns a key which is unique for the subject and the attributes of a review
+func getCacheKey(review *authorizationv1.SubjectAccessReview) string {
+	spec := review.Spec
+	if len(spec.Extra) > 0 {
+		// the extra is not a part of the key, then a review with it will not be cached
+		return ""
+	}
+
+	parts := []string{spec.User, spec.UID, strings.Join(spec.Groups, ",")}
+	if attrs := spec.ResourceAttributes; attrs != nil {
+		parts = append(parts, attrs.Namespace, attrs.Verb, attrs.Group, attrs.Version,
+			attrs.Resource, attrs.Subresource, attrs.Name)
+	}
+	if attrs := spec.NonResourceAttributes; attrs != nil {
+		parts = append(parts, attrs.Path, attrs.Verb)
+	}
+	return fmt.Sprintf("%q", parts)
+}
@@ -0,0 +1,159 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package subjectaccessreview
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/authorization/authorizer"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestSubjectAccessReviewAuthorizer(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	var reviews []*authorizationv1.SubjectAccessReview
+	client.PrependReactor("create", "subjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SubjectAccessReview)
+		reviews = append(reviews, review.DeepCopy())
+		switch {
+		case review.Spec.User == "admin":
+			review.Status = authorizationv1.SubjectAccessReviewStatus{Allowed: true}
+		case review.Spec.User == "banned":
+			review.Status = authorizationv1.SubjectAccessReviewStatus{Denied: true, Reason: "banned"}
+		case review.Spec.ResourceAttributes != nil && review.Spec.ResourceAttributes.Verb == "get":
+			review.Status = authorizationv1.SubjectAccessReviewStatus{Allowed: true}
+		default:
+			review.Status = authorizationv1.SubjectAccessReviewStatus{EvaluationError: "no rules"}
+		}
+		return true, review, nil
+	})
+
+	now := time.Now()
+	authz := New(client).(*subjectAccessReviewAuthorizer)
+	authz.cache.Now = func() time.Time {
+		return now
+	}
+	getLog := authorizer.AttributesRecord{
+		User:            &user.DefaultInfo{Name: "admin", Groups: []string{"admins"}},
+		Verb:            "get",
+		Namespace:       "ns",
+		APIGroup:        "devops.kubesphere.io",
+		APIVersion:      "v1alpha3",
+		Resource:        "pipelineruns",
+		Subresource:     "log",
+		Name:            "run",
+		ResourceRequest: true,
+	}
+
+	decision, _, err := authz.Authorize(context.Background(), getLog)
+	assert.Nil(t, err)
+	assert.Equal(t, authorizer.DecisionAllow, decision)
+	assert.Equal(t, authorizationv1.SubjectAccessReviewSpec{
+		User:   "admin",
+		Groups: []string{"admins"},
+		Extra:  map[string]authorizationv1.ExtraValue{},
+		ResourceAttributes: &authorizationv1.ResourceAttributes{
+			Namespace:   "ns",
+			Verb:        "get",
+			Group:       "devops.kubesphere.io",
+			Version:     "v1alpha3",
+			Resource:    "pipelineruns",
+			Subresource: "log",
+			Name:        "run",
+		},
+	}, reviews[0].Spec)
+
+	// the allowed decision is cached
+	decision, _, _ = authz.Authorize(context.Background(), getLog)
+	assert.Equal(t, authorizer.DecisionAllow, decision)
+	assert.Equal(t, 1, len(reviews))
+
+	// review it again once the cache expired
+	now = now.Add(cacheTTL)
+	decision, _, _ = authz.Authorize(context.Background(), getLog)
+	assert.Equal(t, authorizer.DecisionAllow, decision)
+	assert.Equal(t, 2, len(reviews))
+
+	// the denied decisions are not cached
+	banned := getLog
+	banned.User = &user.DefaultInfo{Name: "banned"}
+	for i := 0; i < 2; i++ {
+		decision, reason, err := authz.Authorize(context.Background(), banned)
+		assert.Nil(t, err)
+		assert.Equal(t, authorizer.DecisionDeny, decision)
+		assert.Equal(t, "banned", reason)
+	}
+	assert.Equal(t, 4, len(reviews))
+
+	// the anonymous user is reviewed as system:anonymous
+	anonymousPost := authorizer.AttributesRecord{
+		User: &user.DefaultInfo{Name: "anonymous", Groups: []string{user.AllAuthenticated}},
+		Verb: "post",
+		Path: "/v1alpha3/webhooks/scm",
+	}
+	decision, _, err = authz.Authorize(context.Background(), anonymousPost)
+	assert.NotNil(t, err)
+	assert.Equal(t, authorizer.DecisionNoOpinion, decision)
+	assert.Equal(t, authorizationv1.SubjectAccessReviewSpec{
+		User:   user.Anonymous,
+		Groups: []string{user.AllUnauthenticated},
+		Extra:  map[string]authorizationv1.ExtraValue{},
+		NonResourceAttributes: &authorizationv1.NonResourceAttributes{
+			Path: "/v1alpha3/webhooks/scm",
+			Verb: "post",
+		},
+	}, reviews[4].Spec)
+
+	// no user
+	decision, _, err = authz.Authorize(context.Background(), authorizer.AttributesRecord{Verb: "get"})
+	assert.Nil(t, err)
+	assert.Equal(t, authorizer.DecisionDeny, decision)
+	assert.Equal(t, 5, len(reviews))
+}
+
+func TestGetCacheKey(t *testing.T) {
+	review := newSubjectAccessReview(authorizer.AttributesRecord{
+		User:            &user.DefaultInfo{Name: "admin"},
+		Verb:            "get",
+		Namespace:       "ns",
+		Resource:        "pipelines",
+		ResourceRequest: true,
+	})
+	another := newSubjectAccessReview(authorizer.AttributesRecord{
+		User:            &user.DefaultInfo{Name: "admin"},
+		Verb:            "get",
+		Namespace:       "ns",
+		Resource:        "pipelineruns",
+		ResourceRequest: true,
+	})
+	assert.NotEmpty(t, getCacheKey(review))
+	assert.NotEqual(t, getCacheKey(review), getCacheKey(another))
+
+	// the reviews with the extra are not cached
+	withExtra := newSubjectAccessReview(authorizer.AttributesRecord{
+		User: &user.DefaultInfo{Name: "admin", Extra: map[string][]string{"scope": {"all"}}},
+		Verb: "get",
+	})
+	assert.Empty(t, getCacheKey(withExtra))
+}
@@ -0,0 +1,107 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package filters
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/apiserver/pkg/authorization/authorizer"
+	"k8s.io/apiserver/pkg/endpoints/handlers/responsewriters"
+	"k8s.io/klog/v2"
+	"kubesphere.io/devops/pkg/apiserver/request"
+)
+
+// WithAuthorization checks if the request user is allowed to access the requested resource.
+// It must be installed after the authentication and the request info filters.
+func WithAuthorization(handler http.Handler, authz authorizer.Authorizer) http.Handler {
+	if authz == nil {
+		klog.Warningf("Authorization is disabled")
+		return handler
+	}
+
+	s := serializer.NewCodecFactory(runtime.NewScheme()).WithoutConversion()
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		requestInfo, found := request.RequestInfoFrom(ctx)
+		if !found {
+			responsewriters.InternalError(w, req, errors.New("no RequestInfo found in the context"))
+			return
+		}
+		attrs := getAuthorizerAttributes(req, requestInfo)
+
+		decision, reason, err := authz.Authorize(ctx, attrs)
+		if decision == authorizer.DecisionAllow {
+			handler.ServeHTTP(w, req)
+			return
+		}
+		if err != nil {
+			klog.Errorf("failed to authorize the request %s %s, error: %v", req.Method, req.URL.Path, err)
+			responsewriters.InternalError(w, req, err)
+			return
+		}
+
+		klog.V(4).Infof("Forbidden: %s %s, reason: %q", req.Method, req.URL.Path, reason)
+		gv := schema.GroupVersion{Group: requestInfo.APIGroup, Version: requestInfo.APIVersion}
+		gr := schema.GroupResource{Group: requestInfo.APIGroup, Resource: requestInfo.Resource}
+		responsewriters.ErrorNegotiated(apierrors.NewForbidden(gr, requestInfo.Name,
+			fmt.Errorf("user %q cannot %s the resource, reason: %s", getUserName(attrs), attrs.GetVerb(), reason)),
+			s, gv, w, req)
+	})
+}
+
+// getAuthorizerAttributes turns the request info into the attributes of an authorizer
+func getAuthorizerAttributes(req *http.Request, info *request.RequestInfo) authorizer.AttributesRecord {
+	attrs := authorizer.AttributesRecord{
+		ResourceRequest: info.IsResourceRequest,
+		Path:            info.Path,
+		Verb:            info.Verb,
+	}
+	if user, ok := request.UserFrom(req.Context()); ok {
+		attrs.User = user
+	}
+
+	if info.IsResourceRequest {
+		attrs.APIGroup = info.APIGroup
+		attrs.APIVersion = info.APIVersion
+		attrs.Resource = info.Resource
+		attrs.Subresource = info.Subresource
+		attrs.Name = info.Name
+		// a DevOps project is a namespace in Kubernetes
+		attrs.Namespace = info.Namespace
+		if attrs.Namespace == "" {
+			attrs.Namespace = info.DevOps
+		}
+	} else {
+		// the verb of a non-resource request is the lower-cased HTTP method
+		attrs.Verb = strings.ToLower(req.Method)
+	}
+	return attrs
+}
+
+func getUserName(attrs authorizer.Attributes) string {
+	if attrs.GetUser() == nil {
+		return ""
+	}
+	return attrs.GetUser().GetName()
+}
@@ -0,0 +1,166 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package filters
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/authorization/authorizer"
+	"kubesphere.io/devops/pkg/apiserver/request"
+)
+
+func TestWithAuthorization(t *testing.T) {
+	resolver := &request.RequestInfoFactory{
+		APIPrefixes:          sets.NewString("api", "apis", "kapis", "kapi"),
+		GrouplessAPIPrefixes: sets.NewString("api", "kapi"),
+	}
+	admin := &user.DefaultInfo{Name: "admin"}
+
+	tests := []struct {
+		name      string
+		method    string
+		path      string
+		decision  authorizer.Decision
+		err       error
+		wantCode  int
+		wantAttrs authorizer.AttributesRecord
+	}{{
+		name:     "allowed resource request",
+		method:   http.MethodGet,
+		path:     "/kapis/devops.kubesphere.io/v1alpha3/namespaces/ns/pipelineruns/run/log",
+		decision: authorizer.DecisionAllow,
+		wantCode: http.StatusOK,
+		wantAttrs: authorizer.AttributesRecord{
+			User:            admin,
+			Verb:            "get",
+			Namespace:       "ns",
+			APIGroup:        "devops.kubesphere.io",
+			APIVersion:      "v1alpha3",
+			Resource:        "pipelineruns",
+			Subresource:     "log",
+			Name:            "run",
+			ResourceRequest: true,
+			Path:            "/kapis/devops.kubesphere.io/v1alpha3/namespaces/ns/pipelineruns/run/log",
+		},
+	}, {
+		name:     "the DevOps project is the namespace",
+		method:   http.MethodPost,
+		path:     "/kapis/devops.kubesphere.io/v1alpha3/devops/project/pipelines",
+		decision: authorizer.DecisionAllow,
+		wantCode: http.StatusOK,
+		wantAttrs: authorizer.AttributesRecord{
+			User:            admin,
+			Verb:            "create",
+			Namespace:       "project",
+			APIGroup:        "devops.kubesphere.io",
+			APIVersion:      "v1alpha3",
+			Resource:        "pipelines",
+			ResourceRequest: true,
+			Path:            "/kapis/devops.kubesphere.io/v1alpha3/devops/project/pipelines",
+		},
+	}, {
+		name:     "non-resource request",
+		method:   http.MethodPost,
+		path:     "/v1alpha3/webhooks/scm",
+		decision: authorizer.DecisionAllow,
+		wantCode: http.StatusOK,
+		wantAttrs: authorizer.AttributesRecord{
+			User: admin,
+			Verb: "post",
+			Path: "/v1alpha3/webhooks/scm",
+		},
+	}, {
+		name:     "forbidden",
+		method:   http.MethodDelete,
+		path:     "/kapis/devops.kubesphere.io/v1alpha3/namespaces/ns/pipelineruns/run",
+		decision: authorizer.DecisionDeny,
+		wantCode: http.StatusForbidden,
+		wantAttrs: authorizer.AttributesRecord{
+			User:            admin,
+			Verb:            "delete",
+			Namespace:       "ns",
+			APIGroup:        "devops.kubesphere.io",
+			APIVersion:      "v1alpha3",
+			Resource:        "pipelineruns",
+			Name:            "run",
+			ResourceRequest: true,
+			Path:            "/kapis/devops.kubesphere.io/v1alpha3/namespaces/ns/pipelineruns/run",
+		},
+	}, {
+		name:     "no opinion is forbidden",
+		method:   http.MethodGet,
+		path:     "/v1alpha3/scms/github/organizations",
+		decision: authorizer.DecisionNoOpinion,
+		wantCode: http.StatusForbidden,
+		wantAttrs: authorizer.AttributesRecord{
+			User: admin,
+			Verb: "get",
+			Path: "/v1alpha3/scms/github/organizations",
+		},
+	}, {
+		name:     "failed to authorize",
+		method:   http.MethodGet,
+		path:     "/v1alpha3/scms/github/organizations",
+		decision: authorizer.DecisionNoOpinion,
+		err:      errors.New("fake error"),
+		wantCode: http.StatusInternalServerError,
+		wantAttrs: authorizer.AttributesRecord{
+			User: admin,
+			Verb: "get",
+			Path: "/v1alpha3/scms/github/organizations",
+		},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotAttrs authorizer.Attributes
+			authz := authorizer.AuthorizerFunc(func(ctx context.Context, attrs authorizer.Attributes) (authorizer.Decision, string, error) {
+				gotAttrs = attrs
+				return tt.decision, "", tt.err
+			})
+			handler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			info, err := resolver.NewRequestInfo(req)
+			assert.Nil(t, err)
+			ctx := request.WithRequestInfo(req.Context(), info)
+			ctx = request.WithUser(ctx, admin)
+			recorder := httptest.NewRecorder()
+			WithAuthorization(handler, authz).ServeHTTP(recorder, req.WithContext(ctx))
+
+			assert.Equal(t, tt.wantCode, recorder.Code)
+			assert.Equal(t, tt.wantAttrs, gotAttrs)
+		})
+	}
+}
+
+func TestWithAuthorizationDisabled(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	})
+	recorder := httptest.NewRecorder()
+	WithAuthorization(handler, nil).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/v1alpha3/scms", nil))
+	assert.Equal(t, http.StatusAccepted, recorder.Code)
+}
@@ -0,0 +1,83 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+// TTLCache is an in-memory cache whose records expire after the same duration.
+// It's safe for concurrent use.
+type TTLCache struct {
+	ttl     time.Duration
+	mutex   sync.Mutex
+	records map[string]ttlRecord
+
+	// Now returns the current time, it could be replaced in tests
+	Now func() time.Time
+}
+
+type ttlRecord struct {
+	value    interface{}
+	expireAt time.Time
+}
+
+// NewTTLCache creates a cache whose records expire after the ttl
+func NewTTLCache(ttl time.Duration) *TTLCache {
+	return &TTLCache{
+		ttl:     ttl,
+		records: map[string]ttlRecord{},
+		Now:     time.Now,
+	}
+}
+
+// Get returns the value of a record which has not expired
+func (c *TTLCache) Get(key string) (value interface{}, ok bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	var record ttlRecord
+	if record, ok = c.records[key]; !ok {
+		return
+	}
+	if !c.Now().Before(record.expireAt) {
+		delete(c.records, key)
+		return nil, false
+	}
+	return record.value, true
+}
+
+// Set puts a record which expires after the ttl
+func (c *TTLCache) Set(key string, value interface{}) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	now := c.Now()
+	// clean up the expired records, then the cache will not grow forever
+	for k, record := range c.records {
+		if !now.Before(record.expireAt) {
+			delete(c.records, k)
+		}
+	}
+	c.records[key] = ttlRecord{value: value, expireAt: now.Add(c.ttl)}
+}
+
+// Len returns the number of the records, including the expired ones which have not been cleaned up
+func (c *TTLCache) Len() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return len(c.records)
+}
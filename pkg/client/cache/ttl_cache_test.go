@@ -0,0 +1,62 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTTLCache(t *testing.T) {
+	now := time.Now()
+	c := NewTTLCache(10 * time.Second)
+	c.Now = func() time.Time {
+		return now
+	}
+
+	_, ok := c.Get("foo")
+	assert.False(t, ok)
+
+	c.Set("foo", "bar")
+	value, ok := c.Get("foo")
+	assert.True(t, ok)
+	assert.Equal(t, "bar", value)
+
+	now = now.Add(5 * time.Second)
+	c.Set("another", 1)
+	_, ok = c.Get("foo")
+	assert.True(t, ok)
+
+	// the record expires after the ttl
+	now = now.Add(5 * time.Second)
+	_, ok = c.Get("foo")
+	assert.False(t, ok)
+	value, ok = c.Get("another")
+	assert.True(t, ok)
+	assert.Equal(t, 1, value)
+
+	// the expired records are cleaned up when setting a new one
+	now = now.Add(5 * time.Second)
+	assert.Equal(t, 1, c.Len())
+	c.Set("foo", "baz")
+	assert.Equal(t, 1, c.Len())
+	value, ok = c.Get("foo")
+	assert.True(t, ok)
+	assert.Equal(t, "baz", value)
+}
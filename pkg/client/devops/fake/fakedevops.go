@@ -201,6 +201,9 @@ func (d *Devops) DownloadArtifact(projectName, pipelineName, runId, filename str
 func (d *Devops) GetRunLog(projectName, pipelineName, runId string, httpParameters *devops.HttpParameters) ([]byte, error) {
 	return nil, nil
 }
+func (d *Devops) GetRunLogWithHeader(projectName, pipelineName, runId string, httpParameters *devops.HttpParameters) ([]byte, http.Header, error) {
+	return nil, nil, nil
+}
 func (d *Devops) GetStepLog(projectName, pipelineName, runId, nodeId, stepId string, httpParameters *devops.HttpParameters) ([]byte, http.Header, error) {
 	return nil, nil, nil
 }
@@ -242,6 +245,9 @@ func (d *Devops) GetBranchArtifacts(projectName, pipelineName, branchName, runId
 func (d *Devops) GetBranchRunLog(projectName, pipelineName, branchName, runId string, httpParameters *devops.HttpParameters) ([]byte, error) {
 	return nil, nil
 }
+func (d *Devops) GetBranchRunLogWithHeader(projectName, pipelineName, branchName, runId string, httpParameters *devops.HttpParameters) ([]byte, http.Header, error) {
+	return nil, nil, nil
+}
 func (d *Devops) GetBranchStepLog(projectName, pipelineName, branchName, runId, nodeId, stepId string, httpParameters *devops.HttpParameters) ([]byte, http.Header, error) {
 	return nil, nil, nil
 }
@@ -197,6 +197,8 @@ func TestNotImplement(t *testing.T) {
 	assertNils(t, o1, o2)
 	o1, o2, o3 = client.GetStepLog("", "", "", "", "", nil)
 	assertNils(t, o1, o2, o3)
+	o1, o2, o3 = client.GetRunLogWithHeader("", "", "", nil)
+	assertNils(t, o1, o2, o3)
 	o1, o2 = client.RunPipeline("", "", nil)
 	assertNils(t, o1, o2)
 	o1, o2 = client.ListPipelineRuns("", "", nil)
@@ -85,6 +85,11 @@ func (j *JenkinsClient) GetRunLog(projectName, pipelineName, runID string, httpP
 	return j.jenkins.GetRunLog(projectName, pipelineName, runID, httpParameters)
 }
 
+// GetRunLogWithHeader returns the log output of a pipeline run along with the response headers
+func (j *JenkinsClient) GetRunLogWithHeader(projectName, pipelineName, runID string, httpParameters *devops.HttpParameters) ([]byte, http.Header, error) {
+	return j.jenkins.GetRunLogWithHeader(projectName, pipelineName, runID, httpParameters)
+}
+
 // GetStepLog returns the log output of a step
 func (j *JenkinsClient) GetStepLog(projectName, pipelineName, runID, nodeID, stepID string, httpParameters *devops.HttpParameters) ([]byte, http.Header, error) {
 	return j.jenkins.GetStepLog(projectName, pipelineName, runID, nodeID, stepID, httpParameters)
@@ -140,6 +145,11 @@ func (j *JenkinsClient) GetBranchRunLog(projectName, pipelineName, branchName, r
 	return j.jenkins.GetBranchRunLog(projectName, pipelineName, branchName, runID, httpParameters)
 }
 
+// GetBranchRunLogWithHeader returns the pipeline run log along with the response headers
+func (j *JenkinsClient) GetBranchRunLogWithHeader(projectName, pipelineName, branchName, runID string, httpParameters *devops.HttpParameters) ([]byte, http.Header, error) {
+	return j.jenkins.GetBranchRunLogWithHeader(projectName, pipelineName, branchName, runID, httpParameters)
+}
+
 // GetBranchStepLog returns the log output of a pipeline step
 func (j *JenkinsClient) GetBranchStepLog(projectName, pipelineName, branchName, runID, nodeID, stepID string, httpParameters *devops.HttpParameters) ([]byte, http.Header, error) {
 	return j.jenkins.GetBranchStepLog(projectName, pipelineName, branchName, runID, nodeID, stepID, httpParameters)
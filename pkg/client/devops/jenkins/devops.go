@@ -15,11 +15,17 @@ package jenkins
 
 import (
 	"net/http"
+
+	"kubesphere.io/devops/pkg/metrics"
 )
 
+// transport records the metrics of all the requests to Jenkins
+var transport = metrics.InstrumentJenkinsTransport(http.DefaultTransport)
+
 func NewDevopsClient(options *Options) (*Jenkins, error) {
 	// we have to create http client with no redirection
 	client := &http.Client{
+		Transport: transport,
 		CheckRedirect: func(req *http.Request, via []*http.Request) error {
 			return http.ErrUseLastResponse
 		},
@@ -29,6 +29,7 @@ func TestCommonSituation(t *testing.T) {
 	AppendGitlabSourceToEtree(nil, nil)
 	AppendGithubSourceToEtree(nil, nil)
 	AppendBitbucketServerSourceToEtree(nil, nil)
+	AppendGiteaSourceToEtree(nil, nil)
 	AppendGitSourceToEtree(nil, nil)
 	AppendSingleSvnSourceToEtree(nil, nil)
 	AppendSvnSourceToEtree(nil, nil)
@@ -55,4 +55,15 @@ func TestPRDiscoverTrust(t *testing.T) {
 	assert.Equal(t, BitbucketPRDiscoverTrust(1).ParseFromString("TrustNobody"), BitbucketPRDiscoverTrustNobody)
 	assert.Equal(t, BitbucketPRDiscoverTrust(1).ParseFromString("fake"), BitbucketPRDiscoverTrustEveryone)
 	assert.Equal(t, BitbucketPRDiscoverTrust(1).ParseFromString("TrustNobody").IsValid(), true)
+
+	// Gitea
+	assert.Equal(t, GiteaPRDiscoverTrust(1).String(), "TrustContributors")
+	assert.Equal(t, GiteaPRDiscoverTrust(2).String(), "TrustEveryone")
+	assert.Equal(t, GiteaPRDiscoverTrust(4).String(), "TrustNobody")
+	assert.Equal(t, GiteaPRDiscoverTrust(3).IsValid(), false)
+	assert.Equal(t, GiteaPRDiscoverTrust(4).Value(), 4)
+	assert.Equal(t, GiteaPRDiscoverTrust(1).ParseFromString("TrustContributors"), GiteaPRDiscoverTrustContributors)
+	assert.Equal(t, GiteaPRDiscoverTrust(1).ParseFromString("TrustEveryone"), GiteaPRDiscoverTrustEveryone)
+	assert.Equal(t, GiteaPRDiscoverTrust(1).ParseFromString("TrustNobody"), GiteaPRDiscoverTrustNobody)
+	assert.Equal(t, GiteaPRDiscoverTrust(1).ParseFromString("TrustMembers").IsValid(), false)
 }
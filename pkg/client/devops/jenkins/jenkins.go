@@ -318,6 +318,16 @@ func (j *Jenkins) GetRunLog(projectName, pipelineName, runId string, httpParamet
 	return res, err
 }
 
+// GetRunLogWithHeader returns the run log along with the response headers
+func (j *Jenkins) GetRunLogWithHeader(projectName, pipelineName, runId string, httpParameters *devops.HttpParameters) ([]byte, http.Header, error) {
+	PipelineOjb := &Pipeline{
+		HttpParameters: httpParameters,
+		Jenkins:        j,
+		Path:           fmt.Sprintf(GetRunLogUrl+httpParameters.Url.RawQuery, projectName, pipelineName, runId),
+	}
+	return PipelineOjb.GetRunLogWithHeader()
+}
+
 func (j *Jenkins) GetStepLog(projectName, pipelineName, runId, nodeId, stepId string, httpParameters *devops.HttpParameters) ([]byte, http.Header, error) {
 	PipelineOjb := &Pipeline{
 		HttpParameters: httpParameters,
@@ -428,6 +438,16 @@ func (j *Jenkins) GetBranchRunLog(projectName, pipelineName, branchName, runId s
 	return res, err
 }
 
+// GetBranchRunLogWithHeader returns the run log of a branch along with the response headers
+func (j *Jenkins) GetBranchRunLogWithHeader(projectName, pipelineName, branchName, runId string, httpParameters *devops.HttpParameters) ([]byte, http.Header, error) {
+	PipelineOjb := &Pipeline{
+		HttpParameters: httpParameters,
+		Jenkins:        j,
+		Path:           fmt.Sprintf(GetBranchRunLogUrl+httpParameters.Url.RawQuery, projectName, pipelineName, branchName, runId),
+	}
+	return PipelineOjb.GetRunLogWithHeader()
+}
+
 func (j *Jenkins) GetBranchStepLog(projectName, pipelineName, branchName, runId, nodeId, stepId string, httpParameters *devops.HttpParameters) ([]byte, http.Header, error) {
 	PipelineOjb := &Pipeline{
 		HttpParameters: httpParameters,
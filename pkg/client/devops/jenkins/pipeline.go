@@ -383,6 +383,16 @@ func (p *Pipeline) GetRunLog() ([]byte, error) {
 	return res, err
 }
 
+// GetRunLogWithHeader returns the log of a run or a branch run along with the response headers
+func (p *Pipeline) GetRunLogWithHeader() ([]byte, http.Header, error) {
+	res, header, err := p.Jenkins.SendPureRequestWithHeaderResp(p.Path, p.HttpParameters)
+	if err != nil {
+		klog.Error(err)
+	}
+
+	return res, header, err
+}
+
 func (p *Pipeline) GetStepLog() ([]byte, http.Header, error) {
 	res, header, err := p.Jenkins.SendPureRequestWithHeaderResp(p.Path, p.HttpParameters)
 	if err != nil {
@@ -787,7 +797,7 @@ func (p *Pipeline) CheckCron() (*devops.CheckCronRes, error) {
 		reqJenkins.URL = cronServiceURL
 	}
 
-	client := &http.Client{Timeout: 30 * time.Second}
+	client := &http.Client{Timeout: 30 * time.Second, Transport: transport}
 	reqJenkins.SetBasicAuth(p.Jenkins.Requester.BasicAuth.Username, p.Jenkins.Requester.BasicAuth.Password)
 	resp, err := client.Do(reqJenkins)
 	if err != nil {
@@ -45,7 +45,7 @@ func (j *Jenkins) SendPureRequestWithHeaderResp(path string, httpParameters *dev
 	}
 
 	apiURL.RawQuery = httpParameters.Url.RawQuery
-	client := &http.Client{Timeout: 30 * time.Second}
+	client := &http.Client{Timeout: 30 * time.Second, Transport: transport}
 
 	header := httpParameters.Header.Clone()
 	if header == nil {
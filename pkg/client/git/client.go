@@ -48,6 +48,11 @@ func NewClientFactory(provider string, secretRef *v1.SecretReference, k8sClient
 
 // GetClient returns the git client with auth
 func (c *ClientFactory) GetClient() (client *goscm.Client, err error) {
+	return c.GetClientWithContext(context.TODO())
+}
+
+// GetClientWithContext returns the git client with auth, the secret is read within the given context
+func (c *ClientFactory) GetClientWithContext(ctx context.Context) (client *goscm.Client, err error) {
 	provider := c.provider
 	switch c.provider {
 	case "bitbucket_cloud":
@@ -63,7 +68,7 @@ func (c *ClientFactory) GetClient() (client *goscm.Client, err error) {
 	var token string
 	username := ""
 	if c.secretRef != nil {
-		if token, username, err = c.getTokenFromSecret(c.secretRef); err != nil {
+		if token, username, err = c.getTokenFromSecret(ctx, c.secretRef); err != nil {
 			return
 		}
 	}
@@ -73,9 +78,9 @@ func (c *ClientFactory) GetClient() (client *goscm.Client, err error) {
 	return
 }
 
-func (c *ClientFactory) getTokenFromSecret(secretRef *v1.SecretReference) (token, username string, err error) {
+func (c *ClientFactory) getTokenFromSecret(ctx context.Context, secretRef *v1.SecretReference) (token, username string, err error) {
 	var gitSecret *v1.Secret
-	if gitSecret, err = c.getSecret(secretRef); err != nil {
+	if gitSecret, err = c.getSecret(ctx, secretRef); err != nil {
 		return
 	}
 
@@ -92,11 +97,11 @@ func (c *ClientFactory) getTokenFromSecret(secretRef *v1.SecretReference) (token
 }
 
 // getSecret returns the secret, taking the namespace from GitRepository if it is empty
-func (c *ClientFactory) getSecret(ref *v1.SecretReference) (secret *v1.Secret, err error) {
+func (c *ClientFactory) getSecret(ctx context.Context, ref *v1.SecretReference) (secret *v1.Secret, err error) {
 	secret = &v1.Secret{}
 	ns := ref.Namespace
 
-	if err = c.k8sClient.Get(context.TODO(), types.NamespacedName{
+	if err = c.k8sClient.Get(ctx, types.NamespacedName{
 		Namespace: ns, Name: ref.Name,
 	}, secret); err != nil {
 		err = fmt.Errorf("cannot get secret %v, error is: %v", secret, err)
@@ -0,0 +1,201 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package k8s
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/client-go/rest"
+	"kubesphere.io/devops/pkg/apiserver/authentication/request/anonymous"
+	"kubesphere.io/devops/pkg/apiserver/request"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// maxImpersonatingClients limits the number of the cached clients, all of them will be dropped once it's reached
+const maxImpersonatingClients = 1024
+
+// ErrNoUser indicates that there is no request user in the context to impersonate
+var ErrNoUser = errors.New("no user found in the context to impersonate")
+
+// impersonatingClient sends the requests as the user of the context via the impersonation of Kubernetes.
+// Then the access of a request is limited by the permissions of its user instead of the ones of the server.
+type impersonatingClient struct {
+	config    *rest.Config
+	options   client.Options
+	newClient func(config *rest.Config, options client.Options) (client.Client, error)
+
+	mutex   sync.Mutex
+	clients map[string]client.Client
+}
+
+var _ client.Client = &impersonatingClient{}
+
+// NewImpersonatingClient creates a client which impersonates the user of the context in every call.
+// A call without the request context fails with ErrNoUser instead of using the identity of the server.
+func NewImpersonatingClient(config *rest.Config, options client.Options) client.Client {
+	return &impersonatingClient{
+		config:    config,
+		options:   options,
+		newClient: client.New,
+		clients:   map[string]client.Client{},
+	}
+}
+
+// getClient returns the client of the context user, it's created once per user
+func (c *impersonatingClient) getClient(ctx context.Context) (client.Client, error) {
+	info, ok := request.UserFrom(ctx)
+	if !ok || info == nil {
+		return nil, ErrNoUser
+	}
+	info = anonymous.KubernetesUser(info)
+	key := getUserKey(info)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if cli, ok := c.clients[key]; ok {
+		return cli, nil
+	}
+
+	config := rest.CopyConfig(c.config)
+	config.Impersonate = rest.ImpersonationConfig{
+		UserName: info.GetName(),
+		UID:      info.GetUID(),
+		Groups:   info.GetGroups(),
+		Extra:    info.GetExtra(),
+	}
+	cli, err := c.newClient(config, c.options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the client to impersonate %q, error: %v", info.GetName(), err)
+	}
+	if len(c.clients) >= maxImpersonatingClients {
+		c.clients = map[string]client.Client{}
+	}
+	c.clients[key] = cli
+	return cli, nil
+}
+
+// getUserKey returns a key which is unique for all the attributes of a user
+func getUserKey(info user.Info) string {
+	parts := []string{info.GetName(), info.GetUID(), strings.Join(info.GetGroups(), ",")}
+	extra := info.GetExtra()
+	keys := make([]string, 0, len(extra))
+	for key := range extra {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		parts = append(parts, key+"="+strings.Join(extra[key], ","))
+	}
+	return fmt.Sprintf("%q", parts)
+}
+
+func (c *impersonatingClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+	cli, err := c.getClient(ctx)
+	if err != nil {
+		return err
+	}
+	return cli.Get(ctx, key, obj)
+}
+
+func (c *impersonatingClient) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	cli, err := c.getClient(ctx)
+	if err != nil {
+		return err
+	}
+	return cli.List(ctx, list, opts...)
+}
+
+func (c *impersonatingClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	cli, err := c.getClient(ctx)
+	if err != nil {
+		return err
+	}
+	return cli.Create(ctx, obj, opts...)
+}
+
+func (c *impersonatingClient) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	cli, err := c.getClient(ctx)
+	if err != nil {
+		return err
+	}
+	return cli.Delete(ctx, obj, opts...)
+}
+
+func (c *impersonatingClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	cli, err := c.getClient(ctx)
+	if err != nil {
+		return err
+	}
+	return cli.Update(ctx, obj, opts...)
+}
+
+func (c *impersonatingClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	cli, err := c.getClient(ctx)
+	if err != nil {
+		return err
+	}
+	return cli.Patch(ctx, obj, patch, opts...)
+}
+
+func (c *impersonatingClient) DeleteAllOf(ctx context.Context, obj client.Object, opts ...client.DeleteAllOfOption) error {
+	cli, err := c.getClient(ctx)
+	if err != nil {
+		return err
+	}
+	return cli.DeleteAllOf(ctx, obj, opts...)
+}
+
+func (c *impersonatingClient) Status() client.StatusWriter {
+	return &impersonatingStatusWriter{client: c}
+}
+
+func (c *impersonatingClient) Scheme() *runtime.Scheme {
+	return c.options.Scheme
+}
+
+func (c *impersonatingClient) RESTMapper() meta.RESTMapper {
+	return c.options.Mapper
+}
+
+// impersonatingStatusWriter updates the status subresource as the user of the context
+type impersonatingStatusWriter struct {
+	client *impersonatingClient
+}
+
+func (w *impersonatingStatusWriter) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	cli, err := w.client.getClient(ctx)
+	if err != nil {
+		return err
+	}
+	return cli.Status().Update(ctx, obj, opts...)
+}
+
+func (w *impersonatingStatusWriter) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	cli, err := w.client.getClient(ctx)
+	if err != nil {
+		return err
+	}
+	return cli.Status().Patch(ctx, obj, patch, opts...)
+}
@@ -0,0 +1,89 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package k8s
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"kubesphere.io/devops/pkg/apiserver/request"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestImpersonatingClient(t *testing.T) {
+	secret := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "secret"}}
+	var impersonated []rest.ImpersonationConfig
+	cli := NewImpersonatingClient(&rest.Config{Host: "https://fake.com"}, client.Options{Scheme: scheme.Scheme})
+	cli.(*impersonatingClient).newClient = func(config *rest.Config, options client.Options) (client.Client, error) {
+		impersonated = append(impersonated, config.Impersonate)
+		return fake.NewClientBuilder().WithScheme(options.Scheme).WithObjects(secret.DeepCopy()).Build(), nil
+	}
+	key := types.NamespacedName{Namespace: "ns", Name: "secret"}
+
+	// no user in the context
+	err := cli.Get(context.Background(), key, &v1.Secret{})
+	assert.Equal(t, ErrNoUser, err)
+	assert.Empty(t, impersonated)
+
+	admin := request.WithUser(context.Background(), &user.DefaultInfo{
+		Name:   "admin",
+		UID:    "uid",
+		Groups: []string{"admins"},
+		Extra:  map[string][]string{"scope": {"all"}},
+	})
+	assert.Nil(t, cli.Get(admin, key, &v1.Secret{}))
+	assert.Nil(t, cli.List(admin, &v1.SecretList{}))
+	assert.Equal(t, []rest.ImpersonationConfig{{
+		UserName: "admin",
+		UID:      "uid",
+		Groups:   []string{"admins"},
+		Extra:    map[string][]string{"scope": {"all"}},
+	}}, impersonated, "the client of a user should be reused")
+
+	// the anonymous user is impersonated as system:anonymous
+	anonymous := request.WithUser(context.Background(), &user.DefaultInfo{
+		Name:   "anonymous",
+		Groups: []string{user.AllAuthenticated},
+	})
+	assert.Nil(t, cli.Delete(anonymous, secret.DeepCopy()))
+	assert.Equal(t, rest.ImpersonationConfig{
+		UserName: user.Anonymous,
+		Groups:   []string{user.AllUnauthenticated},
+	}, impersonated[1])
+
+	// the status writer impersonates as well
+	err = cli.Status().Update(context.Background(), secret.DeepCopy())
+	assert.Equal(t, ErrNoUser, err)
+
+	assert.Equal(t, scheme.Scheme, cli.Scheme())
+}
+
+func TestGetUserKey(t *testing.T) {
+	key := getUserKey(&user.DefaultInfo{Name: "admin", Extra: map[string][]string{"a": {"1"}, "b": {"2"}}})
+	assert.Equal(t, key, getUserKey(&user.DefaultInfo{Name: "admin", Extra: map[string][]string{"b": {"2"}, "a": {"1"}}}))
+	assert.NotEqual(t, key, getUserKey(&user.DefaultInfo{Name: "admin"}))
+	assert.NotEqual(t, getUserKey(&user.DefaultInfo{Name: "admin", Groups: []string{"a"}}),
+		getUserKey(&user.DefaultInfo{Name: "admin", Groups: []string{"b"}}))
+}
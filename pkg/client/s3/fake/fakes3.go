@@ -17,9 +17,12 @@ limitations under the License.
 package fake
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"sort"
+	"strings"
 
 	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/s3"
@@ -64,12 +67,24 @@ func (s *FakeS3) Delete(key string) error {
 	return nil
 }
 
+func (s *FakeS3) List(prefix string) (keys []string, err error) {
+	for key := range s.Storage {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return
+}
+
 func (s *FakeS3) Read(key string) ([]byte, error) {
 	if o, ok := s.Storage[key]; ok && o.Body != nil {
 		data, err := ioutil.ReadAll(o.Body)
 		if err != nil {
 			return nil, err
 		}
+		// keep the object readable for the next time
+		o.Body = bytes.NewReader(data)
 		return data, nil
 	}
 	return nil, awserr.New(s3.ErrCodeNoSuchKey, "no such object", nil)
@@ -88,6 +88,19 @@ func (s *Client) Delete(key string) error {
 	return nil
 }
 
+func (s *Client) List(prefix string) (keys []string, err error) {
+	err = s.s3Client.ListObjectsV2Pages(&s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(prefix),
+	}, func(page *s3.ListObjectsV2Output, _ bool) bool {
+		for _, object := range page.Contents {
+			keys = append(keys, aws.StringValue(object.Key))
+		}
+		return true
+	})
+	return
+}
+
 func NewS3Client(options *Options) (Interface, error) {
 	cred := credentials.NewStaticCredentials(options.AccessKeyID, options.SecretAccessKey, options.SessionToken)
 
@@ -69,14 +69,54 @@ const (
 )
 
 // AuthMode is the auth mode of current project
-// TODO add a validation method to verify all the supported values
 type AuthMode string
 
 var (
-	// AuthModeToken let it use the token directly
+	// AuthModeToken verifies the token with the JWT secret or the JWKS of the authentication options
 	AuthModeToken AuthMode = "token"
+	// AuthModeTokenReview delegates the authentication to Kubernetes via TokenReview
+	AuthModeTokenReview AuthMode = "tokenreview"
+	// AuthModeImpersonate authenticates via TokenReview, authorizes every request via SubjectAccessReview,
+	// then the handlers access Kubernetes as the request user via the impersonation
+	AuthModeImpersonate AuthMode = "impersonate"
+	// AuthModeOIDC authenticates the users with the ID tokens of an OpenID Connect provider,
+	// and issues the DevOps tokens in exchange for the ID tokens
+	AuthModeOIDC AuthMode = "oidc"
 )
 
+// ValidateAuthMode checks if the auth mode is supported, and its options are provided
+func (c *Config) ValidateAuthMode() (errs []error) {
+	switch c.AuthMode {
+	case AuthModeToken:
+		if c.AuthenticationOptions == nil {
+			errs = append(errs, fmt.Errorf("the authentication options are required by the auth mode %s", c.AuthMode))
+		} else {
+			errs = append(errs, c.AuthenticationOptions.Validate()...)
+		}
+	case AuthModeOIDC:
+		if c.AuthenticationOptions == nil {
+			errs = append(errs, fmt.Errorf("the authentication options are required by the auth mode %s", c.AuthMode))
+			break
+		}
+		errs = append(errs, c.AuthenticationOptions.Validate()...)
+		if c.AuthenticationOptions.JwtSecret == "" {
+			errs = append(errs, fmt.Errorf("the JWT secret is required to issue tokens in the auth mode %s", c.AuthMode))
+		}
+		if c.AuthenticationOptions.OAuthOptions == nil {
+			errs = append(errs, fmt.Errorf("the OAuth options are required to issue tokens in the auth mode %s", c.AuthMode))
+		}
+		if c.AuthenticationOptions.OIDC == nil {
+			errs = append(errs, fmt.Errorf("the OIDC options are required by the auth mode %s", c.AuthMode))
+		} else {
+			errs = append(errs, c.AuthenticationOptions.OIDC.Validate()...)
+		}
+	case AuthModeTokenReview, AuthModeImpersonate:
+	default:
+		errs = append(errs, fmt.Errorf("unsupported auth mode: %s", c.AuthMode))
+	}
+	return
+}
+
 // Config defines everything needed for apiserver to deal with external services
 type Config struct {
 	JenkinsOptions        *jenkins.Options                   `json:"devops,omitempty" yaml:"devops,omitempty" mapstructure:"devops"`
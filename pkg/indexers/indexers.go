@@ -20,7 +20,9 @@ import (
 	"context"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
+	"k8s.io/apimachinery/pkg/util/sets"
 	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
+	"kubesphere.io/devops/pkg/utils/gitutil"
 	"sigs.k8s.io/controller-runtime/pkg/cache"
 )
 
@@ -59,3 +61,62 @@ func extractPipelineRunIdentifier(o client.Object) []string {
 	}
 	return []string{pipelineRun.GetPipelineRunIdentifier()}
 }
+
+// CreatePipelineGitURLIndexer creates an indexer which could speed up locating the Pipelines by the normalized git URL.
+// It's used when receiving the SCM webhook deliveries.
+func CreatePipelineGitURLIndexer(runtimeCache cache.Cache) error {
+	return runtimeCache.IndexField(context.Background(),
+		&v1alpha3.Pipeline{},
+		v1alpha3.PipelineGitURLIndexerName,
+		extractPipelineGitURLs)
+}
+
+func extractPipelineGitURLs(o client.Object) []string {
+	pipeline, ok := o.(*v1alpha3.Pipeline)
+	if !ok || pipeline == nil {
+		return []string{}
+	}
+	urls := sets.NewString()
+	for _, gitURL := range pipeline.GetGitURLs() {
+		if normalized := gitutil.NormalizeURL(gitURL); normalized != "" {
+			urls.Insert(normalized)
+		}
+	}
+	return urls.List()
+}
+
+// CreatePipelineUpstreamIndexer creates an indexer which could speed up locating the Pipelines by their upstream
+// Pipelines. It's used when an upstream PipelineRun completed.
+func CreatePipelineUpstreamIndexer(runtimeCache cache.Cache) error {
+	return runtimeCache.IndexField(context.Background(),
+		&v1alpha3.Pipeline{},
+		v1alpha3.PipelineUpstreamIndexerName,
+		extractPipelineUpstreams)
+}
+
+func extractPipelineUpstreams(o client.Object) []string {
+	pipeline, ok := o.(*v1alpha3.Pipeline)
+	if !ok || pipeline == nil {
+		return []string{}
+	}
+	return pipeline.GetUpstreamPipelines()
+}
+
+// CreateGitRepositoryURLIndexer creates an indexer which could speed up locating the GitRepositories by the normalized URL.
+func CreateGitRepositoryURLIndexer(runtimeCache cache.Cache) error {
+	return runtimeCache.IndexField(context.Background(),
+		&v1alpha3.GitRepository{},
+		v1alpha3.GitRepositoryURLIndexerName,
+		extractGitRepositoryURL)
+}
+
+func extractGitRepositoryURL(o client.Object) []string {
+	repo, ok := o.(*v1alpha3.GitRepository)
+	if !ok || repo == nil {
+		return []string{}
+	}
+	if normalized := gitutil.NormalizeURL(repo.Spec.URL); normalized != "" {
+		return []string{normalized}
+	}
+	return []string{}
+}
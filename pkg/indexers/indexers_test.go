@@ -17,6 +17,7 @@ limitations under the License.
 package indexers
 
 import (
+	"github.com/stretchr/testify/assert"
 	v1 "k8s.io/api/core/v1"
 	v12 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
@@ -150,3 +151,125 @@ func Test_extractPipelineRunIdentifier(t *testing.T) {
 		})
 	}
 }
+
+func TestCreatePipelineGitURLIndexer(t *testing.T) {
+	assert.Nil(t, CreatePipelineGitURLIndexer(&informertest.FakeInformers{}))
+}
+
+func TestCreatePipelineUpstreamIndexer(t *testing.T) {
+	assert.Nil(t, CreatePipelineUpstreamIndexer(&informertest.FakeInformers{}))
+}
+
+func TestCreateGitRepositoryURLIndexer(t *testing.T) {
+	assert.Nil(t, CreateGitRepositoryURLIndexer(&informertest.FakeInformers{}))
+}
+
+func Test_extractPipelineGitURLs(t *testing.T) {
+	tests := []struct {
+		name string
+		o    client.Object
+		want []string
+	}{{
+		name: "not expect kind",
+		o:    &v1.ConfigMap{},
+		want: []string{},
+	}, {
+		name: "no git URL",
+		o:    &v1alpha3.Pipeline{},
+		want: []string{},
+	}, {
+		name: "regular Pipeline",
+		o: &v1alpha3.Pipeline{
+			ObjectMeta: v12.ObjectMeta{
+				Annotations: map[string]string{
+					v1alpha3.PipelineSCMURLAnnoKey: "https://GitHub.com/linuxsuren/tools.git",
+				},
+			},
+		},
+		want: []string{"github.com/linuxsuren/tools"},
+	}, {
+		name: "multi-branch Pipeline",
+		o: &v1alpha3.Pipeline{
+			Spec: v1alpha3.PipelineSpec{
+				Type: v1alpha3.MultiBranchPipelineType,
+				MultiBranchPipeline: &v1alpha3.MultiBranchPipeline{
+					SourceType:   v1alpha3.SourceTypeGithub,
+					GitHubSource: &v1alpha3.GithubSource{Owner: "linuxsuren", Repo: "tools"},
+				},
+			},
+		},
+		want: []string{"github.com/linuxsuren/tools"},
+	}, {
+		name: "multi-branch Pipeline without the details",
+		o: &v1alpha3.Pipeline{
+			Spec: v1alpha3.PipelineSpec{Type: v1alpha3.MultiBranchPipelineType},
+		},
+		want: []string{},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, extractPipelineGitURLs(tt.o))
+		})
+	}
+}
+
+func Test_extractPipelineUpstreams(t *testing.T) {
+	tests := []struct {
+		name string
+		o    client.Object
+		want []string
+	}{{
+		name: "not expect kind",
+		o:    &v1.ConfigMap{},
+		want: []string{},
+	}, {
+		name: "no upstream triggers",
+		o:    &v1alpha3.Pipeline{},
+		want: []string{},
+	}, {
+		name: "upstream Pipelines in the same and other namespaces",
+		o: &v1alpha3.Pipeline{
+			ObjectMeta: v12.ObjectMeta{Namespace: "deploy", Name: "release"},
+			Spec: v1alpha3.PipelineSpec{
+				UpstreamTriggers: []v1alpha3.UpstreamTrigger{
+					{Pipeline: "test"},
+					{Namespace: "build", Pipeline: "build"},
+					{Namespace: "deploy", Pipeline: "test", Phases: []v1alpha3.RunPhase{v1alpha3.Failed}},
+				},
+			},
+		},
+		want: []string{"build/build", "deploy/test"},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, extractPipelineUpstreams(tt.o))
+		})
+	}
+}
+
+func Test_extractGitRepositoryURL(t *testing.T) {
+	tests := []struct {
+		name string
+		o    client.Object
+		want []string
+	}{{
+		name: "not expect kind",
+		o:    &v1.ConfigMap{},
+		want: []string{},
+	}, {
+		name: "empty URL",
+		o:    &v1alpha3.GitRepository{},
+		want: []string{},
+	}, {
+		name: "normal",
+		o: &v1alpha3.GitRepository{
+			Spec: v1alpha3.GitRepositorySpec{URL: "git@github.com:LinuxSuRen/tools.git"},
+		},
+		want: []string{"github.com/linuxsuren/tools"},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, extractGitRepositoryURL(tt.o))
+		})
+	}
+}
@@ -26,10 +26,18 @@ const (
 	AccessToken  TokenType = "access_token"
 	RefreshToken TokenType = "refresh_token"
 	StaticToken  TokenType = "static_token"
+	// ExternalToken is a token which was issued by a third party, such as an OIDC provider
+	ExternalToken TokenType = "external_token"
 )
 
 type TokenType string
 
+// GrantsAccess tells if the tokens of the type are able to access the APIs.
+// The refresh tokens are not, they are only able to be exchanged for new tokens.
+func (t TokenType) GrantsAccess() bool {
+	return t == AccessToken || t == StaticToken || t == ExternalToken
+}
+
 // Issuer issues token to user, tokens are required to perform mutating requests to resources
 type Issuer interface {
 	// IssueTo issues a token a User, return error if issuing process failed
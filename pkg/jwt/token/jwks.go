@@ -0,0 +1,209 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package token
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// KeySet provides the public keys to verify the tokens which were signed by a third party, such as an OIDC provider
+type KeySet interface {
+	// GetKey returns the public key of a key ID, the key ID could be empty if there is only one key
+	GetKey(kid string) (interface{}, error)
+}
+
+// minRefreshInterval limits the frequency of fetching the keys, unknown key IDs should not flood the provider
+const minRefreshInterval = time.Minute
+
+// remoteKeySet fetches the keys from a JSON Web Key Set (JWKS) URL.
+// The keys are refreshed once an unknown key ID comes, because the provider might rotate its keys.
+type remoteKeySet struct {
+	url         string
+	client      *http.Client
+	mutex       sync.Mutex
+	keys        map[string]interface{}
+	lastFetched time.Time
+}
+
+// NewRemoteKeySet creates a KeySet which fetches the keys from a JWKS URL
+func NewRemoteKeySet(url string) KeySet {
+	return &remoteKeySet{
+		url:    url,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (s *remoteKeySet) GetKey(kid string) (key interface{}, err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if key = s.findKey(kid); key != nil {
+		return
+	}
+	if time.Since(s.lastFetched) < minRefreshInterval {
+		err = fmt.Errorf("key '%s' not found from %s", kid, s.url)
+		return
+	}
+
+	if err = s.refresh(); err != nil {
+		return
+	}
+	if key = s.findKey(kid); key == nil {
+		err = fmt.Errorf("key '%s' not found from %s", kid, s.url)
+	}
+	return
+}
+
+func (s *remoteKeySet) findKey(kid string) interface{} {
+	if kid == "" && len(s.keys) == 1 {
+		for _, key := range s.keys {
+			return key
+		}
+	}
+	return s.keys[kid]
+}
+
+func (s *remoteKeySet) refresh() (err error) {
+	// record the time even if it failed, then the failures will not flood the provider
+	s.lastFetched = time.Now()
+
+	var resp *http.Response
+	if resp, err = s.client.Get(s.url); err != nil {
+		return
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to fetch the keys from %s, status code: %d", s.url, resp.StatusCode)
+	}
+
+	var data []byte
+	if data, err = io.ReadAll(resp.Body); err == nil {
+		s.keys, err = parseJWKS(data)
+	}
+	return
+}
+
+// jsonWebKey is a public key of a JWKS, see also https://www.rfc-editor.org/rfc/rfc7517
+type jsonWebKey struct {
+	KeyType string `json:"kty"`
+	KeyID   string `json:"kid"`
+	Use     string `json:"use"`
+	// N and E are the modulus and exponent of a RSA key
+	N string `json:"n"`
+	E string `json:"e"`
+	// Curve, X and Y are the parameters of an EC key
+	Curve string `json:"crv"`
+	X     string `json:"x"`
+	Y     string `json:"y"`
+}
+
+// parseJWKS parses the signing keys of a JWKS, the unsupported keys will be ignored
+func parseJWKS(data []byte) (keys map[string]interface{}, err error) {
+	jwks := struct {
+		Keys []jsonWebKey `json:"keys"`
+	}{}
+	if err = json.Unmarshal(data, &jwks); err != nil {
+		return
+	}
+
+	keys = map[string]interface{}{}
+	for _, jwk := range jwks.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+
+		var key interface{}
+		switch jwk.KeyType {
+		case "RSA":
+			key, err = parseRSAKey(jwk)
+		case "EC":
+			key, err = parseECKey(jwk)
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid key '%s', error: %v", jwk.KeyID, err)
+		}
+		keys[jwk.KeyID] = key
+	}
+	return
+}
+
+func parseRSAKey(jwk jsonWebKey) (key *rsa.PublicKey, err error) {
+	var n, e *big.Int
+	if n, err = decodeBigInt(jwk.N); err != nil {
+		return
+	}
+	if e, err = decodeBigInt(jwk.E); err != nil {
+		return
+	}
+	if !e.IsInt64() {
+		return nil, fmt.Errorf("the exponent is too large")
+	}
+	key = &rsa.PublicKey{N: n, E: int(e.Int64())}
+	return
+}
+
+func parseECKey(jwk jsonWebKey) (key *ecdsa.PublicKey, err error) {
+	var curve elliptic.Curve
+	switch jwk.Curve {
+	case "P-256":
+		curve = elliptic.P256()
+	case "P-384":
+		curve = elliptic.P384()
+	case "P-521":
+		curve = elliptic.P521()
+	default:
+		return nil, fmt.Errorf("unsupported curve: %s", jwk.Curve)
+	}
+
+	var x, y *big.Int
+	if x, err = decodeBigInt(jwk.X); err != nil {
+		return
+	}
+	if y, err = decodeBigInt(jwk.Y); err != nil {
+		return
+	}
+	if !curve.IsOnCurve(x, y) {
+		return nil, fmt.Errorf("the point is not on the curve %s", jwk.Curve)
+	}
+	key = &ecdsa.PublicKey{Curve: curve, X: x, Y: y}
+	return
+}
+
+func decodeBigInt(val string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(val)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("empty value")
+	}
+	return new(big.Int).SetBytes(data), nil
+}
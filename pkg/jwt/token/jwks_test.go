@@ -0,0 +1,277 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package token
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/form3tech-oss/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apiserver/pkg/authentication/user"
+)
+
+func TestRemoteKeySet(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+
+	encode := func(val *big.Int) string {
+		return base64.RawURLEncoding.EncodeToString(val.Bytes())
+	}
+	jwks := map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA", "kid": "rsa", "use": "sig",
+			"n": encode(rsaKey.N), "e": encode(big.NewInt(int64(rsaKey.E))),
+		}, {
+			"kty": "EC", "kid": "ec", "crv": "P-256",
+			"x": encode(ecKey.X), "y": encode(ecKey.Y),
+		}, {
+			// the keys for encryption and the unknown keys are ignored
+			"kty": "RSA", "kid": "enc", "use": "enc",
+		}, {
+			"kty": "oct", "kid": "oct",
+		}},
+	}
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		_ = json.NewEncoder(w).Encode(jwks)
+	}))
+	defer server.Close()
+
+	issuer := NewTokenIssuerWithExternal("", &ExternalIssuer{
+		KeySet:   NewRemoteKeySet(server.URL),
+		Issuer:   "https://idp.example.com",
+		Audience: "devops",
+	}, 0)
+	skewIssuer := NewTokenIssuerWithExternal("", &ExternalIssuer{
+		KeySet:   NewRemoteKeySet(server.URL),
+		Issuer:   "https://idp.example.com",
+		Audience: "devops",
+	}, 2*time.Minute)
+	sign := func(method jwt.SigningMethod, kid string, key interface{}, claims jwt.MapClaims) string {
+		// the tokens are issued for the expected audience, and expire in a minute by default
+		if _, ok := claims["exp"]; !ok {
+			claims["exp"] = time.Now().Add(time.Minute).Unix()
+		}
+		if _, ok := claims["iss"]; !ok {
+			claims["iss"] = "https://idp.example.com"
+		}
+		if _, ok := claims["aud"]; !ok {
+			claims["aud"] = "devops"
+		}
+		token := jwt.NewWithClaims(method, claims)
+		if kid != "" {
+			token.Header["kid"] = kid
+		}
+		tokenString, err := token.SignedString(key)
+		assert.Nil(t, err)
+		return tokenString
+	}
+	hmacIssuer := NewTokenIssuer("secret", 0)
+	hmacToken, err := hmacIssuer.IssueTo(&user.DefaultInfo{Name: "admin"}, AccessToken, time.Minute)
+	assert.Nil(t, err)
+
+	tests := []struct {
+		name      string
+		issuer    Issuer
+		token     string
+		wantUser  string
+		wantGroup string
+		wantType  TokenType
+		wantErr   bool
+	}{{
+		name:      "signed with a RSA key",
+		issuer:    issuer,
+		token:     sign(jwt.SigningMethodRS256, "rsa", rsaKey, jwt.MapClaims{"sub": "rick", "groups": []string{"dev"}}),
+		wantUser:  "rick",
+		wantGroup: "dev",
+		wantType:  ExternalToken,
+	}, {
+		name:     "signed with an EC key",
+		issuer:   issuer,
+		token:    sign(jwt.SigningMethodES256, "ec", ecKey, jwt.MapClaims{"username": "rick"}),
+		wantUser: "rick",
+		wantType: ExternalToken,
+	}, {
+		name:     "the token type claim is ignored",
+		issuer:   issuer,
+		token:    sign(jwt.SigningMethodRS256, "rsa", rsaKey, jwt.MapClaims{"sub": "rick", "token_type": StaticToken}),
+		wantUser: "rick",
+		wantType: ExternalToken,
+	}, {
+		name:    "expired",
+		issuer:  issuer,
+		token:   sign(jwt.SigningMethodRS256, "rsa", rsaKey, jwt.MapClaims{"sub": "rick", "exp": time.Now().Add(-time.Minute).Unix()}),
+		wantErr: true,
+	}, {
+		name:     "expired, but within the clock skew",
+		issuer:   skewIssuer,
+		token:    sign(jwt.SigningMethodRS256, "rsa", rsaKey, jwt.MapClaims{"sub": "rick", "exp": time.Now().Add(-time.Minute).Unix()}),
+		wantUser: "rick",
+		wantType: ExternalToken,
+	}, {
+		name:    "not valid yet",
+		issuer:  issuer,
+		token:   sign(jwt.SigningMethodRS256, "rsa", rsaKey, jwt.MapClaims{"sub": "rick", "nbf": time.Now().Add(time.Minute).Unix()}),
+		wantErr: true,
+	}, {
+		name:    "no expiration",
+		issuer:  issuer,
+		token:   sign(jwt.SigningMethodRS256, "rsa", rsaKey, jwt.MapClaims{"sub": "rick", "exp": nil}),
+		wantErr: true,
+	}, {
+		name:    "unknown key ID",
+		issuer:  issuer,
+		token:   sign(jwt.SigningMethodRS256, "fake", rsaKey, jwt.MapClaims{"sub": "rick"}),
+		wantErr: true,
+	}, {
+		name:    "signed with another key",
+		issuer:  issuer,
+		token:   sign(jwt.SigningMethodES256, "ec", mustGenerateECKey(t), jwt.MapClaims{"sub": "rick"}),
+		wantErr: true,
+	}, {
+		name:     "one of the audiences",
+		issuer:   issuer,
+		token:    sign(jwt.SigningMethodRS256, "rsa", rsaKey, jwt.MapClaims{"sub": "rick", "aud": []string{"other", "devops"}}),
+		wantUser: "rick",
+		wantType: ExternalToken,
+	}, {
+		name:    "issued by another issuer",
+		issuer:  issuer,
+		token:   sign(jwt.SigningMethodRS256, "rsa", rsaKey, jwt.MapClaims{"sub": "rick", "iss": "https://fake.example.com"}),
+		wantErr: true,
+	}, {
+		name:    "issued for another client",
+		issuer:  issuer,
+		token:   sign(jwt.SigningMethodRS256, "rsa", rsaKey, jwt.MapClaims{"sub": "rick", "groups": []string{"admin"}, "aud": "other"}),
+		wantErr: true,
+	}, {
+		name:    "no audience",
+		issuer:  issuer,
+		token:   sign(jwt.SigningMethodRS256, "rsa", rsaKey, jwt.MapClaims{"sub": "rick", "aud": ""}),
+		wantErr: true,
+	}, {
+		name:    "no username",
+		issuer:  issuer,
+		token:   sign(jwt.SigningMethodRS256, "rsa", rsaKey, jwt.MapClaims{}),
+		wantErr: true,
+	}, {
+		name:    "signed with HMAC, but there is no secret",
+		issuer:  issuer,
+		token:   sign(jwt.SigningMethodHS256, "", []byte(""), jwt.MapClaims{"username": "admin"}),
+		wantErr: true,
+	}, {
+		name:     "signed with HMAC",
+		issuer:   hmacIssuer,
+		token:    hmacToken,
+		wantUser: "admin",
+		wantType: AccessToken,
+	}, {
+		name:    "signed with RSA, but there is no key set",
+		issuer:  hmacIssuer,
+		token:   sign(jwt.SigningMethodRS256, "rsa", rsaKey, jwt.MapClaims{"sub": "rick"}),
+		wantErr: true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userInfo, tokenType, err := tt.issuer.Verify(tt.token)
+			if tt.wantErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.wantUser, userInfo.GetName())
+			assert.Equal(t, tt.wantType, tokenType)
+			if tt.wantGroup != "" {
+				assert.Equal(t, []string{tt.wantGroup}, userInfo.GetGroups())
+			}
+		})
+	}
+	// the unknown key IDs don't flood the provider
+	assert.Equal(t, 2, requests)
+}
+
+func TestRemoteKeySet_error(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	_, err := NewRemoteKeySet(server.URL).GetKey("")
+	assert.NotNil(t, err)
+}
+
+func Test_parseJWKS(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		wantKeys int
+		wantErr  bool
+	}{{
+		name:    "invalid JSON",
+		data:    "{",
+		wantErr: true,
+	}, {
+		name: "no keys",
+		data: "{}",
+	}, {
+		name:    "invalid RSA key",
+		data:    `{"keys": [{"kty": "RSA", "n": "!", "e": "AQAB"}]}`,
+		wantErr: true,
+	}, {
+		name:    "unsupported curve",
+		data:    `{"keys": [{"kty": "EC", "crv": "P-224", "x": "AQAB", "y": "AQAB"}]}`,
+		wantErr: true,
+	}, {
+		name:    "not on the curve",
+		data:    `{"keys": [{"kty": "EC", "crv": "P-256", "x": "AQAB", "y": "AQAB"}]}`,
+		wantErr: true,
+	}, {
+		name:     "a RSA key",
+		data:     `{"keys": [{"kty": "RSA", "kid": "a", "n": "AQAB", "e": "AQAB"}]}`,
+		wantKeys: 1,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys, err := parseJWKS([]byte(tt.data))
+			if tt.wantErr {
+				assert.NotNil(t, err)
+			} else {
+				assert.Nil(t, err)
+				assert.Equal(t, tt.wantKeys, len(keys))
+			}
+		})
+	}
+}
+
+func mustGenerateECKey(t *testing.T) *ecdsa.PrivateKey {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	return key
+}
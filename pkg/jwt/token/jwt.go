@@ -17,6 +17,7 @@ limitations under the License.
 package token
 
 import (
+	"encoding/json"
 	"fmt"
 	"time"
 
@@ -35,13 +36,108 @@ type Claims struct {
 	Groups    []string            `json:"groups,omitempty"`
 	Extra     map[string][]string `json:"extra,omitempty"`
 	TokenType TokenType           `json:"token_type"`
+	// Audience shadows the one of jwt.StandardClaims which doesn't accept a single string
+	Audience audience `json:"aud,omitempty"`
 	// Currently, we are not using any field in jwt.StandardClaims
 	jwt.StandardClaims
 }
 
+// VerifyAudience compares the aud claim against cmp
+func (c *Claims) VerifyAudience(cmp string, req bool) bool {
+	return (&jwt.StandardClaims{Audience: c.Audience}).VerifyAudience(cmp, req)
+}
+
+// audience is either a single string or an array of strings, see also https://tools.ietf.org/html/rfc7519#section-4.1.3
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	var multiple []string
+	if err := json.Unmarshal(data, &multiple); err != nil {
+		return err
+	}
+	*a = multiple
+	return nil
+}
+
+// ExternalIssuer is a third party token issuer, such as an OIDC provider.
+// Its tokens are accepted only if they were issued by Issuer for Audience.
+type ExternalIssuer struct {
+	// KeySet provides the keys which sign the tokens
+	KeySet KeySet
+	// Issuer is the expected iss claim
+	Issuer string
+	// Audience is the expected aud claim, it's usually the client ID
+	Audience string
+}
+
+// externalSigningMethods are the algorithms which a third party signs the tokens with
+var externalSigningMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}
+
+// issuedClaims is implemented by both jwt.StandardClaims and jwt.MapClaims
+type issuedClaims interface {
+	VerifyIssuer(cmp string, req bool) bool
+	VerifyAudience(cmp string, req bool) bool
+}
+
+// ExternalClaims are the claims of a token issued by an ExternalIssuer, both Claims and jwt.MapClaims implement it
+type ExternalClaims interface {
+	jwt.Claims
+	issuedClaims
+	VerifyExpiresAt(cmp int64, req bool) bool
+	VerifyIssuedAt(cmp int64, req bool) bool
+	VerifyNotBefore(cmp int64, req bool) bool
+}
+
+// Verify parses a token into the claims, then verifies its signature with the KeySet and the claims.
+// The time based claims are verified with the maximum clock skew, and the exp claim is required.
+func (e *ExternalIssuer) Verify(tokenString string, clm ExternalClaims, maximumClockSkew time.Duration) error {
+	if e.KeySet == nil {
+		return errors.New("no key set to verify the token")
+	}
+	parser := &jwt.Parser{ValidMethods: externalSigningMethods, SkipClaimsValidation: true}
+	if _, err := parser.ParseWithClaims(tokenString, clm, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return e.KeySet.GetKey(kid)
+	}); err != nil {
+		return err
+	}
+	if err := e.verifyClaims(clm); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if !clm.VerifyExpiresAt(now.Add(-maximumClockSkew).Unix(), true) {
+		return errors.New("the token is expired")
+	}
+	if !clm.VerifyIssuedAt(now.Add(maximumClockSkew).Unix(), false) ||
+		!clm.VerifyNotBefore(now.Add(maximumClockSkew).Unix(), false) {
+		return errors.New("the token is not valid yet")
+	}
+	return nil
+}
+
+// verifyClaims makes sure the token was issued by the expected issuer for the expected audience,
+// otherwise any token issued for another client would be accepted
+func (e *ExternalIssuer) verifyClaims(clm issuedClaims) error {
+	if !clm.VerifyIssuer(e.Issuer, true) {
+		return fmt.Errorf("the token was not issued by '%s'", e.Issuer)
+	}
+	if !clm.VerifyAudience(e.Audience, true) {
+		return fmt.Errorf("the token was not issued for the audience '%s'", e.Audience)
+	}
+	return nil
+}
+
 type jwtTokenIssuer struct {
 	name   string
 	secret []byte
+	// external verifies the tokens which were signed by a third party, it's optional
+	external *ExternalIssuer
 	// Maximum time difference
 	maximumClockSkew time.Duration
 }
@@ -62,6 +158,8 @@ func (s *jwtTokenIssuer) VerifyWithoutClaimsValidation(tokenString string) (user
 		var ok bool
 		if mapClaims, ok = token.Claims.(jwt.MapClaims); !ok {
 			err = errors.New("unexpect type (should be map[string]interface{}) of JWT token claims: %v", token.Claims)
+		} else if err = s.verifyExternalClaims(token, mapClaims); err != nil {
+			return
 		} else if username := getUserFromClaims(mapClaims); username != "" {
 			userInfo = &user.DefaultInfo{
 				Name: username,
@@ -89,12 +187,49 @@ func getUserFromClaims(claims jwt.MapClaims) (user string) {
 
 func (s *jwtTokenIssuer) Verify(tokenString string) (user.Info, TokenType, error) {
 	clm := &Claims{}
-	// verify token signature and expiration time
-	_, err := jwt.ParseWithClaims(tokenString, clm, s.keyFunc)
+	var tokenType TokenType
+	if s.isExternal(tokenString) {
+		if err := s.external.Verify(tokenString, clm, s.maximumClockSkew); err != nil {
+			return nil, "", err
+		}
+		// the token type claim is meaningless if the token was not issued by KubeSphere
+		tokenType = ExternalToken
+	} else {
+		// verify token signature and expiration time
+		if _, err := jwt.ParseWithClaims(tokenString, clm, s.keyFunc); err != nil {
+			return nil, "", err
+		}
+		tokenType = clm.TokenType
+	}
+	username := clm.Username
+	if username == "" {
+		username = clm.Subject
+	}
+	if username == "" {
+		return nil, "", errors.New("no username or sub found from jwt")
+	}
+	return &user.DefaultInfo{Name: username, Groups: clm.Groups, Extra: clm.Extra}, tokenType, nil
+}
+
+// isExternal returns true if the token is not signed with the secret and there is an external issuer to verify it
+func (s *jwtTokenIssuer) isExternal(tokenString string) bool {
+	if s.external == nil {
+		return false
+	}
+	token, _, err := new(jwt.Parser).ParseUnverified(tokenString, jwt.MapClaims{})
 	if err != nil {
-		return nil, "", err
+		return false
+	}
+	_, ok := token.Method.(*jwt.SigningMethodHMAC)
+	return !ok
+}
+
+// verifyExternalClaims verifies the issuer and audience of the tokens which were not signed with the secret
+func (s *jwtTokenIssuer) verifyExternalClaims(token *jwt.Token, clm issuedClaims) error {
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); ok || s.external == nil {
+		return nil
 	}
-	return &user.DefaultInfo{Name: clm.Username, Groups: clm.Groups, Extra: clm.Extra}, clm.TokenType, nil
+	return s.external.verifyClaims(clm)
 }
 
 func (s *jwtTokenIssuer) IssueTo(user user.Info, tokenType TokenType, expiresIn time.Duration) (string, error) {
@@ -127,17 +262,34 @@ func (s *jwtTokenIssuer) IssueTo(user user.Info, tokenType TokenType, expiresIn
 }
 
 func (s *jwtTokenIssuer) keyFunc(token *jwt.Token) (i interface{}, err error) {
-	if _, ok := token.Method.(*jwt.SigningMethodHMAC); ok {
+	switch token.Method.(type) {
+	case *jwt.SigningMethodHMAC:
+		if len(s.secret) == 0 {
+			// an empty secret means anyone is able to sign a token
+			return nil, fmt.Errorf("no secret to verify the token signed with %v", token.Header["alg"])
+		}
 		return s.secret, nil
-	} else {
-		return nil, fmt.Errorf("expect token signed with HMAC but got %v", token.Header["alg"])
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS, *jwt.SigningMethodECDSA:
+		if s.external == nil || s.external.KeySet == nil {
+			return nil, fmt.Errorf("expect token signed with HMAC but got %v", token.Header["alg"])
+		}
+		kid, _ := token.Header["kid"].(string)
+		return s.external.KeySet.GetKey(kid)
+	default:
+		return nil, fmt.Errorf("unsupported signing method %v", token.Header["alg"])
 	}
 }
 
 func NewTokenIssuer(secret string, maximumClockSkew time.Duration) Issuer {
+	return NewTokenIssuerWithExternal(secret, nil, maximumClockSkew)
+}
+
+// NewTokenIssuerWithExternal creates an Issuer which is able to verify the tokens signed by a third party as well
+func NewTokenIssuerWithExternal(secret string, external *ExternalIssuer, maximumClockSkew time.Duration) Issuer {
 	return &jwtTokenIssuer{
 		name:             DefaultIssuerName,
 		secret:           []byte(secret),
+		external:         external,
 		maximumClockSkew: maximumClockSkew,
 	}
 }
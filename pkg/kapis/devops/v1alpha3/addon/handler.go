@@ -0,0 +1,103 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package addon
+
+import (
+	"net/http"
+
+	"github.com/emicklei/go-restful"
+	"k8s.io/apimachinery/pkg/runtime"
+	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
+	"kubesphere.io/devops/pkg/apiserver/query"
+	resourcesV1alpha3 "kubesphere.io/devops/pkg/models/resources/v1alpha3"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Health is the summary of an addon and the health of its resources
+type Health struct {
+	Name      string                   `json:"name"`
+	Namespace string                   `json:"namespace,omitempty"`
+	Version   string                   `json:"version,omitempty"`
+	Strategy  string                   `json:"strategy,omitempty"`
+	Phase     v1alpha3.AddonPhase      `json:"phase,omitempty"`
+	Ready     bool                     `json:"ready"`
+	Message   string                   `json:"message,omitempty"`
+	Resources []v1alpha3.AddonResource `json:"resources,omitempty"`
+}
+
+func (h *handler) listAddons(req *restful.Request, resp *restful.Response) {
+	ctx := req.Request.Context()
+
+	var opts []client.ListOption
+	if namespace := req.PathParameter(NamespacePathParameter.Data().Name); namespace != "" {
+		opts = append(opts, client.InNamespace(namespace))
+	}
+
+	addonList := &v1alpha3.AddonList{}
+	if err := h.List(ctx, addonList, opts...); err != nil {
+		_ = resp.WriteError(http.StatusInternalServerError, err)
+		return
+	}
+
+	queryParam := query.ParseQueryParameter(req)
+	_ = resp.WriteAsJson(resourcesV1alpha3.ToListResult(convertToObject(addonList.Items), queryParam, listHandler{}))
+}
+
+func convertToObject(addons []v1alpha3.Addon) []runtime.Object {
+	var result []runtime.Object
+	for i := range addons {
+		result = append(result, &addons[i])
+	}
+	return result
+}
+
+// listHandler sorts the addons by name, then transforms them into the health summaries
+type listHandler struct {
+	resourcesV1alpha3.NamedHandler
+}
+
+// Transformer transforms an addon into its health summary
+func (h listHandler) Transformer() resourcesV1alpha3.TransformFunc {
+	return func(object runtime.Object) interface{} {
+		addon, ok := object.(*v1alpha3.Addon)
+		if !ok {
+			return object
+		}
+		return toHealth(addon)
+	}
+}
+
+func toHealth(addon *v1alpha3.Addon) Health {
+	health := Health{
+		Name:      addon.Name,
+		Namespace: addon.Namespace,
+		Version:   addon.Spec.Version,
+		Strategy:  addon.Spec.Strategy.Name,
+		Phase:     addon.Status.Phase,
+		Ready:     addon.Status.IsReady(),
+		Resources: addon.Status.Resources,
+	}
+
+	// take the message of the failed condition
+	for _, conditionType := range []v1alpha3.ConditionType{v1alpha3.AddonConditionInstalled, v1alpha3.AddonConditionReady} {
+		if condition := addon.Status.GetCondition(conditionType); condition != nil && condition.Status != v1alpha3.ConditionTrue {
+			health.Message = condition.Message
+			break
+		}
+	}
+	return health
+}
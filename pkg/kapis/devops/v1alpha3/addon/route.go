@@ -0,0 +1,52 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package addon
+
+import (
+	"net/http"
+
+	"github.com/emicklei/go-restful"
+	"kubesphere.io/devops/pkg/api"
+	"kubesphere.io/devops/pkg/kapis/devops/v1alpha3/common"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+type handler struct {
+	client.Client
+}
+
+var (
+	// NamespacePathParameter is the path parameter definition of the namespace
+	NamespacePathParameter = restful.PathParameter("namespace", "The namespace of the addons")
+)
+
+// TODO perhaps we can find a better way to declaim the permission needs of the apiserver
+//+kubebuilder:rbac:groups=devops.kubesphere.io,resources=addons,verbs=get;list;watch
+
+// RegisterRoutes registry the handlers of the addons
+func RegisterRoutes(service *restful.WebService, options *common.Options) {
+	h := &handler{options.GenericClient}
+	service.Route(service.GET("/addons").
+		To(h.listAddons).
+		Doc("Return the addons of all namespaces with their health").
+		Returns(http.StatusOK, api.StatusOK, api.ListResult{Items: []interface{}{Health{}}}))
+	service.Route(service.GET("/namespaces/{namespace}/addons").
+		To(h.listAddons).
+		Param(NamespacePathParameter).
+		Doc("Return the addons of a namespace with their health").
+		Returns(http.StatusOK, api.StatusOK, api.ListResult{Items: []interface{}{Health{}}}))
+}
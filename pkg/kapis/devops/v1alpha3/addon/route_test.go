@@ -0,0 +1,144 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package addon
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/emicklei/go-restful"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	runtimeSchema "k8s.io/apimachinery/pkg/runtime/schema"
+	"kubesphere.io/devops/pkg/api"
+	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
+	ksruntime "kubesphere.io/devops/pkg/apiserver/runtime"
+	"kubesphere.io/devops/pkg/kapis/devops/v1alpha3/common"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestAPIs(t *testing.T) {
+	schema, err := v1alpha3.SchemeBuilder.Register().Build()
+	assert.Nil(t, err)
+
+	readyAddon := &v1alpha3.Addon{
+		ObjectMeta: metav1.ObjectMeta{Name: "argocd", Namespace: "default"},
+		Spec: v1alpha3.AddonSpec{
+			Version:  "v2.3.1",
+			Strategy: v1.LocalObjectReference{Name: "simple-operator-argocd"},
+		},
+		Status: v1alpha3.AddonStatus{
+			Phase: v1alpha3.AddonPhaseReady,
+			Conditions: []v1alpha3.Condition{{
+				Type:   v1alpha3.AddonConditionReady,
+				Status: v1alpha3.ConditionTrue,
+			}},
+			Resources: []v1alpha3.AddonResource{{Kind: "ArgoCD", Namespace: "default", Name: "argocd", Ready: true}},
+		},
+	}
+	failedAddon := &v1alpha3.Addon{
+		ObjectMeta: metav1.ObjectMeta{Name: "jenkins", Namespace: "devops"},
+		Spec: v1alpha3.AddonSpec{
+			Strategy: v1.LocalObjectReference{Name: "helm-jenkins"},
+		},
+		Status: v1alpha3.AddonStatus{
+			Phase: v1alpha3.AddonPhaseFailed,
+			Conditions: []v1alpha3.Condition{{
+				Type:    v1alpha3.AddonConditionInstalled,
+				Status:  v1alpha3.ConditionFalse,
+				Reason:  "StrategyNotFound",
+				Message: "not found",
+			}},
+		},
+	}
+
+	tests := []struct {
+		name       string
+		api        string
+		objects    []runtime.Object
+		wantCode   int
+		wantHealth []Health
+	}{{
+		name:       "an empty list",
+		api:        "/addons",
+		wantCode:   http.StatusOK,
+		wantHealth: []Health{},
+	}, {
+		name:     "all the addons",
+		api:      "/addons",
+		objects:  []runtime.Object{readyAddon, failedAddon},
+		wantCode: http.StatusOK,
+		wantHealth: []Health{{
+			Name:      "argocd",
+			Namespace: "default",
+			Version:   "v2.3.1",
+			Strategy:  "simple-operator-argocd",
+			Phase:     v1alpha3.AddonPhaseReady,
+			Ready:     true,
+			Resources: []v1alpha3.AddonResource{{Kind: "ArgoCD", Namespace: "default", Name: "argocd", Ready: true}},
+		}, {
+			Name:      "jenkins",
+			Namespace: "devops",
+			Strategy:  "helm-jenkins",
+			Phase:     v1alpha3.AddonPhaseFailed,
+			Message:   "not found",
+		}},
+	}, {
+		name:     "the addons of a namespace",
+		api:      "/namespaces/devops/addons",
+		objects:  []runtime.Object{readyAddon, failedAddon},
+		wantCode: http.StatusOK,
+		wantHealth: []Health{{
+			Name:      "jenkins",
+			Namespace: "devops",
+			Strategy:  "helm-jenkins",
+			Phase:     v1alpha3.AddonPhaseFailed,
+			Message:   "not found",
+		}},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ws := ksruntime.NewWebService(runtimeSchema.GroupVersion{Group: api.GroupName, Version: "v1alpha3"})
+			RegisterRoutes(ws, &common.Options{
+				GenericClient: fake.NewFakeClientWithScheme(schema, tt.objects...),
+			})
+			container := restful.NewContainer()
+			container.Add(ws)
+
+			httpRequest, _ := http.NewRequest(http.MethodGet,
+				"http://fake.com/kapis/devops.kubesphere.io/v1alpha3"+tt.api, nil)
+			httpWriter := httptest.NewRecorder()
+			container.Dispatch(httpWriter, httpRequest)
+			assert.Equal(t, tt.wantCode, httpWriter.Code)
+
+			result := &struct {
+				Items      []Health `json:"items"`
+				TotalItems int      `json:"totalItems"`
+			}{}
+			err := json.Unmarshal(httpWriter.Body.Bytes(), result)
+			assert.Nil(t, err)
+			assert.Equal(t, len(tt.wantHealth), result.TotalItems)
+			if len(tt.wantHealth) > 0 {
+				assert.Equal(t, tt.wantHealth, result.Items)
+			}
+		})
+	}
+}
@@ -17,7 +17,6 @@ limitations under the License.
 package pipeline
 
 import (
-	"context"
 	"encoding/json"
 	"fmt"
 
@@ -54,7 +53,7 @@ func (h *apiHandler) getBranches(request *restful.Request, response *restful.Res
 
 	// get pipelinerun
 	pipeline := &v1alpha3.Pipeline{}
-	if err := h.client.Get(context.Background(), client.ObjectKey{Namespace: namespaceName, Name: pipelineName}, pipeline); err != nil {
+	if err := h.client.Get(request.Request.Context(), client.ObjectKey{Namespace: namespaceName, Name: pipelineName}, pipeline); err != nil {
 		kapis.HandleError(request, response, err)
 		return
 	}
@@ -87,7 +86,7 @@ func (h *apiHandler) getBranch(request *restful.Request, response *restful.Respo
 
 	// get pipelinerun
 	pipeline := &v1alpha3.Pipeline{}
-	if err := h.client.Get(context.Background(), client.ObjectKey{Namespace: namespaceName, Name: pipelineName}, pipeline); err != nil {
+	if err := h.client.Get(request.Request.Context(), client.ObjectKey{Namespace: namespaceName, Name: pipelineName}, pipeline); err != nil {
 		kapis.HandleError(request, response, err)
 		return
 	}
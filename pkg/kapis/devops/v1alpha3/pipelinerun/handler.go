@@ -22,10 +22,14 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"kubesphere.io/devops/pkg/client/s3"
 	cmstore "kubesphere.io/devops/pkg/store/configmap"
 	"net/url"
 	"strconv"
+	"time"
 
 	"kubesphere.io/devops/pkg/kapis"
 
@@ -45,6 +49,13 @@ import (
 type apiHandlerOption struct {
 	devopsClient devopsClient.Interface
 	client       client.Client
+	// genericClient is the own client of the server, it works for the things which the request user
+	// might have no permission to, such as reading the role bindings and recording the approvals
+	genericClient client.Client
+	// s3Client is optional, it's used to read the archived data of PipelineRuns
+	s3Client s3.Interface
+	// logPollInterval is the interval of reading the log of a running PipelineRun in the follow mode
+	logPollInterval time.Duration
 }
 
 // apiHandler contains functions to handle coming request and give a response.
@@ -71,7 +82,7 @@ func (h *apiHandler) listPipelineRuns(request *restful.Request, response *restfu
 
 	// validate the Pipeline
 	pipeline := &v1alpha3.Pipeline{}
-	err = h.client.Get(context.Background(), client.ObjectKey{Namespace: nsName, Name: pipName}, pipeline)
+	err = h.client.Get(request.Request.Context(), client.ObjectKey{Namespace: nsName, Name: pipName}, pipeline)
 	if err != nil {
 		kapis.HandleError(request, response, err)
 		return
@@ -84,19 +95,21 @@ func (h *apiHandler) listPipelineRuns(request *restful.Request, response *restfu
 		return
 	}
 
-	opts := make([]client.ListOption, 0, 3)
+	opts := make([]client.ListOption, 0, 2)
 	opts = append(opts, client.InNamespace(pipeline.Namespace))
 	opts = append(opts, client.MatchingLabelsSelector{Selector: labelSelector})
-	if branchName != "" {
-		opts = append(opts, client.MatchingFields{v1alpha3.PipelineRunSCMRefNameField: branchName})
-	}
 
 	var prs v1alpha3.PipelineRunList
 	// fetch PipelineRuns
-	if err := h.client.List(context.Background(), &prs, opts...); err != nil {
+	if err := h.client.List(request.Request.Context(), &prs, opts...); err != nil {
 		kapis.HandleError(request, response, err)
 		return
 	}
+	if branchName != "" {
+		// filter by the branch here instead of a field selector, which is only supported by the cache,
+		// then it works with the impersonating client as well
+		prs.Items = filterPipelineRunsByRefName(prs.Items, branchName)
+	}
 
 	var listHandler resourcesV1alpha3.ListHandler = listHandler{}
 	if backward {
@@ -106,6 +119,17 @@ func (h *apiHandler) listPipelineRuns(request *restful.Request, response *restfu
 	_ = response.WriteAsJson(apiResult)
 }
 
+// filterPipelineRunsByRefName returns the PipelineRuns of the given SCM reference name
+func filterPipelineRunsByRefName(prs []v1alpha3.PipelineRun, refName string) []v1alpha3.PipelineRun {
+	filtered := make([]v1alpha3.PipelineRun, 0, len(prs))
+	for i := range prs {
+		if prs[i].Spec.SCM != nil && prs[i].Spec.SCM.RefName == refName {
+			filtered = append(filtered, prs[i])
+		}
+	}
+	return filtered
+}
+
 func (h *apiHandler) createPipelineRun(request *restful.Request, response *restful.Response) {
 	nsName := request.PathParameter("namespace")
 	pipName := request.PathParameter("pipeline")
@@ -117,7 +141,7 @@ func (h *apiHandler) createPipelineRun(request *restful.Request, response *restf
 	}
 	// validate the Pipeline
 	var pipeline v1alpha3.Pipeline
-	if err := h.client.Get(context.Background(), client.ObjectKey{Namespace: nsName, Name: pipName}, &pipeline); err != nil {
+	if err := h.client.Get(request.Request.Context(), client.ObjectKey{Namespace: nsName, Name: pipName}, &pipeline); err != nil {
 		kapis.HandleError(request, response, err)
 		return
 	}
@@ -144,7 +168,7 @@ func (h *apiHandler) createPipelineRun(request *restful.Request, response *restf
 	if user.GetName() != "" {
 		pr.GetAnnotations()[v1alpha3.PipelineRunCreatorAnnoKey] = user.GetName()
 	}
-	if err := h.client.Create(context.Background(), pr); err != nil {
+	if err := h.client.Create(request.Request.Context(), pr); err != nil {
 		kapis.HandleError(request, response, err)
 		return
 	}
@@ -158,13 +182,53 @@ func (h *apiHandler) getPipelineRun(request *restful.Request, response *restful.
 
 	// get pipelinerun
 	var pr v1alpha3.PipelineRun
-	if err := h.client.Get(context.Background(), client.ObjectKey{Namespace: nsName, Name: prName}, &pr); err != nil {
+	if err := h.client.Get(request.Request.Context(), client.ObjectKey{Namespace: nsName, Name: prName}, &pr); err != nil {
 		kapis.HandleError(request, response, err)
 		return
 	}
 	_ = response.WriteEntity(&pr)
 }
 
+// actionPayload is the request body to set the action of a PipelineRun.
+type actionPayload struct {
+	Action v1alpha3.Action `json:"action" description:"the action of the PipelineRun, one of Stop, Pause and Resume"`
+}
+
+func (h *apiHandler) setPipelineRunAction(request *restful.Request, response *restful.Response) {
+	nsName := request.PathParameter("namespace")
+	prName := request.PathParameter("pipelinerun")
+	ctx := request.Request.Context()
+
+	payload := actionPayload{}
+	if err := request.ReadEntity(&payload); err != nil {
+		kapis.HandleBadRequest(response, request, err)
+		return
+	}
+	if !payload.Action.IsValid() {
+		kapis.HandleBadRequest(response, request, fmt.Errorf("invalid action '%s', it should be one of %s, %s and %s",
+			payload.Action, v1alpha3.Stop, v1alpha3.Pause, v1alpha3.Resume))
+		return
+	}
+
+	pr := &v1alpha3.PipelineRun{}
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if err := h.client.Get(ctx, client.ObjectKey{Namespace: nsName, Name: prName}, pr); err != nil {
+			return err
+		}
+		if pr.HasCompleted() {
+			return errors.NewBadRequest(fmt.Sprintf("the PipelineRun '%s/%s' has completed", nsName, prName))
+		}
+		action := payload.Action
+		pr.Spec.Action = &action
+		return h.client.Update(ctx, pr)
+	})
+	if err != nil {
+		kapis.HandleError(request, response, err)
+		return
+	}
+	_ = response.WriteEntity(pr)
+}
+
 func (h *apiHandler) getNodeDetails(request *restful.Request, response *restful.Response) {
 	namespaceName := request.PathParameter("namespace")
 	pipelineRunName := request.PathParameter("pipelinerun")
@@ -177,12 +241,31 @@ func (h *apiHandler) getNodeDetails(request *restful.Request, response *restful.
 		return
 	}
 
-	// get stage status
+	stages, err := h.getStages(ctx, pr)
+	if err != nil {
+		kapis.HandleError(request, response, err)
+		return
+	}
+
+	// the approvable field depends on the current user
+	identities := h.getIdentities(ctx, namespaceName)
+	for i := range stages {
+		for j := range stages[i].Steps {
+			stages[i].Steps[j].Approvable = isApprovable(&stages[i].Steps[j], identities)
+		}
+	}
+
+	_ = response.WriteEntity(&stages)
+}
+
+// getStages returns the stages of a PipelineRun, they come from the annotation, the ConfigMap data store
+// or the archived data store.
+func (h *apiHandler) getStages(ctx context.Context, pr *v1alpha3.PipelineRun) (stages []pipelinerun.NodeDetail, err error) {
 	stagesJSON, ok := pr.Annotations[v1alpha3.JenkinsPipelineRunStagesStatusAnnoKey]
 	if !ok {
 		if pipelineRunStore, err := cmstore.NewConfigMapStore(ctx, types.NamespacedName{
-			Namespace: namespaceName,
-			Name:      pipelineRunName,
+			Namespace: pr.Namespace,
+			Name:      pr.Name,
 		}, h.client); err != nil {
 			// If the stages status does not exist, set it as an empty array
 			stagesJSON = "[]"
@@ -190,22 +273,12 @@ func (h *apiHandler) getNodeDetails(request *restful.Request, response *restful.
 			stagesJSON = pipelineRunStore.GetStages()
 		}
 	}
-
-	var stages []pipelinerun.NodeDetail
-	if err := json.Unmarshal([]byte(stagesJSON), &stages); err != nil {
-		kapis.HandleError(request, response, err)
-		return
+	if stagesJSON == "" {
+		stagesJSON = h.getArchivedStages(pr)
 	}
 
-	// TODO(johnniang): Check current user Handle the approvable field of NodeDetail
-	// this is a temporary solution of approvable
-	for i := range stages {
-		for j := range stages[i].Steps {
-			stages[i].Steps[j].Approvable = true
-		}
-	}
-
-	_ = response.WriteEntity(&stages)
+	err = json.Unmarshal([]byte(stagesJSON), &stages)
+	return
 }
 
 // downloadArtifact API to download artifacts from Jenkins
@@ -216,7 +289,7 @@ func (h *apiHandler) downloadArtifact(request *restful.Request, response *restfu
 
 	// get pipelinerun
 	pr := &v1alpha3.PipelineRun{}
-	err := h.client.Get(context.Background(), client.ObjectKey{Namespace: namespaceName, Name: pipelineRunName}, pr)
+	err := h.client.Get(request.Request.Context(), client.ObjectKey{Namespace: namespaceName, Name: pipelineRunName}, pr)
 	if err != nil {
 		kapis.HandleError(request, response, err)
 		return
@@ -22,6 +22,7 @@ import (
 	"io"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apiserver/pkg/authentication/user"
 	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
 	"kubesphere.io/devops/pkg/apiserver/request"
@@ -42,7 +43,7 @@ func TestApis(t *testing.T) {
 	schema, err := v1alpha3.SchemeBuilder.Register().Build()
 	assert.Nil(t, err)
 
-	RegisterRoutes(wsWithGroup, fakedevops.NewFakeDevops(nil), fake.NewFakeClientWithScheme(schema, &v1alpha3.Pipeline{
+	c := fake.NewFakeClientWithScheme(schema, &v1alpha3.Pipeline{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "fake",
 			Namespace: "fake",
@@ -50,7 +51,8 @@ func TestApis(t *testing.T) {
 		Spec: v1alpha3.PipelineSpec{
 			Type: v1alpha3.NoScmPipelineType,
 		},
-	}))
+	})
+	RegisterRoutes(wsWithGroup, fakedevops.NewFakeDevops(nil), c, c, nil)
 	restful.DefaultContainer.Add(wsWithGroup)
 
 	type args struct {
@@ -170,10 +172,110 @@ func TestGetNodeDetails(t *testing.T) {
   "startTime": null,
   "steps": [
    {
-    "startTime": null,
-    "approvable": true
+    "startTime": null
    }
   ]
  }
 ]`, string(body))
 }
+
+func TestSetPipelineRunAction(t *testing.T) {
+	schema, err := v1alpha3.SchemeBuilder.Register().Build()
+	assert.Nil(t, err)
+
+	pipelineRun := &v1alpha3.PipelineRun{}
+	pipelineRun.SetName("pr1")
+	pipelineRun.SetNamespace("ns")
+
+	now := metav1.Now()
+	completedPipelineRun := pipelineRun.DeepCopy()
+	completedPipelineRun.SetName("pr2")
+	completedPipelineRun.Status.CompletionTime = &now
+
+	handler := &apiHandler{
+		apiHandlerOption: apiHandlerOption{
+			client: fake.NewClientBuilder().WithScheme(schema).
+				WithObjects(pipelineRun, completedPipelineRun).Build(),
+		},
+	}
+
+	tests := []struct {
+		name       string
+		prName     string
+		body       string
+		wantStatus int
+		wantAction v1alpha3.Action
+	}{{
+		name:       "invalid payload",
+		prName:     "pr1",
+		body:       "{",
+		wantStatus: http.StatusBadRequest,
+	}, {
+		name:       "invalid action",
+		prName:     "pr1",
+		body:       `{"action":"Restart"}`,
+		wantStatus: http.StatusBadRequest,
+	}, {
+		name:       "not found PipelineRun",
+		prName:     "fake",
+		body:       `{"action":"Stop"}`,
+		wantStatus: http.StatusNotFound,
+	}, {
+		name:       "completed PipelineRun",
+		prName:     "pr2",
+		body:       `{"action":"Stop"}`,
+		wantStatus: http.StatusBadRequest,
+	}, {
+		name:       "pause a PipelineRun",
+		prName:     "pr1",
+		body:       `{"action":"Pause"}`,
+		wantStatus: http.StatusOK,
+		wantAction: v1alpha3.Pause,
+	}, {
+		name:       "stop a PipelineRun",
+		prName:     "pr1",
+		body:       `{"action":"Stop"}`,
+		wantStatus: http.StatusOK,
+		wantAction: v1alpha3.Stop,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			httpRequest, _ := http.NewRequest(http.MethodPut, "/", bytes.NewBufferString(tt.body))
+			httpRequest.Header.Set("Content-Type", "application/json")
+			req := restful.NewRequest(httpRequest)
+			req.PathParameters()["namespace"] = "ns"
+			req.PathParameters()["pipelinerun"] = tt.prName
+			recorder := httptest.NewRecorder()
+			resp := restful.NewResponse(recorder)
+			resp.SetRequestAccepts(restful.MIME_JSON)
+
+			handler.setPipelineRunAction(req, resp)
+			assert.Equal(t, tt.wantStatus, recorder.Code)
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			pr := &v1alpha3.PipelineRun{}
+			assert.Nil(t, handler.client.Get(context.Background(), types.NamespacedName{Namespace: "ns", Name: tt.prName}, pr))
+			assert.Equal(t, tt.wantAction, pr.GetAction())
+		})
+	}
+}
+
+func TestFilterPipelineRunsByRefName(t *testing.T) {
+	newPipelineRun := func(name string, scm *v1alpha3.SCM) v1alpha3.PipelineRun {
+		return v1alpha3.PipelineRun{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec:       v1alpha3.PipelineRunSpec{SCM: scm},
+		}
+	}
+	prs := []v1alpha3.PipelineRun{
+		newPipelineRun("main-1", &v1alpha3.SCM{RefName: "main"}),
+		newPipelineRun("dev-1", &v1alpha3.SCM{RefName: "dev"}),
+		newPipelineRun("no-scm", nil),
+		newPipelineRun("main-2", &v1alpha3.SCM{RefName: "main"}),
+	}
+
+	assert.Equal(t, []v1alpha3.PipelineRun{prs[0], prs[3]}, filterPipelineRunsByRefName(prs, "main"))
+	assert.Equal(t, []v1alpha3.PipelineRun{prs[1]}, filterPipelineRunsByRefName(prs, "dev"))
+	assert.Empty(t, filterPipelineRunsByRefName(prs, "unknown"))
+}
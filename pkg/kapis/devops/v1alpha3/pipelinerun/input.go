@@ -0,0 +1,219 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinerun
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/emicklei/go-restful"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/klog/v2"
+	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
+	apiserverrequest "kubesphere.io/devops/pkg/apiserver/request"
+	"kubesphere.io/devops/pkg/client/devops"
+	"kubesphere.io/devops/pkg/kapis"
+	"kubesphere.io/devops/pkg/models/pipelinerun"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// inputPayload is the request body to submit an input step.
+type inputPayload struct {
+	Abort      bool                 `json:"abort,omitempty" description:"abort the PipelineRun instead of proceeding"`
+	Parameters []v1alpha3.Parameter `json:"parameters,omitempty" description:"the values of the input parameters"`
+}
+
+// jenkinsInput is the request body of the BlueOcean API to submit an input step.
+type jenkinsInput struct {
+	ID         string               `json:"id"`
+	Abort      bool                 `json:"abort,omitempty"`
+	Parameters []v1alpha3.Parameter `json:"parameters,omitempty"`
+}
+
+// getIdentities returns the identities of the current user in a namespace, they are the name, groups and
+// the roles of the user. It's empty if there is no user found.
+func (h *apiHandler) getIdentities(ctx context.Context, namespace string) sets.String {
+	identities := sets.NewString()
+	userInfo, ok := apiserverrequest.UserFrom(ctx)
+	if !ok || userInfo == nil || userInfo.GetName() == "" {
+		return identities
+	}
+	identities.Insert(userInfo.GetName())
+	identities.Insert(userInfo.GetGroups()...)
+
+	// the request user might have no permission to read the role bindings, so it's read by the own client of the server
+	roleBindingList := &rbacv1.RoleBindingList{}
+	if err := h.genericClient.List(ctx, roleBindingList, client.InNamespace(namespace)); err != nil {
+		klog.Errorf("failed to list the role bindings of namespace %s, error: %v", namespace, err)
+		return identities
+	}
+	for i := range roleBindingList.Items {
+		roleBinding := roleBindingList.Items[i]
+		if boundTo(roleBinding.Subjects, userInfo) {
+			identities.Insert(roleBinding.RoleRef.Name)
+		}
+	}
+	return identities
+}
+
+// boundTo checks if any of the subjects is the user or one of the groups of the user
+func boundTo(subjects []rbacv1.Subject, userInfo user.Info) bool {
+	groups := sets.NewString(userInfo.GetGroups()...)
+	for _, subject := range subjects {
+		switch subject.Kind {
+		case rbacv1.UserKind:
+			if subject.Name == userInfo.GetName() {
+				return true
+			}
+		case rbacv1.GroupKind:
+			if groups.Has(subject.Name) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isApprovable checks if an input step can be submitted by any of the identities.
+// Anyone could submit it if there are no particular submitters, which is the same as Jenkins.
+func isApprovable(step *pipelinerun.Step, identities sets.String) bool {
+	if step.State != devops.StatePaused || step.Input == nil || identities.Len() == 0 {
+		return false
+	}
+	submitters := (&devops.Input{Submitter: step.Input.Submitter}).GetSubmitters()
+	return len(submitters) == 0 || identities.HasAny(submitters...)
+}
+
+func (h *apiHandler) submitInputStep(request *restful.Request, response *restful.Response) {
+	namespaceName := request.PathParameter("namespace")
+	pipelineRunName := request.PathParameter("pipelinerun")
+	nodeID := request.PathParameter("node")
+	stepID := request.PathParameter("step")
+	ctx := request.Request.Context()
+
+	payload := inputPayload{}
+	if err := request.ReadEntity(&payload); err != nil && err != io.EOF {
+		kapis.HandleBadRequest(response, request, err)
+		return
+	}
+
+	pr := &v1alpha3.PipelineRun{}
+	if err := h.client.Get(ctx, client.ObjectKey{Namespace: namespaceName, Name: pipelineRunName}, pr); err != nil {
+		kapis.HandleError(request, response, err)
+		return
+	}
+	if pr.HasCompleted() {
+		kapis.HandleBadRequest(response, request, fmt.Errorf("the PipelineRun '%s/%s' has completed", namespaceName, pipelineRunName))
+		return
+	}
+	runID, exists := pr.GetPipelineRunID()
+	if !exists {
+		kapis.HandleBadRequest(response, request, fmt.Errorf("the PipelineRun '%s/%s' has not started", namespaceName, pipelineRunName))
+		return
+	}
+
+	stages, err := h.getStages(ctx, pr)
+	if err != nil {
+		kapis.HandleError(request, response, err)
+		return
+	}
+	stageIndex, stepIndex, found := findStep(stages, nodeID, stepID)
+	if !found {
+		kapis.HandleNotFound(response, request, fmt.Errorf("step '%s' of node '%s' not found", stepID, nodeID))
+		return
+	}
+	step := &stages[stageIndex].Steps[stepIndex]
+	if step.State != devops.StatePaused || step.Input == nil {
+		kapis.HandleBadRequest(response, request, fmt.Errorf("the step '%s' is not waiting for an input", stepID))
+		return
+	}
+	if !isApprovable(step, h.getIdentities(ctx, namespaceName)) {
+		kapis.HandleForbidden(response, request, fmt.Errorf("you have no permission to approve the step '%s'", stepID))
+		return
+	}
+
+	if err = h.submitToJenkins(pr, runID, nodeID, stepID, &jenkinsInput{
+		ID:         step.Input.ID,
+		Abort:      payload.Abort,
+		Parameters: payload.Parameters,
+	}); err != nil {
+		kapis.HandleError(request, response, err)
+		return
+	}
+
+	userInfo, _ := apiserverrequest.UserFrom(ctx)
+	approval := v1alpha3.Approval{
+		NodeID:     nodeID,
+		StepID:     stepID,
+		InputID:    step.Input.ID,
+		Approver:   userInfo.GetName(),
+		Aborted:    payload.Abort,
+		Parameters: payload.Parameters,
+		Time:       metav1.Now(),
+	}
+	if pr, err = h.recordApproval(ctx, client.ObjectKeyFromObject(pr), approval); err != nil {
+		kapis.HandleError(request, response, err)
+		return
+	}
+	_ = response.WriteEntity(pr)
+}
+
+// submitToJenkins submits the input via the BlueOcean API
+func (h *apiHandler) submitToJenkins(pr *v1alpha3.PipelineRun, runID, nodeID, stepID string, input *jenkinsInput) (err error) {
+	var body []byte
+	if body, err = json.Marshal(input); err != nil {
+		return
+	}
+	httpParameters := &devops.HttpParameters{
+		Method: http.MethodPost,
+		Header: http.Header{"Content-Type": []string{"application/json"}},
+		Body:   io.NopCloser(bytes.NewReader(body)),
+		Url:    &url.URL{},
+	}
+
+	pipelineName := pr.Labels[v1alpha3.PipelineNameLabelKey]
+	if pr.Spec.IsMultiBranchPipeline() {
+		_, err = h.devopsClient.SubmitBranchInputStep(pr.Namespace, pipelineName, pr.GetRefName(), runID, nodeID, stepID, httpParameters)
+	} else {
+		_, err = h.devopsClient.SubmitInputStep(pr.Namespace, pipelineName, runID, nodeID, stepID, httpParameters)
+	}
+	return
+}
+
+// recordApproval appends an approval to the status of a PipelineRun. The request user might have
+// no permission to update the status, so it's recorded by the own client of the server.
+func (h *apiHandler) recordApproval(ctx context.Context, key client.ObjectKey, approval v1alpha3.Approval) (
+	pr *v1alpha3.PipelineRun, err error) {
+	pr = &v1alpha3.PipelineRun{}
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if err := h.genericClient.Get(ctx, key, pr); err != nil {
+			return err
+		}
+		pr.Status.Approvals = append(pr.Status.Approvals, approval)
+		return h.genericClient.Status().Update(ctx, pr)
+	})
+	return
+}
@@ -0,0 +1,276 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinerun
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/emicklei/go-restful"
+	"github.com/jenkins-zh/jenkins-client/pkg/job"
+	"github.com/stretchr/testify/assert"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
+	"kubesphere.io/devops/pkg/apiserver/request"
+	"kubesphere.io/devops/pkg/client/devops"
+	fakedevops "kubesphere.io/devops/pkg/client/devops/fake"
+	"kubesphere.io/devops/pkg/models/pipelinerun"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// inputRecorder records the submitted inputs
+type inputRecorder struct {
+	*fakedevops.Devops
+	path  []string
+	input *jenkinsInput
+}
+
+func (r *inputRecorder) SubmitInputStep(projectName, pipelineName, runID, nodeID, stepID string, httpParameters *devops.HttpParameters) ([]byte, error) {
+	r.path = []string{projectName, pipelineName, runID, nodeID, stepID}
+	r.input = &jenkinsInput{}
+	return nil, json.NewDecoder(httpParameters.Body).Decode(r.input)
+}
+
+func (r *inputRecorder) SubmitBranchInputStep(projectName, pipelineName, branchName, runID, nodeID, stepID string, httpParameters *devops.HttpParameters) ([]byte, error) {
+	r.path = []string{projectName, pipelineName, branchName, runID, nodeID, stepID}
+	r.input = &jenkinsInput{}
+	return nil, json.NewDecoder(httpParameters.Body).Decode(r.input)
+}
+
+func TestIsApprovable(t *testing.T) {
+	newStep := func(state, submitter string) *pipelinerun.Step {
+		return &pipelinerun.Step{Step: job.Step{State: state, Input: &job.Input{ID: "approve", Submitter: submitter}}}
+	}
+
+	tests := []struct {
+		name       string
+		step       *pipelinerun.Step
+		identities sets.String
+		expected   bool
+	}{{
+		name:       "not an input step",
+		step:       &pipelinerun.Step{Step: job.Step{State: devops.StatePaused}},
+		identities: sets.NewString("alice"),
+		expected:   false,
+	}, {
+		name:       "not paused",
+		step:       newStep("FINISHED", ""),
+		identities: sets.NewString("alice"),
+		expected:   false,
+	}, {
+		name:       "no user",
+		step:       newStep(devops.StatePaused, ""),
+		identities: sets.NewString(),
+		expected:   false,
+	}, {
+		name:       "no particular submitters",
+		step:       newStep(devops.StatePaused, ""),
+		identities: sets.NewString("alice"),
+		expected:   true,
+	}, {
+		name:       "the user is one of the submitters",
+		step:       newStep(devops.StatePaused, "bob, alice"),
+		identities: sets.NewString("alice"),
+		expected:   true,
+	}, {
+		name:       "a group or role of the user is one of the submitters",
+		step:       newStep(devops.StatePaused, "bob,admin"),
+		identities: sets.NewString("alice", "system:authenticated", "admin"),
+		expected:   true,
+	}, {
+		name:       "not a submitter",
+		step:       newStep(devops.StatePaused, "bob"),
+		identities: sets.NewString("alice", "system:authenticated"),
+		expected:   false,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, isApprovable(tt.step, tt.identities))
+		})
+	}
+}
+
+func TestSubmitInputStep(t *testing.T) {
+	schema, err := v1alpha3.SchemeBuilder.Register().Build()
+	assert.Nil(t, err)
+	assert.Nil(t, rbacv1.AddToScheme(schema))
+
+	stages := `[{"id":"1","steps":[{"id":"2","state":"PAUSED","input":{"id":"approve","submitter":"alice,release-managers,admin"}},
+{"id":"3","state":"FINISHED"}]}]`
+	pipelineRun := &v1alpha3.PipelineRun{}
+	pipelineRun.SetName("pr1")
+	pipelineRun.SetNamespace("ns")
+	pipelineRun.SetLabels(map[string]string{v1alpha3.PipelineNameLabelKey: "pipeline"})
+	pipelineRun.SetAnnotations(map[string]string{
+		v1alpha3.JenkinsPipelineRunIDAnnoKey:           "1",
+		v1alpha3.JenkinsPipelineRunStagesStatusAnnoKey: stages,
+	})
+
+	multiBranchPipelineRun := pipelineRun.DeepCopy()
+	multiBranchPipelineRun.SetName("pr2")
+	multiBranchPipelineRun.Spec.PipelineSpec = &v1alpha3.PipelineSpec{Type: v1alpha3.MultiBranchPipelineType}
+	multiBranchPipelineRun.Spec.SCM = &v1alpha3.SCM{RefName: "main"}
+
+	now := metav1.Now()
+	completedPipelineRun := pipelineRun.DeepCopy()
+	completedPipelineRun.SetName("pr3")
+	completedPipelineRun.Status.CompletionTime = &now
+
+	notStartedPipelineRun := pipelineRun.DeepCopy()
+	notStartedPipelineRun.SetName("pr4")
+	notStartedPipelineRun.SetAnnotations(nil)
+
+	roleBinding := &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "bob-admin", Namespace: "ns"},
+		Subjects:   []rbacv1.Subject{{Kind: rbacv1.UserKind, Name: "bob"}},
+		RoleRef:    rbacv1.RoleRef{Kind: "Role", Name: "admin"},
+	}
+
+	tests := []struct {
+		name         string
+		prName       string
+		step         string
+		user         user.Info
+		body         string
+		wantStatus   int
+		wantPath     []string
+		wantInput    *jenkinsInput
+		wantApproval *v1alpha3.Approval
+	}{{
+		name:       "not found PipelineRun",
+		prName:     "fake",
+		step:       "2",
+		user:       &user.DefaultInfo{Name: "alice"},
+		wantStatus: http.StatusNotFound,
+	}, {
+		name:       "completed PipelineRun",
+		prName:     "pr3",
+		step:       "2",
+		user:       &user.DefaultInfo{Name: "alice"},
+		wantStatus: http.StatusBadRequest,
+	}, {
+		name:       "not started PipelineRun",
+		prName:     "pr4",
+		step:       "2",
+		user:       &user.DefaultInfo{Name: "alice"},
+		wantStatus: http.StatusBadRequest,
+	}, {
+		name:       "not found step",
+		prName:     "pr1",
+		step:       "4",
+		user:       &user.DefaultInfo{Name: "alice"},
+		wantStatus: http.StatusNotFound,
+	}, {
+		name:       "not an input step",
+		prName:     "pr1",
+		step:       "3",
+		user:       &user.DefaultInfo{Name: "alice"},
+		wantStatus: http.StatusBadRequest,
+	}, {
+		name:       "not a submitter",
+		prName:     "pr1",
+		step:       "2",
+		user:       &user.DefaultInfo{Name: "dave", Groups: []string{"developers"}},
+		wantStatus: http.StatusForbidden,
+	}, {
+		name:       "anonymous",
+		prName:     "pr1",
+		step:       "2",
+		wantStatus: http.StatusForbidden,
+	}, {
+		name:       "proceed by a submitter",
+		prName:     "pr1",
+		step:       "2",
+		user:       &user.DefaultInfo{Name: "alice"},
+		body:       `{"parameters":[{"name":"version","value":"v1.0.0"}]}`,
+		wantStatus: http.StatusOK,
+		wantPath:   []string{"ns", "pipeline", "1", "1", "2"},
+		wantInput: &jenkinsInput{ID: "approve", Parameters: []v1alpha3.Parameter{{
+			Name: "version", Value: "v1.0.0",
+		}}},
+		wantApproval: &v1alpha3.Approval{NodeID: "1", StepID: "2", InputID: "approve", Approver: "alice",
+			Parameters: []v1alpha3.Parameter{{Name: "version", Value: "v1.0.0"}}},
+	}, {
+		name:         "abort by a member of a group",
+		prName:       "pr1",
+		step:         "2",
+		user:         &user.DefaultInfo{Name: "carol", Groups: []string{"release-managers"}},
+		body:         `{"abort":true}`,
+		wantStatus:   http.StatusOK,
+		wantPath:     []string{"ns", "pipeline", "1", "1", "2"},
+		wantInput:    &jenkinsInput{ID: "approve", Abort: true},
+		wantApproval: &v1alpha3.Approval{NodeID: "1", StepID: "2", InputID: "approve", Approver: "carol", Aborted: true},
+	}, {
+		name:         "proceed a multi-branch PipelineRun by a role",
+		prName:       "pr2",
+		step:         "2",
+		user:         &user.DefaultInfo{Name: "bob"},
+		wantStatus:   http.StatusOK,
+		wantPath:     []string{"ns", "pipeline", "main", "1", "1", "2"},
+		wantInput:    &jenkinsInput{ID: "approve"},
+		wantApproval: &v1alpha3.Approval{NodeID: "1", StepID: "2", InputID: "approve", Approver: "bob"},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := fake.NewClientBuilder().WithScheme(schema).WithObjects(pipelineRun.DeepCopy(),
+				multiBranchPipelineRun.DeepCopy(), completedPipelineRun.DeepCopy(), notStartedPipelineRun.DeepCopy(),
+				roleBinding.DeepCopy()).Build()
+			recorder := &inputRecorder{Devops: fakedevops.NewFakeDevops(nil)}
+			handler := newAPIHandler(apiHandlerOption{devopsClient: recorder, client: c, genericClient: c})
+
+			httpRequest, _ := http.NewRequest(http.MethodPost, "/", bytes.NewBufferString(tt.body))
+			httpRequest.Header.Set("Content-Type", "application/json")
+			if tt.user != nil {
+				httpRequest = httpRequest.WithContext(request.WithUser(context.Background(), tt.user))
+			}
+			req := restful.NewRequest(httpRequest)
+			req.PathParameters()["namespace"] = "ns"
+			req.PathParameters()["pipelinerun"] = tt.prName
+			req.PathParameters()["node"] = "1"
+			req.PathParameters()["step"] = tt.step
+			httpRecorder := httptest.NewRecorder()
+			resp := restful.NewResponse(httpRecorder)
+			resp.SetRequestAccepts(restful.MIME_JSON)
+
+			handler.submitInputStep(req, resp)
+			assert.Equal(t, tt.wantStatus, httpRecorder.Code, httpRecorder.Body.String())
+			assert.Equal(t, tt.wantPath, recorder.path)
+			assert.Equal(t, tt.wantInput, recorder.input)
+
+			pr := &v1alpha3.PipelineRun{}
+			if err := c.Get(context.Background(), types.NamespacedName{Namespace: "ns", Name: tt.prName}, pr); err != nil {
+				return
+			}
+			if tt.wantApproval == nil {
+				assert.Empty(t, pr.Status.Approvals)
+			} else if assert.Equal(t, 1, len(pr.Status.Approvals)) {
+				approval := pr.Status.Approvals[0]
+				assert.False(t, approval.Time.IsZero())
+				approval.Time = metav1.Time{}
+				assert.Equal(t, *tt.wantApproval, approval)
+			}
+		})
+	}
+}
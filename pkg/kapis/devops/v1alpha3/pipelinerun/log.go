@@ -0,0 +1,340 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinerun
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/emicklei/go-restful"
+	"k8s.io/klog/v2"
+	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
+	"kubesphere.io/devops/pkg/client/devops"
+	"kubesphere.io/devops/pkg/kapis"
+	"kubesphere.io/devops/pkg/models/pipelinerun"
+	s3store "kubesphere.io/devops/pkg/store/s3"
+	"kubesphere.io/devops/pkg/store/store"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// headerTextSize is the header of the offset for reading the next part of a log, it comes from Jenkins
+	headerTextSize = "X-Text-Size"
+	// headerMoreData is the header which indicates that there is more log, it comes from Jenkins
+	headerMoreData = "X-More-Data"
+	// headerLastEventID is the header of the last received event ID of a Server-Sent Events client
+	headerLastEventID = "Last-Event-ID"
+	// mimeEventStream is the content type of Server-Sent Events
+	mimeEventStream = "text/event-stream"
+	// defaultLogPollInterval is the interval of reading the log of a running PipelineRun in the follow mode
+	defaultLogPollInterval = 2 * time.Second
+)
+
+// logChunk is a part of a log which starts from an offset
+type logChunk struct {
+	data []byte
+	// offset is the start of the next chunk
+	offset int64
+	// more indicates if there is more log, it's true until the PipelineRun completes
+	more bool
+}
+
+// logReader reads a chunk of a log from the given offset
+type logReader func(pr *v1alpha3.PipelineRun, start int64) (logChunk, error)
+
+func (h *apiHandler) getLog(request *restful.Request, response *restful.Response) {
+	h.serveLog(request, response, h.readLog)
+}
+
+func (h *apiHandler) getStepLog(request *restful.Request, response *restful.Response) {
+	nodeID := request.PathParameter("node")
+	stepID := request.PathParameter("step")
+	h.serveLog(request, response, func(pr *v1alpha3.PipelineRun, start int64) (logChunk, error) {
+		return h.readStepLog(pr, nodeID, stepID, start)
+	})
+}
+
+// serveLog writes a log once, or keeps writing it until the PipelineRun completes if the follow mode is on
+func (h *apiHandler) serveLog(request *restful.Request, response *restful.Response, read logReader) {
+	ctx := request.Request.Context()
+	key := client.ObjectKey{Namespace: request.PathParameter("namespace"), Name: request.PathParameter("pipelinerun")}
+	pr := &v1alpha3.PipelineRun{}
+	if err := h.client.Get(ctx, key, pr); err != nil {
+		kapis.HandleError(request, response, err)
+		return
+	}
+
+	start, err := getLogStart(request)
+	if err != nil {
+		kapis.HandleBadRequest(response, request, err)
+		return
+	}
+
+	if follow, _ := strconv.ParseBool(request.QueryParameter("follow")); !follow {
+		var chunk logChunk
+		if chunk, err = read(pr, start); err != nil {
+			kapis.HandleError(request, response, err)
+			return
+		}
+		response.AddHeader(headerTextSize, strconv.FormatInt(chunk.offset, 10))
+		response.AddHeader(headerMoreData, strconv.FormatBool(chunk.more))
+		response.AddHeader(restful.HEADER_ContentType, "text/plain; charset=utf-8")
+		_, _ = response.Write(chunk.data)
+		return
+	}
+
+	sse := strings.Contains(request.HeaderParameter(restful.HEADER_Accept), mimeEventStream)
+	if sse {
+		response.AddHeader(restful.HEADER_ContentType, mimeEventStream)
+	} else {
+		response.AddHeader(restful.HEADER_ContentType, "text/plain; charset=utf-8")
+	}
+	response.AddHeader("Cache-Control", "no-cache")
+	response.WriteHeader(http.StatusOK)
+
+	for {
+		var chunk logChunk
+		if chunk, err = read(pr, start); err != nil {
+			klog.V(4).Infof("failed to read the log of PipelineRun: %s, error: %v", key, err)
+			if sse {
+				writeEvent(response, "error", "", []byte(err.Error()))
+			}
+			return
+		}
+		if len(chunk.data) > 0 {
+			if sse {
+				writeEvent(response, "", strconv.FormatInt(chunk.offset, 10), chunk.data)
+			} else {
+				_, _ = response.Write(chunk.data)
+			}
+			response.Flush()
+		}
+		start = chunk.offset
+		if !chunk.more {
+			if sse {
+				writeEvent(response, "end", strconv.FormatInt(start, 10), nil)
+			}
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(h.getLogPollInterval()):
+		}
+		if err = h.client.Get(ctx, key, pr); err != nil {
+			klog.V(4).Infof("failed to get PipelineRun: %s, error: %v", key, err)
+			return
+		}
+	}
+}
+
+// readLog reads the log of a PipelineRun from Jenkins, the archived log is the fallback
+func (h *apiHandler) readLog(pr *v1alpha3.PipelineRun, start int64) (chunk logChunk, err error) {
+	return h.readLogWithFallback(pr, start, func(runID string) (chunk logChunk, err error) {
+		var data []byte
+		var header http.Header
+		if pr.Spec.IsMultiBranchPipeline() {
+			data, header, err = h.devopsClient.GetBranchRunLogWithHeader(pr.Namespace, pr.Spec.PipelineRef.Name,
+				pr.GetRefName(), runID, newLogParameters(start))
+		} else {
+			data, header, err = h.devopsClient.GetRunLogWithHeader(pr.Namespace, pr.Spec.PipelineRef.Name, runID,
+				newLogParameters(start))
+		}
+		if err == nil {
+			chunk = newJenkinsChunk(pr, data, header, start)
+		}
+		return
+	}, func(s3Store store.S3Store) string {
+		return s3Store.GetAllLog()
+	})
+}
+
+// readStepLog reads the log of a step from Jenkins, the archived log is the fallback
+func (h *apiHandler) readStepLog(pr *v1alpha3.PipelineRun, nodeID, stepID string, start int64) (chunk logChunk, err error) {
+	return h.readLogWithFallback(pr, start, func(runID string) (chunk logChunk, err error) {
+		var data []byte
+		var header http.Header
+		if pr.Spec.IsMultiBranchPipeline() {
+			data, header, err = h.devopsClient.GetBranchStepLog(pr.Namespace, pr.Spec.PipelineRef.Name, pr.GetRefName(),
+				runID, nodeID, stepID, newLogParameters(start))
+		} else {
+			data, header, err = h.devopsClient.GetStepLog(pr.Namespace, pr.Spec.PipelineRef.Name, runID, nodeID, stepID,
+				newLogParameters(start))
+		}
+		if err == nil {
+			chunk = newJenkinsChunk(pr, data, header, start)
+		}
+		return
+	}, func(s3Store store.S3Store) string {
+		var stages []pipelinerun.NodeDetail
+		if err := json.Unmarshal([]byte(s3Store.GetStages()), &stages); err == nil {
+			if stage, step, ok := findStep(stages, nodeID, stepID); ok {
+				return s3Store.GetStepLog(stage, step)
+			}
+		}
+		return ""
+	})
+}
+
+// newJenkinsChunk creates a chunk of the log read from Jenkins. The offset comes from the header X-Text-Size,
+// it differs from the length of the data because Jenkins strips the console notes.
+func newJenkinsChunk(pr *v1alpha3.PipelineRun, data []byte, header http.Header, start int64) (chunk logChunk) {
+	chunk = logChunk{data: data, offset: start + int64(len(data)), more: !pr.HasCompleted()}
+	if offset, err := strconv.ParseInt(header.Get(headerTextSize), 10, 64); err == nil {
+		chunk.offset = offset
+	}
+	if moreData := header.Get(headerMoreData); moreData != "" {
+		chunk.more = moreData == "true"
+	}
+	return
+}
+
+// readLogWithFallback reads a log from Jenkins, then from the archived data store if Jenkins cannot provide it.
+// The archived log goes first once the PipelineRun was archived, because Jenkins might have discarded it.
+func (h *apiHandler) readLogWithFallback(pr *v1alpha3.PipelineRun, start int64,
+	readFromJenkins func(runID string) (logChunk, error), readFromStore func(store.S3Store) string) (
+	chunk logChunk, err error) {
+	runID, started := pr.GetPipelineRunID()
+	if !started {
+		// nothing to read until the PipelineRun starts
+		chunk = logChunk{offset: start, more: !pr.HasCompleted()}
+		return
+	}
+
+	archived := pr.Annotations[v1alpha3.PipelineRunArchivedAnnoKey] == "true"
+	s3Store := h.getArchivedStore(pr)
+	if archived && s3Store != nil {
+		if log := readFromStore(s3Store); log != "" {
+			chunk = newArchivedChunk(log, start)
+			return
+		}
+	}
+
+	if h.devopsClient != nil && pr.GetEngineType() != v1alpha3.KubernetesEngine &&
+		pr.Spec.PipelineRef != nil && pr.Spec.PipelineRef.Name != "" {
+		if chunk, err = readFromJenkins(runID); err == nil {
+			return
+		}
+	} else {
+		err = restful.NewError(http.StatusNotFound, fmt.Sprintf("no log found from PipelineRun: %s/%s", pr.Namespace, pr.Name))
+	}
+
+	if s3Store != nil && !archived {
+		if log := readFromStore(s3Store); log != "" {
+			chunk, err = newArchivedChunk(log, start), nil
+		}
+	}
+	return
+}
+
+// newArchivedChunk returns the chunk of an archived log from the offset
+func newArchivedChunk(log string, start int64) logChunk {
+	chunk := logChunk{offset: int64(len(log))}
+	if start < chunk.offset {
+		chunk.data = []byte(log[start:])
+	}
+	return chunk
+}
+
+func newLogParameters(start int64) *devops.HttpParameters {
+	return &devops.HttpParameters{
+		Method: http.MethodGet,
+		Url:    &url.URL{RawQuery: url.Values{"start": []string{strconv.FormatInt(start, 10)}}.Encode()},
+	}
+}
+
+// getLogStart returns the offset of reading a log, the ID of the last event is the fallback
+func getLogStart(request *restful.Request) (start int64, err error) {
+	startParam := request.QueryParameter("start")
+	if startParam == "" {
+		startParam = request.HeaderParameter(headerLastEventID)
+	}
+	if startParam != "" {
+		if start, err = strconv.ParseInt(startParam, 10, 64); err == nil && start < 0 {
+			err = fmt.Errorf("invalid start: %d", start)
+		}
+	}
+	return
+}
+
+// writeEvent writes an event of Server-Sent Events, every line of the data has a prefix
+func writeEvent(response *restful.Response, event, id string, data []byte) {
+	buf := &strings.Builder{}
+	if event != "" {
+		buf.WriteString("event: " + event + "\n")
+	}
+	if id != "" {
+		buf.WriteString("id: " + id + "\n")
+	}
+	for _, line := range strings.Split(strings.TrimSuffix(string(data), "\n"), "\n") {
+		buf.WriteString("data: " + line + "\n")
+	}
+	buf.WriteString("\n")
+	_, _ = response.Write([]byte(buf.String()))
+}
+
+func (h *apiHandler) getLogPollInterval() time.Duration {
+	if h.logPollInterval > 0 {
+		return h.logPollInterval
+	}
+	return defaultLogPollInterval
+}
+
+// getArchivedStore returns the S3 data store of a PipelineRun, or nil if it's not available
+func (h *apiHandler) getArchivedStore(pr *v1alpha3.PipelineRun) store.S3Store {
+	if h.s3Client == nil {
+		return nil
+	}
+	s3Store, err := s3store.NewS3Store(client.ObjectKeyFromObject(pr), h.s3Client)
+	if err != nil {
+		klog.V(4).Infof("failed to create the S3 store of PipelineRun: %s/%s, error: %v", pr.Namespace, pr.Name, err)
+		return nil
+	}
+	return s3Store
+}
+
+// getArchivedStages returns the archived stages of a PipelineRun, it's an empty array if there is no one
+func (h *apiHandler) getArchivedStages(pr *v1alpha3.PipelineRun) (stages string) {
+	if s3Store := h.getArchivedStore(pr); s3Store != nil {
+		stages = s3Store.GetStages()
+	}
+	if stages == "" {
+		stages = "[]"
+	}
+	return
+}
+
+// findStep returns the indexes of a step by the IDs of the node and step
+func findStep(stages []pipelinerun.NodeDetail, nodeID, stepID string) (stage, step int, ok bool) {
+	for i := range stages {
+		if stages[i].ID != nodeID {
+			continue
+		}
+		for j := range stages[i].Steps {
+			if stages[i].Steps[j].ID == stepID {
+				return i, j, true
+			}
+		}
+	}
+	return
+}
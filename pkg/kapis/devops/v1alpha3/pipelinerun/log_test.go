@@ -0,0 +1,281 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinerun
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/emicklei/go-restful"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
+	"kubesphere.io/devops/pkg/apiserver/runtime"
+	"kubesphere.io/devops/pkg/client/devops"
+	fakedevops "kubesphere.io/devops/pkg/client/devops/fake"
+	fakes3 "kubesphere.io/devops/pkg/client/s3/fake"
+	s3store "kubesphere.io/devops/pkg/store/s3"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// logDevops serves the logs like Jenkins does
+type logDevops struct {
+	*fakedevops.Devops
+	runLog string
+	// stepLogs are the logs of a running step, the last one is the completed log
+	stepLogs []string
+	calls    int
+	err      error
+}
+
+// fakeConsoleNote is stripped from the run log like Jenkins strips the console notes
+const fakeConsoleNote = "\x1b[8mha:note\x1b[0m"
+
+func (d *logDevops) GetRunLogWithHeader(_, _, _ string, httpParameters *devops.HttpParameters) ([]byte, http.Header, error) {
+	if d.err != nil {
+		return nil, nil, d.err
+	}
+	start, _ := strconv.Atoi(httpParameters.Url.Query().Get("start"))
+	header := http.Header{}
+	header.Set(headerTextSize, strconv.Itoa(len(d.runLog)))
+	return []byte(strings.ReplaceAll(d.runLog[start:], fakeConsoleNote, "")), header, nil
+}
+
+func (d *logDevops) GetStepLog(_, _, _, _, _ string, httpParameters *devops.HttpParameters) ([]byte, http.Header, error) {
+	if d.err != nil {
+		return nil, nil, d.err
+	}
+	start, _ := strconv.Atoi(httpParameters.Url.Query().Get("start"))
+	log := d.stepLogs[d.calls]
+	header := http.Header{}
+	header.Set(headerTextSize, strconv.Itoa(len(log)))
+	header.Set(headerMoreData, strconv.FormatBool(d.calls < len(d.stepLogs)-1))
+	if d.calls < len(d.stepLogs)-1 {
+		d.calls++
+	}
+	return []byte(log[start:]), header, nil
+}
+
+func TestPipelineRunLogs(t *testing.T) {
+	schema, err := v1alpha3.SchemeBuilder.Register().Build()
+	assert.Nil(t, err)
+
+	newPipelineRun := func(name string, started bool, annotations ...string) *v1alpha3.PipelineRun {
+		pr := &v1alpha3.PipelineRun{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: name, Annotations: map[string]string{}},
+			Spec:       v1alpha3.PipelineRunSpec{PipelineRef: &v1.ObjectReference{Name: "pipeline"}},
+		}
+		if started {
+			pr.Annotations[v1alpha3.JenkinsPipelineRunIDAnnoKey] = "1"
+		}
+		for i := 0; i+1 < len(annotations); i += 2 {
+			pr.Annotations[annotations[i]] = annotations[i+1]
+		}
+		return pr
+	}
+	completedRun := newPipelineRun("completed", true)
+	now := metav1.Now()
+	completedRun.Status.CompletionTime = &now
+
+	fakeS3 := fakes3.NewFakeS3()
+	for _, name := range []string{"archived", "running"} {
+		store, err := s3store.NewS3Store(client.ObjectKey{Namespace: "ns", Name: name}, fakeS3)
+		assert.Nil(t, err)
+		store.SetStages(`[{"id":"1","steps":[{"id":"2"},{"id":"3"}]}]`)
+		store.SetAllLog("archived log\n")
+		store.SetStepLog(0, 1, "archived log of step 3\n")
+		assert.Nil(t, store.Save())
+	}
+
+	tests := []struct {
+		name          string
+		devops        *logDevops
+		uri           string
+		header        http.Header
+		wantStatus    int
+		wantBody      string
+		wantTextSize  string
+		wantMoreData  string
+		wantEventType bool
+	}{{
+		name:         "read the log of a running PipelineRun from Jenkins",
+		devops:       &logDevops{runLog: "line1\nline2\n"},
+		uri:          "/namespaces/ns/pipelineruns/running/log",
+		wantStatus:   http.StatusOK,
+		wantBody:     "line1\nline2\n",
+		wantTextSize: "12",
+		wantMoreData: "true",
+	}, {
+		name:         "read the log from an offset",
+		devops:       &logDevops{runLog: "line1\nline2\n"},
+		uri:          "/namespaces/ns/pipelineruns/completed/log?start=6",
+		wantStatus:   http.StatusOK,
+		wantBody:     "line2\n",
+		wantTextSize: "12",
+		wantMoreData: "false",
+	}, {
+		name:         "the offset of a log with console notes",
+		devops:       &logDevops{runLog: fakeConsoleNote + "line1\n"},
+		uri:          "/namespaces/ns/pipelineruns/running/log",
+		wantStatus:   http.StatusOK,
+		wantBody:     "line1\n",
+		wantTextSize: strconv.Itoa(len(fakeConsoleNote) + 6),
+		wantMoreData: "true",
+	}, {
+		name:         "read the log of a step from Jenkins",
+		devops:       &logDevops{stepLogs: []string{"step\n"}},
+		uri:          "/namespaces/ns/pipelineruns/running/nodes/1/steps/2/log",
+		wantStatus:   http.StatusOK,
+		wantBody:     "step\n",
+		wantTextSize: "5",
+		wantMoreData: "false",
+	}, {
+		name:         "read the archived log once it was archived",
+		devops:       &logDevops{runLog: "line1\n"},
+		uri:          "/namespaces/ns/pipelineruns/archived/log?start=9",
+		wantStatus:   http.StatusOK,
+		wantBody:     "log\n",
+		wantTextSize: "13",
+		wantMoreData: "false",
+	}, {
+		name:         "read the archived log of a step",
+		devops:       &logDevops{err: errors.New("fake")},
+		uri:          "/namespaces/ns/pipelineruns/archived/nodes/1/steps/3/log",
+		wantStatus:   http.StatusOK,
+		wantBody:     "archived log of step 3\n",
+		wantTextSize: "23",
+		wantMoreData: "false",
+	}, {
+		name:         "fall back to the archived log if Jenkins discarded it",
+		devops:       &logDevops{err: errors.New("fake")},
+		uri:          "/namespaces/ns/pipelineruns/running/log",
+		wantStatus:   http.StatusOK,
+		wantBody:     "archived log\n",
+		wantTextSize: "13",
+		wantMoreData: "false",
+	}, {
+		name:       "no log found from Jenkins or the data store",
+		devops:     &logDevops{err: errors.New("fake")},
+		uri:        "/namespaces/ns/pipelineruns/completed/log",
+		wantStatus: http.StatusInternalServerError,
+	}, {
+		name:       "no log of a PipelineRun which runs on Kubernetes",
+		devops:     &logDevops{},
+		uri:        "/namespaces/ns/pipelineruns/kubernetes/log",
+		wantStatus: http.StatusNotFound,
+	}, {
+		name:         "a pending PipelineRun",
+		devops:       &logDevops{},
+		uri:          "/namespaces/ns/pipelineruns/pending/log",
+		wantStatus:   http.StatusOK,
+		wantTextSize: "0",
+		wantMoreData: "true",
+	}, {
+		name:       "invalid offset",
+		devops:     &logDevops{},
+		uri:        "/namespaces/ns/pipelineruns/running/log?start=-1",
+		wantStatus: http.StatusBadRequest,
+	}, {
+		name:       "PipelineRun does not exist",
+		devops:     &logDevops{},
+		uri:        "/namespaces/ns/pipelineruns/fake/log",
+		wantStatus: http.StatusNotFound,
+	}, {
+		name:       "follow the log of a step",
+		devops:     &logDevops{stepLogs: []string{"a\n", "a\nb\n", "a\nb\nc\n"}},
+		uri:        "/namespaces/ns/pipelineruns/running/nodes/1/steps/2/log?follow=true",
+		wantStatus: http.StatusOK,
+		wantBody:   "a\nb\nc\n",
+	}, {
+		name:          "follow the log of a step as Server-Sent Events",
+		devops:        &logDevops{stepLogs: []string{"a\n", "a\nb\nc\n"}},
+		uri:           "/namespaces/ns/pipelineruns/running/nodes/1/steps/2/log?follow=true",
+		header:        http.Header{"Accept": []string{mimeEventStream}},
+		wantStatus:    http.StatusOK,
+		wantBody:      "id: 2\ndata: a\n\nid: 6\ndata: b\ndata: c\n\nevent: end\nid: 6\ndata: \n\n",
+		wantEventType: true,
+	}, {
+		name:          "resume the events from the last event ID",
+		devops:        &logDevops{stepLogs: []string{"a\nb\nc\n"}},
+		uri:           "/namespaces/ns/pipelineruns/running/nodes/1/steps/2/log?follow=true",
+		header:        http.Header{"Accept": []string{mimeEventStream}, headerLastEventID: []string{"4"}},
+		wantStatus:    http.StatusOK,
+		wantBody:      "id: 6\ndata: c\n\nevent: end\nid: 6\ndata: \n\n",
+		wantEventType: true,
+	}, {
+		name:       "follow the log of a completed PipelineRun",
+		devops:     &logDevops{runLog: "line1\n"},
+		uri:        "/namespaces/ns/pipelineruns/completed/log?follow=true",
+		wantStatus: http.StatusOK,
+		wantBody:   "line1\n",
+	}, {
+		name:       "get the archived stages",
+		devops:     &logDevops{},
+		uri:        "/namespaces/ns/pipelineruns/archived/nodedetails",
+		wantStatus: http.StatusOK,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.devops.Devops = fakedevops.NewFakeDevops(nil)
+			ws := runtime.NewWebService(v1alpha3.GroupVersion)
+			registerRoutes(ws, newAPIHandler(apiHandlerOption{
+				devopsClient: tt.devops,
+				client: fake.NewClientBuilder().WithScheme(schema).WithObjects(
+					newPipelineRun("running", true),
+					newPipelineRun("pending", false),
+					newPipelineRun("archived", true, v1alpha3.PipelineRunArchivedAnnoKey, "true"),
+					newPipelineRun("kubernetes", true, v1alpha3.PipelineRunEngineAnnoKey, string(v1alpha3.KubernetesEngine)),
+					completedRun.DeepCopy()).Build(),
+				s3Client:        fakeS3,
+				logPollInterval: time.Millisecond,
+			}))
+			container := restful.NewContainer()
+			container.Add(ws)
+
+			httpRequest, _ := http.NewRequest(http.MethodGet,
+				"http://fake.com/kapis/devops.kubesphere.io/v1alpha3"+tt.uri, nil)
+			for key, values := range tt.header {
+				httpRequest.Header[http.CanonicalHeaderKey(key)] = values
+			}
+			httpWriter := httptest.NewRecorder()
+			container.Dispatch(httpWriter, httpRequest)
+			assert.Equal(t, tt.wantStatus, httpWriter.Code)
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			body, err := io.ReadAll(httpWriter.Body)
+			assert.Nil(t, err)
+			if tt.wantBody != "" {
+				assert.Equal(t, tt.wantBody, string(body))
+			}
+			assert.Equal(t, tt.wantTextSize, httpWriter.Header().Get(headerTextSize))
+			assert.Equal(t, tt.wantMoreData, httpWriter.Header().Get(headerMoreData))
+			if tt.wantEventType {
+				assert.Equal(t, mimeEventStream, httpWriter.Header().Get(restful.HEADER_ContentType))
+			}
+		})
+	}
+}
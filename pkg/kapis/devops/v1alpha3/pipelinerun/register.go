@@ -29,16 +29,23 @@ import (
 	"kubesphere.io/devops/pkg/api"
 	"kubesphere.io/devops/pkg/client/devops"
 	devopsClient "kubesphere.io/devops/pkg/client/devops"
+	"kubesphere.io/devops/pkg/client/s3"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 // RegisterRoutes register routes into web service.
-func RegisterRoutes(ws *restful.WebService, devopsClient devopsClient.Interface, c client.Client) {
+// The genericClient is the own client of the server, and the client could be an impersonating client of the request user.
+func RegisterRoutes(ws *restful.WebService, devopsClient devopsClient.Interface, genericClient, c client.Client, s3Client s3.Interface) {
 	handler := newAPIHandler(apiHandlerOption{
-		devopsClient: devopsClient,
-		client:       c,
+		devopsClient:  devopsClient,
+		client:        c,
+		genericClient: genericClient,
+		s3Client:      s3Client,
 	})
+	registerRoutes(ws, handler)
+}
 
+func registerRoutes(ws *restful.WebService, handler *apiHandler) {
 	ws.Route(ws.GET("/namespaces/{namespace}/pipelines/{pipeline}/pipelineruns").
 		To(handler.listPipelineRuns).
 		Doc("Get all runs of the specified pipeline").
@@ -68,6 +75,23 @@ func RegisterRoutes(ws *restful.WebService, devopsClient devopsClient.Interface,
 		Param(ws.PathParameter("pipelinerun", "Name of the PipelineRun")).
 		Returns(http.StatusOK, api.StatusOK, v1alpha3.PipelineRun{}))
 
+	ws.Route(ws.PUT("/namespaces/{namespace}/pipelineruns/{pipelinerun}/action").
+		To(handler.setPipelineRunAction).
+		Doc("Set the action of a PipelineRun, such as Stop, Pause and Resume").
+		Param(ws.PathParameter("namespace", "Namespace of the PipelineRun")).
+		Param(ws.PathParameter("pipelinerun", "Name of the PipelineRun")).
+		Reads(actionPayload{}).
+		Returns(http.StatusOK, api.StatusOK, v1alpha3.PipelineRun{}))
+
+	ws.Route(ws.POST("/namespaces/{namespace}/pipelineruns/{pipelinerun}/rerun").
+		To(handler.rerunPipelineRun).
+		Doc("Rerun a completed PipelineRun from the beginning or a stage. A new PipelineRun is created, "+
+			"it's linked to the original one by the labels of the root, parent and attempt").
+		Param(ws.PathParameter("namespace", "Namespace of the PipelineRun")).
+		Param(ws.PathParameter("pipelinerun", "Name of the PipelineRun")).
+		Reads(rerunPayload{}).
+		Returns(http.StatusOK, api.StatusOK, v1alpha3.PipelineRun{}))
+
 	ws.Route(ws.GET("/namespaces/{namespace}/pipelineruns/{pipelinerun}/nodedetails").
 		To(handler.getNodeDetails).
 		Doc("Get node details including steps and approvable for a given Pipeline").
@@ -75,6 +99,45 @@ func RegisterRoutes(ws *restful.WebService, devopsClient devopsClient.Interface,
 		Param(ws.PathParameter("pipelinerun", "Name of the PipelineRun")).
 		Returns(http.StatusOK, api.StatusOK, []pipelinerun.NodeDetail{}))
 
+	ws.Route(ws.POST("/namespaces/{namespace}/pipelineruns/{pipelinerun}/nodes/{node}/steps/{step}/input").
+		To(handler.submitInputStep).
+		Doc("Proceed or abort an input step of a PipelineRun. Only the submitters of the input are able to do it, "+
+			"a submitter could be a user, group or role of the DevOps project").
+		Param(ws.PathParameter("namespace", "Namespace of the PipelineRun")).
+		Param(ws.PathParameter("pipelinerun", "Name of the PipelineRun")).
+		Param(ws.PathParameter("node", "ID of the node")).
+		Param(ws.PathParameter("step", "ID of the step")).
+		Reads(inputPayload{}).
+		Returns(http.StatusOK, api.StatusOK, v1alpha3.PipelineRun{}))
+
+	ws.Route(ws.GET("/namespaces/{namespace}/pipelineruns/{pipelinerun}/log").
+		To(handler.getLog).
+		Doc("Get the log of a PipelineRun. It comes from the archived data store once the run history was discarded").
+		Param(ws.PathParameter("namespace", "Namespace of the PipelineRun")).
+		Param(ws.PathParameter("pipelinerun", "Name of the PipelineRun")).
+		Param(ws.QueryParameter("start", "The offset of the log, it comes from the header X-Text-Size of the "+
+			"previous response").DataType("integer").DefaultValue("0")).
+		Param(ws.QueryParameter("follow", "Keep streaming the log until the PipelineRun completes. "+
+			"It's in the format of Server-Sent Events if the Accept header is text/event-stream").
+			DataType("bool").DefaultValue("false")).
+		Produces("text/plain", mimeEventStream).
+		Returns(http.StatusOK, api.StatusOK, ""))
+
+	ws.Route(ws.GET("/namespaces/{namespace}/pipelineruns/{pipelinerun}/nodes/{node}/steps/{step}/log").
+		To(handler.getStepLog).
+		Doc("Get the log of a step. It comes from the archived data store once the run history was discarded").
+		Param(ws.PathParameter("namespace", "Namespace of the PipelineRun")).
+		Param(ws.PathParameter("pipelinerun", "Name of the PipelineRun")).
+		Param(ws.PathParameter("node", "ID of the node")).
+		Param(ws.PathParameter("step", "ID of the step")).
+		Param(ws.QueryParameter("start", "The offset of the log, it comes from the header X-Text-Size of the "+
+			"previous response").DataType("integer").DefaultValue("0")).
+		Param(ws.QueryParameter("follow", "Keep streaming the log until the step completes. "+
+			"It's in the format of Server-Sent Events if the Accept header is text/event-stream").
+			DataType("bool").DefaultValue("false")).
+		Produces("text/plain", mimeEventStream).
+		Returns(http.StatusOK, api.StatusOK, ""))
+
 	// download PipelineRun artifact
 	ws.Route(ws.GET("/namespaces/{namespace}/pipelineruns/{pipelinerun}/artifacts/download").
 		Param(ws.PathParameter("namespace", "Namespace of the PipelineRun")).
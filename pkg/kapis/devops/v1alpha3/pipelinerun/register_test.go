@@ -36,7 +36,8 @@ func TestAPIsExist(t *testing.T) {
 	schema, err := v1alpha1.SchemeBuilder.Register().Build()
 	assert.Nil(t, err)
 
-	RegisterRoutes(wsWithGroup, fakedevops.NewFakeDevops(nil), fake.NewFakeClientWithScheme(schema))
+	c := fake.NewFakeClientWithScheme(schema)
+	RegisterRoutes(wsWithGroup, fakedevops.NewFakeDevops(nil), c, c, nil)
 	restful.DefaultContainer.Add(wsWithGroup)
 
 	type args struct {
@@ -64,12 +65,30 @@ func TestAPIsExist(t *testing.T) {
 			method: http.MethodGet,
 			uri:    "/namespaces/fake/pipelineruns/fake",
 		},
+	}, {
+		name: "set the action of a pipelinerun",
+		args: args{
+			method: http.MethodPut,
+			uri:    "/namespaces/fake/pipelineruns/fake/action",
+		},
 	}, {
 		name: "get node details",
 		args: args{
 			method: http.MethodGet,
 			uri:    "/namespaces/fake/pipelineruns/fake/nodedetails",
 		},
+	}, {
+		name: "rerun a pipelinerun",
+		args: args{
+			method: http.MethodPost,
+			uri:    "/namespaces/fake/pipelineruns/fake/rerun",
+		},
+	}, {
+		name: "submit an input step",
+		args: args{
+			method: http.MethodPost,
+			uri:    "/namespaces/fake/pipelineruns/fake/nodes/1/steps/2/input",
+		},
 	}, {
 		name: "receive pipeline event",
 		args: args{
@@ -0,0 +1,124 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinerun
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/emicklei/go-restful"
+	"k8s.io/client-go/util/retry"
+	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
+	apiserverrequest "kubesphere.io/devops/pkg/apiserver/request"
+	"kubesphere.io/devops/pkg/kapis"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// rerunPayload is the request body to rerun a PipelineRun.
+type rerunPayload struct {
+	Stage string `json:"stage,omitempty" description:"the name of the stage to restart from, the whole Pipeline runs again if it's empty"`
+}
+
+// rerunPipelineRun creates a new attempt of a completed PipelineRun, it runs from the beginning or the given stage.
+func (h *apiHandler) rerunPipelineRun(request *restful.Request, response *restful.Response) {
+	nsName := request.PathParameter("namespace")
+	prName := request.PathParameter("pipelinerun")
+	ctx := request.Request.Context()
+
+	payload := rerunPayload{}
+	if err := request.ReadEntity(&payload); err != nil && err != io.EOF {
+		kapis.HandleBadRequest(response, request, err)
+		return
+	}
+
+	pr := &v1alpha3.PipelineRun{}
+	if err := h.client.Get(ctx, client.ObjectKey{Namespace: nsName, Name: prName}, pr); err != nil {
+		kapis.HandleError(request, response, err)
+		return
+	}
+	if !pr.HasCompleted() {
+		kapis.HandleBadRequest(response, request, fmt.Errorf("the PipelineRun '%s/%s' has not completed", nsName, prName))
+		return
+	}
+	if payload.Stage != "" {
+		if err := h.validateRestartStage(request, pr, payload.Stage); err != nil {
+			kapis.HandleBadRequest(response, request, err)
+			return
+		}
+	}
+
+	userInfo, ok := apiserverrequest.UserFrom(ctx)
+	if !ok || userInfo == nil {
+		// should never happen
+		kapis.HandleUnauthorized(response, request, fmt.Errorf("unauthenticated user entered to rerun PipelineRun '%s/%s'", nsName, prName))
+		return
+	}
+
+	// make sure the first attempt is able to be found by the root label
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		latest := &v1alpha3.PipelineRun{}
+		if err := h.client.Get(ctx, client.ObjectKeyFromObject(pr), latest); err != nil {
+			return err
+		}
+		if !latest.LabelAsRoot() {
+			return nil
+		}
+		return h.client.Update(ctx, latest)
+	})
+	if err != nil {
+		kapis.HandleError(request, response, err)
+		return
+	}
+
+	attempt := pr.NewAttempt()
+	if userInfo.GetName() != "" {
+		attempt.Annotations[v1alpha3.PipelineRunCreatorAnnoKey] = userInfo.GetName()
+	}
+	if payload.Stage != "" {
+		attempt.Annotations[v1alpha3.PipelineRunRestartStageAnnoKey] = payload.Stage
+	}
+	if err := h.client.Create(ctx, attempt); err != nil {
+		kapis.HandleError(request, response, err)
+		return
+	}
+	_ = response.WriteEntity(attempt)
+}
+
+// validateRestartStage checks if the PipelineRun is able to be restarted from the stage
+func (h *apiHandler) validateRestartStage(request *restful.Request, pr *v1alpha3.PipelineRun, stage string) error {
+	if engineType := pr.GetEngineType(); engineType != "" && engineType != v1alpha3.JenkinsEngine {
+		return fmt.Errorf("restarting from a stage is not supported by the engine %s", engineType)
+	}
+	if _, ok := pr.GetPipelineRunID(); !ok {
+		return fmt.Errorf("the PipelineRun '%s/%s' has not started", pr.Namespace, pr.Name)
+	}
+
+	stages, err := h.getStages(request.Request.Context(), pr)
+	if err != nil {
+		return err
+	}
+	for i := range stages {
+		if stages[i].DisplayName != stage {
+			continue
+		}
+		if !stages[i].Restartable {
+			return fmt.Errorf("the stage '%s' is not restartable", stage)
+		}
+		return nil
+	}
+	return fmt.Errorf("the stage '%s' is not found", stage)
+}
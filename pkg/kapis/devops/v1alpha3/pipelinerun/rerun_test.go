@@ -0,0 +1,181 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinerun
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/emicklei/go-restful"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
+	"kubesphere.io/devops/pkg/apiserver/request"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestRerunPipelineRun(t *testing.T) {
+	schema, err := v1alpha3.SchemeBuilder.Register().Build()
+	assert.Nil(t, err)
+
+	now := metav1.Now()
+	stages := `[{"id":"6","displayName":"Build","restartable":true},{"id":"12","displayName":"Test"}]`
+	pipelineRun := &v1alpha3.PipelineRun{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "build-abcde",
+			Namespace: "ns",
+			Labels:    map[string]string{v1alpha3.PipelineNameLabelKey: "build"},
+			Annotations: map[string]string{
+				v1alpha3.PipelineRunCreatorAnnoKey:             "admin",
+				v1alpha3.JenkinsPipelineRunIDAnnoKey:           "1",
+				v1alpha3.JenkinsPipelineRunStagesStatusAnnoKey: stages,
+			},
+		},
+		Spec: v1alpha3.PipelineRunSpec{
+			Parameters: []v1alpha3.Parameter{{Name: "version", Value: "v1"}},
+		},
+		Status: v1alpha3.PipelineRunStatus{CompletionTime: &now},
+	}
+
+	runningPipelineRun := pipelineRun.DeepCopy()
+	runningPipelineRun.Name = "build-running"
+	runningPipelineRun.Status.CompletionTime = nil
+
+	kubernetesPipelineRun := pipelineRun.DeepCopy()
+	kubernetesPipelineRun.Name = "build-kubernetes"
+	kubernetesPipelineRun.Annotations[v1alpha3.PipelineRunEngineAnnoKey] = string(v1alpha3.KubernetesEngine)
+
+	retriedPipelineRun := pipelineRun.DeepCopy()
+	retriedPipelineRun.Name = "build-vwxyz"
+	retriedPipelineRun.LabelAsRoot()
+	secondAttempt := retriedPipelineRun.NewAttempt()
+	secondAttempt.Name = "build-vwxyz-fghij"
+	secondAttempt.Annotations = pipelineRun.Annotations
+	secondAttempt.Status.CompletionTime = &now
+
+	tests := []struct {
+		name        string
+		prName      string
+		body        string
+		wantStatus  int
+		wantAttempt string
+		wantRoot    string
+		wantStage   string
+		wantGroup   int
+	}{{
+		name:       "not found PipelineRun",
+		prName:     "fake",
+		wantStatus: http.StatusNotFound,
+	}, {
+		name:       "running PipelineRun",
+		prName:     "build-running",
+		wantStatus: http.StatusBadRequest,
+	}, {
+		name:       "not found stage",
+		prName:     "build-abcde",
+		body:       `{"stage":"Release"}`,
+		wantStatus: http.StatusBadRequest,
+	}, {
+		name:       "not restartable stage",
+		prName:     "build-abcde",
+		body:       `{"stage":"Test"}`,
+		wantStatus: http.StatusBadRequest,
+	}, {
+		name:       "restart a stage with the kubernetes engine",
+		prName:     "build-kubernetes",
+		body:       `{"stage":"Build"}`,
+		wantStatus: http.StatusBadRequest,
+	}, {
+		name:        "rerun the whole Pipeline",
+		prName:      "build-abcde",
+		wantStatus:  http.StatusOK,
+		wantAttempt: "2",
+		wantRoot:    "build-abcde",
+		wantGroup:   2,
+	}, {
+		name:        "restart from a stage",
+		prName:      "build-abcde",
+		body:        `{"stage":"Build"}`,
+		wantStatus:  http.StatusOK,
+		wantAttempt: "2",
+		wantRoot:    "build-abcde",
+		wantStage:   "Build",
+		wantGroup:   2,
+	}, {
+		name:        "rerun an attempt",
+		prName:      "build-vwxyz-fghij",
+		wantStatus:  http.StatusOK,
+		wantAttempt: "3",
+		wantRoot:    "build-vwxyz",
+		wantGroup:   3,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := fake.NewClientBuilder().WithScheme(schema).WithObjects(pipelineRun.DeepCopy(), runningPipelineRun.DeepCopy(),
+				kubernetesPipelineRun.DeepCopy(), retriedPipelineRun.DeepCopy(), secondAttempt.DeepCopy()).Build()
+			handler := newAPIHandler(apiHandlerOption{client: c, genericClient: c})
+
+			httpRequest, _ := http.NewRequest(http.MethodPost, "/", bytes.NewBufferString(tt.body))
+			httpRequest.Header.Set("Content-Type", "application/json")
+			httpRequest = httpRequest.WithContext(request.WithUser(context.Background(), &user.DefaultInfo{Name: "alice"}))
+			req := restful.NewRequest(httpRequest)
+			req.PathParameters()["namespace"] = "ns"
+			req.PathParameters()["pipelinerun"] = tt.prName
+			httpRecorder := httptest.NewRecorder()
+			resp := restful.NewResponse(httpRecorder)
+			resp.SetRequestAccepts(restful.MIME_JSON)
+
+			handler.rerunPipelineRun(req, resp)
+			assert.Equal(t, tt.wantStatus, httpRecorder.Code, httpRecorder.Body.String())
+
+			attempts := &v1alpha3.PipelineRunList{}
+			assert.Nil(t, c.List(context.Background(), attempts, client.InNamespace("ns"),
+				client.MatchingLabels{v1alpha3.PipelineRunParentLabelKey: tt.prName}))
+			if tt.wantStatus != http.StatusOK {
+				assert.Empty(t, attempts.Items)
+				return
+			}
+			if !assert.Equal(t, 1, len(attempts.Items)) {
+				return
+			}
+			attempt := attempts.Items[0]
+			assert.Equal(t, tt.wantAttempt, attempt.Labels[v1alpha3.PipelineRunAttemptLabelKey])
+			assert.Equal(t, tt.wantRoot, attempt.Labels[v1alpha3.PipelineRunRootLabelKey])
+			assert.Equal(t, "build", attempt.Labels[v1alpha3.PipelineNameLabelKey])
+			assert.Equal(t, "alice", attempt.Annotations[v1alpha3.PipelineRunCreatorAnnoKey])
+			assert.Equal(t, tt.wantStage, attempt.Annotations[v1alpha3.PipelineRunRestartStageAnnoKey])
+			assert.Equal(t, pipelineRun.Spec.Parameters, attempt.Spec.Parameters)
+			assert.False(t, attempt.HasStarted())
+
+			// all the attempts could be listed by the root label
+			group := &v1alpha3.PipelineRunList{}
+			assert.Nil(t, c.List(context.Background(), group, client.InNamespace("ns"),
+				client.MatchingLabels{v1alpha3.PipelineRunRootLabelKey: tt.wantRoot}))
+			assert.Equal(t, tt.wantGroup, len(group.Items))
+
+			root := &v1alpha3.PipelineRun{}
+			assert.Nil(t, c.Get(context.Background(), types.NamespacedName{Namespace: "ns", Name: tt.wantRoot}, root))
+			assert.Equal(t, "1", root.Labels[v1alpha3.PipelineRunAttemptLabelKey])
+		})
+	}
+}
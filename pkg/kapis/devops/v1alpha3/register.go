@@ -30,6 +30,8 @@ import (
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
 	"kubesphere.io/devops/pkg/client/k8s"
+	"kubesphere.io/devops/pkg/client/s3"
+	"kubesphere.io/devops/pkg/kapis/devops/v1alpha3/addon"
 	"kubesphere.io/devops/pkg/kapis/devops/v1alpha3/common"
 	"kubesphere.io/devops/pkg/kapis/devops/v1alpha3/pipeline"
 	"kubesphere.io/devops/pkg/kapis/devops/v1alpha3/pipelinerun"
@@ -50,30 +52,41 @@ import (
 //+kubebuilder:rbac:groups=devops.kubesphere.io,resources=devopsprojects,verbs=get;list;update;delete;create;watch
 //+kubebuilder:rbac:groups=devops.kubesphere.io,resources=pipelines,verbs=get;list;update;delete;create;watch
 //+kubebuilder:rbac:groups=devops.kubesphere.io,resources=pipelineruns,verbs=get;list;update;delete;create;watch
+//+kubebuilder:rbac:groups=devops.kubesphere.io,resources=pipelineruns/status,verbs=get;update
+//+kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=rolebindings,verbs=get;list;watch
 
 // GroupVersion describes CRD group and its version.
 var GroupVersion = schema.GroupVersion{Group: api.GroupName, Version: "v1alpha3"}
 
 // AddToContainer adds web service into container.
+// The client is the own one of the server, the webhook deliveries are handled with it because they are anonymous.
+// The handlerClient works for the other handlers, it could be an impersonating client of the request user.
+// The templates are read with the client because the requests are authorized already.
 func AddToContainer(container *restful.Container, devopsClient devopsClient.Interface, k8sClient k8s.Client,
-	client client.Client, tokenIssue token.Issuer, jenkins core.JenkinsCore) (wss []*restful.WebService) {
+	client, handlerClient client.Client, tokenIssue token.Issuer, jenkins core.JenkinsCore,
+	s3Client s3.Interface) (wss []*restful.WebService) {
 
 	services := []*restful.WebService{
 		runtime.NewWebService(v1alpha3.GroupVersion),
 		runtime.NewWebServiceWithoutGroup(v1alpha3.GroupVersion),
 	}
 
+	// the recorder is shared by the services, so the events are aggregated by one broadcaster
+	recorder := webhook.NewEventRecorder(k8sClient.Kubernetes(), client.Scheme())
 	for _, service := range services {
-		registerRoutes(devopsClient, k8sClient, client, service)
-		pipelinerun.RegisterRoutes(service, devopsClient, client)
-		pipeline.RegisterRoutes(service, client)
+		registerRoutes(devopsClient, k8sClient, handlerClient, service)
+		pipelinerun.RegisterRoutes(service, devopsClient, client, handlerClient, s3Client)
+		pipeline.RegisterRoutes(service, handlerClient)
 		template.RegisterRoutes(service, &common.Options{
 			GenericClient: client,
 		})
 		steptemplate.RegisterRoutes(service, &common.Options{
-			GenericClient: client,
+			GenericClient: handlerClient,
+		})
+		addon.RegisterRoutes(service, &common.Options{
+			GenericClient: handlerClient,
 		})
-		webhook.RegisterWebhooks(client, service, tokenIssue, jenkins)
+		webhook.RegisterWebhooks(client, handlerClient, service, tokenIssue, jenkins, s3Client, recorder)
 		container.Add(service)
 	}
 	return services
@@ -46,6 +46,11 @@ func TestAPIsExist(t *testing.T) {
 	err = v1.SchemeBuilder.AddToScheme(schema)
 	assert.Nil(t, err)
 
+	genericClient := fake.NewFakeClientWithScheme(schema, &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "fake", Namespace: "fake",
+		},
+	})
 	container := restful.NewContainer()
 	AddToContainer(container, fakedevops.NewFakeDevops(nil), k8s.NewFakeClientSets(k8sfake.NewSimpleClientset(&v1.Secret{
 		ObjectMeta: metav1.ObjectMeta{
@@ -57,11 +62,7 @@ func TestAPIsExist(t *testing.T) {
 			Status:     v1alpha3.DevOpsProjectStatus{AdminNamespace: "fake"},
 		}, &v1alpha3.Pipeline{
 			ObjectMeta: metav1.ObjectMeta{Namespace: "fake", Name: "fake"},
-		})), fake.NewFakeClientWithScheme(schema, &v1.Secret{
-		ObjectMeta: metav1.ObjectMeta{
-			Name: "fake", Namespace: "fake",
-		},
-	}), &token.FakeIssuer{}, core.JenkinsCore{})
+		})), genericClient, genericClient, &token.FakeIssuer{}, core.JenkinsCore{}, nil)
 
 	type args struct {
 		method string
@@ -261,7 +262,7 @@ func TestGetDevOpsProject(t *testing.T) {
 	schema, err := v1alpha1.SchemeBuilder.Register().Build()
 	assert.Nil(t, err)
 	container := restful.NewContainer()
-
+	genericClient := fake.NewFakeClientWithScheme(schema)
 	AddToContainer(container, fakedevops.NewFakeDevops(nil), k8s.NewFakeClientSets(k8sfake.NewSimpleClientset(), nil, nil, "", nil,
 		fakeclientset.NewSimpleClientset(&v1alpha3.DevOpsProject{
 			ObjectMeta: metav1.ObjectMeta{
@@ -271,7 +272,7 @@ func TestGetDevOpsProject(t *testing.T) {
 					constants.WorkspaceLabelKey: "ws",
 				},
 			},
-		})), fake.NewFakeClientWithScheme(schema), &token.FakeIssuer{}, core.JenkinsCore{})
+		})), genericClient, genericClient, &token.FakeIssuer{}, core.JenkinsCore{}, nil)
 
 	type args struct {
 		method string
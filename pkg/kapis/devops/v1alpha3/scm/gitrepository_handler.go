@@ -17,7 +17,6 @@ limitations under the License.
 package scm
 
 import (
-	"context"
 	"github.com/emicklei/go-restful"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
@@ -33,7 +32,7 @@ func (h *handler) getGitRepository(req *restful.Request, res *restful.Response)
 	repoName := common.GetPathParameter(req, pathParameterGitRepository)
 
 	repo := &v1alpha3.GitRepository{}
-	err := h.Get(context.Background(), types.NamespacedName{
+	err := h.Get(req.Request.Context(), types.NamespacedName{
 		Namespace: namespace,
 		Name:      repoName,
 	}, repo)
@@ -44,7 +43,7 @@ func (h *handler) createGitRepositories(req *restful.Request, res *restful.Respo
 	namespace := common.GetPathParameter(req, common.NamespacePathParameter)
 	repo := &v1alpha3.GitRepository{}
 	err := req.ReadEntity(repo)
-	ctx := context.Background()
+	ctx := req.Request.Context()
 
 	if err == nil {
 		repo.Namespace = namespace
@@ -56,7 +55,7 @@ func (h *handler) createGitRepositories(req *restful.Request, res *restful.Respo
 func (h *handler) listGitRepositories(req *restful.Request, res *restful.Response) {
 	namespace := common.GetPathParameter(req, common.NamespacePathParameter)
 	repoList := &v1alpha3.GitRepositoryList{}
-	if err := h.List(context.Background(), repoList, client.InNamespace(namespace)); err != nil {
+	if err := h.List(req.Request.Context(), repoList, client.InNamespace(namespace)); err != nil {
 		common.Response(req, res, repoList, err)
 		return
 	}
@@ -76,7 +75,7 @@ func toObjects(apps []v1alpha3.GitRepository) []runtime.Object {
 func (h *handler) updateGitRepositories(req *restful.Request, res *restful.Response) {
 	namespace := common.GetPathParameter(req, common.NamespacePathParameter)
 	repoName := common.GetPathParameter(req, pathParameterGitRepository)
-	ctx := context.Background()
+	ctx := req.Request.Context()
 
 	patchRepo := &v1alpha3.GitRepository{}
 	err := req.ReadEntity(patchRepo)
@@ -97,7 +96,7 @@ func (h *handler) updateGitRepositories(req *restful.Request, res *restful.Respo
 func (h *handler) deleteGitRepositories(req *restful.Request, res *restful.Response) {
 	namespace := common.GetPathParameter(req, common.NamespacePathParameter)
 	repoName := common.GetPathParameter(req, pathParameterGitRepository)
-	ctx := context.Background()
+	ctx := req.Request.Context()
 
 	repo := &v1alpha3.GitRepository{}
 	err := h.Get(ctx, types.NamespacedName{
@@ -48,7 +48,7 @@ func (h *handler) verify(request *restful.Request, response *restful.Response) {
 	secretNamespace := request.QueryParameter("secretNamespace")
 	server := common.GetQueryParameter(request, queryParameterServer)
 
-	_, code, err := h.getOrganizations(scm, server, secretName, secretNamespace, 1, 1, false)
+	_, code, err := h.getOrganizations(request.Request.Context(), scm, server, secretName, secretNamespace, 1, 1, false)
 
 	response.Header().Set(restful.HEADER_ContentType, restful.MIME_JSON)
 	verifyResult := git.VerifyResult(err, code)
@@ -56,15 +56,14 @@ func (h *handler) verify(request *restful.Request, response *restful.Response) {
 	_ = response.WriteAsJson(verifyResult)
 }
 
-func (h *handler) getOrganizations(scm, server, secret, namespace string, page, size int, includeUser bool) (orgs []*goscm.Organization, code int, err error) {
+func (h *handler) getOrganizations(ctx context.Context, scm, server, secret, namespace string, page, size int, includeUser bool) (orgs []*goscm.Organization, code int, err error) {
 	factory := git.NewClientFactory(scm, &v1.SecretReference{
 		Namespace: namespace, Name: secret,
 	}, h.Client)
 	factory.Server = server
 
-	ctx := context.Background()
 	var c *goscm.Client
-	if c, err = factory.GetClient(); err == nil {
+	if c, err = factory.GetClientWithContext(ctx); err == nil {
 		var resp *goscm.Response
 
 		if orgs, resp, err = c.Organizations.List(ctx, &goscm.ListOptions{Size: size, Page: page}); err == nil {
@@ -74,11 +73,15 @@ func (h *handler) getOrganizations(scm, server, secret, namespace string, page,
 		}
 
 		if includeUser {
-			var user string
-			if user, err = h.getCurrentUsername(c); err == nil {
+			var user *goscm.User
+			if user, err = h.getCurrentUser(ctx, c); err == nil {
+				avatar := user.Avatar
+				if avatar == "" {
+					avatar = fmt.Sprintf("https://avatars.githubusercontent.com/%s", user.Login)
+				}
 				orgs = append(orgs, &goscm.Organization{
-					Name:   user,
-					Avatar: fmt.Sprintf("https://avatars.githubusercontent.com/%s", user),
+					Name:   user.Login,
+					Avatar: avatar,
 				})
 			}
 		}
@@ -88,18 +91,18 @@ func (h *handler) getOrganizations(scm, server, secret, namespace string, page,
 	return
 }
 
-func (h *handler) getRepositories(scm, server, org, secret, namespace string, page, size int) (repos []*goscm.Repository, code int, err error) {
+func (h *handler) getRepositories(ctx context.Context, scm, server, org, secret, namespace string, page, size int) (repos []*goscm.Repository, code int, err error) {
 	factory := git.NewClientFactory(scm, &v1.SecretReference{
 		Namespace: namespace, Name: secret,
 	}, h.Client)
 	factory.Server = server
 
 	var c *goscm.Client
-	if c, err = factory.GetClient(); err == nil {
+	if c, err = factory.GetClientWithContext(ctx); err == nil {
 		// check if the org name is a user account name
 		var user string
 		var listRepositoryFunc listRepository
-		if user, err = h.getCurrentUsername(c); err == nil {
+		if user, err = h.getCurrentUsername(ctx, c); err == nil {
 			if user == org && !strings.HasPrefix(scm, "bitbucket") {
 				listRepositoryFunc = func(ctx context.Context, s string, options *goscm.ListOptions) ([]*goscm.Repository, *goscm.Response, error) {
 					return c.Repositories.List(ctx, options)
@@ -111,7 +114,6 @@ func (h *handler) getRepositories(scm, server, org, secret, namespace string, pa
 			return
 		}
 
-		ctx := context.Background()
 		if repos, _, err = listRepositoryFunc(ctx, org, &goscm.ListOptions{
 			Page: page,
 			Size: size,
@@ -134,7 +136,7 @@ func (h *handler) listOrganizations(req *restful.Request, rsp *restful.Response)
 	includeUser := common.GetQueryParameter(req, queryParameterIncludeUser) == "true"
 	pageNumber, pageSize := common.GetPageParameters(req)
 
-	orgs, _, err := h.getOrganizations(scm, server, secretName, secretNamespace, pageNumber, pageSize, includeUser)
+	orgs, _, err := h.getOrganizations(req.Request.Context(), scm, server, secretName, secretNamespace, pageNumber, pageSize, includeUser)
 	if err != nil {
 		kapis.HandleError(req, rsp, err)
 	} else {
@@ -150,7 +152,7 @@ func (h *handler) listRepositories(req *restful.Request, rsp *restful.Response)
 	secretNamespace := req.QueryParameter("secretNamespace")
 	pageNumber, pageSize := common.GetPageParameters(req)
 
-	repos, _, err := h.getRepositories(scm, server, organization, secretName, secretNamespace, pageNumber, pageSize)
+	repos, _, err := h.getRepositories(req.Request.Context(), scm, server, organization, secretName, secretNamespace, pageNumber, pageSize)
 	if err != nil {
 		kapis.HandleError(req, rsp, err)
 	} else {
@@ -158,14 +160,19 @@ func (h *handler) listRepositories(req *restful.Request, rsp *restful.Response)
 	}
 }
 
-func (h *handler) getCurrentUsername(c *goscm.Client) (username string, err error) {
+func (h *handler) getCurrentUsername(ctx context.Context, c *goscm.Client) (username string, err error) {
 	var user *goscm.User
-	user, _, err = c.Users.Find(context.Background())
-	if err != nil {
-		return
+	if user, err = h.getCurrentUser(ctx, c); err == nil {
+		username = user.Login
 	}
+	return
+}
 
-	username = user.Login
+func (h *handler) getCurrentUser(ctx context.Context, c *goscm.Client) (user *goscm.User, err error) {
+	if user, _, err = c.Users.Find(ctx); err == nil && user == nil {
+		// some drivers return nothing instead of an error, such as Gitea
+		err = fmt.Errorf("cannot find the current user")
+	}
 	return
 }
 
@@ -309,6 +309,78 @@ func TestSCMAPI(t *testing.T) {
 			assert.Equal(t, "Hello-World", repos.Repositories.Items[0].Name)
 			assert.Equal(t, "master", repos.Repositories.Items[0].DefaultBranch)
 		},
+	}, {
+		name: "get the organization list of Gitea include current user",
+		args: args{
+			method: http.MethodGet,
+			uri:    "/scms/gitea/organizations?secret=token&secretNamespace=default&server=https://gitea.com&includeUser=true",
+		},
+		prepare: func() {
+			gock.New("https://gitea.com").
+				Get("/api/v1/version").
+				Reply(200).
+				JSON(map[string]string{"version": "1.17.0"})
+
+			gock.New("https://gitea.com").
+				Get("/api/v1/user/orgs").
+				Reply(200).
+				Type("application/json").
+				File("testdata/gitea-orgs.json")
+
+			gock.New("https://gitea.com").
+				Get("/api/v1/user").
+				Reply(200).
+				Type("application/json").
+				File("testdata/gitea-user.json")
+		},
+		verify: func(code int, response []byte, t *testing.T) {
+			assert.Equal(t, 200, code)
+
+			var orgs []organization
+			err := json.Unmarshal(response, &orgs)
+			assert.Nil(t, err)
+			assert.Equal(t, []organization{{
+				Name:   "kubesphere",
+				Avatar: "https://gitea.com/avatars/kubesphere",
+			}, {
+				Name:   "linuxsuren",
+				Avatar: "https://gitea.com/avatars/linuxsuren",
+			}}, orgs)
+		},
+	}, {
+		name: "get the repository list of Gitea with the organization name",
+		args: args{
+			method: http.MethodGet,
+			uri:    "/scms/gitea/organizations/kubesphere/repositories?secret=token&secretNamespace=default&server=https://gitea.com",
+		},
+		prepare: func() {
+			gock.New("https://gitea.com").
+				Get("/api/v1/version").
+				Reply(200).
+				JSON(map[string]string{"version": "1.17.0"})
+
+			gock.New("https://gitea.com").
+				Get("/api/v1/orgs/kubesphere/repos").
+				Reply(200).
+				Type("application/json").
+				File("testdata/gitea-repos.json")
+
+			gock.New("https://gitea.com").
+				Get("/api/v1/user").
+				Reply(200).
+				Type("application/json").
+				File("testdata/gitea-user.json")
+		},
+		verify: func(code int, response []byte, t *testing.T) {
+			assert.Equal(t, 200, code)
+
+			var repos repositoryListResult
+			err := json.Unmarshal(response, &repos)
+			assert.Nil(t, err)
+			assert.Equal(t, 1, len(repos.Repositories.Items))
+			assert.Equal(t, "ks-devops", repos.Repositories.Items[0].Name)
+			assert.Equal(t, "master", repos.Repositories.Items[0].DefaultBranch)
+		},
 	}}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -17,7 +17,6 @@ limitations under the License.
 package steptemplate
 
 import (
-	"context"
 	"fmt"
 	"net/http"
 
@@ -31,7 +30,7 @@ import (
 )
 
 func (h *handler) clusterStepTemplates(req *restful.Request, resp *restful.Response) {
-	ctx := context.TODO()
+	ctx := req.Request.Context()
 
 	clusterStepTemplateList := &v1alpha3.ClusterStepTemplateList{}
 	err := h.List(ctx, clusterStepTemplateList)
@@ -51,7 +50,7 @@ func convertToObject(prs []v1alpha3.ClusterStepTemplate) []runtime.Object {
 }
 
 func (h *handler) getClusterStepTemplate(req *restful.Request, resp *restful.Response) {
-	ctx := context.TODO()
+	ctx := req.Request.Context()
 	name := req.PathParameter(ClusterStepTemplate.Data().Name)
 
 	clusterStepTemplate := &v1alpha3.ClusterStepTemplate{}
@@ -60,7 +59,7 @@ func (h *handler) getClusterStepTemplate(req *restful.Request, resp *restful.Res
 }
 
 func (h *handler) renderClusterStepTemplate(req *restful.Request, resp *restful.Response) {
-	ctx := context.TODO()
+	ctx := req.Request.Context()
 	name := req.PathParameter(ClusterStepTemplate.Data().Name)
 
 	var err error
@@ -95,7 +94,7 @@ func (h *handler) getSecret(req *restful.Request) (secret *v1.Secret, err error)
 	secretNamespace := req.QueryParameter(SecretNamespaceQueryParameter.Data().Name)
 	if secretName != "" || secretNamespace != "" {
 		secret = &v1.Secret{}
-		err = h.Get(context.Background(), types.NamespacedName{
+		err = h.Get(req.Request.Context(), types.NamespacedName{
 			Namespace: secretNamespace,
 			Name:      secretName,
 		}, secret)
@@ -43,7 +43,7 @@ func (h *handler) handleRenderClusterTemplate(request *restful.Request, response
 		return
 	}
 
-	kapis.ResponseWriter{Response: response}.WriteEntityOrError(h.renderClusterTemplate(templateName, renderBody.Parameters))
+	renderResultWriter{Response: response}.writeRenderResult(h.renderClusterTemplate(templateName, renderBody.Parameters))
 }
 
 func (h *handler) queryClusterTemplates(commonQuery *query.Query) (*api.ListResult, error) {
@@ -0,0 +1,301 @@
+// Copyright 2022 KubeSphere Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package template
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/emicklei/go-restful"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
+	"kubesphere.io/devops/pkg/kapis"
+)
+
+// parameter types of a template
+const (
+	typeString      = "string"
+	typeBool        = "bool"
+	typeBoolean     = "boolean"
+	typeNumber      = "number"
+	typeInteger     = "integer"
+	typeInt         = "int"
+	typeStringArray = "string-array"
+	typeArray       = "array"
+)
+
+// functionExpression matches a CEL-like function expression, such as: matches('^https://.*$').
+// Expressions in other forms are not supported.
+var functionExpression = regexp.MustCompile(`^(?:self\.)?(matches|startsWith|endsWith|contains)\((.*)\)$`)
+
+// errUnsupportedExpression means that the expression is not one of the supported function calls
+var errUnsupportedExpression = fmt.Errorf("only one of the functions matches, startsWith, endsWith and contains " +
+	"with a quoted argument is supported")
+
+// resolveParameters applies the default values, then checks the required parameters, types and validation expressions.
+// It returns a bad request error with all failing parameters as the causes.
+func resolveParameters(templateObject v1alpha3.TemplateObject, parameters []Parameter) (map[string]interface{}, error) {
+	parameterMap := map[string]interface{}{}
+	for _, parameter := range parameters {
+		parameterMap[parameter.Name] = parameter.Value
+	}
+
+	var causes []metav1.StatusCause
+	for _, definition := range templateObject.TemplateSpec().Parameters {
+		value, ok := parameterMap[definition.Name]
+		if !ok || value == nil {
+			if len(definition.Default.Raw) > 0 {
+				if err := json.Unmarshal(definition.Default.Raw, &value); err != nil {
+					causes = append(causes, newCause(metav1.CauseTypeFieldValueInvalid, definition.Name,
+						fmt.Sprintf("invalid default value: %v", err)))
+					continue
+				}
+			}
+		}
+
+		if value == nil || value == "" {
+			if definition.Required {
+				causes = append(causes, newCause(metav1.CauseTypeFieldValueRequired, definition.Name, "parameter is required"))
+			}
+			continue
+		}
+
+		var err error
+		if value, err = convertType(definition.Type, value); err != nil {
+			causes = append(causes, newCause(metav1.CauseTypeFieldValueInvalid, definition.Name, err.Error()))
+			continue
+		}
+		parameterMap[definition.Name] = value
+
+		if definition.Validation != nil && definition.Validation.Expression != "" {
+			if err = validate(definition.Validation, value); err != nil {
+				causes = append(causes, newCause(metav1.CauseTypeFieldValueInvalid, definition.Name, err.Error()))
+			}
+		}
+	}
+
+	if len(causes) > 0 {
+		return nil, newParametersError(templateObject, causes)
+	}
+	return parameterMap, nil
+}
+
+// convertType checks the type of value, and converts a string value into the expected type if possible.
+// Unknown types are not checked for the sake of compatibility.
+func convertType(parameterType string, value interface{}) (interface{}, error) {
+	switch parameterType {
+	case "", typeString:
+		if _, ok := value.(string); !ok {
+			return nil, fmt.Errorf("expect a string, got %v", value)
+		}
+	case typeBool, typeBoolean:
+		switch val := value.(type) {
+		case bool:
+		case string:
+			boolVal, err := strconv.ParseBool(val)
+			if err != nil {
+				return nil, fmt.Errorf("expect a boolean, got %q", val)
+			}
+			return boolVal, nil
+		default:
+			return nil, fmt.Errorf("expect a boolean, got %v", value)
+		}
+	case typeNumber:
+		switch val := value.(type) {
+		case float64:
+		case string:
+			number, err := strconv.ParseFloat(val, 64)
+			if err != nil {
+				return nil, fmt.Errorf("expect a number, got %q", val)
+			}
+			return number, nil
+		default:
+			return nil, fmt.Errorf("expect a number, got %v", value)
+		}
+	case typeInteger, typeInt:
+		// the integers are kept as int64, otherwise a large one is rendered like 1e+08
+		switch val := value.(type) {
+		case float64:
+			if val != math.Trunc(val) || val < math.MinInt64 || val >= math.MaxInt64 {
+				return nil, fmt.Errorf("expect an integer, got %v", val)
+			}
+			return int64(val), nil
+		case string:
+			integer, err := strconv.ParseInt(val, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("expect an integer, got %q", val)
+			}
+			return integer, nil
+		default:
+			return nil, fmt.Errorf("expect an integer, got %v", value)
+		}
+	case typeStringArray:
+		switch val := value.(type) {
+		case string:
+			// a single string is allowed
+		case []interface{}:
+			for _, item := range val {
+				if _, ok := item.(string); !ok {
+					return nil, fmt.Errorf("expect an array of strings, got an item %v", item)
+				}
+			}
+		default:
+			return nil, fmt.Errorf("expect an array of strings, got %v", value)
+		}
+	case typeArray:
+		if _, ok := value.([]interface{}); !ok {
+			return nil, fmt.Errorf("expect an array, got %v", value)
+		}
+	}
+	return value, nil
+}
+
+// validate evaluates the validation expression against the value, every item is evaluated if it's an array
+func validate(validation *v1alpha3.ParameterValidation, value interface{}) error {
+	values := []interface{}{value}
+	if items, ok := value.([]interface{}); ok {
+		values = items
+	}
+
+	for _, item := range values {
+		ok, err := evaluate(validation.Expression, fmt.Sprint(item))
+		if err != nil {
+			return fmt.Errorf("invalid validation expression %q: %v", validation.Expression, err)
+		}
+		if !ok {
+			if validation.Message != "" {
+				return fmt.Errorf("%s", validation.Message)
+			}
+			return fmt.Errorf("value %q does not match the expression %q", fmt.Sprint(item), validation.Expression)
+		}
+	}
+	return nil
+}
+
+// evaluate supports a small subset of CEL string functions: matches, startsWith, endsWith and contains
+func evaluate(expression, value string) (bool, error) {
+	function, argument, err := parseExpression(expression)
+	if err != nil {
+		return false, err
+	}
+
+	switch function {
+	case "startsWith":
+		return strings.HasPrefix(value, argument), nil
+	case "endsWith":
+		return strings.HasSuffix(value, argument), nil
+	case "contains":
+		return strings.Contains(value, argument), nil
+	}
+	return regexp.MatchString(argument, value)
+}
+
+// CompileExpression checks if a validation expression is able to be evaluated
+func CompileExpression(expression string) error {
+	function, argument, err := parseExpression(expression)
+	if err == nil && function == "matches" {
+		_, err = regexp.Compile(argument)
+	}
+	return err
+}
+
+// parseExpression returns the function and its argument of an expression.
+// It returns an error if the expression is not a single supported function call.
+func parseExpression(expression string) (function, argument string, err error) {
+	groups := functionExpression.FindStringSubmatch(strings.TrimSpace(expression))
+	if groups == nil {
+		return "", "", errUnsupportedExpression
+	}
+	function = groups[1]
+	argument, err = unquote(strings.TrimSpace(groups[2]))
+	return
+}
+
+// unquote removes the quotes of a function argument, a single-quoted argument is taken literally.
+// An argument which is not a single quoted string, such as: 'a') && contains('b', is not supported.
+func unquote(argument string) (string, error) {
+	switch {
+	case argument == "":
+		return "", nil
+	case len(argument) >= 2 && strings.HasPrefix(argument, "'") && strings.HasSuffix(argument, "'"):
+		if literal := argument[1 : len(argument)-1]; !strings.Contains(literal, "'") {
+			return literal, nil
+		}
+		return "", errUnsupportedExpression
+	case strings.HasPrefix(argument, `"`):
+		if literal, err := strconv.Unquote(argument); err == nil {
+			return literal, nil
+		}
+		return "", errUnsupportedExpression
+	}
+	return "", fmt.Errorf("the argument %s should be quoted", argument)
+}
+
+func newCause(causeType metav1.CauseType, name, message string) metav1.StatusCause {
+	return metav1.StatusCause{
+		Type:    causeType,
+		Field:   fmt.Sprintf("%s.%s", parametersKey, name),
+		Message: message,
+	}
+}
+
+func newParametersError(templateObject v1alpha3.TemplateObject, causes []metav1.StatusCause) error {
+	var fields []string
+	for _, cause := range causes {
+		fields = append(fields, cause.Field)
+	}
+
+	kind := "Template"
+	if _, ok := templateObject.(*v1alpha3.ClusterTemplate); ok {
+		kind = "ClusterTemplate"
+	}
+	return &errors.StatusError{ErrStatus: metav1.Status{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "Status",
+			APIVersion: "v1",
+		},
+		Status:  metav1.StatusFailure,
+		Code:    http.StatusBadRequest,
+		Reason:  metav1.StatusReasonBadRequest,
+		Message: fmt.Sprintf("Failed to render template, invalid parameters: %s", strings.Join(fields, ", ")),
+		Details: &metav1.StatusDetails{
+			Name:   templateObject.GetName(),
+			Group:  v1alpha3.GroupVersion.Group,
+			Kind:   kind,
+			Causes: causes,
+		},
+	}}
+}
+
+// renderResultWriter writes the rendered template, or the status with all failing parameters as JSON.
+type renderResultWriter struct {
+	*restful.Response
+}
+
+func (w renderResultWriter) writeRenderResult(templateObject v1alpha3.TemplateObject, err error) {
+	if statusErr, ok := err.(*errors.StatusError); ok && statusErr.ErrStatus.Details != nil &&
+		len(statusErr.ErrStatus.Details.Causes) > 0 {
+		_ = w.WriteHeaderAndEntity(http.StatusBadRequest, statusErr.ErrStatus)
+		return
+	}
+	kapis.ResponseWriter{Response: w.Response}.WriteEntityOrError(templateObject, err)
+}
@@ -0,0 +1,276 @@
+// Copyright 2022 KubeSphere Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package template
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	apiextensionv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
+)
+
+func Test_resolveParameters(t *testing.T) {
+	createTemplate := func(parameters ...v1alpha3.TemplateParameter) v1alpha3.TemplateObject {
+		return &v1alpha3.ClusterTemplate{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "fake-name",
+			},
+			Spec: v1alpha3.TemplateSpec{
+				Parameters: parameters,
+			},
+		}
+	}
+	tests := []struct {
+		name       string
+		template   v1alpha3.TemplateObject
+		parameters []Parameter
+		want       map[string]interface{}
+		wantCauses []metav1.StatusCause
+	}{{
+		name:       "no definitions",
+		template:   createTemplate(),
+		parameters: []Parameter{{Name: "name", Value: "value"}},
+		want:       map[string]interface{}{"name": "value"},
+	}, {
+		name: "apply the default values",
+		template: createTemplate(v1alpha3.TemplateParameter{
+			Name:    "revision",
+			Default: apiextensionv1.JSON{Raw: []byte(`"main"`)},
+		}, v1alpha3.TemplateParameter{
+			Name:    "buildOnly",
+			Type:    "bool",
+			Default: apiextensionv1.JSON{Raw: []byte(`false`)},
+		}, v1alpha3.TemplateParameter{
+			Name:    "matrix",
+			Type:    "string-array",
+			Default: apiextensionv1.JSON{Raw: []byte(`["6.9.1-jdk11", "7.0.0-jdk11"]`)},
+		}),
+		want: map[string]interface{}{
+			"revision":  "main",
+			"buildOnly": false,
+			"matrix":    []interface{}{"6.9.1-jdk11", "7.0.0-jdk11"},
+		},
+	}, {
+		name: "the given value takes precedence over the default one",
+		template: createTemplate(v1alpha3.TemplateParameter{
+			Name:    "revision",
+			Default: apiextensionv1.JSON{Raw: []byte(`"main"`)},
+		}),
+		parameters: []Parameter{{Name: "revision", Value: "release"}},
+		want:       map[string]interface{}{"revision": "release"},
+	}, {
+		name: "convert the string values",
+		template: createTemplate(v1alpha3.TemplateParameter{
+			Name: "buildOnly",
+			Type: "boolean",
+		}, v1alpha3.TemplateParameter{
+			Name: "replicas",
+			Type: "integer",
+		}),
+		parameters: []Parameter{{Name: "buildOnly", Value: "false"}, {Name: "replicas", Value: "3"}},
+		want:       map[string]interface{}{"buildOnly": false, "replicas": int64(3)},
+	}, {
+		name: "list all failing parameters",
+		template: createTemplate(v1alpha3.TemplateParameter{
+			Name:     "gitCloneURL",
+			Required: true,
+		}, v1alpha3.TemplateParameter{
+			Name:     "revision",
+			Required: true,
+		}, v1alpha3.TemplateParameter{
+			Name: "buildOnly",
+			Type: "bool",
+		}, v1alpha3.TemplateParameter{
+			Name: "version",
+			Validation: &v1alpha3.ParameterValidation{
+				Expression: `matches('^v\d+$')`,
+				Message:    "Please input a correct version.",
+			},
+		}, v1alpha3.TemplateParameter{
+			Name:    "invalidDefault",
+			Default: apiextensionv1.JSON{Raw: []byte(`invalid`)},
+		}),
+		parameters: []Parameter{{Name: "revision", Value: ""}, {Name: "buildOnly", Value: "yes"}, {Name: "version", Value: "1.0"}},
+		wantCauses: []metav1.StatusCause{{
+			Type:    metav1.CauseTypeFieldValueRequired,
+			Message: "parameter is required",
+			Field:   "params.gitCloneURL",
+		}, {
+			Type:    metav1.CauseTypeFieldValueRequired,
+			Message: "parameter is required",
+			Field:   "params.revision",
+		}, {
+			Type:    metav1.CauseTypeFieldValueInvalid,
+			Message: `expect a boolean, got "yes"`,
+			Field:   "params.buildOnly",
+		}, {
+			Type:    metav1.CauseTypeFieldValueInvalid,
+			Message: "Please input a correct version.",
+			Field:   "params.version",
+		}, {
+			Type:    metav1.CauseTypeFieldValueInvalid,
+			Message: "invalid default value: invalid character 'i' looking for beginning of value",
+			Field:   "params.invalidDefault",
+		}},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveParameters(tt.template, tt.parameters)
+			if tt.wantCauses == nil {
+				assert.Nil(t, err)
+				assert.Equal(t, tt.want, got)
+				return
+			}
+
+			assert.True(t, errors.IsBadRequest(err))
+			status := err.(errors.APIStatus).Status()
+			assert.Equal(t, "ClusterTemplate", status.Details.Kind)
+			assert.Equal(t, "fake-name", status.Details.Name)
+			assert.Equal(t, tt.wantCauses, status.Details.Causes)
+		})
+	}
+}
+
+func Test_convertType(t *testing.T) {
+	tests := []struct {
+		name          string
+		parameterType string
+		value         interface{}
+		want          interface{}
+		wantErr       bool
+	}{{
+		name:  "string without type",
+		value: "value",
+		want:  "value",
+	}, {
+		name:    "not a string",
+		value:   true,
+		wantErr: true,
+	}, {
+		name:          "boolean",
+		parameterType: "bool",
+		value:         true,
+		want:          true,
+	}, {
+		name:          "not a boolean",
+		parameterType: "bool",
+		value:         1.0,
+		wantErr:       true,
+	}, {
+		name:          "number",
+		parameterType: "number",
+		value:         "1.5",
+		want:          1.5,
+	}, {
+		name:          "integer",
+		parameterType: "integer",
+		value:         1e8,
+		want:          int64(100000000),
+	}, {
+		name:          "integer in a string",
+		parameterType: "int",
+		value:         "100000000",
+		want:          int64(100000000),
+	}, {
+		name:          "not an integer",
+		parameterType: "int",
+		value:         1.5,
+		wantErr:       true,
+	}, {
+		name:          "not an integer in a string",
+		parameterType: "int",
+		value:         "1.5",
+		wantErr:       true,
+	}, {
+		name:          "a single string as a string array",
+		parameterType: "string-array",
+		value:         "7.3.3-jdk11",
+		want:          "7.3.3-jdk11",
+	}, {
+		name:          "not a string array",
+		parameterType: "string-array",
+		value:         []interface{}{"7.3.3-jdk11", 1.0},
+		wantErr:       true,
+	}, {
+		name:          "array",
+		parameterType: "array",
+		value:         []interface{}{"a", 1.0},
+		want:          []interface{}{"a", 1.0},
+	}, {
+		name:          "unknown type",
+		parameterType: "unknown",
+		value:         1.0,
+		want:          1.0,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := convertType(tt.parameterType, tt.value)
+			if tt.wantErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_evaluate(t *testing.T) {
+	tests := []struct {
+		expression string
+		value      string
+		want       bool
+		wantErr    bool
+	}{
+		{expression: `^https://.*$`, value: "https://github.com", wantErr: true},
+		{expression: `size(self) > 3`, value: "https://github.com", wantErr: true},
+		{expression: `self.startsWith('a') && self.endsWith('b')`, value: "a') && self.endsWith('b", wantErr: true},
+		{expression: `contains("a") || contains("b")`, value: "https://github.com", wantErr: true},
+		{expression: `matches('^v\d+$')`, value: "v1", want: true},
+		{expression: `self.matches("^v\\d+$")`, value: "v1.0", want: false},
+		{expression: `matches()`, value: "anything", want: true},
+		{expression: `startsWith('https://')`, value: "https://github.com", want: true},
+		{expression: `endsWith('.git')`, value: "https://github.com", want: false},
+		{expression: `contains("github")`, value: "https://github.com", want: true},
+		{expression: `contains(github)`, value: "https://github.com", wantErr: true},
+		{expression: `[`, value: "https://github.com", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			got, err := evaluate(tt.expression, tt.value)
+			if tt.wantErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestCompileExpression(t *testing.T) {
+	assert.NotNil(t, CompileExpression(`^https://.*$`))
+	assert.NotNil(t, CompileExpression(`size(self) > 3`))
+	assert.NotNil(t, CompileExpression(`self.startsWith('a') && self.endsWith('b')`))
+	assert.Nil(t, CompileExpression(`matches('^v\d+$')`))
+	assert.Nil(t, CompileExpression(`startsWith('[')`))
+	assert.NotNil(t, CompileExpression(`[`))
+	assert.NotNil(t, CompileExpression(`matches('[')`))
+	assert.NotNil(t, CompileExpression(`contains(github)`))
+}
@@ -33,6 +33,12 @@ type Parameter struct {
 	Value interface{} `json:"value"`
 }
 
+// ParseTemplate parses the template of a Template or ClusterTemplate
+func ParseTemplate(name, rawTemplate string) (*tmpl.Template, error) {
+	//TODO Make delimiters configurable
+	return tmpl.New(name).Delims("$(", ")").Parse(rawTemplate)
+}
+
 func render(templateObject v1alpha3.TemplateObject, parameters []Parameter) (v1alpha3.TemplateObject, error) {
 	templateObject = templateObject.DeepCopyObject().(v1alpha3.TemplateObject)
 	rawTemplate := templateObject.TemplateSpec().Template
@@ -40,19 +46,15 @@ func render(templateObject v1alpha3.TemplateObject, parameters []Parameter) (v1a
 		Name:      templateObject.GetName(),
 		Namespace: templateObject.GetNamespace(),
 	}.String()
-	template := tmpl.New(templateName)
-	//TODO Make delimiters configurable
-	template.Delims("$(", ")")
-	if _, err := template.Parse(rawTemplate); err != nil {
+	template, err := ParseTemplate(templateName, rawTemplate)
+	if err != nil {
 		klog.Errorf("failed to parse template: %s, and err = %v", templateName, err)
 		return nil, errors.NewBadRequest("Failed to render template, please check the pipeline template for syntax error.")
 	}
 
-	// TODO Verify required parameters and default parameters
-	// check the required parameters
-	parameterMap := map[string]interface{}{}
-	for _, parameter := range parameters {
-		parameterMap[parameter.Name] = parameter.Value
+	parameterMap, err := resolveParameters(templateObject, parameters)
+	if err != nil {
+		return nil, err
 	}
 
 	parametersData := map[string]map[string]interface{}{}
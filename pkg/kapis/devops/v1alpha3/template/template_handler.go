@@ -73,7 +73,7 @@ func (h *handler) handleRenderTemplate(request *restful.Request, response *restf
 		return
 	}
 
-	kapis.ResponseWriter{Response: response}.WriteEntityOrError(h.renderTemplate(devopsName, templateName, renderBody.Parameters))
+	renderResultWriter{Response: response}.writeRenderResult(h.renderTemplate(devopsName, templateName, renderBody.Parameters))
 }
 
 func (h *handler) renderTemplate(devopsName, templateName string, parameters []Parameter) (v1alpha3.TemplateObject, error) {
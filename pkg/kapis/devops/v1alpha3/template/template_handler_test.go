@@ -19,6 +19,7 @@ import (
 	"encoding/json"
 	"github.com/emicklei/go-restful"
 	"github.com/stretchr/testify/assert"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
@@ -251,6 +252,18 @@ func Test_handler_handleRenderTemplate(t *testing.T) {
 			Template: "fake template content",
 		},
 	}
+	templateWithParameters := fakeTemplate.DeepCopy()
+	templateWithParameters.Spec.Parameters = []v1alpha3.TemplateParameter{{
+		Name:     "name",
+		Required: true,
+	}, {
+		Name:    "url",
+		Default: apiextensionsv1.JSON{Raw: []byte(`"ftp://github.com"`)},
+		Validation: &v1alpha3.ParameterValidation{
+			Expression: "startsWith('https://')",
+			Message:    "Please input an HTTPS URL.",
+		},
+	}}
 	createRequest := func(uri, devopsName, templateName string) *restful.Request {
 		fakeRequest := httptest.NewRequest(http.MethodGet, uri, nil)
 		fakeRequest.Header.Add(restful.HEADER_ContentType, restful.MIME_JSON)
@@ -292,6 +305,29 @@ func Test_handler_handleRenderTemplate(t *testing.T) {
 			renderResult := gotTemplate.GetAnnotations()[devops.GroupName+devops.RenderResultAnnoKey]
 			assert.Equal(t, fakeTemplate.Spec.Template, renderResult)
 		},
+	}, {
+		name: "Should return all failing parameters",
+		args: args{
+			request: createRequest("/v1alpha1/devops/fake-devops/templates/fake-template/render", "fake-devops", "fake-template"),
+			initObjects: []runtime.Object{
+				templateWithParameters,
+			},
+		},
+		wantCode: 400,
+		assertion: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+			status := &metav1.Status{}
+			assert.Nil(t, json.Unmarshal(recorder.Body.Bytes(), status))
+			assert.Equal(t, metav1.StatusReasonBadRequest, status.Reason)
+			assert.Equal(t, []metav1.StatusCause{{
+				Type:    metav1.CauseTypeFieldValueRequired,
+				Message: "parameter is required",
+				Field:   "params.name",
+			}, {
+				Type:    metav1.CauseTypeFieldValueInvalid,
+				Message: "Please input an HTTPS URL.",
+				Field:   "params.url",
+			}}, status.Details.Causes)
+		},
 	}}
 	for _, tt := range tests {
 		utilruntime.Must(v1alpha3.AddToScheme(scheme.Scheme))
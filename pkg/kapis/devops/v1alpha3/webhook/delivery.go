@@ -0,0 +1,142 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"sort"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// deliveryHistoryLimit is the maximum number of the deliveries to keep in a namespace
+	deliveryHistoryLimit = 50
+	// maxPayloadSize is the maximum size of a payload to keep, a larger one is not able to be redelivered
+	maxPayloadSize = 256 * 1024
+)
+
+// the actions of a delivery to a Pipeline
+const (
+	// actionRun indicates that a PipelineRun was created for a regular Pipeline
+	actionRun = "run"
+	// actionScan indicates that a multi-branch Pipeline was scanned
+	actionScan = "scan"
+	// actionReject indicates that the delivery failed the verification
+	actionReject = "reject"
+	// actionSync indicates that a Jenkins event was synchronized to the PipelineRun
+	actionSync = "sync"
+)
+
+// replayHeaders are the headers which are kept for replaying a delivery.
+// The signatures and tokens are excluded, only the verified deliveries are able to be redelivered.
+var replayHeaders = []string{
+	"Content-Type",
+	"User-Agent",
+	"X-GitHub-Event",
+	"X-Gitlab-Event",
+	"X-Gitea-Event",
+	"X-Gogs-Event",
+	"X-Event-Key",
+}
+
+// newDelivery creates a delivery record from the request, the result is filled after handling it
+func newDelivery(source string, request *http.Request, payload []byte) *v1alpha3.WebhookDelivery {
+	delivery := &v1alpha3.WebhookDelivery{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: source + "-",
+			Labels: map[string]string{
+				v1alpha3.WebhookDeliverySourceLabelKey: source,
+			},
+		},
+		Spec: v1alpha3.WebhookDeliverySpec{
+			Source:  source,
+			Headers: map[string]string{},
+		},
+	}
+	for _, key := range replayHeaders {
+		if val := request.Header.Get(key); val != "" {
+			delivery.Spec.Headers[key] = val
+		}
+	}
+
+	checksum := sha256.Sum256(payload)
+	delivery.Spec.PayloadHash = hex.EncodeToString(checksum[:])
+	if len(payload) <= maxPayloadSize {
+		delivery.Spec.Payload = string(payload)
+	}
+	now := metav1.Now()
+	delivery.Status.DeliveredAt = &now
+	return delivery
+}
+
+// newReplayRequest creates a request from a delivery record
+func newReplayRequest(ctx context.Context, delivery *v1alpha3.WebhookDelivery) (request *http.Request, err error) {
+	if request, err = http.NewRequestWithContext(ctx, http.MethodPost, "/", nil); err != nil {
+		return
+	}
+	for key, val := range delivery.Spec.Headers {
+		request.Header.Set(key, val)
+	}
+	return
+}
+
+// recordDelivery creates the delivery record, then removes the oldest ones of the namespace beyond the limit.
+// It's best effort, a failure of it should not block the delivery.
+func recordDelivery(ctx context.Context, c client.Client, delivery *v1alpha3.WebhookDelivery) {
+	if err := c.Create(ctx, delivery); err != nil {
+		klog.Errorf("failed to record the webhook delivery in namespace %s, error: %v", delivery.Namespace, err)
+		return
+	}
+
+	deliveryList := &v1alpha3.WebhookDeliveryList{}
+	if err := c.List(ctx, deliveryList, client.InNamespace(delivery.Namespace)); err != nil {
+		klog.Errorf("failed to list the webhook deliveries in namespace %s, error: %v", delivery.Namespace, err)
+		return
+	}
+	if len(deliveryList.Items) <= deliveryHistoryLimit {
+		return
+	}
+
+	sortDeliveries(deliveryList.Items)
+	for i := deliveryHistoryLimit; i < len(deliveryList.Items); i++ {
+		if err := c.Delete(ctx, &deliveryList.Items[i]); client.IgnoreNotFound(err) != nil {
+			klog.Errorf("failed to delete the webhook delivery %s/%s, error: %v",
+				delivery.Namespace, deliveryList.Items[i].Name, err)
+		}
+	}
+}
+
+// sortDeliveries sorts the deliveries from the newest to the oldest
+func sortDeliveries(deliveries []v1alpha3.WebhookDelivery) {
+	sort.SliceStable(deliveries, func(i, j int) bool {
+		return getDeliveredTime(&deliveries[j]).Before(getDeliveredTime(&deliveries[i]))
+	})
+}
+
+func getDeliveredTime(delivery *v1alpha3.WebhookDelivery) *metav1.Time {
+	if delivery.Status.DeliveredAt != nil {
+		return delivery.Status.DeliveredAt
+	}
+	return &delivery.CreationTimestamp
+}
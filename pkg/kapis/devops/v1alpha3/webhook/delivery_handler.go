@@ -0,0 +1,180 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/emicklei/go-restful"
+	"kubesphere.io/devops/pkg/api"
+	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
+	"kubesphere.io/devops/pkg/apiserver/query"
+	"kubesphere.io/devops/pkg/event/common"
+	"kubesphere.io/devops/pkg/kapis"
+	"kubesphere.io/devops/pkg/utils/gitutil"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// deliveryHandler serves the delivery records.
+// The records are read with the client of the request user, and the redeliveries are handled by the webhook handlers.
+type deliveryHandler struct {
+	client.Client
+	scmHandler     *SCMHandler
+	jenkinsHandler *Handler
+}
+
+func (h *deliveryHandler) listGitRepositoryDeliveries(request *restful.Request, response *restful.Response) {
+	ctx := request.Request.Context()
+	namespace := request.PathParameter("namespace")
+	gitRepo := &v1alpha3.GitRepository{}
+	if err := h.Get(ctx, client.ObjectKey{Namespace: namespace, Name: request.PathParameter("gitrepository")}, gitRepo); err != nil {
+		kapis.HandleError(request, response, err)
+		return
+	}
+
+	deliveries, err := h.listDeliveries(ctx, namespace, []v1alpha3.GitRepository{*gitRepo})
+	if err != nil {
+		kapis.HandleError(request, response, err)
+		return
+	}
+	writeDeliveries(request, response, deliveries)
+}
+
+func (h *deliveryHandler) listWebhookDeliveries(request *restful.Request, response *restful.Response) {
+	ctx := request.Request.Context()
+	namespace := request.PathParameter("namespace")
+	webhook := &v1alpha3.Webhook{}
+	if err := h.Get(ctx, client.ObjectKey{Namespace: namespace, Name: request.PathParameter("webhook")}, webhook); err != nil {
+		kapis.HandleError(request, response, err)
+		return
+	}
+
+	// the deliveries of a Webhook are the ones of the GitRepositories which refer to it
+	repoList := &v1alpha3.GitRepositoryList{}
+	if err := h.List(ctx, repoList, client.InNamespace(namespace)); err != nil {
+		kapis.HandleError(request, response, err)
+		return
+	}
+	var gitRepos []v1alpha3.GitRepository
+	for i := range repoList.Items {
+		for _, ref := range repoList.Items[i].Spec.Webhooks {
+			if ref.Name == webhook.Name {
+				gitRepos = append(gitRepos, repoList.Items[i])
+				break
+			}
+		}
+	}
+
+	deliveries, err := h.listDeliveries(ctx, namespace, gitRepos)
+	if err != nil {
+		kapis.HandleError(request, response, err)
+		return
+	}
+	writeDeliveries(request, response, deliveries)
+}
+
+// listDeliveries returns the SCM deliveries of the GitRepositories, from the newest to the oldest
+func (h *deliveryHandler) listDeliveries(ctx context.Context, namespace string, gitRepos []v1alpha3.GitRepository) (
+	deliveries []v1alpha3.WebhookDelivery, err error) {
+	if len(gitRepos) == 0 {
+		return
+	}
+
+	deliveryList := &v1alpha3.WebhookDeliveryList{}
+	if err = h.List(ctx, deliveryList, client.InNamespace(namespace),
+		client.MatchingLabels{v1alpha3.WebhookDeliverySourceLabelKey: v1alpha3.WebhookDeliverySourceSCM}); err != nil {
+		return
+	}
+	for i := range deliveryList.Items {
+		delivery := deliveryList.Items[i]
+		for _, gitRepo := range gitRepos {
+			if gitRepo.Spec.URL != "" && gitutil.MatchAnyURL(delivery.Spec.RepositoryURLs, gitRepo.Spec.URL) {
+				deliveries = append(deliveries, delivery)
+				break
+			}
+		}
+	}
+	sortDeliveries(deliveries)
+	return
+}
+
+func writeDeliveries(request *restful.Request, response *restful.Response, deliveries []v1alpha3.WebhookDelivery) {
+	queryParam := query.ParseQueryParameter(request)
+	total := len(deliveries)
+	startIndex, endIndex := queryParam.Pagination.GetValidPagination(total)
+	items := make([]interface{}, 0, endIndex-startIndex)
+	for i := startIndex; i < endIndex; i++ {
+		items = append(items, deliveries[i])
+	}
+	_ = response.WriteEntity(api.NewListResult(items, total))
+}
+
+// redeliver replays the payload of a delivery through the webhook handlers, then returns the new delivery record.
+// It only affects the namespace of the delivery. The signature verification is skipped, so only the SCM deliveries
+// which were verified are able to be redelivered.
+func (h *deliveryHandler) redeliver(request *restful.Request, response *restful.Response) {
+	ctx := request.Request.Context()
+	namespace := request.PathParameter("namespace")
+	delivery := &v1alpha3.WebhookDelivery{}
+	if err := h.Get(ctx, client.ObjectKey{Namespace: namespace, Name: request.PathParameter("webhookdelivery")}, delivery); err != nil {
+		kapis.HandleError(request, response, err)
+		return
+	}
+	if !delivery.IsRedeliverable() {
+		kapis.HandleBadRequest(response, request, fmt.Errorf("delivery %s is not redeliverable, its payload was not kept or it was not verified", delivery.Name))
+		return
+	}
+
+	req, err := newReplayRequest(context.TODO(), delivery)
+	if err != nil {
+		kapis.HandleError(request, response, err)
+		return
+	}
+	payload := []byte(delivery.Spec.Payload)
+	req.Body = io.NopCloser(bytes.NewReader(payload))
+
+	var record *v1alpha3.WebhookDelivery
+	redelivery := newDelivery(delivery.Spec.Source, req, payload)
+	redelivery.Spec.RedeliveryOf = delivery.Name
+	switch delivery.Spec.Source {
+	case v1alpha3.WebhookDeliverySourceSCM:
+		outcome := h.scmHandler.handleDelivery(context.TODO(), req, payload, namespace, true)
+		if records := h.scmHandler.recordSCMDelivery(context.TODO(), redelivery, outcome, namespace); len(records) > 0 {
+			record = records[0]
+		}
+	case v1alpha3.WebhookDeliverySourceJenkins:
+		event := &common.Event{}
+		if err = json.Unmarshal(payload, event); err != nil {
+			kapis.HandleBadRequest(response, request, err)
+			return
+		}
+		// only the events of the same namespace are allowed
+		if identifier := getEventPipelineRunIdentifier(event); identifier == nil || identifier.namespaceName != namespace {
+			kapis.HandleBadRequest(response, request, fmt.Errorf("delivery %s is not about namespace %s", delivery.Name, namespace))
+			return
+		}
+		record = h.jenkinsHandler.recordDelivery(context.TODO(), redelivery, event, h.jenkinsHandler.handleEvent(event))
+	default:
+		kapis.HandleBadRequest(response, request, fmt.Errorf("unknown source of delivery %s: %s", delivery.Name, delivery.Spec.Source))
+		return
+	}
+	_ = response.WriteEntity(record)
+}
@@ -0,0 +1,274 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/emicklei/go-restful"
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-zh/jenkins-client/pkg/core"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
+	apiserverruntime "kubesphere.io/devops/pkg/apiserver/runtime"
+	"kubesphere.io/devops/pkg/jwt/token"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// deliveryListResult is the list result of the delivery records
+type deliveryListResult struct {
+	Items      []v1alpha3.WebhookDelivery `json:"items"`
+	TotalItems int                        `json:"totalItems"`
+}
+
+func newDeliveryTestContainer(c client.Client) *restful.Container {
+	container := restful.NewContainer()
+	ws := apiserverruntime.NewWebService(v1alpha3.GroupVersion)
+	RegisterWebhooks(c, c, ws, &token.FakeIssuer{}, core.JenkinsCore{}, nil, record.NewFakeRecorder(100))
+	container.Add(ws)
+	return container
+}
+
+func dispatch(container *restful.Container, method, uri, body string, header map[string]string) *httptest.ResponseRecorder {
+	var bodyReader io.Reader
+	if body != "" {
+		bodyReader = strings.NewReader(body)
+	}
+	request, _ := http.NewRequest(method, "http://fake.com/kapis/devops.kubesphere.io/v1alpha3"+uri, bodyReader)
+	request.Header.Set("Content-Type", "application/json")
+	for k, v := range header {
+		request.Header.Set(k, v)
+	}
+	recorder := httptest.NewRecorder()
+	container.Dispatch(recorder, request)
+	return recorder
+}
+
+func listDeliveries(t *testing.T, container *restful.Container, uri string) (result deliveryListResult) {
+	recorder := dispatch(container, http.MethodGet, uri, "", nil)
+	if assert.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String()) {
+		assert.Nil(t, json.Unmarshal(recorder.Body.Bytes(), &result))
+	}
+	return
+}
+
+func TestWebhookDeliveries(t *testing.T) {
+	utilruntime.Must(v1alpha3.AddToScheme(scheme.Scheme))
+
+	pipeline := &v1alpha3.Pipeline{}
+	pipeline.SetName("fake")
+	pipeline.SetNamespace("default")
+	pipeline.SetAnnotations(map[string]string{scmAnnotationKey: "https://gitlab.com/linuxsuren/test"})
+
+	webhookSecret := &corev1.Secret{}
+	webhookSecret.SetName("webhook-secret")
+	webhookSecret.SetNamespace("default")
+	webhookSecret.Type = corev1.SecretTypeOpaque
+	webhookSecret.Data = map[string][]byte{corev1.ServiceAccountTokenKey: []byte("secret")}
+
+	webhook := &v1alpha3.Webhook{}
+	webhook.SetName("webhook")
+	webhook.SetNamespace("default")
+	webhook.Spec.Secret = &corev1.SecretReference{Name: "webhook-secret"}
+
+	gitRepo := &v1alpha3.GitRepository{}
+	gitRepo.SetName("repo")
+	gitRepo.SetNamespace("default")
+	gitRepo.Spec.URL = "https://gitlab.com/linuxsuren/test"
+	gitRepo.Spec.Webhooks = []corev1.LocalObjectReference{{Name: "webhook"}}
+
+	// it's another repository, it has nothing to do with the deliveries
+	otherRepo := &v1alpha3.GitRepository{}
+	otherRepo.SetName("other")
+	otherRepo.SetNamespace("default")
+	otherRepo.Spec.URL = "https://gitlab.com/linuxsuren/other"
+
+	t.Run("no pipeline matched, but it's recorded for the GitRepository", func(t *testing.T) {
+		c := fake.NewFakeClientWithScheme(scheme.Scheme, gitRepo.DeepCopy(), otherRepo.DeepCopy())
+		container := newDeliveryTestContainer(c)
+
+		recorder := dispatch(container, http.MethodPost, "/webhooks/scm", gitlabWebhookBody,
+			map[string]string{"X-Gitlab-Event": "Push Hook"})
+		assert.Equal(t, "no pipeline matched", recorder.Body.String())
+
+		result := listDeliveries(t, container, "/namespaces/default/gitrepositories/repo/webhookdeliveries")
+		if assert.Equal(t, 1, result.TotalItems) {
+			delivery := result.Items[0]
+			assert.Equal(t, v1alpha3.WebhookDeliverySourceSCM, delivery.Spec.Source)
+			assert.Equal(t, "gitlab", delivery.Spec.Provider)
+			assert.Equal(t, eventPush, delivery.Spec.Event)
+			assert.Equal(t, "refs/heads/master", delivery.Spec.Ref)
+			assert.Equal(t, "https://gitlab.com/linuxsuren/test", delivery.Spec.Repository)
+			assert.Equal(t, "Push Hook", delivery.Spec.Headers["X-Gitlab-Event"])
+			assert.Equal(t, gitlabWebhookBody, delivery.Spec.Payload)
+			assert.Equal(t, http.StatusOK, delivery.Status.Code)
+			assert.Equal(t, "no pipeline matched", delivery.Status.Message)
+			assert.Empty(t, delivery.Status.Pipelines)
+		}
+
+		result = listDeliveries(t, container, "/namespaces/default/gitrepositories/other/webhookdeliveries")
+		assert.Equal(t, 0, result.TotalItems)
+	})
+
+	t.Run("no pipeline or GitRepository matched, it's not recorded", func(t *testing.T) {
+		c := fake.NewFakeClientWithScheme(scheme.Scheme, otherRepo.DeepCopy())
+		container := newDeliveryTestContainer(c)
+
+		recorder := dispatch(container, http.MethodPost, "/webhooks/scm/namespaces/default", gitlabWebhookBody,
+			map[string]string{"X-Gitlab-Event": "Push Hook"})
+		assert.Equal(t, "no pipeline matched", recorder.Body.String())
+
+		deliveryList := &v1alpha3.WebhookDeliveryList{}
+		assert.Nil(t, c.List(context.Background(), deliveryList))
+		assert.Empty(t, deliveryList.Items)
+	})
+
+	t.Run("only the verified delivery is able to be redelivered", func(t *testing.T) {
+		c := fake.NewFakeClientWithScheme(scheme.Scheme, pipeline.DeepCopy(), webhookSecret.DeepCopy(),
+			webhook.DeepCopy(), gitRepo.DeepCopy())
+		container := newDeliveryTestContainer(c)
+
+		recorder := dispatch(container, http.MethodPost, "/webhooks/scm", gitlabWebhookBody,
+			map[string]string{"X-Gitlab-Event": "Push Hook", "X-Gitlab-Token": "invalid"})
+		assert.Equal(t, http.StatusForbidden, recorder.Code)
+
+		result := listDeliveries(t, container, "/namespaces/default/webhooks/webhook/webhookdeliveries")
+		if !assert.Equal(t, 1, result.TotalItems) {
+			return
+		}
+		delivery := result.Items[0]
+		assert.Equal(t, http.StatusForbidden, delivery.Status.Code)
+		assert.Equal(t, []v1alpha3.WebhookDeliveryPipeline{{
+			Name: "fake", Action: actionReject, Error: scm.ErrSignatureInvalid.Error(),
+		}}, delivery.Status.Pipelines)
+		// the token is never kept
+		assert.Empty(t, delivery.Spec.Headers["X-Gitlab-Token"])
+		assert.False(t, delivery.Status.Verified)
+
+		// a rejected delivery skips the signature verification if it's redelivered
+		recorder = dispatch(container, http.MethodPost,
+			"/namespaces/default/webhookdeliveries/"+delivery.Name+"/redeliver", "", nil)
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+
+		recorder = dispatch(container, http.MethodPost, "/webhooks/scm", gitlabWebhookBody,
+			map[string]string{"X-Gitlab-Event": "Push Hook", "X-Gitlab-Token": "secret"})
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		result = listDeliveries(t, container, "/namespaces/default/webhooks/webhook/webhookdeliveries")
+		if !assert.Equal(t, 2, result.TotalItems) {
+			return
+		}
+		rejected := delivery.Name
+		for _, item := range result.Items {
+			if item.Name != rejected {
+				delivery = item
+			}
+		}
+		assert.True(t, delivery.Status.Verified)
+
+		recorder = dispatch(container, http.MethodPost,
+			"/namespaces/default/webhookdeliveries/"+delivery.Name+"/redeliver", "", nil)
+		if !assert.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String()) {
+			return
+		}
+		redelivery := &v1alpha3.WebhookDelivery{}
+		assert.Nil(t, json.Unmarshal(recorder.Body.Bytes(), redelivery))
+		assert.Equal(t, delivery.Name, redelivery.Spec.RedeliveryOf)
+		assert.Equal(t, delivery.Spec.PayloadHash, redelivery.Spec.PayloadHash)
+		assert.Equal(t, http.StatusOK, redelivery.Status.Code)
+		assert.True(t, redelivery.Status.Verified)
+		if assert.Equal(t, 1, len(redelivery.Status.Pipelines)) {
+			assert.Equal(t, actionRun, redelivery.Status.Pipelines[0].Action)
+			run := &v1alpha3.PipelineRun{}
+			assert.Nil(t, c.Get(context.Background(), client.ObjectKey{
+				Namespace: "default", Name: redelivery.Status.Pipelines[0].PipelineRun}, run))
+		}
+
+		result = listDeliveries(t, container, "/namespaces/default/gitrepositories/repo/webhookdeliveries")
+		assert.Equal(t, 3, result.TotalItems)
+	})
+
+	t.Run("redeliver invalid deliveries", func(t *testing.T) {
+		tooLarge := &v1alpha3.WebhookDelivery{}
+		tooLarge.SetName("too-large")
+		tooLarge.SetNamespace("default")
+		tooLarge.Spec.Source = v1alpha3.WebhookDeliverySourceSCM
+
+		jenkinsDelivery := &v1alpha3.WebhookDelivery{}
+		jenkinsDelivery.SetName("jenkins")
+		jenkinsDelivery.SetNamespace("default")
+		jenkinsDelivery.Spec.Source = v1alpha3.WebhookDeliverySourceJenkins
+		jenkinsDelivery.Spec.Payload = `{"type": "run.initialize", "dataType": "org.jenkinsci.plugins.workflow.job.WorkflowRun",
+"data": {"id": "1", "_parentFullName": "another-namespace", "_projectName": "fake"}}`
+
+		c := fake.NewFakeClientWithScheme(scheme.Scheme, []runtime.Object{tooLarge, jenkinsDelivery}...)
+		container := newDeliveryTestContainer(c)
+
+		recorder := dispatch(container, http.MethodPost, "/namespaces/default/webhookdeliveries/not-found/redeliver", "", nil)
+		assert.Equal(t, http.StatusNotFound, recorder.Code)
+		recorder = dispatch(container, http.MethodPost, "/namespaces/default/webhookdeliveries/too-large/redeliver", "", nil)
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+		// the event is about another namespace
+		recorder = dispatch(container, http.MethodPost, "/namespaces/default/webhookdeliveries/jenkins/redeliver", "", nil)
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	})
+
+	t.Run("list the deliveries of a non-existing GitRepository", func(t *testing.T) {
+		container := newDeliveryTestContainer(fake.NewFakeClientWithScheme(scheme.Scheme))
+		recorder := dispatch(container, http.MethodGet, "/namespaces/default/gitrepositories/repo/webhookdeliveries", "", nil)
+		assert.Equal(t, http.StatusNotFound, recorder.Code)
+		recorder = dispatch(container, http.MethodGet, "/namespaces/default/webhooks/webhook/webhookdeliveries", "", nil)
+		assert.Equal(t, http.StatusNotFound, recorder.Code)
+	})
+}
+
+func TestJenkinsWebhookDelivery(t *testing.T) {
+	utilruntime.Must(v1alpha3.AddToScheme(scheme.Scheme))
+	c := fake.NewFakeClientWithScheme(scheme.Scheme)
+	container := newDeliveryTestContainer(c)
+
+	recorder := dispatch(container, http.MethodPost, "/webhooks/jenkins", `{"type": "run.started",
+"dataType": "org.jenkinsci.plugins.workflow.job.WorkflowRun",
+"data": {"id": "1", "_parentFullName": "default", "_projectName": "fake"}}`, nil)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	deliveryList := &v1alpha3.WebhookDeliveryList{}
+	assert.Nil(t, c.List(context.Background(), deliveryList, client.InNamespace("default")))
+	if assert.Equal(t, 1, len(deliveryList.Items)) {
+		delivery := deliveryList.Items[0]
+		assert.Equal(t, v1alpha3.WebhookDeliverySourceJenkins, delivery.Spec.Source)
+		assert.Equal(t, "run.started", delivery.Spec.Event)
+		assert.Equal(t, http.StatusOK, delivery.Status.Code)
+		assert.Equal(t, []v1alpha3.WebhookDeliveryPipeline{{Name: "fake", Action: actionSync}}, delivery.Status.Pipelines)
+
+		// redeliver it
+		recorder = dispatch(container, http.MethodPost,
+			"/namespaces/default/webhookdeliveries/"+delivery.Name+"/redeliver", "", nil)
+		assert.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+	}
+}
@@ -0,0 +1,129 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func Test_newDelivery(t *testing.T) {
+	request, _ := http.NewRequest(http.MethodPost, "/webhooks/scm", nil)
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("X-Gitlab-Event", "Push Hook")
+	request.Header.Set("X-Gitlab-Token", "secret")
+	request.Header.Set("X-Hub-Signature", "sha1=fake")
+
+	delivery := newDelivery(v1alpha3.WebhookDeliverySourceSCM, request, []byte("payload"))
+	assert.Equal(t, "scm-", delivery.GenerateName)
+	assert.Equal(t, v1alpha3.WebhookDeliverySourceSCM, delivery.Labels[v1alpha3.WebhookDeliverySourceLabelKey])
+	assert.Equal(t, map[string]string{
+		"Content-Type":   "application/json",
+		"X-Gitlab-Event": "Push Hook",
+	}, delivery.Spec.Headers)
+	assert.Equal(t, "payload", delivery.Spec.Payload)
+	assert.Equal(t, "239f59ed55e737c77147cf55ad0c1b030b6d7ee748a7426952f9b852d5a935e5", delivery.Spec.PayloadHash)
+	assert.NotNil(t, delivery.Status.DeliveredAt)
+	// the signature was not verified
+	assert.False(t, delivery.IsRedeliverable())
+	delivery.Status.Verified = true
+	assert.True(t, delivery.IsRedeliverable())
+
+	// the payload is too large to keep
+	delivery = newDelivery(v1alpha3.WebhookDeliverySourceSCM, request, []byte(strings.Repeat("a", maxPayloadSize+1)))
+	assert.Empty(t, delivery.Spec.Payload)
+	assert.NotEmpty(t, delivery.Spec.PayloadHash)
+	assert.False(t, delivery.IsRedeliverable())
+}
+
+func Test_newReplayRequest(t *testing.T) {
+	delivery := &v1alpha3.WebhookDelivery{
+		Spec: v1alpha3.WebhookDeliverySpec{
+			Headers: map[string]string{"X-GitHub-Event": "push"},
+		},
+	}
+	request, err := newReplayRequest(context.Background(), delivery)
+	assert.Nil(t, err)
+	assert.Equal(t, http.MethodPost, request.Method)
+	assert.Equal(t, "push", request.Header.Get("X-GitHub-Event"))
+}
+
+func Test_recordDelivery(t *testing.T) {
+	utilruntime.Must(v1alpha3.AddToScheme(scheme.Scheme))
+	now := time.Now()
+	var objects []client.Object
+	for i := 0; i < deliveryHistoryLimit; i++ {
+		deliveredAt := metav1.NewTime(now.Add(-time.Duration(i+1) * time.Minute))
+		delivery := &v1alpha3.WebhookDelivery{}
+		delivery.SetName(fmt.Sprintf("delivery-%d", i))
+		delivery.SetNamespace("default")
+		delivery.Status.DeliveredAt = &deliveredAt
+		objects = append(objects, delivery)
+	}
+	// it's in another namespace, should not be removed
+	other := &v1alpha3.WebhookDelivery{}
+	other.SetName("other")
+	other.SetNamespace("other")
+	objects = append(objects, other)
+	c := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(objects...).Build()
+
+	deliveredAt := metav1.NewTime(now)
+	delivery := &v1alpha3.WebhookDelivery{}
+	delivery.SetName("latest")
+	delivery.SetNamespace("default")
+	delivery.Status.DeliveredAt = &deliveredAt
+	recordDelivery(context.Background(), c, delivery)
+
+	deliveryList := &v1alpha3.WebhookDeliveryList{}
+	assert.Nil(t, c.List(context.Background(), deliveryList, client.InNamespace("default")))
+	assert.Equal(t, deliveryHistoryLimit, len(deliveryList.Items))
+	sortDeliveries(deliveryList.Items)
+	assert.Equal(t, "latest", deliveryList.Items[0].Name)
+	// the oldest one was removed
+	assert.Equal(t, fmt.Sprintf("delivery-%d", deliveryHistoryLimit-2), deliveryList.Items[deliveryHistoryLimit-1].Name)
+	assert.Nil(t, c.Get(context.Background(), client.ObjectKeyFromObject(other), &v1alpha3.WebhookDelivery{}))
+}
+
+func Test_sortDeliveries(t *testing.T) {
+	now := metav1.Now()
+	earlier := metav1.NewTime(now.Add(-time.Minute))
+	deliveries := []v1alpha3.WebhookDelivery{{
+		ObjectMeta: metav1.ObjectMeta{Name: "earlier"},
+		Status:     v1alpha3.WebhookDeliveryStatus{DeliveredAt: &earlier},
+	}, {
+		ObjectMeta: metav1.ObjectMeta{Name: "no-time", CreationTimestamp: metav1.NewTime(now.Add(-time.Hour))},
+	}, {
+		ObjectMeta: metav1.ObjectMeta{Name: "now"},
+		Status:     v1alpha3.WebhookDeliveryStatus{DeliveredAt: &now},
+	}}
+	sortDeliveries(deliveries)
+	assert.Equal(t, "now", deliveries[0].Name)
+	assert.Equal(t, "earlier", deliveries[1].Name)
+	assert.Equal(t, "no-time", deliveries[2].Name)
+}
@@ -17,8 +17,16 @@ limitations under the License.
 package webhook
 
 import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+
 	"github.com/emicklei/go-restful"
+	"github.com/jenkins-zh/jenkins-client/pkg/core"
 	"k8s.io/apimachinery/pkg/util/errors"
+	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
+	"kubesphere.io/devops/pkg/client/s3"
 	"kubesphere.io/devops/pkg/event/common"
 	"kubesphere.io/devops/pkg/event/workflowrun"
 	"kubesphere.io/devops/pkg/kapis"
@@ -28,35 +36,61 @@ import (
 // Handler handles requests from webhooks.
 type Handler struct {
 	client.Client
+	JenkinsCore core.JenkinsCore
+	// S3Client is required when the PipelineRun controller stores the PipelineRun data in s3
+	S3Client s3.Interface
 }
 
 // NewHandler creates a new handler for handling webhooks.
-func NewHandler(genericClient client.Client) *Handler {
+func NewHandler(genericClient client.Client, jenkins core.JenkinsCore, s3Client s3.Interface) *Handler {
 	return &Handler{
-		Client: genericClient,
+		Client:      genericClient,
+		JenkinsCore: jenkins,
+		S3Client:    s3Client,
 	}
 }
 
 // ReceiveEventsFromJenkins receives events from Jenkins
 func (handler *Handler) ReceiveEventsFromJenkins(request *restful.Request, response *restful.Response) {
+	if request.Request.Body == nil {
+		kapis.HandleBadRequest(response, request, io.ErrUnexpectedEOF)
+		return
+	}
+
+	// keep the payload, it's required when recording the delivery
+	payload, err := io.ReadAll(request.Request.Body)
+	if err != nil {
+		kapis.HandleError(request, response, err)
+		return
+	}
+
 	// concrete event body
 	event := &common.Event{}
-	if err := request.ReadEntity(event); err != nil {
+	if err = json.Unmarshal(payload, event); err != nil {
 		kapis.HandleError(request, response, err)
 		return
 	}
 
+	err = handler.handleEvent(event)
+	handler.recordDelivery(context.TODO(), newDelivery(v1alpha3.WebhookDeliverySourceJenkins, request.Request, payload), event, err)
+	if err != nil {
+		kapis.HandleError(request, response, err)
+	}
+}
+
+// handleEvent handles an event from Jenkins
+func (handler *Handler) handleEvent(event *common.Event) error {
 	// TODO Make all handlers execute asynchronously
 
 	// register WorkflowRun event handler
 	var errs []error
 	workflowRunHandlers := workflowrun.Handlers{
 		HandleInitialize: handler.handleWorkflowRunInitialize,
+		HandleStarted:    handler.handleWorkflowRunStarted,
+		HandleFinalized:  handler.handleWorkflowRunFinished,
+		HandleCompleted:  handler.handleWorkflowRunFinished,
 		// TODO Handler others
-		HandleStarted:   nil,
-		HandleFinalized: nil,
-		HandleCompleted: nil,
-		HandleDeleted:   nil,
+		HandleDeleted: nil,
 	}
 	if err := workflowRunHandlers.Handle(event); err != nil {
 		errs = append(errs, err)
@@ -64,7 +98,27 @@ func (handler *Handler) ReceiveEventsFromJenkins(request *restful.Request, respo
 
 	// TODO Register other event handlers here
 
-	if len(errs) > 0 {
-		kapis.HandleError(request, response, errors.NewAggregate(errs))
+	return errors.NewAggregate(errs)
+}
+
+// recordDelivery records the delivery in the namespace of the Pipeline, it's skipped if the Pipeline is unknown
+func (handler *Handler) recordDelivery(ctx context.Context, delivery *v1alpha3.WebhookDelivery, event *common.Event,
+	handleErr error) *v1alpha3.WebhookDelivery {
+	identifier := getEventPipelineRunIdentifier(event)
+	if identifier == nil {
+		return nil
+	}
+
+	delivery.Namespace = identifier.namespaceName
+	delivery.Spec.Event = event.Type
+	delivery.Spec.Ref = identifier.scmRefName
+	delivery.Status.Code = http.StatusOK
+	pipeline := v1alpha3.WebhookDeliveryPipeline{Name: identifier.pipelineName, Action: actionSync}
+	if handleErr != nil {
+		delivery.Status.Code = http.StatusInternalServerError
+		pipeline.Error = handleErr.Error()
 	}
+	delivery.Status.Pipelines = []v1alpha3.WebhookDeliveryPipeline{pipeline}
+	recordDelivery(ctx, handler.Client, delivery)
+	return delivery
 }
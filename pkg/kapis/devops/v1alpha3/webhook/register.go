@@ -22,19 +22,63 @@ import (
 	"net/http"
 
 	"github.com/emicklei/go-restful"
+	"k8s.io/client-go/tools/record"
 	"kubesphere.io/devops/pkg/api"
+	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
+	"kubesphere.io/devops/pkg/apiserver/query"
+	"kubesphere.io/devops/pkg/client/s3"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 // RegisterWebhooks registers all webhooks into web service.
-func RegisterWebhooks(genericClient client.Client, ws *restful.WebService, issue token.Issuer, jenkins core.JenkinsCore) {
-	webhookHandler := NewHandler(genericClient)
+// The deliveries are handled with the genericClient because they are anonymous,
+// and the delivery records are served with the handlerClient which could be an impersonating client of the request user.
+// The s3Client is only required if the PipelineRun controller stores the PipelineRun data in s3.
+// The recorder records the audit events of the SCM deliveries, see NewEventRecorder.
+func RegisterWebhooks(genericClient, handlerClient client.Client, ws *restful.WebService, issue token.Issuer, jenkins core.JenkinsCore,
+	s3Client s3.Interface, recorder record.EventRecorder) {
+	webhookHandler := NewHandler(genericClient, jenkins, s3Client)
 	ws.Route(ws.POST("/webhooks/jenkins").
 		To(webhookHandler.ReceiveEventsFromJenkins).
 		Doc("Webhook for receiving events from Jenkins").
 		Returns(http.StatusOK, api.StatusOK, nil))
 
-	scmHandler := NewSCMHandler(genericClient, issue, jenkins)
+	scmHandler := NewSCMHandler(genericClient, issue, jenkins, recorder)
 	ws.Route(ws.POST("/webhooks/scm").
-		To(scmHandler.scmWebhook))
+		To(scmHandler.scmWebhook).
+		Doc("Webhook for receiving the deliveries from the git providers, it triggers the Pipelines of all namespaces").
+		Returns(http.StatusOK, api.StatusOK, deliveryResult{}))
+	ws.Route(ws.POST("/webhooks/scm/namespaces/{namespace}").
+		To(scmHandler.scmWebhook).
+		Doc("Webhook for receiving the deliveries from the git providers, it only triggers the Pipelines of the namespace").
+		Param(ws.PathParameter("namespace", "Namespace of the Pipelines")).
+		Returns(http.StatusOK, api.StatusOK, deliveryResult{}))
+
+	deliveryHandler := &deliveryHandler{
+		Client:         handlerClient,
+		scmHandler:     scmHandler,
+		jenkinsHandler: webhookHandler,
+	}
+	ws.Route(ws.GET("/namespaces/{namespace}/gitrepositories/{gitrepository}/webhookdeliveries").
+		To(deliveryHandler.listGitRepositoryDeliveries).
+		Doc("List the webhook deliveries of a GitRepository, from the newest to the oldest").
+		Param(ws.PathParameter("namespace", "Namespace of the GitRepository")).
+		Param(ws.PathParameter("gitrepository", "Name of the GitRepository")).
+		Param(ws.QueryParameter(query.ParameterPage, "page").Required(false).DataFormat("page=%d").DefaultValue("page=1")).
+		Param(ws.QueryParameter(query.ParameterLimit, "limit").Required(false)).
+		Returns(http.StatusOK, api.StatusOK, api.ListResult{Items: []interface{}{}}))
+	ws.Route(ws.GET("/namespaces/{namespace}/webhooks/{webhook}/webhookdeliveries").
+		To(deliveryHandler.listWebhookDeliveries).
+		Doc("List the webhook deliveries of the GitRepositories which refer to a Webhook, from the newest to the oldest").
+		Param(ws.PathParameter("namespace", "Namespace of the Webhook")).
+		Param(ws.PathParameter("webhook", "Name of the Webhook")).
+		Param(ws.QueryParameter(query.ParameterPage, "page").Required(false).DataFormat("page=%d").DefaultValue("page=1")).
+		Param(ws.QueryParameter(query.ParameterLimit, "limit").Required(false)).
+		Returns(http.StatusOK, api.StatusOK, api.ListResult{Items: []interface{}{}}))
+	ws.Route(ws.POST("/namespaces/{namespace}/webhookdeliveries/{webhookdelivery}/redeliver").
+		To(deliveryHandler.redeliver).
+		Doc("Replay the payload of a webhook delivery, it only triggers the Pipelines of the namespace").
+		Param(ws.PathParameter("namespace", "Namespace of the WebhookDelivery")).
+		Param(ws.PathParameter("webhookdelivery", "Name of the WebhookDelivery")).
+		Returns(http.StatusOK, api.StatusOK, v1alpha3.WebhookDelivery{}))
 }
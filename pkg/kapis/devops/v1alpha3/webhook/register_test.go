@@ -18,12 +18,15 @@ package webhook
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/jenkins-zh/jenkins-client/pkg/core"
 	"io"
+	corev1 "k8s.io/api/core/v1"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/client-go/util/retry"
 	"kubesphere.io/devops/pkg/jwt/token"
 	"net/http"
@@ -36,6 +39,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
 	apiserverruntime "kubesphere.io/devops/pkg/apiserver/runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -160,7 +164,8 @@ func TestJenkinsWebhook(t *testing.T) {
 
 			container := restful.NewContainer()
 			wsWithGroup := apiserverruntime.NewWebService(v1alpha3.GroupVersion)
-			RegisterWebhooks(fakeClient, wsWithGroup, &token.FakeIssuer{}, core.JenkinsCore{})
+			RegisterWebhooks(fakeClient, fakeClient, wsWithGroup, &token.FakeIssuer{}, core.JenkinsCore{}, nil,
+				record.NewFakeRecorder(10))
 			container.Add(wsWithGroup)
 
 			var bodyReader io.Reader
@@ -182,14 +187,68 @@ func TestJenkinsWebhook(t *testing.T) {
 }
 
 func TestSCMWebhook(t *testing.T) {
+	var recorder *record.FakeRecorder
 	defaultPipeline := &v1alpha3.Pipeline{}
 	defaultPipeline.SetName("fake")
 	defaultPipeline.SetNamespace("default")
 	defaultPipeline.SetAnnotations(map[string]string{
 		scmRefAnnotationKey: `["master"]`,
 		scmAnnotationKey:    "https://gitlab.com/linuxsuren/test",
+		v1alpha3.PipelineWebhookAllowUnsignedAnnoKey: "true",
 	})
 
+	// the unsigned deliveries are rejected by default
+	signedOnlyPipeline := defaultPipeline.DeepCopy()
+	delete(signedOnlyPipeline.Annotations, v1alpha3.PipelineWebhookAllowUnsignedAnnoKey)
+
+	webhookSecret := &corev1.Secret{}
+	webhookSecret.SetName("webhook-secret")
+	webhookSecret.SetNamespace("default")
+	webhookSecret.Type = corev1.SecretTypeOpaque
+	webhookSecret.Data = map[string][]byte{corev1.ServiceAccountTokenKey: []byte("secret")}
+
+	defaultWebhook := &v1alpha3.Webhook{}
+	defaultWebhook.SetName("webhook")
+	defaultWebhook.SetNamespace("default")
+	defaultWebhook.Spec.Secret = &corev1.SecretReference{Name: "webhook-secret"}
+
+	mrPipeline := defaultPipeline.DeepCopy()
+	mrPipeline.Annotations[scmEventsAnnotationKey] = `["mr"]`
+
+	defaultRepo := &v1alpha3.GitRepository{}
+	defaultRepo.SetName("repo")
+	defaultRepo.SetNamespace("default")
+	defaultRepo.Spec.URL = "https://gitlab.com/linuxsuren/test"
+	defaultRepo.Spec.Webhooks = []corev1.LocalObjectReference{{Name: "webhook"}}
+
+	otherPipeline := defaultPipeline.DeepCopy()
+	otherPipeline.SetNamespace("other")
+
+	linkedRepo := &v1alpha3.GitRepository{}
+	linkedRepo.SetName("repo")
+	linkedRepo.SetNamespace("default")
+	linkedRepo.Spec.URL = "git@gitlab.com:LinuxSuRen/test.git"
+
+	linkedPipeline := &v1alpha3.Pipeline{}
+	linkedPipeline.SetName("linked")
+	linkedPipeline.SetNamespace("default")
+	linkedPipeline.SetLabels(map[string]string{v1alpha3.PipelineGitRepositoryLabelKey: "repo"})
+	linkedPipeline.SetAnnotations(map[string]string{v1alpha3.PipelineWebhookAllowUnsignedAnnoKey: "true"})
+
+	// it's not linked to the repository because the GitRepository is in another namespace
+	unlinkedPipeline := linkedPipeline.DeepCopy()
+	unlinkedPipeline.SetNamespace("other")
+
+	multiBranchPipeline := &v1alpha3.Pipeline{}
+	multiBranchPipeline.SetName("multi-branch")
+	multiBranchPipeline.SetNamespace("default")
+	multiBranchPipeline.SetAnnotations(map[string]string{v1alpha3.PipelineWebhookAllowUnsignedAnnoKey: "true"})
+	multiBranchPipeline.Spec.Type = v1alpha3.MultiBranchPipelineType
+	multiBranchPipeline.Spec.MultiBranchPipeline = &v1alpha3.MultiBranchPipeline{
+		SourceType:   v1alpha3.SourceTypeGitlab,
+		GitlabSource: &v1alpha3.GitlabSource{Owner: "linuxsuren", Repo: "linuxsuren/test"},
+	}
+
 	type args struct {
 		method     string
 		uri        string
@@ -200,6 +259,7 @@ func TestSCMWebhook(t *testing.T) {
 	tests := []struct {
 		name      string
 		args      args
+		wantCode  int
 		assertion func(t *testing.T, c client.Client, body string)
 	}{{
 		name: "unknown SCM webhook",
@@ -237,7 +297,172 @@ func TestSCMWebhook(t *testing.T) {
 			},
 		},
 		assertion: func(t *testing.T, c client.Client, body string) {
-			assert.Equal(t, "ok", body)
+			assertDeliveryResult(t, c, body, pipelineResult{Namespace: "default", Name: "fake", Action: actionRun})
+		},
+	}, {
+		name: "gitlab webhook without any secret",
+		args: args{
+			method:     http.MethodPost,
+			uri:        "/webhooks/scm",
+			initObject: []runtime.Object{signedOnlyPipeline.DeepCopy()},
+			bodyJSON:   gitlabWebhookBody,
+			header: map[string]string{
+				"X-Gitlab-Event": "Push Hook",
+			},
+		},
+		wantCode: http.StatusForbidden,
+		assertion: func(t *testing.T, c client.Client, body string) {
+			assertDeliveryResult(t, c, body, pipelineResult{Namespace: "default", Name: "fake", Action: actionReject,
+				Error: errSecretMissing.Error()})
+			runs := &v1alpha3.PipelineRunList{}
+			assert.Nil(t, c.List(context.Background(), runs))
+			assert.Empty(t, runs.Items)
+			assertAuditEvent(t, recorder, corev1.EventTypeWarning, webhookRejectedReason)
+		},
+	}, {
+		name: "gitlab webhook without token, but the repository has a secret",
+		args: args{
+			method: http.MethodPost,
+			uri:    "/webhooks/scm",
+			initObject: []runtime.Object{defaultPipeline.DeepCopy(), webhookSecret.DeepCopy(),
+				defaultWebhook.DeepCopy(), defaultRepo.DeepCopy()},
+			bodyJSON: gitlabWebhookBody,
+			header: map[string]string{
+				"X-Gitlab-Event": "Push Hook",
+			},
+		},
+		wantCode: http.StatusUnauthorized,
+		assertion: func(t *testing.T, c client.Client, body string) {
+			runs := &v1alpha3.PipelineRunList{}
+			assert.Nil(t, c.List(context.Background(), runs))
+			assert.Empty(t, runs.Items)
+			assertAuditEvent(t, recorder, corev1.EventTypeWarning, webhookRejectedReason)
+		},
+	}, {
+		name: "gitlab webhook with an invalid token",
+		args: args{
+			method: http.MethodPost,
+			uri:    "/webhooks/scm",
+			initObject: []runtime.Object{defaultPipeline.DeepCopy(), webhookSecret.DeepCopy(),
+				defaultWebhook.DeepCopy(), defaultRepo.DeepCopy()},
+			bodyJSON: gitlabWebhookBody,
+			header: map[string]string{
+				"X-Gitlab-Event": "Push Hook",
+				"X-Gitlab-Token": "invalid",
+			},
+		},
+		wantCode: http.StatusForbidden,
+		assertion: func(t *testing.T, c client.Client, body string) {
+			runs := &v1alpha3.PipelineRunList{}
+			assert.Nil(t, c.List(context.Background(), runs))
+			assert.Empty(t, runs.Items)
+			assertAuditEvent(t, recorder, corev1.EventTypeWarning, webhookRejectedReason)
+		},
+	}, {
+		name: "gitlab webhook with a valid token",
+		args: args{
+			method: http.MethodPost,
+			uri:    "/webhooks/scm",
+			initObject: []runtime.Object{defaultPipeline.DeepCopy(), webhookSecret.DeepCopy(),
+				defaultWebhook.DeepCopy(), defaultRepo.DeepCopy()},
+			bodyJSON: gitlabWebhookBody,
+			header: map[string]string{
+				"X-Gitlab-Event": "Push Hook",
+				"X-Gitlab-Token": "secret",
+			},
+		},
+		assertion: func(t *testing.T, c client.Client, body string) {
+			assertDeliveryResult(t, c, body, pipelineResult{Namespace: "default", Name: "fake", Action: actionRun})
+			runs := &v1alpha3.PipelineRunList{}
+			assert.Nil(t, c.List(context.Background(), runs))
+			assert.Equal(t, 1, len(runs.Items))
+			assertAuditEvent(t, recorder, corev1.EventTypeNormal, webhookAcceptedReason)
+		},
+	}, {
+		name: "namespace-scoped gitlab webhook",
+		args: args{
+			method:     http.MethodPost,
+			uri:        "/webhooks/scm/namespaces/other",
+			initObject: []runtime.Object{defaultPipeline.DeepCopy(), otherPipeline.DeepCopy()},
+			bodyJSON:   gitlabWebhookBody,
+			header: map[string]string{
+				"X-Gitlab-Event": "Push Hook",
+			},
+		},
+		assertion: func(t *testing.T, c client.Client, body string) {
+			assertDeliveryResult(t, c, body, pipelineResult{Namespace: "other", Name: "fake", Action: actionRun})
+		},
+	}, {
+		name: "gitlab webhook triggers the Pipelines linked to the GitRepository",
+		args: args{
+			method: http.MethodPost,
+			uri:    "/webhooks/scm",
+			initObject: []runtime.Object{linkedRepo.DeepCopy(), linkedPipeline.DeepCopy(),
+				unlinkedPipeline.DeepCopy()},
+			bodyJSON: gitlabWebhookBody,
+			header: map[string]string{
+				"X-Gitlab-Event": "Push Hook",
+			},
+		},
+		assertion: func(t *testing.T, c client.Client, body string) {
+			assertDeliveryResult(t, c, body, pipelineResult{Namespace: "default", Name: "linked", Action: actionRun})
+		},
+	}, {
+		name: "gitlab webhook reports the error of each Pipeline",
+		args: args{
+			method:     http.MethodPost,
+			uri:        "/webhooks/scm",
+			initObject: []runtime.Object{defaultPipeline.DeepCopy(), multiBranchPipeline.DeepCopy()},
+			bodyJSON:   gitlabWebhookBody,
+			header: map[string]string{
+				"X-Gitlab-Event": "Push Hook",
+			},
+		},
+		wantCode: http.StatusBadRequest,
+		assertion: func(t *testing.T, c client.Client, body string) {
+			assertDeliveryResult(t, c, body,
+				pipelineResult{Namespace: "default", Name: "fake", Action: actionRun},
+				pipelineResult{Namespace: "default", Name: "multi-branch", Action: actionScan, Error: "failed"})
+		},
+	}, {
+		name: "gitlab merge request webhook without the events annotation",
+		args: args{
+			method:     http.MethodPost,
+			uri:        "/webhooks/scm",
+			initObject: []runtime.Object{defaultPipeline.DeepCopy()},
+			bodyJSON:   gitlabMergeRequestWebhookBody,
+			header: map[string]string{
+				"X-Gitlab-Event": "Merge Request Hook",
+			},
+		},
+		assertion: func(t *testing.T, c client.Client, body string) {
+			assert.Equal(t, "no pipeline matched", body)
+		},
+	}, {
+		name: "gitlab merge request webhook",
+		args: args{
+			method:     http.MethodPost,
+			uri:        "/webhooks/scm",
+			initObject: []runtime.Object{mrPipeline.DeepCopy()},
+			bodyJSON:   gitlabMergeRequestWebhookBody,
+			header: map[string]string{
+				"X-Gitlab-Event": "Merge Request Hook",
+			},
+		},
+		assertion: func(t *testing.T, c client.Client, body string) {
+			assertDeliveryResult(t, c, body, pipelineResult{Namespace: "default", Name: "fake", Action: actionRun})
+			runs := &v1alpha3.PipelineRunList{}
+			assert.Nil(t, c.List(context.Background(), runs))
+			if assert.Equal(t, 1, len(runs.Items)) {
+				run := runs.Items[0]
+				assert.Equal(t, &v1alpha3.SCM{RefType: v1alpha3.MergeRequest, RefName: "MR-1"}, run.Spec.SCM)
+				assert.Equal(t, []v1alpha3.Parameter{
+					{Name: headSHAParamName, Value: "da1560886d4f094c3e6c9ef40349f7d38b5d27d7"},
+					{Name: sourceBranchParamName, Value: "feat"},
+					{Name: prNumberParamName, Value: "1"},
+				}, run.Spec.Parameters)
+				assert.Equal(t, "webhook", run.Annotations[triggerAnnotationKey])
+			}
 		},
 	}}
 	for _, tt := range tests {
@@ -245,9 +470,10 @@ func TestSCMWebhook(t *testing.T) {
 			utilruntime.Must(v1alpha3.AddToScheme(scheme.Scheme))
 			fakeClient := fake.NewFakeClientWithScheme(scheme.Scheme, tt.args.initObject...)
 
+			recorder = record.NewFakeRecorder(10)
 			container := restful.NewContainer()
 			wsWithGroup := apiserverruntime.NewWebService(v1alpha3.GroupVersion)
-			RegisterWebhooks(fakeClient, wsWithGroup, &token.FakeIssuer{}, core.JenkinsCore{})
+			RegisterWebhooks(fakeClient, fakeClient, wsWithGroup, &token.FakeIssuer{}, core.JenkinsCore{}, nil, recorder)
 			container.Add(wsWithGroup)
 
 			var bodyReader io.Reader
@@ -263,7 +489,10 @@ func TestSCMWebhook(t *testing.T) {
 			}
 			httpWriter := httptest.NewRecorder()
 			container.Dispatch(httpWriter, httpRequest)
-			assert.Equal(t, 200, httpWriter.Code)
+			if tt.wantCode == 0 {
+				tt.wantCode = http.StatusOK
+			}
+			assert.Equal(t, tt.wantCode, httpWriter.Code)
 			if tt.assertion != nil {
 				body := httpWriter.Body
 				var bodyResponse string
@@ -276,6 +505,12 @@ func TestSCMWebhook(t *testing.T) {
 	}
 }
 
+func assertAuditEvent(t *testing.T, recorder *record.FakeRecorder, eventType, reason string) {
+	if assert.Equal(t, 1, len(recorder.Events)) {
+		assert.True(t, strings.HasPrefix(<-recorder.Events, eventType+" "+reason+" "))
+	}
+}
+
 const gitlabWebhookBody = `{
   "object_kind": "push",
   "event_name": "push",
@@ -393,3 +628,65 @@ func Test(t *testing.T) {
 	})
 	fmt.Println(err)
 }
+
+const gitlabMergeRequestWebhookBody = `{
+  "object_kind": "merge_request",
+  "event_type": "merge_request",
+  "user": {
+    "name": "Rick",
+    "username": "linuxsuren"
+  },
+  "project": {
+    "id": 30004467,
+    "name": "test",
+    "web_url": "https://gitlab.com/linuxsuren/test",
+    "git_ssh_url": "git@gitlab.com:linuxsuren/test.git",
+    "git_http_url": "https://gitlab.com/linuxsuren/test.git",
+    "path_with_namespace": "linuxsuren/test",
+    "default_branch": "master"
+  },
+  "object_attributes": {
+    "iid": 1,
+    "title": "feat: a new feature",
+    "state": "opened",
+    "action": "open",
+    "source_branch": "feat",
+    "target_branch": "master",
+    "last_commit": {
+      "id": "da1560886d4f094c3e6c9ef40349f7d38b5d27d7"
+    },
+    "source": {
+      "name": "test",
+      "web_url": "https://gitlab.com/linuxsuren/test",
+      "path_with_namespace": "linuxsuren/test"
+    },
+    "target": {
+      "name": "test",
+      "web_url": "https://gitlab.com/linuxsuren/test",
+      "path_with_namespace": "linuxsuren/test"
+    }
+  }
+}`
+
+// assertDeliveryResult asserts the Pipelines in the response of a delivery, the names of PipelineRuns are checked via the client
+func assertDeliveryResult(t *testing.T, c client.Client, body string, expected ...pipelineResult) {
+	result := &deliveryResult{}
+	if !assert.Nil(t, json.Unmarshal([]byte(body), result)) {
+		return
+	}
+	if !assert.Equal(t, len(expected), len(result.Pipelines)) {
+		return
+	}
+	for i, item := range result.Pipelines {
+		if item.Action == actionRun && item.Error == "" {
+			run := &v1alpha3.PipelineRun{}
+			assert.Nil(t, c.Get(context.Background(), types.NamespacedName{Namespace: item.Namespace, Name: item.PipelineRun}, run))
+			expected[i].PipelineRun = item.PipelineRun
+		}
+		if expected[i].Error != "" && item.Error != "" {
+			// only check if there is an error
+			expected[i].Error = item.Error
+		}
+		assert.Equal(t, expected[i], item)
+	}
+}
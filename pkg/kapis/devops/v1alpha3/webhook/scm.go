@@ -17,21 +17,31 @@ limitations under the License.
 package webhook
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/emicklei/go-restful"
 	"github.com/jenkins-x/go-scm/scm"
 	"github.com/jenkins-x/go-scm/scm/driver/bitbucket"
+	"github.com/jenkins-x/go-scm/scm/driver/gitea"
 	"github.com/jenkins-x/go-scm/scm/driver/github"
 	"github.com/jenkins-x/go-scm/scm/driver/gitlab"
+	"github.com/jenkins-x/go-scm/scm/driver/gogs"
+	"github.com/jenkins-x/go-scm/scm/driver/stash"
 	"github.com/jenkins-zh/jenkins-client/pkg/core"
 	"github.com/jenkins-zh/jenkins-client/pkg/job"
+	"io"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/client-go/tools/record"
 	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
-	"kubesphere.io/devops/pkg/client/devops"
 	"kubesphere.io/devops/pkg/jwt/token"
 	"kubesphere.io/devops/pkg/kapis/devops/v1alpha3/pipelinerun"
+	"kubesphere.io/devops/pkg/metrics"
+	"kubesphere.io/devops/pkg/utils/gitutil"
 	"net/http"
 	"regexp"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -41,27 +51,50 @@ import (
 
 // tokenExpireIn indicates that the temporary token issued by controller will be expired in some time.
 const tokenExpireIn time.Duration = 5 * time.Minute
-const scmAnnotationKey = "scm.devops.kubesphere.io"
+const scmAnnotationKey = v1alpha3.PipelineSCMURLAnnoKey
 const scmRefAnnotationKey = "scm.devops.kubesphere.io/ref"
 const triggerAnnotationKey = "devops.kubesphere.io/trigger"
 
+// unknownProvider is the provider of the webhook deliveries which are not supported
+const unknownProvider = "unknown"
+
 // SCMHandler handles requests from webhooks.
 type SCMHandler struct {
 	client.Client
-	issue   token.Issuer
-	jenkins core.JenkinsCore
+	issue    token.Issuer
+	jenkins  core.JenkinsCore
+	recorder record.EventRecorder
 }
 
 // NewSCMHandler creates a new handler for handling webhooks.
-func NewSCMHandler(genericClient client.Client, issue token.Issuer, jenkins core.JenkinsCore) *SCMHandler {
+// The recorder records the audit events of the deliveries on the Pipelines.
+func NewSCMHandler(genericClient client.Client, issue token.Issuer, jenkins core.JenkinsCore, recorder record.EventRecorder) *SCMHandler {
 	return &SCMHandler{
-		Client:  genericClient,
-		issue:   issue,
-		jenkins: jenkins,
+		Client:   genericClient,
+		issue:    issue,
+		jenkins:  jenkins,
+		recorder: recorder,
 	}
 }
 
 func getSCMClient(request *http.Request) *scm.Client {
+	// Gitea sends the headers of Gogs and GitHub as well, so it must be checked first
+	if request.Header.Get("X-Gitea-Event") != "" {
+		return newWebhookClient(scm.DriverGitea, &selfHostedWebhookService{
+			WebhookService: gitea.NewWebHookService(),
+			eventHeader:    "X-Gitea-Event",
+			requiredFields: giteaRequiredFields,
+		})
+	}
+
+	if request.Header.Get("X-Gogs-Event") != "" {
+		return newWebhookClient(scm.DriverGogs, &selfHostedWebhookService{
+			WebhookService: gogs.NewWebHookService(),
+			eventHeader:    "X-Gogs-Event",
+			requiredFields: gogsRequiredFields,
+		})
+	}
+
 	if request.Header.Get("X-Gitlab-Event") != "" {
 		return gitlab.NewDefault()
 	}
@@ -70,77 +103,354 @@ func getSCMClient(request *http.Request) *scm.Client {
 		return github.NewDefault()
 	}
 
-	if strings.HasPrefix(request.Header.Get("User-Agent"), "Bitbucket-Webhooks") {
+	if isBitbucketCloud(request) {
 		return bitbucket.NewDefault()
 	}
+
+	if isBitbucketServer(request) {
+		return newWebhookClient(scm.DriverStash, &selfHostedWebhookService{
+			WebhookService: stash.NewWebHookService(),
+			eventHeader:    "X-Event-Key",
+			requiredFields: stashRequiredFields,
+		})
+	}
+	return nil
+}
+
+// isBitbucketCloud indicates if the delivery comes from Bitbucket Cloud
+func isBitbucketCloud(request *http.Request) bool {
+	return strings.HasPrefix(request.Header.Get("User-Agent"), "Bitbucket-Webhooks") ||
+		request.Header.Get("X-Hook-UUID") != ""
+}
+
+// isBitbucketServer indicates if the delivery comes from Bitbucket Server. It sends the same event header as
+// Bitbucket Cloud, but neither the User-Agent nor the hook UUID.
+func isBitbucketServer(request *http.Request) bool {
+	return request.Header.Get("X-Event-Key") != "" && !isBitbucketCloud(request)
+}
+
+// newWebhookClient creates a client which is only able to parse the webhook deliveries.
+// The self-hosted git providers require the server address to create a complete client.
+func newWebhookClient(driver scm.Driver, service scm.WebhookService) *scm.Client {
+	return &scm.Client{
+		Driver:   driver,
+		Webhooks: service,
+	}
+}
+
+// selfHostedWebhookService parses the deliveries of a self-hosted git provider without its API server.
+// Only the events which are able to trigger Pipelines are parsed, the others, such as comments, are skipped
+// because parsing them requires the API server.
+type selfHostedWebhookService struct {
+	scm.WebhookService
+	// eventHeader is the header which carries the event type
+	eventHeader string
+	// requiredFields are the fields of the supported events which the driver reads without checking
+	requiredFields map[string][]string
+}
+
+// the fields are dot-separated paths in the payload
+var (
+	giteaRequiredFields = map[string][]string{
+		"push":   {"repository.owner", "sender.login"},
+		"create": {"repository.owner", "sender.login"},
+		"delete": {"repository.owner", "sender.login"},
+		"pull_request": {"repository.owner", "sender.login", "pull_request.title", "pull_request.user.login",
+			"pull_request.head.repo.owner", "pull_request.base.repo.owner", "pull_request.created_at", "pull_request.updated_at"},
+	}
+	gogsRequiredFields = map[string][]string{
+		"push":         {"commits"},
+		"create":       {},
+		"delete":       {},
+		"pull_request": {},
+	}
+	stashRequiredFields = map[string][]string{
+		"repo:refs_changed":   {"actor", "repository", "changes"},
+		"pr:opened":           {"actor", "pullRequest"},
+		"pr:modified":         {"actor", "pullRequest"},
+		"pr:from_ref_updated": {"actor", "pullRequest"},
+		"pr:merged":           {"actor", "pullRequest"},
+		"pr:declined":         {"actor", "pullRequest"},
+	}
+)
+
+// Parse validates the delivery of a supported event before parsing it
+func (s *selfHostedWebhookService) Parse(req *http.Request, fn scm.SecretFunc) (scm.Webhook, error) {
+	event := req.Header.Get(s.eventHeader)
+	fields, ok := s.requiredFields[event]
+	if !ok {
+		return nil, scm.UnknownWebhook{Event: event}
+	}
+	if req.Body == nil {
+		return nil, fmt.Errorf("no payload found in the %s delivery", event)
+	}
+
+	payload, err := io.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	if err = validatePayload(payload, fields); err != nil {
+		return nil, fmt.Errorf("invalid %s delivery, error: %v", event, err)
+	}
+	req.Body = io.NopCloser(bytes.NewReader(payload))
+	return s.WebhookService.Parse(req, fn)
+}
+
+// validatePayload checks if the payload is a JSON object, and none of the required fields is null or empty
+func validatePayload(payload []byte, fields []string) error {
+	object := map[string]interface{}{}
+	if err := json.Unmarshal(payload, &object); err != nil {
+		return err
+	}
+
+	for _, field := range fields {
+		var value interface{} = object
+		for _, key := range strings.Split(field, ".") {
+			if parent, ok := value.(map[string]interface{}); ok {
+				value = parent[key]
+			} else {
+				value = nil
+				break
+			}
+		}
+
+		switch val := value.(type) {
+		case nil:
+			return fmt.Errorf("the field '%s' is required", field)
+		case string:
+			if val == "" {
+				return fmt.Errorf("the field '%s' is empty", field)
+			}
+		case []interface{}:
+			if len(val) == 0 {
+				return fmt.Errorf("the field '%s' is empty", field)
+			}
+		}
+	}
 	return nil
 }
 
 func (h *SCMHandler) scmWebhook(request *restful.Request, response *restful.Response) {
-	scmClient := getSCMClient(request.Request)
+	// keep the payload, it's required when verifying the signature against the secrets
+	var payload []byte
+	if request.Request.Body != nil {
+		var err error
+		if payload, err = io.ReadAll(request.Request.Body); err != nil {
+			_, _ = response.Write([]byte(err.Error()))
+			return
+		}
+	}
+
+	// it's namespace-scoped if the delivery comes to the webhook address of a namespace
+	namespace := request.PathParameter("namespace")
+	ctx := context.TODO()
+	outcome := h.handleDelivery(ctx, request.Request, payload, namespace, false)
+	h.recordSCMDelivery(ctx, newDelivery(v1alpha3.WebhookDeliverySourceSCM, request.Request, payload), outcome, namespace)
+	outcome.write(response)
+}
+
+// scmDelivery is the outcome of handling a SCM webhook delivery
+type scmDelivery struct {
+	provider string
+	// event is nil if the delivery is not able to trigger any Pipelines
+	event *scmEvent
+	// code is the status code of the response
+	code int
+	// message is the plain text response if no Pipeline was triggered
+	message string
+	// result is the response if there are Pipelines matched
+	result *deliveryResult
+	// verifiedNamespaces are the namespaces where the signature of the delivery was verified
+	verifiedNamespaces sets.String
+}
+
+func (d *scmDelivery) write(response *restful.Response) {
+	if d.result == nil {
+		_ = response.WriteErrorString(d.code, d.message)
+		return
+	}
+	_ = response.WriteHeaderAndJson(d.code, d.result, restful.MIME_JSON)
+}
+
+// handleDelivery triggers the Pipelines which match the delivery, all namespaces are searched if the namespace is empty.
+// The signature verification is skipped if the delivery was verified already, such as a redelivery.
+func (h *SCMHandler) handleDelivery(ctx context.Context, req *http.Request, payload []byte, namespace string,
+	verified bool) (outcome *scmDelivery) {
+	outcome = &scmDelivery{provider: unknownProvider, code: http.StatusOK, verifiedNamespaces: sets.NewString()}
+	scmClient := getSCMClient(req)
 	if scmClient == nil {
-		_, _ = response.Write([]byte("unknown SCM type"))
+		metrics.WebhookDeliveryReceived(unknownProvider)
+		outcome.message = "unknown SCM type"
 		return
 	}
+	outcome.provider = scmClient.Driver.String()
+	metrics.WebhookDeliveryReceived(outcome.provider)
 
-	webhook, err := scmClient.Webhooks.Parse(request.Request, func(webhook scm.Webhook) (string, error) {
+	// parse it without verification, the secrets depend on the matched Pipelines
+	req.Body = io.NopCloser(bytes.NewReader(payload))
+	webhook, err := scmClient.Webhooks.Parse(req, func(webhook scm.Webhook) (string, error) {
 		return "", nil
 	})
 	if err != nil {
-		_, _ = response.Write([]byte(err.Error()))
+		outcome.message = err.Error()
 		return
 	}
 
-	ctx := context.TODO()
-	found := false
-	if webhook.Kind() == scm.WebhookKindPush {
-		repo := webhook.Repository()
-		pushHook := webhook.(*scm.PushHook)
-
-		pipelineList := &v1alpha3.PipelineList{}
-		if err = h.List(ctx, pipelineList); err == nil {
-			for i := range pipelineList.Items {
-				pipeline := pipelineList.Items[i]
-				if !branchMatch(pipeline, pushHook.Ref) {
-					continue
+	result := &deliveryResult{}
+	accepted := false
+	var rejectErr error
+	if event := newSCMEvent(webhook, scmClient.Driver); event != nil {
+		outcome.event = event
+		repo := event.repo
+
+		// verify the delivery for a namespace only once
+		verifiedNamespaces := map[string]error{}
+		verify := func(pipeline *v1alpha3.Pipeline) (verifyErr error) {
+			var ok bool
+			if verifyErr, ok = verifiedNamespaces[pipeline.Namespace]; !ok && !verified {
+				var secrets []string
+				if secrets, verifyErr = h.getWebhookSecrets(ctx, pipeline.Namespace, repo); verifyErr == nil {
+					verifyErr = verifySignature(scmClient, req, payload, secrets)
 				}
-				found = true
-
-				gitURL := pipeline.GetAnnotations()[scmAnnotationKey]
-				if pipeline.IsMultiBranch() {
-					gitURL = pipeline.Spec.MultiBranchPipeline.GetGitURL()
-					if gitURL != "" && gitRepoMatch(gitURL, repo.Link, repo.Clone, repo.CloneSSH) {
-						err = scanJenkinsMultiBranchPipeline(pipeline, h.jenkins, h.issue)
-					}
-				} else if gitURL != "" {
-					if gitRepoMatch(gitURL, repo.Link, repo.Clone, repo.CloneSSH) {
-						err = h.createPipelineRun(pipeline, pushHook)
-					} else {
-						err = fmt.Errorf("expect URL: %s, got: %v", gitURL, []string{repo.Link, repo.Clone, repo.CloneSSH})
-					}
+				verifiedNamespaces[pipeline.Namespace] = verifyErr
+			}
+			if verifyErr == nil {
+				outcome.verifiedNamespaces.Insert(pipeline.Namespace)
+			}
+			if errors.Is(verifyErr, errSecretMissing) && pipeline.AllowsUnsignedWebhook() {
+				verifyErr = nil
+			}
+
+			if verifyErr != nil {
+				rejectErr = verifyErr
+				h.recorder.Eventf(pipeline, v1.EventTypeWarning, webhookRejectedReason,
+					"rejected the %s delivery of %s from %s, error: %v", event.event, event.ref, repo.Link, verifyErr)
+			} else {
+				accepted = true
+				h.recorder.Eventf(pipeline, v1.EventTypeNormal, webhookAcceptedReason,
+					"accepted the %s delivery of %s from %s", event.event, event.ref, repo.Link)
+			}
+			return
+		}
+
+		var pipelines []v1alpha3.Pipeline
+		if pipelines, err = h.findPipelines(ctx, namespace, repo); err != nil {
+			outcome.code, outcome.message = http.StatusInternalServerError, err.Error()
+			return
+		}
+
+		for i := range pipelines {
+			pipeline := pipelines[i]
+			if !eventMatch(pipeline, event.event) || !branchMatch(pipeline, event.ref) {
+				continue
+			}
+
+			pipelineResult := pipelineResult{Namespace: pipeline.Namespace, Name: pipeline.Name}
+			var triggerErr error
+			if triggerErr = verify(&pipeline); triggerErr != nil {
+				pipelineResult.Action = actionReject
+			} else if pipeline.IsMultiBranch() {
+				pipelineResult.Action = actionScan
+				triggerErr = scanJenkinsMultiBranchPipeline(pipeline, h.jenkins, h.issue)
+			} else {
+				pipelineResult.Action = actionRun
+				pipelineResult.PipelineRun, triggerErr = h.createPipelineRun(pipeline, event)
+			}
+			if triggerErr != nil {
+				pipelineResult.Error = triggerErr.Error()
+				if pipelineResult.Action != actionReject {
+					err = triggerErr
 				}
 			}
+			result.Pipelines = append(result.Pipelines, pipelineResult)
 		}
 	}
 
-	if !found {
-		_ = response.WriteErrorString(http.StatusOK, "no pipeline matched")
+	if len(result.Pipelines) == 0 {
+		outcome.message = "no pipeline matched"
 		return
+	}
+
+	metrics.WebhookDeliveryMatched(outcome.provider)
+	if rejectErr != nil && !accepted {
+		metrics.WebhookDeliveryRejected(outcome.provider)
+		outcome.code = getStatusCode(rejectErr)
 	} else if err != nil {
-		_ = response.WriteError(http.StatusBadRequest, err)
-	} else {
-		_, _ = response.Write([]byte("ok"))
+		outcome.code = http.StatusBadRequest
 	}
+	outcome.result = result
+	return
 }
 
-func (h *SCMHandler) createPipelineRun(pipeline v1alpha3.Pipeline, hook *scm.PushHook) (err error) {
-	branch := strings.TrimPrefix(hook.Ref, "refs/heads/")
+// recordSCMDelivery records the delivery in the namespaces of the matched Pipelines and GitRepositories.
+// Only the given namespace is recorded if it's not empty. A delivery which matched nothing is not recorded,
+// or the anonymous deliveries would push the real ones out of the history. It returns the records.
+func (h *SCMHandler) recordSCMDelivery(ctx context.Context, delivery *v1alpha3.WebhookDelivery, outcome *scmDelivery,
+	namespace string) (records []*v1alpha3.WebhookDelivery) {
+	delivery.Spec.Provider = outcome.provider
+	delivery.Status.Code = outcome.code
+	delivery.Status.Message = outcome.message
+
+	namespaces := sets.NewString()
+	if event := outcome.event; event != nil {
+		delivery.Spec.Event = event.event
+		delivery.Spec.Ref = event.ref
+		delivery.Spec.Repository = event.repo.Link
+		for _, address := range []string{event.repo.Link, event.repo.Clone, event.repo.CloneSSH} {
+			if address != "" {
+				delivery.Spec.RepositoryURLs = append(delivery.Spec.RepositoryURLs, address)
+			}
+		}
 
-	var scmObj *v1alpha3.SCM
-	if scmObj, err = pipelinerun.CreateScm(&pipeline.Spec, branch); err == nil {
-		run := pipelinerun.CreatePipelineRun(&pipeline, &devops.RunPayload{}, scmObj)
-		run.Annotations[triggerAnnotationKey] = "webhook"
-		err = h.Create(context.Background(), run)
+		// record it for the GitRepositories as well, even if it didn't trigger anything
+		if gitRepos, err := h.findGitRepositories(ctx, namespace, event.repo); err == nil {
+			for i := range gitRepos {
+				namespaces.Insert(gitRepos[i].Namespace)
+			}
+		}
+	}
+	if outcome.result != nil {
+		for _, item := range outcome.result.Pipelines {
+			namespaces.Insert(item.Namespace)
+		}
+	}
+
+	for _, ns := range namespaces.List() {
+		record := delivery.DeepCopy()
+		record.Namespace = ns
+		record.Status.Verified = outcome.verifiedNamespaces.Has(ns)
+		if outcome.result != nil {
+			for _, item := range outcome.result.Pipelines {
+				if item.Namespace == ns {
+					record.Status.Pipelines = append(record.Status.Pipelines, v1alpha3.WebhookDeliveryPipeline{
+						Name:        item.Name,
+						Action:      item.Action,
+						PipelineRun: item.PipelineRun,
+						Error:       item.Error,
+					})
+				}
+			}
+		}
+		recordDelivery(ctx, h.Client, record)
+		records = append(records, record)
+	}
+	return
+}
+
+// createPipelineRun creates a PipelineRun for a regular Pipeline, then returns the name of it
+func (h *SCMHandler) createPipelineRun(pipeline v1alpha3.Pipeline, event *scmEvent) (name string, err error) {
+	scmObj := event.scm
+	if scmObj == nil {
+		branch := strings.TrimPrefix(event.ref, "refs/heads/")
+		if scmObj, err = pipelinerun.CreateScm(&pipeline.Spec, branch); err != nil {
+			return
+		}
+	}
+
+	run := pipelinerun.CreateBarePipelineRun(&pipeline, event.parameters, scmObj)
+	run.Annotations[triggerAnnotationKey] = "webhook"
+	if err = h.Create(context.Background(), run); err == nil {
+		name = run.Name
 	}
 	return
 }
@@ -184,13 +494,8 @@ func branchMatch(pipeline v1alpha3.Pipeline, branch string) (ok bool) {
 	return
 }
 
-// gitRepoMatch if the source matches target
-func gitRepoMatch(source string, targets ...string) (ok bool) {
-	for _, target := range targets {
-		if target == source {
-			ok = true
-			break
-		}
-	}
-	return
+// gitRepoMatch if the source matches target, the URLs are normalized before comparing.
+// So the case, the ".git" suffix and the form of SSH or HTTPS make no difference.
+func gitRepoMatch(source string, targets ...string) bool {
+	return gitutil.MatchURL(source, targets...)
 }
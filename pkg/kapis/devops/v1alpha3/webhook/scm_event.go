@@ -0,0 +1,185 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
+)
+
+// scmEventsAnnotationKey is the annotation key of the webhook events which a Pipeline is interested in.
+// The value is a JSON array, such as: ["push", "tag", "pr"]
+const scmEventsAnnotationKey = "scm.devops.kubesphere.io/events"
+
+const (
+	// eventPush represents pushing to a branch
+	eventPush = "push"
+	// eventTag represents pushing or creating a tag
+	eventTag = "tag"
+	// eventPullRequest represents a pull request of GitHub or Bitbucket
+	eventPullRequest = "pr"
+	// eventMergeRequest represents a merge request of GitLab
+	eventMergeRequest = "mr"
+)
+
+// defaultEvents are the events that a Pipeline is interested in if it doesn't have the events annotation.
+// Tags and pull requests are excluded, a Pipeline opts in to them via the events annotation.
+// Pull requests might come from untrusted forks.
+var defaultEvents = []string{eventPush}
+
+// parameters which are passed to the PipelineRun triggered by a tag or a pull request
+const (
+	headSHAParamName      = "SCM_HEAD_SHA"
+	sourceBranchParamName = "SCM_SOURCE_BRANCH"
+	prNumberParamName     = "SCM_PR_NUMBER"
+)
+
+// scmEvent is the normalized webhook delivery which is able to trigger Pipelines
+type scmEvent struct {
+	// event is one of push, tag, pr and mr
+	event string
+	// ref is the git reference to match the ref annotation of a Pipeline, such as: refs/heads/master
+	ref  string
+	repo scm.Repository
+	// scm is the SCM of the PipelineRun, it's nil for a push event
+	scm        *v1alpha3.SCM
+	parameters []v1alpha3.Parameter
+}
+
+// newSCMEvent converts a webhook into an event, returns nil if the webhook is not able to trigger any Pipelines.
+func newSCMEvent(webhook scm.Webhook, driver scm.Driver) *scmEvent {
+	switch hook := webhook.(type) {
+	case *scm.PushHook:
+		if hook.Deleted {
+			return nil
+		}
+		if strings.HasPrefix(hook.Ref, "refs/tags/") {
+			return newTagEvent(hook.Repo, hook.Ref, hook.After)
+		}
+		return &scmEvent{
+			event: eventPush,
+			ref:   hook.Ref,
+			repo:  hook.Repo,
+		}
+	case *scm.TagHook:
+		if hook.Action != scm.ActionCreate {
+			return nil
+		}
+		return newTagEvent(hook.Repo, "refs/tags/"+scm.TrimRef(hook.Ref.Name), hook.Ref.Sha)
+	case *scm.PullRequestHook:
+		return newPullRequestEvent(hook, driver)
+	}
+	return nil
+}
+
+func newTagEvent(repo scm.Repository, ref, sha string) *scmEvent {
+	return &scmEvent{
+		event: eventTag,
+		ref:   ref,
+		repo:  repo,
+		scm: &v1alpha3.SCM{
+			RefType: v1alpha3.Tag,
+			RefName: strings.TrimPrefix(ref, "refs/tags/"),
+		},
+		parameters: []v1alpha3.Parameter{{
+			Name:  headSHAParamName,
+			Value: sha,
+		}},
+	}
+}
+
+func newPullRequestEvent(hook *scm.PullRequestHook, driver scm.Driver) *scmEvent {
+	event, refType, refPrefix := eventPullRequest, v1alpha3.PullRequest, "PR"
+	if driver == scm.DriverGitlab {
+		event, refType, refPrefix = eventMergeRequest, v1alpha3.MergeRequest, "MR"
+	}
+
+	switch hook.Action {
+	case scm.ActionOpen, scm.ActionReopen, scm.ActionSync:
+	case scm.ActionUpdate:
+		// GitLab sends an update action when new commits are pushed into a merge request,
+		// but it means editing the title or body for others
+		if driver != scm.DriverGitlab {
+			return nil
+		}
+	default:
+		return nil
+	}
+
+	pr := hook.PullRequest
+	sha := pr.Sha
+	if sha == "" {
+		sha = pr.Head.Sha
+	}
+	source := pr.Source
+	if source == "" {
+		source = pr.Head.Ref
+	}
+	target := pr.Target
+	if target == "" {
+		target = pr.Base.Ref
+	}
+
+	return &scmEvent{
+		event: event,
+		// match the ref annotation with the target branch
+		ref:  "refs/heads/" + target,
+		repo: hook.Repo,
+		scm: &v1alpha3.SCM{
+			RefType: refType,
+			RefName: fmt.Sprintf("%s-%d", refPrefix, pr.Number),
+		},
+		parameters: []v1alpha3.Parameter{{
+			Name:  headSHAParamName,
+			Value: sha,
+		}, {
+			Name:  sourceBranchParamName,
+			Value: source,
+		}, {
+			Name:  prNumberParamName,
+			Value: strconv.Itoa(pr.Number),
+		}},
+	}
+}
+
+// eventMatch matches the event with the events annotation of a Pipeline.
+// Pull request and merge request are treated as the same one.
+func eventMatch(pipeline v1alpha3.Pipeline, event string) bool {
+	events := defaultEvents
+	if eventRules, ok := pipeline.Annotations[scmEventsAnnotationKey]; ok {
+		events = nil
+		if err := json.Unmarshal([]byte(eventRules), &events); err != nil {
+			return false
+		}
+	}
+
+	for _, item := range events {
+		if item == event || (isPullRequestEvent(item) && isPullRequestEvent(event)) {
+			return true
+		}
+	}
+	return false
+}
+
+func isPullRequestEvent(event string) bool {
+	return event == eventPullRequest || event == eventMergeRequest
+}
@@ -0,0 +1,209 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"testing"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
+)
+
+func Test_newSCMEvent(t *testing.T) {
+	repo := scm.Repository{Link: "https://github.com/linuxsuren/test"}
+	pullRequest := scm.PullRequest{
+		Number: 12,
+		Sha:    "sha",
+		Source: "feat",
+		Target: "master",
+	}
+	prParameters := []v1alpha3.Parameter{
+		{Name: headSHAParamName, Value: "sha"},
+		{Name: sourceBranchParamName, Value: "feat"},
+		{Name: prNumberParamName, Value: "12"},
+	}
+
+	tests := []struct {
+		name    string
+		webhook scm.Webhook
+		driver  scm.Driver
+		want    *scmEvent
+	}{{
+		name:    "push to a branch",
+		webhook: &scm.PushHook{Ref: "refs/heads/master", Repo: repo, After: "sha"},
+		want:    &scmEvent{event: eventPush, ref: "refs/heads/master", repo: repo},
+	}, {
+		name:    "delete a branch",
+		webhook: &scm.PushHook{Ref: "refs/heads/master", Repo: repo, Deleted: true},
+		want:    nil,
+	}, {
+		name:    "push a tag",
+		webhook: &scm.PushHook{Ref: "refs/tags/v1.0.0", Repo: repo, After: "sha"},
+		want: &scmEvent{
+			event:      eventTag,
+			ref:        "refs/tags/v1.0.0",
+			repo:       repo,
+			scm:        &v1alpha3.SCM{RefType: v1alpha3.Tag, RefName: "v1.0.0"},
+			parameters: []v1alpha3.Parameter{{Name: headSHAParamName, Value: "sha"}},
+		},
+	}, {
+		name:    "create a tag",
+		webhook: &scm.TagHook{Ref: scm.Reference{Name: "v1.0.0", Sha: "sha"}, Repo: repo, Action: scm.ActionCreate},
+		want: &scmEvent{
+			event:      eventTag,
+			ref:        "refs/tags/v1.0.0",
+			repo:       repo,
+			scm:        &v1alpha3.SCM{RefType: v1alpha3.Tag, RefName: "v1.0.0"},
+			parameters: []v1alpha3.Parameter{{Name: headSHAParamName, Value: "sha"}},
+		},
+	}, {
+		name:    "delete a tag",
+		webhook: &scm.TagHook{Ref: scm.Reference{Name: "v1.0.0"}, Repo: repo, Action: scm.ActionDelete},
+		want:    nil,
+	}, {
+		name:    "open a pull request",
+		webhook: &scm.PullRequestHook{Action: scm.ActionOpen, Repo: repo, PullRequest: pullRequest},
+		driver:  scm.DriverGithub,
+		want: &scmEvent{
+			event:      eventPullRequest,
+			ref:        "refs/heads/master",
+			repo:       repo,
+			scm:        &v1alpha3.SCM{RefType: v1alpha3.PullRequest, RefName: "PR-12"},
+			parameters: prParameters,
+		},
+	}, {
+		name:    "synchronize a pull request",
+		webhook: &scm.PullRequestHook{Action: scm.ActionSync, Repo: repo, PullRequest: pullRequest},
+		driver:  scm.DriverGithub,
+		want: &scmEvent{
+			event:      eventPullRequest,
+			ref:        "refs/heads/master",
+			repo:       repo,
+			scm:        &v1alpha3.SCM{RefType: v1alpha3.PullRequest, RefName: "PR-12"},
+			parameters: prParameters,
+		},
+	}, {
+		name:    "edit a pull request",
+		webhook: &scm.PullRequestHook{Action: scm.ActionUpdate, Repo: repo, PullRequest: pullRequest},
+		driver:  scm.DriverGithub,
+		want:    nil,
+	}, {
+		name:    "close a pull request",
+		webhook: &scm.PullRequestHook{Action: scm.ActionClose, Repo: repo, PullRequest: pullRequest},
+		driver:  scm.DriverGithub,
+		want:    nil,
+	}, {
+		name:    "update a merge request",
+		webhook: &scm.PullRequestHook{Action: scm.ActionUpdate, Repo: repo, PullRequest: pullRequest},
+		driver:  scm.DriverGitlab,
+		want: &scmEvent{
+			event:      eventMergeRequest,
+			ref:        "refs/heads/master",
+			repo:       repo,
+			scm:        &v1alpha3.SCM{RefType: v1alpha3.MergeRequest, RefName: "MR-12"},
+			parameters: prParameters,
+		},
+	}, {
+		name: "pull request with head and base only",
+		webhook: &scm.PullRequestHook{Action: scm.ActionOpen, Repo: repo, PullRequest: scm.PullRequest{
+			Number: 12,
+			Head:   scm.PullRequestBranch{Ref: "feat", Sha: "sha"},
+			Base:   scm.PullRequestBranch{Ref: "master"},
+		}},
+		driver: scm.DriverBitbucket,
+		want: &scmEvent{
+			event:      eventPullRequest,
+			ref:        "refs/heads/master",
+			repo:       repo,
+			scm:        &v1alpha3.SCM{RefType: v1alpha3.PullRequest, RefName: "PR-12"},
+			parameters: prParameters,
+		},
+	}, {
+		name:    "not supported webhook",
+		webhook: &scm.IssueHook{},
+		want:    nil,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, newSCMEvent(tt.webhook, tt.driver))
+		})
+	}
+}
+
+func Test_eventMatch(t *testing.T) {
+	newPipeline := func(events string) v1alpha3.Pipeline {
+		pipeline := v1alpha3.Pipeline{ObjectMeta: v1.ObjectMeta{Annotations: map[string]string{}}}
+		if events != "" {
+			pipeline.Annotations[scmEventsAnnotationKey] = events
+		}
+		return pipeline
+	}
+
+	tests := []struct {
+		name     string
+		pipeline v1alpha3.Pipeline
+		event    string
+		want     bool
+	}{{
+		name:     "push without annotation",
+		pipeline: newPipeline(""),
+		event:    eventPush,
+		want:     true,
+	}, {
+		name:     "tag without annotation",
+		pipeline: newPipeline(""),
+		event:    eventTag,
+		want:     false,
+	}, {
+		name:     "tag is in the annotation",
+		pipeline: newPipeline(`["push", "tag"]`),
+		event:    eventTag,
+		want:     true,
+	}, {
+		name:     "pull request without annotation",
+		pipeline: newPipeline(""),
+		event:    eventPullRequest,
+		want:     false,
+	}, {
+		name:     "pull request is in the annotation",
+		pipeline: newPipeline(`["pr"]`),
+		event:    eventPullRequest,
+		want:     true,
+	}, {
+		name:     "merge request is the same as pull request",
+		pipeline: newPipeline(`["pr"]`),
+		event:    eventMergeRequest,
+		want:     true,
+	}, {
+		name:     "push is not in the annotation",
+		pipeline: newPipeline(`["tag", "mr"]`),
+		event:    eventPush,
+		want:     false,
+	}, {
+		name:     "invalid annotation",
+		pipeline: newPipeline(`push`),
+		event:    eventPush,
+		want:     false,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, eventMatch(tt.pipeline, tt.event))
+		})
+	}
+}
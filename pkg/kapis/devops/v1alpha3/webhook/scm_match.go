@@ -0,0 +1,126 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"context"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
+	"kubesphere.io/devops/pkg/utils/gitutil"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// deliveryResult is the response of a SCM webhook delivery
+type deliveryResult struct {
+	// Pipelines are the Pipelines which matched the delivery
+	Pipelines []pipelineResult `json:"pipelines"`
+}
+
+// pipelineResult is what a delivery did to a Pipeline
+type pipelineResult struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	// Action is one of run, scan and reject
+	Action string `json:"action"`
+	// PipelineRun is the name of the PipelineRun which was created for a regular Pipeline
+	PipelineRun string `json:"pipelineRun,omitempty"`
+	// Error is the reason why the Pipeline was not triggered
+	Error string `json:"error,omitempty"`
+}
+
+// getRepoURLs returns the normalized addresses of the repository which sends the delivery
+func getRepoURLs(repo scm.Repository) []string {
+	urls := sets.NewString()
+	for _, address := range []string{repo.Link, repo.Clone, repo.CloneSSH} {
+		if normalized := gitutil.NormalizeURL(address); normalized != "" {
+			urls.Insert(normalized)
+		}
+	}
+	return urls.List()
+}
+
+// findPipelines finds the Pipelines which are triggered by the repository, all namespaces are searched if it's empty.
+// A Pipeline matches if its git URL is the repository, or it's linked to a GitRepository of the repository via the label.
+func (h *SCMHandler) findPipelines(ctx context.Context, namespace string, repo scm.Repository) (pipelines []v1alpha3.Pipeline, err error) {
+	targets := []string{repo.Link, repo.Clone, repo.CloneSSH}
+	found := map[types.NamespacedName]bool{}
+	collect := func(pipeline v1alpha3.Pipeline) {
+		key := types.NamespacedName{Namespace: pipeline.Namespace, Name: pipeline.Name}
+		if !found[key] {
+			found[key] = true
+			pipelines = append(pipelines, pipeline)
+		}
+	}
+
+	for _, repoURL := range getRepoURLs(repo) {
+		pipelineList := &v1alpha3.PipelineList{}
+		if err = h.List(ctx, pipelineList, client.InNamespace(namespace),
+			client.MatchingFields{v1alpha3.PipelineGitURLIndexerName: repoURL}); err != nil {
+			return
+		}
+		for i := range pipelineList.Items {
+			// double check it in case the client doesn't support the field selector
+			if gitutil.MatchAnyURL(pipelineList.Items[i].GetGitURLs(), targets...) {
+				collect(pipelineList.Items[i])
+			}
+		}
+
+	}
+
+	var gitRepos []v1alpha3.GitRepository
+	if gitRepos, err = h.findGitRepositories(ctx, namespace, repo); err != nil {
+		return
+	}
+	for i := range gitRepos {
+		gitRepo := gitRepos[i]
+		linkedList := &v1alpha3.PipelineList{}
+		if err = h.List(ctx, linkedList, client.InNamespace(gitRepo.Namespace),
+			client.MatchingLabels{v1alpha3.PipelineGitRepositoryLabelKey: gitRepo.Name}); err != nil {
+			return
+		}
+		for j := range linkedList.Items {
+			collect(linkedList.Items[j])
+		}
+	}
+	return
+}
+
+// findGitRepositories finds the GitRepositories of the repository, all namespaces are searched if it's empty
+func (h *SCMHandler) findGitRepositories(ctx context.Context, namespace string, repo scm.Repository) (
+	gitRepos []v1alpha3.GitRepository, err error) {
+	found := map[types.NamespacedName]bool{}
+	for _, repoURL := range getRepoURLs(repo) {
+		repoList := &v1alpha3.GitRepositoryList{}
+		if err = h.List(ctx, repoList, client.InNamespace(namespace),
+			client.MatchingFields{v1alpha3.GitRepositoryURLIndexerName: repoURL}); err != nil {
+			return
+		}
+		for i := range repoList.Items {
+			gitRepo := repoList.Items[i]
+			key := types.NamespacedName{Namespace: gitRepo.Namespace, Name: gitRepo.Name}
+			// double check it in case the client doesn't support the field selector
+			if !found[key] && gitRepoMatch(gitRepo.Spec.URL, repo.Link, repo.Clone, repo.CloneSSH) {
+				found[key] = true
+				gitRepos = append(gitRepos, gitRepo)
+			}
+		}
+	}
+	return
+}
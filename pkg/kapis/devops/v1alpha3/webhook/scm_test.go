@@ -19,12 +19,16 @@ package webhook
 import (
 	"github.com/jenkins-x/go-scm/scm"
 	"github.com/jenkins-x/go-scm/scm/driver/bitbucket"
+	"github.com/jenkins-x/go-scm/scm/driver/gitea"
 	"github.com/jenkins-x/go-scm/scm/driver/github"
 	"github.com/jenkins-x/go-scm/scm/driver/gitlab"
+	"github.com/jenkins-x/go-scm/scm/driver/gogs"
+	"github.com/jenkins-x/go-scm/scm/driver/stash"
 	"github.com/stretchr/testify/assert"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
 	"net/http"
+	"strings"
 	"testing"
 )
 
@@ -69,6 +73,54 @@ func Test_getSCMClient(t *testing.T) {
 			},
 		},
 		want: bitbucket.NewDefault(),
+	}, {
+		name: "gitea",
+		args: args{
+			request: func() *http.Request {
+				defaultRequest := &http.Request{}
+				defaultRequest.Header = map[string][]string{}
+				defaultRequest.Header.Add("X-Gitea-Event", "push")
+				defaultRequest.Header.Add("X-Gogs-Event", "push")
+				defaultRequest.Header.Add("X-GitHub-Event", "push")
+				return defaultRequest
+			},
+		},
+		want: newWebhookClient(scm.DriverGitea, &selfHostedWebhookService{
+			WebhookService: gitea.NewWebHookService(),
+			eventHeader:    "X-Gitea-Event",
+			requiredFields: giteaRequiredFields,
+		}),
+	}, {
+		name: "gogs",
+		args: args{
+			request: func() *http.Request {
+				defaultRequest := &http.Request{}
+				defaultRequest.Header = map[string][]string{}
+				defaultRequest.Header.Add("X-Gogs-Event", "push")
+				return defaultRequest
+			},
+		},
+		want: newWebhookClient(scm.DriverGogs, &selfHostedWebhookService{
+			WebhookService: gogs.NewWebHookService(),
+			eventHeader:    "X-Gogs-Event",
+			requiredFields: gogsRequiredFields,
+		}),
+	}, {
+		name: "bitbucket server",
+		args: args{
+			request: func() *http.Request {
+				defaultRequest := &http.Request{}
+				defaultRequest.Header = map[string][]string{}
+				defaultRequest.Header.Add("X-Event-Key", "repo:refs_changed")
+				defaultRequest.Header.Add("X-Request-Id", "guid")
+				return defaultRequest
+			},
+		},
+		want: newWebhookClient(scm.DriverStash, &selfHostedWebhookService{
+			WebhookService: stash.NewWebHookService(),
+			eventHeader:    "X-Event-Key",
+			requiredFields: stashRequiredFields,
+		}),
 	}, {
 		name: "unknown SCM provider",
 		args: args{
@@ -85,6 +137,56 @@ func Test_getSCMClient(t *testing.T) {
 	}
 }
 
+func Test_selfHostedWebhookService(t *testing.T) {
+	service := &selfHostedWebhookService{
+		WebhookService: gitea.NewWebHookService(),
+		eventHeader:    "X-Gitea-Event",
+		requiredFields: giteaRequiredFields,
+	}
+	parse := func(event, payload string) (scm.Webhook, error) {
+		request, _ := http.NewRequest(http.MethodPost, "http://fake.com/webhooks/scm", strings.NewReader(payload))
+		request.Header.Set("X-Gitea-Event", event)
+		return service.Parse(request, func(scm.Webhook) (string, error) {
+			return "", nil
+		})
+	}
+
+	// skip the comments
+	_, err := parse("issue_comment", `{"issue":{"pull_request":{}}}`)
+	assert.Equal(t, scm.UnknownWebhook{Event: "issue_comment"}, err)
+
+	// the malformed deliveries
+	_, err = parse("pull_request", `{"action":"opened"}`)
+	assert.EqualError(t, err, "invalid pull_request delivery, error: the field 'repository.owner' is required")
+	_, err = parse("push", `{"repository":{"owner":{}},"sender":{"login":""}}`)
+	assert.EqualError(t, err, "invalid push delivery, error: the field 'sender.login' is empty")
+	_, err = parse("push", `{`)
+	assert.NotNil(t, err)
+
+	webhook, err := parse("push", `{"ref":"refs/heads/master","after":"sha","pusher":{"login":"linuxsuren"},
+"sender":{"login":"linuxsuren"},"repository":{"name":"test","full_name":"linuxsuren/test","owner":{"login":"linuxsuren"},
+"html_url":"https://gitea.com/linuxsuren/test"}}`)
+	assert.Nil(t, err)
+	if assert.IsType(t, &scm.PushHook{}, webhook) {
+		hook := webhook.(*scm.PushHook)
+		assert.Equal(t, "refs/heads/master", hook.Ref)
+		assert.Equal(t, "https://gitea.com/linuxsuren/test", hook.Repo.Link)
+	}
+
+	// Bitbucket Server does not check the actor of a pull request
+	stashService := &selfHostedWebhookService{
+		WebhookService: stash.NewWebHookService(),
+		eventHeader:    "X-Event-Key",
+		requiredFields: stashRequiredFields,
+	}
+	request, _ := http.NewRequest(http.MethodPost, "http://fake.com/webhooks/scm", strings.NewReader(`{"pullRequest":{}}`))
+	request.Header.Set("X-Event-Key", "pr:opened")
+	_, err = stashService.Parse(request, func(scm.Webhook) (string, error) {
+		return "", nil
+	})
+	assert.EqualError(t, err, "invalid pr:opened delivery, error: the field 'actor' is required")
+}
+
 func Test_branchMatch(t *testing.T) {
 	type args struct {
 		pipeline v1alpha3.Pipeline
@@ -134,3 +236,82 @@ func Test_branchMatch(t *testing.T) {
 		})
 	}
 }
+
+func Test_gitRepoMatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  string
+		targets []string
+		wantOk  bool
+	}{{
+		name:    "exactly same",
+		source:  "https://github.com/linuxsuren/tools",
+		targets: []string{"https://github.com/linuxsuren/tools"},
+		wantOk:  true,
+	}, {
+		name:    "different case and the .git suffix",
+		source:  "https://github.com/LinuxSuRen/tools",
+		targets: []string{"https://github.com/linuxsuren/tools.git"},
+		wantOk:  true,
+	}, {
+		name:    "SSH",
+		source:  "https://github.example.com/linuxsuren/tools",
+		targets: []string{"", "", "git@github.example.com:linuxsuren/tools.git"},
+		wantOk:  true,
+	}, {
+		name:    "different repository",
+		source:  "https://github.com/linuxsuren/tools",
+		targets: []string{"https://github.com/linuxsuren/tool", "git@github.example.com:linuxsuren/tools.git"},
+		wantOk:  false,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equalf(t, tt.wantOk, gitRepoMatch(tt.source, tt.targets...), "gitRepoMatch(%v, %v)", tt.source, tt.targets)
+		})
+	}
+}
+
+func Test_validatePayload(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		fields  []string
+		wantErr string
+	}{{
+		name:    "not a JSON object",
+		payload: `[]`,
+		wantErr: "json: cannot unmarshal array into Go value of type map[string]interface {}",
+	}, {
+		name:    "no required fields",
+		payload: `{}`,
+	}, {
+		name:    "all required fields exist",
+		payload: `{"actor":{},"repository":{"owner":{"login":"admin"}},"changes":[{}]}`,
+		fields:  []string{"actor", "repository.owner.login", "changes"},
+	}, {
+		name:    "a null field",
+		payload: `{"actor":null}`,
+		fields:  []string{"actor"},
+		wantErr: "the field 'actor' is required",
+	}, {
+		name:    "the parent is not an object",
+		payload: `{"repository":"test"}`,
+		fields:  []string{"repository.owner"},
+		wantErr: "the field 'repository.owner' is required",
+	}, {
+		name:    "an empty array",
+		payload: `{"changes":[]}`,
+		fields:  []string{"changes"},
+		wantErr: "the field 'changes' is empty",
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validatePayload([]byte(tt.payload), tt.fields)
+			if tt.wantErr == "" {
+				assert.Nil(t, err)
+			} else {
+				assert.EqualError(t, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,210 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/jenkins-x/go-scm/scm"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// webhookAcceptedReason is the event reason of a delivery which triggered the Pipeline
+	webhookAcceptedReason = "WebhookAccepted"
+	// webhookRejectedReason is the event reason of a delivery which failed the verification
+	webhookRejectedReason = "WebhookRejected"
+	// eventSourceComponent is the component name of the audit events
+	eventSourceComponent = "devops-apiserver"
+)
+
+var (
+	// errSignatureMissing indicates that the delivery doesn't carry any signature or token,
+	// but the target repository has a secret.
+	errSignatureMissing = errors.New("missing webhook signature")
+	// errSecretMissing indicates that the target repository doesn't have any secret, so the delivery is not able to
+	// be verified. It's rejected unless the Pipeline allows the unsigned deliveries explicitly.
+	errSecretMissing = errors.New("no webhook secret is configured for the repository")
+)
+
+// getSignature returns the signature or token of a webhook delivery.
+// Different git providers put it in different places.
+func getSignature(request *http.Request) string {
+	// Gitea sends the headers of Gogs and GitHub as well, so it must be checked first
+	if request.Header.Get("X-Gitea-Event") != "" {
+		return request.Header.Get("X-Gitea-Signature")
+	}
+
+	if request.Header.Get("X-Gogs-Event") != "" {
+		return request.Header.Get("X-Gogs-Signature")
+	}
+
+	if request.Header.Get("X-Gitlab-Event") != "" {
+		return request.Header.Get("X-Gitlab-Token")
+	}
+
+	if request.Header.Get("X-GitHub-Event") != "" {
+		if signature := request.Header.Get("X-Hub-Signature"); signature != "" {
+			return signature
+		}
+		return request.Header.Get("X-Hub-Signature-256")
+	}
+
+	if isBitbucketCloud(request) {
+		return request.URL.Query().Get("secret")
+	}
+
+	if isBitbucketServer(request) {
+		return request.Header.Get("X-Hub-Signature")
+	}
+	return ""
+}
+
+// verifySignature checks the delivery against the secrets. It passes if any of them matches.
+// The payload is required because the body of the original request was consumed already.
+func verifySignature(scmClient *scm.Client, request *http.Request, payload []byte, secrets []string) (err error) {
+	if len(secrets) == 0 {
+		return errSecretMissing
+	}
+	if getSignature(request) == "" {
+		return errSignatureMissing
+	}
+
+	err = scm.ErrSignatureInvalid
+	for _, secret := range secrets {
+		req := request.Clone(request.Context())
+		req.Body = io.NopCloser(bytes.NewReader(payload))
+		if req.Header.Get("X-GitHub-Event") != "" && req.Header.Get("X-Hub-Signature") == "" {
+			// go-scm only takes X-Hub-Signature, but it is able to validate a sha256 signature
+			req.Header.Set("X-Hub-Signature", req.Header.Get("X-Hub-Signature-256"))
+		}
+
+		secret := secret
+		if _, err = scmClient.Webhooks.Parse(req, func(scm.Webhook) (string, error) {
+			return secret, nil
+		}); err == nil {
+			break
+		}
+	}
+	return
+}
+
+// getStatusCode returns the HTTP status code of a verification error
+func getStatusCode(err error) int {
+	switch {
+	case errors.Is(err, errSignatureMissing):
+		return http.StatusUnauthorized
+	case errors.Is(err, scm.ErrSignatureInvalid), errors.Is(err, errSecretMissing):
+		return http.StatusForbidden
+	}
+	return http.StatusBadRequest
+}
+
+// getWebhookSecrets returns the secrets of the GitRepositories which match the given repository.
+// The secret of a Webhook takes precedence over the one of its GitRepository.
+func (h *SCMHandler) getWebhookSecrets(ctx context.Context, namespace string, repo scm.Repository) (secrets []string, err error) {
+	repoList := &v1alpha3.GitRepositoryList{}
+	if err = h.List(ctx, repoList, client.InNamespace(namespace)); err != nil {
+		return
+	}
+
+	for i := range repoList.Items {
+		gitRepo := repoList.Items[i]
+		if gitRepo.Spec.URL == "" || !gitRepoMatch(gitRepo.Spec.URL, repo.Link, repo.Clone, repo.CloneSSH) {
+			continue
+		}
+
+		var refs []*v1.SecretReference
+		for _, webhookRef := range gitRepo.Spec.Webhooks {
+			webhook := &v1alpha3.Webhook{}
+			if err = h.Get(ctx, types.NamespacedName{Namespace: namespace, Name: webhookRef.Name}, webhook); err != nil {
+				err = fmt.Errorf("cannot get webhook '%s', error: %v", webhookRef.Name, err)
+				return
+			}
+			if webhook.Spec.Secret != nil {
+				refs = append(refs, webhook.Spec.Secret)
+			} else if gitRepo.Spec.Secret != nil {
+				refs = append(refs, gitRepo.Spec.Secret)
+			}
+		}
+		if len(gitRepo.Spec.Webhooks) == 0 && gitRepo.Spec.Secret != nil {
+			refs = append(refs, gitRepo.Spec.Secret)
+		}
+
+		for _, ref := range refs {
+			var secret string
+			if secret, err = h.getTokenFromSecret(ctx, ref, namespace); err != nil {
+				return
+			}
+			if secret != "" && !contains(secrets, secret) {
+				secrets = append(secrets, secret)
+			}
+		}
+	}
+	return
+}
+
+// getTokenFromSecret reads the token in the same way as the GitRepository controller registers it
+func (h *SCMHandler) getTokenFromSecret(ctx context.Context, ref *v1.SecretReference, defaultNamespace string) (token string, err error) {
+	ns := ref.Namespace
+	if ns == "" {
+		ns = defaultNamespace
+	}
+
+	secret := &v1.Secret{}
+	if err = h.Get(ctx, types.NamespacedName{Namespace: ns, Name: ref.Name}, secret); err != nil {
+		err = fmt.Errorf("cannot get secret '%s/%s', error: %v", ns, ref.Name, err)
+		return
+	}
+
+	switch secret.Type {
+	case v1.SecretTypeBasicAuth:
+		token = string(secret.Data[v1.BasicAuthPasswordKey])
+	case v1.SecretTypeOpaque:
+		token = string(secret.Data[v1.ServiceAccountTokenKey])
+	}
+	return
+}
+
+// NewEventRecorder returns a recorder of the audit events of the webhook deliveries, which are recorded on the Pipelines.
+// The scheme must be able to resolve the kind of Pipelines.
+func NewEventRecorder(kubeClient kubernetes.Interface, scheme *runtime.Scheme) record.EventRecorder {
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: kubeClient.CoreV1().Events("")})
+	return broadcaster.NewRecorder(scheme, v1.EventSource{Component: eventSourceComponent})
+}
+
+func contains(array []string, item string) bool {
+	for _, val := range array {
+		if val == item {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,276 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/go-scm/scm/driver/github"
+	"github.com/jenkins-zh/jenkins-client/pkg/core"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func Test_getSignature(t *testing.T) {
+	tests := []struct {
+		name    string
+		url     string
+		headers map[string]string
+		want    string
+	}{{
+		name:    "gitlab",
+		headers: map[string]string{"X-Gitlab-Event": "Push Hook", "X-Gitlab-Token": "token"},
+		want:    "token",
+	}, {
+		name:    "github with sha1 signature",
+		headers: map[string]string{"X-GitHub-Event": "push", "X-Hub-Signature": "sha1=abc"},
+		want:    "sha1=abc",
+	}, {
+		name:    "github with sha256 signature only",
+		headers: map[string]string{"X-GitHub-Event": "push", "X-Hub-Signature-256": "sha256=abc"},
+		want:    "sha256=abc",
+	}, {
+		name:    "bitbucket",
+		url:     "http://fake.com/webhooks/scm?secret=token",
+		headers: map[string]string{"User-Agent": "Bitbucket-Webhooks/2.0", "X-Event-Key": "repo:push"},
+		want:    "token",
+	}, {
+		name:    "bitbucket server",
+		url:     "http://fake.com/webhooks/scm?secret=token",
+		headers: map[string]string{"X-Event-Key": "repo:refs_changed", "X-Hub-Signature": "sha256=abc"},
+		want:    "sha256=abc",
+	}, {
+		name: "gitea",
+		headers: map[string]string{"X-Gitea-Event": "push", "X-Gogs-Event": "push", "X-GitHub-Event": "push",
+			"X-Gitea-Signature": "gitea", "X-Gogs-Signature": "gogs", "X-Hub-Signature": "github"},
+		want: "gitea",
+	}, {
+		name:    "gogs",
+		headers: map[string]string{"X-Gogs-Event": "push", "X-Gogs-Signature": "gogs"},
+		want:    "gogs",
+	}, {
+		name: "unknown",
+		want: "",
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.url == "" {
+				tt.url = "http://fake.com/webhooks/scm"
+			}
+			request, _ := http.NewRequest(http.MethodPost, tt.url, nil)
+			for k, v := range tt.headers {
+				request.Header.Set(k, v)
+			}
+			assert.Equal(t, tt.want, getSignature(request))
+		})
+	}
+}
+
+func Test_verifySignature(t *testing.T) {
+	payload := []byte(`{"ref":"refs/heads/master","repository":{"full_name":"linuxsuren/test"}}`)
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write(payload)
+	signature := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	newRequest := func(signature string) *http.Request {
+		request, _ := http.NewRequest(http.MethodPost, "http://fake.com/webhooks/scm", strings.NewReader(""))
+		request.Header.Set("X-GitHub-Event", "push")
+		request.Header.Set("X-GitHub-Delivery", "guid")
+		if signature != "" {
+			request.Header.Set("X-Hub-Signature-256", signature)
+		}
+		return request
+	}
+
+	tests := []struct {
+		name    string
+		request *http.Request
+		secrets []string
+		wantErr error
+	}{{
+		name:    "no secrets",
+		request: newRequest(signature),
+		wantErr: errSecretMissing,
+	}, {
+		name:    "no signature",
+		request: newRequest(""),
+		secrets: []string{"secret"},
+		wantErr: errSignatureMissing,
+	}, {
+		name:    "invalid signature",
+		request: newRequest("sha256=invalid"),
+		secrets: []string{"secret"},
+		wantErr: scm.ErrSignatureInvalid,
+	}, {
+		name:    "one of the secrets matches",
+		request: newRequest(signature),
+		secrets: []string{"other", "secret"},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := verifySignature(github.NewDefault(), tt.request, payload, tt.secrets)
+			assert.Equal(t, tt.wantErr, err)
+		})
+	}
+}
+
+func Test_verifyGiteaSignature(t *testing.T) {
+	payload := []byte(`{"ref":"refs/heads/master","pusher":{"login":"linuxsuren"},"sender":{"login":"linuxsuren"},` +
+		`"repository":{"full_name":"linuxsuren/test","owner":{"login":"linuxsuren"}}}`)
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write(payload)
+
+	newRequest := func(signature string) *http.Request {
+		request, _ := http.NewRequest(http.MethodPost, "http://fake.com/webhooks/scm", strings.NewReader(""))
+		request.Header.Set("X-Gitea-Event", "push")
+		request.Header.Set("X-Gitea-Signature", signature)
+		return request
+	}
+	scmClient := getSCMClient(newRequest(""))
+
+	assert.Equal(t, errSignatureMissing, verifySignature(scmClient, newRequest(""), payload, []string{"secret"}))
+	assert.Equal(t, scm.ErrSignatureInvalid, verifySignature(scmClient, newRequest("invalid"), payload, []string{"secret"}))
+	assert.Nil(t, verifySignature(scmClient, newRequest(hex.EncodeToString(mac.Sum(nil))), payload, []string{"secret"}))
+}
+
+func Test_verifyBitbucketServerSignature(t *testing.T) {
+	payload := []byte(`{"eventKey":"repo:refs_changed","actor":{"name":"linuxsuren"},` +
+		`"changes":[{"ref":{"id":"refs/heads/master","displayId":"master","type":"BRANCH"},` +
+		`"fromHash":"8f4b347e","toHash":"bd4f171c","type":"UPDATE"}],` +
+		`"repository":{"slug":"test","project":{"key":"PRJ"}}}`)
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write(payload)
+
+	newRequest := func(signature string) *http.Request {
+		request, _ := http.NewRequest(http.MethodPost, "http://fake.com/webhooks/scm", strings.NewReader(""))
+		request.Header.Set("X-Event-Key", "repo:refs_changed")
+		request.Header.Set("X-Request-Id", "guid")
+		if signature != "" {
+			request.Header.Set("X-Hub-Signature", signature)
+		}
+		return request
+	}
+	scmClient := getSCMClient(newRequest(""))
+
+	assert.Equal(t, errSecretMissing, verifySignature(scmClient, newRequest(""), payload, nil))
+	assert.Equal(t, errSignatureMissing, verifySignature(scmClient, newRequest(""), payload, []string{"secret"}))
+	assert.Equal(t, scm.ErrSignatureInvalid, verifySignature(scmClient, newRequest("sha256=invalid"), payload, []string{"secret"}))
+	assert.Nil(t, verifySignature(scmClient, newRequest("sha256="+hex.EncodeToString(mac.Sum(nil))), payload,
+		[]string{"secret"}))
+}
+
+func Test_getStatusCode(t *testing.T) {
+	assert.Equal(t, http.StatusUnauthorized, getStatusCode(errSignatureMissing))
+	assert.Equal(t, http.StatusForbidden, getStatusCode(scm.ErrSignatureInvalid))
+	assert.Equal(t, http.StatusForbidden, getStatusCode(errSecretMissing))
+	assert.Equal(t, http.StatusBadRequest, getStatusCode(http.ErrBodyNotAllowed))
+}
+
+func TestSCMHandler_getWebhookSecrets(t *testing.T) {
+	repoSecret := &v1.Secret{}
+	repoSecret.SetName("repo-secret")
+	repoSecret.SetNamespace("ns")
+	repoSecret.Type = v1.SecretTypeBasicAuth
+	repoSecret.Data = map[string][]byte{v1.BasicAuthPasswordKey: []byte("repo")}
+
+	webhookSecret := &v1.Secret{}
+	webhookSecret.SetName("webhook-secret")
+	webhookSecret.SetNamespace("ns")
+	webhookSecret.Type = v1.SecretTypeOpaque
+	webhookSecret.Data = map[string][]byte{v1.ServiceAccountTokenKey: []byte("webhook")}
+
+	withSecret := &v1alpha3.Webhook{}
+	withSecret.SetName("with-secret")
+	withSecret.SetNamespace("ns")
+	withSecret.Spec.Secret = &v1.SecretReference{Name: "webhook-secret"}
+
+	withoutSecret := &v1alpha3.Webhook{}
+	withoutSecret.SetName("without-secret")
+	withoutSecret.SetNamespace("ns")
+
+	newRepo := func(url string, secret *v1.SecretReference, webhooks ...string) *v1alpha3.GitRepository {
+		repo := &v1alpha3.GitRepository{}
+		repo.SetName("repo")
+		repo.SetNamespace("ns")
+		repo.Spec.URL = url
+		repo.Spec.Secret = secret
+		for _, webhook := range webhooks {
+			repo.Spec.Webhooks = append(repo.Spec.Webhooks, v1.LocalObjectReference{Name: webhook})
+		}
+		return repo
+	}
+	scmRepo := scm.Repository{Link: "https://github.com/linuxsuren/test"}
+
+	tests := []struct {
+		name        string
+		initObjects []runtime.Object
+		wantSecrets []string
+		wantErr     bool
+	}{{
+		name:        "no git repositories",
+		initObjects: []runtime.Object{repoSecret.DeepCopy()},
+	}, {
+		name: "git repository does not match",
+		initObjects: []runtime.Object{repoSecret.DeepCopy(),
+			newRepo("https://github.com/linuxsuren/other", &v1.SecretReference{Name: "repo-secret"})},
+	}, {
+		name: "secret of the git repository",
+		initObjects: []runtime.Object{repoSecret.DeepCopy(),
+			newRepo("https://github.com/linuxsuren/test", &v1.SecretReference{Name: "repo-secret"})},
+		wantSecrets: []string{"repo"},
+	}, {
+		name: "secret of the webhook takes precedence",
+		initObjects: []runtime.Object{repoSecret.DeepCopy(), webhookSecret.DeepCopy(), withSecret.DeepCopy(), withoutSecret.DeepCopy(),
+			newRepo("https://github.com/linuxsuren/test", &v1.SecretReference{Name: "repo-secret"}, "with-secret", "without-secret")},
+		wantSecrets: []string{"webhook", "repo"},
+	}, {
+		name: "webhook not found",
+		initObjects: []runtime.Object{repoSecret.DeepCopy(),
+			newRepo("https://github.com/linuxsuren/test", &v1.SecretReference{Name: "repo-secret"}, "not-found")},
+		wantErr: true,
+	}, {
+		name:        "secret not found",
+		initObjects: []runtime.Object{newRepo("https://github.com/linuxsuren/test", &v1.SecretReference{Name: "repo-secret"})},
+		wantErr:     true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			utilruntime.Must(v1alpha3.AddToScheme(scheme.Scheme))
+			handler := NewSCMHandler(fake.NewFakeClientWithScheme(scheme.Scheme, tt.initObjects...), nil, core.JenkinsCore{}, nil)
+
+			secrets, err := handler.getWebhookSecrets(context.Background(), "ns", scmRepo)
+			assert.Equal(t, tt.wantSecrets, secrets)
+			if tt.wantErr {
+				assert.NotNil(t, err)
+			} else {
+				assert.Nil(t, err)
+			}
+		})
+	}
+}
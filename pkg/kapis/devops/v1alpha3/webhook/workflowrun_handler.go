@@ -18,11 +18,13 @@ package webhook
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/util/retry"
 	"k8s.io/klog/v2"
+	"kubesphere.io/devops/pkg/event/common"
 	"kubesphere.io/devops/pkg/event/workflowrun"
 	"strings"
 	"time"
@@ -91,6 +93,19 @@ func extractPipelineRunIdentifier(workflowRunData *workflowrun.Data) *pipelineRu
 	return identifier
 }
 
+// getEventPipelineRunIdentifier returns the identifier of the PipelineRun which the event is about.
+// It's nil if the event is not about a standard Pipeline in ks-devops.
+func getEventPipelineRunIdentifier(event *common.Event) *pipelineRunIdentifier {
+	if event == nil || event.DataType != workflowrun.Type || len(event.Data) == 0 {
+		return nil
+	}
+	workflowRunData := &workflowrun.Data{}
+	if err := json.Unmarshal(event.Data, workflowRunData); err != nil {
+		return nil
+	}
+	return extractPipelineRunIdentifier(workflowRunData)
+}
+
 func (handler *Handler) handleWorkflowRunInitialize(workflowRunData *workflowrun.Data) error {
 	identifier := extractPipelineRunIdentifier(workflowRunData)
 	if identifier == nil {
@@ -0,0 +1,177 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/jenkins-zh/jenkins-client/pkg/job"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
+	"kubesphere.io/devops/pkg/event/workflowrun"
+	modelpipelinerun "kubesphere.io/devops/pkg/models/pipelinerun"
+)
+
+// states of a Jenkins WorkflowRun, they are the same as the reasons which are set by the PipelineRun controller
+const (
+	stateRunning  = "RUNNING"
+	stateFinished = "FINISHED"
+)
+
+// handleWorkflowRunStarted marks the PipelineRun as running
+func (handler *Handler) handleWorkflowRunStarted(workflowRunData *workflowrun.Data) error {
+	pipelineRun, err := handler.findPipelineRun(extractPipelineRunIdentifier(workflowRunData))
+	if err != nil || pipelineRun == nil || pipelineRun.HasCompleted() {
+		return err
+	}
+
+	status := pipelineRun.Status.DeepCopy()
+	applyWorkflowRunStatus(workflowRunData, false, status)
+	return handler.updatePipelineRunStatus(pipelineRun, status)
+}
+
+// handleWorkflowRunFinished stores the latest run data and stages, then marks the PipelineRun as completed.
+// Both the completed and finalized events are handled by it, the finalized event makes sure the stages are up to date.
+func (handler *Handler) handleWorkflowRunFinished(workflowRunData *workflowrun.Data) error {
+	identifier := extractPipelineRunIdentifier(workflowRunData)
+	pipelineRun, err := handler.findPipelineRun(identifier)
+	if err != nil || pipelineRun == nil {
+		return err
+	}
+
+	// the PipelineRun controller does not synchronize the completed PipelineRuns,
+	// so we have to store the run data before marking it as completed
+	dataStore, ok := pipelineRun.Annotations[v1alpha3.PipelineRunDataStoreAnnoKey]
+	if !ok {
+		// the data store is unknown before the first synchronization, leave it to the PipelineRun controller
+		return nil
+	}
+	if err = handler.storeRunData(identifier, pipelineRun, dataStore); err != nil {
+		// the status is still able to be updated without the run data
+		klog.Errorf("failed to store the run data of PipelineRun: %s/%s, error: %v", pipelineRun.Namespace, pipelineRun.Name, err)
+	}
+	if pipelineRun.HasCompleted() {
+		return nil
+	}
+
+	status := pipelineRun.Status.DeepCopy()
+	applyWorkflowRunStatus(workflowRunData, true, status)
+	return handler.updatePipelineRunStatus(pipelineRun, status)
+}
+
+// findPipelineRun returns the PipelineRun of the identifier, it returns nil if not found
+func (handler *Handler) findPipelineRun(identifier *pipelineRunIdentifier) (*v1alpha3.PipelineRun, error) {
+	if identifier == nil {
+		// not a standard Pipeline in ks-devops
+		return nil, nil
+	}
+	pipelineRunList := &v1alpha3.PipelineRunList{}
+	if err := handler.List(context.Background(), pipelineRunList,
+		client.InNamespace(identifier.namespaceName),
+		client.MatchingFields{v1alpha3.PipelineRunIdentifierIndexerName: identifier.String()}); err != nil {
+		return nil, err
+	}
+	if len(pipelineRunList.Items) == 0 {
+		// the PipelineRun will be created by the initialize event handler
+		return nil, nil
+	}
+	return &pipelineRunList.Items[0], nil
+}
+
+// storeRunData fetches the run data and stages from Jenkins, then stores them in the data store of the PipelineRun controller
+func (handler *Handler) storeRunData(identifier *pipelineRunIdentifier, pipelineRun *v1alpha3.PipelineRun, dataStore string) error {
+	c := job.BlueOceanClient{JenkinsCore: handler.JenkinsCore, Organization: "jenkins"}
+	run, err := c.GetBuild(job.GetBuildOption{
+		RunID:     identifier.buildNumber,
+		Pipelines: []string{identifier.namespaceName, identifier.pipelineName},
+		Branch:    identifier.scmRefName,
+	})
+	if err != nil {
+		return err
+	}
+	nodeDetails, err := modelpipelinerun.GetNodeDetails(c, identifier.namespaceName, identifier.pipelineName,
+		identifier.scmRefName, identifier.buildNumber)
+	if err != nil {
+		return err
+	}
+	runJSON, err := json.Marshal(run)
+	if err != nil {
+		return err
+	}
+	nodeDetailsJSON, err := json.Marshal(nodeDetails)
+	if err != nil {
+		return err
+	}
+
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		prToUpdate := &v1alpha3.PipelineRun{}
+		if err := handler.Get(context.Background(), client.ObjectKeyFromObject(pipelineRun), prToUpdate); err != nil {
+			return err
+		}
+		if err := modelpipelinerun.StoreStages(context.Background(), handler.Client, handler.S3Client, dataStore,
+			prToUpdate, string(nodeDetailsJSON)); err != nil {
+			return err
+		}
+		prToUpdate.Annotations[v1alpha3.JenkinsPipelineRunStatusAnnoKey] = string(runJSON)
+		return handler.Update(context.Background(), prToUpdate)
+	})
+}
+
+func (handler *Handler) updatePipelineRunStatus(pipelineRun *v1alpha3.PipelineRun, status *v1alpha3.PipelineRunStatus) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		prToUpdate := &v1alpha3.PipelineRun{}
+		if err := handler.Get(context.Background(), client.ObjectKeyFromObject(pipelineRun), prToUpdate); err != nil {
+			return err
+		}
+		if prToUpdate.HasCompleted() {
+			// the PipelineRun might be stopped in the meantime
+			return nil
+		}
+		prToUpdate.Status = *modelpipelinerun.KeepApprovals(status, &prToUpdate.Status)
+		return handler.Status().Update(context.Background(), prToUpdate)
+	})
+}
+
+// applyWorkflowRunStatus applies the WorkflowRun data to the status of PipelineRun
+func applyWorkflowRunStatus(workflowRunData *workflowrun.Data, finished bool, status *v1alpha3.PipelineRunStatus) {
+	now := metav1.Now()
+	startTime := metav1.NewTime(time.UnixMilli(workflowRunData.Timestamp))
+	condition := v1alpha3.Condition{
+		Type:               v1alpha3.ConditionReady,
+		Status:             v1alpha3.ConditionUnknown,
+		LastProbeTime:      now,
+		LastTransitionTime: now,
+		Reason:             stateRunning,
+	}
+	status.StartTime = &startTime
+	status.UpdateTime = &now
+	status.Phase = v1alpha3.Running
+
+	if finished {
+		completionTime := metav1.NewTime(startTime.Add(time.Duration(workflowRunData.Duration) * time.Millisecond))
+		status.CompletionTime = &completionTime
+		condition.Reason = stateFinished
+		modelpipelinerun.ApplyResult(workflowRunData.Result, &condition, status)
+	}
+	status.AddCondition(&condition)
+}
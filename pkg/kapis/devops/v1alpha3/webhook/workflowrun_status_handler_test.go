@@ -0,0 +1,274 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jenkins-zh/jenkins-client/pkg/core"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
+	fakes3 "kubesphere.io/devops/pkg/client/s3/fake"
+	"kubesphere.io/devops/pkg/event/workflowrun"
+	s3store "kubesphere.io/devops/pkg/store/s3"
+)
+
+func Test_applyWorkflowRunStatus(t *testing.T) {
+	startTime := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name          string
+		result        string
+		finished      bool
+		wantPhase     v1alpha3.RunPhase
+		wantCondition v1alpha3.ConditionStatus
+	}{{
+		name:          "started",
+		wantPhase:     v1alpha3.Running,
+		wantCondition: v1alpha3.ConditionUnknown,
+	}, {
+		name:          "succeeded",
+		result:        "SUCCESS",
+		finished:      true,
+		wantPhase:     v1alpha3.Succeeded,
+		wantCondition: v1alpha3.ConditionTrue,
+	}, {
+		name:          "failed",
+		result:        "FAILURE",
+		finished:      true,
+		wantPhase:     v1alpha3.Failed,
+		wantCondition: v1alpha3.ConditionFalse,
+	}, {
+		name:          "aborted",
+		result:        "ABORTED",
+		finished:      true,
+		wantPhase:     v1alpha3.Failed,
+		wantCondition: v1alpha3.ConditionFalse,
+	}, {
+		name:          "not built",
+		result:        "NOT_BUILT",
+		finished:      true,
+		wantPhase:     v1alpha3.Unknown,
+		wantCondition: v1alpha3.ConditionUnknown,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := &workflowrun.Data{
+				Result:    tt.result,
+				Timestamp: startTime.UnixMilli(),
+				Duration:  60 * 1000,
+			}
+			status := &v1alpha3.PipelineRunStatus{}
+			applyWorkflowRunStatus(data, tt.finished, status)
+
+			assert.Equal(t, tt.wantPhase, status.Phase)
+			assert.True(t, startTime.Equal(status.StartTime.Time))
+			assert.NotNil(t, status.UpdateTime)
+			condition := status.GetLatestCondition()
+			if assert.NotNil(t, condition) {
+				assert.Equal(t, tt.wantCondition, condition.Status)
+			}
+			if tt.finished {
+				assert.True(t, startTime.Add(time.Minute).Equal(status.CompletionTime.Time))
+				assert.Equal(t, v1alpha3.ConditionSucceeded, condition.Type)
+			} else {
+				assert.Nil(t, status.CompletionTime)
+				assert.Equal(t, v1alpha3.ConditionReady, condition.Type)
+			}
+		})
+	}
+}
+
+// newFakeBlueOceanServer returns a server which responds a run with a single stage and step
+func newFakeBlueOceanServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/steps/"):
+			_, _ = w.Write([]byte(`[{"id":"2","displayName":"Shell Script","result":"SUCCESS","state":"FINISHED"}]`))
+		case strings.HasSuffix(r.URL.Path, "/nodes/"):
+			_, _ = w.Write([]byte(`[{"id":"1","displayName":"build","result":"SUCCESS","state":"FINISHED"}]`))
+		case strings.HasSuffix(r.URL.Path, "/runs/1/"):
+			_, _ = w.Write([]byte(`{"id":"1","result":"SUCCESS","state":"FINISHED"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestHandler_handleWorkflowRunEvents(t *testing.T) {
+	server := newFakeBlueOceanServer()
+	defer server.Close()
+
+	scheme := runtime.NewScheme()
+	assert.Nil(t, v1alpha3.AddToScheme(scheme))
+	assert.Nil(t, corev1.AddToScheme(scheme))
+
+	pipelineRun := &v1alpha3.PipelineRun{
+		ObjectMeta: v1.ObjectMeta{
+			Namespace: "fake-namespace",
+			Name:      "fake-pipeline-abcde",
+			Labels:    map[string]string{v1alpha3.PipelineNameLabelKey: "fake-pipeline"},
+			Annotations: map[string]string{
+				v1alpha3.JenkinsPipelineRunIDAnnoKey: "1",
+			},
+		},
+	}
+	// withDataStore returns the PipelineRun which was annotated with the data store by the PipelineRun controller
+	withDataStore := func(dataStore string) *v1alpha3.PipelineRun {
+		pr := pipelineRun.DeepCopy()
+		pr.Annotations[v1alpha3.PipelineRunDataStoreAnnoKey] = dataStore
+		return pr
+	}
+	startTime := time.Now().Add(-time.Minute)
+	workflowRunData := createWorkflowRun("fake-namespace", "fake-pipeline", "1", false)
+	workflowRunData.Timestamp = startTime.UnixMilli()
+
+	t.Run("no PipelineRun found", func(t *testing.T) {
+		handler := &Handler{Client: fake.NewClientBuilder().WithScheme(scheme).Build()}
+		assert.Nil(t, handler.handleWorkflowRunStarted(workflowRunData))
+		assert.Nil(t, handler.handleWorkflowRunFinished(workflowRunData))
+	})
+
+	t.Run("not a standard Pipeline", func(t *testing.T) {
+		handler := &Handler{Client: fake.NewClientBuilder().WithScheme(scheme).Build()}
+		assert.Nil(t, handler.handleWorkflowRunStarted(createWorkflowRun("", "", "", false)))
+	})
+
+	t.Run("started and finished", func(t *testing.T) {
+		handler := &Handler{
+			Client:      fake.NewClientBuilder().WithScheme(scheme).WithObjects(withDataStore("configmap")).Build(),
+			JenkinsCore: core.JenkinsCore{URL: server.URL},
+		}
+		key := client.ObjectKeyFromObject(pipelineRun)
+
+		assert.Nil(t, handler.handleWorkflowRunStarted(workflowRunData))
+		pr := &v1alpha3.PipelineRun{}
+		assert.Nil(t, handler.Get(context.Background(), key, pr))
+		assert.Equal(t, v1alpha3.Running, pr.Status.Phase)
+		assert.False(t, pr.HasCompleted())
+
+		finishedData := *workflowRunData
+		finishedData.Result = "SUCCESS"
+		finishedData.Duration = 1000
+		assert.Nil(t, handler.handleWorkflowRunFinished(&finishedData))
+		assert.Nil(t, handler.Get(context.Background(), key, pr))
+		assert.Equal(t, v1alpha3.Succeeded, pr.Status.Phase)
+		assert.True(t, pr.HasCompleted())
+		assert.Contains(t, pr.Annotations[v1alpha3.JenkinsPipelineRunStatusAnnoKey], `"result":"SUCCESS"`)
+
+		// the stages are stored in the ConfigMap
+		cm := &corev1.ConfigMap{}
+		assert.Nil(t, handler.Get(context.Background(), key, cm))
+		assert.Contains(t, cm.Data["stage"], `"displayName":"Shell Script"`)
+
+		// the started event of a completed PipelineRun should be ignored
+		assert.Nil(t, handler.handleWorkflowRunStarted(workflowRunData))
+		assert.Nil(t, handler.Get(context.Background(), key, pr))
+		assert.Equal(t, v1alpha3.Succeeded, pr.Status.Phase)
+	})
+
+	t.Run("store the stages in the annotation", func(t *testing.T) {
+		// the stages annotation does not exist before the first synchronization
+		handler := &Handler{
+			Client:      fake.NewClientBuilder().WithScheme(scheme).WithObjects(withDataStore("")).Build(),
+			JenkinsCore: core.JenkinsCore{URL: server.URL},
+		}
+		finishedData := *workflowRunData
+		finishedData.Result = "FAILURE"
+		assert.Nil(t, handler.handleWorkflowRunFinished(&finishedData))
+
+		key := client.ObjectKeyFromObject(pipelineRun)
+		pr := &v1alpha3.PipelineRun{}
+		assert.Nil(t, handler.Get(context.Background(), key, pr))
+		assert.Equal(t, v1alpha3.Failed, pr.Status.Phase)
+		assert.Contains(t, pr.Annotations[v1alpha3.JenkinsPipelineRunStagesStatusAnnoKey], `"displayName":"build"`)
+		assert.True(t, apierrors.IsNotFound(handler.Get(context.Background(), key, &corev1.ConfigMap{})))
+	})
+
+	t.Run("store the stages in S3", func(t *testing.T) {
+		s3Client := fakes3.NewFakeS3()
+		handler := &Handler{
+			Client:      fake.NewClientBuilder().WithScheme(scheme).WithObjects(withDataStore("s3")).Build(),
+			JenkinsCore: core.JenkinsCore{URL: server.URL},
+			S3Client:    s3Client,
+		}
+		finishedData := *workflowRunData
+		finishedData.Result = "SUCCESS"
+		assert.Nil(t, handler.handleWorkflowRunFinished(&finishedData))
+
+		key := client.ObjectKeyFromObject(pipelineRun)
+		s3Store, err := s3store.NewS3Store(key, s3Client)
+		assert.Nil(t, err)
+		assert.Contains(t, s3Store.GetStages(), `"displayName":"build"`)
+		pr := &v1alpha3.PipelineRun{}
+		assert.Nil(t, handler.Get(context.Background(), key, pr))
+		assert.Empty(t, pr.Annotations[v1alpha3.JenkinsPipelineRunStagesStatusAnnoKey])
+		assert.True(t, apierrors.IsNotFound(handler.Get(context.Background(), key, &corev1.ConfigMap{})))
+	})
+
+	t.Run("unknown data store", func(t *testing.T) {
+		handler := &Handler{
+			Client:      fake.NewClientBuilder().WithScheme(scheme).WithObjects(pipelineRun.DeepCopy()).Build(),
+			JenkinsCore: core.JenkinsCore{URL: server.URL},
+		}
+		assert.NotNil(t, handler.storeRunData(extractPipelineRunIdentifier(workflowRunData), pipelineRun, "fake"))
+	})
+
+	t.Run("the data store is not known yet", func(t *testing.T) {
+		handler := &Handler{
+			Client:      fake.NewClientBuilder().WithScheme(scheme).WithObjects(pipelineRun.DeepCopy()).Build(),
+			JenkinsCore: core.JenkinsCore{URL: server.URL},
+		}
+		finishedData := *workflowRunData
+		finishedData.Result = "SUCCESS"
+		assert.Nil(t, handler.handleWorkflowRunFinished(&finishedData))
+
+		// it's left to the PipelineRun controller
+		pr := &v1alpha3.PipelineRun{}
+		assert.Nil(t, handler.Get(context.Background(), client.ObjectKeyFromObject(pipelineRun), pr))
+		assert.False(t, pr.HasCompleted())
+		assert.Empty(t, pr.Annotations[v1alpha3.JenkinsPipelineRunStatusAnnoKey])
+	})
+
+	t.Run("failed to fetch the run data from Jenkins", func(t *testing.T) {
+		handler := &Handler{
+			Client:      fake.NewClientBuilder().WithScheme(scheme).WithObjects(withDataStore("configmap")).Build(),
+			JenkinsCore: core.JenkinsCore{URL: server.URL + "/fake"},
+		}
+		finishedData := *workflowRunData
+		finishedData.ID = "2"
+		finishedData.Result = "SUCCESS"
+		assert.Nil(t, handler.handleWorkflowRunFinished(&finishedData))
+
+		pr := &v1alpha3.PipelineRun{}
+		assert.Nil(t, handler.Get(context.Background(), client.ObjectKeyFromObject(pipelineRun), pr))
+		assert.Equal(t, v1alpha3.Succeeded, pr.Status.Phase)
+		assert.Empty(t, pr.Annotations[v1alpha3.JenkinsPipelineRunStatusAnnoKey])
+	})
+}
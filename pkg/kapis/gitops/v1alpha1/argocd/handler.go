@@ -51,7 +51,7 @@ func (h *handler) createApplication(req *restful.Request, res *restful.Response)
 			application.Labels = make(map[string]string)
 		}
 		application.Labels[v1alpha1.ArgoCDLocationLabelKey] = h.ArgoCDNamespace
-		err = h.Create(context.Background(), application)
+		err = h.Create(req.Request.Context(), application)
 	}
 	common.Response(req, res, application, err)
 }
@@ -59,13 +59,13 @@ func (h *handler) createApplication(req *restful.Request, res *restful.Response)
 func (h *handler) applicationSummary(request *restful.Request, response *restful.Response) {
 	namespace := common.GetPathParameter(request, common.NamespacePathParameter)
 
-	summary, err := h.populateApplicationSummary(namespace)
+	summary, err := h.populateApplicationSummary(request.Request.Context(), namespace)
 	common.Response(request, response, summary, err)
 }
 
-func (h *handler) populateApplicationSummary(namespace string) (*ApplicationsSummary, error) {
+func (h *handler) populateApplicationSummary(ctx context.Context, namespace string) (*ApplicationsSummary, error) {
 	applicationList := &v1alpha1.ApplicationList{}
-	if err := h.List(context.Background(), applicationList, client.InNamespace(namespace)); err != nil {
+	if err := h.List(ctx, applicationList, client.InNamespace(namespace)); err != nil {
 		return nil, err
 	}
 	summary := &ApplicationsSummary{
@@ -107,13 +107,12 @@ func (h *handler) handleSyncApplication(req *restful.Request, res *restful.Respo
 		return
 	}
 
-	app, err := h.syncApplication(namespace, name, syncRequest, currentUser)
+	app, err := h.syncApplication(req.Request.Context(), namespace, name, syncRequest, currentUser)
 	common.Response(req, res, app, err)
 }
 
-func (h *handler) syncApplication(namespace, name string, syncRequest *ApplicationSyncRequest, currentUser user.Info) (*v1alpha1.Application, error) {
+func (h *handler) syncApplication(ctx context.Context, namespace, name string, syncRequest *ApplicationSyncRequest, currentUser user.Info) (*v1alpha1.Application, error) {
 	app := &v1alpha1.Application{}
-	ctx := context.Background()
 	if err := h.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, app); err != nil {
 		return nil, err
 	}
@@ -154,14 +153,14 @@ func (h *handler) syncApplication(namespace, name string, syncRequest *Applicati
 		operation.Retry = *retry
 	}
 
-	return h.updateOperation(namespace, name, operation)
+	return h.updateOperation(ctx, namespace, name, operation)
 }
 
-func (h *handler) updateOperation(namespace, name string, operation *v1alpha1.Operation) (*v1alpha1.Application, error) {
+func (h *handler) updateOperation(ctx context.Context, namespace, name string, operation *v1alpha1.Operation) (*v1alpha1.Application, error) {
 	var app *v1alpha1.Application
 	return app, utilretry.RetryOnConflict(utilretry.DefaultRetry, func() error {
 		app = &v1alpha1.Application{}
-		err := h.Get(context.Background(), types.NamespacedName{Namespace: namespace, Name: name}, app)
+		err := h.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, app)
 		if err != nil {
 			return err
 		}
@@ -173,7 +172,7 @@ func (h *handler) updateOperation(namespace, name string, operation *v1alpha1.Op
 		}
 
 		app.Spec.ArgoApp.Operation = operation
-		if err := h.Update(context.Background(), app); err != nil {
+		if err := h.Update(ctx, app); err != nil {
 			return err
 		}
 		// updated successfully
@@ -182,7 +181,7 @@ func (h *handler) updateOperation(namespace, name string, operation *v1alpha1.Op
 }
 
 func (h *handler) getClusters(req *restful.Request, res *restful.Response) {
-	ctx := context.Background()
+	ctx := req.Request.Context()
 
 	secrets := &v1.SecretList{}
 	err := h.List(ctx, secrets, client.MatchingLabels{
@@ -17,6 +17,7 @@ package argocd
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"github.com/emicklei/go-restful"
 	"github.com/stretchr/testify/assert"
@@ -298,7 +299,7 @@ func Test_handler_updateOperation(t *testing.T) {
 			h := &handler{
 				Handler: &gitops.Handler{Client: fakeClient},
 			}
-			_, err := h.updateOperation(tt.args.namespace, tt.args.name, tt.operation)
+			_, err := h.updateOperation(context.Background(), tt.args.namespace, tt.args.name, tt.operation)
 			assert.Equal(t, tt.wantErrMessage, err.Error())
 		})
 	}
@@ -16,7 +16,6 @@
 package fluxcd
 
 import (
-	"context"
 	"github.com/emicklei/go-restful"
 	v1 "k8s.io/api/core/v1"
 	"kubesphere.io/devops/pkg/api/gitops/v1alpha1"
@@ -32,13 +31,13 @@ func (h *handler) createApplication(req *restful.Request, res *restful.Response)
 	application := &v1alpha1.Application{}
 	if err = req.ReadEntity(application); err == nil {
 		application.Namespace = namespace
-		err = h.Create(context.Background(), application)
+		err = h.Create(req.Request.Context(), application)
 	}
 	common.Response(req, res, application, err)
 }
 
 func (h *handler) getClusters(req *restful.Request, res *restful.Response) {
-	ctx := context.Background()
+	ctx := req.Request.Context()
 
 	secrets := &v1.SecretList{}
 	err := h.List(ctx, secrets, client.MatchingLabels{
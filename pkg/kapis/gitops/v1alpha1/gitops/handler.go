@@ -16,7 +16,6 @@
 package gitops
 
 import (
-	"context"
 	"github.com/emicklei/go-restful"
 	"k8s.io/apimachinery/pkg/types"
 	"kubesphere.io/devops/pkg/api/gitops/v1alpha1"
@@ -50,7 +49,7 @@ func (h *Handler) ApplicationList(req *restful.Request, res *restful.Response) {
 	if healthStatus != "" {
 		matchingLabels[v1alpha1.HealthStatusLabelKey] = healthStatus
 	}
-	if err := h.List(context.Background(), applicationList, client.InNamespace(namespace), matchingLabels); err != nil {
+	if err := h.List(req.Request.Context(), applicationList, client.InNamespace(namespace), matchingLabels); err != nil {
 		common.Response(req, res, applicationList, err)
 		return
 	}
@@ -66,7 +65,7 @@ func (h *Handler) GetApplication(req *restful.Request, res *restful.Response) {
 	name := common.GetPathParameter(req, pathParameterApplication)
 
 	application := &v1alpha1.Application{}
-	err := h.Get(context.Background(), types.NamespacedName{
+	err := h.Get(req.Request.Context(), types.NamespacedName{
 		Namespace: namespace,
 		Name:      name,
 	}, application)
@@ -78,7 +77,7 @@ func (h *Handler) DelApplication(req *restful.Request, res *restful.Response) {
 	name := common.GetPathParameter(req, pathParameterApplication)
 	cascade := common.GetQueryParameter(req, cascadeQueryParam)
 
-	ctx := context.Background()
+	ctx := req.Request.Context()
 	application := &v1alpha1.Application{}
 	objectKey := types.NamespacedName{
 		Namespace: namespace,
@@ -114,13 +113,13 @@ func (h *Handler) UpdateApplication(req *restful.Request, res *restful.Response)
 	application := &v1alpha1.Application{}
 	if err = req.ReadEntity(application); err == nil {
 		latestApp := &v1alpha1.Application{}
-		err = h.Get(context.Background(), types.NamespacedName{
+		err = h.Get(req.Request.Context(), types.NamespacedName{
 			Namespace: namespace,
 			Name:      name,
 		}, latestApp)
 		if err == nil {
 			application.ResourceVersion = latestApp.ResourceVersion
-			err = h.Update(context.Background(), application)
+			err = h.Update(req.Request.Context(), application)
 		}
 	}
 	common.Response(req, res, application, err)
@@ -22,6 +22,7 @@ import (
 
 	"github.com/emicklei/go-restful"
 
+	"kubesphere.io/devops/pkg/apiserver/authentication/oidc"
 	"kubesphere.io/devops/pkg/models/auth"
 )
 
@@ -58,11 +59,12 @@ func (request *TokenReview) Validate() error {
 }
 
 type handler struct {
-	tokenOperator auth.TokenManagementInterface
+	tokenOperator   auth.TokenManagementInterface
+	idTokenVerifier oidc.Verifier
 }
 
-func newHandler(tokenOperator auth.TokenManagementInterface) *handler {
-	return &handler{tokenOperator: tokenOperator}
+func newHandler(tokenOperator auth.TokenManagementInterface, idTokenVerifier oidc.Verifier) *handler {
+	return &handler{tokenOperator: tokenOperator, idTokenVerifier: idTokenVerifier}
 }
 
 // TokenReview Implement webhook authentication interface
@@ -81,7 +83,10 @@ func (h *handler) TokenReview(req *restful.Request, resp *restful.Response) {
 		return
 	}
 
-	authenticated, err := h.tokenOperator.Verify(tokenReview.Spec.Token)
+	authenticated, tokenType, err := h.tokenOperator.Verify(tokenReview.Spec.Token)
+	if err == nil && !tokenType.GrantsAccess() {
+		err = fmt.Errorf("the token type %q is not allowed to access the APIs", tokenType)
+	}
 	if err != nil {
 		kapis.HandleInternalError(resp, req, err)
 		return
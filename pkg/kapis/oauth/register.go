@@ -23,17 +23,21 @@ import (
 	restfulspec "github.com/emicklei/go-restful-openapi"
 
 	"kubesphere.io/devops/pkg/api"
+	"kubesphere.io/devops/pkg/apiserver/authentication/oauth"
+	"kubesphere.io/devops/pkg/apiserver/authentication/oidc"
 	"kubesphere.io/devops/pkg/constants"
 	"kubesphere.io/devops/pkg/models/auth"
 )
 
-func AddToContainer(c *restful.Container, tokenOperator auth.TokenManagementInterface) (ws *restful.WebService) {
+// AddToContainer adds the OAuth APIs. The ID token exchange is not available if the idTokenVerifier is nil.
+func AddToContainer(c *restful.Container, tokenOperator auth.TokenManagementInterface,
+	idTokenVerifier oidc.Verifier) (ws *restful.WebService) {
 	ws = &restful.WebService{}
 	ws.Path("/oauth").
 		Consumes(restful.MIME_JSON).
 		Produces(restful.MIME_JSON)
 
-	handler := newHandler(tokenOperator)
+	handler := newHandler(tokenOperator, idTokenVerifier)
 
 	// Implement webhook authentication interface
 	// https://kubernetes.io/docs/reference/access-authn-authz/authentication/#webhook-token-authentication
@@ -44,6 +48,20 @@ func AddToContainer(c *restful.Container, tokenOperator auth.TokenManagementInte
 		To(handler.TokenReview).
 		Returns(http.StatusOK, api.StatusOK, TokenReview{}).
 		Metadata(restfulspec.KeyOpenAPITags, []string{constants.AuthenticationTag}))
+
+	// the token endpoint of RFC 6749, it exchanges an ID token (RFC 8693) or refreshes the DevOps tokens
+	ws.Route(ws.POST("/token").
+		Doc("Issue the DevOps access token and refresh token by exchanging an OIDC ID token or a refresh token").
+		Consumes("application/x-www-form-urlencoded").
+		Param(ws.FormParameter("grant_type", "urn:ietf:params:oauth:grant-type:token-exchange or refresh_token").
+			Required(true)).
+		Param(ws.FormParameter("subject_token", "the ID token of the OIDC provider, required by the token exchange")).
+		Param(ws.FormParameter("subject_token_type", "must be urn:ietf:params:oauth:token-type:id_token")).
+		Param(ws.FormParameter("refresh_token", "the refresh token, required by the refresh_token grant")).
+		To(handler.Token).
+		Returns(http.StatusOK, api.StatusOK, oauth.Token{}).
+		Returns(http.StatusBadRequest, "invalid token request", TokenError{}).
+		Metadata(restfulspec.KeyOpenAPITags, []string{constants.AuthenticationTag}))
 	c.Add(ws)
 	return
 }
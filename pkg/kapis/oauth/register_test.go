@@ -28,7 +28,7 @@ import (
 func TestAPIsExist(t *testing.T) {
 	httpWriter := httptest.NewRecorder()
 
-	AddToContainer(restful.DefaultContainer, nil)
+	AddToContainer(restful.DefaultContainer, nil, nil)
 
 	type args struct {
 		method string
@@ -43,6 +43,12 @@ func TestAPIsExist(t *testing.T) {
 			method: http.MethodPost,
 			uri:    "/authenticate",
 		},
+	}, {
+		name: "token",
+		args: args{
+			method: http.MethodPost,
+			uri:    "/token",
+		},
 	}}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -0,0 +1,133 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oauth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/emicklei/go-restful"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/klog/v2"
+	"kubesphere.io/devops/pkg/apiserver/authentication/oauth"
+)
+
+const (
+	// GrantTypeTokenExchange exchanges an ID token of the OIDC provider for the DevOps tokens, see also RFC 8693
+	GrantTypeTokenExchange = "urn:ietf:params:oauth:grant-type:token-exchange"
+	// GrantTypeRefreshToken issues new DevOps tokens with a refresh token
+	GrantTypeRefreshToken = "refresh_token"
+	// TokenTypeIDToken is the subject token type of an OIDC ID token
+	TokenTypeIDToken = "urn:ietf:params:oauth:token-type:id_token"
+
+	// the error codes of RFC 6749
+	errInvalidRequest       = "invalid_request"
+	errInvalidGrant         = "invalid_grant"
+	errUnsupportedGrantType = "unsupported_grant_type"
+	errServerError          = "server_error"
+)
+
+// TokenError is the error response of the token endpoint, see also RFC 6749 section 5.2
+type TokenError struct {
+	Error            string `json:"error" description:"error code"`
+	ErrorDescription string `json:"error_description,omitempty" description:"human-readable description of the error"`
+}
+
+// Token issues the DevOps tokens by exchanging an ID token or refreshing
+func (h *handler) Token(req *restful.Request, resp *restful.Response) {
+	if err := req.Request.ParseForm(); err != nil {
+		writeTokenError(resp, http.StatusBadRequest, errInvalidRequest, err)
+		return
+	}
+
+	switch grantType := req.Request.PostForm.Get("grant_type"); grantType {
+	case GrantTypeTokenExchange:
+		h.exchangeToken(req, resp)
+	case GrantTypeRefreshToken:
+		h.refreshToken(req, resp)
+	case "":
+		writeTokenError(resp, http.StatusBadRequest, errInvalidRequest, errors.New("grant_type is required"))
+	default:
+		writeTokenError(resp, http.StatusBadRequest, errUnsupportedGrantType,
+			fmt.Errorf("grant_type %s is not supported", grantType))
+	}
+}
+
+func (h *handler) exchangeToken(req *restful.Request, resp *restful.Response) {
+	if h.idTokenVerifier == nil {
+		writeTokenError(resp, http.StatusBadRequest, errUnsupportedGrantType,
+			errors.New("no OIDC provider is configured"))
+		return
+	}
+
+	form := req.Request.PostForm
+	subjectToken := form.Get("subject_token")
+	if subjectToken == "" {
+		writeTokenError(resp, http.StatusBadRequest, errInvalidRequest, errors.New("subject_token is required"))
+		return
+	}
+	if subjectTokenType := form.Get("subject_token_type"); subjectTokenType != TokenTypeIDToken {
+		writeTokenError(resp, http.StatusBadRequest, errInvalidRequest,
+			fmt.Errorf("subject_token_type must be %s", TokenTypeIDToken))
+		return
+	}
+
+	authenticated, err := h.idTokenVerifier.Verify(req.Request.Context(), subjectToken)
+	if err != nil {
+		writeTokenError(resp, http.StatusBadRequest, errInvalidGrant, err)
+		return
+	}
+	h.issueTo(resp, authenticated)
+}
+
+func (h *handler) refreshToken(req *restful.Request, resp *restful.Response) {
+	refreshToken := req.Request.PostForm.Get("refresh_token")
+	if refreshToken == "" {
+		writeTokenError(resp, http.StatusBadRequest, errInvalidRequest, errors.New("refresh_token is required"))
+		return
+	}
+
+	token, err := h.tokenOperator.Refresh(refreshToken)
+	if err != nil {
+		writeTokenError(resp, http.StatusBadRequest, errInvalidGrant, err)
+		return
+	}
+	writeToken(resp, token)
+}
+
+func (h *handler) issueTo(resp *restful.Response, authenticated user.Info) {
+	token, err := h.tokenOperator.IssueTo(authenticated)
+	if err != nil {
+		klog.Errorf("failed to issue token to %s, error: %v", authenticated.GetName(), err)
+		writeTokenError(resp, http.StatusInternalServerError, errServerError, errors.New("failed to issue token"))
+		return
+	}
+	writeToken(resp, token)
+}
+
+func writeToken(resp *restful.Response, token *oauth.Token) {
+	// the tokens must not be cached, see also RFC 6749 section 5.1
+	resp.Header().Set("Cache-Control", "no-store")
+	resp.Header().Set("Pragma", "no-cache")
+	_ = resp.WriteHeaderAndJson(http.StatusOK, token, restful.MIME_JSON)
+}
+
+func writeTokenError(resp *restful.Response, status int, code string, err error) {
+	resp.Header().Set("Cache-Control", "no-store")
+	_ = resp.WriteHeaderAndJson(status, TokenError{Error: code, ErrorDescription: err.Error()}, restful.MIME_JSON)
+}
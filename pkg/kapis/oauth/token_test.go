@@ -0,0 +1,165 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oauth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/emicklei/go-restful"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"kubesphere.io/devops/pkg/apiserver/authentication/oauth"
+	"kubesphere.io/devops/pkg/apiserver/authentication/oidc"
+	"kubesphere.io/devops/pkg/apiserver/authentication/oidc/oidctest"
+	authoptions "kubesphere.io/devops/pkg/apiserver/authentication/options"
+	"kubesphere.io/devops/pkg/client/cache"
+	"kubesphere.io/devops/pkg/models/auth"
+)
+
+func TestToken(t *testing.T) {
+	provider, err := oidctest.NewServer()
+	assert.Nil(t, err)
+	defer provider.Close()
+
+	tokenOperator := auth.NewTokenOperator(cache.NewSimpleCache(), &authoptions.AuthenticationOptions{
+		JwtSecret: "nQk6f1gM9uPYZHXyyuLoqfSMZAZ5RdYQ",
+		OAuthOptions: &oauth.Options{
+			AccessTokenMaxAge: time.Minute,
+		},
+	})
+	verifier := oidc.NewVerifier(&oidc.Options{Issuer: provider.Issuer(), ClientID: "devops"}, time.Second)
+
+	idToken, err := provider.IDToken(map[string]interface{}{"sub": "alice", "aud": "devops"})
+	assert.Nil(t, err)
+	invalidIDToken, err := provider.IDToken(map[string]interface{}{"sub": "alice", "aud": "other"})
+	assert.Nil(t, err)
+	issued, err := tokenOperator.IssueTo(&user.DefaultInfo{Name: "bob"})
+	assert.Nil(t, err)
+
+	tests := []struct {
+		name          string
+		verifier      oidc.Verifier
+		form          url.Values
+		expectCode    int
+		expectError   string
+		expectSubject string
+	}{{
+		name:        "without grant_type",
+		verifier:    verifier,
+		form:        url.Values{},
+		expectCode:  http.StatusBadRequest,
+		expectError: errInvalidRequest,
+	}, {
+		name:        "unsupported grant_type",
+		verifier:    verifier,
+		form:        url.Values{"grant_type": {"password"}},
+		expectCode:  http.StatusBadRequest,
+		expectError: errUnsupportedGrantType,
+	}, {
+		name:     "exchange an ID token",
+		verifier: verifier,
+		form: url.Values{
+			"grant_type":         {GrantTypeTokenExchange},
+			"subject_token":      {idToken},
+			"subject_token_type": {TokenTypeIDToken},
+		},
+		expectCode:    http.StatusOK,
+		expectSubject: "alice",
+	}, {
+		name:     "exchange an invalid ID token",
+		verifier: verifier,
+		form: url.Values{
+			"grant_type":         {GrantTypeTokenExchange},
+			"subject_token":      {invalidIDToken},
+			"subject_token_type": {TokenTypeIDToken},
+		},
+		expectCode:  http.StatusBadRequest,
+		expectError: errInvalidGrant,
+	}, {
+		name:     "exchange without subject_token_type",
+		verifier: verifier,
+		form: url.Values{
+			"grant_type":    {GrantTypeTokenExchange},
+			"subject_token": {idToken},
+		},
+		expectCode:  http.StatusBadRequest,
+		expectError: errInvalidRequest,
+	}, {
+		name: "exchange without an OIDC provider",
+		form: url.Values{
+			"grant_type":         {GrantTypeTokenExchange},
+			"subject_token":      {idToken},
+			"subject_token_type": {TokenTypeIDToken},
+		},
+		expectCode:  http.StatusBadRequest,
+		expectError: errUnsupportedGrantType,
+	}, {
+		name: "refresh the tokens",
+		form: url.Values{
+			"grant_type":    {GrantTypeRefreshToken},
+			"refresh_token": {issued.RefreshToken},
+		},
+		expectCode:    http.StatusOK,
+		expectSubject: "bob",
+	}, {
+		name: "refresh with an access token",
+		form: url.Values{
+			"grant_type":    {GrantTypeRefreshToken},
+			"refresh_token": {issued.AccessToken},
+		},
+		expectCode:  http.StatusBadRequest,
+		expectError: errInvalidGrant,
+	}, {
+		name:        "refresh without refresh_token",
+		form:        url.Values{"grant_type": {GrantTypeRefreshToken}},
+		expectCode:  http.StatusBadRequest,
+		expectError: errInvalidRequest,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			container := restful.NewContainer()
+			AddToContainer(container, tokenOperator, tt.verifier)
+
+			request := httptest.NewRequest(http.MethodPost, "/oauth/token", strings.NewReader(tt.form.Encode()))
+			request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			recorder := httptest.NewRecorder()
+			container.Dispatch(recorder, request)
+
+			assert.Equal(t, tt.expectCode, recorder.Code)
+			assert.Equal(t, "no-store", recorder.Header().Get("Cache-Control"))
+			if tt.expectError != "" {
+				tokenError := &TokenError{}
+				assert.Nil(t, json.Unmarshal(recorder.Body.Bytes(), tokenError))
+				assert.Equal(t, tt.expectError, tokenError.Error)
+				return
+			}
+
+			token := &oauth.Token{}
+			assert.Nil(t, json.Unmarshal(recorder.Body.Bytes(), token))
+			assert.NotEmpty(t, token.RefreshToken)
+			authenticated, _, err := tokenOperator.Verify(token.AccessToken)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.expectSubject, authenticated.GetName())
+		})
+	}
+}
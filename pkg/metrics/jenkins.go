@@ -0,0 +1,78 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	jenkinsRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "jenkins_requests_total",
+		Help:      "The number of the requests to Jenkins by HTTP method and status code",
+	}, []string{"method", "code"})
+	jenkinsRequestErrorsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "jenkins_request_errors_total",
+		Help:      "The number of the requests to Jenkins which failed to connect or got a server error",
+	}, []string{"method"})
+	jenkinsRequestDurationSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Name:      "jenkins_request_duration_seconds",
+		Help:      "The latency of the requests to Jenkins",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method"})
+)
+
+// errorCode is the code label of a request which failed without any response
+const errorCode = "<error>"
+
+// jenkinsTransport records the metrics of the requests to Jenkins
+type jenkinsTransport struct {
+	next http.RoundTripper
+}
+
+// InstrumentJenkinsTransport wraps a transport to record the metrics of the requests to Jenkins.
+// The default transport is used if next is nil.
+func InstrumentJenkinsTransport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &jenkinsTransport{next: next}
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *jenkinsTransport) RoundTrip(request *http.Request) (response *http.Response, err error) {
+	start := time.Now()
+	response, err = t.next.RoundTrip(request)
+	jenkinsRequestDurationSeconds.WithLabelValues(request.Method).Observe(time.Since(start).Seconds())
+
+	code := errorCode
+	if err == nil {
+		code = strconv.Itoa(response.StatusCode)
+	}
+	jenkinsRequestsTotal.WithLabelValues(request.Method, code).Inc()
+	if err != nil || response.StatusCode >= http.StatusInternalServerError {
+		jenkinsRequestErrorsTotal.WithLabelValues(request.Method).Inc()
+	}
+	return
+}
@@ -0,0 +1,79 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(request *http.Request) (*http.Response, error) {
+	return f(request)
+}
+
+func TestJenkinsTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/error":
+			w.WriteHeader(http.StatusBadGateway)
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	getCount := func(method, code string) float64 {
+		return testutil.ToFloat64(jenkinsRequestsTotal.WithLabelValues(method, code))
+	}
+	getErrors := func(method string) float64 {
+		return testutil.ToFloat64(jenkinsRequestErrorsTotal.WithLabelValues(method))
+	}
+	ok, notFound, badGateway := getCount(http.MethodGet, "200"), getCount(http.MethodGet, "404"),
+		getCount(http.MethodPost, "502")
+	failed, getErrs, postErrs := getCount(http.MethodGet, errorCode), getErrors(http.MethodGet), getErrors(http.MethodPost)
+
+	client := &http.Client{Transport: InstrumentJenkinsTransport(nil)}
+	for _, path := range []string{"/", "/missing"} {
+		response, err := client.Get(server.URL + path)
+		assert.Nil(t, err)
+		_ = response.Body.Close()
+	}
+	response, err := client.Post(server.URL+"/error", "application/json", nil)
+	assert.Nil(t, err)
+	_ = response.Body.Close()
+
+	client.Transport = InstrumentJenkinsTransport(roundTripperFunc(func(*http.Request) (*http.Response, error) {
+		return nil, errors.New("connection refused")
+	}))
+	_, err = client.Get(server.URL)
+	assert.NotNil(t, err)
+
+	assert.Equal(t, ok+1, getCount(http.MethodGet, "200"))
+	assert.Equal(t, notFound+1, getCount(http.MethodGet, "404"))
+	assert.Equal(t, badGateway+1, getCount(http.MethodPost, "502"))
+	assert.Equal(t, failed+1, getCount(http.MethodGet, errorCode))
+	// only the failed connections and server errors are counted as errors
+	assert.Equal(t, getErrs+1, getErrors(http.MethodGet))
+	assert.Equal(t, postErrs+1, getErrors(http.MethodPost))
+}
@@ -0,0 +1,59 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package metrics contains the Prometheus metrics of ks-devops.
+// All the metrics are registered into the registry of controller-runtime, it's served by both the controller manager
+// and the API server.
+package metrics
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// Namespace is the common prefix of all the metrics
+const Namespace = "ks_devops"
+
+func init() {
+	ctrlmetrics.Registry.MustRegister(
+		pipelineRunCompletedTotal, pipelineRunDurationSeconds, pipelineRunQueueWaitSeconds,
+		jenkinsRequestsTotal, jenkinsRequestErrorsTotal, jenkinsRequestDurationSeconds,
+		webhookDeliveriesReceivedTotal, webhookDeliveriesMatchedTotal, webhookDeliveriesRejectedTotal,
+	)
+}
+
+// Register registers the collectors into the registry of controller-runtime.
+// It's fine to register the same collector more than once, such as setting up a controller again.
+func Register(collectors ...prometheus.Collector) error {
+	for _, collector := range collectors {
+		if err := ctrlmetrics.Registry.Register(collector); err != nil {
+			alreadyRegisteredErr := prometheus.AlreadyRegisteredError{}
+			if !errors.As(err, &alreadyRegisteredErr) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Handler returns the HTTP handler which exposes all the metrics
+func Handler() http.Handler {
+	return promhttp.HandlerFor(ctrlmetrics.Registry, promhttp.HandlerOpts{})
+}
@@ -0,0 +1,60 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegister(t *testing.T) {
+	collector := prometheus.NewCounter(prometheus.CounterOpts{Namespace: Namespace, Name: "fake_total", Help: "fake"})
+	assert.Nil(t, Register(collector))
+	// registering it again is fine
+	assert.Nil(t, Register(prometheus.NewCounter(prometheus.CounterOpts{Namespace: Namespace, Name: "fake_total", Help: "fake"})))
+	// conflicts with the existing one
+	assert.NotNil(t, Register(prometheus.NewGauge(prometheus.GaugeOpts{Namespace: Namespace, Name: "fake_total", Help: "another"})))
+}
+
+func TestHandler(t *testing.T) {
+	WebhookDeliveryReceived("github")
+
+	recorder := httptest.NewRecorder()
+	Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), `ks_devops_webhook_deliveries_received_total{provider="github"}`)
+}
+
+func TestWebhookDeliveries(t *testing.T) {
+	received := testutil.ToFloat64(webhookDeliveriesReceivedTotal.WithLabelValues("gitlab"))
+	matched := testutil.ToFloat64(webhookDeliveriesMatchedTotal.WithLabelValues("gitlab"))
+	rejected := testutil.ToFloat64(webhookDeliveriesRejectedTotal.WithLabelValues("gitlab"))
+
+	WebhookDeliveryReceived("gitlab")
+	WebhookDeliveryReceived("gitlab")
+	WebhookDeliveryMatched("gitlab")
+	WebhookDeliveryRejected("gitlab")
+
+	assert.Equal(t, received+2, testutil.ToFloat64(webhookDeliveriesReceivedTotal.WithLabelValues("gitlab")))
+	assert.Equal(t, matched+1, testutil.ToFloat64(webhookDeliveriesMatchedTotal.WithLabelValues("gitlab")))
+	assert.Equal(t, rejected+1, testutil.ToFloat64(webhookDeliveriesRejectedTotal.WithLabelValues("gitlab")))
+}
@@ -0,0 +1,159 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+	toolscache "k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+var (
+	pipelineRunCompletedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "pipelinerun_completed_total",
+		Help:      "The number of the completed PipelineRuns",
+	}, []string{"namespace", "pipeline", "phase"})
+	pipelineRunDurationSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Name:      "pipelinerun_duration_seconds",
+		Help:      "The time from the start to the completion of the PipelineRuns",
+		// from 10 seconds to about 5.7 hours
+		Buckets: prometheus.ExponentialBuckets(10, 2, 12),
+	}, []string{"namespace", "pipeline", "phase"})
+	pipelineRunQueueWaitSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Name:      "pipelinerun_queue_wait_seconds",
+		Help:      "The time from the creation to the start of the PipelineRuns",
+		// from 1 second to about 34 minutes
+		Buckets: prometheus.ExponentialBuckets(1, 2, 12),
+	}, []string{"namespace", "pipeline"})
+
+	pipelineRunsDesc = prometheus.NewDesc(Namespace+"_pipelineruns",
+		"The number of the existing PipelineRuns", []string{"namespace", "pipeline", "phase"}, nil)
+)
+
+// SetupPipelineRunMetrics observes the changes of PipelineRuns from the cache of the manager,
+// and registers the collector which counts the existing PipelineRuns.
+func SetupPipelineRunMetrics(mgr manager.Manager) (err error) {
+	if err = Register(NewPipelineRunCollector(mgr.GetClient())); err != nil {
+		return
+	}
+
+	var informer cache.Informer
+	if informer, err = mgr.GetCache().GetInformer(context.Background(), &v1alpha3.PipelineRun{}); err != nil {
+		return
+	}
+	// only the updates matter, it avoids observing the existing PipelineRuns again after restarting
+	informer.AddEventHandler(toolscache.ResourceEventHandlerFuncs{
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldPipelineRun, ok := oldObj.(*v1alpha3.PipelineRun)
+			if !ok {
+				return
+			}
+			if pipelineRun, ok := newObj.(*v1alpha3.PipelineRun); ok {
+				ObservePipelineRun(oldPipelineRun, pipelineRun)
+			}
+		},
+	})
+	return
+}
+
+// ObservePipelineRun observes the queue wait time once a PipelineRun started,
+// and the duration once it completed.
+func ObservePipelineRun(oldPipelineRun, pipelineRun *v1alpha3.PipelineRun) {
+	namespace, pipeline := pipelineRun.Namespace, getPipelineName(pipelineRun)
+
+	if oldPipelineRun.Status.StartTime.IsZero() && !pipelineRun.Status.StartTime.IsZero() {
+		queueWait := pipelineRun.Status.StartTime.Sub(pipelineRun.CreationTimestamp.Time)
+		pipelineRunQueueWaitSeconds.WithLabelValues(namespace, pipeline).Observe(queueWait.Seconds())
+	}
+
+	if !oldPipelineRun.HasCompleted() && pipelineRun.HasCompleted() {
+		phase := getPhase(pipelineRun)
+		pipelineRunCompletedTotal.WithLabelValues(namespace, pipeline, phase).Inc()
+		if !pipelineRun.Status.StartTime.IsZero() {
+			duration := pipelineRun.Status.CompletionTime.Sub(pipelineRun.Status.StartTime.Time)
+			pipelineRunDurationSeconds.WithLabelValues(namespace, pipeline, phase).Observe(duration.Seconds())
+		}
+	}
+}
+
+func getPipelineName(pipelineRun *v1alpha3.PipelineRun) (name string) {
+	if name = pipelineRun.Labels[v1alpha3.PipelineNameLabelKey]; name == "" && pipelineRun.Spec.PipelineRef != nil {
+		name = pipelineRun.Spec.PipelineRef.Name
+	}
+	return
+}
+
+// getPhase returns the phase of a PipelineRun, a PipelineRun without phase is pending until it completes
+func getPhase(pipelineRun *v1alpha3.PipelineRun) string {
+	switch {
+	case pipelineRun.Status.Phase != "":
+		return string(pipelineRun.Status.Phase)
+	case pipelineRun.HasCompleted():
+		return string(v1alpha3.Unknown)
+	}
+	return string(v1alpha3.Pending)
+}
+
+// pipelineRunCollector counts the existing PipelineRuns by phase when being scraped
+type pipelineRunCollector struct {
+	reader client.Reader
+}
+
+// NewPipelineRunCollector creates a collector which counts the PipelineRuns, the reader is supposed to be a cache
+func NewPipelineRunCollector(reader client.Reader) prometheus.Collector {
+	return &pipelineRunCollector{reader: reader}
+}
+
+// Describe implements prometheus.Collector
+func (c *pipelineRunCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- pipelineRunsDesc
+}
+
+// Collect implements prometheus.Collector
+func (c *pipelineRunCollector) Collect(ch chan<- prometheus.Metric) {
+	pipelineRunList := &v1alpha3.PipelineRunList{}
+	if err := c.reader.List(context.Background(), pipelineRunList); err != nil {
+		klog.V(4).Infof("failed to list PipelineRuns, error: %v", err)
+		return
+	}
+
+	type pipelineRunKey struct {
+		namespace, pipeline, phase string
+	}
+	counts := map[pipelineRunKey]int{}
+	for i := range pipelineRunList.Items {
+		pipelineRun := &pipelineRunList.Items[i]
+		counts[pipelineRunKey{
+			namespace: pipelineRun.Namespace,
+			pipeline:  getPipelineName(pipelineRun),
+			phase:     getPhase(pipelineRun),
+		}]++
+	}
+	for key, count := range counts {
+		ch <- prometheus.MustNewConstMetric(pipelineRunsDesc, prometheus.GaugeValue, float64(count),
+			key.namespace, key.pipeline, key.phase)
+	}
+}
@@ -0,0 +1,123 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// getHistogram returns the sample count and sum of a histogram
+func getHistogram(t *testing.T, observer prometheus.Observer) (count uint64, sum float64) {
+	metric := &dto.Metric{}
+	assert.Nil(t, observer.(prometheus.Metric).Write(metric))
+	return metric.GetHistogram().GetSampleCount(), metric.GetHistogram().GetSampleSum()
+}
+
+func newPipelineRun(name, pipeline string, phase v1alpha3.RunPhase, startAfter, completeAfter time.Duration) *v1alpha3.PipelineRun {
+	created := time.Date(2022, 8, 1, 0, 0, 0, 0, time.UTC)
+	pipelineRun := &v1alpha3.PipelineRun{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:         "ns",
+			Name:              name,
+			CreationTimestamp: metav1.NewTime(created),
+			Labels:            map[string]string{v1alpha3.PipelineNameLabelKey: pipeline},
+		},
+		Status: v1alpha3.PipelineRunStatus{Phase: phase},
+	}
+	if startAfter > 0 {
+		pipelineRun.Status.StartTime = &metav1.Time{Time: created.Add(startAfter)}
+	}
+	if completeAfter > 0 {
+		pipelineRun.Status.CompletionTime = &metav1.Time{Time: created.Add(completeAfter)}
+	}
+	return pipelineRun
+}
+
+func TestObservePipelineRun(t *testing.T) {
+	pending := newPipelineRun("run", "observe", "", 0, 0)
+	running := newPipelineRun("run", "observe", v1alpha3.Running, 10*time.Second, 0)
+	succeeded := newPipelineRun("run", "observe", v1alpha3.Succeeded, 10*time.Second, 70*time.Second)
+
+	queueWait := pipelineRunQueueWaitSeconds.WithLabelValues("ns", "observe")
+	duration := pipelineRunDurationSeconds.WithLabelValues("ns", "observe", string(v1alpha3.Succeeded))
+	completed := pipelineRunCompletedTotal.WithLabelValues("ns", "observe", string(v1alpha3.Succeeded))
+
+	// started
+	ObservePipelineRun(pending, running)
+	count, sum := getHistogram(t, queueWait)
+	assert.Equal(t, uint64(1), count)
+	assert.Equal(t, float64(10), sum)
+
+	// no changes
+	ObservePipelineRun(running, running.DeepCopy())
+	count, _ = getHistogram(t, queueWait)
+	assert.Equal(t, uint64(1), count)
+	assert.Equal(t, float64(0), testutil.ToFloat64(completed))
+
+	// completed
+	ObservePipelineRun(running, succeeded)
+	count, sum = getHistogram(t, duration)
+	assert.Equal(t, uint64(1), count)
+	assert.Equal(t, float64(60), sum)
+	assert.Equal(t, float64(1), testutil.ToFloat64(completed))
+
+	// updated after the completion
+	ObservePipelineRun(succeeded, succeeded.DeepCopy())
+	assert.Equal(t, float64(1), testutil.ToFloat64(completed))
+
+	// completed without the phase and start time, such as being cancelled before starting
+	ObservePipelineRun(pending, newPipelineRun("run", "observe", "", 0, time.Minute))
+	assert.Equal(t, float64(1), testutil.ToFloat64(
+		pipelineRunCompletedTotal.WithLabelValues("ns", "observe", string(v1alpha3.Unknown))))
+	count, _ = getHistogram(t, pipelineRunDurationSeconds.WithLabelValues("ns", "observe", string(v1alpha3.Unknown)))
+	assert.Equal(t, uint64(0), count)
+}
+
+func TestPipelineRunCollector(t *testing.T) {
+	schema, err := v1alpha3.SchemeBuilder.Register().Build()
+	assert.Nil(t, err)
+
+	withoutLabel := newPipelineRun("run-4", "", "", 0, 0)
+	withoutLabel.Labels = nil
+	withoutLabel.Spec.PipelineRef = &corev1.ObjectReference{Name: "another"}
+	reader := fake.NewClientBuilder().WithScheme(schema).WithObjects(
+		newPipelineRun("run-1", "pipeline", v1alpha3.Succeeded, time.Second, time.Minute),
+		newPipelineRun("run-2", "pipeline", v1alpha3.Succeeded, time.Second, time.Minute),
+		newPipelineRun("run-3", "pipeline", v1alpha3.Running, time.Second, 0),
+		withoutLabel,
+	).Build()
+
+	expected := `
+# HELP ks_devops_pipelineruns The number of the existing PipelineRuns
+# TYPE ks_devops_pipelineruns gauge
+ks_devops_pipelineruns{namespace="ns",phase="Pending",pipeline="another"} 1
+ks_devops_pipelineruns{namespace="ns",phase="Running",pipeline="pipeline"} 1
+ks_devops_pipelineruns{namespace="ns",phase="Succeeded",pipeline="pipeline"} 2
+`
+	assert.Nil(t, testutil.CollectAndCompare(NewPipelineRunCollector(reader), strings.NewReader(expected)))
+}
@@ -0,0 +1,52 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var (
+	webhookDeliveriesReceivedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "webhook_deliveries_received_total",
+		Help:      "The number of the received webhook deliveries from the git providers",
+	}, []string{"provider"})
+	webhookDeliveriesMatchedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "webhook_deliveries_matched_total",
+		Help:      "The number of the webhook deliveries which matched at least one Pipeline",
+	}, []string{"provider"})
+	webhookDeliveriesRejectedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "webhook_deliveries_rejected_total",
+		Help:      "The number of the webhook deliveries which failed the verification",
+	}, []string{"provider"})
+)
+
+// WebhookDeliveryReceived counts a received webhook delivery
+func WebhookDeliveryReceived(provider string) {
+	webhookDeliveriesReceivedTotal.WithLabelValues(provider).Inc()
+}
+
+// WebhookDeliveryMatched counts a webhook delivery which matched at least one Pipeline
+func WebhookDeliveryMatched(provider string) {
+	webhookDeliveriesMatchedTotal.WithLabelValues(provider).Inc()
+}
+
+// WebhookDeliveryRejected counts a webhook delivery which failed the verification
+func WebhookDeliveryRejected(provider string) {
+	webhookDeliveriesRejectedTotal.WithLabelValues(provider).Inc()
+}
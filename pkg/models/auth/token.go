@@ -34,10 +34,12 @@ import (
 
 // TokenManagementInterface is the interface for the token management
 type TokenManagementInterface interface {
-	// Verify verifies a token, and return a User if it's a valid token, otherwise return error
-	Verify(token string) (user.Info, error)
+	// Verify verifies a token, and return a User and the type of the token if it's a valid token, otherwise return error
+	Verify(token string) (user.Info, token.TokenType, error)
 	// IssueTo issues a token a User, return error if issuing process failed
 	IssueTo(user user.Info) (*oauth.Token, error)
+	// Refresh issues new tokens to the User of a refresh token, return error if it's not a valid refresh token
+	Refresh(refreshToken string) (*oauth.Token, error)
 	// RevokeAllUserTokens revoke all user tokens
 	RevokeAllUserTokens(username string) error
 }
@@ -50,8 +52,16 @@ type tokenOperator struct {
 
 // NewTokenOperator creates an instance of the token operator
 func NewTokenOperator(cache cache.Interface, options *authoptions.AuthenticationOptions) TokenManagementInterface {
+	var external *token.ExternalIssuer
+	if options.JwksURL != "" {
+		external = &token.ExternalIssuer{
+			KeySet:   token.NewRemoteKeySet(options.JwksURL),
+			Issuer:   options.JwksIssuer,
+			Audience: options.JwksAudience,
+		}
+	}
 	operator := &tokenOperator{
-		issuer:  token.NewTokenIssuer(options.JwtSecret, options.MaximumClockSkew),
+		issuer:  token.NewTokenIssuerWithExternal(options.JwtSecret, external, options.MaximumClockSkew),
 		options: options,
 		cache:   cache,
 	}
@@ -59,19 +69,20 @@ func NewTokenOperator(cache cache.Interface, options *authoptions.Authentication
 }
 
 // Verify verifies the token
-func (t tokenOperator) Verify(tokenStr string) (user.Info, error) {
+func (t tokenOperator) Verify(tokenStr string) (user.Info, token.TokenType, error) {
 	authenticated, tokenType, err := t.issuer.Verify(tokenStr)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
+	// the tokens issued by a third party are not cached, the issuer takes care of the revocation of them
 	if t.options.OAuthOptions == nil || t.options.OAuthOptions.AccessTokenMaxAge == 0 ||
-		tokenType == token.StaticToken {
-		return authenticated, nil
+		tokenType == token.StaticToken || tokenType == token.ExternalToken {
+		return authenticated, tokenType, nil
 	}
 	if err := t.tokenCacheValidate(authenticated.GetName(), tokenStr); err != nil {
-		return nil, err
+		return nil, "", err
 	}
-	return authenticated, nil
+	return authenticated, tokenType, nil
 }
 
 // IssueTo creates a new token for a user
@@ -119,6 +130,28 @@ func (t tokenOperator) IssueTo(user user.Info) (*oauth.Token, error) {
 	return result, nil
 }
 
+// Refresh issues new tokens with a refresh token which was issued by IssueTo
+func (t tokenOperator) Refresh(refreshToken string) (*oauth.Token, error) {
+	if t.options.OAuthOptions == nil {
+		// no tokens are issued without the OAuth options
+		return nil, errors.New("the OAuth options are required to refresh tokens")
+	}
+	authenticated, tokenType, err := t.issuer.Verify(refreshToken)
+	if err != nil {
+		return nil, err
+	}
+	if tokenType != token.RefreshToken {
+		return nil, fmt.Errorf("expect a %s but got %s", token.RefreshToken, tokenType)
+	}
+	if t.options.OAuthOptions.AccessTokenMaxAge > 0 {
+		// a revoked refresh token is not in the cache any more
+		if err = t.tokenCacheValidate(authenticated.GetName(), refreshToken); err != nil {
+			return nil, err
+		}
+	}
+	return t.IssueTo(authenticated)
+}
+
 // RevokeAllUserTokens revokes all tokens of a user
 func (t tokenOperator) RevokeAllUserTokens(username string) error {
 	pattern := fmt.Sprintf("kubesphere:user:%s:token:*", username)
@@ -30,3 +30,41 @@ type Step struct {
 	// Approvable is a transient field for different users and should not be persisted.
 	Approvable bool `json:"approvable,omitempty"`
 }
+
+// GetNodeDetails gets the nodes including their steps of a Jenkins build via the BlueOcean API.
+func GetNodeDetails(c job.BlueOceanClient, namespace, pipelineName, branch, runID string) ([]NodeDetail, error) {
+	nodes, err := c.GetNodes(job.GetNodesOption{
+		Pipelines: []string{namespace, pipelineName},
+		Branch:    branch,
+		RunID:     runID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// get steps for every node
+	nodeDetails := []NodeDetail{}
+	for _, node := range nodes {
+		jobSteps, err := c.GetSteps(job.GetStepsOption{
+			RunID:        runID,
+			Branch:       branch,
+			PipelineName: pipelineName,
+			Folders:      []string{namespace},
+			NodeID:       node.ID,
+		})
+		if err != nil {
+			return nil, err
+		}
+		steps := make([]Step, 0, len(jobSteps))
+		for i := range jobSteps {
+			steps = append(steps, Step{
+				Step: jobSteps[i],
+			})
+		}
+		nodeDetails = append(nodeDetails, NodeDetail{
+			Node:  node,
+			Steps: steps,
+		})
+	}
+	return nodeDetails, nil
+}
@@ -0,0 +1,61 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinerun
+
+import (
+	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
+)
+
+// results of a Jenkins run
+const (
+	// ResultSuccess indicates the run was successful
+	ResultSuccess = "SUCCESS"
+	// ResultUnstable indicates the run was unstable, such as some tests failed
+	ResultUnstable = "UNSTABLE"
+	// ResultFailure indicates the run failed
+	ResultFailure = "FAILURE"
+	// ResultNotBuilt indicates the run finished without being built
+	ResultNotBuilt = "NOT_BUILT"
+	// ResultUnknown indicates the result of the run is unknown
+	ResultUnknown = "UNKNOWN"
+	// ResultAborted indicates the run was aborted
+	ResultAborted = "ABORTED"
+)
+
+// ApplyResult sets the condition status and the phase according to the result of a finished run
+func ApplyResult(result string, condition *v1alpha3.Condition, status *v1alpha3.PipelineRunStatus) {
+	condition.Type = v1alpha3.ConditionSucceeded
+	switch result {
+	case ResultSuccess:
+		condition.Status = v1alpha3.ConditionTrue
+		status.Phase = v1alpha3.Succeeded
+	case ResultUnstable, ResultFailure, ResultAborted:
+		condition.Status = v1alpha3.ConditionFalse
+		status.Phase = v1alpha3.Failed
+	default:
+		condition.Status = v1alpha3.ConditionUnknown
+		status.Phase = v1alpha3.Unknown
+	}
+}
+
+// KeepApprovals returns a copy of the desired status with the approvals of the current one.
+// The approvals are recorded by the apiserver, so the latest ones are kept when the status is synchronized from Jenkins.
+func KeepApprovals(desired, current *v1alpha3.PipelineRunStatus) *v1alpha3.PipelineRunStatus {
+	status := desired.DeepCopy()
+	status.Approvals = current.Approvals
+	return status
+}
@@ -0,0 +1,59 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinerun
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
+)
+
+func TestApplyResult(t *testing.T) {
+	tests := []struct {
+		result        string
+		wantPhase     v1alpha3.RunPhase
+		wantCondition v1alpha3.ConditionStatus
+	}{
+		{result: ResultSuccess, wantPhase: v1alpha3.Succeeded, wantCondition: v1alpha3.ConditionTrue},
+		{result: ResultUnstable, wantPhase: v1alpha3.Failed, wantCondition: v1alpha3.ConditionFalse},
+		{result: ResultFailure, wantPhase: v1alpha3.Failed, wantCondition: v1alpha3.ConditionFalse},
+		{result: ResultAborted, wantPhase: v1alpha3.Failed, wantCondition: v1alpha3.ConditionFalse},
+		{result: ResultNotBuilt, wantPhase: v1alpha3.Unknown, wantCondition: v1alpha3.ConditionUnknown},
+		{result: "", wantPhase: v1alpha3.Unknown, wantCondition: v1alpha3.ConditionUnknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.result, func(t *testing.T) {
+			condition := &v1alpha3.Condition{Type: v1alpha3.ConditionReady}
+			status := &v1alpha3.PipelineRunStatus{}
+			ApplyResult(tt.result, condition, status)
+			assert.Equal(t, v1alpha3.ConditionSucceeded, condition.Type)
+			assert.Equal(t, tt.wantCondition, condition.Status)
+			assert.Equal(t, tt.wantPhase, status.Phase)
+		})
+	}
+}
+
+func TestKeepApprovals(t *testing.T) {
+	desired := &v1alpha3.PipelineRunStatus{Phase: v1alpha3.Running}
+	current := &v1alpha3.PipelineRunStatus{Approvals: []v1alpha3.Approval{{NodeID: "1"}}}
+	status := KeepApprovals(desired, current)
+	assert.Equal(t, v1alpha3.Running, status.Phase)
+	assert.Equal(t, current.Approvals, status.Approvals)
+	assert.Empty(t, desired.Approvals)
+}
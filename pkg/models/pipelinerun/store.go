@@ -0,0 +1,79 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinerun
+
+import (
+	"context"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
+	"kubesphere.io/devops/pkg/client/s3"
+	cmstore "kubesphere.io/devops/pkg/store/configmap"
+	s3store "kubesphere.io/devops/pkg/store/s3"
+	"kubesphere.io/devops/pkg/store/store"
+)
+
+// types of the PipelineRun data store
+const (
+	// DataStoreAnnotation keeps the stages in the annotations of a PipelineRun
+	DataStoreAnnotation = ""
+	// DataStoreConfigMap keeps the stages in a ConfigMap which is owned by the PipelineRun
+	DataStoreConfigMap = "configmap"
+	// DataStoreS3 keeps the stages in an object storage
+	DataStoreS3 = "s3"
+)
+
+// StoreStages stores the stages of a PipelineRun in the data store.
+// The stages are set into the annotations if it's the annotation data store, the caller has to update the PipelineRun then.
+func StoreStages(ctx context.Context, c client.Client, s3Client s3.Interface, dataStore string,
+	pipelineRun *v1alpha3.PipelineRun, stages string) (err error) {
+	key := client.ObjectKeyFromObject(pipelineRun)
+	switch dataStore {
+	case DataStoreAnnotation:
+		if pipelineRun.Annotations == nil {
+			pipelineRun.Annotations = map[string]string{}
+		}
+		pipelineRun.Annotations[v1alpha3.JenkinsPipelineRunStagesStatusAnnoKey] = stages
+	case DataStoreConfigMap:
+		var cmStore store.ConfigMapStore
+		if cmStore, err = cmstore.NewConfigMapStore(ctx, key, c); err == nil {
+			cmStore.SetStages(stages)
+			cmStore.SetOwnerReference(metav1.OwnerReference{
+				APIVersion: v1alpha3.GroupVersion.String(),
+				Kind:       "PipelineRun",
+				Name:       pipelineRun.Name,
+				UID:        pipelineRun.UID,
+			})
+			err = cmStore.Save()
+		}
+	case DataStoreS3:
+		if s3Client == nil {
+			return fmt.Errorf("the S3 client is required by the data store: %s", dataStore)
+		}
+		var s3Store store.S3Store
+		if s3Store, err = s3store.NewS3Store(key, s3Client); err == nil {
+			s3Store.SetStages(stages)
+			err = s3Store.Save()
+		}
+	default:
+		err = fmt.Errorf("unknown pipelineRun data store type: %s", dataStore)
+	}
+	return
+}
@@ -0,0 +1,63 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinerun
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"kubesphere.io/devops/pkg/api/devops/v1alpha3"
+	fakes3 "kubesphere.io/devops/pkg/client/s3/fake"
+)
+
+func TestStoreStages(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.Nil(t, v1alpha3.AddToScheme(scheme))
+	assert.Nil(t, corev1.AddToScheme(scheme))
+
+	pipelineRun := &v1alpha3.PipelineRun{}
+	pipelineRun.SetName("name")
+	pipelineRun.SetNamespace("ns")
+	ctx := context.Background()
+
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(pipelineRun.DeepCopy()).Build()
+	assert.NotNil(t, StoreStages(ctx, c, nil, "fake", pipelineRun.DeepCopy(), "[]"))
+
+	// the caller updates the annotations
+	pr := pipelineRun.DeepCopy()
+	assert.Nil(t, StoreStages(ctx, c, nil, DataStoreAnnotation, pr, "[]"))
+	assert.Equal(t, "[]", pr.Annotations[v1alpha3.JenkinsPipelineRunStagesStatusAnnoKey])
+
+	assert.Nil(t, StoreStages(ctx, c, nil, DataStoreConfigMap, pipelineRun.DeepCopy(), "[]"))
+	cm := &corev1.ConfigMap{}
+	assert.Nil(t, c.Get(ctx, client.ObjectKeyFromObject(pipelineRun), cm))
+	if assert.Equal(t, 1, len(cm.OwnerReferences)) {
+		assert.Equal(t, "PipelineRun", cm.OwnerReferences[0].Kind)
+	}
+
+	// the S3 client is required
+	assert.NotNil(t, StoreStages(ctx, c, nil, DataStoreS3, pipelineRun.DeepCopy(), "[]"))
+	fakeS3 := fakes3.NewFakeS3()
+	assert.Nil(t, StoreStages(ctx, c, fakeS3, DataStoreS3, pipelineRun.DeepCopy(), "[]"))
+	assert.NotEmpty(t, fakeS3.Storage)
+}
@@ -0,0 +1,166 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package s3
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	awss3 "github.com/aws/aws-sdk-go/service/s3"
+	"k8s.io/klog/v2"
+	s3client "kubesphere.io/devops/pkg/client/s3"
+	"kubesphere.io/devops/pkg/store/store"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// S3Store represents a key-value store base on an object storage.
+// Every key is an object, such as: pipelineruns/{namespace}/{name}/log-all
+type S3Store struct {
+	s3Client s3client.Interface
+	prefix   string
+
+	cache map[string]string
+	dirty map[string]bool
+}
+
+// NewS3Store creates a PipelineRun data store
+func NewS3Store(key client.ObjectKey, s3Client s3client.Interface) (result store.S3Store, err error) {
+	if s3Client == nil {
+		err = errors.New("the S3 client is required")
+		return
+	}
+
+	result = &S3Store{
+		s3Client: s3Client,
+		prefix:   fmt.Sprintf("pipelineruns/%s/%s/", key.Namespace, key.Name),
+		cache:    map[string]string{},
+		dirty:    map[string]bool{},
+	}
+	return
+}
+
+// GetStages returns the stage data
+func (s *S3Store) GetStages() string {
+	return s.Get(store.DataKeyStage)
+}
+
+// SetStages stores the stage data
+func (s *S3Store) SetStages(stages string) {
+	s.Set(store.DataKeyStage, stages)
+}
+
+// GetStatus returns the status
+func (s *S3Store) GetStatus() string {
+	return s.Get(store.DataKeyStatus)
+}
+
+// SetStatus stores the status
+func (s *S3Store) SetStatus(status string) {
+	s.Set(store.DataKeyStatus, status)
+}
+
+// GetStepLog returns the step log
+func (s *S3Store) GetStepLog(stage, step int) string {
+	return s.Get(store.StepLogKey(stage, step))
+}
+
+// SetStepLog stores the step log
+func (s *S3Store) SetStepLog(stage, step int, log string) {
+	s.Set(store.StepLogKey(stage, step), log)
+}
+
+// GetAllLog returns the whole log
+func (s *S3Store) GetAllLog() string {
+	return s.Get(store.DataKeyAllLog)
+}
+
+// SetAllLog store the whole log
+func (s *S3Store) SetAllLog(log string) {
+	s.Set(store.DataKeyAllLog, log)
+}
+
+// Get returns the value by a key, it reads the object when it's not cached
+func (s *S3Store) Get(key string) string {
+	if value, ok := s.cache[key]; ok {
+		return value
+	}
+
+	value, err := s.read(key)
+	if err != nil {
+		klog.V(4).Infof("failed to read %s from S3, error: %v", s.prefix+key, err)
+		return ""
+	}
+	s.cache[key] = value
+	return value
+}
+
+// Set puts a key and value
+func (s *S3Store) Set(key, value string) {
+	s.cache[key] = value
+	s.dirty[key] = true
+}
+
+// Save uploads the changed values.
+// Every key is an independent object, so the concurrent savings of different keys never overwrite each other.
+func (s *S3Store) Save() (err error) {
+	for key := range s.dirty {
+		if err = s.s3Client.Upload(s.prefix+key, key, bytes.NewBufferString(s.cache[key])); err != nil {
+			err = fmt.Errorf("failed to upload %s, error: %v", s.prefix+key, err)
+			return
+		}
+		delete(s.dirty, key)
+	}
+	return
+}
+
+// Delete removes all the objects under the prefix of the PipelineRun
+func (s *S3Store) Delete() (err error) {
+	var keys []string
+	if keys, err = s.s3Client.List(s.prefix); err != nil {
+		err = fmt.Errorf("failed to list the objects of %s, error: %v", s.prefix, err)
+		return
+	}
+	for _, key := range keys {
+		if err = s.s3Client.Delete(key); err != nil {
+			err = fmt.Errorf("failed to delete %s, error: %v", key, err)
+			return
+		}
+	}
+	s.cache = map[string]string{}
+	s.dirty = map[string]bool{}
+	return
+}
+
+// read returns the value of a key, it's empty if the object does not exist
+func (s *S3Store) read(key string) (value string, err error) {
+	var data []byte
+	if data, err = s.s3Client.Read(s.prefix + key); err != nil {
+		if isNotFound(err) {
+			err = nil
+		}
+		return
+	}
+	value = string(data)
+	return
+}
+
+func isNotFound(err error) bool {
+	var awsErr awserr.Error
+	return errors.As(err, &awsErr) && (awsErr.Code() == awss3.ErrCodeNoSuchKey || awsErr.Code() == "NotFound")
+}
@@ -0,0 +1,130 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package s3
+
+import (
+	"errors"
+	"io"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/types"
+	"kubesphere.io/devops/pkg/client/s3/fake"
+	"kubesphere.io/devops/pkg/store/store"
+)
+
+func TestS3Store(t *testing.T) {
+	_, err := NewS3Store(types.NamespacedName{}, nil)
+	assert.NotNil(t, err)
+
+	fakeS3 := fake.NewFakeS3()
+	key := types.NamespacedName{Namespace: "ns", Name: "name"}
+
+	var s3Store store.S3Store
+	s3Store, err = NewS3Store(key, fakeS3)
+	assert.Nil(t, err)
+	assert.Nil(t, s3Store.Save())
+	assert.Empty(t, fakeS3.Storage)
+
+	assert.Empty(t, s3Store.GetStages())
+	s3Store.SetStages("stages")
+	assert.Equal(t, "stages", s3Store.GetStages())
+
+	assert.Empty(t, s3Store.GetStatus())
+	s3Store.SetStatus("status")
+	assert.Equal(t, "status", s3Store.GetStatus())
+
+	assert.Empty(t, s3Store.GetStepLog(1, 2))
+	s3Store.SetStepLog(1, 2, "step")
+	assert.Equal(t, "step", s3Store.GetStepLog(1, 2))
+
+	assert.Empty(t, s3Store.GetAllLog())
+	s3Store.SetAllLog("log")
+	assert.Equal(t, "log", s3Store.GetAllLog())
+	assert.Nil(t, s3Store.Save())
+	assert.Contains(t, fakeS3.Storage, "pipelineruns/ns/name/log-all")
+	assert.Contains(t, fakeS3.Storage, "pipelineruns/ns/name/log-step-1-2")
+
+	// read the data from another store instance
+	s3Store, err = NewS3Store(key, fakeS3)
+	assert.Nil(t, err)
+	assert.Equal(t, "stages", s3Store.GetStages())
+	assert.Equal(t, "status", s3Store.GetStatus())
+	assert.Equal(t, "step", s3Store.GetStepLog(1, 2))
+	assert.Equal(t, "log", s3Store.GetAllLog())
+	assert.Empty(t, s3Store.GetStepLog(2, 1))
+
+	// the saving of another store instance keeps the previous keys
+	s3Store.SetStepLog(2, 1, "another step")
+	assert.Nil(t, s3Store.Save())
+	keys, err := fakeS3.List("pipelineruns/ns/name/")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"pipelineruns/ns/name/log-all", "pipelineruns/ns/name/log-step-1-2",
+		"pipelineruns/ns/name/log-step-2-1", "pipelineruns/ns/name/stage", "pipelineruns/ns/name/status"}, keys)
+
+	// the data of other PipelineRuns should be kept
+	other, err := NewS3Store(types.NamespacedName{Namespace: "ns", Name: "other"}, fakeS3)
+	assert.Nil(t, err)
+	other.SetAllLog("other")
+	assert.Nil(t, other.Save())
+
+	assert.Nil(t, s3Store.Delete())
+	assert.Equal(t, []string{"pipelineruns/ns/other/log-all"}, storageKeys(fakeS3))
+	assert.Empty(t, s3Store.GetAllLog())
+}
+
+func TestS3Store_error(t *testing.T) {
+	s3Store, err := NewS3Store(types.NamespacedName{Namespace: "ns", Name: "name"}, &errorS3{})
+	assert.Nil(t, err)
+
+	assert.Empty(t, s3Store.GetAllLog())
+	s3Store.SetAllLog("log")
+	assert.NotNil(t, s3Store.Save())
+	assert.NotNil(t, s3Store.Delete())
+}
+
+func storageKeys(s3 *fake.FakeS3) (keys []string) {
+	for key := range s3.Storage {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return
+}
+
+// errorS3 fails all the requests
+type errorS3 struct{}
+
+func (s *errorS3) Read(string) ([]byte, error) {
+	return nil, errors.New("fake error")
+}
+
+func (s *errorS3) Upload(string, string, io.Reader) error {
+	return errors.New("fake error")
+}
+
+func (s *errorS3) GetDownloadURL(string, string) (string, error) {
+	return "", errors.New("fake error")
+}
+
+func (s *errorS3) Delete(string) error {
+	return errors.New("fake error")
+}
+
+func (s *errorS3) List(string) ([]string, error) {
+	return nil, errors.New("fake error")
+}
@@ -61,3 +61,10 @@ type ConfigMapStore interface {
 	PipelineRunDataStore
 	SetOwnerReference(owner metav1.OwnerReference)
 }
+
+// S3Store represents a store base on an object storage which is compatible with S3
+type S3Store interface {
+	PipelineRunDataStore
+	// Delete removes all the data of a PipelineRun
+	Delete() error
+}
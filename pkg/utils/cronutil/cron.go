@@ -0,0 +1,253 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cronutil parses the cron expressions in the format of Jenkins, then computes the times when they fire.
+// Same as Jenkins, all the fields of a cron must match, and the symbol H stands for a value hashed from a seed,
+// so the schedules which have the same cron don't fire at the same time.
+package cronutil
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxSearchYears is how far to look for the next time, a cron like "0 0 31 2 *" never fires
+const maxSearchYears = 5
+
+// field is a field of a cron, such as the minute and the hour
+type field struct {
+	name string
+	min  int
+	max  int
+	// maxHash is the max value of H, it's less than max for the day of month, so H fires every month
+	maxHash int
+}
+
+var fields = []field{
+	{name: "minute", min: 0, max: 59, maxHash: 59},
+	{name: "hour", min: 0, max: 23, maxHash: 23},
+	{name: "day of month", min: 1, max: 31, maxHash: 28},
+	{name: "month", min: 1, max: 12, maxHash: 12},
+	// both 0 and 7 are Sunday
+	{name: "day of week", min: 0, max: 7, maxHash: 6},
+}
+
+// aliases are the shortcuts of Jenkins
+var aliases = map[string]string{
+	"@yearly":   "H H H H *",
+	"@annually": "H H H H *",
+	"@monthly":  "H H H * *",
+	"@weekly":   "H H * * H",
+	"@daily":    "H H * * *",
+	"@midnight": "H H(0-2) * * *",
+	"@hourly":   "H * * * *",
+}
+
+// Schedule is a parsed cron. It fires if any line of the cron matches.
+type Schedule struct {
+	entries []entry
+}
+
+// entry is a line of a cron, every field is a bit set of the matched values
+type entry [5]uint64
+
+// Parse parses a cron which might have many lines, the empty lines and the comments starting with # are ignored.
+// The seed determines the values of H, it's usually the name of the object which owns the cron.
+func Parse(cron, seed string) (schedule *Schedule, err error) {
+	schedule = &Schedule{}
+	for i, line := range strings.Split(cron, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var e entry
+		if e, err = parseLine(line, seed); err != nil {
+			err = fmt.Errorf("invalid cron at line %d: %v", i+1, err)
+			return nil, err
+		}
+		schedule.entries = append(schedule.entries, e)
+	}
+	if len(schedule.entries) == 0 {
+		return nil, fmt.Errorf("the cron is empty")
+	}
+	return
+}
+
+func parseLine(line, seed string) (e entry, err error) {
+	if alias, ok := aliases[line]; ok {
+		line = alias
+	}
+	values := strings.Fields(line)
+	if len(values) != len(fields) {
+		err = fmt.Errorf("expected %d fields but got %d in %q", len(fields), len(values), line)
+		return
+	}
+	for i, value := range values {
+		if e[i], err = parseField(fields[i], value, hash(seed, i)); err != nil {
+			return
+		}
+	}
+	// Sunday is both 0 and 7
+	if e[4]&(1<<7) != 0 {
+		e[4] = e[4]&^(1<<7) | 1
+	}
+	return
+}
+
+// parseField parses a field which is a list of values separated by commas, every value is one of
+// *, H, H(a-b), a and a-b, with an optional step like */n
+func parseField(f field, value string, hashed uint32) (bits uint64, err error) {
+	for _, item := range strings.Split(value, ",") {
+		var itemBits uint64
+		if itemBits, err = parseItem(f, item, hashed); err != nil {
+			return
+		}
+		bits |= itemBits
+	}
+	return
+}
+
+func parseItem(f field, item string, hashed uint32) (bits uint64, err error) {
+	rangePart, step := item, 1
+	if index := strings.Index(item, "/"); index >= 0 {
+		rangePart = item[:index]
+		if step, err = strconv.Atoi(item[index+1:]); err != nil || step <= 0 {
+			err = fmt.Errorf("invalid step %q of the %s", item[index+1:], f.name)
+			return
+		}
+	}
+
+	var low, high int
+	hashedRange := false
+	switch {
+	case rangePart == "*":
+		low, high = f.min, f.max
+	case rangePart == "H":
+		low, high, hashedRange = f.min, f.maxHash, true
+	case strings.HasPrefix(rangePart, "H(") && strings.HasSuffix(rangePart, ")"):
+		if low, high, err = parseRange(f, strings.TrimSuffix(strings.TrimPrefix(rangePart, "H("), ")")); err != nil {
+			return
+		}
+		hashedRange = true
+	default:
+		if low, high, err = parseRange(f, rangePart); err != nil {
+			return
+		}
+		if step > 1 && !strings.Contains(rangePart, "-") {
+			// a/n means from a to the max
+			high = f.max
+		}
+	}
+
+	if hashedRange {
+		if step == 1 && !strings.Contains(item, "/") {
+			// H means a single value in the range
+			value := low + int(hashed%uint32(high-low+1))
+			return 1 << uint(value), nil
+		}
+		// H/n starts from a hashed offset in the range
+		if step > high-low+1 {
+			err = fmt.Errorf("the step %d is larger than the range of the %s", step, f.name)
+			return
+		}
+		low += int(hashed % uint32(step))
+	}
+	for value := low; value <= high; value += step {
+		bits |= 1 << uint(value)
+	}
+	return
+}
+
+func parseRange(f field, value string) (low, high int, err error) {
+	parts := strings.SplitN(value, "-", 2)
+	if low, err = parseValue(f, parts[0]); err != nil {
+		return
+	}
+	high = low
+	if len(parts) == 2 {
+		if high, err = parseValue(f, parts[1]); err != nil {
+			return
+		}
+		if high < low {
+			err = fmt.Errorf("invalid range %q of the %s", value, f.name)
+		}
+	}
+	return
+}
+
+func parseValue(f field, value string) (result int, err error) {
+	if result, err = strconv.Atoi(value); err != nil || result < f.min || result > f.max {
+		err = fmt.Errorf("invalid value %q of the %s, it should be in %d-%d", value, f.name, f.min, f.max)
+	}
+	return
+}
+
+// hash returns a number hashed from the seed, every field has a different one
+func hash(seed string, index int) uint32 {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(seed + "#" + strconv.Itoa(index)))
+	return h.Sum32()
+}
+
+// Next returns the first time after the given one when the schedule fires, in the location of the given time.
+// It's zero if the schedule never fires in the next years.
+func (s *Schedule) Next(t time.Time) (next time.Time) {
+	for _, e := range s.entries {
+		if candidate := e.next(t); !candidate.IsZero() && (next.IsZero() || candidate.Before(next)) {
+			next = candidate
+		}
+	}
+	return
+}
+
+func (e entry) next(t time.Time) time.Time {
+	loc := t.Location()
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	yearLimit := t.Year() + maxSearchYears
+
+	for t.Year() <= yearLimit {
+		if !e.has(3, int(t.Month())) {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if !e.has(2, t.Day()) || !e.has(4, int(t.Weekday())) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if !e.has(1, t.Hour()) {
+			next := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, loc).Add(time.Hour)
+			for next.Hour() == t.Hour() {
+				// the hour is repeated when the daylight saving time ends
+				next = next.Add(time.Hour)
+			}
+			t = next
+			continue
+		}
+		if !e.has(0, t.Minute()) {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+func (e entry) has(index, value int) bool {
+	return e[index]&(1<<uint(value)) != 0
+}
@@ -0,0 +1,195 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cronutil
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		cron    string
+		wantErr bool
+	}{{
+		name: "every minute",
+		cron: "* * * * *",
+	}, {
+		name: "ranges, lists and steps",
+		cron: "0,30 8-18/2 1-15 */3 1-5",
+	}, {
+		name: "hashed values",
+		cron: "H H(0-7) H/3 * H",
+	}, {
+		name: "an alias",
+		cron: "@daily",
+	}, {
+		name: "many lines with comments",
+		cron: "# every morning\nH 8 * * 1-5\n\nH 10 * * 0,6",
+	}, {
+		name:    "empty",
+		cron:    "# nothing\n",
+		wantErr: true,
+	}, {
+		name:    "missing fields",
+		cron:    "* * * *",
+		wantErr: true,
+	}, {
+		name:    "out of range",
+		cron:    "60 * * * *",
+		wantErr: true,
+	}, {
+		name:    "invalid range",
+		cron:    "* 18-8 * * *",
+		wantErr: true,
+	}, {
+		name:    "invalid step",
+		cron:    "*/0 * * * *",
+		wantErr: true,
+	}, {
+		name:    "the step is larger than the hashed range",
+		cron:    "H(0-5)/10 * * * *",
+		wantErr: true,
+	}, {
+		name:    "not a number",
+		cron:    "* * * JAN *",
+		wantErr: true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := Parse(tt.cron, "ns/build")
+			assert.Equal(t, tt.wantErr, err != nil, err)
+			assert.Equal(t, tt.wantErr, schedule == nil)
+		})
+	}
+}
+
+func TestSchedule_Next(t *testing.T) {
+	shanghai, err := time.LoadLocation("Asia/Shanghai")
+	assert.Nil(t, err)
+	newYork, err := time.LoadLocation("America/New_York")
+	assert.Nil(t, err)
+	// 2022-08-01 is a Monday
+	base := time.Date(2022, 8, 1, 10, 30, 15, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		cron string
+		from time.Time
+		want time.Time
+	}{{
+		name: "every minute",
+		cron: "* * * * *",
+		from: base,
+		want: time.Date(2022, 8, 1, 10, 31, 0, 0, time.UTC),
+	}, {
+		name: "never the given time itself",
+		cron: "30 10 * * *",
+		from: time.Date(2022, 8, 1, 10, 30, 0, 0, time.UTC),
+		want: time.Date(2022, 8, 2, 10, 30, 0, 0, time.UTC),
+	}, {
+		name: "every 15 minutes",
+		cron: "*/15 * * * *",
+		from: base,
+		want: time.Date(2022, 8, 1, 10, 45, 0, 0, time.UTC),
+	}, {
+		name: "the next hour",
+		cron: "0 * * * *",
+		from: base,
+		want: time.Date(2022, 8, 1, 11, 0, 0, 0, time.UTC),
+	}, {
+		name: "working days",
+		cron: "0 9 * * 1-5",
+		from: time.Date(2022, 8, 5, 10, 0, 0, 0, time.UTC),
+		want: time.Date(2022, 8, 8, 9, 0, 0, 0, time.UTC),
+	}, {
+		name: "Sunday is 7",
+		cron: "0 0 * * 7",
+		from: base,
+		want: time.Date(2022, 8, 7, 0, 0, 0, 0, time.UTC),
+	}, {
+		name: "both the day of month and the day of week must match",
+		cron: "0 0 13 * 5",
+		from: base,
+		want: time.Date(2023, 1, 13, 0, 0, 0, 0, time.UTC),
+	}, {
+		name: "the next year",
+		cron: "0 0 1 1 *",
+		from: base,
+		want: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+	}, {
+		name: "the earliest line",
+		cron: "0 12 * * *\n45 10 * * *",
+		from: base,
+		want: time.Date(2022, 8, 1, 10, 45, 0, 0, time.UTC),
+	}, {
+		name: "in a time zone",
+		cron: "0 20 * * *",
+		from: base.In(shanghai),
+		want: time.Date(2022, 8, 1, 20, 0, 0, 0, shanghai),
+	}, {
+		name: "skip the missing hour when the daylight saving time starts",
+		cron: "30 2 * * *",
+		from: time.Date(2022, 3, 12, 3, 0, 0, 0, newYork),
+		want: time.Date(2022, 3, 14, 2, 30, 0, 0, newYork),
+	}, {
+		name: "the repeated hour when the daylight saving time ends",
+		cron: "0 2 * * *",
+		from: time.Date(2022, 11, 6, 1, 30, 0, 0, newYork).Add(time.Hour),
+		want: time.Date(2022, 11, 6, 2, 0, 0, 0, newYork),
+	}, {
+		name: "never",
+		cron: "0 0 31 2 *",
+		from: base,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := Parse(tt.cron, "ns/build")
+			if assert.Nil(t, err) {
+				next := schedule.Next(tt.from)
+				assert.True(t, tt.want.Equal(next), "expect %v but got %v", tt.want, next)
+			}
+		})
+	}
+}
+
+func TestParse_hash(t *testing.T) {
+	from := time.Date(2022, 8, 1, 0, 0, 0, 0, time.UTC)
+	first, err := Parse("H H * * *", "ns/build")
+	assert.Nil(t, err)
+	again, err := Parse("H H * * *", "ns/build")
+	assert.Nil(t, err)
+	// the same seed always has the same time
+	assert.Equal(t, first.Next(from), again.Next(from))
+
+	// H is in the range
+	ranged, err := Parse("H(10-14) H(8-9) * * *", "ns/test")
+	assert.Nil(t, err)
+	next := ranged.Next(from)
+	assert.True(t, next.Minute() >= 10 && next.Minute() <= 14, next)
+	assert.True(t, next.Hour() >= 8 && next.Hour() <= 9, next)
+
+	// H/n fires every n minutes from a hashed offset
+	stepped, err := Parse("H/20 * * * *", "ns/build")
+	assert.Nil(t, err)
+	next = stepped.Next(from)
+	assert.Equal(t, 20*time.Minute, stepped.Next(next).Sub(next))
+	assert.True(t, next.Minute() < 20, next)
+}
@@ -0,0 +1,87 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gitutil
+
+import (
+	"net/url"
+	"strings"
+)
+
+// NormalizeURL normalizes the address of a git repository, then the different forms of the same repository are equal.
+// The scheme, user, port and the ".git" suffix are removed, and it's case-insensitive.
+// For instance, all the following addresses are normalized as "github.com/owner/repo":
+//
+//	https://github.com/Owner/Repo
+//	https://github.com/owner/repo.git
+//	git@github.com:owner/repo.git
+//	ssh://git@github.com:22/owner/repo.git
+func NormalizeURL(address string) string {
+	address = strings.ToLower(strings.TrimSpace(address))
+	if address == "" {
+		return ""
+	}
+
+	var host, path string
+	if strings.Contains(address, "://") {
+		gitURL, err := url.Parse(address)
+		if err != nil {
+			return address
+		}
+		host, path = gitURL.Hostname(), gitURL.Path
+	} else if index := strings.Index(address, ":"); index > 0 {
+		// it's a scp-like address, such as: git@github.com:owner/repo.git
+		host, path = address[:index], address[index+1:]
+		if at := strings.LastIndex(host, "@"); at >= 0 {
+			host = host[at+1:]
+		}
+	} else {
+		host = address
+		if slash := strings.Index(address, "/"); slash > 0 {
+			host, path = address[:slash], address[slash:]
+		}
+	}
+
+	path = strings.TrimSuffix(strings.Trim(path, "/"), ".git")
+	path = strings.TrimSuffix(path, "/")
+	if path == "" {
+		return host
+	}
+	return host + "/" + path
+}
+
+// MatchURL returns true if the source matches any of the targets after normalized, the empty ones are ignored
+func MatchURL(source string, targets ...string) bool {
+	if source = NormalizeURL(source); source == "" {
+		return false
+	}
+	for _, target := range targets {
+		if NormalizeURL(target) == source {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchAnyURL returns true if any of the sources matches any of the targets
+func MatchAnyURL(sources []string, targets ...string) bool {
+	for _, source := range sources {
+		if MatchURL(source, targets...) {
+			return true
+		}
+	}
+	return false
+}